@@ -0,0 +1,826 @@
+// Code generated by go generate ./internal/webui; DO NOT EDIT.
+
+package apiclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Bootstrap struct {
+	AssetsHash      string          `json:"assets_hash"`
+	Features        map[string]bool `json:"features"`
+	InstanceID      string          `json:"instance_id"`
+	Limits          map[string]int  `json:"limits"`
+	ManagedByGUI    bool            `json:"managed_by_gui"`
+	ProtocolVersion int             `json:"protocol_version"`
+	Roots           []Root          `json:"roots"`
+	ServerVersion   string          `json:"server_version"`
+	Snapshot        Snapshot        `json:"snapshot"`
+}
+
+type CommandResult struct {
+	RequestID string `json:"request_id"`
+	Revision  uint64 `json:"revision"`
+}
+
+type Device struct {
+	Capabilities []string `json:"capabilities,omitempty"`
+	ID           string   `json:"id"`
+	Label        string   `json:"label"`
+	Protocol     string   `json:"protocol"`
+}
+
+type DeviceList struct {
+	ActiveDeviceID   string   `json:"active_device_id,omitempty"`
+	Devices          []Device `json:"devices"`
+	Revision         uint64   `json:"revision"`
+	SelectedDeviceID string   `json:"selected_device_id,omitempty"`
+}
+
+type Error struct {
+	Error     string `json:"error"`
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Revision  uint64 `json:"revision,omitempty"`
+}
+
+type Group struct {
+	DeviceIDs   []string `json:"device_ids"`
+	ID          string   `json:"id"`
+	Label       string   `json:"label"`
+	ToleranceMS int      `json:"tolerance_ms"`
+}
+
+type GroupList struct {
+	Groups   []Group `json:"groups"`
+	Revision uint64  `json:"revision"`
+}
+
+type GroupResult struct {
+	GroupID   string `json:"group_id"`
+	RequestID string `json:"request_id"`
+	Revision  uint64 `json:"revision"`
+}
+
+type History struct {
+	ContinueWatching []HistoryEntry `json:"continue_watching"`
+	Revision         uint64         `json:"revision"`
+}
+
+type HistoryEntry struct {
+	Duration  int       `json:"duration"`
+	EntryID   string    `json:"entry_id"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	RootID    string    `json:"root_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type LibraryEntry struct {
+	ArtworkURL   string        `json:"artwork_url,omitempty"`
+	Duration     int           `json:"duration,omitempty"`
+	ID           string        `json:"id"`
+	Kind         string        `json:"kind"`
+	Media        *MediaDetails `json:"media,omitempty"`
+	MediaKind    string        `json:"media_kind,omitempty"`
+	Name         string        `json:"name"`
+	PlayCount    int           `json:"play_count,omitempty"`
+	Position     int           `json:"position,omitempty"`
+	ThumbnailURL string        `json:"thumbnail_url,omitempty"`
+	Watched      bool          `json:"watched,omitempty"`
+}
+
+type LibraryPage struct {
+	Cursor  string         `json:"cursor,omitempty"`
+	Entries []LibraryEntry `json:"entries"`
+}
+
+type MediaDetails struct {
+	Album          string   `json:"album,omitempty"`
+	Artist         string   `json:"artist,omitempty"`
+	AudioCodec     string   `json:"audio_codec,omitempty"`
+	AudioLanguages []string `json:"audio_languages,omitempty"`
+	Duration       float64  `json:"duration,omitempty"`
+	Height         int      `json:"height,omitempty"`
+	Subtitles      []string `json:"subtitles,omitempty"`
+	Title          string   `json:"title,omitempty"`
+	VideoCodec     string   `json:"video_codec,omitempty"`
+	Width          int      `json:"width,omitempty"`
+}
+
+type Playlist struct {
+	Content  string `json:"content"`
+	FileName string `json:"file_name"`
+	Format   string `json:"format"`
+	Revision uint64 `json:"revision"`
+}
+
+type Policy struct {
+	AutoPlayNext         bool `json:"AutoPlayNext"`
+	AutoPlaySameType     bool `json:"AutoPlaySameType"`
+	GaplessEnabled       bool `json:"GaplessEnabled"`
+	ImageDurationSeconds int  `json:"ImageDurationSeconds"`
+	LoopSelected         bool `json:"LoopSelected"`
+	RepeatAll            bool `json:"RepeatAll,omitempty"`
+	Shuffle              bool `json:"Shuffle,omitempty"`
+}
+
+type PolicyState struct {
+	Policy   Policy `json:"policy"`
+	Revision uint64 `json:"revision"`
+}
+
+type Queue struct {
+	Queue    []QueueItem `json:"queue"`
+	Revision uint64      `json:"revision"`
+}
+
+type QueueBatchResult struct {
+	Added      int    `json:"added"`
+	Dropped    int    `json:"dropped"`
+	Duplicates int    `json:"duplicates"`
+	Failed     int    `json:"failed"`
+	RequestID  string `json:"request_id"`
+	Revision   uint64 `json:"revision"`
+}
+
+type QueueItem struct {
+	Active   bool   `json:"active"`
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Parent   string `json:"parent,omitempty"`
+	Selected bool   `json:"selected"`
+}
+
+type Root struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type ScheduledStart struct {
+	At          *time.Time `json:"at,omitempty"`
+	DeviceID    string     `json:"device_id,omitempty"`
+	DeviceLabel string     `json:"device_label,omitempty"`
+}
+
+type SearchPage struct {
+	Cursor  string         `json:"cursor,omitempty"`
+	Entries []SearchResult `json:"entries"`
+}
+
+type SearchResult struct {
+	ArtworkURL   string        `json:"artwork_url,omitempty"`
+	Duration     int           `json:"duration,omitempty"`
+	ID           string        `json:"id"`
+	Kind         string        `json:"kind"`
+	Media        *MediaDetails `json:"media,omitempty"`
+	MediaKind    string        `json:"media_kind,omitempty"`
+	Name         string        `json:"name"`
+	Parent       string        `json:"parent"`
+	PlayCount    int           `json:"play_count,omitempty"`
+	Position     int           `json:"position,omitempty"`
+	RootID       string        `json:"root_id"`
+	ThumbnailURL string        `json:"thumbnail_url,omitempty"`
+	Watched      bool          `json:"watched,omitempty"`
+}
+
+type Session struct {
+	ActiveMediaName string `json:"active_media_name,omitempty"`
+	DeviceID        string `json:"device_id,omitempty"`
+	DeviceLabel     string `json:"device_label,omitempty"`
+	Duration        int    `json:"duration"`
+	HasSession      bool   `json:"has_session"`
+	ID              string `json:"id"`
+	Muted           bool   `json:"muted"`
+	PlaybackState   string `json:"playback_state"`
+	Position        int    `json:"position"`
+	Revision        uint64 `json:"revision"`
+	Volume          int    `json:"volume"`
+}
+
+type SessionList struct {
+	Revision uint64    `json:"revision"`
+	Sessions []Session `json:"sessions"`
+}
+
+type SleepTimer struct {
+	Deadline *time.Time `json:"deadline,omitempty"`
+	Mode     string     `json:"mode"`
+}
+
+type Snapshot struct {
+	ActiveDeviceID       string         `json:"active_device_id,omitempty"`
+	ActiveMediaName      string         `json:"active_media_name,omitempty"`
+	ArtworkID            string         `json:"artwork_id"`
+	ContinueWatching     []HistoryEntry `json:"continue_watching"`
+	Devices              []Device       `json:"devices"`
+	Duration             int            `json:"duration"`
+	Groups               []Group        `json:"groups"`
+	HasSession           bool           `json:"has_session"`
+	MediaType            string         `json:"media_type,omitempty"`
+	Muted                bool           `json:"muted"`
+	PlaybackState        string         `json:"playback_state"`
+	Policy               Policy         `json:"policy"`
+	Position             int            `json:"position"`
+	Queue                []QueueItem    `json:"queue"`
+	Revision             uint64         `json:"revision"`
+	ScheduledStart       ScheduledStart `json:"scheduled_start"`
+	SelectedDeviceID     string         `json:"selected_device_id,omitempty"`
+	SelectedMedia        bool           `json:"selected_media"`
+	SelectedMediaName    string         `json:"selected_media_name,omitempty"`
+	SelectedSubtitle     bool           `json:"selected_subtitle"`
+	SelectedSubtitleName string         `json:"selected_subtitle_name,omitempty"`
+	Sessions             []Session      `json:"sessions"`
+	SleepTimer           SleepTimer     `json:"sleep_timer"`
+	Transcode            bool           `json:"transcode"`
+	Volume               int            `json:"volume"`
+}
+
+type Timers struct {
+	Revision       uint64         `json:"revision"`
+	ScheduledStart ScheduledStart `json:"scheduled_start"`
+	SleepTimer     SleepTimer     `json:"sleep_timer"`
+}
+
+// Bootstrap calls GET /api/bootstrap: issue the session cookie and return server state.
+func (c *Client) Bootstrap(ctx context.Context, opts ...Option) (*Bootstrap, error) {
+	var out Bootstrap
+	if err := c.do(ctx, http.MethodGet, "/api/bootstrap", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BrowseLibraryParams holds the query parameters of BrowseLibrary.
+type BrowseLibraryParams struct {
+	Cursor   string
+	Filter   string
+	Limit    int
+	ParentID string
+	RootID   string
+}
+
+// BrowseLibrary calls GET /api/library: browse a media root.
+func (c *Client) BrowseLibrary(ctx context.Context, params BrowseLibraryParams, opts ...Option) (*LibraryPage, error) {
+	query := url.Values{}
+	if params.Cursor != "" {
+		query.Set("cursor", params.Cursor)
+	}
+	if params.Filter != "" {
+		query.Set("filter", params.Filter)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.ParentID != "" {
+		query.Set("parent_id", params.ParentID)
+	}
+	query.Set("root_id", params.RootID)
+	var out LibraryPage
+	if err := c.do(ctx, http.MethodGet, "/api/library", query, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LibraryDetailsParams holds the query parameters of LibraryDetails.
+type LibraryDetailsParams struct {
+	EntryID string
+	RootID  string
+}
+
+// LibraryDetails calls GET /api/library/details: probe a library file for its duration, streams, and tags.
+func (c *Client) LibraryDetails(ctx context.Context, params LibraryDetailsParams, opts ...Option) (*MediaDetails, error) {
+	query := url.Values{}
+	query.Set("entry_id", params.EntryID)
+	query.Set("root_id", params.RootID)
+	var out MediaDetails
+	if err := c.do(ctx, http.MethodGet, "/api/library/details", query, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RecentLibraryParams holds the query parameters of RecentLibrary.
+type RecentLibraryParams struct {
+	Limit int
+}
+
+// RecentLibrary calls GET /api/library/recent: list the media files most recently added to the library index.
+func (c *Client) RecentLibrary(ctx context.Context, params RecentLibraryParams, opts ...Option) (*SearchPage, error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var out SearchPage
+	if err := c.do(ctx, http.MethodGet, "/api/library/recent", query, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SearchLibraryParams holds the query parameters of SearchLibrary.
+type SearchLibraryParams struct {
+	Cursor string
+	Limit  int
+	Q      string
+}
+
+// SearchLibrary calls GET /api/library/search: search media file names in every root.
+func (c *Client) SearchLibrary(ctx context.Context, params SearchLibraryParams, opts ...Option) (*SearchPage, error) {
+	query := url.Values{}
+	if params.Cursor != "" {
+		query.Set("cursor", params.Cursor)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	query.Set("q", params.Q)
+	var out SearchPage
+	if err := c.do(ctx, http.MethodGet, "/api/library/search", query, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDevices calls GET /api/v1/devices: read devices.
+func (c *Client) GetDevices(ctx context.Context, opts ...Option) (*DeviceList, error) {
+	var out DeviceList
+	if err := c.do(ctx, http.MethodGet, "/api/v1/devices", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DevicesRefresh calls POST /api/v1/devices/refresh: run the devices.refresh command.
+func (c *Client) DevicesRefresh(ctx context.Context, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/devices/refresh", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DevicesSelectRequest is the request body of DevicesSelect.
+type DevicesSelectRequest struct {
+	DeviceID string `json:"device_id"`
+}
+
+// DevicesSelect calls POST /api/v1/devices/select: run the devices.select command.
+func (c *Client) DevicesSelect(ctx context.Context, body DevicesSelectRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/devices/select", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GroupsRemoveRequest is the request body of GroupsRemove.
+type GroupsRemoveRequest struct {
+	GroupID string `json:"group_id"`
+}
+
+// GroupsRemove calls DELETE /api/v1/groups: run the groups.remove command.
+func (c *Client) GroupsRemove(ctx context.Context, body GroupsRemoveRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodDelete, "/api/v1/groups", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetGroups calls GET /api/v1/groups: read groups.
+func (c *Client) GetGroups(ctx context.Context, opts ...Option) (*GroupList, error) {
+	var out GroupList
+	if err := c.do(ctx, http.MethodGet, "/api/v1/groups", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GroupsCreateRequest is the request body of GroupsCreate.
+type GroupsCreateRequest struct {
+	DeviceIDs   []string `json:"device_ids"`
+	Name        *string  `json:"name,omitempty"`
+	ToleranceMS *int     `json:"tolerance_ms,omitempty"`
+}
+
+// GroupsCreate calls POST /api/v1/groups: run the groups.create command.
+func (c *Client) GroupsCreate(ctx context.Context, body GroupsCreateRequest, opts ...Option) (*GroupResult, error) {
+	var out GroupResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/groups", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetHistory calls GET /api/v1/history: read history.
+func (c *Client) GetHistory(ctx context.Context, opts ...Option) (*History, error) {
+	var out History
+	if err := c.do(ctx, http.MethodGet, "/api/v1/history", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LibraryMarkRequest is the request body of LibraryMark.
+type LibraryMarkRequest struct {
+	EntryID *string `json:"entry_id,omitempty"`
+	RootID  string  `json:"root_id"`
+	Watched bool    `json:"watched"`
+}
+
+// LibraryMark calls POST /api/v1/library/mark: run the library.mark command.
+func (c *Client) LibraryMark(ctx context.Context, body LibraryMarkRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/library/mark", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LibraryPlayRequest is the request body of LibraryPlay.
+type LibraryPlayRequest struct {
+	EntryID string `json:"entry_id"`
+	Resume  *bool  `json:"resume,omitempty"`
+	RootID  string `json:"root_id"`
+}
+
+// LibraryPlay calls POST /api/v1/library/play: run the library.play command.
+func (c *Client) LibraryPlay(ctx context.Context, body LibraryPlayRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/library/play", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LibrarySelectMediaRequest is the request body of LibrarySelectMedia.
+type LibrarySelectMediaRequest struct {
+	EntryID string `json:"entry_id"`
+	RootID  string `json:"root_id"`
+}
+
+// LibrarySelectMedia calls POST /api/v1/library/select: run the library.select_media command.
+func (c *Client) LibrarySelectMedia(ctx context.Context, body LibrarySelectMediaRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/library/select", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LibraryClearSubtitle calls DELETE /api/v1/library/subtitle: run the library.clear_subtitle command.
+func (c *Client) LibraryClearSubtitle(ctx context.Context, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodDelete, "/api/v1/library/subtitle", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LibrarySelectSubtitleRequest is the request body of LibrarySelectSubtitle.
+type LibrarySelectSubtitleRequest struct {
+	EntryID string `json:"entry_id"`
+	RootID  string `json:"root_id"`
+}
+
+// LibrarySelectSubtitle calls POST /api/v1/library/subtitle: run the library.select_subtitle command.
+func (c *Client) LibrarySelectSubtitle(ctx context.Context, body LibrarySelectSubtitleRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/library/subtitle", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerMuteRequest is the request body of PlayerMute.
+type PlayerMuteRequest struct {
+	Muted bool `json:"muted"`
+}
+
+// PlayerMute calls POST /api/v1/player/mute: run the player.mute command.
+func (c *Client) PlayerMute(ctx context.Context, body PlayerMuteRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/mute", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerPause calls POST /api/v1/player/pause: run the player.pause command.
+func (c *Client) PlayerPause(ctx context.Context, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/pause", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerPlayRequest is the request body of PlayerPlay.
+type PlayerPlayRequest struct {
+	ItemID *string `json:"item_id,omitempty"`
+}
+
+// PlayerPlay calls POST /api/v1/player/play: run the player.play command.
+func (c *Client) PlayerPlay(ctx context.Context, body PlayerPlayRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/play", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerResume calls POST /api/v1/player/resume: run the player.resume command.
+func (c *Client) PlayerResume(ctx context.Context, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/resume", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerScheduleRequest is the request body of PlayerSchedule.
+type PlayerScheduleRequest struct {
+	At       *time.Time `json:"at,omitempty"`
+	DeviceID *string    `json:"device_id,omitempty"`
+}
+
+// PlayerSchedule calls POST /api/v1/player/schedule: run the player.schedule command.
+func (c *Client) PlayerSchedule(ctx context.Context, body PlayerScheduleRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/schedule", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerSeekRequest is the request body of PlayerSeek.
+type PlayerSeekRequest struct {
+	Seconds uint64 `json:"seconds"`
+}
+
+// PlayerSeek calls POST /api/v1/player/seek: run the player.seek command.
+func (c *Client) PlayerSeek(ctx context.Context, body PlayerSeekRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/seek", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerSleepRequest is the request body of PlayerSleep.
+type PlayerSleepRequest struct {
+	Minutes *int   `json:"minutes,omitempty"`
+	Mode    string `json:"mode"`
+}
+
+// PlayerSleep calls POST /api/v1/player/sleep: run the player.sleep command.
+func (c *Client) PlayerSleep(ctx context.Context, body PlayerSleepRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/sleep", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerStop calls POST /api/v1/player/stop: run the player.stop command.
+func (c *Client) PlayerStop(ctx context.Context, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/stop", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerTranscodeRequest is the request body of PlayerTranscode.
+type PlayerTranscodeRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// PlayerTranscode calls POST /api/v1/player/transcode: run the player.transcode command.
+func (c *Client) PlayerTranscode(ctx context.Context, body PlayerTranscodeRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/transcode", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerTransferRequest is the request body of PlayerTransfer.
+type PlayerTransferRequest struct {
+	DeviceID string `json:"device_id"`
+}
+
+// PlayerTransfer calls POST /api/v1/player/transfer: run the player.transfer command.
+func (c *Client) PlayerTransfer(ctx context.Context, body PlayerTransferRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/transfer", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerVolumeRequest is the request body of PlayerVolume.
+type PlayerVolumeRequest struct {
+	Delta  *int `json:"delta,omitempty"`
+	Volume *int `json:"volume,omitempty"`
+}
+
+// PlayerVolume calls POST /api/v1/player/volume: run the player.volume command.
+func (c *Client) PlayerVolume(ctx context.Context, body PlayerVolumeRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/player/volume", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPolicy calls GET /api/v1/policy: read policy.
+func (c *Client) GetPolicy(ctx context.Context, opts ...Option) (*PolicyState, error) {
+	var out PolicyState
+	if err := c.do(ctx, http.MethodGet, "/api/v1/policy", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlaybackPolicyRequest is the request body of PlaybackPolicy.
+type PlaybackPolicyRequest struct {
+	Policy Policy `json:"policy"`
+}
+
+// PlaybackPolicy calls PUT /api/v1/policy: run the playback.policy command.
+func (c *Client) PlaybackPolicy(ctx context.Context, body PlaybackPolicyRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPut, "/api/v1/policy", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QueueClear calls DELETE /api/v1/queue: run the queue.clear command.
+func (c *Client) QueueClear(ctx context.Context, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodDelete, "/api/v1/queue", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetQueue calls GET /api/v1/queue: read queue.
+func (c *Client) GetQueue(ctx context.Context, opts ...Option) (*Queue, error) {
+	var out Queue
+	if err := c.do(ctx, http.MethodGet, "/api/v1/queue", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QueueAddRequest is the request body of QueueAdd.
+type QueueAddRequest struct {
+	EntryID string `json:"entry_id"`
+	RootID  string `json:"root_id"`
+}
+
+// QueueAdd calls POST /api/v1/queue: run the queue.add command.
+func (c *Client) QueueAdd(ctx context.Context, body QueueAddRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/queue", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QueueAddManyRequest is the request body of QueueAddMany.
+type QueueAddManyRequest struct {
+	EntryIDs []string `json:"entry_ids"`
+	RootID   string   `json:"root_id"`
+}
+
+// QueueAddMany calls POST /api/v1/queue/batch: run the queue.add_many command.
+func (c *Client) QueueAddMany(ctx context.Context, body QueueAddManyRequest, opts ...Option) (*QueueBatchResult, error) {
+	var out QueueBatchResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/queue/batch", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetQueueExportParams holds the query parameters of GetQueueExport.
+type GetQueueExportParams struct {
+	Format string
+}
+
+// GetQueueExport calls GET /api/v1/queue/export: read queue/export.
+func (c *Client) GetQueueExport(ctx context.Context, params GetQueueExportParams, opts ...Option) (*Playlist, error) {
+	query := url.Values{}
+	if params.Format != "" {
+		query.Set("format", params.Format)
+	}
+	var out Playlist
+	if err := c.do(ctx, http.MethodGet, "/api/v1/queue/export", query, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QueueImportRequest is the request body of QueueImport.
+type QueueImportRequest struct {
+	Content string `json:"content"`
+	Format  string `json:"format"`
+}
+
+// QueueImport calls POST /api/v1/queue/import: run the queue.import command.
+func (c *Client) QueueImport(ctx context.Context, body QueueImportRequest, opts ...Option) (*QueueBatchResult, error) {
+	var out QueueBatchResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/queue/import", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QueueMoveRequest is the request body of QueueMove.
+type QueueMoveRequest struct {
+	Delta  int    `json:"delta"`
+	ItemID string `json:"item_id"`
+}
+
+// QueueMove calls POST /api/v1/queue/move: run the queue.move command.
+func (c *Client) QueueMove(ctx context.Context, body QueueMoveRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/queue/move", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QueueRemoveRequest is the request body of QueueRemove.
+type QueueRemoveRequest struct {
+	ItemID string `json:"item_id"`
+}
+
+// QueueRemove calls POST /api/v1/queue/remove: run the queue.remove command.
+func (c *Client) QueueRemove(ctx context.Context, body QueueRemoveRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/queue/remove", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QueueSelectRequest is the request body of QueueSelect.
+type QueueSelectRequest struct {
+	ItemID string `json:"item_id"`
+}
+
+// QueueSelect calls POST /api/v1/queue/select: run the queue.select command.
+func (c *Client) QueueSelect(ctx context.Context, body QueueSelectRequest, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/queue/select", nil, body, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SessionClose calls DELETE /api/v1/sessions: run the session.close command.
+func (c *Client) SessionClose(ctx context.Context, opts ...Option) (*CommandResult, error) {
+	var out CommandResult
+	if err := c.do(ctx, http.MethodDelete, "/api/v1/sessions", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSessions calls GET /api/v1/sessions: read sessions.
+func (c *Client) GetSessions(ctx context.Context, opts ...Option) (*SessionList, error) {
+	var out SessionList
+	if err := c.do(ctx, http.MethodGet, "/api/v1/sessions", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetState calls GET /api/v1/state: read state.
+func (c *Client) GetState(ctx context.Context, opts ...Option) (*Snapshot, error) {
+	var out Snapshot
+	if err := c.do(ctx, http.MethodGet, "/api/v1/state", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTimers calls GET /api/v1/timers: read timers.
+func (c *Client) GetTimers(ctx context.Context, opts ...Option) (*Timers, error) {
+	var out Timers
+	if err := c.do(ctx, http.MethodGet, "/api/v1/timers", nil, nil, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
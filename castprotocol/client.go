@@ -17,9 +17,12 @@ import (
 	"go2tv.app/go2tv/v2/castprotocol/v2/application"
 	"go2tv.app/go2tv/v2/castprotocol/v2/cast"
 	"go2tv.app/go2tv/v2/internal/logging"
+	"go2tv.app/go2tv/v2/internal/metrics"
 	"go2tv.app/go2tv/v2/metadata"
 )
 
+var reconnects = metrics.NewCounter("go2tv_chromecast_reconnects_total", "Chromecast connections reopened before loading media.")
+
 // CastClient wraps go-chromecast Application for simplified API
 type CastClient struct {
 	app         *application.Application
@@ -251,6 +254,7 @@ func (c *CastClient) LoadMedia(req LoadRequest) error {
 	// This handles cases where Close() was called but the client is being reused
 	if !c.IsConnected() {
 		c.Log().Debug("connection closed, reconnecting", "Method", "Load")
+		reconnects.Inc()
 		if err := c.Connect(); err != nil {
 			return fmt.Errorf("reconnect before load: %w", err)
 		}
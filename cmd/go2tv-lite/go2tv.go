@@ -106,6 +106,12 @@ func run(crash *crashlog.Session) error {
 	if err := serverOptions.Validate(flag.CommandLine); err != nil {
 		return err
 	}
+	if handled, err := serverOptions.RunAuthCommand(os.Stdin, os.Stdout); handled {
+		return err
+	}
+	if handled, err := serverOptions.RunRemoteCommand(exitCTX, os.Stdout); handled {
+		return err
+	}
 	if serverOptions.Server {
 		return servermode.Run(exitCTX, serverOptions.Config(version), os.Stdout)
 	}
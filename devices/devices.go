@@ -32,11 +32,15 @@ type Device struct {
 	Addr        string
 	Type        string
 	IsAudioOnly bool
+	// SinkProtocolInfo is the comma-separated GetProtocolInfo Sink list of
+	// a DLNA renderer, empty when it did not report one.
+	SinkProtocolInfo string
 }
 
 type deviceEntry struct {
 	name string
 	addr string
+	sink string
 }
 
 var ErrNoDeviceAvailable = errors.New("loadSSDPservices: No available Media Renderers")
@@ -44,6 +48,7 @@ var ErrNoDeviceAvailable = errors.New("loadSSDPservices: No available Media Rend
 var (
 	ssdpSearch              = ssdp.Search
 	loadDevicesFromLocation = soapcalls.LoadDevicesFromLocation
+	loadSinkProtocolInfo    = soapcalls.LoadSinkProtocolInfo
 	interfaceAddrs          = net.Interfaces
 	ifaceAddrLookup         = func(iface net.Interface) ([]net.Addr, error) { return iface.Addrs() }
 	listenUDP               = net.ListenUDP
@@ -53,8 +58,15 @@ var (
 	dlnaDevices             []Device
 	dlnaMu                  sync.RWMutex
 	discoveryStartOnce      sync.Once
+	// sinkProtocols caches each renderer's Sink list by location and UDN,
+	// so repeated scans ask a renderer once.
+	sinkProtocols   = make(map[string]string)
+	sinkProtocolsMu sync.Mutex
 )
 
+// maxSinkProtocols bounds the sink list cache; it starts over when full.
+const maxSinkProtocols = 256
+
 type summaryState struct {
 	lastLine string
 	repeats  int
@@ -261,14 +273,14 @@ func LoadSSDPservicesContext(ctx context.Context, delay int) ([]Device, error) {
 					name = "Unknown Device"
 					unnamedDevices++
 				}
-				allDevices = append(allDevices, deviceEntry{name: name, addr: loc})
+				allDevices = append(allDevices, deviceEntry{name: name, addr: loc, sink: cachedSinkProtocolInfo(ctx, loc, dev)})
 			}
 		})
 	}
 	wg.Wait()
 
 	// Handle duplicate names
-	deviceList := make(map[string]string)
+	deviceList := make(map[string]deviceEntry)
 	dupNames := make(map[string]int)
 	for _, dev := range allDevices {
 		fn := dev.name
@@ -278,16 +290,16 @@ func LoadSSDPservicesContext(ctx context.Context, delay int) ([]Device, error) {
 			fn = fn + " (" + dev.addr + ")"
 		}
 
-		deviceList[fn] = dev.addr
+		deviceList[fn] = dev
 	}
 
 	for fn, c := range dupNames {
 		if c > 1 {
 			dupNameCount++
-			loc := deviceList[fn]
+			dev := deviceList[fn]
 			delete(deviceList, fn)
-			fn = fn + " (" + loc + ")"
-			deviceList[fn] = loc
+			fn = fn + " (" + dev.addr + ")"
+			deviceList[fn] = dev
 		}
 	}
 
@@ -310,11 +322,12 @@ func LoadSSDPservicesContext(ctx context.Context, delay int) ([]Device, error) {
 
 	// Convert map to Device slice with proper type
 	result := make([]Device, 0, len(deviceList))
-	for name, addr := range deviceList {
+	for name, dev := range deviceList {
 		result = append(result, Device{
-			Name: name,
-			Addr: addr,
-			Type: DeviceTypeDLNA,
+			Name:             name,
+			Addr:             dev.addr,
+			Type:             DeviceTypeDLNA,
+			SinkProtocolInfo: dev.sink,
 		})
 	}
 	discoverySummaryf(
@@ -406,6 +419,34 @@ func getDLNADevices() []Device {
 	return slices.Clone(dlnaDevices)
 }
 
+// cachedSinkProtocolInfo returns the Sink list of dev, found at loc, asking
+// the renderer only when it is not cached. Failed requests are not cached.
+func cachedSinkProtocolInfo(ctx context.Context, loc string, dev *soapcalls.DMRextracted) string {
+	key := loc + "\x00" + dev.UDN
+	sinkProtocolsMu.Lock()
+	sink, ok := sinkProtocols[key]
+	sinkProtocolsMu.Unlock()
+	if ok || dev.ConnectionManagerURL == "" {
+		return sink
+	}
+
+	sinkCtx, cancel := context.WithTimeout(ctx, dlnaLocationTimeout)
+	defer cancel()
+	sink, err := loadSinkProtocolInfo(sinkCtx, dev)
+	if err != nil || sink == "" {
+		discoveryDebugf("GetProtocolInfo failed for %s: %v", locationHost(loc), err)
+		return ""
+	}
+
+	sinkProtocolsMu.Lock()
+	if len(sinkProtocols) >= maxSinkProtocols {
+		clear(sinkProtocols)
+	}
+	sinkProtocols[key] = sink
+	sinkProtocolsMu.Unlock()
+	return sink
+}
+
 func isDLNADeviceCastable(dev *soapcalls.DMRextracted) bool {
 	if dev == nil {
 		return false
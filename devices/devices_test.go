@@ -17,9 +17,12 @@ import (
 func TestLoadSSDPServicesDetectsNonAVTransportST(t *testing.T) {
 	origSearch := ssdpSearch
 	origLoad := loadDevicesFromLocation
+	origSink := loadSinkProtocolInfo
 	t.Cleanup(func() {
 		ssdpSearch = origSearch
 		loadDevicesFromLocation = origLoad
+		loadSinkProtocolInfo = origSink
+		clear(sinkProtocols)
 	})
 
 	ssdpSearch = func(searchType string, waitSec int, localAddr string) ([]ssdp.Service, error) {
@@ -41,14 +44,29 @@ func TestLoadSSDPServicesDetectsNonAVTransportST(t *testing.T) {
 				FriendlyName:          "Sonos One",
 				AvtransportControlURL: "http://sonos.local:1400/MediaRenderer/AVTransport/Control",
 				ConnectionManagerURL:  "http://sonos.local:1400/MediaRenderer/ConnectionManager/Control",
+				UDN:                   "uuid:sonos",
 			},
 		}, nil
 	}
+	sinkCalls := 0
+	loadSinkProtocolInfo = func(ctx context.Context, dev *soapcalls.DMRextracted) (string, error) {
+		sinkCalls++
+		return "http-get:*:audio/mpeg:*", nil
+	}
 
 	devs, err := LoadSSDPservices(1)
 	if err != nil {
 		t.Fatalf("LoadSSDPservices() err = %v, want nil", err)
 	}
+	if _, err := LoadSSDPservices(1); err != nil {
+		t.Fatalf("second LoadSSDPservices() err = %v, want nil", err)
+	}
+	if sinkCalls != 1 {
+		t.Fatalf("GetProtocolInfo calls = %d, want 1 across scans", sinkCalls)
+	}
+	if devs[0].SinkProtocolInfo != "http-get:*:audio/mpeg:*" {
+		t.Fatalf("LoadSSDPservices() sink = %q", devs[0].SinkProtocolInfo)
+	}
 
 	if len(devs) != 1 {
 		t.Fatalf("LoadSSDPservices() len = %d, want 1", len(devs))
@@ -481,6 +481,10 @@ func serveContent(w http.ResponseWriter, r *http.Request, tv *soapcalls.TVPayloa
 		w.Header()["Content-Type"] = []string{mediaType}
 	}
 
+	if tv != nil && strings.Contains(tv.SubtitlesURL, "srt") && tv.Quirks.Lookup(tv.Renderer).CaptionInfoHeader {
+		w.Header()["CaptionInfo.sec"] = []string{tv.SubtitlesURL}
+	}
+
 	switch f := mf.(type) {
 	case osFileType:
 		serveContentCustomType(w, r, tv, tcOpts, mediaType, transcode, seek, f, ff)
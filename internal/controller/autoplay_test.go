@@ -36,6 +36,11 @@ func newAutoplayClock() *autoplayClock {
 	return &autoplayClock{timers: make(chan *autoplayTimer, 4)}
 }
 
+// autoplayNow is the fixed wall-clock time an autoplayClock reports.
+var autoplayNow = time.Date(2026, 3, 1, 21, 30, 0, 0, time.UTC)
+
+func (*autoplayClock) Now() time.Time { return autoplayNow }
+
 func (*autoplayClock) NewTicker(time.Duration) playback.Ticker {
 	return &autoplayTicker{ch: make(chan time.Time)}
 }
@@ -171,6 +176,82 @@ func TestAutoplayQueueTraversalMatchesDesktop(t *testing.T) {
 	}
 }
 
+func TestAutoplayShuffleVisitsEveryItemOnce(t *testing.T) {
+	device := playback.Device{ID: "renderer", Protocol: "Chromecast"}
+	c, _, _ := newTestController(device)
+	defer c.Close()
+	awaitDevices(t, c, 1)
+	c.SelectDevice(context.Background(), Mutation{}, device.ID)
+	addTestQueue(t, c,
+		testMedia("a.mp3", mediamodel.MediaKindAudio),
+		testMedia("b.mp3", mediamodel.MediaKindAudio),
+		testMedia("c.mp3", mediamodel.MediaKindAudio),
+		testMedia("d.mp3", mediamodel.MediaKindAudio),
+	)
+	queued, _ := c.Snapshot(context.Background())
+	if result := c.SetPolicy(context.Background(), PolicyRequest{Policy: Policy{AutoPlayNext: true, Shuffle: true, ImageDurationSeconds: 10}}); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.Play(context.Background(), PlayRequest{QueueItemID: queued.Queue[0].ID}); !result.OK() {
+		t.Fatal(result)
+	}
+	activeID := func(snapshot Snapshot) string {
+		for _, item := range snapshot.Queue {
+			if item.IsActive {
+				return item.ID
+			}
+		}
+		return ""
+	}
+	playing, _ := c.Snapshot(context.Background())
+	played := []string{activeID(playing)}
+	for len(played) < len(queued.Queue) {
+		c.HandleMonitorEvent(context.Background(), playback.MonitorEvent{Generation: playing.Generation, Terminal: playback.TerminalFinished})
+		previous := playing.Generation
+		playing = awaitAutoplaySnapshot(t, c, func(snapshot Snapshot) bool {
+			return snapshot.Generation > previous && snapshot.HasSession && activeID(snapshot) != ""
+		})
+		if slices.Contains(played, activeID(playing)) {
+			t.Fatalf("shuffle replayed %s after %v", activeID(playing), played)
+		}
+		played = append(played, activeID(playing))
+	}
+	c.HandleMonitorEvent(context.Background(), playback.MonitorEvent{Generation: playing.Generation, Terminal: playback.TerminalFinished})
+	after := awaitAutoplaySnapshot(t, c, func(snapshot Snapshot) bool {
+		return !snapshot.HasSession && snapshot.TerminalReason == playback.TerminalFinished
+	})
+	if after.Generation != playing.Generation {
+		t.Fatalf("shuffle continued past the last item: %#v", after)
+	}
+}
+
+func TestAutoplayShuffleRepeatAllStartsOver(t *testing.T) {
+	device := playback.Device{ID: "renderer", Protocol: "Chromecast"}
+	c, _, _ := newTestController(device)
+	defer c.Close()
+	awaitDevices(t, c, 1)
+	c.SelectDevice(context.Background(), Mutation{}, device.ID)
+	addTestQueue(t, c,
+		testMedia("a.mp3", mediamodel.MediaKindAudio),
+		testMedia("b.mp3", mediamodel.MediaKindAudio),
+	)
+	queued, _ := c.Snapshot(context.Background())
+	if result := c.SetPolicy(context.Background(), PolicyRequest{Policy: Policy{AutoPlayNext: true, Shuffle: true, RepeatAll: true, ImageDurationSeconds: 10}}); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.Play(context.Background(), PlayRequest{QueueItemID: queued.Queue[0].ID}); !result.OK() {
+		t.Fatal(result)
+	}
+	playing, _ := c.Snapshot(context.Background())
+	for _, want := range []int{1, 0} {
+		c.HandleMonitorEvent(context.Background(), playback.MonitorEvent{Generation: playing.Generation, Terminal: playback.TerminalFinished})
+		previous := playing.Generation
+		playing = awaitAutoplaySnapshot(t, c, func(snapshot Snapshot) bool {
+			return snapshot.Generation > previous && snapshot.HasSession && snapshot.Queue[want].IsActive
+		})
+	}
+}
+
 func TestDLNAGaplessPromotesQueuedNextWithoutReload(t *testing.T) {
 	device := playback.Device{ID: "renderer", Protocol: "DLNA"}
 	c, log, factory := newTestController(device)
@@ -0,0 +1,73 @@
+package controller
+
+import "sync"
+
+// Change is one committed revision of a session, as delivered by Subscribe.
+type Change struct {
+	// Session is the device ID of an additional session, or empty for the
+	// default session.
+	Session string
+	// Snapshot is the session state right after the commit. Sessions is not
+	// filled in.
+	Snapshot Snapshot
+}
+
+// changeFeed fans committed snapshots out to subscribers. A Controller and its
+// additional sessions share one, so a single subscription sees them all.
+type changeFeed struct {
+	mu          sync.Mutex
+	next        uint64
+	subscribers map[uint64]chan Change
+}
+
+// Subscribe delivers a Change after every actor step that commits a new
+// revision, in commit order per session. Snapshots are only built while
+// someone is subscribed. A subscriber that lets buffer fill up misses changes
+// until it catches up; the unsubscribe function closes the channel.
+func (c *Controller) Subscribe(buffer int) (<-chan Change, func()) {
+	if buffer <= 0 {
+		buffer = 1
+	}
+	f := c.changes
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	id := f.next
+	ch := make(chan Change, buffer)
+	if f.subscribers == nil {
+		f.subscribers = make(map[uint64]chan Change)
+	}
+	f.subscribers[id] = ch
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subscribers, id)
+			close(ch)
+			f.mu.Unlock()
+		})
+	}
+}
+
+// publish runs on the actor of the session that committed, so it never
+// blocks on a subscriber.
+func (f *changeFeed) publish(s *actorState) {
+	f.mu.Lock()
+	idle := len(f.subscribers) == 0
+	f.mu.Unlock()
+	if idle {
+		return
+	}
+	change := Change{Snapshot: s.snapshot()}
+	if pinned := s.controller.pinned; pinned != nil {
+		change.Session = pinned.ID
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.subscribers {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
@@ -102,6 +102,15 @@ func TestInputValidationContracts(t *testing.T) {
 	if err := (Policy{GaplessEnabled: true}).Validate(); !errors.Is(err, ErrInvalidPolicy) {
 		t.Fatalf("gapless without autoplay: %v", err)
 	}
+	if err := (Policy{Shuffle: true}).Validate(); !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("shuffle without autoplay: %v", err)
+	}
+	if err := (Policy{AutoPlayNext: true, RepeatAll: true, Shuffle: true}).Validate(); err != nil {
+		t.Fatalf("shuffled repeat: %v", err)
+	}
+	if err := (Policy{AutoPlayNext: true, RepeatAll: true}).Validate(); !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("repeat without shuffle: %v", err)
+	}
 	if err := (Config{OperationTimeout: -time.Second}).Validate(); !errors.Is(err, ErrInvalidConfig) {
 		t.Fatalf("negative timeout: %v", err)
 	}
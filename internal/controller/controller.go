@@ -15,8 +15,10 @@ import (
 
 	"go2tv.app/go2tv/v2/httphandlers"
 	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/metrics"
 	"go2tv.app/go2tv/v2/internal/playback"
 	"go2tv.app/go2tv/v2/metadata"
+	"go2tv.app/go2tv/v2/soapcalls"
 )
 
 const (
@@ -58,9 +60,13 @@ type activeSession struct {
 	imageEpoch       uint64
 	seekOffset       int
 	expectedDuration int
+	stream           playback.StreamDecision
 	queued           *gaplessSession
 	gaplessActive    atomic.Bool
 	gaplessQueueing  bool
+	resumeSaved      int
+	resumeSavedAt    time.Time
+	resumeFinished   bool
 }
 
 type gaplessCandidate struct {
@@ -79,6 +85,7 @@ type gaplessSession struct {
 	load      playback.LoadRequest
 	routeIDs  []string
 	transcode bool
+	stream    playback.StreamDecision
 }
 
 const (
@@ -119,32 +126,46 @@ func playbackTime(seconds int) string {
 }
 
 type actorState struct {
-	controller   *Controller
-	revision     uint64
-	devices      []playback.Device
-	selectedID   string
+	controller *Controller
+	revision   uint64
+	// discovered is the latest discovery list; devices adds the groups.
+	discovered []playback.Device
+	devices    []playback.Device
+	selectedID string
+	// preferred is a restored device selection waiting for discovery to
+	// report a device with the same protocol and endpoint.
+	preferred    *playback.Device
 	media        MediaRef
 	subtitle     SubtitleRef
 	mediaQueueID string
 	queue        *mediamodel.Queue
 	queueRefs    map[string]MediaRef
-	transcode    bool
-	policy       Policy
-	active       *activeSession
-	pending      *playOperation
-	generation   uint64
-	mutation     bool
-	refreshing   bool
-	state        string
-	position     int
-	duration     int
-	volume       int
-	muted        bool
-	artworkID    string
-	lastError    string
-	terminal     playback.TerminalReason
-	deferred     *playback.MonitorEvent
-	cleanup      bool
+	// shuffle is the play order of queue item IDs while Policy.Shuffle is on.
+	shuffle    []string
+	transcode  bool
+	policy     Policy
+	active     *activeSession
+	pending    *playOperation
+	generation uint64
+	mutation   bool
+	refreshing bool
+	state      string
+	position   int
+	duration   int
+	volume     int
+	muted      bool
+	artworkID  string
+	lastError  string
+	terminal   playback.TerminalReason
+	deferred   *playback.MonitorEvent
+	cleanup    bool
+	// sleep and schedule are armed by SetSleepTimer and ScheduleStart. Their
+	// cancel functions stop the goroutine waiting on the Clock.
+	sleep          SleepTimer
+	sleepCancel    context.CancelFunc
+	schedule       ScheduledStart
+	scheduleDevice playback.Device
+	scheduleCancel context.CancelFunc
 	// gaplessUnsupported remembers renderers that reported they cannot stage a
 	// next URI, so later tracks fall back to ordinary autoplay instead of
 	// re-attempting gapless queueing on every session.
@@ -161,24 +182,39 @@ type Controller struct {
 	stopped   chan struct{}
 	closeOnce sync.Once
 	lifecycle sync.WaitGroup
+	// pinned is the device of an additional session. It is nil on the default
+	// session, which owns the additional ones.
+	pinned         *playback.Device
+	groups         *groupRegistry
+	changes        *changeFeed
+	sessionsMu     sync.Mutex
+	sessions       map[string]*session
+	sessionsClosed bool
 }
 
 // New creates and starts a Controller. Config is copied and safe defaults are
 // applied. Use Config.Validate before New when strict startup validation is
 // required. The returned Controller owns all goroutines and opened transports.
 func New(cfg Config) *Controller {
+	return start(cfg, nil, &groupRegistry{}, &changeFeed{})
+}
+
+func start(cfg Config, pinned *playback.Device, groups *groupRegistry, changes *changeFeed) *Controller {
 	if cfg.OperationTimeout <= 0 {
 		cfg.OperationTimeout = defaultOperationTimeout
 	}
 	if cfg.Artwork == nil {
 		cfg.Artwork = NewArtworkCache(ArtworkCacheBytes)
 	}
+	if cfg.Clock == nil {
+		cfg.Clock = playback.SystemClock()
+	}
 	parent := cfg.ParentContext
 	if parent == nil {
 		parent = context.Background()
 	}
 	ctx, cancel := context.WithCancelCause(parent)
-	c := &Controller{cfg: cfg, ctx: ctx, cancel: cancel, queue: make(chan message, actorQueueSize), callbacks: make(chan playback.MonitorEvent, callbackQueueSize), done: make(chan struct{}), stopped: make(chan struct{})}
+	c := &Controller{cfg: cfg, ctx: ctx, cancel: cancel, queue: make(chan message, actorQueueSize), callbacks: make(chan playback.MonitorEvent, callbackQueueSize), done: make(chan struct{}), stopped: make(chan struct{}), pinned: pinned, groups: groups, changes: changes}
 	go c.run()
 	c.goOwned(c.forwardCallbacks)
 	if cfg.Discovery != nil {
@@ -205,6 +241,7 @@ func New(cfg Config) *Controller {
 	go func() {
 		<-c.done
 		c.lifecycle.Wait()
+		c.closeSessions()
 		close(c.stopped)
 	}()
 	return c
@@ -217,6 +254,10 @@ func (c *Controller) goOwned(fn func()) {
 func (c *Controller) run() {
 	defer close(c.done)
 	s := &actorState{controller: c, policy: DefaultPolicy(), state: PlaybackStateStopped, volume: 100}
+	if c.pinned != nil {
+		s.discovered = c.cfg.Discovery.Snapshot()
+		s.devices, s.selectedID = c.groups.withGroups(s.discovered), c.pinned.ID
+	}
 	for {
 		select {
 		case <-c.ctx.Done():
@@ -227,15 +268,23 @@ func (c *Controller) run() {
 				ctx, cancel := context.WithTimeout(context.Background(), c.cfg.OperationTimeout)
 				_ = teardownSession(ctx, s.active, c.cfg.MediaServer, c.cfg.OperationTimeout)
 				cancel()
+				// Subscribers still see a closed session end.
+				s.active, s.state, s.terminal = nil, PlaybackStateStopped, playback.TerminalShutdown
+				s.commit()
+				c.changes.publish(s)
 			}
 			return
 		case msg := <-c.queue:
+			revision := s.revision
 			if msg.call == nil || msg.call.state.CompareAndSwap(actorCallPending, actorCallRunning) {
 				msg.fn(s)
 			}
 			if msg.done != nil {
 				close(msg.done)
 			}
+			if s.revision != revision {
+				c.changes.publish(s)
+			}
 		}
 	}
 }
@@ -304,9 +353,19 @@ func (c *Controller) enqueueInternal(msg message) error {
 	}
 }
 
-// Snapshot returns a detached state projection. It returns context errors,
-// ErrBusy when the actor queue is saturated, or ErrClosed after shutdown.
+// Snapshot returns a detached projection of the default session, with every
+// session summarized in Sessions. It returns context errors, ErrBusy when the
+// actor queue is saturated, or ErrClosed after shutdown.
 func (c *Controller) Snapshot(ctx context.Context) (Snapshot, error) {
+	snapshot, err := c.snapshot(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.Sessions = c.sessionInfos(ctx, snapshot)
+	return snapshot, nil
+}
+
+func (c *Controller) snapshot(ctx context.Context) (Snapshot, error) {
 	if ctx == nil {
 		return Snapshot{}, ErrInvalidOperation
 	}
@@ -408,9 +467,15 @@ func (s *actorState) snapshot() Snapshot {
 		Generation: s.generation, PlaybackState: s.state, Position: s.position, Duration: s.duration,
 		Volume: s.volume, Muted: s.muted, ArtworkID: s.artworkID, Policy: s.policy,
 		LastError: s.lastError, TerminalReason: s.terminal,
+		SleepTimer: s.sleep, ScheduledStart: s.schedule, Groups: s.controller.groups.infos(),
+	}
+	if history := s.controller.cfg.Resume; history != nil {
+		result.ContinueWatching = history.Recent(ContinueWatchingLimit)
 	}
 	if s.active != nil {
 		result.HasSession, result.ActiveDeviceID, result.ActiveMediaName, result.MediaType = true, s.active.target.ID, s.active.media.Name, s.active.kind
+		result.ActiveMediaID = s.active.media.ID
+		result.Stream = s.active.stream
 	}
 	if s.queue != nil {
 		current, _ := s.queue.Current()
@@ -422,19 +487,35 @@ func (s *actorState) snapshot() Snapshot {
 	return result
 }
 
-func (s *actorState) setDevices(devices []playback.Device) {
+func (s *actorState) setDevices(discovered []playback.Device) {
+	s.discovered = slices.Clone(discovered)
+	devices := s.controller.groups.withGroups(discovered)
 	if slices.Equal(s.devices, devices) {
 		return
 	}
-	s.devices = slices.Clone(devices)
+	s.devices = devices
+	s.controller.publishDevices(discovered)
 	if s.selectedID != "" && !slices.ContainsFunc(devices, func(device playback.Device) bool { return device.ID == s.selectedID }) {
 		s.selectedID = ""
+		if s.controller.pinned != nil {
+			// An additional session waits for its device to come back.
+			s.preferred = s.controller.pinned
+		}
 		s.commit()
 		return
 	}
+	if s.selectedID == "" && s.preferred != nil {
+		if index := slices.IndexFunc(devices, func(device playback.Device) bool { return sameDevice(device, *s.preferred) }); index >= 0 {
+			s.selectedID, s.preferred = devices[index].ID, nil
+		}
+	}
 	s.commit()
 }
 
+func sameDevice(a, b playback.Device) bool {
+	return a.Protocol == b.Protocol && a.Endpoint == b.Endpoint
+}
+
 func (s *actorState) commit() { s.revision++ }
 
 func (s *actorState) check(m Mutation) Result {
@@ -444,8 +525,11 @@ func (s *actorState) check(m Mutation) Result {
 	return Result{RequestID: m.RequestID, Revision: s.revision}
 }
 
+var resultErrors = metrics.NewCounter("go2tv_controller_errors_total", "Failed controller commands, by Result code.", "code")
+
 func fail(requestID string, revision uint64, err error) Result {
 	code, message := ErrorCodeOf(err), "operation failed"
+	resultErrors.Inc(string(code))
 	switch {
 	case code == CodeBusy:
 		message = "operation busy"
@@ -493,6 +577,12 @@ func (c *Controller) mutate(ctx context.Context, mutation Mutation, fn func(*act
 
 // SelectDevice selects a currently discovered device by opaque ID.
 func (c *Controller) SelectDevice(ctx context.Context, mutation Mutation, id string) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.SelectDevice(ctx, mutation, id)
+	}
 	return c.mutate(ctx, mutation, func(s *actorState) Result {
 		if result := s.check(mutation); !result.OK() {
 			return result
@@ -500,7 +590,10 @@ func (c *Controller) SelectDevice(ctx context.Context, mutation Mutation, id str
 		if !slices.ContainsFunc(s.devices, func(device playback.Device) bool { return device.ID == id }) {
 			return fail(mutation.RequestID, s.revision, ErrNotFound)
 		}
-		s.selectedID = id
+		if c.pinned != nil && id != c.pinned.ID {
+			return fail(mutation.RequestID, s.revision, ErrInvalidOperation)
+		}
+		s.selectedID, s.preferred = id, nil
 		s.commit()
 		return Result{RequestID: mutation.RequestID, Revision: s.revision}
 	})
@@ -508,6 +601,12 @@ func (c *Controller) SelectDevice(ctx context.Context, mutation Mutation, id str
 
 // SelectMedia validates, copies, and selects an in-process media reference.
 func (c *Controller) SelectMedia(ctx context.Context, mutation Mutation, media MediaRef) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.SelectMedia(ctx, mutation, media)
+	}
 	return c.mutate(ctx, mutation, func(s *actorState) Result {
 		if result := s.check(mutation); !result.OK() {
 			return result
@@ -535,6 +634,12 @@ func cloneMediaRef(media MediaRef) MediaRef {
 
 // SelectSubtitle selects a subtitle reference. The zero reference clears it.
 func (c *Controller) SelectSubtitle(ctx context.Context, mutation Mutation, subtitle SubtitleRef) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.SelectSubtitle(ctx, mutation, subtitle)
+	}
 	return c.mutate(ctx, mutation, func(s *actorState) Result {
 		if result := s.check(mutation); !result.OK() {
 			return result
@@ -551,6 +656,12 @@ func (c *Controller) SelectSubtitle(ctx context.Context, mutation Mutation, subt
 
 // SetTranscode controls transcoding for subsequent loads.
 func (c *Controller) SetTranscode(ctx context.Context, mutation Mutation, enabled bool) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.SetTranscode(ctx, mutation, enabled)
+	}
 	return c.mutate(ctx, mutation, func(s *actorState) Result {
 		if result := s.check(mutation); !result.OK() {
 			return result
@@ -565,6 +676,12 @@ func (c *Controller) SetTranscode(ctx context.Context, mutation Mutation, enable
 // AddQueueItem validates Media, then returns the existing absolute-path match
 // or appends a copied item.
 func (c *Controller) AddQueueItem(ctx context.Context, request QueueAddRequest) QueueAddResult {
+	if target, result := c.sessionTarget(ctx, &request.Mutation); target != c {
+		if target == nil {
+			return QueueAddResult{Result: result}
+		}
+		return target.AddQueueItem(ctx, request)
+	}
 	var itemID string
 	result := c.mutate(ctx, request.Mutation, func(s *actorState) Result {
 		if result := s.check(request.Mutation); !result.OK() {
@@ -584,7 +701,7 @@ func (c *Controller) AddQueueItem(ctx context.Context, request QueueAddRequest)
 		if s.queue != nil && s.queue.Len() >= MaxQueueItems {
 			return fail(request.RequestID, s.revision, ErrQueueLimit)
 		}
-		item, ok := mediamodel.NewQueueReference(request.Media.Name, request.Media.Parent, request.Media.Kind)
+		item, ok := mediamodel.NewQueueReference(request.Media.label(), request.Media.Parent, request.Media.Kind)
 		if !ok {
 			return fail(request.RequestID, s.revision, ErrInvalidOperation)
 		}
@@ -602,6 +719,12 @@ func (c *Controller) AddQueueItem(ctx context.Context, request QueueAddRequest)
 // once the queue reaches MaxQueueItems. A batch with no room for any item
 // fails with ErrQueueLimit.
 func (c *Controller) AddQueueItems(ctx context.Context, request QueueAddManyRequest) QueueAddManyResult {
+	if target, result := c.sessionTarget(ctx, &request.Mutation); target != c {
+		if target == nil {
+			return QueueAddManyResult{Result: result}
+		}
+		return target.AddQueueItems(ctx, request)
+	}
 	var added, duplicates, dropped int
 	result := c.mutate(ctx, request.Mutation, func(s *actorState) Result {
 		if result := s.check(request.Mutation); !result.OK() {
@@ -615,7 +738,7 @@ func (c *Controller) AddQueueItems(ctx context.Context, request QueueAddManyRequ
 			if !media.valid() {
 				return fail(request.RequestID, s.revision, ErrInvalidOperation)
 			}
-			item, ok := mediamodel.NewQueueReference(media.Name, media.Parent, media.Kind)
+			item, ok := mediamodel.NewQueueReference(media.label(), media.Parent, media.Kind)
 			if !ok {
 				return fail(request.RequestID, s.revision, ErrInvalidOperation)
 			}
@@ -658,6 +781,9 @@ func (s *actorState) queueItemByMedia(media MediaRef) (mediamodel.QueueItem, boo
 }
 
 func sameMediaPath(a, b MediaRef) bool {
+	if a.URL != "" || b.URL != "" {
+		return a.URL == b.URL
+	}
 	if filepath.IsAbs(a.AbsolutePath) && filepath.IsAbs(b.AbsolutePath) {
 		left, right := filepath.Clean(a.AbsolutePath), filepath.Clean(b.AbsolutePath)
 		if runtime.GOOS == "windows" {
@@ -707,6 +833,12 @@ func (s *actorState) appendQueueItem(item mediamodel.QueueItem, media MediaRef,
 // ClearQueue removes queued items. An active queued item is retained until its
 // session ends so snapshots and autoplay retain a valid identity.
 func (c *Controller) ClearQueue(ctx context.Context, mutation Mutation) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.ClearQueue(ctx, mutation)
+	}
 	return c.mutate(ctx, mutation, func(s *actorState) Result {
 		if result := s.check(mutation); !result.OK() {
 			return result
@@ -739,6 +871,12 @@ func (c *Controller) ClearQueue(ctx context.Context, mutation Mutation) Result {
 
 // SelectQueueItem selects an existing item by its opaque queue ID.
 func (c *Controller) SelectQueueItem(ctx context.Context, mutation Mutation, id string) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.SelectQueueItem(ctx, mutation, id)
+	}
 	return c.mutate(ctx, mutation, func(s *actorState) Result {
 		if result := s.check(mutation); !result.OK() {
 			return result
@@ -761,6 +899,12 @@ func (c *Controller) SelectQueueItem(ctx context.Context, mutation Mutation, id
 // RemoveQueueItem removes an inactive item. The selected item may only be
 // removed while playback is stopped; doing so clears the player selection.
 func (c *Controller) RemoveQueueItem(ctx context.Context, mutation Mutation, id string) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.RemoveQueueItem(ctx, mutation, id)
+	}
 	return c.mutate(ctx, mutation, func(s *actorState) Result {
 		if result := s.check(mutation); !result.OK() {
 			return result
@@ -790,6 +934,12 @@ func (c *Controller) RemoveQueueItem(ctx context.Context, mutation Mutation, id
 
 // MoveQueueItem moves an item by delta.
 func (c *Controller) MoveQueueItem(ctx context.Context, mutation Mutation, id string, delta int) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.MoveQueueItem(ctx, mutation, id, delta)
+	}
 	return c.mutate(ctx, mutation, func(s *actorState) Result {
 		if result := s.check(mutation); !result.OK() {
 			return result
@@ -809,6 +959,12 @@ func (c *Controller) MoveQueueItem(ctx context.Context, mutation Mutation, id st
 
 // SetPolicy validates and atomically replaces the playback policy.
 func (c *Controller) SetPolicy(ctx context.Context, request PolicyRequest) Result {
+	if target, result := c.sessionTarget(ctx, &request.Mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.SetPolicy(ctx, request)
+	}
 	return c.mutate(ctx, request.Mutation, func(s *actorState) Result {
 		if result := s.check(request.Mutation); !result.OK() {
 			return result
@@ -818,6 +974,9 @@ func (c *Controller) SetPolicy(ctx context.Context, request PolicyRequest) Resul
 			return fail(request.RequestID, s.revision, ErrInvalidPolicy)
 		}
 		restartImageTimer := s.policy.AutoPlayNext != p.AutoPlayNext || s.policy.ImageDurationSeconds != p.ImageDurationSeconds
+		if !p.Shuffle || !s.policy.Shuffle {
+			s.shuffle = nil
+		}
 		s.policy = p
 		if restartImageTimer {
 			s.syncImageTimer()
@@ -828,11 +987,106 @@ func (c *Controller) SetPolicy(ctx context.Context, request PolicyRequest) Resul
 	})
 }
 
-func queueIndex(queue *mediamodel.Queue, id string) int {
-	if queue == nil {
-		return -1
+// SavedState returns the queue, selection, subtitle, policy, and selected
+// device together with the revision they were read at.
+func (c *Controller) SavedState(ctx context.Context) (SavedState, uint64, error) {
+	if ctx == nil {
+		return SavedState{}, 0, ErrInvalidOperation
+	}
+	var state SavedState
+	revision, err := c.callActor(ctx, func(s *actorState) { state = s.savedState() })
+	if err != nil {
+		return SavedState{}, 0, err
 	}
-	return slices.IndexFunc(queue.Items(), func(item mediamodel.QueueItem) bool { return item.ID() == id })
+	return state, revision, nil
+}
+
+func (s *actorState) savedState() SavedState {
+	state := SavedState{Selected: -1, Subtitle: s.subtitle, Policy: s.policy}
+	if device, ok := s.selectedDevice(); ok {
+		state.Device = device
+	} else if s.preferred != nil {
+		state.Device = *s.preferred
+	}
+	if s.queue == nil {
+		return state
+	}
+	current := s.queue.CurrentIndex()
+	for index, item := range s.queue.Items() {
+		media, ok := s.queueRefs[item.ID()]
+		if !ok {
+			continue
+		}
+		if index == current {
+			state.Selected = len(state.Queue)
+		}
+		state.Queue = append(state.Queue, cloneMediaRef(media))
+	}
+	return state
+}
+
+// RestoreState replaces the queue, selection, subtitle, and policy with a
+// saved state. It is meant for startup and fails with CodeBusy while a session
+// is loading or active. A saved device is selected once discovery reports it.
+func (c *Controller) RestoreState(ctx context.Context, mutation Mutation, state SavedState) Result {
+	return c.mutate(ctx, mutation, func(s *actorState) Result {
+		if result := s.check(mutation); !result.OK() {
+			return result
+		}
+		if s.active != nil || s.pending != nil {
+			return fail(mutation.RequestID, s.revision, ErrBusy)
+		}
+		if len(state.Queue) > MaxQueueItems {
+			return fail(mutation.RequestID, s.revision, ErrQueueLimit)
+		}
+		if state.Policy.Validate() != nil {
+			return fail(mutation.RequestID, s.revision, ErrInvalidPolicy)
+		}
+		if state.Subtitle.Validate() != nil || state.Selected < -1 || state.Selected >= len(state.Queue) {
+			return fail(mutation.RequestID, s.revision, ErrInvalidOperation)
+		}
+		items := make([]mediamodel.QueueItem, 0, len(state.Queue))
+		refs := make(map[string]MediaRef, len(state.Queue))
+		for _, media := range state.Queue {
+			if !media.valid() {
+				return fail(mutation.RequestID, s.revision, ErrInvalidOperation)
+			}
+			item, ok := mediamodel.NewQueueReference(media.label(), media.Parent, media.Kind)
+			if !ok {
+				return fail(mutation.RequestID, s.revision, ErrInvalidOperation)
+			}
+			items = append(items, item)
+			refs[item.ID()] = cloneMediaRef(media)
+		}
+		s.queue, s.queueRefs = nil, nil
+		s.media, s.mediaQueueID, s.artworkID = MediaRef{}, "", ""
+		if len(items) != 0 {
+			s.queue, s.queueRefs = mediamodel.NewQueue(items, state.Selected), refs
+			if state.Selected >= 0 {
+				s.mediaQueueID = items[state.Selected].ID()
+				s.media = refs[s.mediaQueueID]
+			}
+		}
+		s.subtitle = state.Subtitle
+		s.policy = state.Policy
+		s.shuffle = nil
+		s.selectedID, s.preferred = "", nil
+		if state.Device.Endpoint != "" {
+			if index := slices.IndexFunc(s.devices, func(device playback.Device) bool { return sameDevice(device, state.Device) }); index >= 0 {
+				s.selectedID = s.devices[index].ID
+			} else {
+				device := state.Device
+				s.preferred = &device
+			}
+		}
+		s.commit()
+		s.reconcileGapless()
+		return Result{RequestID: mutation.RequestID, Revision: s.revision}
+	})
+}
+
+func queueIndex(queue *mediamodel.Queue, id string) int {
+	return queue.IndexByID(id)
 }
 
 // gaplessUnsupportedURI is the AVTransport GetMediaInfo NextURI value a renderer
@@ -841,10 +1095,11 @@ func queueIndex(queue *mediamodel.Queue, id string) int {
 const gaplessUnsupportedURI = "NOT_IMPLEMENTED"
 
 func (s *actorState) desiredGapless(itemID string, target playback.Device) *gaplessCandidate {
-	if !s.policy.AutoPlayNext || !s.policy.GaplessEnabled || target.Protocol != "DLNA" || s.queue == nil {
+	if !s.policy.AutoPlayNext || !s.policy.GaplessEnabled || target.Protocol != "DLNA" || s.queue == nil || s.sleep.Mode == SleepAfterItem {
 		return nil
 	}
-	if s.gaplessUnsupported[target.ID] {
+	// Group members change tracks together through ordinary autoplay.
+	if s.gaplessUnsupported[target.ID] || strings.HasPrefix(target.ID, groupIDPrefix) {
 		return nil
 	}
 	index := queueIndex(s.queue, itemID)
@@ -853,7 +1108,7 @@ func (s *actorState) desiredGapless(itemID string, target playback.Device) *gapl
 	}
 	queue := s.queue.Clone()
 	queue.SetCurrentIndex(index)
-	next := queue.AdjacentIndex(1, s.policy.AutoPlaySameType, true)
+	next := s.nextIndex(queue)
 	if next < 0 {
 		return nil
 	}
@@ -862,12 +1117,25 @@ func (s *actorState) desiredGapless(itemID string, target playback.Device) *gapl
 		return nil
 	}
 	media, ok := s.queueRefs[item.ID()]
-	if !ok {
+	// Gapless loads are served next to the current item, so neither side
+	// can be a remote URL.
+	if current := s.queueRefs[itemID]; !ok || media.URL != "" || current.URL != "" {
 		return nil
 	}
 	return &gaplessCandidate{item: item, media: media, subtitle: s.subtitle, transcode: s.transcode}
 }
 
+// nextIndex returns the item autoplay moves to after the current item of
+// queue, following the shuffle order when Shuffle is on.
+func (s *actorState) nextIndex(queue *mediamodel.Queue) int {
+	wrap := (!s.policy.Shuffle || s.policy.RepeatAll) && s.sleep.Mode != SleepEndOfQueue
+	if !s.policy.Shuffle {
+		return queue.AdjacentIndex(1, s.policy.AutoPlaySameType, wrap)
+	}
+	s.shuffle = queue.ShuffleOrder(s.shuffle)
+	return queue.AdjacentIndexInOrder(s.shuffle, 1, s.policy.AutoPlaySameType, wrap)
+}
+
 func gaplessMatches(candidate *gaplessCandidate, queued *gaplessSession) bool {
 	if candidate == nil || queued == nil {
 		return candidate == nil && queued == nil
@@ -998,7 +1266,7 @@ func (s *actorState) choosePlay(request PlayRequest) (playback.Device, mediamode
 			return target, item, MediaRef{}, ErrNoMedia
 		}
 		resolved = *request.media
-		item, ok = mediamodel.NewQueueReference(resolved.Name, resolved.Parent, resolved.Kind)
+		item, ok = mediamodel.NewQueueReference(resolved.label(), resolved.Parent, resolved.Kind)
 		if !ok {
 			return target, item, MediaRef{}, ErrNoMedia
 		}
@@ -1015,7 +1283,7 @@ func (s *actorState) choosePlay(request PlayRequest) (playback.Device, mediamode
 		}
 		item, _ = s.queue.Item(index)
 	} else if s.media.valid() {
-		item, _ = mediamodel.NewQueueReference(s.media.Name, s.media.Parent, s.media.Kind)
+		item, _ = mediamodel.NewQueueReference(s.media.label(), s.media.Parent, s.media.Kind)
 	} else if s.queue != nil {
 		item, ok = s.queue.Current()
 		if !ok {
@@ -1043,19 +1311,47 @@ func (s *actorState) choosePlay(request PlayRequest) (playback.Device, mediamode
 // Play loads and starts the requested or selected media. Controller owns the
 // opened transport until replacement, stop, failure, or shutdown.
 func (c *Controller) Play(ctx context.Context, request PlayRequest) Result {
+	if target, result := c.sessionTarget(ctx, &request.Mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.Play(ctx, request)
+	}
 	return c.play(ctx, request)
 }
 
 // QueueAndPlay atomically adds or reuses, selects, and starts media.
 func (c *Controller) QueueAndPlay(ctx context.Context, mutation Mutation, media MediaRef) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.QueueAndPlay(ctx, mutation, media)
+	}
 	media = cloneMediaRef(media)
 	return c.play(ctx, PlayRequest{Mutation: mutation, media: &media, queueMedia: true})
 }
 
+// QueueAndResume is QueueAndPlay starting at the saved position of media, if
+// Config.Resume has one.
+func (c *Controller) QueueAndResume(ctx context.Context, mutation Mutation, media MediaRef) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.QueueAndResume(ctx, mutation, media)
+	}
+	media = cloneMediaRef(media)
+	return c.play(ctx, PlayRequest{Mutation: mutation, Resume: true, media: &media, queueMedia: true})
+}
+
 func (c *Controller) play(ctx context.Context, request PlayRequest) Result {
 	if ctx == nil {
 		return fail(request.RequestID, 0, ErrInvalidOperation)
 	}
+	if err := c.claimDevice(ctx, request); err != nil {
+		return fail(request.RequestID, 0, err)
+	}
 	request.ctx = ctx
 	return c.callResult(ctx, request.RequestID, func(s *actorState, response chan<- Result) {
 		s.beginPlay(request, response)
@@ -1071,6 +1367,12 @@ func (s *actorState) beginPlay(request PlayRequest, response chan<- Result) {
 		response <- fail(request.RequestID, s.revision, ErrBusy)
 		return
 	}
+	if request.transfer {
+		if err := s.prepareHandoff(&request); err != nil {
+			response <- fail(request.RequestID, s.revision, err)
+			return
+		}
+	}
 	target, item, media, err := s.choosePlay(request)
 	if err != nil {
 		response <- fail(request.RequestID, s.revision, err)
@@ -1080,6 +1382,12 @@ func (s *actorState) beginPlay(request PlayRequest, response chan<- Result) {
 		response <- fail(request.RequestID, s.revision, ErrInvalidOperation)
 		return
 	}
+	if request.transfer {
+		s.selectedID, s.preferred = target.ID, nil
+	}
+	if request.Resume && request.start == nil {
+		request.start = s.savedStart(media, item.MediaKind())
+	}
 	if request.queueMedia {
 		if existing, ok := s.queueItemByMedia(media); ok {
 			item = existing
@@ -1099,6 +1407,7 @@ func (s *actorState) beginPlay(request PlayRequest, response chan<- Result) {
 	generation := s.generation
 	old := s.active
 	if old != nil {
+		s.rememberPosition(old, true)
 		old.cancel(playback.TerminalReplacement)
 		s.terminal = playback.TerminalReplacement
 	}
@@ -1124,6 +1433,7 @@ type playCompletion struct {
 	generation uint64
 	operation  *playOperation
 	session    *activeSession
+	position   int
 	err        error
 	request    PlayRequest
 	response   chan<- Result
@@ -1141,6 +1451,17 @@ type callbackActivator interface {
 	ActivateCallbacks(uint64) error
 }
 
+type quirkedTransport interface {
+	Quirks() soapcalls.Quirks
+}
+
+// captionInfo reports whether the renderer behind transport reads its
+// subtitles from a CaptionInfo.sec header.
+func captionInfo(transport Transport) bool {
+	quirked, ok := transport.(quirkedTransport)
+	return ok && quirked.Quirks().CaptionInfoHeader
+}
+
 type callbackStopSuppressor interface {
 	SuppressCallbackStops(uint64, bool) error
 }
@@ -1149,9 +1470,14 @@ func (c *Controller) playIO(ctx context.Context, operation *playOperation, targe
 	generation := operation.generation
 	ioCtx, timeoutCancel := operationContext(ctx, request.ctx, c.cfg.OperationTimeout)
 	defer timeoutCancel()
-	if target.Protocol == "Chromecast" {
+	if media.URL != "" {
+		// The renderer fetches remote media itself; nothing is served.
+		transcode, subtitle = false, SubtitleRef{}
+	} else if target.Protocol == "Chromecast" {
 		transcode = playback.ChromecastTranscodeEnabled(transcode, media.Name, mediaMIME(media, item.MediaKind()))
 	}
+	stream := c.stream(ioCtx, target, media, item.MediaKind(), transcode)
+	transcode = stream.Mode != playback.StreamDirect
 	var reusedCast existingLoader
 	var routeAdder mediaRouteAdder
 	if old != nil {
@@ -1166,6 +1492,13 @@ func (c *Controller) playIO(ctx context.Context, operation *playOperation, targe
 		case routeAdder != nil:
 		case reusedCast != nil:
 			err = c.cfg.MediaServer.Stop(ioCtx)
+		case request.transfer:
+			// The previous renderer may already be unreachable; that must not
+			// keep the item from moving.
+			if teardownErr := teardownSession(ioCtx, old, c.cfg.MediaServer, c.cfg.OperationTimeout); teardownErr != nil && c.cfg.Logger != nil {
+				c.cfg.Logger.Warning("Previous device did not stop cleanly")
+				c.cfg.Logger.Debug("Transfer teardown failure detail: " + teardownErr.Error())
+			}
 		default:
 			err = transitionSession(ioCtx, old, c.cfg.MediaServer, c.cfg.OperationTimeout)
 		}
@@ -1191,7 +1524,7 @@ func (c *Controller) playIO(ctx context.Context, operation *playOperation, targe
 	if reusedCast != nil {
 		transport = old.transport
 	} else {
-		transport, err = c.cfg.TransportFactory.Open(ioCtx, target)
+		transport, err = c.openTransport(ioCtx, target)
 		if err != nil {
 			c.completePlay(playCompletion{generation: generation, operation: operation, err: err, request: request, response: response})
 			return
@@ -1210,7 +1543,10 @@ func (c *Controller) playIO(ctx context.Context, operation *playOperation, targe
 			return
 		}
 	}
-	serverRequest := playback.ServerRequest{Media: opener, MediaExt: media.extension(), MediaType: mediaMIME(media, item.MediaKind()), Transcode: transcode, Target: target}
+	serverRequest := playback.ServerRequest{Media: opener, MediaExt: media.extension(), MediaType: mediaMIME(media, item.MediaKind()), Transcode: transcode, Remux: stream.Mode == playback.StreamRemux, CaptionInfo: captionInfo(transport), Target: target}
+	if serverRequest.Remux {
+		serverRequest.MediaExt, serverRequest.MediaType = stream.Ext, stream.MediaType
+	}
 	if transcode && target.Protocol == "Chromecast" {
 		serverRequest.MediaExt = ".mp4"
 		serverRequest.MediaType = "video/mp4"
@@ -1226,9 +1562,12 @@ func (c *Controller) playIO(ctx context.Context, operation *playOperation, targe
 	}
 	serverRequest.Duration = duration
 	var route playback.MediaRoute
-	if routeAdder != nil {
+	switch {
+	case media.URL != "":
+		route = playback.MediaRoute{URL: media.URL}
+	case routeAdder != nil:
 		route, err = routeAdder.AddMedia(ioCtx, serverRequest)
-	} else {
+	default:
 		route, err = c.cfg.MediaServer.Start(ioCtx, serverRequest)
 	}
 	if err == nil && strings.TrimSpace(route.URL) == "" {
@@ -1251,6 +1590,10 @@ func (c *Controller) playIO(ctx context.Context, operation *playOperation, targe
 		Metadata:    metadata.Media{Title: item.BaseName()},
 	}
 	c.attachArtwork(ioCtx, item.ID(), &media, &loadRequest, &routeIDs)
+	if request.start != nil && target.Protocol == "Chromecast" && !transcode {
+		// A Chromecast LOAD takes a start time, which spares a seek.
+		loadRequest.Start = request.start.seconds
+	}
 	if err == nil && target.Protocol == "DLNA" {
 		if activator, ok := transport.(callbackActivator); ok {
 			err = activator.ActivateCallbacks(generation)
@@ -1265,7 +1608,7 @@ func (c *Controller) playIO(ctx context.Context, operation *playOperation, targe
 			_ = rendererCleanup(transport, c.cfg.OperationTimeout, func(ctx context.Context, transport Transport) error {
 				return transport.Close(ctx)
 			})
-			transport, err = c.cfg.TransportFactory.Open(ioCtx, target)
+			transport, err = c.openTransport(ioCtx, target)
 			if err == nil && transport == nil {
 				err = fmt.Errorf("transport factory returned nil: %w", errAdapterContract)
 			}
@@ -1296,7 +1639,11 @@ func (c *Controller) playIO(ctx context.Context, operation *playOperation, targe
 		c.completePlay(playCompletion{generation: generation, operation: operation, err: err, request: request, response: response})
 		return
 	}
-	session := &activeSession{generation: generation, target: target, itemID: item.ID(), media: media, subtitle: subtitle, kind: item.MediaKind(), transport: transport, server: serverRequest, load: loadRequest, routeIDs: routeIDs, ctx: ctx, cancel: operation.cancel, reusable: true, imageReady: target.Protocol != "Chromecast", expectedDuration: int(loadRequest.Duration)}
+	position := loadRequest.Start
+	if request.start != nil && request.start.seconds > 0 && position == 0 {
+		position = c.seekToStart(ioCtx, generation, target, transport, &serverRequest, loadRequest, request.start)
+	}
+	session := &activeSession{generation: generation, target: target, itemID: item.ID(), media: media, subtitle: subtitle, kind: item.MediaKind(), transport: transport, server: serverRequest, load: loadRequest, routeIDs: routeIDs, ctx: ctx, cancel: operation.cancel, reusable: true, imageReady: target.Protocol != "Chromecast", seekOffset: serverRequest.SeekOffset, expectedDuration: int(loadRequest.Duration), stream: stream}
 	if gapless != nil {
 		queued, queueErr := c.queueGapless(ioCtx, session, gapless)
 		if queueErr != nil {
@@ -1309,7 +1656,7 @@ func (c *Controller) playIO(ctx context.Context, operation *playOperation, targe
 			session.gaplessActive.Store(true)
 		}
 	}
-	c.completePlay(playCompletion{generation: generation, operation: operation, session: session, request: request, response: response})
+	c.completePlay(playCompletion{generation: generation, operation: operation, session: session, position: position, request: request, response: response})
 }
 
 func operationContext(base, request context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
@@ -1354,21 +1701,28 @@ func (c *Controller) queueGapless(ctx context.Context, active *activeSession, ca
 	if !ok {
 		return nil, ErrInvalidOperation
 	}
+	stream := c.stream(ctx, active.target, candidate.media, candidate.item.MediaKind(), candidate.transcode)
+	transcode := stream.Mode != playback.StreamDirect
 	opener := candidate.media.OpenDirect
-	if candidate.transcode {
+	if transcode {
 		opener = candidate.media.OpenTranscode
 		if opener == nil {
 			return nil, ErrInvalidOperation
 		}
 	}
 	serverRequest := playback.ServerRequest{
-		Media:     opener,
-		MediaExt:  candidate.media.extension(),
-		MediaType: mediaMIME(candidate.media, candidate.item.MediaKind()),
-		Transcode: candidate.transcode,
-		Target:    active.target,
+		Media:       opener,
+		MediaExt:    candidate.media.extension(),
+		MediaType:   mediaMIME(candidate.media, candidate.item.MediaKind()),
+		Transcode:   transcode,
+		Remux:       stream.Mode == playback.StreamRemux,
+		CaptionInfo: captionInfo(active.transport),
+		Target:      active.target,
+	}
+	if serverRequest.Remux {
+		serverRequest.MediaExt, serverRequest.MediaType = stream.Ext, stream.MediaType
 	}
-	if candidate.transcode && c.cfg.DurationProbe != nil {
+	if transcode && c.cfg.DurationProbe != nil {
 		if duration, probeErr := c.cfg.DurationProbe(ctx, candidate.media.OpenDirect); probeErr == nil && duration > 0 {
 			serverRequest.Duration = duration
 		}
@@ -1398,8 +1752,8 @@ func (c *Controller) queueGapless(ctx context.Context, active *activeSession, ca
 		MediaType:   serverRequest.MediaType,
 		SubtitleURL: route.SubtitleURL,
 		Duration:    serverRequest.Duration,
-		Seekable:    !candidate.transcode,
-		Transcode:   candidate.transcode,
+		Seekable:    !transcode,
+		Transcode:   transcode,
 		Metadata:    metadata.Media{Title: candidate.item.BaseName()},
 	}
 	media := candidate.media
@@ -1411,10 +1765,41 @@ func (c *Controller) queueGapless(ctx context.Context, active *activeSession, ca
 	return &gaplessSession{
 		itemID: candidate.item.ID(), media: media, subtitle: candidate.subtitle,
 		kind: candidate.item.MediaKind(), server: serverRequest, load: loadRequest,
-		routeIDs: routeIDs, transcode: candidate.transcode,
+		routeIDs: routeIDs, transcode: candidate.transcode, stream: stream,
 	}, nil
 }
 
+// stream decides how media reaches target. Video for a DLNA renderer that
+// reported its sink formats gets what those formats require; everything
+// else follows the transcode setting.
+func (c *Controller) stream(ctx context.Context, target playback.Device, media MediaRef, kind mediamodel.MediaKind, transcode bool) playback.StreamDecision {
+	switch {
+	case media.URL != "":
+		return playback.StreamDecision{Mode: playback.StreamDirect, Reason: "renderer loads the URL itself"}
+	case transcode:
+		return playback.StreamDecision{Mode: playback.StreamTranscode, Reason: "transcoding requested"}
+	}
+	direct := playback.StreamDecision{Mode: playback.StreamDirect, Reason: "transcoding off"}
+	if target.Protocol != "DLNA" || target.SinkProtocolInfo == "" || kind != mediamodel.MediaKindVideo || c.cfg.CodecProbe == nil || media.OpenTranscode == nil {
+		return direct
+	}
+	codecs, err := c.cfg.CodecProbe(ctx, media.OpenDirect)
+	if err != nil {
+		if c.cfg.Logger != nil {
+			c.cfg.Logger.Debug("Codec probe failed; following the transcode setting: " + err.Error())
+		}
+		return direct
+	}
+	decision, ok := playback.DecideStream(target.SinkProtocolInfo, mediaMIME(media, kind), codecs, c.cfg.Remux)
+	if !ok {
+		return direct
+	}
+	if c.cfg.Logger != nil {
+		c.cfg.Logger.Debug("Stream " + string(decision.Mode) + ": " + decision.Reason)
+	}
+	return decision
+}
+
 func (c *Controller) removeRoutes(ctx context.Context, ids []string) {
 	if c.cfg.MediaServer == nil {
 		return
@@ -1639,7 +2024,7 @@ func (c *Controller) completePlay(completion playCompletion) {
 		}
 		s.pending = nil
 		s.mutation = false
-		s.active, s.state, s.position, s.duration, s.lastError, s.terminal = completion.session, PlaybackStatePlaying, 0, 0, "", ""
+		s.active, s.state, s.position, s.duration, s.lastError, s.terminal = completion.session, PlaybackStatePlaying, completion.position, 0, "", ""
 		if s.mediaQueueID == completion.session.itemID || s.mediaQueueID == "" && sameMediaPath(s.media, completion.session.media) {
 			s.media = cloneMediaRef(completion.session.media)
 		}
@@ -1709,6 +2094,12 @@ func (s *actorState) syncImageTimer() {
 // Stop ends pending or active playback and completes owned cleanup before
 // returning. Cleanup continues if the caller's context is canceled.
 func (c *Controller) Stop(ctx context.Context, mutation Mutation) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.Stop(ctx, mutation)
+	}
 	if ctx == nil {
 		return fail(mutation.RequestID, 0, ErrInvalidOperation)
 	}
@@ -1723,9 +2114,11 @@ func (c *Controller) Stop(ctx context.Context, mutation Mutation) Result {
 		}
 		s.generation++
 		s.deferred = nil
+		s.disarmItemSleep()
 		s.terminal, s.state = playback.TerminalUserStop, PlaybackStateStopping
 		active := s.active
 		pending := s.pending
+		s.rememberPosition(active, true)
 		if pending != nil {
 			pending.cancel(playback.TerminalUserStop)
 		} else if active != nil {
@@ -1781,16 +2174,34 @@ func (c *Controller) Stop(ctx context.Context, mutation Mutation) Result {
 
 // Pause pauses the active session.
 func (c *Controller) Pause(ctx context.Context, mutation Mutation) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.Pause(ctx, mutation)
+	}
 	return c.transportControl(ctx, mutation, PlaybackStatePaused, func(ctx context.Context, t Transport) error { return t.Pause(ctx) })
 }
 
 // Resume resumes the active session and publishes PlaybackStatePlaying.
 func (c *Controller) Resume(ctx context.Context, mutation Mutation) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.Resume(ctx, mutation)
+	}
 	return c.transportControl(ctx, mutation, PlaybackStatePlaying, func(ctx context.Context, t Transport) error { return t.Play(ctx) })
 }
 
 // Seek moves the active session to an absolute position in seconds.
 func (c *Controller) Seek(ctx context.Context, request SeekRequest) Result {
+	if target, result := c.sessionTarget(ctx, &request.Mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.Seek(ctx, request)
+	}
 	if ctx == nil {
 		return fail(request.RequestID, 0, ErrInvalidOperation)
 	}
@@ -1952,6 +2363,12 @@ func (c *Controller) transportControl(ctx context.Context, mutation Mutation, st
 
 // SetVolume sets renderer volume in the inclusive range 0..100.
 func (c *Controller) SetVolume(ctx context.Context, mutation Mutation, volume int) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.SetVolume(ctx, mutation, volume)
+	}
 	if volume < 0 || volume > 100 {
 		return fail(mutation.RequestID, 0, ErrInvalidOperation)
 	}
@@ -1960,6 +2377,12 @@ func (c *Controller) SetVolume(ctx context.Context, mutation Mutation, volume in
 
 // AdjustVolume changes renderer volume by exactly -1 or 1 and clamps 0..100.
 func (c *Controller) AdjustVolume(ctx context.Context, mutation Mutation, delta int) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.AdjustVolume(ctx, mutation, delta)
+	}
 	if delta != -1 && delta != 1 {
 		return fail(mutation.RequestID, 0, ErrInvalidOperation)
 	}
@@ -1976,6 +2399,12 @@ func (c *Controller) AdjustVolume(ctx context.Context, mutation Mutation, delta
 
 // SetMute changes renderer mute state.
 func (c *Controller) SetMute(ctx context.Context, mutation Mutation, muted bool) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.SetMute(ctx, mutation, muted)
+	}
 	return c.deviceControl(ctx, mutation, func(ctx context.Context, t Transport) error { return t.SetMute(ctx, muted) }, func(s *actorState) { s.muted = muted })
 }
 
@@ -2020,7 +2449,7 @@ func (c *Controller) deviceControl(ctx context.Context, mutation Mutation, call
 			defer cancel()
 			var err error
 			if transient {
-				transport, err = c.cfg.TransportFactory.Open(ioCtx, target)
+				transport, err = c.openTransport(ioCtx, target)
 				if err == nil && transport == nil {
 					err = fmt.Errorf("transport factory returned nil: %w", errAdapterContract)
 				}
@@ -2114,6 +2543,7 @@ func (s *actorState) monitor(event playback.MonitorEvent) {
 	if !promoted && (event.Position != 0 || event.Duration != 0) {
 		if s.position != event.Position || s.duration != event.Duration {
 			s.position, s.duration, changed = event.Position, event.Duration, true
+			s.rememberPosition(s.active, false)
 		}
 	}
 	if event.State != "" {
@@ -2154,9 +2584,17 @@ func (s *actorState) monitor(event playback.MonitorEvent) {
 			s.lastError = "playback failed"
 		}
 		active := s.active
+		if event.Terminal == playback.TerminalFinished {
+			s.finishPosition(active)
+		} else {
+			s.rememberPosition(active, true)
+		}
 		active.cancel(event.Terminal)
 		advanced := playback.ShouldAdvance(event.Terminal, s.policy.LoopSelected, s.policy.AutoPlayNext) && s.followup(active)
 		if !advanced {
+			if s.disarmItemSleep() && event.Terminal == playback.TerminalFinished && s.controller.cfg.Logger != nil {
+				s.controller.cfg.Logger.Info("Sleep timer stopped playback")
+			}
 			s.active = nil
 			s.mutation, s.cleanup, s.state = true, true, PlaybackStateStopping
 			s.controller.cleanupTerminal(s.generation, active, event.Terminal)
@@ -2209,6 +2647,8 @@ func (s *actorState) promoteGapless() bool {
 	}
 	queued := active.queued
 	oldRoutes := slices.Clone(active.routeIDs)
+	s.finishPosition(active)
+	active.resumeSaved, active.resumeSavedAt, active.resumeFinished = 0, time.Time{}, false
 	active.itemID = queued.itemID
 	active.media = queued.media
 	active.subtitle = queued.subtitle
@@ -2218,6 +2658,7 @@ func (s *actorState) promoteGapless() bool {
 	active.routeIDs = queued.routeIDs
 	active.seekOffset = 0
 	active.expectedDuration = int(queued.load.Duration)
+	active.stream = queued.stream
 	active.imageReady = true
 	active.queued = nil
 	active.gaplessActive.Store(false)
@@ -2299,7 +2740,7 @@ func (s *actorState) resumeDeferredMonitor() {
 }
 
 func (s *actorState) followup(previous *activeSession) bool {
-	if s.mutation {
+	if s.mutation || s.sleepAfterItem() {
 		return false
 	}
 	targetCopy := previous.target
@@ -2326,7 +2767,7 @@ func (s *actorState) followup(previous *activeSession) bool {
 	s.queue.SetCurrentIndex(index)
 	// Desktop autoplay wraps, while manual next remains bounded. AdjacentIndex
 	// excludes the current item, so singleton and only-current-type queues stop.
-	target := s.queue.AdjacentIndex(1, s.policy.AutoPlaySameType, true)
+	target := s.nextIndex(s.queue)
 	if target < 0 {
 		return false
 	}
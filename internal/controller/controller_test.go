@@ -3,10 +3,12 @@ package controller
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"path/filepath"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -15,6 +17,7 @@ import (
 	"go2tv.app/go2tv/v2/internal/mediamodel"
 	"go2tv.app/go2tv/v2/internal/playback"
 	"go2tv.app/go2tv/v2/metadata"
+	"go2tv.app/go2tv/v2/utils"
 )
 
 type testReadSeekCloser struct{ *bytes.Reader }
@@ -509,6 +512,39 @@ func TestQueueAddPreservesIDsAndSelectsByID(t *testing.T) {
 	}
 }
 
+func TestSavedStateRestoresQueueAndWaitsForDevice(t *testing.T) {
+	discovery := newFakeDiscovery()
+	c := New(Config{Discovery: discovery, OperationTimeout: time.Second})
+	defer c.Close()
+	policy := Policy{AutoPlayNext: true, ImageDurationSeconds: 20}
+	tv := playback.Device{ID: "old-id", Name: "TV", Protocol: "DLNA", Endpoint: "http://tv/desc.xml"}
+	saved := SavedState{Queue: []MediaRef{testMedia("a.mp3", mediamodel.MediaKindAudio), testMedia("b.mp4", mediamodel.MediaKindVideo)}, Selected: 1, Policy: policy, Device: tv}
+	if result := c.RestoreState(context.Background(), Mutation{}, SavedState{Selected: 2, Queue: saved.Queue}); result.Code != CodeInvalid {
+		t.Fatalf("out of range selection = %#v", result)
+	}
+	if result := c.RestoreState(context.Background(), Mutation{}, saved); !result.OK() {
+		t.Fatal(result)
+	}
+	snapshot, _ := c.Snapshot(context.Background())
+	if len(snapshot.Queue) != 2 || !snapshot.Queue[1].IsSelected || snapshot.SelectedMedia != "b.mp4" || snapshot.Policy != policy || snapshot.SelectedDeviceID != "" {
+		t.Fatalf("restored snapshot = %#v", snapshot)
+	}
+	state, _, err := c.SavedState(context.Background())
+	if err != nil || len(state.Queue) != 2 || state.Queue[0].ID != "a.mp3" || state.Selected != 1 || state.Device != tv {
+		t.Fatalf("saved state before discovery = %#v, %v", state, err)
+	}
+
+	discovery.updates <- []playback.Device{{ID: "new-id", Name: "TV", Protocol: "DLNA", Endpoint: tv.Endpoint}}
+	snapshot = awaitSnapshotState(t, c, func(s Snapshot) bool { return s.SelectedDeviceID == "new-id" })
+	if result := c.RemoveQueueItem(context.Background(), Mutation{}, snapshot.Queue[0].ID); !result.OK() {
+		t.Fatal(result)
+	}
+	state, _, _ = c.SavedState(context.Background())
+	if len(state.Queue) != 1 || state.Selected != 0 || state.Device.ID != "new-id" {
+		t.Fatalf("saved state after discovery = %#v", state)
+	}
+}
+
 func TestQueueAddDeduplicatesAbsolutePath(t *testing.T) {
 	c, _, _ := newTestController()
 	defer c.Close()
@@ -930,6 +966,34 @@ func TestChromecastTranscodeLoadsMP4(t *testing.T) {
 	}
 }
 
+func TestRemoteMediaLoadsURLWithoutServing(t *testing.T) {
+	c, log, factory := newTestController(playback.Device{ID: "one", Protocol: "DLNA"})
+	defer c.Close()
+	awaitDevices(t, c, 1)
+	stream := MediaRef{RootID: "remote", ID: "https://radio.example/live", Name: "live", Title: "Live radio", Kind: mediamodel.MediaKindAudio, URL: "https://radio.example/live"}
+	if err := (MediaRef{RootID: "remote", ID: "x", Name: "x", Kind: mediamodel.MediaKindAudio, URL: "ftp://radio.example/live"}).Validate(); !errors.Is(err, ErrInvalidMedia) {
+		t.Fatalf("ftp URL validation = %v", err)
+	}
+	addTestQueue(t, c, stream, stream)
+	snapshot, _ := c.Snapshot(context.Background())
+	if len(snapshot.Queue) != 1 || snapshot.Queue[0].Name != "Live radio" {
+		t.Fatalf("queue = %#v", snapshot.Queue)
+	}
+	c.SelectDevice(context.Background(), Mutation{}, "one")
+	c.SelectSubtitle(context.Background(), Mutation{}, SubtitleRef{RootID: "root", ID: "sub", Name: "sub.srt", Open: testMedia("sub.srt", mediamodel.MediaKindVideo).OpenDirect})
+	c.SetTranscode(context.Background(), Mutation{}, true)
+	if result := c.Play(context.Background(), PlayRequest{QueueItemID: snapshot.Queue[0].ID}); !result.OK() {
+		t.Fatal(result)
+	}
+	load := factory.opened[0].load
+	if load.MediaURL != stream.URL || load.Transcode || load.SubtitleURL != "" || !load.Seekable {
+		t.Fatalf("load = %#v", load)
+	}
+	if slices.ContainsFunc(log.snapshot(), func(event string) bool { return strings.HasPrefix(event, "server:start") }) {
+		t.Fatalf("remote media started the media server: %v", log.snapshot())
+	}
+}
+
 func TestDLNATranscodeDurationKeepsRestartSeek(t *testing.T) {
 	device := playback.Device{ID: "one", Protocol: "DLNA"}
 	log := &eventLog{}
@@ -989,6 +1053,64 @@ func TestDLNATranscodeDurationKeepsRestartSeek(t *testing.T) {
 	}
 }
 
+func TestDLNAStreamFollowsRendererSinkFormats(t *testing.T) {
+	sink := "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC,http-get:*:video/vnd.dlna.mpeg-tts:*"
+	for _, tc := range []struct {
+		name      string
+		remux     bool
+		probeErr  error
+		transcode bool
+		mode      playback.StreamMode
+		ext, mime string
+	}{
+		{name: "remux", remux: true, mode: playback.StreamRemux, ext: ".ts", mime: "video/vnd.dlna.mpeg-tts"},
+		{name: "no remux", mode: playback.StreamTranscode, ext: ".mkv", mime: "video/x-matroska"},
+		{name: "probe failure", remux: true, probeErr: errors.New("no ffprobe"), mode: playback.StreamDirect, ext: ".mkv", mime: "video/x-matroska"},
+		{name: "transcode requested", remux: true, transcode: true, mode: playback.StreamTranscode, ext: ".mkv", mime: "video/x-matroska"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			device := playback.Device{ID: "one", Protocol: "DLNA", SinkProtocolInfo: sink}
+			log := &eventLog{}
+			server := &fakeServer{log: log}
+			var probes atomic.Int32
+			c := New(Config{
+				Discovery:        newFakeDiscovery(device),
+				TransportFactory: &fakeFactory{log: log},
+				MediaServer:      server,
+				OperationTimeout: time.Second,
+				Remux:            tc.remux,
+				CodecProbe: func(context.Context, playback.SourceOpener) (*utils.MediaCodecInfo, error) {
+					probes.Add(1)
+					return &utils.MediaCodecInfo{VideoCodec: "h264", AudioCodec: "aac"}, tc.probeErr
+				},
+			})
+			defer c.Close()
+			awaitDevices(t, c, 1)
+			c.SelectDevice(context.Background(), Mutation{}, device.ID)
+			media := testMedia("movie.mkv", mediamodel.MediaKindVideo)
+			media.MIMEType = "video/x-matroska"
+			c.SelectMedia(context.Background(), Mutation{}, media)
+			c.SetTranscode(context.Background(), Mutation{}, tc.transcode)
+			if result := c.Play(context.Background(), PlayRequest{}); !result.OK() {
+				t.Fatal(result)
+			}
+			snapshot := awaitSnapshotState(t, c, func(snapshot Snapshot) bool { return snapshot.HasSession })
+			if snapshot.Stream.Mode != tc.mode || snapshot.Stream.Reason == "" {
+				t.Fatalf("stream = %#v", snapshot.Stream)
+			}
+			server.mu.Lock()
+			request := server.last
+			server.mu.Unlock()
+			if request.Transcode != (tc.mode != playback.StreamDirect) || request.Remux != (tc.mode == playback.StreamRemux) || request.MediaExt != tc.ext || request.MediaType != tc.mime {
+				t.Fatalf("server request = %#v", request)
+			}
+			if tc.transcode && probes.Load() != 0 {
+				t.Fatal("requested transcode still probed codecs")
+			}
+		})
+	}
+}
+
 func TestLoadUsesDisplayNameAndArtwork(t *testing.T) {
 	c, _, factory := newTestController(playback.Device{ID: "one", Protocol: "DLNA"})
 	defer c.Close()
@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+// groupIDPrefix marks the device IDs of groups; discovered devices never use it.
+const groupIDPrefix = "group:"
+
+// groupRegistry holds the groups of a Controller and its additional sessions,
+// which share it. Transports are opened outside the actor, so it has its own
+// lock.
+type groupRegistry struct {
+	mu     sync.Mutex
+	next   int
+	groups []deviceGroup
+}
+
+type deviceGroup struct {
+	id        string
+	name      string
+	members   []string
+	tolerance time.Duration
+}
+
+func (r *groupRegistry) lookup(id string) (deviceGroup, bool) {
+	if r == nil || !strings.HasPrefix(id, groupIDPrefix) {
+		return deviceGroup{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := slices.IndexFunc(r.groups, func(group deviceGroup) bool { return group.id == id })
+	if index < 0 {
+		return deviceGroup{}, false
+	}
+	return r.groups[index], true
+}
+
+func (r *groupRegistry) infos() []GroupInfo {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]GroupInfo, 0, len(r.groups))
+	for _, group := range r.groups {
+		infos = append(infos, GroupInfo{ID: group.id, Name: group.name, DeviceIDs: slices.Clone(group.members), Tolerance: group.tolerance})
+	}
+	return infos
+}
+
+// withGroups returns discovered followed by one device per group whose
+// members are all present. A group takes its leader's protocol and is
+// audio-only if any member is.
+func (r *groupRegistry) withGroups(discovered []playback.Device) []playback.Device {
+	devices := slices.DeleteFunc(slices.Clone(discovered), func(device playback.Device) bool {
+		return strings.HasPrefix(device.ID, groupIDPrefix)
+	})
+	if r == nil {
+		return devices
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := len(devices)
+	for _, group := range r.groups {
+		device := playback.Device{ID: group.id, Name: group.name}
+		complete := true
+		for index, id := range group.members {
+			at := slices.IndexFunc(devices[:found], func(candidate playback.Device) bool { return candidate.ID == id })
+			if at < 0 {
+				complete = false
+				break
+			}
+			if index == 0 {
+				device.Protocol = devices[at].Protocol
+			}
+			device.AudioOnly = device.AudioOnly || devices[at].AudioOnly
+		}
+		if complete {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// regroup refreshes the device list after the groups changed. Groups are part
+// of the snapshot, so the revision moves even when no group device appeared
+// or vanished.
+func (s *actorState) regroup() {
+	revision := s.revision
+	s.setDevices(s.discovered)
+	if s.revision == revision {
+		s.commit()
+	}
+}
+
+// CreateGroup adds a group of discovered renderers that play together. The
+// first device leads: the group reports its position and status, and the
+// others are seeked back whenever they drift further than Tolerance from it.
+// The group appears in Snapshot.Devices under GroupResult.GroupID and is
+// selected and played like any device. Groups apply to every session.
+func (c *Controller) CreateGroup(ctx context.Context, request GroupRequest) GroupResult {
+	if ctx == nil || c.pinned != nil {
+		return GroupResult{Result: fail(request.RequestID, 0, ErrInvalidOperation)}
+	}
+	var id string
+	result := c.mutate(ctx, request.Mutation, func(s *actorState) Result {
+		if result := s.check(request.Mutation); !result.OK() {
+			return result
+		}
+		members := make([]string, 0, len(request.DeviceIDs))
+		for _, member := range request.DeviceIDs {
+			if !slices.Contains(members, member) {
+				members = append(members, member)
+			}
+		}
+		if len(members) < 2 || request.Tolerance < 0 {
+			return fail(request.RequestID, s.revision, ErrInvalidOperation)
+		}
+		names := make([]string, 0, len(members))
+		for _, member := range members {
+			index := slices.IndexFunc(s.discovered, func(device playback.Device) bool { return device.ID == member })
+			if index < 0 {
+				return fail(request.RequestID, s.revision, ErrNotFound)
+			}
+			names = append(names, s.discovered[index].Name)
+		}
+		name := strings.TrimSpace(request.Name)
+		if name == "" {
+			name = strings.Join(names, " + ")
+		}
+		tolerance := request.Tolerance
+		if tolerance == 0 {
+			tolerance = playback.DefaultGroupTolerance
+		}
+		groups := c.groups
+		groups.mu.Lock()
+		groups.next++
+		id = groupIDPrefix + strconv.Itoa(groups.next)
+		groups.groups = append(groups.groups, deviceGroup{id: id, name: name, members: members, tolerance: tolerance})
+		groups.mu.Unlock()
+		s.regroup()
+		if c.cfg.Logger != nil {
+			c.cfg.Logger.Info("Group created: " + name)
+		}
+		return Result{RequestID: request.RequestID, Revision: s.revision}
+	})
+	if !result.OK() {
+		return GroupResult{Result: result}
+	}
+	return GroupResult{Result: result, GroupID: id}
+}
+
+// RemoveGroup deletes a group. A session playing on it keeps playing until it
+// stops; an additional session opened for the group is closed.
+func (c *Controller) RemoveGroup(ctx context.Context, mutation Mutation, id string) Result {
+	if ctx == nil || c.pinned != nil {
+		return fail(mutation.RequestID, 0, ErrInvalidOperation)
+	}
+	var name string
+	result := c.mutate(ctx, mutation, func(s *actorState) Result {
+		if result := s.check(mutation); !result.OK() {
+			return result
+		}
+		groups := c.groups
+		groups.mu.Lock()
+		index := slices.IndexFunc(groups.groups, func(group deviceGroup) bool { return group.id == id })
+		if index >= 0 {
+			name = groups.groups[index].name
+			groups.groups = slices.Delete(groups.groups, index, index+1)
+		}
+		groups.mu.Unlock()
+		if index < 0 {
+			return fail(mutation.RequestID, s.revision, ErrNotFound)
+		}
+		s.regroup()
+		return Result{RequestID: mutation.RequestID, Revision: s.revision}
+	})
+	if !result.OK() {
+		return result
+	}
+	if c.lookupSession(id) != nil {
+		_ = c.CloseSession(ctx, Mutation{Session: id})
+	}
+	if c.cfg.Logger != nil {
+		c.cfg.Logger.Info("Group removed: " + name)
+	}
+	return result
+}
+
+// openTransport opens target, or every member of the group target names. A
+// follower that cannot be opened is left out so the rest of the group still
+// plays; the leader is required.
+func (c *Controller) openTransport(ctx context.Context, target playback.Device) (Transport, error) {
+	group, ok := c.groups.lookup(target.ID)
+	if !ok {
+		return c.cfg.TransportFactory.Open(ctx, target)
+	}
+	var devices []playback.Device
+	if c.cfg.Discovery != nil {
+		devices = c.cfg.Discovery.Snapshot()
+	}
+	members := make([]playback.GroupMember, 0, len(group.members))
+	closeMembers := func() {
+		for _, member := range members {
+			_ = rendererCleanup(member.Transport, c.cfg.OperationTimeout, func(ctx context.Context, transport Transport) error {
+				return transport.Close(ctx)
+			})
+		}
+	}
+	for index, id := range group.members {
+		at := slices.IndexFunc(devices, func(device playback.Device) bool { return device.ID == id })
+		var transport Transport
+		err := ErrNotFound
+		if at >= 0 {
+			transport, err = c.cfg.TransportFactory.Open(ctx, devices[at])
+			if err == nil && transport == nil {
+				err = errAdapterContract
+			}
+		}
+		if err != nil {
+			if index == 0 {
+				closeMembers()
+				return nil, err
+			}
+			if c.cfg.Logger != nil {
+				c.cfg.Logger.Warning("Group member unavailable: " + id)
+				c.cfg.Logger.Debug("Group member open failure detail: " + err.Error())
+			}
+			continue
+		}
+		members = append(members, playback.GroupMember{Device: devices[at], Transport: transport})
+	}
+	transport, err := playback.NewGroup(members, playback.GroupConfig{Tolerance: group.tolerance, MemberError: func(device playback.Device, err error) {
+		if c.cfg.Logger != nil {
+			c.cfg.Logger.Warning("Group member " + device.Name + " failed: " + err.Error())
+		}
+	}})
+	if err != nil {
+		closeMembers()
+		return nil, err
+	}
+	return transport, nil
+}
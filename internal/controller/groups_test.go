@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+func TestGroupPlaysOnEveryMember(t *testing.T) {
+	c, log, _ := newTestController(
+		playback.Device{ID: "one", Name: "Living room", Protocol: "DLNA"},
+		playback.Device{ID: "two", Name: "Kitchen", Protocol: "DLNA", AudioOnly: true},
+	)
+	defer c.Close()
+	awaitDevices(t, c, 2)
+	ctx := context.Background()
+	created := c.CreateGroup(ctx, GroupRequest{DeviceIDs: []string{"one", "two", "one"}})
+	if !created.OK() || created.GroupID == "" {
+		t.Fatal(created)
+	}
+	snapshot := awaitDevices(t, c, 3)
+	group := snapshot.Devices[2]
+	if group.ID != created.GroupID || group.Name != "Living room + Kitchen" || group.Protocol != "DLNA" || !group.AudioOnly {
+		t.Fatalf("group device = %+v", group)
+	}
+	if len(snapshot.Groups) != 1 || !slices.Equal(snapshot.Groups[0].DeviceIDs, []string{"one", "two"}) || snapshot.Groups[0].Tolerance != playback.DefaultGroupTolerance {
+		t.Fatalf("groups = %+v", snapshot.Groups)
+	}
+	if result := c.SelectDevice(ctx, Mutation{}, group.ID); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.QueueAndPlay(ctx, Mutation{}, testMedia("a.mp3", mediamodel.MediaKindAudio)); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.SetVolume(ctx, Mutation{}, 30); !result.OK() {
+		t.Fatal(result)
+	}
+	events := log.snapshot()
+	for _, want := range []string{"open:one", "open:two", "server:start:" + group.ID, "load:one", "load:two", "play:one", "play:two", "callbacks:1", "volume:set:one:30", "volume:set:two:30"} {
+		if !slices.Contains(events, want) {
+			t.Fatalf("missing %q in %v", want, events)
+		}
+	}
+	if slices.Contains(events, "server:start:one") || slices.Contains(events, "server:start:two") {
+		t.Fatalf("members got their own routes: %v", events)
+	}
+
+	if result := c.RemoveGroup(ctx, Mutation{}, group.ID); !result.OK() {
+		t.Fatal(result)
+	}
+	snapshot = awaitDevices(t, c, 2)
+	if len(snapshot.Groups) != 0 || snapshot.SelectedDeviceID != "" {
+		t.Fatalf("after removal = %+v", snapshot)
+	}
+	if result := c.RemoveGroup(ctx, Mutation{}, group.ID); result.Code != CodeNotFound {
+		t.Fatalf("second removal = %+v", result)
+	}
+}
+
+func TestCreateGroupValidation(t *testing.T) {
+	c, _, _ := newTestController(playback.Device{ID: "one", Protocol: "DLNA"}, playback.Device{ID: "two", Protocol: "Chromecast"})
+	defer c.Close()
+	awaitDevices(t, c, 2)
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name    string
+		request GroupRequest
+		code    ErrorCode
+	}{
+		{name: "single device", request: GroupRequest{DeviceIDs: []string{"one", "one"}}, code: CodeInvalid},
+		{name: "unknown device", request: GroupRequest{DeviceIDs: []string{"one", "gone"}}, code: CodeNotFound},
+		{name: "negative tolerance", request: GroupRequest{DeviceIDs: []string{"one", "two"}, Tolerance: -1}, code: CodeInvalid},
+	} {
+		if result := c.CreateGroup(ctx, tc.request); result.Code != tc.code || result.GroupID != "" {
+			t.Fatalf("%s = %+v", tc.name, result)
+		}
+	}
+	first := c.CreateGroup(ctx, GroupRequest{Name: " Party ", DeviceIDs: []string{"two", "one"}})
+	if !first.OK() {
+		t.Fatal(first)
+	}
+	// Groups are built from discovered devices, never from other groups.
+	if result := c.CreateGroup(ctx, GroupRequest{DeviceIDs: []string{first.GroupID, "one"}}); result.Code != CodeNotFound {
+		t.Fatalf("nested group = %+v", result)
+	}
+	snapshot, _ := c.Snapshot(ctx)
+	if index := slices.IndexFunc(snapshot.Devices, func(device playback.Device) bool { return device.ID == first.GroupID }); index < 0 || snapshot.Devices[index].Name != "Party" || snapshot.Devices[index].Protocol != "Chromecast" {
+		t.Fatalf("devices = %+v", snapshot.Devices)
+	}
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+	"go2tv.app/go2tv/v2/internal/resume"
+)
+
+// DLNA renderers often refuse a seek until they leave TRANSITIONING, so the
+// seek to a start position is retried for a few seconds.
+const (
+	startSeekAttempts = 5
+	startSeekInterval = time.Second
+)
+
+// startPoint is where a new session starts instead of the beginning: the
+// position a Transfer hands over, or a saved resume position.
+type startPoint struct {
+	seconds  int
+	duration int
+}
+
+// MarkWatched marks the library files at locations watched, or forgets their
+// playback history when watched is false. It needs Config.Resume.
+func (c *Controller) MarkWatched(ctx context.Context, mutation Mutation, locations []library.Location, watched bool) Result {
+	if target, result := c.sessionTarget(ctx, &mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.MarkWatched(ctx, mutation, locations, watched)
+	}
+	locations = slices.Clone(locations)
+	return c.mutate(ctx, mutation, func(s *actorState) Result {
+		if result := s.check(mutation); !result.OK() {
+			return result
+		}
+		if c.cfg.Resume == nil {
+			return fail(mutation.RequestID, s.revision, ErrInvalidOperation)
+		}
+		// The marks apply in memory even when an earlier write of the
+		// history file failed, so the error is only logged.
+		if err := c.cfg.Resume.SetWatched(locations, watched, time.Now()); err != nil && c.cfg.Logger != nil {
+			c.cfg.Logger.Warning("Playback history not saved: " + err.Error())
+		}
+		s.commit()
+		return Result{RequestID: mutation.RequestID, Revision: s.revision}
+	})
+}
+
+// savedStart returns the saved position of media, if there is one worth
+// resuming from.
+func (s *actorState) savedStart(media MediaRef, kind mediamodel.MediaKind) *startPoint {
+	history := s.controller.cfg.Resume
+	if history == nil || !resumable(media, kind) {
+		return nil
+	}
+	entry, ok := history.Find(media.Location)
+	if !ok || entry.Position < resume.MinPosition {
+		return nil
+	}
+	return &startPoint{seconds: entry.Position, duration: entry.Duration}
+}
+
+// rememberPosition saves where active is. Unless force is set, saves are
+// throttled to resume.SaveInterval. The store only updates memory here and
+// writes its file in the background, so the actor never waits on the disk; an
+// error reports an earlier write that failed.
+func (s *actorState) rememberPosition(active *activeSession, force bool) {
+	history := s.controller.cfg.Resume
+	if history == nil || active == nil || !resumable(active.media, active.kind) {
+		return
+	}
+	if resume.Finished(s.position, float64(s.duration)) {
+		s.finishPosition(active)
+		return
+	}
+	now := time.Now()
+	if !resume.ShouldSave(s.position, float64(s.duration), active.resumeSaved, active.resumeSavedAt, now, force) {
+		return
+	}
+	entry := resume.Entry{Key: active.media.Location, Name: active.media.Name, Kind: string(active.kind), Position: s.position, Duration: s.duration, UpdatedAt: now}
+	if err := history.Save(entry); err != nil && s.controller.cfg.Logger != nil {
+		s.controller.cfg.Logger.Debug("Resume position not saved: " + err.Error())
+	}
+	active.resumeSaved, active.resumeSavedAt = s.position, now
+}
+
+// finishPosition records that active played to the end. Position reports
+// keep arriving until the renderer stops, so a session counts only once.
+func (s *actorState) finishPosition(active *activeSession) {
+	history := s.controller.cfg.Resume
+	if history == nil || active == nil || active.resumeFinished || !resumable(active.media, active.kind) {
+		return
+	}
+	active.resumeFinished = true
+	entry := resume.Entry{Key: active.media.Location, Name: active.media.Name, Kind: string(active.kind), Duration: s.duration, UpdatedAt: time.Now()}
+	if err := history.Finish(entry); err != nil && s.controller.cfg.Logger != nil {
+		s.controller.cfg.Logger.Debug("Finished playback not recorded: " + err.Error())
+	}
+}
+
+func resumable(media MediaRef, kind mediamodel.MediaKind) bool {
+	return media.Location.Root != "" && (kind == mediamodel.MediaKindVideo || kind == mediamodel.MediaKindAudio)
+}
+
+// seekToStart seeks a freshly started session to from and returns the
+// position playback resumed at. Transcoded streams take the SeekEngine
+// restart path, which leaves the offset in server.SeekOffset. A seek that
+// keeps failing leaves the item playing from the start.
+func (c *Controller) seekToStart(ctx context.Context, generation uint64, target playback.Device, transport Transport, server *playback.ServerRequest, load playback.LoadRequest, from *startPoint) int {
+	var dlna playback.DLNATransport
+	var cast playback.ChromecastTransport
+	switch target.Protocol {
+	case "DLNA":
+		dlna, _ = transport.(playback.DLNATransport)
+	case "Chromecast":
+		cast, _ = transport.(playback.ChromecastTransport)
+	}
+	var suppressor callbackStopSuppressor
+	if dlna != nil && server.Transcode {
+		suppressor, _ = transport.(callbackStopSuppressor)
+		if suppressor != nil && suppressor.SuppressCallbackStops(generation, true) != nil {
+			suppressor = nil
+		}
+	}
+	engine := playback.NewSeekEngine(dlna, cast, c.cfg.MediaServer)
+	request := playback.SeekRequest{Protocol: target.Protocol, Transcoded: server.Transcode, Seconds: from.seconds, Duration: from.duration, Server: *server, Load: load}
+	var err error
+	for attempt := range startSeekAttempts {
+		if attempt > 0 {
+			timer := c.cfg.Clock.NewTimer(startSeekInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C():
+			}
+		}
+		if _, err = engine.Seek(ctx, request); err == nil || server.Transcode || dlna == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if suppressor != nil {
+		_ = suppressor.SuppressCallbackStops(generation, false)
+	}
+	if err != nil {
+		if c.cfg.Logger != nil {
+			c.cfg.Logger.Warning("Playback could not start at " + playbackTime(from.seconds))
+			c.cfg.Logger.Debug("Start seek failure detail: " + err.Error())
+		}
+		return 0
+	}
+	if server.Transcode {
+		server.SeekOffset = from.seconds
+	}
+	return from.seconds
+}
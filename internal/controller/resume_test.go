@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+	"go2tv.app/go2tv/v2/internal/resume"
+)
+
+func TestResumeContinuesFromSavedPosition(t *testing.T) {
+	history, _ := resume.Open("")
+	log := &eventLog{}
+	c := New(Config{
+		Discovery:        newFakeDiscovery(playback.Device{ID: "tv", Protocol: "DLNA"}),
+		TransportFactory: &fakeFactory{log: log},
+		MediaServer:      &fakeServer{log: log},
+		OperationTimeout: time.Second,
+		Resume:           history,
+	})
+	defer c.Close()
+	awaitDevices(t, c, 1)
+	ctx := context.Background()
+	movie := testMedia("movie.mp4", mediamodel.MediaKindVideo)
+	movie.Location = library.Location{Root: "/media", Path: "movie.mp4"}
+
+	playAt(t, c, "tv", movie, 125, 600)
+	if result := c.Stop(ctx, Mutation{}); !result.OK() {
+		t.Fatal(result)
+	}
+	snapshot, _ := c.Snapshot(ctx)
+	if len(snapshot.ContinueWatching) != 1 || snapshot.ContinueWatching[0].Key != movie.Location || snapshot.ContinueWatching[0].Position != 125 {
+		t.Fatalf("continue watching = %+v", snapshot.ContinueWatching)
+	}
+
+	// Starting over leaves the saved position alone.
+	if result := c.QueueAndPlay(ctx, Mutation{}, movie); !result.OK() {
+		t.Fatal(result)
+	}
+	if slices.Contains(log.snapshot(), "seek:00:02:05") {
+		t.Fatalf("start over seeked: %v", log.snapshot())
+	}
+	if result := c.QueueAndResume(ctx, Mutation{}, movie); !result.OK() {
+		t.Fatal(result)
+	}
+	if !slices.Contains(log.snapshot(), "seek:00:02:05") {
+		t.Fatalf("resume did not seek: %v", log.snapshot())
+	}
+
+	playing, _ := c.Snapshot(ctx)
+	c.HandleMonitorEvent(ctx, playback.MonitorEvent{Generation: playing.Generation, Position: 590, Duration: 600, State: PlaybackStatePlaying})
+	c.HandleMonitorEvent(ctx, playback.MonitorEvent{Generation: playing.Generation, Position: 595, Duration: 600, State: PlaybackStatePlaying})
+	c.HandleMonitorEvent(ctx, playback.MonitorEvent{Generation: playing.Generation, Terminal: playback.TerminalFinished})
+	awaitSnapshotState(t, c, func(snapshot Snapshot) bool { return len(snapshot.ContinueWatching) == 0 })
+	// One play to the end counts once, however many reports arrive past it.
+	if progress, _ := history.Progress(movie.Location); !progress.Watched || progress.Plays != 1 {
+		t.Fatalf("progress after finishing = %+v", progress)
+	}
+}
+
+func TestMarkWatchedUpdatesHistory(t *testing.T) {
+	ctx := context.Background()
+	plain := New(Config{Discovery: newFakeDiscovery(), TransportFactory: &fakeFactory{log: &eventLog{}}, MediaServer: &fakeServer{log: &eventLog{}}})
+	defer plain.Close()
+	if result := plain.MarkWatched(ctx, Mutation{}, nil, true); result.Code != CodeInvalid {
+		t.Fatalf("mark without history = %+v", result)
+	}
+
+	history, _ := resume.Open("")
+	c := New(Config{Discovery: newFakeDiscovery(), TransportFactory: &fakeFactory{log: &eventLog{}}, MediaServer: &fakeServer{log: &eventLog{}}, Resume: history})
+	defer c.Close()
+	episodes := []library.Location{{Root: "/media", Path: "show/e01.mkv"}, {Root: "/media", Path: "show/e02.mkv"}}
+	if err := history.Save(resume.Entry{Key: episodes[1], Position: 300, Duration: 1500, UpdatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := c.Snapshot(ctx)
+	result := c.MarkWatched(ctx, Mutation{}, episodes, true)
+	if !result.OK() || result.Revision != before.Revision+1 {
+		t.Fatalf("mark watched = %+v", result)
+	}
+	for _, location := range episodes {
+		if progress, _ := history.Progress(location); !progress.Watched || progress.Position != 0 {
+			t.Fatalf("%s progress = %+v", location.Path, progress)
+		}
+	}
+	if snapshot, _ := c.Snapshot(ctx); len(snapshot.ContinueWatching) != 0 {
+		t.Fatalf("continue watching = %+v", snapshot.ContinueWatching)
+	}
+	if result := c.MarkWatched(ctx, Mutation{}, episodes, false); !result.OK() {
+		t.Fatal(result)
+	}
+	if _, ok := history.Progress(episodes[0]); ok {
+		t.Fatal("unwatched file kept its history")
+	}
+}
+
+func TestResumeSkipsMediaOutsideLibrary(t *testing.T) {
+	history, _ := resume.Open("")
+	log := &eventLog{}
+	c := New(Config{
+		Discovery:        newFakeDiscovery(playback.Device{ID: "tv", Protocol: "DLNA"}),
+		TransportFactory: &fakeFactory{log: log},
+		MediaServer:      &fakeServer{log: log},
+		OperationTimeout: time.Second,
+		Resume:           history,
+	})
+	defer c.Close()
+	awaitDevices(t, c, 1)
+	playAt(t, c, "tv", testMedia("clip.mp4", mediamodel.MediaKindVideo), 125, 600)
+	if result := c.Stop(context.Background(), Mutation{}); !result.OK() {
+		t.Fatal(result)
+	}
+	if recent := history.Recent(ContinueWatchingLimit); len(recent) != 0 {
+		t.Fatalf("saved %+v", recent)
+	}
+}
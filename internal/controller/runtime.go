@@ -3,10 +3,14 @@ package controller
 import (
 	"context"
 	"io"
+	"net/http"
 	"time"
 
 	"go2tv.app/go2tv/v2/internal/playback"
 	"go2tv.app/go2tv/v2/internal/playbackadapter"
+	"go2tv.app/go2tv/v2/internal/resume"
+	"go2tv.app/go2tv/v2/soapcalls"
+	"go2tv.app/go2tv/v2/utils"
 )
 
 // RuntimeConfig composes production protocol adapters without server/UI wiring.
@@ -21,10 +25,21 @@ type RuntimeConfig struct {
 	Logger            EventLogger
 	Artwork           *ArtworkCache
 	DurationProbe     func(context.Context, playback.SourceOpener) (float64, error)
+	CodecProbe        func(context.Context, playback.SourceOpener) (*utils.MediaCodecInfo, error)
+	Remux             bool
 	// Discovery overrides the default scanner-backed discovery service when
 	// non-nil. GUI-managed children inject a pipe-fed discovery here; nil
 	// keeps the standalone SSDP/mDNS construction.
 	Discovery playback.Discovery
+	// SessionMediaServer builds the media server of an additional per-device
+	// session around that session's callback handler. Nil limits the
+	// Controller to the default session.
+	SessionMediaServer func(callback http.Handler) playback.MediaServer
+	// Resume is where playback positions are saved; nil disables resuming.
+	Resume *resume.Store
+	// Quirks holds the DLNA renderer workarounds; nil uses the built-in
+	// ones and learns nothing.
+	Quirks *soapcalls.QuirkDB
 }
 
 // NewRuntimeConfig builds a Config backed by production discovery, transport,
@@ -34,8 +49,17 @@ func NewRuntimeConfig(cfg RuntimeConfig) Config {
 	if discovery == nil {
 		discovery = playback.NewDiscoveryService(playbackadapter.Scanner{DLNADelay: cfg.DLNADelay}, nil, nil, cfg.DiscoveryInterval)
 	}
-	factory := &playbackadapter.Factory{LogOutput: cfg.LogOutput, CallbackURL: callbackURLProvider(cfg.MediaServer), Callbacks: cfg.Callbacks}
-	return Config{ParentContext: cfg.ParentContext, Discovery: discovery, TransportFactory: factory, MediaServer: cfg.MediaServer, Artwork: cfg.Artwork, RunMonitor: playbackadapter.RunMonitor, DurationProbe: cfg.DurationProbe, OperationTimeout: cfg.OperationTimeout, Logger: cfg.Logger}
+	factory := &playbackadapter.Factory{LogOutput: cfg.LogOutput, CallbackURL: callbackURLProvider(cfg.MediaServer), Callbacks: cfg.Callbacks, Quirks: cfg.Quirks}
+	config := Config{ParentContext: cfg.ParentContext, Discovery: discovery, TransportFactory: factory, MediaServer: cfg.MediaServer, Artwork: cfg.Artwork, RunMonitor: playbackadapter.RunMonitor, DurationProbe: cfg.DurationProbe, CodecProbe: cfg.CodecProbe, Remux: cfg.Remux, OperationTimeout: cfg.OperationTimeout, Logger: cfg.Logger, Resume: cfg.Resume}
+	if cfg.SessionMediaServer != nil {
+		config.NewSession = func(playback.Device) (SessionAdapters, error) {
+			callbacks := playbackadapter.NewCallbackBridge()
+			server := cfg.SessionMediaServer(callbacks)
+			factory := &playbackadapter.Factory{LogOutput: cfg.LogOutput, CallbackURL: callbackURLProvider(server), Callbacks: callbacks, Quirks: cfg.Quirks}
+			return SessionAdapters{TransportFactory: factory, MediaServer: server, Release: callbacks.Close}, nil
+		}
+	}
+	return config
 }
 
 func callbackURLProvider(server playback.MediaServer) playbackadapter.CallbackURLProvider {
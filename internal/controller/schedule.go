@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+// SetSleepTimer arms, replaces, or disarms the sleep timer. Item-based modes
+// also withdraw any gapless next staged on the renderer, since the renderer
+// would otherwise advance on its own.
+func (c *Controller) SetSleepTimer(ctx context.Context, request SleepTimerRequest) Result {
+	if target, result := c.sessionTarget(ctx, &request.Mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.SetSleepTimer(ctx, request)
+	}
+	return c.mutate(ctx, request.Mutation, func(s *actorState) Result {
+		if result := s.check(request.Mutation); !result.OK() {
+			return result
+		}
+		timer := SleepTimer{Mode: request.Mode}
+		switch request.Mode {
+		case SleepOff, SleepAfterItem, SleepEndOfQueue:
+		case SleepAfterTime:
+			if request.Duration <= 0 || request.Duration > MaxSleepDuration {
+				return fail(request.RequestID, s.revision, ErrInvalidOperation)
+			}
+			timer.Deadline = c.cfg.Clock.Now().Add(request.Duration).Round(time.Second)
+		default:
+			return fail(request.RequestID, s.revision, ErrInvalidOperation)
+		}
+		s.disarmSleep()
+		s.sleep = timer
+		if timer.Mode == SleepAfterTime {
+			s.sleepCancel = s.controller.afterDelay(request.Duration, (*actorState).sleepExpired)
+		}
+		s.commit()
+		s.reconcileGapless()
+		if c.cfg.Logger != nil {
+			if timer.Mode == SleepOff {
+				c.cfg.Logger.Info("Sleep timer canceled")
+			} else {
+				c.cfg.Logger.Info("Sleep timer set: " + string(timer.Mode))
+			}
+		}
+		return Result{RequestID: request.RequestID, Revision: s.revision}
+	})
+}
+
+// ScheduleStart replaces the scheduled start. Without a DeviceID it targets
+// the selected device, or a restored selection still awaiting discovery. When
+// it fires, the device is selected and the selected media plays as if Play had been called, or the
+// first queue item when nothing is selected. The outcome is reported through
+// LastError and the logger.
+func (c *Controller) ScheduleStart(ctx context.Context, request ScheduleStartRequest) Result {
+	if target, result := c.sessionTarget(ctx, &request.Mutation); target != c {
+		if target == nil {
+			return result
+		}
+		return target.ScheduleStart(ctx, request)
+	}
+	return c.mutate(ctx, request.Mutation, func(s *actorState) Result {
+		if result := s.check(request.Mutation); !result.OK() {
+			return result
+		}
+		if request.At.IsZero() {
+			s.disarmSchedule()
+			s.commit()
+			return Result{RequestID: request.RequestID, Revision: s.revision}
+		}
+		delay := request.At.Sub(c.cfg.Clock.Now())
+		if delay <= 0 || delay > MaxScheduleAhead {
+			return fail(request.RequestID, s.revision, ErrInvalidOperation)
+		}
+		if c.pinned != nil && request.DeviceID != "" && request.DeviceID != c.pinned.ID {
+			return fail(request.RequestID, s.revision, ErrInvalidOperation)
+		}
+		device, ok := s.selectedDevice()
+		if request.DeviceID != "" {
+			index := slices.IndexFunc(s.devices, func(device playback.Device) bool { return device.ID == request.DeviceID })
+			if index < 0 {
+				return fail(request.RequestID, s.revision, ErrNotFound)
+			}
+			device, ok = s.devices[index], true
+		} else if !ok && s.preferred != nil {
+			device, ok = *s.preferred, true
+		}
+		if !ok {
+			return fail(request.RequestID, s.revision, ErrNoDevice)
+		}
+		if (s.queue == nil || s.queue.Len() == 0) && !s.media.valid() {
+			return fail(request.RequestID, s.revision, ErrNoMedia)
+		}
+		s.disarmSchedule()
+		s.schedule = ScheduledStart{At: request.At, DeviceID: device.ID, DeviceName: device.Name}
+		s.scheduleDevice = device
+		s.scheduleCancel = s.controller.afterDelay(delay, (*actorState).startScheduled)
+		s.commit()
+		if c.cfg.Logger != nil {
+			c.cfg.Logger.Info("Playback scheduled on " + device.Name + " at " + request.At.Format(time.DateTime))
+		}
+		return Result{RequestID: request.RequestID, Revision: s.revision}
+	})
+}
+
+// afterDelay runs fire on the actor once delay has passed on the configured
+// Clock. The returned cancel function must be called from the actor; a timer
+// that already fired is then ignored.
+func (c *Controller) afterDelay(delay time.Duration, fire func(*actorState)) context.CancelFunc {
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.goOwned(func() {
+		timer := c.cfg.Clock.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C():
+			_ = c.enqueueInternal(message{fn: func(s *actorState) {
+				if ctx.Err() == nil {
+					cancel()
+					fire(s)
+				}
+			}})
+		}
+	})
+	return cancel
+}
+
+func (s *actorState) disarmSleep() {
+	if s.sleepCancel != nil {
+		s.sleepCancel()
+		s.sleepCancel = nil
+	}
+	s.sleep = SleepTimer{}
+}
+
+func (s *actorState) disarmSchedule() {
+	if s.scheduleCancel != nil {
+		s.scheduleCancel()
+		s.scheduleCancel = nil
+	}
+	s.schedule, s.scheduleDevice = ScheduledStart{}, playback.Device{}
+}
+
+// sleepAfterItem reports whether the item that just ended must not be
+// followed by another one.
+func (s *actorState) sleepAfterItem() bool {
+	return s.sleep.Mode == SleepAfterItem || s.sleep.Mode == SleepEndOfQueue && s.policy.LoopSelected
+}
+
+// disarmItemSleep clears an item-based sleep timer once playback has stopped.
+// It reports whether one was armed.
+func (s *actorState) disarmItemSleep() bool {
+	if s.sleep.Mode != SleepAfterItem && s.sleep.Mode != SleepEndOfQueue {
+		return false
+	}
+	s.disarmSleep()
+	return true
+}
+
+func (s *actorState) sleepExpired() {
+	s.disarmSleep()
+	s.commit()
+	c := s.controller
+	if s.active == nil && s.pending == nil {
+		return
+	}
+	if c.cfg.Logger != nil {
+		c.cfg.Logger.Info("Sleep timer expired; stopping playback")
+	}
+	c.goOwned(func() { _ = c.Stop(c.ctx, Mutation{}) })
+}
+
+func (s *actorState) startScheduled() {
+	device := s.scheduleDevice
+	s.disarmSchedule()
+	logger := s.controller.cfg.Logger
+	index := slices.IndexFunc(s.devices, func(candidate playback.Device) bool {
+		return candidate.ID == device.ID || sameDevice(candidate, device)
+	})
+	if index < 0 {
+		s.lastError = "scheduled device unavailable"
+		s.commit()
+		if logger != nil {
+			logger.Warning("Scheduled playback skipped: " + device.Name + " is unavailable")
+		}
+		return
+	}
+	device = s.devices[index]
+	s.selectedID, s.preferred = device.ID, nil
+	s.commit()
+	request := PlayRequest{target: &device}
+	if s.queue != nil && !s.media.valid() {
+		// Nothing selected yet: play the queue from the top.
+		if _, ok := s.queue.Current(); !ok {
+			if first, ok := s.queue.Item(0); ok {
+				request.QueueItemID = first.ID()
+			}
+		}
+	}
+	response := make(chan Result, 1)
+	s.beginPlay(request, response)
+	select {
+	case result := <-response:
+		if !result.OK() {
+			s.lastError = "scheduled playback failed"
+			s.commit()
+			if logger != nil {
+				logger.Error("Scheduled playback failed")
+				logger.Debug("Scheduled playback failure detail: " + result.Message)
+			}
+		}
+	default:
+		if logger != nil {
+			logger.Info("Scheduled playback starting on " + device.Name)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+func newScheduleTestController(t *testing.T, device playback.Device) (*Controller, *autoplayClock) {
+	t.Helper()
+	log := &eventLog{}
+	clock := newAutoplayClock()
+	c := New(Config{
+		Discovery:        newFakeDiscovery(device),
+		TransportFactory: &fakeFactory{log: log},
+		MediaServer:      &fakeServer{log: log},
+		Clock:            clock,
+		OperationTimeout: time.Second,
+	})
+	t.Cleanup(c.Close)
+	awaitDevices(t, c, 1)
+	addTestQueue(t, c,
+		testMedia("a.mp3", mediamodel.MediaKindAudio),
+		testMedia("b.mp3", mediamodel.MediaKindAudio),
+	)
+	return c, clock
+}
+
+func TestSleepTimerModes(t *testing.T) {
+	device := playback.Device{ID: "renderer", Protocol: "DLNA"}
+	finish := func(t *testing.T, c *Controller) {
+		t.Helper()
+		playing, _ := c.Snapshot(context.Background())
+		c.HandleMonitorEvent(context.Background(), playback.MonitorEvent{Generation: playing.Generation, Terminal: playback.TerminalFinished})
+	}
+	play := func(t *testing.T, c *Controller, index int, policy Policy) Snapshot {
+		t.Helper()
+		c.SelectDevice(context.Background(), Mutation{}, device.ID)
+		if result := c.SetPolicy(context.Background(), PolicyRequest{Policy: policy}); !result.OK() {
+			t.Fatal(result)
+		}
+		queued, _ := c.Snapshot(context.Background())
+		if result := c.Play(context.Background(), PlayRequest{QueueItemID: queued.Queue[index].ID}); !result.OK() {
+			t.Fatal(result)
+		}
+		return queued
+	}
+	stopped := func(snapshot Snapshot) bool {
+		return !snapshot.HasSession && snapshot.PlaybackState == PlaybackStateStopped && snapshot.SleepTimer.Mode == SleepOff
+	}
+	autoplay := Policy{AutoPlayNext: true, ImageDurationSeconds: 10}
+
+	t.Run("time", func(t *testing.T) {
+		c, clock := newScheduleTestController(t, device)
+		play(t, c, 0, autoplay)
+		if result := c.SetSleepTimer(context.Background(), SleepTimerRequest{Mode: SleepAfterTime, Duration: 30 * time.Minute}); !result.OK() {
+			t.Fatal(result)
+		}
+		armed, _ := c.Snapshot(context.Background())
+		if armed.SleepTimer.Mode != SleepAfterTime || !armed.SleepTimer.Deadline.Equal(autoplayNow.Add(30*time.Minute)) {
+			t.Fatalf("sleep timer = %#v", armed.SleepTimer)
+		}
+		waitAutoplayTimer(t, clock).ch <- time.Now()
+		awaitAutoplaySnapshot(t, c, stopped)
+	})
+
+	t.Run("after item", func(t *testing.T) {
+		c, _ := newScheduleTestController(t, device)
+		queued := play(t, c, 0, autoplay)
+		if result := c.SetSleepTimer(context.Background(), SleepTimerRequest{Mode: SleepAfterItem}); !result.OK() {
+			t.Fatal(result)
+		}
+		finish(t, c)
+		after := awaitAutoplaySnapshot(t, c, stopped)
+		if !after.Queue[0].IsSelected || after.Queue[0].ID != queued.Queue[0].ID {
+			t.Fatalf("queue advanced past sleep timer: %#v", after.Queue)
+		}
+	})
+
+	t.Run("end of queue does not wrap", func(t *testing.T) {
+		c, _ := newScheduleTestController(t, device)
+		play(t, c, 0, autoplay)
+		if result := c.SetSleepTimer(context.Background(), SleepTimerRequest{Mode: SleepEndOfQueue}); !result.OK() {
+			t.Fatal(result)
+		}
+		playing, _ := c.Snapshot(context.Background())
+		finish(t, c)
+		next := awaitAutoplaySnapshot(t, c, func(snapshot Snapshot) bool {
+			return snapshot.Generation > playing.Generation && snapshot.HasSession && snapshot.Queue[1].IsActive
+		})
+		if next.SleepTimer.Mode != SleepEndOfQueue {
+			t.Fatalf("sleep timer disarmed before the end of the queue: %#v", next.SleepTimer)
+		}
+		finish(t, c)
+		after := awaitAutoplaySnapshot(t, c, stopped)
+		if !after.Queue[1].IsSelected {
+			t.Fatalf("queue wrapped past sleep timer: %#v", after.Queue)
+		}
+	})
+
+	t.Run("stop disarms item modes only", func(t *testing.T) {
+		c, clock := newScheduleTestController(t, device)
+		play(t, c, 0, autoplay)
+		c.SetSleepTimer(context.Background(), SleepTimerRequest{Mode: SleepAfterItem})
+		if result := c.Stop(context.Background(), Mutation{}); !result.OK() {
+			t.Fatal(result)
+		}
+		if snapshot, _ := c.Snapshot(context.Background()); snapshot.SleepTimer.Mode != SleepOff {
+			t.Fatalf("after-item timer survived stop: %#v", snapshot.SleepTimer)
+		}
+		c.SetSleepTimer(context.Background(), SleepTimerRequest{Mode: SleepAfterTime, Duration: time.Minute})
+		timer := waitAutoplayTimer(t, clock)
+		c.Stop(context.Background(), Mutation{})
+		if snapshot, _ := c.Snapshot(context.Background()); snapshot.SleepTimer.Mode != SleepAfterTime {
+			t.Fatalf("timed sleep disarmed by stop: %#v", snapshot.SleepTimer)
+		}
+		if result := c.SetSleepTimer(context.Background(), SleepTimerRequest{}); !result.OK() {
+			t.Fatal(result)
+		}
+		select {
+		case <-timer.stopped:
+		case <-time.After(time.Second):
+			t.Fatal("canceled sleep timer still waiting")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		c, _ := newScheduleTestController(t, device)
+		for _, request := range []SleepTimerRequest{
+			{Mode: SleepAfterTime},
+			{Mode: SleepAfterTime, Duration: MaxSleepDuration + time.Minute},
+			{Mode: "tomorrow"},
+		} {
+			if result := c.SetSleepTimer(context.Background(), request); result.Code != CodeInvalid {
+				t.Fatalf("%#v = %#v", request, result)
+			}
+		}
+	})
+}
+
+func TestScheduledStartPlaysOnDevice(t *testing.T) {
+	device := playback.Device{ID: "renderer", Name: "Bedroom TV", Protocol: "DLNA", Endpoint: "http://tv"}
+	c, clock := newScheduleTestController(t, device)
+	at := autoplayNow.Add(8 * time.Hour)
+	for _, request := range []ScheduleStartRequest{
+		{At: at},
+		{At: at, DeviceID: "missing"},
+		{At: autoplayNow.Add(-time.Minute), DeviceID: device.ID},
+		{At: autoplayNow.Add(MaxScheduleAhead + time.Hour), DeviceID: device.ID},
+	} {
+		if result := c.ScheduleStart(context.Background(), request); result.OK() {
+			t.Fatalf("%#v scheduled", request)
+		}
+	}
+
+	if result := c.ScheduleStart(context.Background(), ScheduleStartRequest{At: at, DeviceID: device.ID}); !result.OK() {
+		t.Fatal(result)
+	}
+	first := waitAutoplayTimer(t, clock)
+	if result := c.ScheduleStart(context.Background(), ScheduleStartRequest{}); !result.OK() {
+		t.Fatal(result)
+	}
+	<-first.stopped
+	if result := c.ScheduleStart(context.Background(), ScheduleStartRequest{At: at, DeviceID: device.ID}); !result.OK() {
+		t.Fatal(result)
+	}
+	scheduled, _ := c.Snapshot(context.Background())
+	if !scheduled.ScheduledStart.At.Equal(at) || scheduled.ScheduledStart.DeviceName != "Bedroom TV" || scheduled.SelectedDeviceID != "" {
+		t.Fatalf("scheduled start = %#v", scheduled.ScheduledStart)
+	}
+	// A stale timer that fires after cancellation is ignored.
+	first.ch <- time.Now()
+	waitAutoplayTimer(t, clock).ch <- time.Now()
+	after := awaitAutoplaySnapshot(t, c, func(snapshot Snapshot) bool { return snapshot.HasSession })
+	if after.ActiveDeviceID != device.ID || after.SelectedDeviceID != device.ID || !after.Queue[0].IsActive || !after.ScheduledStart.At.IsZero() {
+		t.Fatalf("scheduled playback = %#v", after)
+	}
+}
@@ -0,0 +1,337 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+// session is an additional per-device session. Each one is a Controller of its
+// own, so queue, policy, monitor, and media-server routes stay independent of
+// the default session.
+type session struct {
+	controller *Controller
+	discovery  *sessionDiscovery
+	release    func()
+}
+
+// sessionDiscovery feeds an additional session the devices the default session
+// sees, without starting a second scan.
+type sessionDiscovery struct {
+	source  Discovery
+	mu      sync.Mutex
+	devices []playback.Device
+	updates chan []playback.Device
+}
+
+func newSessionDiscovery(source Discovery, devices []playback.Device) *sessionDiscovery {
+	return &sessionDiscovery{source: source, devices: slices.Clone(devices), updates: make(chan []playback.Device, 1)}
+}
+
+func (d *sessionDiscovery) Start(context.Context) {}
+
+func (d *sessionDiscovery) Refresh(ctx context.Context) error {
+	if d.source == nil {
+		return ErrInvalidOperation
+	}
+	if err := d.source.Refresh(ctx); err != nil {
+		return err
+	}
+	d.publish(d.source.Snapshot())
+	return nil
+}
+
+func (d *sessionDiscovery) Snapshot() []playback.Device {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.devices)
+}
+
+func (d *sessionDiscovery) Subscribe(int) (<-chan []playback.Device, func()) {
+	return d.updates, nil
+}
+
+// publish keeps only the latest list pending, so a busy session never blocks
+// the default session's actor.
+func (d *sessionDiscovery) publish(devices []playback.Device) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.devices = slices.Clone(devices)
+	select {
+	case <-d.updates:
+	default:
+	}
+	d.updates <- slices.Clone(devices)
+}
+
+// sessionDeviceID is the device this session drives: the active target while
+// something plays, otherwise the selection.
+func (s *actorState) sessionDeviceID() string {
+	if s.active != nil {
+		return s.active.target.ID
+	}
+	return s.selectedID
+}
+
+// sessionTarget resolves mutation.Session and clears it. It returns c for the
+// default session, the Controller of an additional session, or nil with the
+// failed Result.
+func (c *Controller) sessionTarget(ctx context.Context, mutation *Mutation) (*Controller, Result) {
+	id := mutation.Session
+	mutation.Session = ""
+	if id == "" || c.pinned != nil {
+		return c, Result{}
+	}
+	if ctx == nil {
+		return nil, fail(mutation.RequestID, 0, ErrInvalidOperation)
+	}
+	if existing := c.lookupSession(id); existing != nil {
+		return existing, Result{}
+	}
+	var owned bool
+	var devices []playback.Device
+	if _, err := c.callActor(ctx, func(s *actorState) {
+		owned = s.sessionDeviceID() == id
+		devices = slices.Clone(s.devices)
+	}); err != nil {
+		return nil, fail(mutation.RequestID, 0, err)
+	}
+	if owned {
+		return c, Result{}
+	}
+	target, err := c.openSession(id, devices)
+	if err != nil {
+		return nil, fail(mutation.RequestID, 0, err)
+	}
+	return target, Result{}
+}
+
+func (c *Controller) lookupSession(id string) *Controller {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	if existing, ok := c.sessions[id]; ok {
+		return existing.controller
+	}
+	return nil
+}
+
+func (c *Controller) openSession(id string, devices []playback.Device) (*Controller, error) {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	if c.sessionsClosed {
+		return nil, ErrClosed
+	}
+	if existing, ok := c.sessions[id]; ok {
+		return existing.controller, nil
+	}
+	index := slices.IndexFunc(devices, func(device playback.Device) bool { return device.ID == id })
+	if index < 0 {
+		return nil, ErrNotFound
+	}
+	if c.cfg.NewSession == nil {
+		return nil, ErrInvalidOperation
+	}
+	device := devices[index]
+	adapters, err := c.cfg.NewSession(device)
+	if err != nil {
+		return nil, err
+	}
+	discovery := newSessionDiscovery(c.cfg.Discovery, devices)
+	cfg := c.cfg
+	cfg.ParentContext = c.ctx
+	cfg.Discovery = discovery
+	cfg.TransportFactory, cfg.MediaServer = adapters.TransportFactory, adapters.MediaServer
+	cfg.NewSession = nil
+	if c.sessions == nil {
+		c.sessions = make(map[string]*session)
+	}
+	c.sessions[id] = &session{controller: start(cfg, &device, c.groups, c.changes), discovery: discovery, release: adapters.Release}
+	if c.cfg.Logger != nil {
+		c.cfg.Logger.Info("Session opened on " + device.Name)
+	}
+	return c.sessions[id].controller, nil
+}
+
+// CloseSession stops and discards the additional session named by
+// mutation.Session. The default session cannot be closed.
+func (c *Controller) CloseSession(ctx context.Context, mutation Mutation) Result {
+	if ctx == nil || mutation.Session == "" {
+		return fail(mutation.RequestID, 0, ErrInvalidOperation)
+	}
+	c.sessionsMu.Lock()
+	closing, ok := c.sessions[mutation.Session]
+	if ok {
+		delete(c.sessions, mutation.Session)
+	}
+	c.sessionsMu.Unlock()
+	if !ok {
+		return fail(mutation.RequestID, 0, ErrNotFound)
+	}
+	err := closing.shutdown(ctx)
+	if err != nil {
+		return fail(mutation.RequestID, 0, err)
+	}
+	if c.cfg.Logger != nil {
+		c.cfg.Logger.Info("Session closed on " + closing.controller.pinned.Name)
+	}
+	return Result{RequestID: mutation.RequestID}
+}
+
+// shutdown releases the adapters once the session has stopped, which may be
+// after ctx expires.
+func (s *session) shutdown(ctx context.Context) error {
+	release := func() {
+		if s.release != nil {
+			s.release()
+		}
+	}
+	err := s.controller.Shutdown(ctx)
+	if err != nil {
+		go func() {
+			<-s.controller.Done()
+			release()
+		}()
+		return err
+	}
+	release()
+	return nil
+}
+
+// closeSessions shuts down every additional session once the default session
+// has stopped. Their contexts derive from c.ctx, so they are already stopping.
+func (c *Controller) closeSessions() {
+	c.sessionsMu.Lock()
+	c.sessionsClosed = true
+	sessions := c.sessions
+	c.sessions = nil
+	c.sessionsMu.Unlock()
+	for _, open := range sessions {
+		open.controller.Close()
+		if open.release != nil {
+			open.release()
+		}
+	}
+}
+
+// publishDevices forwards a discovery update to the additional sessions. It
+// runs on the default session's actor.
+func (c *Controller) publishDevices(devices []playback.Device) {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	for _, open := range c.sessions {
+		open.discovery.publish(devices)
+	}
+}
+
+// claimDevice lets the default session take over a device from an idle
+// additional session. A session that is still playing keeps its device.
+func (c *Controller) claimDevice(ctx context.Context, request PlayRequest) error {
+	if c.pinned != nil {
+		return nil
+	}
+	c.sessionsMu.Lock()
+	empty := len(c.sessions) == 0
+	c.sessionsMu.Unlock()
+	if empty {
+		return nil
+	}
+	id := ""
+	if request.target != nil {
+		id = request.target.ID
+	} else if _, err := c.callActor(ctx, func(s *actorState) { id = s.selectedID }); err != nil {
+		return err
+	}
+	other := c.lookupSession(id)
+	if other == nil {
+		return nil
+	}
+	snapshot, err := other.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	if snapshot.HasSession || snapshot.PlaybackState == PlaybackStateLoading {
+		return ErrBusy
+	}
+	if result := c.CloseSession(ctx, Mutation{Session: id}); !result.OK() && result.Code != CodeNotFound {
+		return result.Err()
+	}
+	return nil
+}
+
+// SessionSnapshot returns the Snapshot of one session, resolved like
+// Mutation.Session but without opening a new session. Unknown IDs return
+// ErrNotFound.
+func (c *Controller) SessionSnapshot(ctx context.Context, id string) (Snapshot, error) {
+	if id == "" || c.pinned != nil {
+		return c.Snapshot(ctx)
+	}
+	if ctx == nil {
+		return Snapshot{}, ErrInvalidOperation
+	}
+	if other := c.lookupSession(id); other != nil {
+		return other.Snapshot(ctx)
+	}
+	snapshot, err := c.Snapshot(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if sessionDevice(snapshot) != id {
+		return Snapshot{}, ErrNotFound
+	}
+	return snapshot, nil
+}
+
+func sessionDevice(snapshot Snapshot) string {
+	if snapshot.HasSession {
+		return snapshot.ActiveDeviceID
+	}
+	return snapshot.SelectedDeviceID
+}
+
+// sessionInfos lists this session followed by the additional ones. Sessions
+// that cannot answer within ctx are left out.
+func (c *Controller) sessionInfos(ctx context.Context, own Snapshot) []SessionInfo {
+	infos := []SessionInfo{sessionInfo(own)}
+	if c.pinned != nil {
+		infos[0].ID = c.pinned.ID
+		return infos
+	}
+	c.sessionsMu.Lock()
+	others := make([]*Controller, 0, len(c.sessions))
+	for _, open := range c.sessions {
+		others = append(others, open.controller)
+	}
+	c.sessionsMu.Unlock()
+	extra := make([]SessionInfo, 0, len(others))
+	for _, other := range others {
+		snapshot, err := other.snapshot(ctx)
+		if err != nil {
+			continue
+		}
+		info := sessionInfo(snapshot)
+		info.ID = other.pinned.ID
+		if info.DeviceName == "" {
+			info.DeviceID, info.DeviceName = other.pinned.ID, other.pinned.Name
+		}
+		extra = append(extra, info)
+	}
+	slices.SortFunc(extra, func(a, b SessionInfo) int {
+		return cmp.Or(cmp.Compare(a.DeviceName, b.DeviceName), cmp.Compare(a.ID, b.ID))
+	})
+	return append(infos, extra...)
+}
+
+func sessionInfo(snapshot Snapshot) SessionInfo {
+	info := SessionInfo{
+		DeviceID: sessionDevice(snapshot), Revision: snapshot.Revision, HasSession: snapshot.HasSession,
+		PlaybackState: snapshot.PlaybackState, ActiveMediaName: snapshot.ActiveMediaName,
+		Position: snapshot.Position, Duration: snapshot.Duration, Volume: snapshot.Volume, Muted: snapshot.Muted,
+	}
+	if index := slices.IndexFunc(snapshot.Devices, func(device playback.Device) bool { return device.ID == info.DeviceID }); index >= 0 {
+		info.DeviceName = snapshot.Devices[index].Name
+	}
+	return info
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+func newSessionsController(devices ...playback.Device) (*Controller, *eventLog, *atomic.Int32) {
+	log := &eventLog{}
+	var released atomic.Int32
+	c := New(Config{
+		Discovery:        newFakeDiscovery(devices...),
+		TransportFactory: &fakeFactory{log: log},
+		MediaServer:      &fakeServer{log: log},
+		OperationTimeout: time.Second,
+		NewSession: func(device playback.Device) (SessionAdapters, error) {
+			log.add("session:" + device.ID)
+			return SessionAdapters{TransportFactory: &fakeFactory{log: log}, MediaServer: &fakeServer{log: log}, Release: func() { released.Add(1) }}, nil
+		},
+	})
+	return c, log, &released
+}
+
+func TestSessionsPlayIndependently(t *testing.T) {
+	c, log, released := newSessionsController(
+		playback.Device{ID: "one", Name: "Living room", Protocol: "DLNA"},
+		playback.Device{ID: "two", Name: "Bedroom", Protocol: "DLNA"},
+	)
+	awaitDevices(t, c, 2)
+	ctx := context.Background()
+	if result := c.SelectDevice(ctx, Mutation{}, "one"); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.QueueAndPlay(ctx, Mutation{}, testMedia("a.mp3", mediamodel.MediaKindAudio)); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.QueueAndPlay(ctx, Mutation{Session: "two"}, testMedia("b.mp3", mediamodel.MediaKindAudio)); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.Pause(ctx, Mutation{Session: "two"}); !result.OK() {
+		t.Fatal(result)
+	}
+	// The device the default session drives resolves to the default session.
+	if result := c.SetVolume(ctx, Mutation{Session: "one"}, 30); !result.OK() {
+		t.Fatal(result)
+	}
+	events := log.snapshot()
+	for _, want := range []string{"server:start:one", "session:two", "server:start:two", "pause:two", "volume:set:one:30"} {
+		if !slices.Contains(events, want) {
+			t.Fatalf("missing %q in %v", want, events)
+		}
+	}
+	if slices.Contains(events, "pause:one") || slices.Contains(events, "session:one") {
+		t.Fatalf("default session disturbed: %v", events)
+	}
+
+	snapshot, err := c.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ActiveDeviceID != "one" || len(snapshot.Queue) != 1 || snapshot.Queue[0].Name != "a.mp3" {
+		t.Fatalf("default session = %+v", snapshot)
+	}
+	if len(snapshot.Sessions) != 2 || snapshot.Sessions[0].ID != "" || snapshot.Sessions[0].DeviceID != "one" ||
+		snapshot.Sessions[1].ID != "two" || snapshot.Sessions[1].DeviceName != "Bedroom" || snapshot.Sessions[1].PlaybackState != PlaybackStatePaused {
+		t.Fatalf("sessions = %+v", snapshot.Sessions)
+	}
+	bedroom, err := c.SessionSnapshot(ctx, "two")
+	if err != nil || bedroom.ActiveMediaName != "b.mp3" || bedroom.ActiveMediaID != "b.mp3" || len(bedroom.Queue) != 1 {
+		t.Fatalf("bedroom = %+v, %v", bedroom, err)
+	}
+
+	if result := c.SelectDevice(ctx, Mutation{Session: "two"}, "one"); result.Code != CodeInvalid {
+		t.Fatalf("moving a device session = %+v", result)
+	}
+	if result := c.SelectDevice(ctx, Mutation{}, "two"); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.Play(ctx, PlayRequest{}); result.Code != CodeBusy {
+		t.Fatalf("play on a busy device = %+v", result)
+	}
+	if result := c.Stop(ctx, Mutation{Session: "two"}); !result.OK() {
+		t.Fatal(result)
+	}
+	// Once the additional session is idle, the default session takes over.
+	if result := c.Play(ctx, PlayRequest{}); !result.OK() {
+		t.Fatal(result)
+	}
+	snapshot, _ = c.Snapshot(ctx)
+	if snapshot.ActiveDeviceID != "two" || len(snapshot.Sessions) != 1 {
+		t.Fatalf("after takeover = %q %+v", snapshot.ActiveDeviceID, snapshot.Sessions)
+	}
+	if _, err := c.SessionSnapshot(ctx, "one"); err != ErrNotFound {
+		t.Fatalf("snapshot of an undriven device error = %v", err)
+	}
+	if result := c.Pause(ctx, Mutation{Session: "two"}); !result.OK() {
+		t.Fatal(result)
+	}
+	c.Close()
+	if released.Load() != 1 {
+		t.Fatalf("released %d sessions", released.Load())
+	}
+}
+
+func TestSessionsRequireFactoryAndKnownDevice(t *testing.T) {
+	c, _, _ := newTestController(playback.Device{ID: "one", Protocol: "DLNA"}, playback.Device{ID: "two", Protocol: "DLNA"})
+	defer c.Close()
+	awaitDevices(t, c, 2)
+	ctx := context.Background()
+	if result := c.SelectDevice(ctx, Mutation{}, "one"); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.SetTranscode(ctx, Mutation{Session: "one"}, true); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.SetTranscode(ctx, Mutation{Session: "two"}, true); result.Code != CodeInvalid {
+		t.Fatalf("session without factory = %+v", result)
+	}
+	sessions, _, _ := newSessionsController(playback.Device{ID: "one", Protocol: "DLNA"})
+	defer sessions.Close()
+	awaitDevices(t, sessions, 1)
+	if result := sessions.AddQueueItem(ctx, QueueAddRequest{Mutation: Mutation{Session: "gone"}, Media: testMedia("a.mp3", mediamodel.MediaKindAudio)}); result.Code != CodeNotFound {
+		t.Fatalf("unknown device = %+v", result)
+	}
+	if result := sessions.CloseSession(ctx, Mutation{}); result.Code != CodeInvalid {
+		t.Fatalf("closing the default session = %+v", result)
+	}
+}
+
+func TestSubscribeCoversAdditionalSessions(t *testing.T) {
+	c, _, _ := newSessionsController(
+		playback.Device{ID: "one", Name: "Living room", Protocol: "DLNA"},
+		playback.Device{ID: "two", Name: "Bedroom", Protocol: "DLNA"},
+	)
+	defer c.Close()
+	awaitDevices(t, c, 2)
+	changes, unsubscribe := c.Subscribe(64)
+	defer unsubscribe()
+	ctx := context.Background()
+	if result := c.SelectDevice(ctx, Mutation{}, "one"); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.QueueAndPlay(ctx, Mutation{Session: "two"}, testMedia("b.mp3", mediamodel.MediaKindAudio)); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.CloseSession(ctx, Mutation{Session: "two"}); !result.OK() {
+		t.Fatal(result)
+	}
+	var selected, played, ended bool
+	var revision uint64
+	deadline := time.After(time.Second)
+	for !selected || !played || !ended {
+		select {
+		case change := <-changes:
+			switch change.Session {
+			case "":
+				selected = selected || change.Snapshot.SelectedDeviceID == "one"
+			case "two":
+				if change.Snapshot.Revision <= revision {
+					t.Fatalf("revision %d after %d", change.Snapshot.Revision, revision)
+				}
+				revision = change.Snapshot.Revision
+				played = played || change.Snapshot.HasSession && change.Snapshot.ActiveMediaName == "b.mp3"
+				ended = played && !change.Snapshot.HasSession && change.Snapshot.TerminalReason == playback.TerminalShutdown
+			default:
+				t.Fatalf("change from session %q", change.Session)
+			}
+		case <-deadline:
+			t.Fatalf("selected=%v played=%v ended=%v", selected, played, ended)
+		}
+	}
+}
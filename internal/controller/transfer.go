@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"slices"
+
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+// Transfer moves playback to deviceID: the item the active session plays
+// starts there at the position the monitor last reported, and the previous
+// renderer is stopped and released. deviceID becomes the selected device.
+// A paused item resumes playing on the new device. For an additional session
+// see transferSession.
+func (c *Controller) Transfer(ctx context.Context, mutation Mutation, deviceID string) Result {
+	if source, result := c.sessionTarget(ctx, &mutation); source != c {
+		if source == nil {
+			return result
+		}
+		return c.transferSession(ctx, mutation, source, deviceID)
+	}
+	if ctx == nil || c.pinned != nil {
+		return fail(mutation.RequestID, 0, ErrInvalidOperation)
+	}
+	var device playback.Device
+	found := false
+	revision, err := c.callActor(ctx, func(s *actorState) {
+		if index := slices.IndexFunc(s.devices, func(device playback.Device) bool { return device.ID == deviceID }); index >= 0 {
+			device, found = s.devices[index], true
+		}
+	})
+	if err != nil {
+		return fail(mutation.RequestID, 0, err)
+	}
+	if !found {
+		return fail(mutation.RequestID, revision, ErrNotFound)
+	}
+	result := c.play(ctx, PlayRequest{Mutation: mutation, target: &device, transfer: true, start: &startPoint{}})
+	if result.OK() && c.cfg.Logger != nil {
+		c.cfg.Logger.Info("Playback transferred to " + device.Name)
+	}
+	return result
+}
+
+// transferSession moves the item an additional session plays to deviceID. A
+// session stays bound to its device, so the item starts in the session that
+// drives deviceID, opened if needed, and source is closed once it plays there.
+// Only the playing item moves; the rest of the source queue is dropped with
+// the session.
+func (c *Controller) transferSession(ctx context.Context, mutation Mutation, source *Controller, deviceID string) Result {
+	var (
+		device  playback.Device
+		media   MediaRef
+		handoff error
+	)
+	start := &startPoint{}
+	revision, err := source.callActor(ctx, func(s *actorState) {
+		if result := s.check(mutation); !result.OK() {
+			handoff = ErrConflict
+			return
+		}
+		index := slices.IndexFunc(s.devices, func(device playback.Device) bool { return device.ID == deviceID })
+		switch {
+		case index < 0:
+			handoff = ErrNotFound
+		case s.active == nil:
+			handoff = ErrNoSession
+		case s.active.target.ID == deviceID:
+			handoff = ErrInvalidOperation
+		case s.devices[index].AudioOnly && s.active.media.Kind != mediamodel.MediaKindAudio:
+			handoff = ErrAudioOnly
+		default:
+			device, media = s.devices[index], cloneMediaRef(s.active.media)
+			if s.duration > 0 {
+				start.seconds, start.duration = min(s.position, s.duration), s.duration
+			}
+		}
+	})
+	if err == nil {
+		err = handoff
+	}
+	if err != nil {
+		return fail(mutation.RequestID, revision, err)
+	}
+	target, result := c.sessionTarget(ctx, &Mutation{RequestID: mutation.RequestID, Session: deviceID})
+	if target == nil {
+		return result
+	}
+	snapshot, err := target.Snapshot(ctx)
+	if err != nil {
+		return fail(mutation.RequestID, 0, err)
+	}
+	if snapshot.HasSession || snapshot.PlaybackState == PlaybackStateLoading {
+		return fail(mutation.RequestID, snapshot.Revision, ErrBusy)
+	}
+	result = target.play(ctx, PlayRequest{Mutation: Mutation{RequestID: mutation.RequestID}, target: &device, media: &media, queueMedia: true, start: start})
+	if !result.OK() {
+		return result
+	}
+	if closed := c.CloseSession(ctx, Mutation{Session: source.pinned.ID}); !closed.OK() && c.cfg.Logger != nil {
+		c.cfg.Logger.Warning("Previous session did not close cleanly")
+	}
+	if c.cfg.Logger != nil {
+		c.cfg.Logger.Info("Playback transferred to " + device.Name)
+	}
+	return result
+}
+
+// prepareHandoff points a transfer at the item of the active session and
+// records where that session is.
+func (s *actorState) prepareHandoff(request *PlayRequest) error {
+	if s.active == nil {
+		return ErrNoSession
+	}
+	if s.active.target.ID == request.target.ID {
+		return ErrInvalidOperation
+	}
+	if queueIndex(s.queue, s.active.itemID) >= 0 {
+		request.QueueItemID = s.active.itemID
+	} else {
+		media := cloneMediaRef(s.active.media)
+		request.media = &media
+	}
+	if s.duration > 0 {
+		request.start.seconds, request.start.duration = min(s.position, s.duration), s.duration
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+// playAt starts media on device and reports pos of dur seconds from its
+// monitor.
+func playAt(t *testing.T, c *Controller, device string, media MediaRef, pos, dur int) {
+	t.Helper()
+	ctx := context.Background()
+	if result := c.SelectDevice(ctx, Mutation{}, device); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := c.QueueAndPlay(ctx, Mutation{}, media); !result.OK() {
+		t.Fatal(result)
+	}
+	playing, _ := c.Snapshot(ctx)
+	c.HandleMonitorEvent(ctx, playback.MonitorEvent{Generation: playing.Generation, Position: pos, Duration: dur, State: PlaybackStatePlaying})
+	awaitSnapshotState(t, c, func(snapshot Snapshot) bool { return snapshot.Position == pos })
+}
+
+func TestTransferResumesOnAnotherDevice(t *testing.T) {
+	c, log, factory := newTestController(
+		playback.Device{ID: "living", Name: "Living room", Protocol: "DLNA"},
+		playback.Device{ID: "bedroom", Name: "Bedroom", Protocol: "DLNA"},
+		playback.Device{ID: "cast", Name: "Kitchen", Protocol: "Chromecast"},
+	)
+	defer c.Close()
+	awaitDevices(t, c, 3)
+	ctx := context.Background()
+	playAt(t, c, "living", testMedia("movie.mp4", mediamodel.MediaKindVideo), 65, 600)
+
+	if result := c.Transfer(ctx, Mutation{}, "bedroom"); !result.OK() {
+		t.Fatal(result)
+	}
+	events := log.snapshot()
+	want := []string{"stop:living", "server:stop", "close:living", "open:bedroom", "load:bedroom", "play:bedroom", "seek:00:01:05"}
+	at := 0
+	for _, event := range events {
+		if at < len(want) && event == want[at] {
+			at++
+		}
+	}
+	if at != len(want) {
+		t.Fatalf("events %v, want in order %v", events, want)
+	}
+	snapshot, _ := c.Snapshot(ctx)
+	if snapshot.SelectedDeviceID != "bedroom" || snapshot.ActiveDeviceID != "bedroom" || snapshot.Position != 65 || len(snapshot.Queue) != 1 || !snapshot.Queue[0].IsActive {
+		t.Fatalf("after transfer = %+v", snapshot)
+	}
+
+	// A Chromecast starts at the position itself.
+	c.HandleMonitorEvent(ctx, playback.MonitorEvent{Generation: snapshot.Generation, Position: 90, Duration: 600})
+	awaitSnapshotState(t, c, func(snapshot Snapshot) bool { return snapshot.Position == 90 })
+	if result := c.Transfer(ctx, Mutation{}, "cast"); !result.OK() {
+		t.Fatal(result)
+	}
+	factory.mu.Lock()
+	cast := factory.opened[len(factory.opened)-1]
+	factory.mu.Unlock()
+	if cast.id != "cast" || cast.load.Start != 90 || slices.Contains(log.snapshot(), "seek:00:01:30") {
+		t.Fatalf("cast load = %+v, events %v", cast.load, log.snapshot())
+	}
+
+	// An additional session hands its item to the session of the new device
+	// and closes.
+	sessions, sessionLog, released := newSessionsController(
+		playback.Device{ID: "one", Name: "Living room", Protocol: "DLNA"},
+		playback.Device{ID: "two", Name: "Bedroom", Protocol: "DLNA"},
+		playback.Device{ID: "three", Name: "Office", Protocol: "DLNA"},
+	)
+	defer sessions.Close()
+	awaitDevices(t, sessions, 3)
+	if result := sessions.SelectDevice(ctx, Mutation{}, "one"); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := sessions.QueueAndPlay(ctx, Mutation{Session: "two"}, testMedia("song.mp3", mediamodel.MediaKindAudio)); !result.OK() {
+		t.Fatal(result)
+	}
+	bedroom, _ := sessions.SessionSnapshot(ctx, "two")
+	sessions.lookupSession("two").HandleMonitorEvent(ctx, playback.MonitorEvent{Generation: bedroom.Generation, Position: 42, Duration: 200, State: PlaybackStatePlaying})
+	awaitSnapshotState(t, sessions.lookupSession("two"), func(snapshot Snapshot) bool { return snapshot.Position == 42 })
+	if result := sessions.Transfer(ctx, Mutation{Session: "two"}, "three"); !result.OK() {
+		t.Fatal(result)
+	}
+	office, err := sessions.SessionSnapshot(ctx, "three")
+	if err != nil || office.ActiveDeviceID != "three" || office.ActiveMediaName != "song.mp3" || office.Position != 42 {
+		t.Fatalf("office = %+v, %v", office, err)
+	}
+	if _, err := sessions.SessionSnapshot(ctx, "two"); err != ErrNotFound || released.Load() != 1 {
+		t.Fatalf("bedroom session error = %v, released %d", err, released.Load())
+	}
+	if events := sessionLog.snapshot(); !slices.Contains(events, "stop:two") || !slices.Contains(events, "seek:00:00:42") {
+		t.Fatalf("session events = %v", events)
+	}
+}
+
+func TestTransferRestartsTranscodedStreamAtOffset(t *testing.T) {
+	log := &eventLog{}
+	server := &fakeServer{log: log}
+	c := New(Config{
+		Discovery:        newFakeDiscovery(playback.Device{ID: "living", Protocol: "DLNA"}, playback.Device{ID: "bedroom", Protocol: "DLNA"}),
+		TransportFactory: &fakeFactory{log: log},
+		MediaServer:      server,
+		OperationTimeout: time.Second,
+	})
+	defer c.Close()
+	awaitDevices(t, c, 2)
+	ctx := context.Background()
+	c.SetTranscode(ctx, Mutation{}, true)
+	playAt(t, c, "living", testMedia("movie.mkv", mediamodel.MediaKindVideo), 300, 3600)
+
+	if result := c.Transfer(ctx, Mutation{}, "bedroom"); !result.OK() {
+		t.Fatal(result)
+	}
+	snapshot, _ := c.Snapshot(ctx)
+	server.mu.Lock()
+	offset := server.last.SeekOffset
+	server.mu.Unlock()
+	if offset != 300 || snapshot.Position != 300 {
+		t.Fatalf("restart offset = %d, position %d", offset, snapshot.Position)
+	}
+	events := log.snapshot()
+	if !slices.Contains(events, "callbacks:suppress:"+strconv.FormatUint(snapshot.Generation, 10)+":true") || slices.Contains(events, "seek:00:05:00") {
+		t.Fatalf("events = %v", events)
+	}
+}
+
+func TestTransferValidation(t *testing.T) {
+	c, _, _ := newTestController(playback.Device{ID: "one", Protocol: "DLNA"}, playback.Device{ID: "two", Protocol: "DLNA", AudioOnly: true})
+	defer c.Close()
+	awaitDevices(t, c, 2)
+	ctx := context.Background()
+	if result := c.Transfer(ctx, Mutation{}, "two"); result.Code != CodeNoSession {
+		t.Fatalf("nothing playing = %+v", result)
+	}
+	playAt(t, c, "one", testMedia("movie.mp4", mediamodel.MediaKindVideo), 10, 100)
+	for _, tc := range []struct {
+		device string
+		code   ErrorCode
+	}{
+		{device: "gone", code: CodeNotFound},
+		{device: "one", code: CodeInvalid},
+		{device: "two", code: CodeAudioOnly},
+	} {
+		if result := c.Transfer(ctx, Mutation{}, tc.device); result.Code != tc.code {
+			t.Fatalf("transfer to %s = %+v", tc.device, result)
+		}
+	}
+	if snapshot, _ := c.Snapshot(ctx); snapshot.ActiveDeviceID != "one" || snapshot.SelectedDeviceID != "one" {
+		t.Fatalf("failed transfers moved playback: %+v", snapshot)
+	}
+
+	sessions, _, _ := newSessionsController(
+		playback.Device{ID: "one", Protocol: "DLNA"},
+		playback.Device{ID: "two", Protocol: "DLNA"},
+		playback.Device{ID: "three", Protocol: "DLNA", AudioOnly: true},
+	)
+	defer sessions.Close()
+	awaitDevices(t, sessions, 3)
+	if result := sessions.SelectDevice(ctx, Mutation{}, "one"); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := sessions.SetTranscode(ctx, Mutation{Session: "two"}, true); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := sessions.Transfer(ctx, Mutation{Session: "two"}, "three"); result.Code != CodeNoSession {
+		t.Fatalf("idle session = %+v", result)
+	}
+	if result := sessions.QueueAndPlay(ctx, Mutation{Session: "two"}, testMedia("movie.mp4", mediamodel.MediaKindVideo)); !result.OK() {
+		t.Fatal(result)
+	}
+	if result := sessions.QueueAndPlay(ctx, Mutation{}, testMedia("song.mp3", mediamodel.MediaKindAudio)); !result.OK() {
+		t.Fatal(result)
+	}
+	for _, tc := range []struct {
+		device string
+		code   ErrorCode
+	}{
+		{device: "gone", code: CodeNotFound},
+		{device: "two", code: CodeInvalid},
+		{device: "three", code: CodeAudioOnly},
+		{device: "one", code: CodeBusy},
+	} {
+		if result := sessions.Transfer(ctx, Mutation{Session: "two"}, tc.device); result.Code != tc.code {
+			t.Fatalf("session transfer to %s = %+v", tc.device, result)
+		}
+	}
+	if bedroom, err := sessions.SessionSnapshot(ctx, "two"); err != nil || bedroom.ActiveDeviceID != "two" {
+		t.Fatalf("failed session transfers moved playback: %+v, %v", bedroom, err)
+	}
+}
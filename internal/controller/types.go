@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"mime"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"go2tv.app/go2tv/v2/internal/library"
 	"go2tv.app/go2tv/v2/internal/mediamodel"
 	"go2tv.app/go2tv/v2/internal/playback"
+	"go2tv.app/go2tv/v2/internal/resume"
 	"go2tv.app/go2tv/v2/metadata"
+	"go2tv.app/go2tv/v2/utils"
 )
 
 const (
@@ -25,6 +29,12 @@ const (
 	MaxImageTime = 300
 	// ArtworkCacheBytes is the default artwork cache byte limit.
 	ArtworkCacheBytes = 64 << 20
+	// MaxSleepDuration is the longest SleepAfterTime timer.
+	MaxSleepDuration = 24 * time.Hour
+	// MaxScheduleAhead is how far in the future a start may be scheduled.
+	MaxScheduleAhead = 7 * 24 * time.Hour
+	// ContinueWatchingLimit is how many entries Snapshot.ContinueWatching holds.
+	ContinueWatchingLimit = 12
 
 	// Playback state values are stable, machine-readable snapshot values. New
 	// adapter states may be added; callers must tolerate unknown values.
@@ -204,12 +214,17 @@ func errorForCode(code ErrorCode) error {
 
 // Policy controls automatic queue traversal. The zero value is valid and
 // disables all automatic behavior. A zero ImageDurationSeconds disables timed
-// image advance; DefaultPolicy supplies the recommended duration.
+// image advance; DefaultPolicy supplies the recommended duration. Autoplay
+// wraps from the last item to the first. Shuffle walks the queue in a random
+// order that is kept across queue edits and stops once every item has played,
+// unless RepeatAll starts the order over; RepeatAll requires Shuffle.
 type Policy struct {
 	LoopSelected         bool `json:"LoopSelected"`
 	AutoPlayNext         bool `json:"AutoPlayNext"`
 	AutoPlaySameType     bool `json:"AutoPlaySameType"`
 	GaplessEnabled       bool `json:"GaplessEnabled"`
+	RepeatAll            bool `json:"RepeatAll,omitempty"`
+	Shuffle              bool `json:"Shuffle,omitempty"`
 	ImageDurationSeconds int  `json:"ImageDurationSeconds"`
 }
 
@@ -223,9 +238,13 @@ func (p Policy) Validate() error {
 	if p.LoopSelected && p.AutoPlayNext {
 		return fmt.Errorf("loop and autoplay are mutually exclusive: %w", ErrInvalidPolicy)
 	}
-	if !p.AutoPlayNext && (p.AutoPlaySameType || p.GaplessEnabled) {
+	if !p.AutoPlayNext && (p.AutoPlaySameType || p.GaplessEnabled || p.RepeatAll || p.Shuffle) {
 		return fmt.Errorf("autoplay options require autoplay: %w", ErrInvalidPolicy)
 	}
+	if p.RepeatAll && !p.Shuffle {
+		// Sequential autoplay always wraps, so the flag would be ignored.
+		return fmt.Errorf("repeat all requires shuffle: %w", ErrInvalidPolicy)
+	}
 	return nil
 }
 
@@ -244,35 +263,116 @@ type QueueItem struct {
 // do not alias controller-owned storage. Revision changes whenever observable
 // state commits; Generation identifies a playback session attempt.
 type Snapshot struct {
-	Revision         uint64                  `json:"Revision"`
-	Devices          []playback.Device       `json:"Devices"`
-	SelectedDeviceID string                  `json:"SelectedDeviceID"`
-	ActiveDeviceID   string                  `json:"ActiveDeviceID"`
-	SelectedMedia    string                  `json:"SelectedMedia"`
-	SelectedSubtitle string                  `json:"SelectedSubtitle"`
-	ActiveMediaName  string                  `json:"ActiveMediaName"`
-	Queue            []QueueItem             `json:"Queue"`
-	Transcode        bool                    `json:"Transcode"`
-	Generation       uint64                  `json:"Generation"`
-	HasSession       bool                    `json:"HasSession"`
-	PlaybackState    string                  `json:"PlaybackState"`
-	Position         int                     `json:"Position"`
-	Duration         int                     `json:"Duration"`
-	Volume           int                     `json:"Volume"`
-	Muted            bool                    `json:"Muted"`
-	MediaType        mediamodel.MediaKind    `json:"MediaType"`
-	ArtworkID        string                  `json:"ArtworkID"`
-	Policy           Policy                  `json:"Policy"`
-	LastError        string                  `json:"LastError"`
-	TerminalReason   playback.TerminalReason `json:"TerminalReason"`
+	Revision         uint64            `json:"Revision"`
+	Devices          []playback.Device `json:"Devices"`
+	SelectedDeviceID string            `json:"SelectedDeviceID"`
+	ActiveDeviceID   string            `json:"ActiveDeviceID"`
+	SelectedMedia    string            `json:"SelectedMedia"`
+	SelectedSubtitle string            `json:"SelectedSubtitle"`
+	ActiveMediaName  string            `json:"ActiveMediaName"`
+	// ActiveMediaID is the MediaRef ID of the active media, the URL for
+	// media played from one.
+	ActiveMediaID string               `json:"ActiveMediaID"`
+	Queue         []QueueItem          `json:"Queue"`
+	Transcode     bool                 `json:"Transcode"`
+	Generation    uint64               `json:"Generation"`
+	HasSession    bool                 `json:"HasSession"`
+	PlaybackState string               `json:"PlaybackState"`
+	Position      int                  `json:"Position"`
+	Duration      int                  `json:"Duration"`
+	Volume        int                  `json:"Volume"`
+	Muted         bool                 `json:"Muted"`
+	MediaType     mediamodel.MediaKind `json:"MediaType"`
+	// Stream is how the active media reaches its renderer and why.
+	Stream         playback.StreamDecision `json:"Stream"`
+	ArtworkID      string                  `json:"ArtworkID"`
+	Policy         Policy                  `json:"Policy"`
+	LastError      string                  `json:"LastError"`
+	TerminalReason playback.TerminalReason `json:"TerminalReason"`
+	SleepTimer     SleepTimer              `json:"SleepTimer"`
+	ScheduledStart ScheduledStart          `json:"ScheduledStart"`
+	// Sessions lists the default session first, then one entry per
+	// additional session in device name order.
+	Sessions []SessionInfo `json:"Sessions"`
+	// Groups lists every group, including those with a member that is not
+	// discovered and therefore missing from Devices.
+	Groups []GroupInfo `json:"Groups"`
+	// ContinueWatching lists the most recently interrupted library media,
+	// newest first. It is empty without Config.Resume.
+	ContinueWatching []resume.Entry `json:"ContinueWatching"`
+}
+
+// GroupInfo describes a group. DeviceIDs starts with the leader.
+type GroupInfo struct {
+	ID        string        `json:"ID"`
+	Name      string        `json:"Name"`
+	DeviceIDs []string      `json:"DeviceIDs"`
+	Tolerance time.Duration `json:"Tolerance"`
+}
+
+// SessionInfo summarizes one playback session. The default session has a blank
+// ID; additional sessions are identified by the ID of the device they drive.
+// DeviceID is the device a session plays on, or would play on next.
+type SessionInfo struct {
+	ID              string `json:"ID"`
+	DeviceID        string `json:"DeviceID"`
+	DeviceName      string `json:"DeviceName"`
+	Revision        uint64 `json:"Revision"`
+	HasSession      bool   `json:"HasSession"`
+	PlaybackState   string `json:"PlaybackState"`
+	ActiveMediaName string `json:"ActiveMediaName"`
+	Position        int    `json:"Position"`
+	Duration        int    `json:"Duration"`
+	Volume          int    `json:"Volume"`
+	Muted           bool   `json:"Muted"`
+}
+
+// SleepMode selects when the sleep timer stops playback. New modes may be
+// added; callers must tolerate unknown values.
+type SleepMode string
+
+const (
+	// SleepOff means no sleep timer is armed.
+	SleepOff SleepMode = ""
+	// SleepAfterTime stops playback once the timer's duration has elapsed.
+	SleepAfterTime SleepMode = "time"
+	// SleepAfterItem stops instead of advancing when the playing item ends.
+	SleepAfterItem SleepMode = "after_item"
+	// SleepEndOfQueue lets autoplay run to the last item without wrapping.
+	// With LoopSelected it acts like SleepAfterItem.
+	SleepEndOfQueue SleepMode = "end_of_queue"
+)
+
+// SleepTimer is the armed sleep timer. Deadline is set only for
+// SleepAfterTime. The timer disarms once it stops playback; item-based modes
+// also disarm when playback stops for any other reason.
+type SleepTimer struct {
+	Mode     SleepMode `json:"Mode"`
+	Deadline time.Time `json:"Deadline,omitzero"`
+}
+
+// ScheduledStart is a pending one-shot start of playback on a device. The
+// zero value means nothing is scheduled.
+type ScheduledStart struct {
+	At         time.Time `json:"At,omitzero"`
+	DeviceID   string    `json:"DeviceID,omitempty"`
+	DeviceName string    `json:"DeviceName,omitempty"`
 }
 
 // Mutation carries optional request correlation and optimistic concurrency.
 // ExpectedRevision nil means no precondition; a non-nil stale value fails with
 // CodeConflict without applying the mutation.
+//
+// Session names the session the command applies to: blank for the default
+// session, otherwise a device ID. A device the default session drives resolves
+// to the default session; any other discovered device gets its own session,
+// opened on first use. ExpectedRevision is then compared with that session's
+// revision. Refresh, SavedState, RestoreState, CreateGroup, and RemoveGroup
+// always apply to the default session.
 type Mutation struct {
 	RequestID        string  `json:"RequestID"`
 	ExpectedRevision *uint64 `json:"ExpectedRevision"`
+	Session          string  `json:"Session,omitempty"`
 }
 
 // PlayRequest starts the selected media or QueueItemID. A blank QueueItemID
@@ -280,10 +380,15 @@ type Mutation struct {
 type PlayRequest struct {
 	Mutation
 	QueueItemID string `json:"QueueItemID"`
-	ctx         context.Context
-	target      *playback.Device
-	media       *MediaRef
-	queueMedia  bool
+	// Resume starts the item at its saved position in Config.Resume instead
+	// of at the beginning.
+	Resume     bool `json:"Resume"`
+	ctx        context.Context
+	target     *playback.Device
+	media      *MediaRef
+	queueMedia bool
+	transfer   bool
+	start      *startPoint
 }
 
 // MediaArtworkLoader lazily resolves normalized artwork for one media item.
@@ -292,6 +397,10 @@ type MediaArtworkLoader func(context.Context) (*metadata.ArtworkAsset, error)
 // MediaRef is an in-process media capability, not a wire DTO. Each opener must
 // return a fresh handle; the consumer closes it. LoadArtwork is attempted only
 // when the controller prepares a renderer load.
+//
+// Media with a URL are loaded by the renderer straight from that http(s)
+// address. They need no openers and are never transcoded or paired with
+// subtitles. Title, when set, is shown in the queue instead of Name.
 type MediaRef struct {
 	RootID        string
 	ID            string
@@ -303,6 +412,11 @@ type MediaRef struct {
 	OpenDirect    playback.SourceOpener
 	OpenTranscode playback.SourceOpener
 	LoadArtwork   MediaArtworkLoader
+	URL           string
+	Title         string
+	// Location is the restart-stable library location of the file. Playback
+	// positions are remembered under it; media without one are not tracked.
+	Location library.Location
 
 	artwork          *metadata.ArtworkAsset
 	artworkAttempted bool
@@ -319,7 +433,9 @@ func (r MediaRef) Validate() error {
 		return fmt.Errorf("name: %w", ErrInvalidMedia)
 	case r.Kind == mediamodel.MediaKindUnknown:
 		return fmt.Errorf("kind: %w", ErrInvalidMedia)
-	case r.OpenDirect == nil:
+	case r.URL != "" && !remoteURL(r.URL):
+		return fmt.Errorf("URL: %w", ErrInvalidMedia)
+	case r.OpenDirect == nil && r.URL == "":
 		return fmt.Errorf("direct opener: %w", ErrInvalidMedia)
 	}
 	if value := strings.TrimSpace(r.MIMEType); value != "" {
@@ -334,6 +450,18 @@ func (r MediaRef) valid() bool { return r.Validate() == nil }
 
 func (r MediaRef) extension() string { return filepath.Ext(r.Name) }
 
+func (r MediaRef) label() string {
+	if title := strings.TrimSpace(r.Title); title != "" {
+		return title
+	}
+	return r.Name
+}
+
+func remoteURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 // SubtitleRef is an in-process subtitle capability. The zero value clears the
 // current subtitle selection. Open must return a fresh caller-owned handle.
 type SubtitleRef struct {
@@ -404,6 +532,54 @@ type QueueAddManyResult struct {
 	Dropped    int
 }
 
+// SavedState is the part of Controller state worth keeping across restarts.
+// Queue media keep their openers; callers map them to and from references that
+// outlive the process. Selected indexes Queue, with -1 meaning no selection.
+// Device is matched by protocol and endpoint because device IDs are opaque
+// per process.
+type SavedState struct {
+	Queue    []MediaRef
+	Selected int
+	Subtitle SubtitleRef
+	Policy   Policy
+	Device   playback.Device
+}
+
+// SleepTimerRequest arms the sleep timer, replacing any armed one, or
+// disarms it with SleepOff. Duration applies only to SleepAfterTime and must
+// be positive and at most MaxSleepDuration.
+type SleepTimerRequest struct {
+	Mutation
+	Mode     SleepMode
+	Duration time.Duration
+}
+
+// ScheduleStartRequest replaces the scheduled start. At must lie in the future
+// and at most MaxScheduleAhead away; the zero value cancels. A blank DeviceID
+// uses the selected device. The device is remembered by protocol and endpoint,
+// so a renderer that was rediscovered meanwhile still matches.
+type ScheduleStartRequest struct {
+	Mutation
+	At       time.Time
+	DeviceID string
+}
+
+// GroupRequest creates a group from at least two discovered devices, the
+// first of which leads. A blank Name joins the device names; a zero Tolerance
+// uses playback.DefaultGroupTolerance.
+type GroupRequest struct {
+	Mutation
+	Name      string
+	DeviceIDs []string
+	Tolerance time.Duration
+}
+
+// GroupResult carries the device ID of the new group when Result is OK.
+type GroupResult struct {
+	Result
+	GroupID string
+}
+
 // SeekRequest seeks to an absolute non-negative position in seconds.
 type SeekRequest struct {
 	Mutation
@@ -431,6 +607,16 @@ type Discovery interface {
 	Subscribe(int) (<-chan []playback.Device, func())
 }
 
+// SessionAdapters are the adapters of one additional session. A MediaServer
+// serves one renderer at a time, so every session needs its own, along with a
+// TransportFactory bound to it. Release, when set, runs once the session has
+// shut down.
+type SessionAdapters struct {
+	TransportFactory TransportFactory
+	MediaServer      playback.MediaServer
+	Release          func()
+}
+
 // EventLogger receives human-readable lifecycle events. Messages are
 // observational, not a machine-readable compatibility contract. Implementations
 // must be concurrency-safe, non-blocking, and must not call back into Controller.
@@ -459,12 +645,26 @@ type Config struct {
 	RunMonitor func(context.Context, playback.MonitorConfig, playback.Device, Transport)
 	// DurationProbe is advisory; probe errors do not fail playback.
 	DurationProbe func(context.Context, playback.SourceOpener) (float64, error)
+	// CodecProbe reads the codecs of local media. With it, video for a DLNA
+	// renderer that reported its sink formats is served as is, remuxed, or
+	// transcoded as those formats require, unless SetTranscode asked for
+	// transcoding. Without it, or when probing fails, SetTranscode decides.
+	CodecProbe func(context.Context, playback.SourceOpener) (*utils.MediaCodecInfo, error)
+	// Remux reports that MediaServer serves ServerRequest.Remux.
+	Remux bool
 	// Clock is optional and intended for deterministic monitor/timer adapters.
 	Clock playback.Clock
 	// OperationTimeout bounds adapter I/O. Non-positive values default to 30s.
 	OperationTimeout time.Duration
 	// Logger is optional and remains caller-owned.
 	Logger EventLogger
+	// Resume is optional. When set, playback positions of library video and
+	// audio are saved there and PlayRequest.Resume starts from them.
+	Resume *resume.Store
+	// NewSession supplies the adapters of an additional session on device.
+	// Additional sessions share every other adapter with the default one.
+	// Nil limits the Controller to its default session.
+	NewSession func(device playback.Device) (SessionAdapters, error)
 }
 
 // Validate checks configuration combinations without applying defaults. New is
@@ -190,7 +190,7 @@ func muteAction(screen *FyneScreen) {
 			// If tvdata is nil, we just need to set RenderingControlURL if we want
 			// to control the sound. We should still rely on the play action to properly
 			// populate our tvdata type.
-			screen.tvdata = &soapcalls.TVPayload{RenderingControlURL: screen.renderingControlURL}
+			screen.tvdata = &soapcalls.TVPayload{RenderingControlURL: screen.renderingControlURL, Renderer: screen.rendererInfo, Quirks: screen.quirks}
 		}
 
 		if err := screen.tvdata.SetMuteSoapCall("1"); err != nil {
@@ -241,7 +241,7 @@ func unmuteAction(screen *FyneScreen) {
 			// If tvdata is nil, we just need to set RenderingControlURL if we want
 			// to control the sound. We should still rely on the play action to properly
 			// populate our tvdata type.
-			screen.tvdata = &soapcalls.TVPayload{RenderingControlURL: screen.renderingControlURL}
+			screen.tvdata = &soapcalls.TVPayload{RenderingControlURL: screen.renderingControlURL, Renderer: screen.rendererInfo, Quirks: screen.quirks}
 		}
 
 		// isMuted, _ := screen.tvdata.GetMuteSoapCall()
@@ -557,6 +557,7 @@ type playbackTarget struct {
 	eventURL             string
 	renderingControlURL  string
 	connectionManagerURL string
+	renderer             soapcalls.RendererInfo
 }
 
 func selectedPlaybackTarget(screen *FyneScreen) playbackTarget {
@@ -566,6 +567,7 @@ func selectedPlaybackTarget(screen *FyneScreen) playbackTarget {
 		eventURL:             screen.eventURL,
 		renderingControlURL:  screen.renderingControlURL,
 		connectionManagerURL: screen.connectionManagerURL,
+		renderer:             screen.rendererInfo,
 	}
 }
 
@@ -581,6 +583,7 @@ func activeSessionPlaybackTarget(screen *FyneScreen) (playbackTarget, bool) {
 		target.eventURL = screen.tvdata.EventURL
 		target.renderingControlURL = screen.tvdata.RenderingControlURL
 		target.connectionManagerURL = screen.tvdata.ConnectionManagerURL
+		target.renderer = screen.tvdata.Renderer
 	}
 
 	return target, true
@@ -797,7 +800,7 @@ func playActionOnTarget(screen *FyneScreen, target playbackTarget) {
 					isSeek = true
 				}
 
-				storedResume := screen.prepareResumeSession(mediaType)
+				storedResume := screen.playbackStart(mediaType)
 				screen.ffmpegSeek, directResumeSeek = computeResumeStart(existingSeek, storedResume, transcodeEnabled)
 			}
 		}
@@ -907,6 +910,8 @@ func playActionOnTarget(screen *FyneScreen, target playbackTarget) {
 				Seekable:                    false,
 				LogOutput:                   screen.Debug,
 				FFmpegPath:                  screen.ffmpegPath,
+				Renderer:                    target.renderer,
+				Quirks:                      screen.quirks,
 			}
 		} else {
 			screen.tvdata = &soapcalls.TVPayload{
@@ -929,6 +934,8 @@ func playActionOnTarget(screen *FyneScreen, target playbackTarget) {
 				FFmpegPath:                  screen.ffmpegPath,
 				FFmpegSeek:                  screen.ffmpegSeek,
 				FFmpegSubsPath:              screen.subsfile,
+				Renderer:                    target.renderer,
+				Quirks:                      screen.quirks,
 			}
 		}
 		showDLNATranscodeTimeline(screen, screen.tvdata)
@@ -989,7 +996,11 @@ func playActionOnTarget(screen *FyneScreen, target playbackTarget) {
 		if screen.NextMediaCheck.Checked && gaplessOption == "Enabled" {
 			newTVPayload, err := queueNext(screen, false)
 			if err != nil {
-				check(screen, err)
+				// Nothing to stage at the end of the playlist or while a
+				// sleep timer holds playback to the current item.
+				if !isTraversalBoundaryError(err) {
+					check(screen, err)
+				}
 				return
 			}
 
@@ -1454,7 +1465,7 @@ func chromecastPlayAction(screen *FyneScreen, actionID uint64, sessionDevice dev
 			transcode = false
 		}
 
-		storedResume := screen.prepareResumeSession(mediaType)
+		storedResume := screen.playbackStart(mediaType)
 		ffmpegSeek = computeChromecastResumeStart(ffmpegSeek, storedResume)
 		screen.ffmpegSeek = 0
 		if transcode {
@@ -2607,7 +2618,7 @@ func volumeAction(screen *FyneScreen, up bool) {
 			// If tvdata is nil, we just need to set RenderingControlURL if we want
 			// to control the sound. We should still rely on the play action to properly
 			// populate our tvdata type.
-			screen.tvdata = &soapcalls.TVPayload{RenderingControlURL: screen.renderingControlURL}
+			screen.tvdata = &soapcalls.TVPayload{RenderingControlURL: screen.renderingControlURL, Renderer: screen.rendererInfo, Quirks: screen.quirks}
 		}
 
 		currentVolume, err := screen.tvdata.GetVolumeSoapCall()
@@ -2710,6 +2721,8 @@ func queueNext(screen *FyneScreen, clear bool) (*soapcalls.TVPayload, error) {
 		FFmpegPath:                  screen.ffmpegPath,
 		FFmpegSubsPath:              spath,
 		Metadata:                    guiMediaMetadata("", oldMediaURL.Host, artworkAsset),
+		Renderer:                    screen.tvdata.Renderer,
+		Quirks:                      screen.tvdata.Quirks,
 	}
 
 	//screen.httpNexterver.StartServer(serverStarted, mediaFile, spath, nextTvData, screen)
@@ -30,6 +30,7 @@ import (
 	"go2tv.app/go2tv/v2/httphandlers"
 	"go2tv.app/go2tv/v2/internal/crashlog"
 	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/servermode"
 	"go2tv.app/go2tv/v2/metadata"
 	"go2tv.app/go2tv/v2/rtmp"
 	"go2tv.app/go2tv/v2/soapcalls"
@@ -54,6 +55,7 @@ type FyneScreen struct {
 	SkipPreviousButton       *widget.Button
 	SkipNextButton           *widget.Button
 	tvdata                   *soapcalls.TVPayload
+	quirks                   *soapcalls.QuirkDB
 	tabs                     *container.AppTabs
 	CheckVersion             *widget.Button
 	SubsText                 *widget.Entry
@@ -68,6 +70,8 @@ type FyneScreen struct {
 	MediaText                *widget.Entry
 	ExternalMediaURL         *widget.Check
 	SkinNextOnlySameTypes    bool
+	RepeatAll                bool
+	Shuffle                  bool
 	GaplessMediaWatcher      func(context.Context, *FyneScreen, *soapcalls.TVPayload)
 	SlideBar                 *tappedSlider
 	MuteUnmute               *widget.Button
@@ -86,6 +90,7 @@ type FyneScreen struct {
 	controlURL               string
 	renderingControlURL      string
 	connectionManagerURL     string
+	rendererInfo             soapcalls.RendererInfo
 	currentmfolder           string
 	ffmpegPath               string
 	ffmpegSeek               int
@@ -144,6 +149,7 @@ type FyneScreen struct {
 	queueClearButton         *widget.Button
 	queueSelectedIndex       int
 	queueRevision            uint64
+	shuffleOrder             []string
 	lastQueueUIState         queueUIState
 	queueUIStateValid        bool
 	lastQueueTapIndex        int
@@ -152,6 +158,7 @@ type FyneScreen struct {
 	ActiveDeviceLabel        *widget.Label
 	ActiveDeviceIcon         *widget.Icon
 	ActiveDeviceCard         *widget.Card
+	TransferButton           *widget.Button
 	rtmpServer               *rtmp.Server
 	rtmpServerCheck          *widget.Check
 	transcodeToolTipCheck    *ttwidget.Check
@@ -167,8 +174,17 @@ type FyneScreen struct {
 	rtmpPrevMediaFile        string
 	imageAutoSkipMediaPath   string
 	imageAutoSkipCancel      context.CancelFunc
+	sleep                    sleepChoice
+	sleepDeadline            time.Time
+	sleepTimer               *time.Timer
+	sleepSelect              *widget.Select
+	scheduleAt               time.Time
+	scheduleDevice           devType
+	scheduleTimer            *time.Timer
+	timersStatus             *widget.Label
 	rtmpMu                   sync.Mutex
 	resumeSession            resumePlaybackSession
+	handoff                  handoffPosition
 	Crash                    *crashlog.Session
 	PendingCrashPath         string
 	renderGate               rendererControlGate
@@ -437,6 +453,11 @@ func (p *FyneScreen) Fini() {
 		// Otherwise playAction may interpret the follow-up as pause/resume.
 		p.updateScreenState("Stopped")
 
+		if p.sleepAfterFinish() {
+			startAfreshPlayButton(p)
+			return
+		}
+
 		// For Chromecast, ignore gapless setting (it's DLNA-specific)
 		isChromecast := target.device.deviceType == devices.DeviceTypeChromecast
 
@@ -517,7 +538,12 @@ func getAdjacentQueuedMedia(screen *FyneScreen, delta int, wrap bool) (string, s
 	}
 	queue.SetCurrentIndex(currentIndex)
 
-	nextIndex := queue.AdjacentIndex(delta, screen.SkinNextOnlySameTypes, wrap)
+	var nextIndex int
+	if order := screen.shuffledQueueOrder(queue); order != nil {
+		nextIndex = queue.AdjacentIndexInOrder(order, delta, screen.SkinNextOnlySameTypes, wrap)
+	} else {
+		nextIndex = queue.AdjacentIndex(delta, screen.SkinNextOnlySameTypes, wrap)
+	}
 	if nextIndex == -1 {
 		if delta < 0 {
 			return "", "", errNoPreviousQueueMedia
@@ -536,7 +562,15 @@ func isTraversalBoundaryError(err error) bool {
 }
 
 func getNextAutoPlayMediaOrError(screen *FyneScreen) (string, string, error) {
-	return getAdjacentQueuedMedia(screen, 1, true)
+	switch screen.sleepTimerMode() {
+	case sleepAfterItem:
+		return "", "", errNoNextQueueMedia
+	case sleepEndOfQueue:
+		return getAdjacentQueuedMedia(screen, 1, false)
+	}
+	// Autoplay wraps around the playlist; only a shuffled pass ends after
+	// every item played once, unless Repeat all is on.
+	return getAdjacentQueuedMedia(screen, 1, !screen.Shuffle || screen.RepeatAll)
 }
 
 func autoSelectNextSubs(v string, screen *FyneScreen) {
@@ -895,9 +929,27 @@ func NewFyneScreen(version string, crash *crashlog.Session) *FyneScreen {
 		PendingCrashPath:   crashPath(crash),
 		queueSelectedIndex: -1,
 		lastQueueTapIndex:  -1,
+		RepeatAll:          go2tv.Preferences().BoolWithFallback("RepeatAll", false),
+		Shuffle:            go2tv.Preferences().BoolWithFallback("Shuffle", false),
 		remoteSession:      newRemoteSessionManager(),
 		shutdownDone:       make(chan struct{}),
+		quirks:             openQuirks(),
+	}
+}
+
+// openQuirks loads the DLNA renderer workarounds from the files server mode
+// uses, so both share what either learns. Files that cannot be read leave
+// the built-in workarounds.
+func openQuirks() *soapcalls.QuirkDB {
+	path, err := servermode.DefaultQuirksPath()
+	if err != nil {
+		return nil
+	}
+	quirks, err := soapcalls.OpenQuirks(path, servermode.LearnedQuirksPath(path))
+	if err != nil {
+		return nil
 	}
+	return quirks
 }
 
 func crashPath(crash *crashlog.Session) string {
@@ -184,6 +184,7 @@ func mainWindow(s *FyneScreen) fyne.CanvasObject {
 	// playpause.Alignment = widget.ButtonAlignCenter
 
 	stop := widget.NewButtonWithIcon(lang.L("Stop"), theme.MediaStopIcon(), func() {
+		s.disarmItemSleep()
 		stopAction(s)
 	})
 	stop.Importance = widget.LowImportance
@@ -393,8 +394,11 @@ func mainWindow(s *FyneScreen) fyne.CanvasObject {
 	s.ActiveDeviceLabel = widget.NewLabel("")
 	s.ActiveDeviceLabel.Wrapping = fyne.TextWrapWord
 	s.ActiveDeviceIcon = widget.NewIcon(theme.MediaPlayIcon())
+	s.TransferButton = widget.NewButton(lang.L("Transfer"), func() {
+		go transferPlayback(s)
+	})
 	s.ActiveDeviceCard = widget.NewCard(lang.L("Active Device"), "",
-		container.NewBorder(nil, nil, s.ActiveDeviceIcon, nil, s.ActiveDeviceLabel))
+		container.NewBorder(nil, nil, s.ActiveDeviceIcon, s.TransferButton, s.ActiveDeviceLabel))
 	s.ActiveDeviceCard.Hide()
 
 	deviceBottom := container.NewVBox(s.ActiveDeviceCard, s.rtmpURLCard)
@@ -419,6 +423,7 @@ func mainWindow(s *FyneScreen) fyne.CanvasObject {
 			s.eventURL = ""
 			s.renderingControlURL = ""
 			s.connectionManagerURL = ""
+			s.rendererInfo = soapcalls.RendererInfo{}
 			s.tvdata = nil
 
 			if s.chromecastClient != nil && s.chromecastClient.IsConnected() {
@@ -446,8 +451,10 @@ func mainWindow(s *FyneScreen) fyne.CanvasObject {
 				s.eventURL = t.AvtransportEventSubURL
 				s.renderingControlURL = t.RenderingControlURL
 				s.connectionManagerURL = t.ConnectionManagerURL
+				s.rendererInfo = t.Renderer()
 				if s.tvdata != nil && !isActivePlayback {
 					s.tvdata.RenderingControlURL = s.renderingControlURL
+					s.tvdata.Renderer, s.tvdata.Quirks = s.rendererInfo, s.quirks
 				}
 			}
 		}
@@ -782,7 +789,7 @@ func checkMutefunc(s *FyneScreen) {
 		}
 
 		if s.tvdata == nil {
-			s.tvdata = &soapcalls.TVPayload{RenderingControlURL: s.renderingControlURL}
+			s.tvdata = &soapcalls.TVPayload{RenderingControlURL: s.renderingControlURL, Renderer: s.rendererInfo, Quirks: s.quirks}
 		}
 
 		isMuted, err := s.tvdata.GetMuteSoapCall()
@@ -0,0 +1,148 @@
+//go:build !(android || ios)
+
+package gui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexballas/refyne/v2"
+	"github.com/alexballas/refyne/v2/dialog"
+	"github.com/alexballas/refyne/v2/lang"
+	"github.com/alexballas/refyne/v2/storage"
+	xfilepicker "github.com/alexballas/xfilepicker/dialog"
+	"go2tv.app/go2tv/v2/internal/playlist"
+)
+
+var playlistExtensions = []string{".m3u8", ".m3u", ".pls", ".xspf"}
+
+// playlistMediaPaths reads a playlist file and returns the local media it
+// lists, with relative entries resolved against the playlist's directory.
+// Remote URLs and missing files are counted as skipped: the desktop queue
+// only holds local files.
+func playlistMediaPaths(playlistPath string, data []byte) ([]string, int, error) {
+	format, err := playlist.FormatForName(playlistPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := playlist.Parse(format, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	dir := filepath.Dir(playlistPath)
+	paths := make([]string, 0, len(entries))
+	skipped := 0
+	for _, entry := range entries {
+		if playlist.IsRemote(entry.Location) {
+			skipped++
+			continue
+		}
+		location := filepath.FromSlash(strings.ReplaceAll(entry.Location, `\`, "/"))
+		if !filepath.IsAbs(location) {
+			location = filepath.Join(dir, location)
+		}
+		if info, err := os.Stat(location); err != nil || !info.Mode().IsRegular() {
+			skipped++
+			continue
+		}
+		paths = append(paths, location)
+	}
+	return paths, skipped, nil
+}
+
+// writeQueuePlaylist writes the queue in the format named by the playlist's
+// extension. Items under the playlist's directory are written relative to it,
+// so the playlist and media can be moved together.
+func writeQueuePlaylist(w io.Writer, playlistPath string, items []QueueItem) error {
+	format, err := playlist.FormatForName(playlistPath)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(playlistPath)
+	entries := make([]playlist.Entry, 0, len(items))
+	for _, item := range items {
+		location := item.Path()
+		if rel, err := filepath.Rel(dir, location); err == nil && filepath.IsLocal(rel) {
+			location = filepath.ToSlash(rel)
+		}
+		entries = append(entries, playlist.Entry{Location: location})
+	}
+	return playlist.Write(w, format, entries)
+}
+
+func (screen *FyneScreen) importPlaylistAction(parent fyne.Window) {
+	var resumeHotkeys func()
+	fd := xfilepicker.NewFileOpen(func(readers []fyne.URIReadCloser, err error) {
+		if resumeHotkeys != nil {
+			defer resumeHotkeys()
+		}
+		if err != nil || len(readers) == 0 {
+			checkInWindow(screen, err, parent)
+			return
+		}
+		defer readers[0].Close()
+		data, err := io.ReadAll(readers[0])
+		if err != nil {
+			checkInWindow(screen, err, parent)
+			return
+		}
+		paths, skipped, err := playlistMediaPaths(readers[0].URI().Path(), data)
+		if err == nil {
+			err = appendMediaPaths(screen, paths)
+		}
+		if err != nil {
+			checkInWindow(screen, err, parent)
+			return
+		}
+		if skipped > 0 {
+			dialog.ShowInformation(lang.L("Import playlist"), fmt.Sprintf(lang.L("Skipped %d entries that are not local media files"), skipped), parent)
+		}
+	}, parent, false)
+	if f, ok := fd.(xfilepicker.FilePicker); ok {
+		f.SetFilter(storage.NewExtensionFileFilter(playlistExtensions))
+	}
+	resumeHotkeys = suspendHotkeys(screen)
+	fd.Show()
+	fd.Resize(fyne.NewSize(filePickerFillSize, filePickerFillSize))
+}
+
+func (screen *FyneScreen) exportPlaylistAction(parent fyne.Window) {
+	queue, _ := screen.queueSnapshot()
+	if queue == nil || queue.Len() == 0 {
+		return
+	}
+	items := queue.Items()
+	var resumeHotkeys func()
+	fd := xfilepicker.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if resumeHotkeys != nil {
+			defer resumeHotkeys()
+		}
+		if err != nil || writer == nil {
+			checkInWindow(screen, err, parent)
+			return
+		}
+		defer writer.Close()
+		var buf bytes.Buffer
+		if err := writeQueuePlaylist(&buf, writer.URI().Path(), items); err != nil {
+			checkInWindow(screen, err, parent)
+			return
+		}
+		_, err = writer.Write(buf.Bytes())
+		checkInWindow(screen, err, parent)
+	}, parent)
+	if f, ok := fd.(interface{ SetFileName(string) }); ok {
+		f.SetFileName("playlist.m3u8")
+	}
+	if f, ok := fd.(xfilepicker.FilePicker); ok && screen.currentmfolder != "" {
+		if lister, err := storage.ListerForURI(storage.NewFileURI(screen.currentmfolder)); err == nil {
+			f.SetLocation(lister)
+		}
+	}
+	resumeHotkeys = suspendHotkeys(screen)
+	fd.Show()
+	fd.Resize(fyne.NewSize(filePickerFillSize, filePickerFillSize))
+}
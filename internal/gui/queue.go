@@ -433,6 +433,34 @@ func (screen *FyneScreen) refreshTraversalControls() {
 	})
 }
 
+// shuffledQueueOrder returns the play order used while shuffle is on, or nil
+// when it is off. The order is reconciled with queue, so edits keep it.
+func (screen *FyneScreen) shuffledQueueOrder(queue *SessionQueue) []string {
+	screen.mu.Lock()
+	defer screen.mu.Unlock()
+	if !screen.Shuffle {
+		screen.shuffleOrder = nil
+		return nil
+	}
+	screen.shuffleOrder = queue.ShuffleOrder(screen.shuffleOrder)
+	return screen.shuffleOrder
+}
+
+func (screen *FyneScreen) setRepeatAll(enabled bool) {
+	fyne.CurrentApp().Preferences().SetBool("RepeatAll", enabled)
+	screen.RepeatAll = enabled
+	screen.refreshTraversalControls()
+}
+
+func (screen *FyneScreen) setShuffle(enabled bool) {
+	fyne.CurrentApp().Preferences().SetBool("Shuffle", enabled)
+	screen.mu.Lock()
+	screen.Shuffle = enabled
+	screen.shuffleOrder = nil
+	screen.mu.Unlock()
+	screen.refreshTraversalControls()
+}
+
 func (screen *FyneScreen) openQueueWindow() {
 	if screen.renderGate.remoteLeaseHeld() {
 		return
@@ -514,6 +542,30 @@ func (screen *FyneScreen) buildQueueWindow() {
 	moveDown := widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() {
 		screen.moveSelectedQueueItem(1)
 	})
+	repeatAll := widget.NewCheck(lang.L("Repeat all"), nil)
+	repeatAll.SetChecked(screen.RepeatAll)
+	repeatAll.OnChanged = screen.setRepeatAll
+	shuffle := widget.NewCheck(lang.L("Shuffle"), nil)
+	shuffle.SetChecked(screen.Shuffle)
+	// Repeat all only starts a shuffled order over; plain autoplay always
+	// wraps, so the option is offered only while shuffle is on.
+	if !screen.Shuffle {
+		repeatAll.Disable()
+	}
+	shuffle.OnChanged = func(enabled bool) {
+		screen.setShuffle(enabled)
+		if enabled {
+			repeatAll.Enable()
+		} else {
+			repeatAll.Disable()
+		}
+	}
+	importPlaylist := widget.NewButtonWithIcon(lang.L("Import"), theme.FolderOpenIcon(), func() {
+		screen.importPlaylistAction(win)
+	})
+	exportPlaylist := widget.NewButtonWithIcon(lang.L("Export"), theme.DocumentSaveIcon(), func() {
+		screen.exportPlaylistAction(win)
+	})
 	clearQueue := widget.NewButton(lang.L("Clear playlist"), func() {
 		screen.clearSessionQueueAction()
 	})
@@ -527,14 +579,18 @@ func (screen *FyneScreen) buildQueueWindow() {
 		remove,
 		moveUp,
 		moveDown,
+		repeatAll,
+		shuffle,
 		layout.NewSpacer(),
+		importPlaylist,
+		exportPlaylist,
 		clearQueue,
 		closeButton,
 	)
 
 	win.SetContent(container.NewBorder(
 		container.NewVBox(header),
-		container.NewVBox(widget.NewSeparator(), details, buttons),
+		container.NewVBox(widget.NewSeparator(), details, screen.buildTimerControls(win), buttons),
 		nil,
 		nil,
 		list,
@@ -551,6 +607,8 @@ func (screen *FyneScreen) buildQueueWindow() {
 		screen.queueMoveUpButton = nil
 		screen.queueMoveDownButton = nil
 		screen.queueClearButton = nil
+		screen.sleepSelect = nil
+		screen.timersStatus = nil
 	})
 	win.Canvas().SetOnTypedKey(func(key *fyne.KeyEvent) {
 		switch key.Name {
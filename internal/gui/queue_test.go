@@ -5,6 +5,7 @@ package gui
 import (
 	"errors"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/alexballas/refyne/v2"
@@ -122,6 +123,56 @@ func TestGetNextAutoPlayMediaWrapsAtEnd(t *testing.T) {
 	}
 }
 
+func TestShuffledAutoPlayRepeatsOnlyWithRepeatAll(t *testing.T) {
+	dir := t.TempDir()
+	videoOne := filepath.Join(dir, "01.mp4")
+	videoTwo := filepath.Join(dir, "02.mp4")
+
+	screen := newTraversalTestScreen(t, videoOne)
+	screen.Shuffle = true
+	screen.SessionQueue = newSessionQueue(testQueueItems(videoOne, videoTwo), 0)
+
+	if _, path, err := getNextAutoPlayMediaOrError(screen); err != nil || path != videoTwo {
+		t.Fatalf("shuffled next = %q, %v; want %q", path, err, videoTwo)
+	}
+	screen.mediafile = videoTwo
+	if _, _, err := getNextAutoPlayMediaOrError(screen); !errors.Is(err, errNoNextQueueMedia) {
+		t.Fatalf("expected errNoNextQueueMedia, got %v", err)
+	}
+	screen.RepeatAll = true
+	if _, path, err := getNextAutoPlayMediaOrError(screen); err != nil || path != videoOne {
+		t.Fatalf("repeated next = %q, %v; want %q", path, err, videoOne)
+	}
+}
+
+func TestShuffledTraversalFollowsStableOrder(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "01.mp4"), filepath.Join(dir, "02.mp4"), filepath.Join(dir, "03.mp4"), filepath.Join(dir, "04.mp4")}
+
+	screen := newTraversalTestScreen(t, paths[0])
+	screen.Shuffle = true
+	screen.SessionQueue = newSessionQueue(testQueueItems(paths...), 0)
+
+	played := []string{paths[0]}
+	for len(played) < len(paths) {
+		_, next, err := getAdjacentMedia(screen, 1)
+		if err != nil {
+			t.Fatalf("next after %v: %v", played, err)
+		}
+		if slices.Contains(played, next) {
+			t.Fatalf("shuffle revisited %q after %v", next, played)
+		}
+		screen.mediafile = next
+		played = append(played, next)
+	}
+	if _, _, err := getAdjacentMedia(screen, 1); !errors.Is(err, errNoNextQueueMedia) {
+		t.Fatalf("expected end of shuffled order, got %v", err)
+	}
+	if _, previous, err := getAdjacentMedia(screen, -1); err != nil || previous != played[2] {
+		t.Fatalf("previous = %q, %v; want %q", previous, err, played[2])
+	}
+}
+
 func TestGetNextAutoPlayMediaWrapsSameTypeOnly(t *testing.T) {
 	dir := t.TempDir()
 	audioOne := filepath.Join(dir, "01.mp3")
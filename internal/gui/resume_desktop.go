@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/alexballas/refyne/v2"
+	"go2tv.app/go2tv/v2/internal/resume"
 	"go2tv.app/go2tv/v2/soapcalls"
 	"go2tv.app/go2tv/v2/utils"
 )
@@ -20,12 +21,8 @@ const (
 	rememberPlaybackPositionPref = "RememberPlaybackPosition"
 	resumeHistoryPref            = "ResumePlaybackHistory"
 	resumeHistoryVersion         = 1
-	resumeHistoryMaxEntries      = 100
-	resumeSaveInterval           = 5 * time.Second
-	resumeMinDeltaSeconds        = 5
-	resumeMinPositionSeconds     = 10
-	resumeCompletionProgress     = 0.95
-	resumeCompletionRemaining    = 30
+	resumeHistoryMaxEntries      = resume.MaxEntries
+	resumeMinPositionSeconds     = resume.MinPosition
 	dlnaResumeRetryAttempts      = 12
 	dlnaResumeRetryInterval      = time.Second
 )
@@ -332,46 +329,11 @@ func computeChromecastResumeStart(existingSeek int, storedResume int) int {
 }
 
 func shouldRemoveResumeEntry(positionSeconds int, durationSeconds float64) bool {
-	if positionSeconds < resumeMinPositionSeconds || durationSeconds <= 0 {
-		return false
-	}
-
-	progress := float64(positionSeconds) / durationSeconds
-	remaining := durationSeconds - float64(positionSeconds)
-
-	return progress >= resumeCompletionProgress || remaining <= resumeCompletionRemaining
+	return resume.Finished(positionSeconds, durationSeconds)
 }
 
 func shouldPersistResumePosition(positionSeconds int, durationSeconds float64, lastSavedSeconds int, lastSavedAt time.Time, now time.Time, force bool) bool {
-	if positionSeconds < resumeMinPositionSeconds {
-		return false
-	}
-
-	if shouldRemoveResumeEntry(positionSeconds, durationSeconds) {
-		return false
-	}
-
-	if force {
-		return positionSeconds != lastSavedSeconds
-	}
-
-	if !lastSavedAt.IsZero() && now.Sub(lastSavedAt) < resumeSaveInterval {
-		return false
-	}
-
-	if absInt(positionSeconds-lastSavedSeconds) < resumeMinDeltaSeconds {
-		return false
-	}
-
-	return true
-}
-
-func absInt(v int) int {
-	if v < 0 {
-		return -v
-	}
-
-	return v
+	return resume.ShouldSave(positionSeconds, durationSeconds, lastSavedSeconds, lastSavedAt, now, force)
 }
 
 func (s *FyneScreen) clearResumeSession() {
@@ -0,0 +1,276 @@
+//go:build !(android || ios)
+
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alexballas/refyne/v2"
+	"github.com/alexballas/refyne/v2/container"
+	"github.com/alexballas/refyne/v2/lang"
+	"github.com/alexballas/refyne/v2/widget"
+	"go2tv.app/go2tv/v2/devices"
+)
+
+const (
+	sleepOff        = ""
+	sleepAfterTime  = "time"
+	sleepAfterItem  = "after_item"
+	sleepEndOfQueue = "end_of_queue"
+)
+
+type sleepChoice struct {
+	mode  string
+	after time.Duration
+}
+
+var sleepChoices = []sleepChoice{
+	{mode: sleepOff},
+	{mode: sleepAfterTime, after: 15 * time.Minute},
+	{mode: sleepAfterTime, after: 30 * time.Minute},
+	{mode: sleepAfterTime, after: 45 * time.Minute},
+	{mode: sleepAfterTime, after: 60 * time.Minute},
+	{mode: sleepAfterTime, after: 90 * time.Minute},
+	{mode: sleepAfterTime, after: 120 * time.Minute},
+	{mode: sleepAfterItem},
+	{mode: sleepEndOfQueue},
+}
+
+func (c sleepChoice) label() string {
+	switch c.mode {
+	case sleepAfterTime:
+		return fmt.Sprintf(lang.L("%d minutes"), int(c.after.Minutes()))
+	case sleepAfterItem:
+		return lang.L("After current item")
+	case sleepEndOfQueue:
+		return lang.L("At end of playlist")
+	default:
+		return lang.L("Off")
+	}
+}
+
+// nextClockTime returns the next time after now that the local clock reads
+// the HH:MM value.
+func nextClockTime(value string, now time.Time) (time.Time, error) {
+	clock, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, errors.New(lang.L("Enter a start time as HH:MM"))
+	}
+	at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at, nil
+}
+
+// setSleepTimer replaces the sleep timer. Item-based modes also withdraw a
+// gapless next already staged on a DLNA renderer, which would otherwise
+// advance on its own.
+func (p *FyneScreen) setSleepTimer(choice sleepChoice) {
+	p.mu.Lock()
+	if p.sleepTimer != nil {
+		p.sleepTimer.Stop()
+		p.sleepTimer = nil
+	}
+	p.sleep, p.sleepDeadline = choice, time.Time{}
+	if choice.mode == sleepAfterTime {
+		p.sleepDeadline = time.Now().Add(choice.after)
+		var timer *time.Timer
+		timer = time.AfterFunc(choice.after, func() { p.sleepExpired(timer) })
+		p.sleepTimer = timer
+	}
+	p.mu.Unlock()
+	p.refreshTimers()
+
+	if choice.mode != sleepAfterItem && choice.mode != sleepEndOfQueue {
+		return
+	}
+	go func() {
+		gaplessOption := fyne.CurrentApp().Preferences().StringWithFallback("Gapless", "Disabled")
+		target := traversalPlaybackTarget(p)
+		if gaplessOption == "Enabled" && target.device.deviceType == devices.DeviceTypeDLNA && p.tvdata != nil && p.tvdata.CallbackURL != "" {
+			if _, err := queueNext(p, true); err != nil {
+				check(p, err)
+			}
+		}
+	}()
+}
+
+func (p *FyneScreen) sleepExpired(timer *time.Timer) {
+	p.mu.Lock()
+	if p.sleepTimer != timer {
+		p.mu.Unlock()
+		return
+	}
+	p.sleepTimer, p.sleep, p.sleepDeadline = nil, sleepChoice{}, time.Time{}
+	p.mu.Unlock()
+	p.refreshTimers()
+	switch p.getScreenState() {
+	case "Playing", "Paused":
+		stopAction(p)
+	}
+}
+
+func (p *FyneScreen) sleepTimerMode() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.sleep.mode
+}
+
+// disarmItemSleep clears an item-based sleep timer; a timed one keeps
+// running across stops.
+func (p *FyneScreen) disarmItemSleep() {
+	switch p.sleepTimerMode() {
+	case sleepAfterItem, sleepEndOfQueue:
+		p.setSleepTimer(sleepChoice{mode: sleepOff})
+	}
+}
+
+// sleepAfterFinish reports whether the item that just finished is the last
+// one an item-based sleep timer allows, disarming the timer if so.
+func (p *FyneScreen) sleepAfterFinish() bool {
+	switch p.sleepTimerMode() {
+	case sleepAfterItem:
+	case sleepEndOfQueue:
+		if !p.Medialoop && p.NextMediaCheck.Checked {
+			if _, _, err := getNextAutoPlayMediaOrError(p); !isTraversalBoundaryError(err) {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	p.setSleepTimer(sleepChoice{mode: sleepOff})
+	return true
+}
+
+// scheduleStart plays the current media, or the playlist from the top, on the
+// selected device at the given time. A zero time cancels.
+func (p *FyneScreen) scheduleStart(at time.Time) error {
+	target := selectedPlaybackTarget(p)
+	if !at.IsZero() {
+		if target.device.addr == "" {
+			return errors.New(lang.L("please select a device"))
+		}
+		if queue, _ := p.queueSnapshot(); p.mediafile == "" && (queue == nil || queue.Len() == 0) {
+			return errors.New(lang.L("please select a media file or enter a media URL"))
+		}
+	}
+
+	p.mu.Lock()
+	if p.scheduleTimer != nil {
+		p.scheduleTimer.Stop()
+		p.scheduleTimer = nil
+	}
+	p.scheduleAt, p.scheduleDevice = at, target.device
+	if !at.IsZero() {
+		var timer *time.Timer
+		timer = time.AfterFunc(time.Until(at), func() { p.startScheduled(timer, target) })
+		p.scheduleTimer = timer
+	}
+	p.mu.Unlock()
+	p.refreshTimers()
+	return nil
+}
+
+func (p *FyneScreen) startScheduled(timer *time.Timer, target playbackTarget) {
+	p.mu.Lock()
+	if p.scheduleTimer != timer {
+		p.mu.Unlock()
+		return
+	}
+	p.scheduleTimer, p.scheduleAt, p.scheduleDevice = nil, time.Time{}, devType{}
+	p.mu.Unlock()
+	p.refreshTimers()
+
+	switch p.getScreenState() {
+	case "Playing", "Paused":
+		return
+	}
+	if p.mediafile == "" {
+		if queue, _ := p.queueSnapshot(); queue != nil {
+			if first, ok := queue.Item(0); ok {
+				if err := setCurrentMediaPath(p, first.Path()); err != nil {
+					check(p, err)
+					return
+				}
+			}
+		}
+	}
+	playActionOnTarget(p, target)
+}
+
+func (p *FyneScreen) timersStatusText() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var parts []string
+	if p.sleep.mode == sleepAfterTime {
+		parts = append(parts, fmt.Sprintf(lang.L("Stops at %s"), p.sleepDeadline.Format("15:04")))
+	}
+	if !p.scheduleAt.IsZero() {
+		parts = append(parts, fmt.Sprintf(lang.L("Starts at %s on %s"), p.scheduleAt.Format("15:04"), p.scheduleDevice.name))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (p *FyneScreen) refreshTimers() {
+	status := p.timersStatusText()
+	mode := p.sleepTimerMode()
+	fyne.Do(func() {
+		if p.timersStatus != nil {
+			p.timersStatus.SetText(status)
+		}
+		if p.sleepSelect != nil && mode == sleepOff && p.sleepSelect.SelectedIndex() > 0 {
+			// Assigned directly so the expired timer is not re-armed.
+			p.sleepSelect.Selected = sleepChoices[0].label()
+			p.sleepSelect.Refresh()
+		}
+	})
+}
+
+// buildTimerControls returns the sleep timer and scheduled start row of the
+// playlist window.
+func (p *FyneScreen) buildTimerControls(parent fyne.Window) fyne.CanvasObject {
+	options := make([]string, len(sleepChoices))
+	for i, choice := range sleepChoices {
+		options[i] = choice.label()
+	}
+	sleepSelect := widget.NewSelect(options, func(selected string) {
+		if index := slices.Index(options, selected); index >= 0 {
+			p.setSleepTimer(sleepChoices[index])
+		}
+	})
+	p.mu.RLock()
+	sleepSelect.Selected = options[max(slices.Index(sleepChoices, p.sleep), 0)]
+	p.mu.RUnlock()
+
+	startEntry := widget.NewEntry()
+	startEntry.SetPlaceHolder("07:00")
+	schedule := widget.NewButton(lang.L("Schedule"), func() {
+		at, err := nextClockTime(startEntry.Text, time.Now())
+		if err == nil {
+			err = p.scheduleStart(at)
+		}
+		checkInWindow(p, err, parent)
+	})
+	cancel := widget.NewButton(lang.L("Cancel"), func() {
+		_ = p.scheduleStart(time.Time{})
+	})
+	status := widget.NewLabel(p.timersStatusText())
+
+	p.sleepSelect = sleepSelect
+	p.timersStatus = status
+	return container.NewHBox(
+		widget.NewLabel(lang.L("Sleep timer")),
+		sleepSelect,
+		widget.NewLabel(lang.L("Start at")),
+		startEntry,
+		schedule,
+		cancel,
+		status,
+	)
+}
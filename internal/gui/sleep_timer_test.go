@@ -0,0 +1,52 @@
+//go:build !(android || ios)
+
+package gui
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSleepTimerLimitsAutoPlay(t *testing.T) {
+	dir := t.TempDir()
+	videoOne := filepath.Join(dir, "01.mp4")
+	videoTwo := filepath.Join(dir, "02.mp4")
+
+	screen := newTraversalTestScreen(t, videoOne)
+	screen.SessionQueue = newSessionQueue(testQueueItems(videoOne, videoTwo), 0)
+
+	screen.sleep = sleepChoice{mode: sleepAfterItem}
+	if _, _, err := getNextAutoPlayMediaOrError(screen); !errors.Is(err, errNoNextQueueMedia) {
+		t.Fatalf("after item: expected errNoNextQueueMedia, got %v", err)
+	}
+
+	screen.sleep = sleepChoice{mode: sleepEndOfQueue}
+	if _, path, err := getNextAutoPlayMediaOrError(screen); err != nil || path != videoTwo {
+		t.Fatalf("end of queue: got %q, %v", path, err)
+	}
+	screen.mediafile = videoTwo
+	screen.SessionQueue = newSessionQueue(testQueueItems(videoOne, videoTwo), 1)
+	if _, _, err := getNextAutoPlayMediaOrError(screen); !errors.Is(err, errNoNextQueueMedia) {
+		t.Fatalf("end of queue wrapped: %v", err)
+	}
+}
+
+func TestNextClockTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 30, 0, 0, time.Local)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{value: "09:15", want: time.Date(2026, 3, 1, 9, 15, 0, 0, time.Local)},
+		{value: " 07:00 ", want: time.Date(2026, 3, 2, 7, 0, 0, 0, time.Local)},
+		{value: "08:30", want: time.Date(2026, 3, 2, 8, 30, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := nextClockTime(tt.value, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Fatalf("nextClockTime(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+//go:build !(android || ios)
+
+package gui
+
+import (
+	"errors"
+
+	"github.com/alexballas/refyne/v2/lang"
+)
+
+// handoffPosition is where a transferred session resumes. It only applies
+// to the media that was playing when the transfer started.
+type handoffPosition struct {
+	media   string
+	seconds int
+}
+
+// transferPlayback moves the active session to the selected device: the
+// active device is stopped and the same media starts on the selected one at
+// the position the player last showed.
+func transferPlayback(screen *FyneScreen) {
+	switch screen.getScreenState() {
+	case "Playing", "Paused":
+	default:
+		return
+	}
+	active, ok := activeSessionPlaybackTarget(screen)
+	target := selectedPlaybackTarget(screen)
+	if !ok || target.device.addr == "" || target.device.addr == active.device.addr {
+		check(screen, errors.New(lang.L("Select another device to transfer playback to")))
+		return
+	}
+
+	position, _ := screen.displayedResumeProgress()
+	media := screen.mediafile
+	stopActionSync(screen)
+
+	screen.mu.Lock()
+	screen.handoff = handoffPosition{media: media, seconds: position}
+	screen.mu.Unlock()
+	playActionOnTarget(screen, target)
+}
+
+// playbackStart returns the offset a new session starts at: the handoff
+// position when a transfer restarts the same media, the stored resume
+// position otherwise.
+func (s *FyneScreen) playbackStart(mediaType string) int {
+	s.mu.Lock()
+	handoff := s.handoff
+	s.handoff = handoffPosition{}
+	s.mu.Unlock()
+
+	stored := s.prepareResumeSession(mediaType)
+	if handoff.seconds > 0 && handoff.media == s.mediafile {
+		return handoff.seconds
+	}
+	return stored
+}
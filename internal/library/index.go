@@ -0,0 +1,814 @@
+package library
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/utils"
+)
+
+const (
+	// DefaultRescan is how often an indexed root is listed again in full. It
+	// catches what the watcher missed and is the only update for roots on
+	// network mounts, where change events do not arrive.
+	DefaultRescan = time.Hour
+	// MaxIndexEntries bounds the entries indexed per root. A larger root is
+	// left out of the index and browsed from disk.
+	MaxIndexEntries = 500000
+	indexVersion    = 2
+	indexSettle     = 500 * time.Millisecond
+	indexSaveDelay  = 5 * time.Second
+)
+
+var (
+	ErrNoIndex    = errors.New("library index disabled")
+	errIndexLimit = errors.New("too many entries to index")
+)
+
+// indexEntry is what the index keeps of a folder or media file. Added is when
+// the index first saw it; a root indexed for the first time takes its files'
+// modification times instead.
+type indexEntry struct {
+	Name    string    `json:"name"`
+	Dir     bool      `json:"dir,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mtime"`
+	Added   time.Time `json:"added"`
+}
+
+// indexedRoot holds the listing of every folder the scan reached, keyed by
+// relative path with "." for the root itself. Listings are sorted by name and
+// replaced, never changed in place, so readers may keep them past the lock.
+// order lists the folders sorted for searches to walk, and recent the newest
+// MaxLimit media files for RecentlyAdded; both are built with the listings.
+type indexedRoot struct {
+	dirs   map[string][]indexEntry
+	order  []string
+	recent []indexedFile
+}
+
+// indexedFile is a media file of an indexed root.
+type indexedFile struct {
+	dir  string
+	item indexEntry
+}
+
+func newIndexedRoot(dirs map[string][]indexEntry) *indexedRoot {
+	root := &indexedRoot{dirs: dirs, order: slices.Sorted(maps.Keys(dirs))}
+	for _, dir := range root.order {
+		for _, item := range dirs[dir] {
+			if item.Dir || mediamodel.KindForPath(item.Name) == mediamodel.MediaKindUnknown {
+				continue
+			}
+			file := indexedFile{dir: dir, item: item}
+			at, _ := slices.BinarySearchFunc(root.recent, file, compareAdded)
+			if at == MaxLimit {
+				continue
+			}
+			root.recent = slices.Insert(root.recent, at, file)
+			if len(root.recent) > MaxLimit {
+				root.recent = root.recent[:MaxLimit]
+			}
+		}
+	}
+	return root
+}
+
+// compareAdded orders the most recently added file first.
+func compareAdded(a, b indexedFile) int {
+	if c := b.item.Added.Compare(a.item.Added); c != 0 {
+		return c
+	}
+	return cmp.Or(strings.Compare(a.dir, b.dir), strings.Compare(a.item.Name, b.item.Name))
+}
+
+// indexFile is the index file itself: the indexed roots, each kept in a file
+// of its own next to it, and the probe results.
+type indexFile struct {
+	Version int               `json:"version"`
+	Roots   map[string]string `json:"roots"`
+	Probes  []probeRecord     `json:"probes,omitempty"`
+}
+
+type rootIndexFile struct {
+	Version int                     `json:"version"`
+	Root    string                  `json:"root"`
+	Dirs    map[string][]indexEntry `json:"dirs"`
+}
+
+// index keeps roots and the dirty marks under Library.mu. The rest belongs to
+// the goroutine running runIndex. Saves write only the root files in
+// dirtyRoots, and the index file itself when dirtyMain is set.
+type index struct {
+	path       string
+	rescan     time.Duration
+	roots      map[string]*indexedRoot
+	dirtyRoots map[string]bool
+	dirtyMain  bool
+	watcher    *fsnotify.Watcher
+	watched    map[string]string
+	pending    map[string]map[string]bool
+	wake       chan struct{}
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// startIndex loads the index file and starts keeping it current. The index is
+// a cache, so a file that cannot be read is rebuilt from the roots, and
+// without a watcher the roots are only rescanned.
+func (l *Library) startIndex(path string, rescan time.Duration) {
+	roots, probes := loadIndex(path)
+	for _, record := range probes {
+		if len(l.probes) == MaxProbes {
+			break
+		}
+		l.probes[record.Key] = record
+	}
+	ix := &index{
+		path:       path,
+		rescan:     rescan,
+		roots:      roots,
+		dirtyRoots: make(map[string]bool),
+		watched:    make(map[string]string),
+		pending:    make(map[string]map[string]bool),
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	if watcher, err := fsnotify.NewWatcher(); err == nil {
+		ix.watcher = watcher
+	}
+	l.index = ix
+	ix.wg.Add(1)
+	go l.runIndex()
+}
+
+func loadIndex(path string) (map[string]*indexedRoot, []probeRecord) {
+	roots := make(map[string]*indexedRoot)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return roots, nil
+	}
+	var saved indexFile
+	if json.Unmarshal(data, &saved) != nil || saved.Version != indexVersion {
+		return roots, nil
+	}
+	for canonical := range saved.Roots {
+		if root := loadRootIndex(rootIndexPath(path, canonical), canonical); root != nil {
+			roots[canonical] = root
+		}
+	}
+	return roots, saved.Probes
+}
+
+// loadRootIndex reads the file of one root. A missing or damaged one leaves
+// the root to be scanned again.
+func loadRootIndex(path, canonical string) *indexedRoot {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var saved rootIndexFile
+	if json.Unmarshal(data, &saved) != nil || saved.Version != indexVersion || saved.Root != canonical {
+		return nil
+	}
+	dirs := make(map[string][]indexEntry, len(saved.Dirs))
+	for rel, listing := range saved.Dirs {
+		rel = filepath.FromSlash(rel)
+		if validateRelative(rel) == nil {
+			dirs[rel] = listing
+		}
+	}
+	if dirs["."] == nil {
+		return nil
+	}
+	return newIndexedRoot(dirs)
+}
+
+// rootIndexPath names the file of an indexed root after the index file and a
+// hash of the root path.
+func rootIndexPath(path, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + hex.EncodeToString(sum[:8]) + ext
+}
+
+// Indexed reports whether the library keeps an index.
+func (l *Library) Indexed() bool { return l.index != nil }
+
+func (l *Library) stopIndex() error {
+	ix := l.index
+	close(ix.done)
+	ix.wg.Wait()
+	var errs []error
+	if ix.watcher != nil {
+		errs = append(errs, ix.watcher.Close())
+	}
+	errs = append(errs, l.saveIndex())
+	return errors.Join(errs...)
+}
+
+func (ix *index) stopped() bool {
+	select {
+	case <-ix.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ix *index) poke() {
+	select {
+	case ix.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (l *Library) runIndex() {
+	ix := l.index
+	defer ix.wg.Done()
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	if ix.watcher != nil {
+		events, watchErrors = ix.watcher.Events, ix.watcher.Errors
+	}
+	rescan := time.NewTicker(ix.rescan)
+	defer rescan.Stop()
+	save := time.NewTicker(indexSaveDelay)
+	defer save.Stop()
+	settle := time.NewTimer(indexSettle)
+	settle.Stop()
+	settling := false
+
+	l.syncIndex(true)
+	for {
+		select {
+		case <-ix.done:
+			return
+		case <-ix.wake:
+			l.syncIndex(false)
+		case <-rescan.C:
+			l.syncIndex(true)
+		case _, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				continue
+			}
+			// Events were dropped, so any folder may have changed.
+			l.syncIndex(true)
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if ix.note(event) && !settling {
+				settle.Reset(indexSettle)
+				settling = true
+			}
+		case <-settle.C:
+			settling = false
+			l.relistPending()
+		case <-save.C:
+			_ = l.saveIndex()
+		}
+	}
+}
+
+// syncIndex drops roots that are no longer configured and scans the rest, or
+// with full unset only the roots not indexed yet.
+func (l *Library) syncIndex(full bool) {
+	ix := l.index
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	configured := make(map[string]bool, len(l.roots))
+	var scan []*rootState
+	for _, root := range l.rootList {
+		state := l.roots[root.ID]
+		configured[state.canonical] = true
+		if full || ix.roots[state.canonical] == nil {
+			scan = append(scan, state)
+		}
+	}
+	for canonical := range ix.roots {
+		if !configured[canonical] {
+			delete(ix.roots, canonical)
+			ix.dirtyRoots[canonical], ix.dirtyMain = true, true
+		}
+	}
+	l.mu.Unlock()
+	for dir, canonical := range ix.watched {
+		if !configured[canonical] {
+			_ = ix.watcher.Remove(dir)
+			delete(ix.watched, dir)
+		}
+	}
+	for _, root := range scan {
+		if ix.stopped() {
+			return
+		}
+		l.scanRoot(root)
+	}
+}
+
+func (l *Library) scanRoot(root *rootState) {
+	ix := l.index
+	var old map[string][]indexEntry
+	if indexed := ix.roots[root.canonical]; indexed != nil {
+		old = indexed.dirs
+	}
+	dirs, err := ix.scanTree(root.handle, ".", old, time.Now(), old != nil)
+	if err != nil && !errors.Is(err, errIndexLimit) {
+		// Keep the last listing; the next rescan tries again.
+		return
+	}
+	if old != nil && dirs != nil && maps.EqualFunc(old, dirs, sameListing) {
+		// Nothing changed, so neither the root nor its file is replaced.
+		ix.watch(root.canonical, dirs)
+		return
+	}
+	var indexed *indexedRoot
+	if dirs != nil {
+		indexed = newIndexedRoot(dirs)
+	}
+	l.mu.Lock()
+	if l.closed || l.roots[root.id] != root {
+		l.mu.Unlock()
+		return
+	}
+	_, known := ix.roots[root.canonical]
+	if indexed == nil {
+		delete(ix.roots, root.canonical)
+	} else {
+		ix.roots[root.canonical] = indexed
+	}
+	ix.dirtyRoots[root.canonical] = true
+	ix.dirtyMain = ix.dirtyMain || known != (indexed != nil)
+	l.mu.Unlock()
+	ix.watch(root.canonical, dirs)
+}
+
+func sameListing(a, b []indexEntry) bool {
+	return slices.EqualFunc(a, b, func(x, y indexEntry) bool {
+		return x.Name == y.Name && x.Dir == y.Dir && x.Size == y.Size && x.ModTime.Equal(y.ModTime) && x.Added.Equal(y.Added)
+	})
+}
+
+// relistPending lists again the folders that change events named and indexes
+// any new subfolders in full.
+func (l *Library) relistPending() {
+	ix := l.index
+	pending := ix.pending
+	ix.pending = make(map[string]map[string]bool)
+	l.mu.Lock()
+	roots := make(map[string]*rootState, len(l.roots))
+	for _, root := range l.roots {
+		roots[root.canonical] = root
+	}
+	l.mu.Unlock()
+	now := time.Now()
+	for canonical, rels := range pending {
+		root, indexed := roots[canonical], ix.roots[canonical]
+		if root == nil || indexed == nil {
+			continue
+		}
+		dirs := maps.Clone(indexed.dirs)
+		for _, rel := range slices.Sorted(maps.Keys(rels)) {
+			ix.relist(root, dirs, rel, now)
+		}
+		relisted := newIndexedRoot(dirs)
+		l.mu.Lock()
+		if l.closed || l.roots[root.id] != root {
+			l.mu.Unlock()
+			continue
+		}
+		ix.roots[canonical] = relisted
+		ix.dirtyRoots[canonical] = true
+		l.mu.Unlock()
+		ix.watch(canonical, dirs)
+	}
+}
+
+func (ix *index) relist(root *rootState, dirs map[string][]indexEntry, rel string, now time.Time) {
+	old, ok := dirs[rel]
+	if !ok {
+		return
+	}
+	listing, subdirs, err := scanDir(root.handle, rel, old, now, true)
+	if err != nil {
+		// The folder is gone; its parent's event updates the parent listing.
+		dropTree(dirs, rel)
+		return
+	}
+	dirs[rel] = listing
+	for _, item := range old {
+		if !item.Dir {
+			continue
+		}
+		if index, found := slices.BinarySearchFunc(listing, item.Name, compareEntryName); !found || !listing[index].Dir {
+			dropTree(dirs, joinRelative(rel, item.Name))
+		}
+	}
+	for _, sub := range subdirs {
+		if _, indexed := dirs[sub]; indexed {
+			continue
+		}
+		if tree, err := ix.scanTree(root.handle, sub, nil, now, true); err == nil {
+			maps.Copy(dirs, tree)
+		}
+	}
+}
+
+func dropTree(dirs map[string][]indexEntry, rel string) {
+	if rel == "." {
+		clear(dirs)
+		return
+	}
+	prefix := rel + string(filepath.Separator)
+	for key := range dirs {
+		if key == rel || strings.HasPrefix(key, prefix) {
+			delete(dirs, key)
+		}
+	}
+}
+
+// scanTree lists rel and every folder below it. Folder symlinks are listed but
+// not followed, so the scan stays within the real tree of the root.
+func (ix *index) scanTree(handle *os.Root, rel string, old map[string][]indexEntry, now time.Time, known bool) (map[string][]indexEntry, error) {
+	dirs := make(map[string][]indexEntry)
+	queue := []string{rel}
+	entries := 0
+	for len(queue) > 0 {
+		if ix.stopped() {
+			return nil, ErrClosed
+		}
+		current := queue[0]
+		queue = queue[1:]
+		listing, subdirs, err := scanDir(handle, current, old[current], now, known)
+		if err != nil {
+			if current == rel {
+				return nil, err
+			}
+			continue
+		}
+		if entries += len(listing); entries > MaxIndexEntries {
+			return nil, errIndexLimit
+		}
+		dirs[current] = listing
+		queue = append(queue, subdirs...)
+	}
+	return dirs, nil
+}
+
+// scanDir lists what Browse would show of rel, with the subfolders a scan
+// should descend into. Added times carry over from old; a file new to a
+// known root is stamped now.
+func scanDir(handle *os.Root, rel string, old []indexEntry, now time.Time, known bool) ([]indexEntry, []string, error) {
+	dir, err := openRootFile(handle, rel)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer dir.Close()
+	if info, err := dir.Stat(); err != nil || !info.IsDir() {
+		return nil, nil, ErrNotDirectory
+	}
+	dirEntries, err := dir.ReadDir(-1)
+	if err != nil {
+		return nil, nil, err
+	}
+	listing := make([]indexEntry, 0, len(dirEntries))
+	var subdirs []string
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		child := joinRelative(rel, name)
+		if hiddenName(name) || validateRelative(child) != nil {
+			continue
+		}
+		info, err := handle.Stat(child)
+		if err != nil {
+			continue
+		}
+		item := indexEntry{Name: name, ModTime: info.ModTime().UTC()}
+		switch {
+		case info.IsDir():
+			item.Dir = true
+			if dirEntry.Type()&fs.ModeSymlink == 0 {
+				subdirs = append(subdirs, child)
+			}
+		case info.Mode().IsRegular() && supportedFile(child):
+			item.Size = info.Size()
+		default:
+			continue
+		}
+		index, found := slices.BinarySearchFunc(old, name, compareEntryName)
+		switch {
+		case found && old[index].Dir == item.Dir:
+			item.Added = old[index].Added
+		case known:
+			item.Added = now.UTC()
+		default:
+			item.Added = item.ModTime
+		}
+		listing = append(listing, item)
+	}
+	slices.SortFunc(listing, func(a, b indexEntry) int { return strings.Compare(a.Name, b.Name) })
+	return listing, subdirs, nil
+}
+
+func compareEntryName(item indexEntry, name string) int {
+	return strings.Compare(item.Name, name)
+}
+
+func joinRelative(parent, name string) string {
+	if parent == "." {
+		return name
+	}
+	return filepath.Join(parent, name)
+}
+
+// watch keeps a watch on every indexed folder of a local root. Once the
+// system refuses another watch the rest of the root relies on rescans.
+func (ix *index) watch(canonical string, dirs map[string][]indexEntry) {
+	if ix.watcher == nil {
+		return
+	}
+	for dir, root := range ix.watched {
+		if root != canonical {
+			continue
+		}
+		if rel, err := filepath.Rel(canonical, dir); err != nil || dirs[rel] == nil {
+			_ = ix.watcher.Remove(dir)
+			delete(ix.watched, dir)
+		}
+	}
+	if dirs == nil || remoteFilesystem(canonical) {
+		return
+	}
+	for rel := range dirs {
+		dir := filepath.Join(canonical, rel)
+		if _, ok := ix.watched[dir]; ok {
+			continue
+		}
+		if ix.watcher.Add(dir) != nil {
+			return
+		}
+		ix.watched[dir] = canonical
+	}
+}
+
+// note queues the folder an event happened in for relisting.
+func (ix *index) note(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	dir := filepath.Dir(event.Name)
+	canonical, ok := ix.watched[dir]
+	if !ok {
+		return false
+	}
+	rel, err := filepath.Rel(canonical, dir)
+	if err != nil {
+		return false
+	}
+	if ix.pending[canonical] == nil {
+		ix.pending[canonical] = make(map[string]bool)
+	}
+	ix.pending[canonical][rel] = true
+	return true
+}
+
+// saveIndex writes the root files that changed since the last save, then the
+// index file if the set of roots or the probe results changed. A failed write
+// stays marked for the next save.
+func (l *Library) saveIndex() error {
+	ix := l.index
+	l.mu.Lock()
+	changed := make(map[string]*indexedRoot, len(ix.dirtyRoots))
+	for canonical := range ix.dirtyRoots {
+		changed[canonical] = ix.roots[canonical]
+	}
+	var main *indexFile
+	if ix.dirtyMain {
+		main = &indexFile{Version: indexVersion, Roots: make(map[string]string, len(ix.roots))}
+		for canonical := range ix.roots {
+			main.Roots[canonical] = filepath.Base(rootIndexPath(ix.path, canonical))
+		}
+		for _, record := range l.probes {
+			main.Probes = append(main.Probes, record)
+		}
+	}
+	clear(ix.dirtyRoots)
+	ix.dirtyMain = false
+	l.mu.Unlock()
+
+	var errs []error
+	failed := make(map[string]bool)
+	for canonical, root := range changed {
+		if err := saveRootIndex(rootIndexPath(ix.path, canonical), canonical, root); err != nil {
+			errs = append(errs, err)
+			failed[canonical] = true
+		}
+	}
+	mainFailed := false
+	if main != nil {
+		data, err := json.Marshal(main)
+		if err == nil {
+			err = utils.WriteFileAtomic(ix.path, data)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			mainFailed = true
+		}
+	}
+	if len(errs) != 0 {
+		l.mu.Lock()
+		for canonical := range failed {
+			ix.dirtyRoots[canonical] = true
+		}
+		ix.dirtyMain = ix.dirtyMain || mainFailed
+		l.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// saveRootIndex writes the file of one root, or removes it when root is nil.
+func saveRootIndex(path, canonical string, root *indexedRoot) error {
+	if root == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	saved := rootIndexFile{Version: indexVersion, Root: canonical, Dirs: make(map[string][]indexEntry, len(root.dirs))}
+	for rel, listing := range root.dirs {
+		saved.Dirs[filepath.ToSlash(rel)] = listing
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, data)
+}
+
+// indexedListing returns the indexed listing of a folder, if the index has one.
+func (l *Library) indexedListing(root *rootState, rel string) ([]indexEntry, bool) {
+	if l.index == nil {
+		return nil, false
+	}
+	indexed := l.index.roots[root.canonical]
+	if indexed == nil {
+		return nil, false
+	}
+	listing, ok := indexed.dirs[rel]
+	return listing, ok
+}
+
+func (l *Library) readListing(root *rootState, state *cursorState, limit int) (Page, bool) {
+	page := Page{Entries: make([]Entry, 0, limit)}
+	for scanned := 0; len(page.Entries) < limit && scanned < l.scanCap && state.offset < len(state.listing); scanned++ {
+		item := state.listing[state.offset]
+		state.offset++
+		kind := "file"
+		if item.Dir {
+			kind = "directory"
+		}
+		entry, ok := l.newEntry(root, joinRelative(state.rel, item.Name), kind, item.Size, item.ModTime)
+		if ok && (entry.Kind == "directory" || state.filter.match(entry.Progress)) {
+			page.Entries = append(page.Entries, entry)
+		}
+	}
+	return page, state.offset == len(state.listing)
+}
+
+// searchHit is an indexed file that matched a search.
+type searchHit struct {
+	rootID string
+	dir    string
+	item   indexEntry
+}
+
+// indexCovers reports whether every root is indexed, so a search can read the
+// index instead of the folders.
+func (l *Library) indexCovers() bool {
+	if l.index == nil {
+		return false
+	}
+	for _, root := range l.rootList {
+		if l.index.roots[l.roots[root.ID].canonical] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// indexPage reads the next matches from the index. Like searchPage it looks
+// at no more than ScanCap entries a page. The walk keeps the listing of the
+// root it is in, so a root indexed again meanwhile is finished as it was.
+func (l *Library) indexPage(state *searchState, limit int) (SearchPage, bool) {
+	page := SearchPage{Results: make([]SearchResult, 0, limit)}
+	for scanned := 0; len(page.Results) < limit && scanned < l.scanCap; {
+		if state.tree == nil {
+			if state.root == len(state.rootIDs) {
+				return page, true
+			}
+			if root := l.roots[state.rootIDs[state.root]]; root != nil {
+				state.tree = l.index.roots[root.canonical]
+			}
+			state.dirAt, state.itemAt = 0, 0
+			if state.tree == nil {
+				state.root++
+				continue
+			}
+		}
+		if state.dirAt == len(state.tree.order) {
+			state.tree = nil
+			state.root++
+			continue
+		}
+		dir := state.tree.order[state.dirAt]
+		listing := state.tree.dirs[dir]
+		if state.itemAt == len(listing) {
+			state.dirAt, state.itemAt = state.dirAt+1, 0
+			continue
+		}
+		item := listing[state.itemAt]
+		state.itemAt++
+		scanned++
+		if item.Dir || mediamodel.KindForPath(item.Name) == mediamodel.MediaKindUnknown || !matchTerms(strings.ToLower(displayName(item.Name)), state.terms) {
+			continue
+		}
+		if result, ok := l.indexedResult(searchHit{rootID: state.rootIDs[state.root], dir: dir, item: item}); ok {
+			page.Results = append(page.Results, result)
+		}
+	}
+	return page, false
+}
+
+func (l *Library) indexedResult(hit searchHit) (SearchResult, bool) {
+	root := l.roots[hit.rootID]
+	if root == nil {
+		return SearchResult{}, false
+	}
+	entry, ok := l.newEntry(root, joinRelative(hit.dir, hit.item.Name), "file", hit.item.Size, hit.item.ModTime)
+	if !ok {
+		return SearchResult{}, false
+	}
+	return SearchResult{RootID: root.id, Parent: searchParent(hit.dir), Entry: entry}, true
+}
+
+// RecentlyAdded lists up to limit media files from the index, the most
+// recently added first. Roots still being indexed for the first time are left
+// out.
+func (l *Library) RecentlyAdded(limit int) ([]SearchResult, error) {
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	if limit < 1 || limit > MaxLimit {
+		return nil, ErrInvalidLimit
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, ErrClosed
+	}
+	if l.index == nil {
+		return nil, ErrNoIndex
+	}
+	var files []searchHit
+	for _, root := range l.rootList {
+		indexed := l.index.roots[l.roots[root.ID].canonical]
+		if indexed == nil {
+			continue
+		}
+		for _, file := range indexed.recent {
+			files = append(files, searchHit{rootID: root.ID, dir: file.dir, item: file.item})
+		}
+	}
+	slices.SortFunc(files, func(a, b searchHit) int {
+		return compareAdded(indexedFile{dir: a.dir, item: a.item}, indexedFile{dir: b.dir, item: b.item})
+	})
+	results := make([]SearchResult, 0, min(limit, len(files)))
+	for _, file := range files {
+		if len(results) == limit {
+			break
+		}
+		if result, ok := l.indexedResult(file); ok {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,221 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestIndexBacksBrowseSearchAndRecentlyAdded(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{filepath.Join(root, "Show"), filepath.Join(root, ".trash")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for index, path := range []string{"old.mp4", "Show/e01.mkv", "Show/e01.srt", ".trash/gone.mp4", "notes.txt"} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		writeFile(t, path, "media")
+		if err := os.Chtimes(path, base, base.Add(time.Duration(index)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	lib, rootID := openTestLibrary(t, Config{Roots: []string{root}, IndexFile: indexPath})
+	if _, err := lib.RecentlyAdded(MaxLimit + 1); !errors.Is(err, ErrInvalidLimit) {
+		t.Fatalf("limit error = %v", err)
+	}
+	waitIndexed(t, lib, root)
+
+	page, err := lib.Browse(rootID, "", "", 1)
+	if err != nil || len(page.Entries) != 1 || page.Entries[0].Name != "Show" || page.Cursor == "" {
+		t.Fatalf("first page = %+v, %v", page, err)
+	}
+	show := page.Entries[0].ID
+	if page, err = lib.Browse(rootID, "", page.Cursor, 1); err != nil || len(page.Entries) != 1 || page.Entries[0].Name != "old.mp4" {
+		t.Fatalf("second page = %+v, %v", page, err)
+	}
+	if page, err = lib.Browse(rootID, show, "", 0); err != nil || len(page.Entries) != 2 {
+		t.Fatalf("show page = %+v, %v", page, err)
+	}
+	found, err := lib.Search("E01", "", 0)
+	if err != nil || len(found.Results) != 1 || found.Results[0].Parent != "Show" || found.Cursor != "" {
+		t.Fatalf("search = %+v, %v", found, err)
+	}
+	recent, err := lib.RecentlyAdded(0)
+	if err != nil || len(recent) != 2 || recent[0].Name != "e01.mkv" || recent[1].Name != "old.mp4" {
+		t.Fatalf("recently added = %+v, %v", recent, err)
+	}
+	file, _, err := lib.OpenMedia(recent[0].RootID, recent[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	if err := lib.Close(); err != nil {
+		t.Fatal(err)
+	}
+	roots, _ := loadIndex(indexPath)
+	saved := roots[mustCanonical(t, root)]
+	if saved == nil || !slices.Equal(slices.Sorted(maps.Keys(saved.dirs)), []string{".", "Show"}) {
+		t.Fatalf("saved index = %+v", saved)
+	}
+}
+
+func TestIndexSavesChangedRootsAndPagesSearch(t *testing.T) {
+	show, movies := t.TempDir(), t.TempDir()
+	for i := range 3 {
+		writeFile(t, filepath.Join(show, fmt.Sprintf("e%02d.mkv", i+1)), "media")
+	}
+	writeFile(t, filepath.Join(movies, "film.mp4"), "media")
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	lib, err := Open(Config{Roots: []string{show, movies}, IndexFile: indexPath, ScanCap: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lib.Close() })
+	waitIndexed(t, lib, show)
+	waitIndexed(t, lib, movies)
+
+	// An indexed search reads ScanCap entries a page.
+	var names []string
+	cursor := ""
+	for pages := 0; pages == 0 || cursor != ""; pages++ {
+		page, err := lib.Search("e0", cursor, 0)
+		if err != nil || len(page.Results) > 1 || pages > 10 {
+			t.Fatalf("page %d = %+v, %v", pages, page, err)
+		}
+		for _, result := range page.Results {
+			names = append(names, result.Name)
+		}
+		cursor = page.Cursor
+	}
+	if !slices.Equal(names, []string{"e01.mkv", "e02.mkv", "e03.mkv"}) {
+		t.Fatalf("paged search = %v", names)
+	}
+
+	if err := lib.saveIndex(); err != nil {
+		t.Fatal(err)
+	}
+	showFile, moviesFile := rootIndexPath(indexPath, mustCanonical(t, show)), rootIndexPath(indexPath, mustCanonical(t, movies))
+	for _, path := range []string{showFile, moviesFile} {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(show, "e04.mkv"), "media")
+	waitFor(t, "new episode indexed", func() bool {
+		recent, err := lib.RecentlyAdded(1)
+		return err == nil && len(recent) == 1 && recent[0].Name == "e04.mkv"
+	})
+	if err := lib.saveIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(showFile); err != nil {
+		t.Fatalf("changed root not saved: %v", err)
+	}
+	if _, err := os.Stat(moviesFile); !os.IsNotExist(err) {
+		t.Fatalf("unchanged root saved again: %v", err)
+	}
+}
+
+func TestIndexFollowsChanges(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "Show"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "Show", "e01.mkv"), "media")
+	lib, _ := openTestLibrary(t, Config{Roots: []string{root}, IndexFile: filepath.Join(t.TempDir(), "index.json")})
+	waitIndexed(t, lib, root)
+
+	if err := os.MkdirAll(filepath.Join(root, "Movies", "New"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "Movies", "New", "film.mp4"), "media")
+	waitFor(t, "new file indexed", func() bool {
+		recent, err := lib.RecentlyAdded(1)
+		return err == nil && len(recent) == 1 && recent[0].Name == "film.mp4" && recent[0].Parent == "Movies/New"
+	})
+	if err := os.RemoveAll(filepath.Join(root, "Show")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "removed folder dropped", func() bool {
+		page, err := lib.Search("e01", "", 0)
+		return err == nil && len(page.Results) == 0
+	})
+}
+
+func TestIndexRescansWithoutWatcher(t *testing.T) {
+	root := t.TempDir()
+	lib, err := Open(Config{Roots: []string{root}, IndexFile: filepath.Join(t.TempDir(), "index.json"), Rescan: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lib.Close() })
+	waitIndexed(t, lib, root)
+	if watcher := lib.index.watcher; watcher != nil {
+		// Stand in for a network mount, which reports no changes.
+		_ = watcher.Remove(mustCanonical(t, root))
+	}
+	writeFile(t, filepath.Join(root, "late.mp3"), "media")
+	waitFor(t, "rescan", func() bool {
+		page, err := lib.Search("late", "", 0)
+		return err == nil && len(page.Results) == 1
+	})
+}
+
+func TestIndexDisabledAndUnreadable(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.mp4"), "media")
+	lib, _ := openTestLibrary(t, Config{Roots: []string{root}})
+	if lib.Indexed() {
+		t.Fatal("index enabled without a file")
+	}
+	if _, err := lib.RecentlyAdded(0); !errors.Is(err, ErrNoIndex) {
+		t.Fatalf("recently added error = %v", err)
+	}
+
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	writeFile(t, indexPath, `{"version":1,"roots":`)
+	indexed, _ := openTestLibrary(t, Config{Roots: []string{root}, IndexFile: indexPath})
+	waitIndexed(t, indexed, root)
+	if recent, err := indexed.RecentlyAdded(0); err != nil || len(recent) != 1 {
+		t.Fatalf("rebuilt index = %+v, %v", recent, err)
+	}
+}
+
+func waitIndexed(t *testing.T, lib *Library, root string) {
+	t.Helper()
+	canonical := mustCanonical(t, root)
+	waitFor(t, "initial index", func() bool {
+		lib.mu.Lock()
+		defer lib.mu.Unlock()
+		return lib.index.roots[canonical] != nil
+	})
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func mustCanonical(t *testing.T, root string) string {
+	t.Helper()
+	canonical, err := canonicalRoot(root)
+	if err != nil {
+		t.Fatalf("canonical %q: %v", root, err)
+	}
+	return canonical
+}
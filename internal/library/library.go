@@ -8,8 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -29,11 +31,21 @@ const (
 	tokenVersion = 1
 )
 
+// MaxFolderFiles bounds the media files Files returns, and folderScanPages
+// the directory entries it reads, in ScanCap units, while walking a whole
+// folder tree.
+const (
+	MaxFolderFiles  = 10000
+	folderScanPages = 10
+)
+
 var (
 	ErrClosed               = errors.New("library closed")
 	ErrCursorExpired        = errors.New("cursor_expired")
 	ErrCursorLimit          = errors.New("cursor limit reached")
+	ErrFolderLimit          = errors.New("folder has too many files")
 	ErrInvalidEntry         = errors.New("invalid entry")
+	ErrInvalidFilter        = errors.New("invalid filter")
 	ErrInvalidLimit         = errors.New("invalid limit")
 	ErrInvalidRoot          = errors.New("invalid root")
 	ErrNotDirectory         = errors.New("not a directory")
@@ -46,6 +58,17 @@ type Config struct {
 	CursorTTL time.Duration
 	ScanCap   int
 	Random    io.Reader
+	// Progress reports playback history for Browse. Nil leaves every file
+	// unplayed.
+	Progress ProgressFunc
+	// IndexFile keeps a listing of every root on disk, which Browse and
+	// Search read instead of the folders and RecentlyAdded requires. The
+	// listing follows changes as local roots report them and is rescanned
+	// every Rescan, DefaultRescan when zero. Empty disables the index.
+	IndexFile string
+	Rescan    time.Duration
+	// Probe reads the MediaInfo Details returns. Nil disables Details.
+	Probe ProbeFunc
 }
 
 type Root struct {
@@ -57,6 +80,51 @@ type Entry struct {
 	ID   string
 	Name string
 	Kind string
+	// Size is the length of a file in bytes.
+	Size     int64
+	Progress Progress
+	// Media is set when the file has been probed since it last changed.
+	Media *MediaInfo
+}
+
+// Progress is what playback history records about a file. Position is where
+// playback stopped part way, 0 when there is nothing to resume; Plays counts
+// plays to the end.
+type Progress struct {
+	Watched  bool
+	Position int
+	Duration int
+	Plays    int
+}
+
+// ProgressFunc looks up the Progress of the file at a location.
+type ProgressFunc func(Location) (Progress, bool)
+
+// Filter narrows the files Browse lists by their Progress. Directories are
+// always listed.
+type Filter string
+
+const (
+	FilterAll        Filter = ""
+	FilterWatched    Filter = "watched"
+	FilterUnwatched  Filter = "unwatched"
+	FilterInProgress Filter = "in_progress"
+)
+
+// Filters lists the accepted filters other than FilterAll.
+var Filters = []Filter{FilterWatched, FilterUnwatched, FilterInProgress}
+
+func (f Filter) match(progress Progress) bool {
+	switch f {
+	case FilterWatched:
+		return progress.Watched
+	case FilterUnwatched:
+		return !progress.Watched
+	case FilterInProgress:
+		return progress.Position > 0
+	default:
+		return true
+	}
 }
 
 type Page struct {
@@ -73,6 +141,13 @@ type Metadata struct {
 // AbsolutePath returns the canonical root joined with the validated entry path.
 func (m Metadata) AbsolutePath() string { return m.path }
 
+// Location identifies an entry across restarts, when root and entry IDs are
+// re-signed: the canonical root directory and the slash-separated path below it.
+type Location struct {
+	Root string `json:"root"`
+	Path string `json:"path"`
+}
+
 type rootState struct {
 	id        string
 	canonical string
@@ -83,10 +158,24 @@ type cursorState struct {
 	id       string
 	rootID   string
 	rel      string
+	filter   Filter
 	dir      *os.File
 	identity os.FileInfo
 	stamp    fileStamp
-	timer    *time.Timer
+	// listing replaces dir for a folder read from the index.
+	listing []indexEntry
+	offset  int
+	timer   *time.Timer
+}
+
+func (s *cursorState) close() error {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.dir == nil {
+		return nil
+	}
+	return s.dir.Close()
 }
 
 type fileStamp struct {
@@ -103,7 +192,14 @@ type Library struct {
 	random   io.Reader
 	ttl      time.Duration
 	scanCap  int
+	progress ProgressFunc
+	probe    ProbeFunc
+	probes   map[Location]probeRecord
+	probing  map[Location]*probeCall
+	slots    chan struct{}
 	cursors  map[string]*cursorState
+	searches map[string]*searchState
+	index    *index
 	closed   bool
 }
 
@@ -126,67 +222,145 @@ func Open(cfg Config) (*Library, error) {
 	if cfg.ScanCap < 1 {
 		return nil, ErrInvalidRoot
 	}
+	if cfg.Rescan == 0 {
+		cfg.Rescan = DefaultRescan
+	}
+	if cfg.Rescan < 0 {
+		return nil, ErrInvalidRoot
+	}
 
 	l := &Library{
-		roots:   make(map[string]*rootState, len(cfg.Roots)),
-		random:  cfg.Random,
-		ttl:     cfg.CursorTTL,
-		scanCap: cfg.ScanCap,
-		cursors: make(map[string]*cursorState),
+		random:   cfg.Random,
+		ttl:      cfg.CursorTTL,
+		scanCap:  cfg.ScanCap,
+		progress: cfg.Progress,
+		probe:    cfg.Probe,
+		probes:   make(map[Location]probeRecord),
+		probing:  make(map[Location]*probeCall),
+		slots:    make(chan struct{}, MaxConcurrentProbes),
+		cursors:  make(map[string]*cursorState),
+		searches: make(map[string]*searchState),
 	}
 	if _, err := io.ReadFull(l.random, l.secret[:]); err != nil {
 		return nil, fmt.Errorf("library secret: %w", err)
 	}
 
-	states := make([]*rootState, 0, len(cfg.Roots))
-	closeStates := func() {
-		for _, state := range states {
+	states, err := l.openRoots(cfg.Roots, nil)
+	if err != nil {
+		return nil, err
+	}
+	l.setRootsLocked(states)
+	if cfg.IndexFile != "" {
+		l.startIndex(cfg.IndexFile, cfg.Rescan)
+	}
+	return l, nil
+}
+
+// SetRoots replaces the configured roots. Roots present before and after keep
+// their IDs, so browse tokens and queued media stay valid; removed roots are
+// closed together with their cursors.
+func (l *Library) SetRoots(paths []string) error {
+	if len(paths) == 0 {
+		return ErrInvalidRoot
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	current := make(map[string]*rootState, len(l.roots))
+	for _, state := range l.roots {
+		current[state.canonical] = state
+	}
+	states, err := l.openRoots(paths, current)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for id, state := range l.roots {
+		if slices.Contains(states, state) {
+			continue
+		}
+		for cursorID, cursor := range l.cursors {
+			if cursor.rootID == id {
+				if err := l.closeCursorLocked(cursorID); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+		if err := state.handle.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// A search walks the roots it started with.
+	for _, search := range l.searches {
+		if err := l.closeSearchLocked(search); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	l.setRootsLocked(states)
+	if l.index != nil {
+		l.index.poke()
+	}
+	return errors.Join(errs...)
+}
+
+// openRoots resolves configured paths, reusing open states by canonical path.
+// On failure only the handles opened by this call are closed.
+func (l *Library) openRoots(paths []string, reuse map[string]*rootState) ([]*rootState, error) {
+	states := make([]*rootState, 0, len(paths))
+	var opened []*rootState
+	fail := func(err error) ([]*rootState, error) {
+		for _, state := range opened {
 			_ = state.handle.Close()
 		}
+		return nil, err
 	}
-	for _, configured := range cfg.Roots {
+	for _, configured := range paths {
 		canonical, err := canonicalRoot(configured)
 		if err != nil {
-			closeStates()
-			return nil, err
+			return fail(err)
 		}
-		handle, err := os.OpenRoot(canonical)
-		if err != nil {
-			closeStates()
-			return nil, fmt.Errorf("open media root: %w", ErrInvalidRoot)
+		state, reused := reuse[canonical]
+		if !reused {
+			handle, err := os.OpenRoot(canonical)
+			if err != nil {
+				return fail(fmt.Errorf("open media root: %w", ErrInvalidRoot))
+			}
+			id, err := l.randomID()
+			if err != nil {
+				_ = handle.Close()
+				return fail(err)
+			}
+			state = &rootState{id: id, canonical: canonical, handle: handle}
+			opened = append(opened, state)
 		}
-		info, err := handle.Stat(".")
+		info, err := state.handle.Stat(".")
 		if err != nil || !info.IsDir() {
-			_ = handle.Close()
-			closeStates()
-			return nil, ErrInvalidRoot
+			return fail(ErrInvalidRoot)
 		}
 		for _, existing := range states {
 			existingInfo, statErr := existing.handle.Stat(".")
 			if statErr != nil || os.SameFile(existingInfo, info) {
-				_ = handle.Close()
-				closeStates()
-				return nil, ErrInvalidRoot
+				return fail(ErrInvalidRoot)
 			}
 		}
-		id, err := l.randomID()
-		if err != nil {
-			_ = handle.Close()
-			closeStates()
-			return nil, err
-		}
-		state := &rootState{id: id, canonical: canonical, handle: handle}
 		states = append(states, state)
-		l.roots[id] = state
 	}
+	return states, nil
+}
+
+func (l *Library) setRootsLocked(states []*rootState) {
+	l.roots = make(map[string]*rootState, len(states))
 	paths := make([]string, len(states))
 	for index, state := range states {
+		l.roots[state.id] = state
 		paths[index] = state.canonical
 	}
+	l.rootList = l.rootList[:0:0]
 	for index, name := range rootDisplayNames(paths) {
 		l.rootList = append(l.rootList, Root{ID: states[index].id, Name: name})
 	}
-	return l, nil
 }
 
 func (l *Library) Roots() []Root {
@@ -208,17 +382,34 @@ func (l *Library) Close() error {
 			errs = append(errs, err)
 		}
 	}
+	for _, search := range l.searches {
+		if err := l.closeSearchLocked(search); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	for _, root := range l.roots {
 		if err := root.handle.Close(); err != nil {
 			errs = append(errs, err)
 		}
 	}
 	l.mu.Unlock()
+	if l.index != nil {
+		errs = append(errs, l.stopIndex())
+	}
 	return errors.Join(errs...)
 }
 
 // Browse implements GET /api/library's root_id, parent_id, cursor, and limit contract.
 func (l *Library) Browse(rootID, parentID, cursor string, limit int) (Page, error) {
+	return l.BrowseFiltered(rootID, parentID, cursor, limit, FilterAll)
+}
+
+// BrowseFiltered is Browse listing only the files that match filter. A cursor
+// continues the listing it was issued for, filter included.
+func (l *Library) BrowseFiltered(rootID, parentID, cursor string, limit int, filter Filter) (Page, error) {
+	if filter != FilterAll && !slices.Contains(Filters, filter) {
+		return Page{}, ErrInvalidFilter
+	}
 	if limit == 0 {
 		limit = DefaultLimit
 	}
@@ -247,13 +438,15 @@ func (l *Library) Browse(rootID, parentID, cursor string, limit int) (Page, erro
 	var state *cursorState
 	if cursor != "" {
 		state = l.cursors[cursor]
-		if state == nil || state.rootID != rootID || state.rel != rel {
+		if state == nil || state.rootID != rootID || state.rel != rel || state.filter != filter {
 			return Page{}, ErrCursorExpired
 		}
-		if !directoryUnchanged(root, state) {
+		if state.dir != nil && !directoryUnchanged(root, state) {
 			_ = l.closeCursorLocked(cursor)
 			return Page{}, ErrCursorExpired
 		}
+	} else if listing, ok := l.indexedListing(root, rel); ok {
+		state = &cursorState{rootID: rootID, rel: rel, filter: filter, listing: listing}
 	} else {
 		file, err := openRootFile(root.handle, rel)
 		if err != nil {
@@ -264,23 +457,30 @@ func (l *Library) Browse(rootID, parentID, cursor string, limit int) (Page, erro
 			_ = file.Close()
 			return Page{}, ErrNotDirectory
 		}
-		state = &cursorState{rootID: rootID, rel: rel, dir: file, identity: info, stamp: stamp(info)}
+		state = &cursorState{rootID: rootID, rel: rel, filter: filter, dir: file, identity: info, stamp: stamp(info)}
 	}
 
-	page, exhausted, err := l.readPage(root, state, limit)
+	var page Page
+	var exhausted bool
+	var err error
+	if state.dir == nil {
+		page, exhausted = l.readListing(root, state, limit)
+	} else {
+		page, exhausted, err = l.readPage(root, state, limit)
+	}
 	if err != nil {
 		if state.id != "" {
 			_ = l.closeCursorLocked(state.id)
 		} else {
-			_ = state.dir.Close()
+			_ = state.close()
 		}
 		return Page{}, err
 	}
-	if !directoryUnchanged(root, state) {
+	if state.dir != nil && !directoryUnchanged(root, state) {
 		if state.id != "" {
 			_ = l.closeCursorLocked(state.id)
 		} else {
-			_ = state.dir.Close()
+			_ = state.close()
 		}
 		return Page{}, ErrCursorExpired
 	}
@@ -288,18 +488,18 @@ func (l *Library) Browse(rootID, parentID, cursor string, limit int) (Page, erro
 		if state.id != "" {
 			_ = l.closeCursorLocked(state.id)
 		} else {
-			_ = state.dir.Close()
+			_ = state.close()
 		}
 		return page, nil
 	}
 	if state.id == "" {
-		if len(l.cursors) >= MaxCursors {
-			_ = state.dir.Close()
+		if len(l.cursors)+len(l.searches) >= MaxCursors {
+			_ = state.close()
 			return Page{}, ErrCursorLimit
 		}
 		id, err := l.randomID()
 		if err != nil {
-			_ = state.dir.Close()
+			_ = state.close()
 			return Page{}, err
 		}
 		state.id = id
@@ -310,6 +510,18 @@ func (l *Library) Browse(rootID, parentID, cursor string, limit int) (Page, erro
 	return page, nil
 }
 
+// CloseCursor releases a Browse or Search cursor before it expires, for
+// callers that stop paging early. Unknown cursors are ignored.
+func (l *Library) CloseCursor(cursor string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if state := l.searches[cursor]; state != nil {
+		_ = l.closeSearchLocked(state)
+		return
+	}
+	_ = l.closeCursorLocked(cursor)
+}
+
 func (l *Library) readPage(root *rootState, state *cursorState, limit int) (Page, bool, error) {
 	page := Page{Entries: make([]Entry, 0, limit)}
 	scanned := 0
@@ -319,7 +531,7 @@ func (l *Library) readPage(root *rootState, state *cursorState, limit int) (Page
 		scanned += len(entries)
 		for _, dirEntry := range entries {
 			entry, ok := l.entry(root, state.rel, dirEntry)
-			if ok {
+			if ok && (entry.Kind == "directory" || state.filter.match(entry.Progress)) {
 				page.Entries = append(page.Entries, entry)
 			}
 		}
@@ -359,11 +571,128 @@ func (l *Library) entry(root *rootState, parent string, dirEntry os.DirEntry) (E
 		}
 		kind = "file"
 	}
+	return l.newEntry(root, rel, kind, info.Size(), info.ModTime())
+}
+
+// newEntry builds the entry for rel. Size and modTime pick out a current
+// probe result for a file.
+func (l *Library) newEntry(root *rootState, rel, kind string, size int64, modTime time.Time) (Entry, bool) {
 	id, err := l.encodeEntry(root.id, rel)
 	if err != nil {
 		return Entry{}, false
 	}
-	return Entry{ID: id, Name: displayName(name), Kind: kind}, true
+	entry := Entry{ID: id, Name: displayName(filepath.Base(rel)), Kind: kind}
+	if kind != "file" {
+		return entry, true
+	}
+	entry.Size = size
+	location := Location{Root: root.canonical, Path: filepath.ToSlash(rel)}
+	if l.progress != nil {
+		entry.Progress, _ = l.progress(location)
+	}
+	entry.Media = l.probed(location, size, modTime)
+	return entry, true
+}
+
+// Files returns the location of the media file entryID names or, for a
+// directory, of every media file below it. A blank entryID is the root
+// directory. Hidden names are skipped and directory symlinks are not followed.
+// The walk runs without holding the library lock, reads folders ScanCap
+// entries at a time and gives up with ErrFolderLimit after folderScanPages
+// times ScanCap entries or MaxFolderFiles media files.
+func (l *Library) Files(rootID, entryID string) ([]Location, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, ErrClosed
+	}
+	root := l.roots[rootID]
+	if root == nil {
+		l.mu.Unlock()
+		return nil, ErrInvalidRoot
+	}
+	rel := "."
+	if entryID != "" {
+		var err error
+		if rel, err = l.decodeEntry(rootID, entryID); err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+	}
+	handle, canonical := root.handle, root.canonical
+	l.mu.Unlock()
+
+	files, err := l.walkFiles(handle, canonical, rel)
+	if errors.Is(err, fs.ErrClosed) {
+		// SetRoots or Close released the root during the walk.
+		return nil, ErrInvalidRoot
+	}
+	return files, err
+}
+
+func (l *Library) walkFiles(handle *os.Root, canonical, rel string) ([]Location, error) {
+	info, err := handle.Stat(rel)
+	if err != nil {
+		return nil, mapOpenError(err)
+	}
+	if !info.IsDir() {
+		if !info.Mode().IsRegular() || !supportedFile(rel) {
+			return nil, ErrNotRegular
+		}
+		return []Location{{Root: canonical, Path: filepath.ToSlash(rel)}}, nil
+	}
+	var files []Location
+	budget := folderScanPages * l.scanCap
+	pending := []string{rel}
+	for len(pending) != 0 {
+		dirRel := pending[0]
+		pending = pending[1:]
+		dir, err := openRootFile(handle, dirRel)
+		if err != nil {
+			if dirRel == rel {
+				return nil, mapOpenError(err)
+			}
+			// The folder went away since it was listed.
+			continue
+		}
+		for {
+			entries, err := dir.ReadDir(min(l.scanCap, budget+1))
+			budget -= len(entries)
+			if budget < 0 {
+				_ = dir.Close()
+				return nil, ErrFolderLimit
+			}
+			for _, dirEntry := range entries {
+				name := dirEntry.Name()
+				entryRel := filepath.Join(dirRel, name)
+				if hiddenName(name) || validateRelative(entryRel) != nil {
+					continue
+				}
+				if dirEntry.IsDir() {
+					pending = append(pending, entryRel)
+					continue
+				}
+				info, err := handle.Stat(entryRel)
+				if err != nil || !info.Mode().IsRegular() || !supportedFile(name) {
+					continue
+				}
+				if len(files) == MaxFolderFiles {
+					_ = dir.Close()
+					return nil, ErrFolderLimit
+				}
+				files = append(files, Location{Root: canonical, Path: filepath.ToSlash(entryRel)})
+			}
+			if errors.Is(err, io.EOF) || err == nil && len(entries) == 0 {
+				break
+			}
+			if err != nil {
+				_ = dir.Close()
+				return nil, fmt.Errorf("read directory: %w", err)
+			}
+		}
+		_ = dir.Close()
+	}
+	return files, nil
 }
 
 func (l *Library) OpenMedia(rootID, entryID string) (*os.File, Metadata, error) {
@@ -486,6 +815,134 @@ func (l *Library) openRelated(rootID, mediaID, extension string) (*os.File, Meta
 	return file, Metadata{Name: displayName(filepath.Base(rel)), Size: info.Size()}, nil
 }
 
+// Locate returns the restart-stable location of a signed entry.
+func (l *Library) Locate(rootID, entryID string) (Location, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return Location{}, ErrClosed
+	}
+	root := l.roots[rootID]
+	if root == nil {
+		return Location{}, ErrInvalidRoot
+	}
+	rel, err := l.decodeEntry(rootID, entryID)
+	if err != nil {
+		return Location{}, err
+	}
+	return Location{Root: root.canonical, Path: filepath.ToSlash(rel)}, nil
+}
+
+// Lookup returns the entry a signed ID names together with the ID of the
+// folder holding it, blank when that folder is the root.
+func (l *Library) Lookup(rootID, entryID string) (Entry, string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return Entry{}, "", ErrClosed
+	}
+	root := l.roots[rootID]
+	if root == nil {
+		return Entry{}, "", ErrInvalidRoot
+	}
+	rel, err := l.decodeEntry(rootID, entryID)
+	if err != nil {
+		return Entry{}, "", err
+	}
+	info, err := root.handle.Stat(rel)
+	if err != nil {
+		return Entry{}, "", mapOpenError(err)
+	}
+	kind := "directory"
+	if !info.IsDir() {
+		if !info.Mode().IsRegular() || !supportedFile(rel) {
+			return Entry{}, "", ErrNotRegular
+		}
+		kind = "file"
+	}
+	entry, ok := l.newEntry(root, rel, kind, info.Size(), info.ModTime())
+	if !ok {
+		return Entry{}, "", ErrInvalidEntry
+	}
+	var parentID string
+	if parent := filepath.Dir(rel); parent != "." {
+		if parentID, err = l.encodeEntry(rootID, parent); err != nil {
+			return Entry{}, "", err
+		}
+	}
+	return entry, parentID, nil
+}
+
+// Resolve signs a location for this process. It fails when the root is no
+// longer configured or the entry is no longer a supported regular file.
+func (l *Library) Resolve(location Location) (rootID, entryID string, err error) {
+	l.mu.Lock()
+	var root *rootState
+	for _, state := range l.roots {
+		if state.canonical == location.Root {
+			root = state
+		}
+	}
+	closed := l.closed
+	l.mu.Unlock()
+	if closed {
+		return "", "", ErrClosed
+	}
+	if root == nil {
+		return "", "", ErrInvalidRoot
+	}
+	if entryID, err = l.encodeEntry(root.id, filepath.FromSlash(location.Path)); err != nil {
+		return "", "", err
+	}
+	file, _, err := l.OpenMedia(root.id, entryID)
+	if err != nil {
+		return "", "", err
+	}
+	_ = file.Close()
+	return root.id, entryID, nil
+}
+
+// Find signs the media file at path. A relative path is tried against each
+// root in configuration order; an absolute path must lie inside a root.
+func (l *Library) Find(path string) (rootID, entryID string, err error) {
+	l.mu.Lock()
+	roots := make([]string, 0, len(l.rootList))
+	for _, root := range l.rootList {
+		roots = append(roots, l.roots[root.ID].canonical)
+	}
+	closed := l.closed
+	l.mu.Unlock()
+	if closed {
+		return "", "", ErrClosed
+	}
+	path = filepath.Clean(path)
+	candidates := []string{path}
+	if filepath.IsAbs(path) {
+		// Roots are canonical, so also try the path with its directory
+		// symlinks resolved.
+		if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil && dir != filepath.Dir(path) {
+			candidates = append(candidates, filepath.Join(dir, filepath.Base(path)))
+		}
+	}
+	for _, root := range roots {
+		for _, candidate := range candidates {
+			rel := candidate
+			if filepath.IsAbs(candidate) {
+				if rel, err = filepath.Rel(root, candidate); err != nil {
+					continue
+				}
+			}
+			if rel == "." || validateRelative(rel) != nil {
+				continue
+			}
+			if rootID, entryID, err = l.Resolve(Location{Root: root, Path: filepath.ToSlash(rel)}); err == nil {
+				return rootID, entryID, nil
+			}
+		}
+	}
+	return "", "", ErrInvalidEntry
+}
+
 func (l *Library) encodeEntry(rootID, rel string) (string, error) {
 	if err := validateRelative(rel); err != nil {
 		return "", err
@@ -540,10 +997,7 @@ func (l *Library) closeCursorLocked(id string) error {
 		return nil
 	}
 	delete(l.cursors, id)
-	if state.timer != nil {
-		state.timer.Stop()
-	}
-	return state.dir.Close()
+	return state.close()
 }
 
 func (l *Library) randomID() (string, error) {
@@ -158,7 +158,7 @@ func TestOpaqueInvalidUTF8Filename(t *testing.T) {
 		t.Fatal(err)
 	}
 	if len(page.Entries) != 1 || !strings.Contains(page.Entries[0].Name, "�") || !utf8.ValidString(page.Entries[0].Name) {
-		t.Fatalf("display name = %q", page.Entries)
+		t.Fatalf("display name = %+v", page.Entries)
 	}
 	file, _, err := lib.OpenMedia(rootID, page.Entries[0].ID)
 	if err != nil {
@@ -339,6 +339,31 @@ func TestCursorLimit(t *testing.T) {
 	if len(lib.cursors) != MaxCursors {
 		t.Fatalf("live cursors = %d", len(lib.cursors))
 	}
+
+	var cursor string
+	for id := range lib.cursors {
+		cursor = id
+		break
+	}
+	lib.CloseCursor(cursor)
+	if _, err := lib.Browse(rootID, rootPage.Entries[MaxCursors].ID, "", 1); err != nil {
+		t.Fatalf("browse after close: %v", err)
+	}
+	search, err := lib.Search("a", "", 1)
+	if !errors.Is(err, ErrCursorLimit) {
+		t.Fatalf("search at limit = %+v, %v", search, err)
+	}
+	lib.CloseCursor(cursor)
+	for id := range lib.cursors {
+		lib.CloseCursor(id)
+	}
+	if search, err = lib.Search("a", "", 1); err != nil || search.Cursor == "" {
+		t.Fatalf("search = %+v, %v", search, err)
+	}
+	lib.CloseCursor(search.Cursor)
+	if len(lib.cursors)+len(lib.searches) != 0 {
+		t.Fatalf("cursors left open = %d, %d", len(lib.cursors), len(lib.searches))
+	}
 }
 
 func TestFreshMediaHandlesAndRelatedContainment(t *testing.T) {
@@ -413,6 +438,236 @@ func TestCloseClosesCursorsAndRoots(t *testing.T) {
 	}
 }
 
+func TestSetRootsKeepsIDsAndClosesRemoved(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(first, "a.mp3"), "a")
+	writeFile(t, filepath.Join(first, "b.mp3"), "b")
+	lib, firstID := openTestLibrary(t, Config{Roots: []string{first}})
+	page, err := lib.Browse(firstID, "", "", 1)
+	if err != nil || page.Cursor == "" {
+		t.Fatalf("page = %+v, %v", page, err)
+	}
+	if err := lib.SetRoots([]string{second, first}); err != nil {
+		t.Fatal(err)
+	}
+	roots := lib.Roots()
+	if len(roots) != 2 || roots[1].ID != firstID || roots[0].ID == firstID {
+		t.Fatalf("roots = %+v", roots)
+	}
+	if _, err := lib.Browse(firstID, "", page.Cursor, 1); err != nil {
+		t.Fatalf("kept root cursor error = %v", err)
+	}
+	if err := lib.SetRoots([]string{second, second}); !errors.Is(err, ErrInvalidRoot) || len(lib.Roots()) != 2 {
+		t.Fatalf("duplicate SetRoots error = %v, roots = %+v", err, lib.Roots())
+	}
+	page, err = lib.Browse(firstID, "", "", 1)
+	if err != nil || page.Cursor == "" {
+		t.Fatalf("page = %+v, %v", page, err)
+	}
+	state := lib.cursors[page.Cursor]
+	if err := lib.SetRoots([]string{second}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.dir.ReadDir(1); err == nil {
+		t.Fatal("removed root cursor remains open")
+	}
+	if _, err := lib.Browse(firstID, "", "", 1); !errors.Is(err, ErrInvalidRoot) {
+		t.Fatalf("removed root browse error = %v", err)
+	}
+}
+
+func TestLocationSurvivesReopen(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "Shows"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "Shows", "pilot.mkv"), "video")
+	lib, rootID := openTestLibrary(t, Config{Roots: []string{root}})
+	folder := findEntry(t, mustBrowse(t, lib, rootID, ""), "Shows")
+	entry := findEntry(t, mustBrowse(t, lib, rootID, folder.ID), "pilot.mkv")
+	location, err := lib.Locate(rootID, entry.ID)
+	if err != nil || location.Path != "Shows/pilot.mkv" {
+		t.Fatalf("location = %+v, %v", location, err)
+	}
+
+	reopened, reopenedRoot := openTestLibrary(t, Config{Roots: []string{root}})
+	gotRoot, gotEntry, err := reopened.Resolve(location)
+	if err != nil || gotRoot != reopenedRoot {
+		t.Fatalf("resolve = %q %q, %v", gotRoot, gotEntry, err)
+	}
+	file, meta, err := reopened.OpenMedia(gotRoot, gotEntry)
+	if err != nil || meta.Name != "pilot.mkv" {
+		t.Fatalf("open resolved = %+v, %v", meta, err)
+	}
+	_ = file.Close()
+
+	if err := os.Remove(filepath.Join(root, "Shows", "pilot.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	for _, stale := range []Location{location, {Root: t.TempDir(), Path: location.Path}, {Root: location.Root, Path: "../escape.mkv"}} {
+		if _, _, err := reopened.Resolve(stale); err == nil {
+			t.Fatalf("stale location %+v resolved", stale)
+		}
+	}
+}
+
+func TestFindRelativeAndAbsolutePaths(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	if err := os.Mkdir(filepath.Join(second, "Music"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(second, "Music", "song.mp3"), "audio")
+	writeFile(t, filepath.Join(first, "notes.txt"), "text")
+	lib, err := Open(Config{Roots: []string{first, second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lib.Close() })
+	secondID := lib.Roots()[1].ID
+
+	for _, path := range []string{filepath.Join("Music", "song.mp3"), filepath.Join(second, "Music", "..", "Music", "song.mp3")} {
+		rootID, entryID, err := lib.Find(path)
+		if err != nil || rootID != secondID {
+			t.Fatalf("find %q = %q, %v", path, rootID, err)
+		}
+		if location, _ := lib.Locate(rootID, entryID); location.Path != "Music/song.mp3" {
+			t.Fatalf("find %q located %+v", path, location)
+		}
+	}
+	for _, path := range []string{"notes.txt", "../" + filepath.Base(second) + "/Music/song.mp3", filepath.Join(t.TempDir(), "song.mp3"), first} {
+		if rootID, _, err := lib.Find(path); err == nil {
+			t.Fatalf("find %q resolved in root %q", path, rootID)
+		}
+	}
+}
+
+func TestBrowseFilteredByProgress(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "Shows"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"seen.mkv", "half.mkv", "new.mkv"} {
+		writeFile(t, filepath.Join(root, name), "video")
+	}
+	progress := map[string]Progress{"seen.mkv": {Watched: true, Plays: 2}, "half.mkv": {Position: 300, Duration: 600}}
+	lib, rootID := openTestLibrary(t, Config{Roots: []string{root}, Progress: func(location Location) (Progress, bool) {
+		value, ok := progress[location.Path]
+		return value, ok
+	}})
+	if seen := findEntry(t, mustBrowse(t, lib, rootID, ""), "seen.mkv"); seen.Progress != progress["seen.mkv"] {
+		t.Fatalf("progress = %+v", seen.Progress)
+	}
+	for filter, want := range map[Filter][]string{
+		FilterWatched:    {"Shows", "seen.mkv"},
+		FilterUnwatched:  {"Shows", "half.mkv", "new.mkv"},
+		FilterInProgress: {"Shows", "half.mkv"},
+	} {
+		page, err := lib.BrowseFiltered(rootID, "", "", MaxLimit, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := entryNames(page.Entries)
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Fatalf("%s = %v, want %v", filter, got, want)
+		}
+	}
+	if _, err := lib.BrowseFiltered(rootID, "", "", MaxLimit, "recent"); !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("unknown filter = %v", err)
+	}
+	page, err := lib.BrowseFiltered(rootID, "", "", 1, FilterWatched)
+	if err != nil || page.Cursor == "" {
+		t.Fatalf("first page = %+v, %v", page, err)
+	}
+	if _, err := lib.BrowseFiltered(rootID, "", page.Cursor, 1, FilterUnwatched); !errors.Is(err, ErrCursorExpired) {
+		t.Fatalf("cursor reused with another filter = %v", err)
+	}
+}
+
+func TestFilesListsFolderTree(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"Shows", filepath.Join("Shows", "S01"), filepath.Join("Shows", ".trash")} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(root, "Shows", "pilot.mkv"), "video")
+	writeFile(t, filepath.Join(root, "Shows", "notes.txt"), "text")
+	writeFile(t, filepath.Join(root, "Shows", "S01", "e01.mkv"), "video")
+	writeFile(t, filepath.Join(root, "Shows", ".trash", "old.mkv"), "video")
+	writeFile(t, filepath.Join(root, "top.mp3"), "audio")
+	lib, rootID := openTestLibrary(t, Config{Roots: []string{root}})
+	folder := findEntry(t, mustBrowse(t, lib, rootID, ""), "Shows")
+
+	paths := func(entryID string) []string {
+		t.Helper()
+		files, err := lib.Files(rootID, entryID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		slices.Sort(paths)
+		return paths
+	}
+	if got := paths(folder.ID); !slices.Equal(got, []string{"Shows/S01/e01.mkv", "Shows/pilot.mkv"}) {
+		t.Fatalf("folder files = %v", got)
+	}
+	if got := paths(""); len(got) != 3 || got[len(got)-1] != "top.mp3" {
+		t.Fatalf("root files = %v", got)
+	}
+	pilot := findEntry(t, mustBrowse(t, lib, rootID, folder.ID), "pilot.mkv")
+	if got := paths(pilot.ID); !slices.Equal(got, []string{"Shows/pilot.mkv"}) {
+		t.Fatalf("file = %v", got)
+	}
+
+	// The walk reads at most folderScanPages times ScanCap entries.
+	capped, cappedID := openTestLibrary(t, Config{Roots: []string{root}, ScanCap: 1})
+	if files, err := capped.Files(cappedID, ""); err != nil || len(files) != 3 {
+		t.Fatalf("files within the scan cap = %v, %v", files, err)
+	}
+	for i := range folderScanPages {
+		writeFile(t, filepath.Join(root, "Shows", "S01", fmt.Sprintf("e%02d.nfo", i+2)), "text")
+	}
+	if _, err := capped.Files(cappedID, ""); !errors.Is(err, ErrFolderLimit) {
+		t.Fatalf("files beyond the scan cap = %v", err)
+	}
+}
+
+func TestLookupReturnsEntryAndParent(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "Shows"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "Shows", "pilot.mkv"), "video")
+	lib, rootID := openTestLibrary(t, Config{Roots: []string{root}})
+	folder := findEntry(t, mustBrowse(t, lib, rootID, ""), "Shows")
+	pilot := findEntry(t, mustBrowse(t, lib, rootID, folder.ID), "pilot.mkv")
+
+	entry, parentID, err := lib.Lookup(rootID, folder.ID)
+	if err != nil || entry.Kind != "directory" || entry.Name != "Shows" || parentID != "" {
+		t.Fatalf("folder lookup = %+v, %q, %v", entry, parentID, err)
+	}
+	entry, parentID, err = lib.Lookup(rootID, pilot.ID)
+	if err != nil || entry.Kind != "file" || entry.Size != 5 || parentID != folder.ID {
+		t.Fatalf("file lookup = %+v, %q, %v", entry, parentID, err)
+	}
+	if _, _, err := lib.Lookup(rootID, "bogus"); !errors.Is(err, ErrInvalidEntry) {
+		t.Fatalf("bad entry error = %v", err)
+	}
+}
+
+func mustBrowse(t *testing.T, lib *Library, rootID, parentID string) []Entry {
+	t.Helper()
+	page, err := lib.Browse(rootID, parentID, "", MaxLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page.Entries
+}
+
 func openTestLibrary(t *testing.T, cfg Config) (*Library, string) {
 	t.Helper()
 	lib, id := openTestLibraryNoCleanup(t, cfg)
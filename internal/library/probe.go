@@ -0,0 +1,140 @@
+package library
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// MaxProbes bounds the probe results kept. The oldest result is dropped to
+// make room for a new one. MaxConcurrentProbes bounds the probes running at
+// once; Details calls beyond it wait for a slot.
+const (
+	MaxProbes           = 20000
+	MaxConcurrentProbes = 2
+)
+
+var ErrNoProbe = errors.New("media probing disabled")
+
+// MediaInfo is what probing a media file found. Fields the probe could not
+// read are left zero.
+type MediaInfo struct {
+	Duration       float64  `json:"duration,omitempty"`
+	Width          int      `json:"width,omitempty"`
+	Height         int      `json:"height,omitempty"`
+	VideoCodec     string   `json:"video_codec,omitempty"`
+	AudioCodec     string   `json:"audio_codec,omitempty"`
+	AudioLanguages []string `json:"audio_languages,omitempty"`
+	Subtitles      []string `json:"subtitles,omitempty"`
+	Title          string   `json:"title,omitempty"`
+	Artist         string   `json:"artist,omitempty"`
+	Album          string   `json:"album,omitempty"`
+}
+
+// ProbeFunc reads the MediaInfo of an open media file.
+type ProbeFunc func(file *os.File, meta Metadata) (MediaInfo, error)
+
+// probeRecord is a probe result together with the size and modification time
+// the file had, so a changed file is probed again.
+type probeRecord struct {
+	Key     Location  `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Probed  time.Time `json:"probed"`
+	Info    MediaInfo `json:"info"`
+}
+
+// probeCall is a probe in progress. Details calls for the same file while it
+// runs wait for its result instead of probing again.
+type probeCall struct {
+	done chan struct{}
+	info MediaInfo
+	err  error
+}
+
+func (r probeRecord) matches(size int64, modTime time.Time) bool {
+	return r.Size == size && r.ModTime.Equal(modTime)
+}
+
+// Probing reports whether Details can probe files.
+func (l *Library) Probing() bool { return l.probe != nil }
+
+// Details returns the MediaInfo of the file entryID names. The file is probed
+// the first time and again after it changes; otherwise the stored result is
+// returned. Results are kept with the index when there is one.
+func (l *Library) Details(rootID, entryID string) (MediaInfo, error) {
+	if l.probe == nil {
+		return MediaInfo{}, ErrNoProbe
+	}
+	location, err := l.Locate(rootID, entryID)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	file, meta, err := l.openRegular(rootID, entryID)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return MediaInfo{}, ErrNotRegular
+	}
+
+	l.mu.Lock()
+	record, ok := l.probes[location]
+	if ok && record.matches(info.Size(), info.ModTime()) {
+		l.mu.Unlock()
+		return record.Info, nil
+	}
+	if call := l.probing[location]; call != nil {
+		l.mu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return MediaInfo{}, call.err
+		}
+		return call.info, nil
+	}
+	call := &probeCall{done: make(chan struct{})}
+	l.probing[location] = call
+	l.mu.Unlock()
+
+	l.slots <- struct{}{}
+	call.info, call.err = l.probe(file, meta)
+	<-l.slots
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.probing, location)
+	close(call.done)
+	if call.err != nil {
+		return MediaInfo{}, call.err
+	}
+	media := call.info
+	if l.closed {
+		return media, nil
+	}
+	if _, ok := l.probes[location]; !ok && len(l.probes) >= MaxProbes {
+		var oldest Location
+		var at time.Time
+		for key, record := range l.probes {
+			if at.IsZero() || record.Probed.Before(at) {
+				oldest, at = key, record.Probed
+			}
+		}
+		delete(l.probes, oldest)
+	}
+	l.probes[location] = probeRecord{Key: location, Size: info.Size(), ModTime: info.ModTime(), Probed: time.Now(), Info: media}
+	if l.index != nil {
+		l.index.dirtyMain = true
+	}
+	return media, nil
+}
+
+// probed returns the stored MediaInfo of a file if it is still current.
+func (l *Library) probed(location Location, size int64, modTime time.Time) *MediaInfo {
+	record, ok := l.probes[location]
+	if !ok || !record.matches(size, modTime) {
+		return nil
+	}
+	media := record.Info
+	return &media
+}
@@ -0,0 +1,139 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDetailsProbesOncePerFileStamp(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "film.mkv")
+	writeFile(t, path, "media")
+	probes := 0
+	probe := func(file *os.File, meta Metadata) (MediaInfo, error) {
+		probes++
+		if meta.AbsolutePath() != filepath.Join(mustCanonical(t, root), "film.mkv") {
+			t.Errorf("probed %q", meta.AbsolutePath())
+		}
+		return MediaInfo{Duration: float64(probes), VideoCodec: "h264", AudioLanguages: []string{"eng"}}, nil
+	}
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	lib, rootID := openTestLibraryNoCleanup(t, Config{Roots: []string{root}, IndexFile: indexPath, Probe: probe})
+	page, err := lib.Browse(rootID, "", "", 0)
+	if err != nil || len(page.Entries) != 1 || page.Entries[0].Media != nil {
+		t.Fatalf("unprobed page = %+v, %v", page, err)
+	}
+	id := page.Entries[0].ID
+	for range 2 {
+		media, err := lib.Details(rootID, id)
+		if err != nil || media.Duration != 1 || media.VideoCodec != "h264" {
+			t.Fatalf("details = %+v, %v", media, err)
+		}
+	}
+	if page, err = lib.Browse(rootID, "", "", 0); err != nil || page.Entries[0].Media == nil || page.Entries[0].Media.Duration != 1 {
+		t.Fatalf("probed page = %+v, %v", page, err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if page, err = lib.Browse(rootID, "", "", 0); err != nil || page.Entries[0].Media != nil {
+		t.Fatalf("changed page = %+v, %v", page, err)
+	}
+	if media, err := lib.Details(rootID, id); err != nil || media.Duration != 2 {
+		t.Fatalf("reprobed details = %+v, %v", media, err)
+	}
+	if _, err := lib.Details(rootID, "bogus"); !errors.Is(err, ErrInvalidEntry) {
+		t.Fatalf("bad entry error = %v", err)
+	}
+	if err := lib.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, rootID := openTestLibrary(t, Config{Roots: []string{root}, IndexFile: indexPath, Probe: probe})
+	page, err = reopened.Browse(rootID, "", "", 0)
+	if err != nil || len(page.Entries) != 1 {
+		t.Fatalf("reopened page = %+v, %v", page, err)
+	}
+	if media, err := reopened.Details(rootID, page.Entries[0].ID); err != nil || media.Duration != 2 || probes != 2 {
+		t.Fatalf("saved details = %+v, %v after %d probes", media, err, probes)
+	}
+}
+
+func TestDetailsWithoutProbe(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.mp4"), "media")
+	lib, rootID := openTestLibrary(t, Config{Roots: []string{root}})
+	page, err := lib.Browse(rootID, "", "", 0)
+	if err != nil || len(page.Entries) != 1 {
+		t.Fatalf("page = %+v, %v", page, err)
+	}
+	if lib.Probing() {
+		t.Fatal("probing without a probe")
+	}
+	if _, err := lib.Details(rootID, page.Entries[0].ID); !errors.Is(err, ErrNoProbe) {
+		t.Fatalf("details error = %v", err)
+	}
+}
+
+func TestDetailsSharesConcurrentProbes(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.mp4", "b.mp4", "c.mp4"} {
+		writeFile(t, filepath.Join(root, name), "media")
+	}
+	var (
+		mu             sync.Mutex
+		probes, active int
+		peak           int
+	)
+	release := make(chan struct{})
+	probe := func(file *os.File, meta Metadata) (MediaInfo, error) {
+		mu.Lock()
+		probes++
+		active++
+		peak = max(peak, active)
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return MediaInfo{Duration: 1}, nil
+	}
+	lib, rootID := openTestLibrary(t, Config{Roots: []string{root}, Probe: probe})
+	page, err := lib.Browse(rootID, "", "", 0)
+	if err != nil || len(page.Entries) != 3 {
+		t.Fatalf("page = %+v, %v", page, err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 9)
+	for _, entry := range page.Entries {
+		for range 3 {
+			wg.Go(func() {
+				media, err := lib.Details(rootID, entry.ID)
+				if err == nil && media.Duration != 1 {
+					err = fmt.Errorf("details = %+v", media)
+				}
+				errs <- err
+			})
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if probes != 3 || peak > MaxConcurrentProbes {
+		t.Fatalf("probes = %d, peak = %d", probes, peak)
+	}
+}
@@ -0,0 +1,240 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+)
+
+const (
+	// MaxQuery bounds a search query in bytes.
+	MaxQuery = 256
+	// MaxSearchDirs bounds the folders a search has found but not read yet.
+	// Folders found beyond it are not searched.
+	MaxSearchDirs = 10000
+)
+
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// SearchResult is a media file whose name matched a search. Parent is the
+// slash-separated folder below the root, empty for files at the top.
+type SearchResult struct {
+	RootID string
+	Parent string
+	Entry
+}
+
+type SearchPage struct {
+	Results []SearchResult
+	Cursor  string
+}
+
+// searchState walks the roots breadth first, holding only the directory being
+// read open.
+type searchState struct {
+	id      string
+	query   string
+	terms   []string
+	rootIDs []string
+	root    int
+	pending []string
+	rel     string
+	dir     *os.File
+	// indexed searches walk the index instead: tree is the listing of the
+	// root being read, and dirAt and itemAt the next folder and entry in it.
+	indexed bool
+	tree    *indexedRoot
+	dirAt   int
+	itemAt  int
+	timer   *time.Timer
+}
+
+func (s *searchState) close() error {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.dir == nil {
+		return nil
+	}
+	err := s.dir.Close()
+	s.dir = nil
+	return err
+}
+
+// Search lists the media files below every root whose names contain all the
+// space-separated words of query, ignoring case. A page reads at most ScanCap
+// directory entries, so it may hold fewer than limit results and still carry
+// a cursor. Search cursors count toward MaxCursors with Browse cursors.
+func (l *Library) Search(query, cursor string, limit int) (SearchPage, error) {
+	query = strings.TrimSpace(query)
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 || len(query) > MaxQuery {
+		return SearchPage{}, ErrInvalidQuery
+	}
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	if limit < 1 || limit > MaxLimit {
+		return SearchPage{}, ErrInvalidLimit
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return SearchPage{}, ErrClosed
+	}
+	var state *searchState
+	if cursor != "" {
+		state = l.searches[cursor]
+		if state == nil || state.query != query {
+			return SearchPage{}, ErrCursorExpired
+		}
+	} else {
+		state = &searchState{query: query, terms: terms, pending: []string{"."}}
+		for _, root := range l.rootList {
+			state.rootIDs = append(state.rootIDs, root.ID)
+		}
+		state.indexed = l.indexCovers()
+	}
+
+	var page SearchPage
+	var exhausted bool
+	var err error
+	if state.indexed {
+		page, exhausted = l.indexPage(state, limit)
+	} else {
+		page, exhausted, err = l.searchPage(state, limit)
+	}
+	if err != nil || exhausted {
+		_ = l.closeSearchLocked(state)
+		return page, err
+	}
+	if state.id == "" {
+		if len(l.cursors)+len(l.searches) >= MaxCursors {
+			_ = state.close()
+			return SearchPage{}, ErrCursorLimit
+		}
+		id, err := l.randomID()
+		if err != nil {
+			_ = state.close()
+			return SearchPage{}, err
+		}
+		state.id = id
+		state.timer = time.AfterFunc(l.ttl, func() { l.expireSearch(id) })
+		l.searches[id] = state
+	}
+	page.Cursor = state.id
+	return page, nil
+}
+
+func (l *Library) searchPage(state *searchState, limit int) (SearchPage, bool, error) {
+	page := SearchPage{Results: make([]SearchResult, 0, limit)}
+	scanned := 0
+	for len(page.Results) < limit && scanned < l.scanCap {
+		if state.root == len(state.rootIDs) {
+			return page, true, nil
+		}
+		root := l.roots[state.rootIDs[state.root]]
+		if state.dir == nil {
+			if len(state.pending) == 0 {
+				state.root++
+				state.pending = []string{"."}
+				continue
+			}
+			state.rel, state.pending = state.pending[0], state.pending[1:]
+			dir, err := openRootFile(root.handle, state.rel)
+			if err != nil {
+				// The folder went away or was swapped for something else
+				// since it was listed; the rest of the walk still holds.
+				continue
+			}
+			if info, err := dir.Stat(); err != nil || !info.IsDir() {
+				_ = dir.Close()
+				continue
+			}
+			state.dir = dir
+		}
+		entries, err := state.dir.ReadDir(min(limit-len(page.Results), l.scanCap-scanned, 32))
+		scanned += len(entries)
+		for _, dirEntry := range entries {
+			if result, ok := l.searchEntry(root, state, dirEntry); ok {
+				page.Results = append(page.Results, result)
+			}
+		}
+		if errors.Is(err, io.EOF) || err == nil && len(entries) == 0 {
+			_ = state.close()
+			continue
+		}
+		if err != nil {
+			return SearchPage{}, false, fmt.Errorf("read directory: %w", err)
+		}
+	}
+	return page, false, nil
+}
+
+// searchEntry queues a subfolder for reading and reports a matching media
+// file. Directory symlinks are not followed, so a link cannot lead the walk
+// around in circles.
+func (l *Library) searchEntry(root *rootState, state *searchState, dirEntry os.DirEntry) (SearchResult, bool) {
+	name := dirEntry.Name()
+	rel := name
+	if state.rel != "." {
+		rel = filepath.Join(state.rel, name)
+	}
+	if hiddenName(name) || validateRelative(rel) != nil {
+		return SearchResult{}, false
+	}
+	if dirEntry.Type()&fs.ModeSymlink == 0 && dirEntry.IsDir() {
+		if len(state.pending) < MaxSearchDirs {
+			state.pending = append(state.pending, rel)
+		}
+		return SearchResult{}, false
+	}
+	if mediamodel.KindForPath(name) == mediamodel.MediaKindUnknown || !matchTerms(strings.ToLower(displayName(name)), state.terms) {
+		return SearchResult{}, false
+	}
+	entry, ok := l.entry(root, state.rel, dirEntry)
+	if !ok || entry.Kind != "file" {
+		return SearchResult{}, false
+	}
+	return SearchResult{RootID: root.id, Parent: searchParent(state.rel), Entry: entry}, true
+}
+
+// searchParent is the Parent of a result in the folder rel.
+func searchParent(rel string) string {
+	if rel == "." {
+		return ""
+	}
+	return displayName(filepath.ToSlash(rel))
+}
+
+func matchTerms(name string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(name, term) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Library) expireSearch(id string) {
+	l.mu.Lock()
+	if state := l.searches[id]; state != nil {
+		_ = l.closeSearchLocked(state)
+	}
+	l.mu.Unlock()
+}
+
+func (l *Library) closeSearchLocked(state *searchState) error {
+	if state.id != "" {
+		delete(l.searches, state.id)
+	}
+	return state.close()
+}
@@ -0,0 +1,116 @@
+package library
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSearchAcrossRootsAndFolders(t *testing.T) {
+	shows, movies, outside := t.TempDir(), t.TempDir(), t.TempDir()
+	season := filepath.Join(shows, "Show", "Season 1")
+	for _, dir := range []string{season, filepath.Join(shows, ".trash")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{
+		filepath.Join(season, "Show S01E01.mkv"),
+		filepath.Join(season, "show s01e02.MP4"),
+		filepath.Join(season, "Show S01E01.srt"),
+		filepath.Join(shows, ".trash", "Show S01E03.mkv"),
+		filepath.Join(shows, "show notes.txt"),
+		filepath.Join(movies, "The Show Movie.mp4"),
+		filepath.Join(outside, "Show S09E09.mkv"),
+	} {
+		writeFile(t, path, "media")
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink(outside, filepath.Join(shows, "escape")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(shows, filepath.Join(season, "loop")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lib, err := Open(Config{Roots: []string{shows, movies}, ScanCap: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lib.Close() })
+	roots := lib.Roots()
+
+	search := func(query string) []SearchResult {
+		t.Helper()
+		var results []SearchResult
+		cursor := ""
+		for {
+			page, err := lib.Search(query, cursor, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, page.Results...)
+			if cursor = page.Cursor; cursor == "" {
+				return results
+			}
+		}
+	}
+	episodes := search("  SHOW s01 ")
+	names := make([]string, 0, len(episodes))
+	for _, result := range episodes {
+		names = append(names, result.Name)
+		if result.RootID != roots[0].ID || result.Parent != "Show/Season 1" || result.Kind != "file" {
+			t.Fatalf("result = %+v", result)
+		}
+	}
+	slices.Sort(names)
+	if strings.Join(names, ",") != "Show S01E01.mkv,show s01e02.MP4" {
+		t.Fatalf("episodes = %q", names)
+	}
+	file, _, err := lib.OpenMedia(episodes[0].RootID, episodes[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	all := search("show")
+	if len(all) != 3 || all[2].RootID != roots[1].ID || all[2].Name != "The Show Movie.mp4" || all[2].Parent != "" {
+		t.Fatalf("all roots = %+v", all)
+	}
+	if len(lib.searches) != 0 {
+		t.Fatalf("live searches = %d", len(lib.searches))
+	}
+}
+
+func TestSearchRejectsBadQueriesAndCursors(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a one.mp3", "a two.mp3", "a three.mp3"} {
+		writeFile(t, filepath.Join(root, name), name)
+	}
+	lib, _ := openTestLibrary(t, Config{Roots: []string{root}})
+	for _, query := range []string{"", "   ", strings.Repeat("a", MaxQuery+1)} {
+		if _, err := lib.Search(query, "", 0); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("query %q error = %v", query, err)
+		}
+	}
+	if _, err := lib.Search("a", "", MaxLimit+1); !errors.Is(err, ErrInvalidLimit) {
+		t.Fatalf("limit error = %v", err)
+	}
+	page, err := lib.Search("a", "", 1)
+	if err != nil || page.Cursor == "" {
+		t.Fatalf("page = %+v, %v", page, err)
+	}
+	if _, err := lib.Search("b", page.Cursor, 1); !errors.Is(err, ErrCursorExpired) {
+		t.Fatalf("other query cursor error = %v", err)
+	}
+	if err := lib.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lib.searches) != 0 {
+		t.Fatalf("searches left open = %d", len(lib.searches))
+	}
+}
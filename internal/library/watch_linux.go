@@ -0,0 +1,18 @@
+package library
+
+import "golang.org/x/sys/unix"
+
+// remoteFilesystem reports whether path is on a network or FUSE mount, where
+// inotify sees only the changes made through this machine.
+func remoteFilesystem(path string) bool {
+	var stat unix.Statfs_t
+	if unix.Statfs(path, &stat) != nil {
+		return false
+	}
+	switch uint32(stat.Type) {
+	case unix.NFS_SUPER_MAGIC, unix.SMB_SUPER_MAGIC, unix.SMB2_SUPER_MAGIC, unix.CIFS_SUPER_MAGIC, unix.FUSE_SUPER_MAGIC, unix.AFS_SUPER_MAGIC, unix.V9FS_MAGIC:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package library
+
+// remoteFilesystem reports false: outside Linux every root is watched and the
+// periodic rescan covers any changes the watcher cannot see.
+func remoteFilesystem(string) bool { return false }
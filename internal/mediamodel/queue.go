@@ -1,6 +1,9 @@
 package mediamodel
 
-import "slices"
+import (
+	"math/rand/v2"
+	"slices"
+)
 
 type Queue struct {
 	items        []QueueItem
@@ -69,6 +72,13 @@ func (q *Queue) IndexByPath(path string) int {
 	return slices.IndexFunc(q.items, func(item QueueItem) bool { return item.Path() == path })
 }
 
+func (q *Queue) IndexByID(id string) int {
+	if q == nil {
+		return -1
+	}
+	return slices.IndexFunc(q.items, func(item QueueItem) bool { return item.ID() == id })
+}
+
 func (q *Queue) SetCurrentByPath(path string) bool {
 	index := q.IndexByPath(path)
 	if index < 0 {
@@ -79,15 +89,52 @@ func (q *Queue) SetCurrentByPath(path string) bool {
 }
 
 func (q *Queue) AdjacentIndex(delta int, sameTypeOnly, wrap bool) int {
-	if q == nil || delta == 0 || q.currentIndex < 0 || q.currentIndex >= len(q.items) {
+	if q == nil {
 		return -1
 	}
+	sequence := make([]int, len(q.items))
+	for index := range sequence {
+		sequence[index] = index
+	}
+	return q.adjacent(sequence, delta, sameTypeOnly, wrap)
+}
 
+// AdjacentIndexInOrder is AdjacentIndex walking a ShuffleOrder instead of the
+// queue positions. Items missing from order follow it in queue order.
+func (q *Queue) AdjacentIndexInOrder(order []string, delta int, sameTypeOnly, wrap bool) int {
+	if q == nil {
+		return -1
+	}
+	positions := make(map[string]int, len(q.items))
+	for index, item := range q.items {
+		positions[item.ID()] = index
+	}
+	sequence := make([]int, 0, len(q.items))
+	for _, id := range order {
+		if index, ok := positions[id]; ok {
+			sequence = append(sequence, index)
+			delete(positions, id)
+		}
+	}
+	for index, item := range q.items {
+		if _, ok := positions[item.ID()]; ok {
+			sequence = append(sequence, index)
+		}
+	}
+	return q.adjacent(sequence, delta, sameTypeOnly, wrap)
+}
+
+func (q *Queue) adjacent(sequence []int, delta int, sameTypeOnly, wrap bool) int {
+	if delta == 0 || q.currentIndex < 0 || q.currentIndex >= len(q.items) {
+		return -1
+	}
+
+	current := slices.Index(sequence, q.currentIndex)
 	targetKind := q.items[q.currentIndex].MediaKind()
-	matches := func(index int) bool { return !sameTypeOnly || q.items[index].MediaKind() == targetKind }
-	for index := q.currentIndex + delta; index >= 0 && index < len(q.items); index += delta {
-		if matches(index) {
-			return index
+	matches := func(position int) bool { return !sameTypeOnly || q.items[sequence[position]].MediaKind() == targetKind }
+	for position := current + delta; position >= 0 && position < len(sequence); position += delta {
+		if matches(position) {
+			return sequence[position]
 		}
 	}
 	if !wrap {
@@ -95,21 +142,58 @@ func (q *Queue) AdjacentIndex(delta int, sameTypeOnly, wrap bool) int {
 	}
 
 	if delta > 0 {
-		for index := 0; index < q.currentIndex; index++ {
-			if matches(index) {
-				return index
+		for position := 0; position < current; position++ {
+			if matches(position) {
+				return sequence[position]
 			}
 		}
 	} else {
-		for index := len(q.items) - 1; index > q.currentIndex; index-- {
-			if matches(index) {
-				return index
+		for position := len(sequence) - 1; position > current; position-- {
+			if matches(position) {
+				return sequence[position]
 			}
 		}
 	}
 	return -1
 }
 
+// ShuffleOrder returns a random play order of the item IDs. IDs kept from
+// previous stay in their relative order, so queue edits never reshuffle it. A
+// fresh order starts at the current item, and items added later land at a
+// random position after the current one so they still play in this pass.
+func (q *Queue) ShuffleOrder(previous []string) []string {
+	if q == nil {
+		return nil
+	}
+	pending := make(map[string]bool, len(q.items))
+	for _, item := range q.items {
+		pending[item.ID()] = true
+	}
+	order := make([]string, 0, len(q.items))
+	for _, id := range previous {
+		if pending[id] {
+			order = append(order, id)
+			pending[id] = false
+		}
+	}
+	current, hasCurrent := q.Current()
+	if hasCurrent && len(order) == 0 {
+		order = append(order, current.ID())
+		pending[current.ID()] = false
+	}
+	start := 0
+	if hasCurrent {
+		start = slices.Index(order, current.ID()) + 1
+	}
+	for _, item := range q.items {
+		if pending[item.ID()] {
+			order = slices.Insert(order, start+rand.IntN(len(order)-start+1), item.ID())
+			pending[item.ID()] = false
+		}
+	}
+	return order
+}
+
 func (q *Queue) Move(index, delta int) int {
 	if q == nil || index < 0 || index >= len(q.items) {
 		return -1
@@ -1,6 +1,9 @@
 package mediamodel
 
-import "testing"
+import (
+	"slices"
+	"testing"
+)
 
 func testItems(t *testing.T, paths ...string) []QueueItem {
 	t.Helper()
@@ -123,3 +126,40 @@ func TestQueueIndexUsesFirstDuplicate(t *testing.T) {
 		t.Fatalf("index = %d, want 0", got)
 	}
 }
+
+func TestQueueShuffleOrderSurvivesEdits(t *testing.T) {
+	queue := NewQueue(testItems(t, "/tmp/a.mp4", "/tmp/b.mp4", "/tmp/c.mp4", "/tmp/d.mp4", "/tmp/e.mp4"), 2)
+	order := queue.ShuffleOrder(nil)
+	current, _ := queue.Current()
+	if len(order) != 5 || order[0] != current.ID() {
+		t.Fatalf("fresh order = %v, current %s", order, current.ID())
+	}
+	if got := queue.AdjacentIndexInOrder(order, 1, false, false); got != queue.IndexByID(order[1]) {
+		t.Fatalf("next = %d, want %s", got, order[1])
+	}
+	queue.SetCurrentIndex(queue.IndexByID(order[2]))
+	if got := queue.AdjacentIndexInOrder(order, -1, false, false); got != queue.IndexByID(order[1]) {
+		t.Fatalf("previous = %d, want %s", got, order[1])
+	}
+
+	queue.Move(0, 4)
+	removed, _ := queue.Remove(queue.IndexByID(order[3]))
+	added, _ := NewQueueItem("/tmp/f.mp4")
+	queue = NewQueue(append(queue.Items(), added), queue.CurrentIndex())
+	edited := queue.ShuffleOrder(order)
+	kept := slices.DeleteFunc(slices.Clone(edited), func(id string) bool { return id == added.ID() })
+	want := slices.DeleteFunc(slices.Clone(order), func(id string) bool { return id == removed.ID() })
+	if !slices.Equal(kept, want) {
+		t.Fatalf("edited order = %v, want %v plus %s", edited, want, added.ID())
+	}
+	if position := slices.Index(edited, added.ID()); position <= 2 {
+		t.Fatalf("added item placed at %d before current", position)
+	}
+	if got := queue.AdjacentIndexInOrder(edited, 1, false, false); got < 0 {
+		t.Fatal("next after edit not found")
+	}
+	queue.SetCurrentIndex(queue.IndexByID(edited[len(edited)-1]))
+	if queue.AdjacentIndexInOrder(edited, 1, false, false) != -1 || queue.AdjacentIndexInOrder(edited, 1, false, true) != queue.IndexByID(edited[0]) {
+		t.Fatal("shuffled end did not stop or wrap")
+	}
+}
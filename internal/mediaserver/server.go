@@ -18,6 +18,7 @@ import (
 	"time"
 	"unicode"
 
+	"go2tv.app/go2tv/v2/internal/metrics"
 	"go2tv.app/go2tv/v2/internal/playback"
 	"go2tv.app/go2tv/v2/utils"
 )
@@ -46,8 +47,26 @@ type activeRequest struct {
 	closer io.Closer
 }
 
+var servedBytes = metrics.NewCounter("go2tv_media_served_bytes_total", "Response bytes sent to renderers, by route purpose (media, subtitle, artwork).", "purpose")
+
+// countingWriter adds each write to servedBytes as it happens, so long
+// streams show up before they finish.
+type countingWriter struct {
+	http.ResponseWriter
+	purpose string
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	servedBytes.Add(float64(n), w.purpose)
+	return n, err
+}
+
+func (w countingWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
 type route struct {
 	id        string
+	purpose   string
 	path      string
 	mediaType string
 	open      playback.SourceOpener
@@ -55,6 +74,8 @@ type route struct {
 	contents  []byte
 	request   playback.ServerRequest
 	artwork   bool
+	// captionInfo is the subtitle URL sent in the CaptionInfo.sec header.
+	captionInfo string
 }
 
 // Server owns one renderer-facing listener and one playback session at a time.
@@ -149,6 +170,7 @@ func (s *Server) Start(ctx context.Context, request playback.ServerRequest) (pla
 			s.mu.Unlock()
 			return playback.MediaRoute{}, err
 		}
+		s.linkCaptionsLocked(mediaRoute, subtitleRoute)
 	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.serveHTTP)
@@ -276,6 +298,7 @@ func (s *Server) AddMedia(_ context.Context, request playback.ServerRequest) (pl
 			delete(s.byID, r.id)
 			return playback.MediaRoute{}, err
 		}
+		s.linkCaptionsLocked(r, subtitle)
 	}
 	base := "http://" + s.listener.Addr().String()
 	result := playback.MediaRoute{URL: base + r.path, ID: r.id}
@@ -356,12 +379,23 @@ func (s *Server) newSourceRouteLocked(purpose string, open playback.SourceOpener
 		return route{}, fmt.Errorf("route id: %w", err)
 	}
 	ext := safeExtension(extension)
-	r := route{id: id, path: "/renderer/" + s.session + "/" + purpose + "/" + token + ext, open: open, extension: ext, mediaType: mediaType, request: request}
+	r := route{id: id, purpose: purpose, path: "/renderer/" + s.session + "/" + purpose + "/" + token + ext, open: open, extension: ext, mediaType: mediaType, request: request}
 	s.routes[r.path] = r
 	s.byID[id] = r.path
 	return r, nil
 }
 
+// linkCaptionsLocked names subtitle in the CaptionInfo.sec header of media
+// when the renderer asked for it. Renderers that read the header only take
+// SRT subtitles from it.
+func (s *Server) linkCaptionsLocked(media, subtitle route) {
+	if !media.request.CaptionInfo || subtitle.extension != ".srt" {
+		return
+	}
+	media.captionInfo = "http://" + s.listener.Addr().String() + subtitle.path
+	s.routes[media.path] = media
+}
+
 func (s *Server) newBytesRouteLocked(purpose, mediaType string, contents []byte) (route, error) {
 	ext := extensionForType(mediaType)
 	path := ""
@@ -385,7 +419,7 @@ func (s *Server) newBytesRouteLocked(purpose, mediaType string, contents []byte)
 		s.byID[id] = path
 		return existing, nil
 	}
-	r := route{id: id, path: path, mediaType: mediaType, contents: append([]byte(nil), contents...), artwork: artwork}
+	r := route{id: id, purpose: purpose, path: path, mediaType: mediaType, contents: append([]byte(nil), contents...), artwork: artwork}
 	s.routes[path] = r
 	s.byID[id] = path
 	return r, nil
@@ -411,6 +445,7 @@ func (s *Server) serveHTTP(w http.ResponseWriter, request *http.Request) {
 		s.mu.Unlock()
 	}()
 
+	w = countingWriter{ResponseWriter: w, purpose: r.purpose}
 	switch request.Method {
 	case http.MethodGet, http.MethodHead:
 	default:
@@ -426,6 +461,10 @@ func (s *Server) serveHTTP(w http.ResponseWriter, request *http.Request) {
 	if r.request.Target.Protocol == "Chromecast" {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 	}
+	if r.captionInfo != "" {
+		// Set directly: the renderers that read it expect this exact case.
+		w.Header()["CaptionInfo.sec"] = []string{r.captionInfo}
+	}
 	if request.Header.Get("getcontentFeatures.dlna.org") == "1" {
 		seek := "00"
 		if !r.request.Transcode && r.open != nil {
@@ -228,6 +228,31 @@ func TestSRTSubtitleRegisteredBeforeReady(t *testing.T) {
 	}
 }
 
+func TestCaptionInfoHeaderNamesSRTSubtitle(t *testing.T) {
+	server := New(Config{ListenAddr: "127.0.0.1:0"})
+	route := startTestServer(t, server, playback.ServerRequest{
+		Media: byteOpener([]byte("media")), MediaExt: ".mp4", MediaType: "video/mp4",
+		Subtitle: byteOpener([]byte("subtitle")), SubtitleExt: ".srt", CaptionInfo: true,
+	})
+	next, err := server.AddMedia(context.Background(), playback.ServerRequest{
+		Media: byteOpener([]byte("next")), MediaExt: ".mp4", MediaType: "video/mp4",
+		Subtitle: byteOpener([]byte("subtitle")), SubtitleExt: ".vtt", CaptionInfo: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ url, want string }{{route.URL, route.SubtitleURL}, {next.URL, ""}} {
+		response, err := http.Head(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if got := response.Header.Get("CaptionInfo.sec"); got != tc.want {
+			t.Fatalf("CaptionInfo.sec = %q, want %q", got, tc.want)
+		}
+	}
+}
+
 func TestGETHEADMethodRangeAndCallbackCoexist(t *testing.T) {
 	payload := []byte("0123456789")
 	var callbacks atomic.Int32
@@ -0,0 +1,347 @@
+// Package metrics is a small registry that renders the Prometheus text
+// exposition format. Instruments live as package-level values in the packages
+// they measure and register on Default; values that are read from a running
+// server, such as device counts, are registered as gauge functions on a
+// registry owned by that server.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the Prometheus text format version 0.0.4.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets suits request latencies in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default holds the process-wide instruments.
+var Default = NewRegistry()
+
+// Sample is one labelled value of a gauge function. Labels are given in the
+// order the gauge declared them.
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+type family interface {
+	describe() (name, help, kind string)
+	write(w *bufio.Writer)
+}
+
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// register panics on a duplicate name; instruments are declared once at
+// package scope, so a clash is a programming error.
+func (r *Registry) register(f family) {
+	name, _, _ := f.describe()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[name]; ok {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.families[name] = f
+}
+
+// GaugeFunc registers a gauge whose samples are read from fn at each scrape.
+func (r *Registry) GaugeFunc(name, help string, labels []string, fn func() []Sample) {
+	r.register(&gaugeFunc{meta: meta{name: name, help: help, kind: "gauge", labels: labels}, fn: fn})
+}
+
+// WriteText renders every family of every registry, sorted by name within
+// each registry.
+func WriteText(w io.Writer, registries ...*Registry) error {
+	buffered := bufio.NewWriter(w)
+	for _, r := range registries {
+		r.mu.Lock()
+		names := make([]string, 0, len(r.families))
+		for name := range r.families {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		families := make([]family, 0, len(names))
+		for _, name := range names {
+			families = append(families, r.families[name])
+		}
+		r.mu.Unlock()
+		for _, f := range families {
+			name, help, kind := f.describe()
+			fmt.Fprintf(buffered, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind)
+			f.write(buffered)
+		}
+	}
+	return buffered.Flush()
+}
+
+// Handler serves the registries for a Prometheus scrape.
+func Handler(registries ...*Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		if r.Method == http.MethodHead {
+			return
+		}
+		_ = WriteText(w, registries...)
+	})
+}
+
+type meta struct {
+	name, help, kind string
+	labels           []string
+}
+
+func (m meta) describe() (string, string, string) { return m.name, m.help, m.kind }
+
+// series is one label combination of an instrument.
+type series struct {
+	values []string
+	value  float64
+	// Histogram state; counts are per bucket, not cumulative.
+	counts []uint64
+	sum    float64
+	total  uint64
+}
+
+type vector struct {
+	meta
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newVector(name, help, kind string, labels []string) *vector {
+	return &vector{meta: meta{name: name, help: help, kind: kind, labels: labels}, series: make(map[string]*series)}
+}
+
+// get returns the series for values, creating it. The caller holds v.mu.
+func (v *vector) get(values []string) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d labels, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: slices.Clone(values)}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted returns the series ordered by label values so scrapes are stable.
+// The caller holds v.mu.
+func (v *vector) sorted() []*series {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	out := make([]*series, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, v.series[key])
+	}
+	return out
+}
+
+func (v *vector) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, s := range v.sorted() {
+		writeSample(w, v.name, v.labels, s.values, "", "", s.value)
+	}
+}
+
+// Counter only goes up. With labels, each call names the label values in
+// declaration order.
+type Counter struct{ *vector }
+
+// NewCounter registers a counter on Default.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVector(name, help, "counter", labels)}
+	Default.register(c.vector)
+	return c
+}
+
+func (c *Counter) Inc(labels ...string) { c.Add(1, labels...) }
+
+// Add ignores negative deltas.
+func (c *Counter) Add(delta float64, labels ...string) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.get(labels).value += delta
+	c.mu.Unlock()
+}
+
+// Value reports the current count, mainly for tests.
+func (c *Counter) Value(labels ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.get(labels).value
+}
+
+// Gauge can go up and down.
+type Gauge struct{ *vector }
+
+// NewGauge registers a gauge on Default.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newVector(name, help, "gauge", labels)}
+	Default.register(g.vector)
+	return g
+}
+
+func (g *Gauge) Set(value float64, labels ...string) {
+	g.mu.Lock()
+	g.get(labels).value = value
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Add(delta float64, labels ...string) {
+	g.mu.Lock()
+	g.get(labels).value += delta
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Value(labels ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.get(labels).value
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	*vector
+	buckets []float64
+}
+
+// NewHistogram registers a histogram on Default. Nil buckets use
+// DefaultBuckets.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{vector: newVector(name, help, "histogram", labels), buckets: slices.Sorted(slices.Values(buckets))}
+	Default.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labels ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labels)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+	if index, _ := slices.BinarySearch(h.buckets, value); index < len(h.buckets) {
+		s.counts[index]++
+	}
+	s.sum += value
+	s.total++
+}
+
+// ObserveSince observes the seconds elapsed since start.
+func (h *Histogram) ObserveSince(start time.Time, labels ...string) {
+	h.Observe(time.Since(start).Seconds(), labels...)
+}
+
+// Count reports how many observations the series has, mainly for tests.
+func (h *Histogram) Count(labels ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.get(labels).total
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range h.sorted() {
+		var cumulative uint64
+		for index, bound := range h.buckets {
+			if s.counts != nil {
+				cumulative += s.counts[index]
+			}
+			writeSample(w, h.name+"_bucket", h.labels, s.values, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, s.values, "le", "+Inf", float64(s.total))
+		writeSample(w, h.name+"_sum", h.labels, s.values, "", "", s.sum)
+		writeSample(w, h.name+"_count", h.labels, s.values, "", "", float64(s.total))
+	}
+}
+
+type gaugeFunc struct {
+	meta
+	fn func() []Sample
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	samples := g.fn()
+	slices.SortFunc(samples, func(a, b Sample) int { return slices.Compare(a.Labels, b.Labels) })
+	for _, sample := range samples {
+		if len(sample.Labels) == len(g.labels) {
+			writeSample(w, g.name, g.labels, sample.Labels, "", "", sample.Value)
+		}
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labels) != 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for index, label := range labels {
+			if index > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabel(values[index]))
+		}
+		if extraLabel != "" {
+			if len(labels) != 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string   { return helpEscaper.Replace(help) }
+func escapeLabel(value string) string { return labelEscaper.Replace(value) }
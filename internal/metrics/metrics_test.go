@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	requests := NewCounter("test_requests_total", "Requests by \"action\".\nSecond line.", "action")
+	requests.Inc("Play")
+	requests.Add(2, `Se"ek`)
+	requests.Add(-1, "Play")
+	inflight := NewGauge("test_inflight", "In flight.")
+	inflight.Add(3)
+	inflight.Add(-1)
+	latency := NewHistogram("test_latency_seconds", "Latency.", []float64{1, 0.1})
+	latency.Observe(0.05)
+	latency.Observe(0.1)
+	latency.Observe(3)
+
+	local := NewRegistry()
+	local.GaugeFunc("test_devices", "Devices.", []string{"protocol"}, func() []Sample {
+		return []Sample{{Labels: []string{"DLNA"}, Value: 2}, {Labels: []string{"Chromecast"}, Value: 1}, {Value: 9}}
+	})
+
+	var out strings.Builder
+	if err := WriteText(&out, Default, local); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_inflight In flight.
+# TYPE test_inflight gauge
+test_inflight 2
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 2
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 3.15
+test_latency_seconds_count 3
+# HELP test_requests_total Requests by "action".\nSecond line.
+# TYPE test_requests_total counter
+test_requests_total{action="Play"} 1
+test_requests_total{action="Se\"ek"} 2
+# HELP test_devices Devices.
+# TYPE test_devices gauge
+test_devices{protocol="Chromecast"} 1
+test_devices{protocol="DLNA"} 2
+`
+	if !strings.Contains(out.String(), want) {
+		t.Fatalf("output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	recorder := httptest.NewRecorder()
+	Handler(local).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Header().Get("Content-Type") != ContentType || !strings.HasPrefix(recorder.Body.String(), "# HELP test_devices") {
+		t.Fatalf("handler = %v %q", recorder.Header(), recorder.Body.String())
+	}
+	recorder = httptest.NewRecorder()
+	Handler(local).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status = %d", recorder.Code)
+	}
+}
+
+func TestDuplicateNamePanics(t *testing.T) {
+	registry := NewRegistry()
+	registry.GaugeFunc("test_duplicate", "", nil, func() []Sample { return nil })
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate registration did not panic")
+		}
+	}()
+	registry.GaugeFunc("test_duplicate", "", nil, func() []Sample { return nil })
+}
@@ -0,0 +1,514 @@
+// Package mqtt bridges the server-mode controller to an MQTT broker. It
+// publishes playback state to retained topics, maps command topics onto
+// controller calls, and announces each discovered renderer to Home Assistant
+// through MQTT discovery. Like the webhook notifier, it follows the controller
+// through snapshots.
+package mqtt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+const (
+	DefaultPrefix          = "go2tv"
+	DefaultDiscoveryPrefix = "homeassistant"
+
+	defaultPollEvery  = 250 * time.Millisecond
+	defaultRetryEvery = 2 * time.Second
+	maxRetryEvery     = time.Minute
+	commandTimeout    = 30 * time.Second
+)
+
+// Command topic suffixes under <prefix>/command/.
+const (
+	CommandPlay         = "play"
+	CommandPause        = "pause"
+	CommandSeek         = "seek"
+	CommandVolume       = "volume"
+	CommandSelectDevice = "select_device"
+)
+
+// Controller is the part of *controller.Controller the bridge drives.
+type Controller interface {
+	Snapshot(context.Context) (controller.Snapshot, error)
+	Play(context.Context, controller.PlayRequest) controller.Result
+	Pause(context.Context, controller.Mutation) controller.Result
+	Resume(context.Context, controller.Mutation) controller.Result
+	Seek(context.Context, controller.SeekRequest) controller.Result
+	SetVolume(context.Context, controller.Mutation, int) controller.Result
+	SelectDevice(context.Context, controller.Mutation, string) controller.Result
+}
+
+type Config struct {
+	Controller Controller
+	Broker     Options
+	// Prefix roots the state and command topics. It defaults to "go2tv".
+	Prefix string
+	// DiscoveryPrefix is where Home Assistant listens for discovery
+	// payloads. It defaults to "homeassistant"; "-" turns discovery off.
+	DiscoveryPrefix string
+	// Logger receives connection and command logs. It is optional.
+	Logger controller.EventLogger
+	// PollEvery and RetryEvery default to 250ms and 2s. RetryEvery doubles
+	// after each failed connection attempt, up to a minute.
+	PollEvery  time.Duration
+	RetryEvery time.Duration
+}
+
+// State is the JSON document retained on <prefix>/state. The same values are
+// also retained one per topic under <prefix>/state/.
+type State struct {
+	State    string `json:"state"`
+	Position int    `json:"position"`
+	Duration int    `json:"duration"`
+	Media    string `json:"media"`
+	Volume   int    `json:"volume"`
+	Muted    bool   `json:"muted"`
+	DeviceID string `json:"device_id"`
+	Device   string `json:"device"`
+}
+
+// StateOf reduces a snapshot to the published state. State is the lowercased
+// controller playback state, or "idle" without a session.
+func StateOf(snapshot controller.Snapshot) State {
+	state := State{State: "idle", Volume: snapshot.Volume, Muted: snapshot.Muted}
+	if !snapshot.HasSession {
+		return state
+	}
+	if snapshot.PlaybackState != "" {
+		state.State = strings.ToLower(snapshot.PlaybackState)
+	}
+	state.Position, state.Duration = snapshot.Position, snapshot.Duration
+	state.Media = snapshot.ActiveMediaName
+	state.DeviceID = snapshot.ActiveDeviceID
+	for _, device := range snapshot.Devices {
+		if device.ID == snapshot.ActiveDeviceID {
+			state.Device = device.Name
+		}
+	}
+	return state
+}
+
+// Bridge keeps one broker connection alive, redialing with backoff.
+type Bridge struct {
+	cfg       Config
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+	// announced is every device with discovery configs on the broker. Unlike
+	// the per-session set it outlives reconnects, so a device that left while
+	// the broker was unreachable is still cleared. Only run touches it.
+	announced map[string]playback.Device
+}
+
+// New checks cfg and starts connecting in the background.
+func New(cfg Config) (*Bridge, error) {
+	if cfg.Controller == nil {
+		return nil, errors.New("mqtt controller is required")
+	}
+	if err := ValidateBroker(cfg.Broker.Broker); err != nil {
+		return nil, err
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultPrefix
+	}
+	cfg.Prefix = strings.TrimSuffix(cfg.Prefix, "/")
+	if cfg.DiscoveryPrefix == "" {
+		cfg.DiscoveryPrefix = DefaultDiscoveryPrefix
+	}
+	if strings.ContainsAny(cfg.Prefix+cfg.DiscoveryPrefix, "#+") {
+		return nil, errors.New("mqtt topic prefixes cannot contain wildcards")
+	}
+	if cfg.Broker.ClientID == "" {
+		cfg.Broker.ClientID = cfg.Prefix
+	}
+	cfg.Broker.Will = &Message{Topic: cfg.Prefix + "/availability", Payload: []byte("offline"), Retain: true}
+	if cfg.PollEvery <= 0 {
+		cfg.PollEvery = defaultPollEvery
+	}
+	if cfg.RetryEvery <= 0 {
+		cfg.RetryEvery = defaultRetryEvery
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bridge{cfg: cfg, ctx: ctx, cancel: cancel, done: make(chan struct{}), announced: make(map[string]playback.Device)}
+	go b.run()
+	return b, nil
+}
+
+// Close marks the bridge offline and disconnects.
+func (b *Bridge) Close() {
+	b.closeOnce.Do(func() {
+		b.cancel()
+		<-b.done
+	})
+}
+
+func (b *Bridge) run() {
+	defer close(b.done)
+	retry := b.cfg.RetryEvery
+	failing := false
+	for {
+		client, err := Dial(b.ctx, b.cfg.Broker)
+		if err == nil {
+			b.logInfo("MQTT connected to " + b.host())
+			retry, failing = b.cfg.RetryEvery, false
+			err = b.serve(client)
+			if b.ctx.Err() != nil {
+				return
+			}
+			b.logWarning("MQTT connection to " + b.host() + " lost: " + err.Error())
+		} else if b.ctx.Err() != nil {
+			return
+		} else if !failing {
+			// Log the first failure of an outage, not every retry.
+			b.logWarning("MQTT broker " + b.host() + " unavailable: " + err.Error())
+			failing = true
+		} else {
+			b.logDebug(fmt.Sprintf("MQTT broker %s unavailable, retrying in %s: %v", b.host(), retry, err))
+		}
+		timer := time.NewTimer(retry)
+		select {
+		case <-b.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if failing {
+			retry = min(retry*2, maxRetryEvery)
+		}
+	}
+}
+
+// host names the broker in logs without credentials.
+func (b *Bridge) host() string {
+	address, _, _, err := brokerAddress(b.cfg.Broker.Broker)
+	if err != nil {
+		return "mqtt"
+	}
+	return address
+}
+
+// session is the per-connection publishing state. Every retained topic is
+// republished after a reconnect, since the broker may have restarted.
+type session struct {
+	client    *Client
+	published map[string]string
+	announced map[string]playback.Device
+}
+
+func (b *Bridge) serve(client *Client) error {
+	defer client.Close()
+	s := &session{client: client, published: make(map[string]string), announced: make(map[string]playback.Device)}
+	if err := s.publish(b.availabilityTopic(), "online"); err != nil {
+		return err
+	}
+	if err := client.Subscribe(b.ctx, b.cfg.Prefix+"/command/+"); err != nil {
+		return err
+	}
+	commands := make(chan Message, 16)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		// Commands can block on renderer I/O, so they run apart from the
+		// state loop and broker reads.
+		defer wg.Done()
+		for message := range commands {
+			b.handle(message)
+		}
+	}()
+	defer wg.Wait()
+	defer close(commands)
+	ticker := time.NewTicker(b.cfg.PollEvery)
+	defer ticker.Stop()
+	if err := b.sync(s); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-b.ctx.Done():
+			// The will only fires on an unclean drop, so say goodbye here.
+			_ = s.publish(b.availabilityTopic(), "offline")
+			return b.ctx.Err()
+		case <-client.Done():
+			return client.Err()
+		case message, ok := <-client.Messages():
+			if !ok {
+				<-client.Done()
+				return client.Err()
+			}
+			select {
+			case commands <- message:
+			default:
+				b.logWarning("MQTT command dropped; earlier commands are still running")
+			}
+		case <-ticker.C:
+			if err := b.sync(s); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (b *Bridge) availabilityTopic() string { return b.cfg.Prefix + "/availability" }
+
+// sync publishes the state topics and discovery payloads that changed since
+// the last call.
+func (b *Bridge) sync(s *session) error {
+	snapshot, err := b.cfg.Controller.Snapshot(b.ctx)
+	if err != nil {
+		return nil
+	}
+	state := StateOf(snapshot)
+	document, _ := json.Marshal(state)
+	topics := [][2]string{
+		{"/state", string(document)},
+		{"/state/playback", state.State},
+		{"/state/position", strconv.Itoa(state.Position)},
+		{"/state/duration", strconv.Itoa(state.Duration)},
+		{"/state/media", state.Media},
+		{"/state/volume", strconv.Itoa(state.Volume)},
+		{"/state/muted", strconv.FormatBool(state.Muted)},
+		{"/state/device", state.Device},
+		{"/state/device_id", state.DeviceID},
+	}
+	for _, topic := range topics {
+		if err := s.publish(b.cfg.Prefix+topic[0], topic[1]); err != nil {
+			return err
+		}
+	}
+	if b.cfg.DiscoveryPrefix == "-" {
+		return nil
+	}
+	current := make(map[string]bool, len(snapshot.Devices))
+	for _, device := range snapshot.Devices {
+		current[device.ID] = true
+		if previous, ok := s.announced[device.ID]; ok && previous == device {
+			continue
+		}
+		for _, entity := range b.discovery(device) {
+			if err := s.publish(entity.topic, entity.payload); err != nil {
+				return err
+			}
+		}
+		s.announced[device.ID] = device
+		b.announced[device.ID] = device
+	}
+	for id, device := range b.announced {
+		if current[id] {
+			continue
+		}
+		// An empty retained config removes the entity from Home Assistant.
+		for _, entity := range b.discovery(device) {
+			if err := s.publish(entity.topic, ""); err != nil {
+				return err
+			}
+		}
+		delete(s.announced, id)
+		delete(b.announced, id)
+	}
+	return nil
+}
+
+// publish retains value on topic unless it is already the retained value.
+func (s *session) publish(topic, value string) error {
+	if previous, ok := s.published[topic]; ok && previous == value {
+		return nil
+	}
+	if err := s.client.Publish(Message{Topic: topic, Payload: []byte(value), Retain: true}); err != nil {
+		return err
+	}
+	s.published[topic] = value
+	return nil
+}
+
+type discoveryEntity struct {
+	topic   string
+	payload string
+}
+
+// NodeID is the Home Assistant node for device. Device IDs may be URLs, so
+// the node is derived from a hash of the ID.
+func NodeID(device playback.Device) string {
+	sum := sha256.Sum256([]byte(device.ID))
+	return "go2tv_" + hex.EncodeToString(sum[:6])
+}
+
+// discovery returns the Home Assistant entities for one renderer: its
+// playback state and media as sensors, play and pause buttons, and a volume
+// number. Pause and volume act on whichever device is playing; the sensors
+// and volume read as idle or empty while another device plays.
+func (b *Bridge) discovery(device playback.Device) []discoveryEntity {
+	node := NodeID(device)
+	quoted, _ := json.Marshal(device.ID)
+	// A JSON string is also a valid Jinja string literal.
+	active := "value_json.device_id == " + string(quoted)
+	stateTopic := b.cfg.Prefix + "/state"
+	common := func(object, name string) map[string]any {
+		return map[string]any{
+			"name":               name,
+			"unique_id":          node + "_" + object,
+			"object_id":          node + "_" + object,
+			"availability_topic": b.availabilityTopic(),
+			"device": map[string]any{
+				"identifiers":  []string{node},
+				"name":         device.Name,
+				"model":        device.Protocol,
+				"manufacturer": "go2tv",
+			},
+		}
+	}
+	entities := []struct {
+		component string
+		object    string
+		config    map[string]any
+	}{
+		{"sensor", "state", withFields(common("state", "Playback"), map[string]any{
+			"state_topic":    stateTopic,
+			"value_template": "{{ value_json.state if " + active + " else 'idle' }}",
+			"icon":           "mdi:cast",
+		})},
+		{"sensor", "media", withFields(common("media", "Media"), map[string]any{
+			"state_topic":    stateTopic,
+			"value_template": "{{ value_json.media if " + active + " else '' }}",
+			"icon":           "mdi:filmstrip",
+		})},
+		{"button", "play", withFields(common("play", "Play"), map[string]any{
+			"command_topic": b.cfg.Prefix + "/command/" + CommandPlay,
+			"payload_press": device.ID,
+			"icon":          "mdi:play",
+		})},
+		{"button", "pause", withFields(common("pause", "Pause"), map[string]any{
+			"command_topic": b.cfg.Prefix + "/command/" + CommandPause,
+			"payload_press": "",
+			"icon":          "mdi:pause",
+		})},
+		{"number", "volume", withFields(common("volume", "Volume"), map[string]any{
+			"state_topic":    stateTopic,
+			"value_template": "{{ value_json.volume if " + active + " else 0 }}",
+			"command_topic":  b.cfg.Prefix + "/command/" + CommandVolume,
+			"min":            0,
+			"max":            100,
+			"step":           1,
+			"icon":           "mdi:volume-high",
+		})},
+	}
+	out := make([]discoveryEntity, 0, len(entities))
+	for _, entity := range entities {
+		payload, _ := json.Marshal(entity.config)
+		out = append(out, discoveryEntity{
+			topic:   strings.Join([]string{b.cfg.DiscoveryPrefix, entity.component, node, entity.object, "config"}, "/"),
+			payload: string(payload),
+		})
+	}
+	return out
+}
+
+func withFields(base, fields map[string]any) map[string]any {
+	for key, value := range fields {
+		base[key] = value
+	}
+	return base
+}
+
+// handle runs one command message:
+//
+//	play           resume when paused, else play the selection; a device ID
+//	               payload selects that device first
+//	pause          pause
+//	seek           absolute position in seconds
+//	volume         0-100
+//	select_device  device ID
+func (b *Bridge) handle(message Message) {
+	command := strings.TrimPrefix(message.Topic, b.cfg.Prefix+"/command/")
+	payload := strings.TrimSpace(string(message.Payload))
+	ctx, cancel := context.WithTimeout(b.ctx, commandTimeout)
+	defer cancel()
+	var result controller.Result
+	switch command {
+	case CommandPlay:
+		result = b.play(ctx, payload)
+	case CommandPause:
+		result = b.cfg.Controller.Pause(ctx, controller.Mutation{})
+	case CommandSeek:
+		seconds, err := parseNumber(payload)
+		if err != nil {
+			b.logWarning(fmt.Sprintf("MQTT %s ignored: %q is not a number of seconds", command, payload))
+			return
+		}
+		result = b.cfg.Controller.Seek(ctx, controller.SeekRequest{Seconds: seconds})
+	case CommandVolume:
+		volume, err := parseNumber(payload)
+		if err != nil {
+			b.logWarning(fmt.Sprintf("MQTT %s ignored: %q is not a volume", command, payload))
+			return
+		}
+		result = b.cfg.Controller.SetVolume(ctx, controller.Mutation{}, volume)
+	case CommandSelectDevice:
+		result = b.cfg.Controller.SelectDevice(ctx, controller.Mutation{}, payload)
+	default:
+		b.logDebug("MQTT command ignored: unknown topic " + message.Topic)
+		return
+	}
+	if !result.OK() {
+		b.logWarning(fmt.Sprintf("MQTT %s failed: %s", command, result.Message))
+	}
+}
+
+func (b *Bridge) play(ctx context.Context, deviceID string) controller.Result {
+	snapshot, err := b.cfg.Controller.Snapshot(ctx)
+	if err != nil {
+		return controller.Result{Code: controller.CodeInternal, Message: err.Error()}
+	}
+	onDevice := deviceID == "" || deviceID == snapshot.ActiveDeviceID
+	if onDevice && snapshot.HasSession && snapshot.PlaybackState == controller.PlaybackStatePaused {
+		return b.cfg.Controller.Resume(ctx, controller.Mutation{})
+	}
+	if deviceID != "" && deviceID != snapshot.SelectedDeviceID {
+		if result := b.cfg.Controller.SelectDevice(ctx, controller.Mutation{}, deviceID); !result.OK() {
+			return result
+		}
+	}
+	return b.cfg.Controller.Play(ctx, controller.PlayRequest{})
+}
+
+// parseNumber accepts integers and the "42.0" form Home Assistant sends for
+// number entities.
+func parseNumber(value string) (int, error) {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, errors.New("not a number")
+	}
+	return int(math.Round(number)), nil
+}
+
+func (b *Bridge) logDebug(message string) {
+	if b.cfg.Logger != nil {
+		b.cfg.Logger.Debug(message)
+	}
+}
+
+func (b *Bridge) logInfo(message string) {
+	if b.cfg.Logger != nil {
+		b.cfg.Logger.Info(message)
+	}
+}
+
+func (b *Bridge) logWarning(message string) {
+	if b.cfg.Logger != nil {
+		b.cfg.Logger.Warning(message)
+	}
+}
@@ -0,0 +1,309 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultKeepAlive = 30 * time.Second
+	dialTimeout      = 10 * time.Second
+)
+
+var (
+	ErrInvalidBroker  = errors.New("invalid MQTT broker URL")
+	ErrConnectRefused = errors.New("MQTT broker refused the connection")
+	ErrClosed         = errors.New("MQTT connection closed")
+)
+
+// Message is one application message. Client only publishes at QoS 0.
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// Options configures one broker connection.
+type Options struct {
+	// Broker is mqtt://host[:1883] or mqtts://host[:8883]. Credentials in the
+	// URL are used when Username is empty.
+	Broker    string
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+	// Will is published by the broker if the connection drops without a
+	// DISCONNECT.
+	Will      *Message
+	TLSConfig *tls.Config
+}
+
+// brokerAddress returns the dial address and whether to use TLS.
+func brokerAddress(raw string) (string, bool, *url.Userinfo, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Hostname() == "" || (parsed.Path != "" && parsed.Path != "/") {
+		return "", false, nil, fmt.Errorf("%w: %q", ErrInvalidBroker, raw)
+	}
+	var secure bool
+	port := parsed.Port()
+	switch parsed.Scheme {
+	case "mqtt", "tcp":
+		if port == "" {
+			port = "1883"
+		}
+	case "mqtts", "ssl", "tls":
+		secure = true
+		if port == "" {
+			port = "8883"
+		}
+	default:
+		return "", false, nil, fmt.Errorf("%w: %q", ErrInvalidBroker, raw)
+	}
+	return net.JoinHostPort(parsed.Hostname(), port), secure, parsed.User, nil
+}
+
+// ValidateBroker checks that raw is a broker URL Dial accepts.
+func ValidateBroker(raw string) error {
+	_, _, _, err := brokerAddress(raw)
+	return err
+}
+
+// Client is a minimal MQTT 3.1.1 client: QoS 0 publish and subscribe with a
+// keep-alive ping. It does not reconnect; Bridge dials a new Client when
+// Done is closed.
+type Client struct {
+	conn      net.Conn
+	writeMu   sync.Mutex
+	messages  chan Message
+	suback    chan packet
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+	nextID    uint16
+	keepAlive time.Duration
+}
+
+// Dial connects and waits for the broker to accept the session.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	address, secure, user, err := brokerAddress(opts.Broker)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Username == "" && user != nil {
+		opts.Username = user.Username()
+		opts.Password, _ = user.Password()
+	}
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = defaultKeepAlive
+	}
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	if secure {
+		config := opts.TLSConfig
+		if config == nil {
+			config = &tls.Config{}
+		}
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: config}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newClient(ctx, conn, opts)
+}
+
+func newClient(ctx context.Context, conn net.Conn, opts Options) (*Client, error) {
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+	reader := bufio.NewReader(conn)
+	if err := writePacket(conn, connectPacket(opts, uint16(opts.KeepAlive/time.Second))); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	ack, err := readPacket(reader)
+	if err == nil && (ack.kind() != packetConnack || len(ack.body) != 2) {
+		err = errMalformedPacket
+	}
+	if err == nil && ack.body[1] != 0 {
+		err = fmt.Errorf("%w: %s", ErrConnectRefused, connackReason(ack.body[1]))
+	}
+	if err != nil {
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if !stop() {
+		// The context ended after CONNACK arrived but may have set the deadline.
+		_ = conn.Close()
+		return nil, ctx.Err()
+	}
+	c := &Client{
+		conn:      conn,
+		messages:  make(chan Message, 16),
+		suback:    make(chan packet, 1),
+		done:      make(chan struct{}),
+		keepAlive: opts.KeepAlive,
+	}
+	go c.read(reader)
+	go c.ping()
+	return c, nil
+}
+
+func connackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("code %d", code)
+}
+
+// Messages delivers publishes for the subscribed filters. It is closed when
+// the connection ends.
+func (c *Client) Messages() <-chan Message { return c.messages }
+
+// Done is closed when the connection ends; Err then reports why.
+func (c *Client) Done() <-chan struct{} { return c.done }
+
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Publish sends message at QoS 0.
+func (c *Client) Publish(message Message) error {
+	return c.write(publishPacket(message))
+}
+
+// Subscribe asks for QoS 0 delivery of filters and waits for the broker to
+// grant them.
+func (c *Client) Subscribe(ctx context.Context, filters ...string) error {
+	c.writeMu.Lock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1
+	}
+	id := c.nextID
+	c.writeMu.Unlock()
+	if err := c.write(subscribePacket(id, filters)); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return ErrClosed
+	case ack := <-c.suback:
+		if len(ack.body) != 2+len(filters) || binary.BigEndian.Uint16(ack.body) != id {
+			return errMalformedPacket
+		}
+		for index, code := range ack.body[2:] {
+			if code == 0x80 {
+				return fmt.Errorf("MQTT broker rejected subscription %q", filters[index])
+			}
+		}
+		return nil
+	}
+}
+
+// Close sends DISCONNECT, so the broker discards the will, and closes the
+// connection.
+func (c *Client) Close() error {
+	_ = c.write(packet{header: packetDisconnect})
+	c.shutdown(ErrClosed)
+	return nil
+}
+
+func (c *Client) write(p packet) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+	if err := writePacket(c.conn, p); err != nil {
+		go c.shutdown(err)
+		return err
+	}
+	return nil
+}
+
+func (c *Client) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		_ = c.conn.Close()
+		close(c.done)
+	})
+}
+
+func (c *Client) read(reader *bufio.Reader) {
+	defer close(c.messages)
+	for {
+		// Pings go out every half keep-alive, so a silent broker is gone.
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+		p, err := readPacket(reader)
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+		switch p.kind() {
+		case packetPublish:
+			message, id, err := parsePublish(p)
+			if err != nil {
+				c.shutdown(err)
+				return
+			}
+			if id != 0 {
+				_ = c.write(packet{header: packetPuback, body: binary.BigEndian.AppendUint16(nil, id)})
+			}
+			select {
+			case c.messages <- message:
+			case <-c.done:
+				return
+			}
+		case packetSuback:
+			select {
+			case c.suback <- p:
+			default:
+			}
+		case packetPingresp:
+		}
+	}
+}
+
+func (c *Client) ping() {
+	ticker := time.NewTicker(c.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			_ = c.write(packet{header: packetPingreq})
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+// broker is an in-process stand-in for an MQTT broker. It keeps retained
+// messages, answers pings and subscriptions, and lets the test publish to
+// connected clients.
+type broker struct {
+	t        *testing.T
+	listener net.Listener
+	refuse   byte
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	connects []connectInfo
+	retained map[string]string
+	changed  chan struct{}
+}
+
+type connectInfo struct {
+	clientID, username, password, willTopic string
+}
+
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{t: t, listener: listener, retained: make(map[string]string), changed: make(chan struct{}, 1)}
+	go b.accept(listener)
+	t.Cleanup(func() {
+		_ = listener.Close()
+		b.dropAll()
+	})
+	return b
+}
+
+// relisten accepts connections again on the address of a closed listener.
+func (b *broker) relisten() {
+	b.t.Helper()
+	b.mu.Lock()
+	address := b.listener.Addr().String()
+	b.mu.Unlock()
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	b.mu.Lock()
+	b.listener = listener
+	b.mu.Unlock()
+	go b.accept(listener)
+	b.t.Cleanup(func() { _ = listener.Close() })
+}
+
+func (b *broker) url() string { return "mqtt://" + b.listener.Addr().String() }
+
+func (b *broker) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.conns = append(b.conns, conn)
+		b.mu.Unlock()
+		go b.serve(conn)
+	}
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		p, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+		switch p.kind() {
+		case packetConnect:
+			info, err := parseConnect(p)
+			if err != nil {
+				b.t.Errorf("connect: %v", err)
+				return
+			}
+			b.mu.Lock()
+			b.connects = append(b.connects, info)
+			b.mu.Unlock()
+			_ = writePacket(conn, packet{header: packetConnack, body: []byte{0, b.refuse}})
+			b.notify()
+		case packetPublish:
+			message, _, err := parsePublish(p)
+			if err != nil {
+				b.t.Errorf("publish: %v", err)
+				return
+			}
+			if message.Retain {
+				b.mu.Lock()
+				if len(message.Payload) == 0 {
+					delete(b.retained, message.Topic)
+				} else {
+					b.retained[message.Topic] = string(message.Payload)
+				}
+				b.mu.Unlock()
+				b.notify()
+			}
+		case packetSubscribe:
+			count := 0
+			for rest := p.body[2:]; len(rest) != 0; count++ {
+				_, rest, err = readString(rest)
+				if err != nil || len(rest) == 0 {
+					b.t.Errorf("subscribe: %v", err)
+					return
+				}
+				rest = rest[1:]
+			}
+			_ = writePacket(conn, packet{header: packetSuback, body: append(slices.Clone(p.body[:2]), make([]byte, count)...)})
+		case packetPingreq:
+			_ = writePacket(conn, packet{header: packetPingresp})
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+func parseConnect(p packet) (connectInfo, error) {
+	protocol, rest, err := readString(p.body)
+	if err != nil || protocol != "MQTT" || len(rest) < 4 || rest[0] != protocolLevel311 {
+		return connectInfo{}, errMalformedPacket
+	}
+	flags := rest[1]
+	rest = rest[4:]
+	var info connectInfo
+	if info.clientID, rest, err = readString(rest); err != nil {
+		return connectInfo{}, err
+	}
+	if flags&0x04 != 0 {
+		if info.willTopic, rest, err = readString(rest); err != nil {
+			return connectInfo{}, err
+		}
+		if _, rest, err = readString(rest); err != nil {
+			return connectInfo{}, err
+		}
+	}
+	if flags&0x80 != 0 {
+		if info.username, rest, err = readString(rest); err != nil {
+			return connectInfo{}, err
+		}
+	}
+	if flags&0x40 != 0 {
+		if info.password, _, err = readString(rest); err != nil {
+			return connectInfo{}, err
+		}
+	}
+	return info, nil
+}
+
+func (b *broker) notify() {
+	select {
+	case b.changed <- struct{}{}:
+	default:
+	}
+}
+
+// send publishes to every connected client.
+func (b *broker) send(topic, payload string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		_ = writePacket(conn, publishPacket(Message{Topic: topic, Payload: []byte(payload)}))
+	}
+}
+
+func (b *broker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		_ = conn.Close()
+	}
+	b.conns = nil
+}
+
+// waitFor polls until check holds under the broker lock.
+func (b *broker) waitFor(what string, check func() bool) {
+	b.t.Helper()
+	deadline := time.After(2 * time.Second)
+	for {
+		b.mu.Lock()
+		ok := check()
+		b.mu.Unlock()
+		if ok {
+			return
+		}
+		select {
+		case <-b.changed:
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.t.Fatalf("timed out waiting for %s; retained = %v", what, b.retained)
+		}
+	}
+}
+
+func (b *broker) waitRetained(topic, want string) {
+	b.t.Helper()
+	b.waitFor(topic+" = "+want, func() bool { return b.retained[topic] == want })
+}
+
+type call struct {
+	name string
+	arg  any
+}
+
+type fakeController struct {
+	mu       sync.Mutex
+	snapshot controller.Snapshot
+	calls    []call
+}
+
+func (c *fakeController) Snapshot(context.Context) (controller.Snapshot, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.snapshot, nil
+}
+
+func (c *fakeController) set(snapshot controller.Snapshot) {
+	c.mu.Lock()
+	c.snapshot = snapshot
+	c.mu.Unlock()
+}
+
+func (c *fakeController) record(name string, arg any) controller.Result {
+	c.mu.Lock()
+	c.calls = append(c.calls, call{name: name, arg: arg})
+	c.mu.Unlock()
+	return controller.Result{}
+}
+
+func (c *fakeController) Play(_ context.Context, request controller.PlayRequest) controller.Result {
+	return c.record("Play", request.QueueItemID)
+}
+func (c *fakeController) Pause(context.Context, controller.Mutation) controller.Result {
+	return c.record("Pause", nil)
+}
+func (c *fakeController) Resume(context.Context, controller.Mutation) controller.Result {
+	return c.record("Resume", nil)
+}
+func (c *fakeController) Seek(_ context.Context, request controller.SeekRequest) controller.Result {
+	return c.record("Seek", request.Seconds)
+}
+func (c *fakeController) SetVolume(_ context.Context, _ controller.Mutation, volume int) controller.Result {
+	return c.record("SetVolume", volume)
+}
+func (c *fakeController) SelectDevice(_ context.Context, _ controller.Mutation, id string) controller.Result {
+	return c.record("SelectDevice", id)
+}
+
+func (c *fakeController) waitCalls(t *testing.T, want int) []call {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.mu.Lock()
+		calls := slices.Clone(c.calls)
+		c.mu.Unlock()
+		if len(calls) >= want {
+			return calls
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("calls = %v, want %d", calls, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+var (
+	tv      = playback.Device{ID: "http://192.168.1.20:8080/desc.xml", Name: "Living room", Protocol: "DLNA"}
+	speaker = playback.Device{ID: "chromecast-1", Name: "Kitchen", Protocol: "Chromecast"}
+)
+
+func playingOn(device playback.Device, state string) controller.Snapshot {
+	return controller.Snapshot{
+		Revision:         1,
+		Devices:          []playback.Device{tv, speaker},
+		SelectedDeviceID: device.ID,
+		ActiveDeviceID:   device.ID,
+		ActiveMediaName:  "movie.mp4",
+		HasSession:       true,
+		PlaybackState:    state,
+		Position:         12,
+		Duration:         300,
+		Volume:           40,
+	}
+}
+
+func startBridge(t *testing.T, b *broker, control Controller) *Bridge {
+	t.Helper()
+	bridge, err := New(Config{
+		Controller: control,
+		Broker:     Options{Broker: b.url(), ClientID: "go2tv-test"},
+		PollEvery:  5 * time.Millisecond,
+		RetryEvery: 5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bridge.Close)
+	return bridge
+}
+
+func TestBridgePublishesStateAndDiscovery(t *testing.T) {
+	b := newBroker(t)
+	control := &fakeController{snapshot: playingOn(tv, controller.PlaybackStatePlaying)}
+	bridge := startBridge(t, b, control)
+
+	b.waitRetained("go2tv/availability", "online")
+	b.waitRetained("go2tv/state/playback", "playing")
+	b.waitRetained("go2tv/state/device", tv.Name)
+	b.waitRetained("go2tv/state/position", "12")
+	b.mu.Lock()
+	var state State
+	err := json.Unmarshal([]byte(b.retained["go2tv/state"]), &state)
+	connect := b.connects[0]
+	b.mu.Unlock()
+	if err != nil || state != StateOf(control.snapshot) || state.Media != "movie.mp4" || state.DeviceID != tv.ID || state.Volume != 40 {
+		t.Fatalf("state = %#v, %v", state, err)
+	}
+	if connect.clientID != "go2tv-test" || connect.willTopic != "go2tv/availability" {
+		t.Fatalf("connect = %#v", connect)
+	}
+
+	node := NodeID(tv)
+	for _, topic := range []string{"sensor/" + node + "/state", "sensor/" + node + "/media", "button/" + node + "/play", "button/" + node + "/pause", "number/" + node + "/volume"} {
+		b.waitFor(topic, func() bool { return b.retained["homeassistant/"+topic+"/config"] != "" })
+	}
+	b.mu.Lock()
+	var play map[string]any
+	err = json.Unmarshal([]byte(b.retained["homeassistant/button/"+node+"/play/config"]), &play)
+	b.mu.Unlock()
+	device, _ := play["device"].(map[string]any)
+	if err != nil || play["command_topic"] != "go2tv/command/play" || play["payload_press"] != tv.ID || device["name"] != tv.Name || play["availability_topic"] != "go2tv/availability" {
+		t.Fatalf("play config = %v, %v", play, err)
+	}
+
+	// A renderer leaving discovery clears its retained configs.
+	idle := controller.Snapshot{Devices: []playback.Device{speaker}, Volume: 40}
+	control.set(idle)
+	b.waitRetained("go2tv/state/playback", "idle")
+	b.waitFor("tv configs removed", func() bool {
+		for topic := range b.retained {
+			if strings.Contains(topic, node) {
+				return false
+			}
+		}
+		return b.retained["homeassistant/button/"+NodeID(speaker)+"/play/config"] != ""
+	})
+
+	bridge.Close()
+	b.waitRetained("go2tv/availability", "offline")
+}
+
+func TestBridgeCommands(t *testing.T) {
+	b := newBroker(t)
+	control := &fakeController{snapshot: playingOn(tv, controller.PlaybackStatePaused)}
+	startBridge(t, b, control)
+	b.waitRetained("go2tv/state/playback", "paused")
+
+	// Commands run one at a time in arrival order.
+	b.send("go2tv/command/pause", "")
+	b.send("go2tv/command/seek", "42")
+	b.send("go2tv/command/volume", "35.0")
+	b.send("go2tv/command/volume", "loud")
+	b.send("go2tv/command/select_device", speaker.ID)
+	b.send("go2tv/command/play", tv.ID)
+	b.send("go2tv/command/play", speaker.ID)
+	b.send("go2tv/command/stop", "")
+	got := control.waitCalls(t, 7)
+	want := []call{
+		{"Pause", nil},
+		{"Seek", 42},
+		{"SetVolume", 35},
+		{"SelectDevice", speaker.ID},
+		{"Resume", nil},
+		{"SelectDevice", speaker.ID},
+		{"Play", ""},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestBridgeReconnects(t *testing.T) {
+	b := newBroker(t)
+	control := &fakeController{snapshot: playingOn(tv, controller.PlaybackStatePlaying)}
+	startBridge(t, b, control)
+	b.waitRetained("go2tv/state/playback", "playing")
+
+	b.dropAll()
+	b.mu.Lock()
+	b.retained = make(map[string]string)
+	b.mu.Unlock()
+	b.waitFor("second connection", func() bool { return len(b.connects) == 2 })
+	// The new session republishes everything, even unchanged state.
+	b.waitRetained("go2tv/availability", "online")
+	b.waitRetained("go2tv/state/playback", "playing")
+
+	// A renderer that leaves while the broker is unreachable loses its
+	// retained configs once the bridge is back.
+	node := NodeID(tv)
+	b.waitFor("tv configs", func() bool { return b.retained["homeassistant/button/"+node+"/play/config"] != "" })
+	b.mu.Lock()
+	_ = b.listener.Close()
+	for _, conn := range b.conns {
+		_ = conn.Close()
+	}
+	b.conns = nil
+	b.mu.Unlock()
+	control.set(controller.Snapshot{Devices: []playback.Device{speaker}, Volume: 40})
+	b.relisten()
+	b.waitFor("tv configs removed", func() bool {
+		for topic := range b.retained {
+			if strings.Contains(topic, node) {
+				return false
+			}
+		}
+		return len(b.connects) == 3
+	})
+}
+
+func TestDialCredentialsAndRefusal(t *testing.T) {
+	b := newBroker(t)
+	client, err := Dial(context.Background(), Options{Broker: "mqtt://user:pa%20ss@" + b.listener.Addr().String(), ClientID: "cli", KeepAlive: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Subscribe(context.Background(), "a/+", "b/#"); err != nil {
+		t.Fatal(err)
+	}
+	// Several keep-alive periods pass without the broker dropping the client.
+	time.Sleep(60 * time.Millisecond)
+	if err := client.Publish(Message{Topic: "a/b", Payload: []byte("x"), Retain: true}); err != nil {
+		t.Fatal(err)
+	}
+	b.waitRetained("a/b", "x")
+	b.mu.Lock()
+	connect := b.connects[0]
+	b.refuse = 5
+	b.mu.Unlock()
+	if connect.username != "user" || connect.password != "pa ss" || connect.willTopic != "" {
+		t.Fatalf("connect = %#v", connect)
+	}
+	b.send("a/c", "hello")
+	select {
+	case message := <-client.Messages():
+		if message.Topic != "a/c" || string(message.Payload) != "hello" {
+			t.Fatalf("message = %#v", message)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not delivered")
+	}
+	_ = client.Close()
+	<-client.Done()
+
+	if _, err := Dial(context.Background(), Options{Broker: b.url()}); !errors.Is(err, ErrConnectRefused) {
+		t.Fatalf("refused dial: %v", err)
+	}
+	for _, broker := range []string{"http://host", "mqtt://", "mqtt://host/topic"} {
+		if err := ValidateBroker(broker); !errors.Is(err, ErrInvalidBroker) {
+			t.Fatalf("%q: %v", broker, err)
+		}
+	}
+}
+
+func TestRemainingLengthEncoding(t *testing.T) {
+	t.Parallel()
+	for _, size := range []int{0, 127, 128, 16383, 16384, maxIncomingBytes} {
+		var frame strings.Builder
+		if err := writePacket(&frame, packet{header: packetPublish, body: make([]byte, size)}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readPacket(bufio.NewReader(strings.NewReader(frame.String())))
+		if err != nil || got.header != packetPublish || len(got.body) != size {
+			t.Fatalf("size %d: %d, %v", size, len(got.body), err)
+		}
+	}
+	var oversized strings.Builder
+	if err := writePacket(&oversized, packet{header: packetPublish, body: make([]byte, maxIncomingBytes+1)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPacket(bufio.NewReader(strings.NewReader(oversized.String()))); !errors.Is(err, errMalformedPacket) {
+		t.Fatalf("oversized packet: %v", err)
+	}
+	// A QoS 1 publish carries a packet identifier after the topic.
+	body := binary.BigEndian.AppendUint16(appendString(nil, "t"), 7)
+	message, id, err := parsePublish(packet{header: packetPublish | 0x02, body: append(body, "v"...)})
+	if err != nil || id != 7 || message.Topic != "t" || string(message.Payload) != "v" {
+		t.Fatalf("qos1 publish = %#v, %d, %v", message, id, err)
+	}
+}
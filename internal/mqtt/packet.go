@@ -0,0 +1,178 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types, already shifted into the high nibble of
+// the fixed header.
+const (
+	packetConnect     byte = 1 << 4
+	packetConnack     byte = 2 << 4
+	packetPublish     byte = 3 << 4
+	packetPuback      byte = 4 << 4
+	packetSubscribe   byte = 8 << 4
+	packetSuback      byte = 9 << 4
+	packetPingreq     byte = 12 << 4
+	packetPingresp    byte = 13 << 4
+	packetDisconnect  byte = 14 << 4
+	protocolLevel311  byte = 4
+	maxRemainingBytes      = 268435455
+	// maxIncomingBytes bounds what a broker may send. Commands and
+	// acknowledgements are tiny, so anything larger is refused rather than
+	// buffered.
+	maxIncomingBytes = 1 << 20
+)
+
+var errMalformedPacket = errors.New("malformed MQTT packet")
+
+// packet is one control packet: the fixed header byte and the remaining
+// bytes after the length.
+type packet struct {
+	header byte
+	body   []byte
+}
+
+func (p packet) kind() byte { return p.header & 0xf0 }
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	length, multiplier := 0, 1
+	for index := 0; ; index++ {
+		if index == 4 {
+			return packet{}, errMalformedPacket
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	if length > maxIncomingBytes {
+		return packet{}, fmt.Errorf("%w: %d byte body", errMalformedPacket, length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{header: header, body: body}, nil
+}
+
+func writePacket(w io.Writer, p packet) error {
+	if len(p.body) > maxRemainingBytes {
+		return fmt.Errorf("%w: %d byte body", errMalformedPacket, len(p.body))
+	}
+	frame := make([]byte, 0, len(p.body)+5)
+	frame = append(frame, p.header)
+	for length := len(p.body); ; {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		frame = append(frame, digit)
+		if length == 0 {
+			break
+		}
+	}
+	frame = append(frame, p.body...)
+	_, err := w.Write(frame)
+	return err
+}
+
+func appendString(buf []byte, value string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(value)))
+	return append(buf, value...)
+}
+
+// readString splits one length-prefixed string off the front of buf.
+func readString(buf []byte) (string, []byte, error) {
+	if len(buf) < 2 {
+		return "", nil, errMalformedPacket
+	}
+	length := int(binary.BigEndian.Uint16(buf))
+	if len(buf) < 2+length {
+		return "", nil, errMalformedPacket
+	}
+	return string(buf[2 : 2+length]), buf[2+length:], nil
+}
+
+func connectPacket(opts Options, keepAlive uint16) packet {
+	var flags byte = 0x02 // clean session
+	if opts.Will != nil {
+		flags |= 0x04
+		if opts.Will.Retain {
+			flags |= 0x20
+		}
+	}
+	if opts.Username != "" {
+		flags |= 0x80
+		if opts.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel311, flags)
+	body = binary.BigEndian.AppendUint16(body, keepAlive)
+	body = appendString(body, opts.ClientID)
+	if opts.Will != nil {
+		body = appendString(body, opts.Will.Topic)
+		body = binary.BigEndian.AppendUint16(body, uint16(len(opts.Will.Payload)))
+		body = append(body, opts.Will.Payload...)
+	}
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+		if opts.Password != "" {
+			body = appendString(body, opts.Password)
+		}
+	}
+	return packet{header: packetConnect, body: body}
+}
+
+// publishPacket encodes a QoS 0 publish.
+func publishPacket(message Message) packet {
+	header := packetPublish
+	if message.Retain {
+		header |= 0x01
+	}
+	body := appendString(nil, message.Topic)
+	return packet{header: header, body: append(body, message.Payload...)}
+}
+
+// parsePublish decodes a publish of any QoS and returns its packet
+// identifier, which is zero for QoS 0.
+func parsePublish(p packet) (Message, uint16, error) {
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return Message{}, 0, err
+	}
+	var id uint16
+	if qos := (p.header >> 1) & 0x03; qos > 0 {
+		if len(rest) < 2 {
+			return Message{}, 0, errMalformedPacket
+		}
+		id, rest = binary.BigEndian.Uint16(rest), rest[2:]
+	}
+	return Message{Topic: topic, Payload: rest, Retain: p.header&0x01 != 0}, id, nil
+}
+
+// subscribePacket asks for QoS 0 delivery of every filter.
+func subscribePacket(id uint16, filters []string) packet {
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for _, filter := range filters {
+		body = appendString(body, filter)
+		body = append(body, 0)
+	}
+	return packet{header: packetSubscribe | 0x02, body: body}
+}
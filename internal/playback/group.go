@@ -0,0 +1,371 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/metrics"
+)
+
+const (
+	DefaultGroupTolerance = 2 * time.Second
+	DefaultGroupInterval  = 3 * time.Second
+)
+
+var groupCorrections = metrics.NewCounter("go2tv_group_corrections_total", "Corrective seeks that brought a group member back in sync, by protocol.", "protocol")
+
+// GroupMember is one renderer of a group. Transport must implement
+// DLNATransport or ChromecastTransport to match Device.Protocol.
+type GroupMember struct {
+	Device    Device
+	Transport Transport
+}
+
+type GroupConfig struct {
+	// Tolerance is how far a member may drift from the leader before it is
+	// seeked back. Non-positive values use DefaultGroupTolerance.
+	Tolerance time.Duration
+	// Interval between position polls. Non-positive values use
+	// DefaultGroupInterval.
+	Interval time.Duration
+	// MemberError reports a follower operation that failed. Followers never
+	// fail the group; only the leader's errors are returned.
+	MemberError func(Device, error)
+}
+
+// Group fans transport calls out to several renderers playing the same
+// route. The first member leads: its errors, position and status speak for
+// the group, and the monitor runs on it. NewGroup returns the Group wrapped in
+// the protocol surface of its leader, so seeks and reloads work as they do on
+// a single renderer.
+type Group struct {
+	cfg     GroupConfig
+	members []GroupMember
+
+	mu       sync.Mutex
+	playing  bool
+	seekable bool
+	// autoplay marks Chromecast members whose last load starts on its own;
+	// Play skips them once so a fresh load is not resumed twice.
+	autoplay map[int]bool
+}
+
+// DLNAGroup is a Group led by a DLNA renderer.
+type DLNAGroup struct{ *Group }
+
+// CastGroup is a Group led by a Chromecast.
+type CastGroup struct{ *Group }
+
+func NewGroup(members []GroupMember, cfg GroupConfig) (Transport, error) {
+	if len(members) == 0 {
+		return nil, errors.New("group has no members")
+	}
+	if cfg.Tolerance <= 0 {
+		cfg.Tolerance = DefaultGroupTolerance
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultGroupInterval
+	}
+	for _, member := range members {
+		if !memberSupported(member) {
+			return nil, fmt.Errorf("group member %q: unsupported renderer protocol %q", member.Device.Name, member.Device.Protocol)
+		}
+	}
+	g := &Group{cfg: cfg, members: members, autoplay: make(map[int]bool)}
+	if members[0].Device.Protocol == "Chromecast" {
+		return CastGroup{g}, nil
+	}
+	return DLNAGroup{g}, nil
+}
+
+// GroupOf returns the Group behind a transport built by NewGroup.
+func GroupOf(transport Transport) (*Group, bool) {
+	switch typed := transport.(type) {
+	case DLNAGroup:
+		return typed.Group, true
+	case CastGroup:
+		return typed.Group, true
+	}
+	return nil, false
+}
+
+func memberSupported(member GroupMember) bool {
+	switch member.Device.Protocol {
+	case "DLNA":
+		_, ok := member.Transport.(DLNATransport)
+		return ok
+	case "Chromecast":
+		_, ok := member.Transport.(ChromecastTransport)
+		return ok
+	}
+	return false
+}
+
+func (g *Group) Leader() GroupMember { return g.members[0] }
+
+func (g *Group) Members() []GroupMember {
+	out := make([]GroupMember, len(g.members))
+	copy(out, g.members)
+	return out
+}
+
+// each runs fn on every member at once, so renderers start as close together
+// as the network allows.
+func (g *Group) each(ctx context.Context, fn func(context.Context, int, GroupMember) error) error {
+	errs := make([]error, len(g.members))
+	var wg sync.WaitGroup
+	for index, member := range g.members {
+		wg.Go(func() { errs[index] = fn(ctx, index, member) })
+	}
+	wg.Wait()
+	for index, err := range errs[1:] {
+		if err != nil && g.cfg.MemberError != nil {
+			g.cfg.MemberError(g.members[index+1].Device, err)
+		}
+	}
+	return errs[0]
+}
+
+func (g *Group) leaderCasts() bool { return g.members[0].Device.Protocol == "Chromecast" }
+
+// load sends req to every member. Chromecasts start on their own; when one
+// leads, the controller never calls Play, so DLNA followers are started here.
+func (g *Group) load(ctx context.Context, req LoadRequest, existing bool) error {
+	g.mu.Lock()
+	g.playing, g.seekable = false, req.Seekable
+	clear(g.autoplay)
+	g.mu.Unlock()
+	err := g.each(ctx, func(ctx context.Context, index int, member GroupMember) error {
+		if member.Device.Protocol == "Chromecast" {
+			var err error
+			if loader, ok := member.Transport.(interface {
+				LoadOnExisting(context.Context, LoadRequest) error
+			}); ok && existing {
+				err = loader.LoadOnExisting(ctx, req)
+			} else {
+				err = member.Transport.Load(ctx, req)
+			}
+			if err == nil {
+				g.mu.Lock()
+				g.autoplay[index] = true
+				g.mu.Unlock()
+			}
+			return err
+		}
+		if err := member.Transport.Load(ctx, req); err != nil {
+			return err
+		}
+		if g.leaderCasts() {
+			return member.Transport.Play(ctx)
+		}
+		return nil
+	})
+	if err == nil && g.leaderCasts() {
+		g.mu.Lock()
+		g.playing = true
+		g.mu.Unlock()
+	}
+	return err
+}
+
+func (g *Group) Load(ctx context.Context, req LoadRequest) error { return g.load(ctx, req, false) }
+
+func (g *Group) Play(ctx context.Context) error {
+	err := g.each(ctx, func(ctx context.Context, index int, member GroupMember) error {
+		g.mu.Lock()
+		fresh := g.autoplay[index]
+		delete(g.autoplay, index)
+		g.mu.Unlock()
+		if fresh {
+			return nil
+		}
+		return member.Transport.Play(ctx)
+	})
+	g.setPlaying(err == nil)
+	return err
+}
+
+func (g *Group) Pause(ctx context.Context) error {
+	g.setPlaying(false)
+	return g.each(ctx, func(ctx context.Context, _ int, member GroupMember) error { return member.Transport.Pause(ctx) })
+}
+
+func (g *Group) Stop(ctx context.Context) error {
+	g.setPlaying(false)
+	return g.each(ctx, func(ctx context.Context, _ int, member GroupMember) error { return member.Transport.Stop(ctx) })
+}
+
+func (g *Group) Close(ctx context.Context) error {
+	g.setPlaying(false)
+	return g.each(ctx, func(ctx context.Context, _ int, member GroupMember) error { return member.Transport.Close(ctx) })
+}
+
+func (g *Group) Volume(ctx context.Context) (int, error) { return g.members[0].Transport.Volume(ctx) }
+
+func (g *Group) SetVolume(ctx context.Context, volume int) error {
+	return g.each(ctx, func(ctx context.Context, _ int, member GroupMember) error {
+		return member.Transport.SetVolume(ctx, volume)
+	})
+}
+
+func (g *Group) SetMute(ctx context.Context, muted bool) error {
+	return g.each(ctx, func(ctx context.Context, _ int, member GroupMember) error {
+		return member.Transport.SetMute(ctx, muted)
+	})
+}
+
+// ActivateCallbacks and SuppressCallbackStops reach the leader only: its
+// callbacks drive the monitor, and a follower's STOPPED must not end the
+// group's session.
+func (g *Group) ActivateCallbacks(generation uint64) error {
+	if activator, ok := g.members[0].Transport.(interface{ ActivateCallbacks(uint64) error }); ok {
+		return activator.ActivateCallbacks(generation)
+	}
+	return nil
+}
+
+func (g *Group) SuppressCallbackStops(generation uint64, suppress bool) error {
+	if suppressor, ok := g.members[0].Transport.(interface {
+		SuppressCallbackStops(uint64, bool) error
+	}); ok {
+		return suppressor.SuppressCallbackStops(generation, suppress)
+	}
+	return nil
+}
+
+func (g *Group) setPlaying(playing bool) {
+	g.mu.Lock()
+	g.playing = playing
+	g.mu.Unlock()
+}
+
+func (g *Group) seek(ctx context.Context, seconds int) error {
+	return g.each(ctx, func(ctx context.Context, _ int, member GroupMember) error { return seekMember(ctx, member, seconds) })
+}
+
+func seekMember(ctx context.Context, member GroupMember, seconds int) error {
+	if member.Device.Protocol == "Chromecast" {
+		return member.Transport.(ChromecastTransport).Seek(ctx, seconds)
+	}
+	return member.Transport.(DLNATransport).Seek(ctx, clockTime(seconds))
+}
+
+func memberPosition(ctx context.Context, member GroupMember) (int, error) {
+	if member.Device.Protocol == "Chromecast" {
+		status, err := member.Transport.(ChromecastTransport).Status(ctx)
+		return status.Current, err
+	}
+	position, err := member.Transport.(DLNATransport).Position(ctx)
+	return position.Current, err
+}
+
+// KeepInSync polls positions until ctx ends and seeks any member that drifted
+// past the tolerance back to the leader. It idles while the group is paused or
+// plays a stream that cannot seek, such as a transcode.
+func (g *Group) KeepInSync(ctx context.Context, clock Clock) {
+	if len(g.members) < 2 {
+		return
+	}
+	if clock == nil {
+		clock = SystemClock()
+	}
+	ticker := clock.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			pollCtx, cancel := context.WithTimeout(ctx, g.cfg.Interval)
+			g.correct(pollCtx)
+			cancel()
+		}
+	}
+}
+
+func (g *Group) correct(ctx context.Context) {
+	g.mu.Lock()
+	active := g.playing && g.seekable
+	g.mu.Unlock()
+	if !active {
+		return
+	}
+	leader, err := memberPosition(ctx, g.members[0])
+	if err != nil {
+		return
+	}
+	polled := time.Now()
+	var wg sync.WaitGroup
+	for _, member := range g.members[1:] {
+		wg.Go(func() {
+			position, err := memberPosition(ctx, member)
+			if err != nil {
+				return
+			}
+			target := leader + int(time.Since(polled).Round(time.Second)/time.Second)
+			drift := time.Duration(position-target) * time.Second
+			if drift.Abs() <= g.cfg.Tolerance {
+				return
+			}
+			if err := seekMember(ctx, member, target); err != nil {
+				if g.cfg.MemberError != nil {
+					g.cfg.MemberError(member.Device, err)
+				}
+				return
+			}
+			groupCorrections.Inc(member.Device.Protocol)
+		})
+	}
+	wg.Wait()
+}
+
+// Seek moves every member; DLNA positions arrive as HH:MM:SS.
+func (g DLNAGroup) Seek(ctx context.Context, value string) error {
+	seconds, err := parseClockTime(value)
+	if err != nil {
+		return err
+	}
+	return g.seek(ctx, seconds)
+}
+
+func (g DLNAGroup) Position(ctx context.Context) (Position, error) {
+	return g.members[0].Transport.(DLNATransport).Position(ctx)
+}
+
+func (g CastGroup) LoadOnExisting(ctx context.Context, req LoadRequest) error {
+	return g.load(ctx, req, true)
+}
+
+func (g CastGroup) Seek(ctx context.Context, seconds int) error { return g.seek(ctx, seconds) }
+
+func (g CastGroup) Status(ctx context.Context) (CastStatus, error) {
+	return g.members[0].Transport.(ChromecastTransport).Status(ctx)
+}
+
+func parseClockTime(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid clock time %q", value)
+	}
+	seconds := 0
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid clock time %q", value)
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, nil
+}
+
+var (
+	_ DLNATransport       = DLNAGroup{}
+	_ Transport           = DLNAGroup{}
+	_ ChromecastTransport = CastGroup{}
+	_ Transport           = CastGroup{}
+)
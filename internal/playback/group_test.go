@@ -0,0 +1,224 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type groupLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *groupLog) add(event string) {
+	l.mu.Lock()
+	l.events = append(l.events, event)
+	l.mu.Unlock()
+}
+
+func (l *groupLog) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := l.events
+	l.events = nil
+	slices.Sort(events)
+	return events
+}
+
+// groupRenderer is a DLNA or Chromecast member, depending on which protocol
+// methods the test reaches through the Group.
+type groupRenderer struct {
+	name     string
+	log      *groupLog
+	mu       sync.Mutex
+	position int
+	failLoad bool
+}
+
+func (r *groupRenderer) Load(context.Context, LoadRequest) error {
+	r.log.add("load:" + r.name)
+	if r.failLoad {
+		return errors.New("unreachable")
+	}
+	return nil
+}
+func (r *groupRenderer) LoadOnExisting(context.Context, LoadRequest) error {
+	r.log.add("load-existing:" + r.name)
+	return nil
+}
+func (r *groupRenderer) Play(context.Context) error  { r.log.add("play:" + r.name); return nil }
+func (r *groupRenderer) Pause(context.Context) error { r.log.add("pause:" + r.name); return nil }
+func (r *groupRenderer) Stop(context.Context) error  { r.log.add("stop:" + r.name); return nil }
+func (r *groupRenderer) Close(context.Context) error { r.log.add("close:" + r.name); return nil }
+func (r *groupRenderer) Volume(context.Context) (int, error) {
+	r.log.add("volume:" + r.name)
+	return 40, nil
+}
+func (r *groupRenderer) SetVolume(_ context.Context, volume int) error {
+	r.log.add("volume:" + r.name + ":" + strconv.Itoa(volume))
+	return nil
+}
+func (r *groupRenderer) SetMute(context.Context, bool) error { r.log.add("mute:" + r.name); return nil }
+func (r *groupRenderer) Position(context.Context) (Position, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Position{Current: r.position, Duration: 600}, nil
+}
+func (r *groupRenderer) Status(ctx context.Context) (CastStatus, error) {
+	position, err := r.Position(ctx)
+	return CastStatus{PlayerState: "PLAYING", Current: position.Current, Duration: position.Duration}, err
+}
+
+type dlnaRenderer struct{ *groupRenderer }
+
+func (r dlnaRenderer) Seek(_ context.Context, value string) error {
+	r.log.add("seek:" + r.name + ":" + value)
+	return nil
+}
+
+type castRenderer struct{ *groupRenderer }
+
+func (r castRenderer) Seek(_ context.Context, seconds int) error {
+	r.log.add("seek:" + r.name + ":" + strconv.Itoa(seconds))
+	r.mu.Lock()
+	r.position = seconds
+	r.mu.Unlock()
+	return nil
+}
+
+func TestGroupFansOutFromDLNALeader(t *testing.T) {
+	log := &groupLog{}
+	var failed []string
+	leader := dlnaRenderer{&groupRenderer{name: "tv", log: log}}
+	cast := castRenderer{&groupRenderer{name: "cast", log: log}}
+	broken := dlnaRenderer{&groupRenderer{name: "broken", log: log, failLoad: true}}
+	transport, err := NewGroup([]GroupMember{
+		{Device: Device{Name: "tv", Protocol: "DLNA"}, Transport: leader},
+		{Device: Device{Name: "cast", Protocol: "Chromecast"}, Transport: cast},
+		{Device: Device{Name: "broken", Protocol: "DLNA"}, Transport: broken},
+	}, GroupConfig{MemberError: func(device Device, err error) { failed = append(failed, device.Name) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dlna, ok := transport.(DLNATransport)
+	if !ok {
+		t.Fatalf("group led by DLNA is %T", transport)
+	}
+	ctx := context.Background()
+	if err := transport.Load(ctx, LoadRequest{MediaURL: "http://host/media", Seekable: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(failed, []string{"broken"}) {
+		t.Fatalf("member errors = %v", failed)
+	}
+	if err := transport.Play(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The Chromecast started itself on load.
+	if got := log.take(); !slices.Equal(got, []string{"load:broken", "load:cast", "load:tv", "play:broken", "play:tv"}) {
+		t.Fatalf("load and play = %v", got)
+	}
+	if err := dlna.Seek(ctx, "00:01:05"); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.take(); !slices.Equal(got, []string{"seek:broken:00:01:05", "seek:cast:65", "seek:tv:00:01:05"}) {
+		t.Fatalf("seek = %v", got)
+	}
+	if volume, err := transport.Volume(ctx); err != nil || volume != 40 {
+		t.Fatalf("volume = %d, %v", volume, err)
+	}
+	if got := log.take(); !slices.Equal(got, []string{"volume:tv"}) {
+		t.Fatalf("volume read %v", got)
+	}
+	if err := transport.Pause(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Play(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.take(); !slices.Equal(got, []string{"pause:broken", "pause:cast", "pause:tv", "play:broken", "play:cast", "play:tv"}) {
+		t.Fatalf("pause and resume = %v", got)
+	}
+	if _, ok := GroupOf(transport); !ok {
+		t.Fatal("GroupOf did not find the group")
+	}
+}
+
+func TestGroupStartsDLNAFollowersOfCastLeader(t *testing.T) {
+	log := &groupLog{}
+	transport, err := NewGroup([]GroupMember{
+		{Device: Device{Name: "cast", Protocol: "Chromecast"}, Transport: castRenderer{&groupRenderer{name: "cast", log: log}}},
+		{Device: Device{Name: "tv", Protocol: "DLNA"}, Transport: dlnaRenderer{&groupRenderer{name: "tv", log: log}}},
+	}, GroupConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cast, ok := transport.(ChromecastTransport)
+	if !ok {
+		t.Fatalf("group led by Chromecast is %T", transport)
+	}
+	if err := cast.LoadOnExisting(context.Background(), LoadRequest{MediaURL: "http://host/media"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.take(); !slices.Equal(got, []string{"load-existing:cast", "load:tv", "play:tv"}) {
+		t.Fatalf("load = %v", got)
+	}
+	if _, err := NewGroup([]GroupMember{{Device: Device{Protocol: "AirPlay"}, Transport: castRenderer{&groupRenderer{log: log}}}}, GroupConfig{}); err == nil {
+		t.Fatal("unsupported member accepted")
+	}
+}
+
+func TestGroupCorrectsDrift(t *testing.T) {
+	log := &groupLog{}
+	leader := castRenderer{&groupRenderer{name: "leader", log: log, position: 100}}
+	near := castRenderer{&groupRenderer{name: "close", log: log, position: 99}}
+	late := castRenderer{&groupRenderer{name: "late", log: log, position: 90}}
+	transport, err := NewGroup([]GroupMember{
+		{Device: Device{Name: "leader", Protocol: "Chromecast"}, Transport: leader},
+		{Device: Device{Name: "close", Protocol: "Chromecast"}, Transport: near},
+		{Device: Device{Name: "late", Protocol: "Chromecast"}, Transport: late},
+	}, GroupConfig{Tolerance: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, _ := GroupOf(transport)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := transport.Load(ctx, LoadRequest{Seekable: true}); err != nil {
+		t.Fatal(err)
+	}
+	log.take()
+	group.correct(ctx)
+	if got := log.take(); !slices.Equal(got, []string{"seek:late:100"}) {
+		t.Fatalf("corrections = %v", got)
+	}
+	if err := transport.Pause(ctx); err != nil {
+		t.Fatal(err)
+	}
+	log.take()
+	late.mu.Lock()
+	late.position = 50
+	late.mu.Unlock()
+	group.correct(ctx)
+	if got := log.take(); len(got) != 0 {
+		t.Fatalf("paused group corrected: %v", got)
+	}
+
+	clock := newManualClock()
+	done := make(chan struct{})
+	go func() {
+		group.KeepInSync(ctx, clock)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("KeepInSync ignored its context")
+	}
+}
@@ -15,6 +15,9 @@ type Device struct {
 	Protocol  string
 	AudioOnly bool
 	Endpoint  string
+	// SinkProtocolInfo is what a DLNA renderer reported it plays, as the
+	// comma-separated GetProtocolInfo Sink list. It is empty when unknown.
+	SinkProtocolInfo string `json:"-"`
 }
 
 type Discovery interface {
@@ -75,6 +78,7 @@ type MediaServer interface {
 type SourceOpener func(context.Context) (io.ReadSeekCloser, time.Time, error)
 
 type Clock interface {
+	Now() time.Time
 	NewTicker(time.Duration) Ticker
 	NewTimer(time.Duration) Timer
 }
@@ -128,7 +132,13 @@ type ServerRequest struct {
 	SeekOffset   int
 	Duration     float64
 	BurnSubtitle bool
-	Target       Device
+	// Remux, with Transcode, copies the codecs into the container MediaExt
+	// names instead of re-encoding them.
+	Remux bool
+	// CaptionInfo names an SRT subtitle route in a CaptionInfo.sec header on
+	// the media response, for renderers with that quirk.
+	CaptionInfo bool
+	Target      Device
 }
 
 type RouteRequest struct {
@@ -146,8 +156,12 @@ type MediaRoute struct {
 	SubtitleID  string
 }
 
+// SystemClock returns the Clock backed by package time.
+func SystemClock() Clock { return realClock{} }
+
 type realClock struct{}
 
+func (realClock) Now() time.Time                   { return time.Now() }
 func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
 func (realClock) NewTimer(d time.Duration) Timer   { return realTimer{time.NewTimer(d)} }
 
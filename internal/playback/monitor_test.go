@@ -27,6 +27,8 @@ func newManualClock() *manualClock {
 	return &manualClock{tick: &manualTicker{make(chan time.Time, 16)}, timer: &manualTimer{make(chan time.Time, 1)}}
 }
 
+func (*manualClock) Now() time.Time { return time.Now() }
+
 func (c *manualClock) NewTicker(time.Duration) Ticker {
 	return c.tick
 }
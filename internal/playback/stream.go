@@ -0,0 +1,268 @@
+package playback
+
+import (
+	"fmt"
+	"strings"
+
+	"go2tv.app/go2tv/v2/utils"
+)
+
+// StreamMode is how media reaches its renderer.
+type StreamMode string
+
+const (
+	// StreamDirect serves the media as it is.
+	StreamDirect StreamMode = "direct"
+	// StreamRemux copies the codecs into a container the renderer accepts.
+	StreamRemux StreamMode = "remux"
+	// StreamTranscode re-encodes the media.
+	StreamTranscode StreamMode = "transcode"
+)
+
+// StreamDecision is how media reaches its renderer and why.
+type StreamDecision struct {
+	Mode   StreamMode `json:"Mode"`
+	Reason string     `json:"Reason"`
+	// MediaType and Ext are the MIME type and file extension a remuxed
+	// stream is served as.
+	MediaType string `json:"MediaType,omitempty"`
+	Ext       string `json:"Ext,omitempty"`
+}
+
+// typeAliases groups the MIME types renderers use for one container.
+var typeAliases = [][]string{
+	{"video/x-matroska", "video/x-mkv", "video/mkv"},
+	{"video/mp2t", "video/vnd.dlna.mpeg-tts", "video/mpegts", "video/mpeg2ts", "video/x-mpegts"},
+	{"video/x-msvideo", "video/avi", "video/x-avi", "video/msvideo"},
+	{"video/mp4", "video/x-mp4"},
+	{"video/quicktime", "video/mov"},
+}
+
+// typeCodecs are the codecs implied by a sink MIME type. Some renderers list
+// codecs this way rather than as DLNA profiles.
+var typeCodecs = map[string][]string{
+	"video/x-h264":   {"h264"},
+	"video/h264":     {"h264"},
+	"video/x-h265":   {"hevc"},
+	"video/h265":     {"hevc"},
+	"video/hevc":     {"hevc"},
+	"video/x-vp8":    {"vp8"},
+	"video/x-vp9":    {"vp9"},
+	"video/webm":     {"vp8", "vp9"},
+	"video/x-av1":    {"av1"},
+	"video/av1":      {"av1"},
+	"video/x-divx":   {"mpeg4"},
+	"video/x-xvid":   {"mpeg4"},
+	"video/x-theora": {"theora"},
+	"video/x-ms-wmv": {"wmv3", "vc1"},
+	"video/x-wmv":    {"wmv3", "vc1"},
+	"audio/mpeg":     {"mp3"},
+	"audio/x-mpeg":   {"mp3"},
+	"audio/mp3":      {"mp3"},
+	"audio/mp4":      {"aac"},
+	"audio/aac":      {"aac"},
+	"audio/x-aac":    {"aac"},
+	"audio/m4a":      {"aac"},
+	"audio/x-m4a":    {"aac"},
+	"audio/ac3":      {"ac3"},
+	"audio/x-ac3":    {"ac3"},
+	"audio/eac3":     {"eac3"},
+	"audio/x-eac3":   {"eac3"},
+	"audio/vnd.dts":  {"dts"},
+	"audio/x-dts":    {"dts"},
+	"audio/flac":     {"flac"},
+	"audio/x-flac":   {"flac"},
+	"audio/ogg":      {"vorbis", "opus"},
+	"audio/x-vorbis": {"vorbis"},
+	"audio/opus":     {"opus"},
+	"audio/x-opus":   {"opus"},
+	"audio/x-ms-wma": {"wmav2"},
+	"audio/x-wma":    {"wmav2"},
+	"audio/l16":      {"pcm"},
+	"audio/wav":      {"pcm"},
+	"audio/x-wav":    {"pcm"},
+	"audio/x-lpcm":   {"pcm"},
+	"audio/alac":     {"alac"},
+	"audio/x-alac":   {"alac"},
+}
+
+// profileVideoCodecs and profileAudioCodecs are the codecs DLNA.ORG_PN
+// profile names carry. Video markers start a profile name; audio markers may
+// appear anywhere in it.
+var (
+	profileVideoCodecs = []struct{ marker, codec string }{
+		{"AVC_", "h264"},
+		{"HEVC", "hevc"},
+		{"MPEG_PS", "mpeg2video"},
+		{"MPEG_TS", "mpeg2video"},
+		{"MPEG_ES", "mpeg2video"},
+		{"MPEG1", "mpeg1video"},
+		{"MPEG4_P2", "mpeg4"},
+		{"VC1_", "vc1"},
+		{"WMV", "wmv3"},
+	}
+	profileAudioCodecs = []struct{ marker, codec string }{
+		{"AAC", "aac"},
+		{"MP3", "mp3"},
+		{"_L3", "mp3"},
+		{"EAC3", "eac3"},
+		{"AC3", "ac3"},
+		{"LPCM", "pcm"},
+		{"WMA", "wmav2"},
+	}
+)
+
+// Codecs every video renderer is taken to decode, named in its sink list
+// or not.
+var (
+	baselineVideoCodecs = []string{"h264", "mpeg2video", "mpeg4"}
+	baselineAudioCodecs = []string{"aac", "mp3"}
+)
+
+// remuxTargets are the containers a remux writes, in order of preference,
+// with the codecs each carries.
+var remuxTargets = []struct {
+	name, mediaType, ext string
+	codecs               map[string]bool
+}{
+	{"MPEG-TS", "video/mp2t", ".ts", map[string]bool{
+		"h264": true, "hevc": true, "mpeg1video": true, "mpeg2video": true, "mpeg4": true,
+		"aac": true, "mp3": true, "ac3": true, "eac3": true, "dts": true,
+	}},
+	{"MP4", "video/mp4", ".mp4", map[string]bool{
+		"h264": true, "hevc": true, "mpeg4": true, "av1": true, "vp9": true,
+		"aac": true, "mp3": true, "ac3": true, "eac3": true, "opus": true, "flac": true, "alac": true,
+	}},
+}
+
+// sinkFormats is what a renderer's Sink list says it plays over HTTP.
+type sinkFormats struct {
+	any    bool
+	types  map[string]bool
+	codecs map[string]bool
+}
+
+func parseSink(sink string) sinkFormats {
+	formats := sinkFormats{types: make(map[string]bool), codecs: make(map[string]bool)}
+	for _, codec := range baselineVideoCodecs {
+		formats.codecs[codec] = true
+	}
+	for _, codec := range baselineAudioCodecs {
+		formats.codecs[codec] = true
+	}
+	for entry := range strings.SplitSeq(sink, ",") {
+		fields := strings.SplitN(strings.TrimSpace(entry), ":", 4)
+		if len(fields) != 4 || fields[0] != "http-get" {
+			continue
+		}
+		mediaType, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(fields[2])), ";")
+		if mediaType == "*" || mediaType == "*/*" {
+			formats.any = true
+			continue
+		}
+		formats.types[mediaType] = true
+		for _, codec := range typeCodecs[mediaType] {
+			formats.codecs[codec] = true
+		}
+		for param := range strings.SplitSeq(fields[3], ";") {
+			profile, ok := strings.CutPrefix(strings.TrimSpace(param), "DLNA.ORG_PN=")
+			if !ok {
+				continue
+			}
+			profile = strings.ToUpper(profile)
+			for _, named := range profileVideoCodecs {
+				if strings.HasPrefix(profile, named.marker) {
+					formats.codecs[named.codec] = true
+				}
+			}
+			for _, named := range profileAudioCodecs {
+				if strings.Contains(profile, named.marker) {
+					formats.codecs[named.codec] = true
+				}
+			}
+		}
+	}
+	return formats
+}
+
+// accepts returns the sink's own name for mediaType, or "" when the
+// renderer does not take it.
+func (f sinkFormats) accepts(mediaType string) string {
+	mediaType = strings.ToLower(mediaType)
+	names := []string{mediaType}
+	for _, aliases := range typeAliases {
+		for _, alias := range aliases {
+			if alias == mediaType {
+				names = aliases
+			}
+		}
+	}
+	for _, name := range names {
+		if f.types[name] {
+			return name
+		}
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	if f.any || f.types[major+"/*"] {
+		return mediaType
+	}
+	return ""
+}
+
+func (f sinkFormats) decodes(kind, codec string) bool {
+	return f.any || f.types[kind+"/*"] || f.codecs[codec]
+}
+
+// DecideStream picks how video of mediaType, with the probed codecs, reaches
+// a DLNA renderer that reported sink: as it is when the renderer takes both
+// the container and the codecs, remuxed into MPEG-TS or MP4 when only the
+// container is the problem and remux is available, and transcoded otherwise.
+// It reports false when there is too little to go on: no sink list, no
+// codecs, or media that is not video of a known type.
+func DecideStream(sink, mediaType string, codecs *utils.MediaCodecInfo, remux bool) (StreamDecision, bool) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if strings.TrimSpace(sink) == "" || codecs == nil || codecs.VideoCodec == "" || !strings.HasPrefix(mediaType, "video/") || strings.HasSuffix(mediaType, "/*") {
+		return StreamDecision{}, false
+	}
+	formats := parseSink(sink)
+	video, audio := codecs.VideoCodec, codecName(codecs.AudioCodec)
+	if !formats.decodes("video", video) {
+		return StreamDecision{Mode: StreamTranscode, Reason: fmt.Sprintf("renderer does not list %s video", video)}, true
+	}
+	if audio != "" && !formats.decodes("audio", audio) {
+		return StreamDecision{Mode: StreamTranscode, Reason: fmt.Sprintf("renderer does not list %s audio", audio)}, true
+	}
+	streams := video
+	if audio != "" {
+		streams += " and " + audio
+	}
+	if formats.accepts(mediaType) != "" {
+		return StreamDecision{Mode: StreamDirect, Reason: fmt.Sprintf("renderer plays %s with %s", mediaType, streams)}, true
+	}
+	if !remux {
+		return StreamDecision{Mode: StreamTranscode, Reason: fmt.Sprintf("renderer does not accept %s", mediaType)}, true
+	}
+	var accepted []string
+	for _, target := range remuxTargets {
+		name := formats.accepts(target.mediaType)
+		if name == "" {
+			continue
+		}
+		accepted = append(accepted, target.name)
+		if target.codecs[video] && (audio == "" || target.codecs[audio]) {
+			return StreamDecision{Mode: StreamRemux, Reason: fmt.Sprintf("renderer does not accept %s; copying %s into %s", mediaType, streams, target.name), MediaType: name, Ext: target.ext}, true
+		}
+	}
+	if len(accepted) == 0 {
+		return StreamDecision{Mode: StreamTranscode, Reason: fmt.Sprintf("renderer accepts neither %s nor a remux container", mediaType)}, true
+	}
+	return StreamDecision{Mode: StreamTranscode, Reason: fmt.Sprintf("renderer does not accept %s, and %s cannot carry %s", mediaType, strings.Join(accepted, " or "), streams)}, true
+}
+
+// codecName folds the PCM sample formats ffprobe reports into one codec.
+func codecName(codec string) string {
+	if strings.HasPrefix(codec, "pcm_") {
+		return "pcm"
+	}
+	return codec
+}
@@ -0,0 +1,56 @@
+package playback
+
+import (
+	"testing"
+
+	"go2tv.app/go2tv/v2/utils"
+)
+
+func TestDecideStream(t *testing.T) {
+	const (
+		tv        = "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_HP_HD_AAC,http-get:*:video/vnd.dlna.mpeg-tts:DLNA.ORG_PN=AVC_TS_HD_24_AC3,http-get:*:audio/mpeg:DLNA.ORG_PN=MP3"
+		mkvTV     = "http-get:*:video/x-mkv:*," + tv
+		codecList = "http-get:*:video/x-matroska:*,http-get:*:video/x-h265:*,http-get:*:audio/*:*"
+	)
+	h264AAC := &utils.MediaCodecInfo{VideoCodec: "h264", AudioCodec: "aac"}
+	tests := []struct {
+		name      string
+		sink      string
+		mediaType string
+		codecs    *utils.MediaCodecInfo
+		remux     bool
+		want      StreamMode
+		wantType  string
+		wantExt   string
+		undecided bool
+	}{
+		{name: "accepted container and codecs", sink: tv, mediaType: "video/mp4", codecs: h264AAC, want: StreamDirect},
+		{name: "container alias", sink: mkvTV, mediaType: "video/x-matroska", codecs: h264AAC, want: StreamDirect},
+		{name: "container only remuxes", sink: tv, mediaType: "video/x-matroska", codecs: &utils.MediaCodecInfo{VideoCodec: "h264", AudioCodec: "ac3"}, remux: true, want: StreamRemux, wantType: "video/vnd.dlna.mpeg-tts", wantExt: ".ts"},
+		{name: "MP4 when MPEG-TS is not accepted", sink: "http-get:*:video/mp4:*,http-get:*:video/x-h265:*", mediaType: "video/x-matroska", codecs: &utils.MediaCodecInfo{VideoCodec: "hevc", AudioCodec: "aac"}, remux: true, want: StreamRemux, wantType: "video/mp4", wantExt: ".mp4"},
+		{name: "container without remux transcodes", sink: tv, mediaType: "video/x-matroska", codecs: h264AAC, want: StreamTranscode},
+		{name: "unlisted video codec", sink: mkvTV, mediaType: "video/x-matroska", codecs: &utils.MediaCodecInfo{VideoCodec: "hevc", AudioCodec: "aac"}, remux: true, want: StreamTranscode},
+		{name: "unlisted audio codec", sink: mkvTV, mediaType: "video/x-matroska", codecs: &utils.MediaCodecInfo{VideoCodec: "h264", AudioCodec: "dts"}, remux: true, want: StreamTranscode},
+		{name: "codecs listed as types", sink: codecList, mediaType: "video/x-matroska", codecs: &utils.MediaCodecInfo{VideoCodec: "hevc", AudioCodec: "pcm_s24le"}, want: StreamDirect},
+		{name: "codecs MPEG-TS cannot carry", sink: "http-get:*:video/mp2t:*,http-get:*:video/x-vp9:*,http-get:*:audio/ogg:*", mediaType: "video/webm", codecs: &utils.MediaCodecInfo{VideoCodec: "vp9", AudioCodec: "opus"}, remux: true, want: StreamTranscode},
+		{name: "wildcard sink", sink: "http-get:*:*:*", mediaType: "video/x-matroska", codecs: &utils.MediaCodecInfo{VideoCodec: "av1"}, want: StreamDirect},
+		{name: "no sink", mediaType: "video/mp4", codecs: h264AAC, undecided: true},
+		{name: "no codecs", sink: tv, mediaType: "video/mp4", undecided: true},
+		{name: "audio", sink: tv, mediaType: "audio/flac", codecs: &utils.MediaCodecInfo{AudioCodec: "flac"}, undecided: true},
+		{name: "unknown container", sink: tv, mediaType: "video/*", codecs: h264AAC, undecided: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, ok := DecideStream(tt.sink, tt.mediaType, tt.codecs, tt.remux)
+			if ok == tt.undecided {
+				t.Fatalf("decided = %v, want %v", ok, !tt.undecided)
+			}
+			if decision.Mode != tt.want || decision.MediaType != tt.wantType || decision.Ext != tt.wantExt {
+				t.Fatalf("decision = %+v, want %s %q %q", decision, tt.want, tt.wantType, tt.wantExt)
+			}
+			if ok && decision.Reason == "" {
+				t.Fatal("decision has no reason")
+			}
+		})
+	}
+}
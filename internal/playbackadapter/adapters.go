@@ -41,7 +41,7 @@ func (s Scanner) Scan(ctx context.Context) ([]playback.Device, error) {
 	for _, device := range found {
 		result = append(result, playback.Device{
 			Name: device.Name, Protocol: device.Type, AudioOnly: device.IsAudioOnly,
-			Endpoint: device.Addr,
+			Endpoint: device.Addr, SinkProtocolInfo: device.SinkProtocolInfo,
 		})
 	}
 	return result, nil
@@ -286,6 +286,7 @@ type DLNAConfig struct {
 	LogOutput   io.Writer
 	CallbackURL CallbackURLProvider
 	Callbacks   *CallbackBridge
+	Quirks      *soapcalls.QuirkDB
 }
 
 // DLNA preserves TVPayload SOAP fallback/logging while serializing contextual calls.
@@ -307,7 +308,7 @@ func NewDLNA(ctx context.Context, cfg DLNAConfig) (*DLNA, error) {
 	if ctx == nil {
 		return nil, errors.New("DLNA context required")
 	}
-	payload, err := soapcalls.NewTVPayload(&soapcalls.Options{Ctx: ctx, DMR: cfg.Endpoint, LogOutput: cfg.LogOutput})
+	payload, err := soapcalls.NewTVPayload(&soapcalls.Options{Ctx: ctx, DMR: cfg.Endpoint, LogOutput: cfg.LogOutput, Quirks: cfg.Quirks})
 	if err != nil {
 		return nil, fmt.Errorf("open DLNA transport: %w", err)
 	}
@@ -324,6 +325,13 @@ func (d *DLNA) call(ctx context.Context, fn func(*soapcalls.TVPayload) error) er
 	return fn(d.payload)
 }
 
+// Quirks returns the workarounds the renderer gets.
+func (d *DLNA) Quirks() soapcalls.Quirks {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.payload.Quirks.Lookup(d.payload.Renderer)
+}
+
 func (d *DLNA) Load(ctx context.Context, req playback.LoadRequest) error {
 	return d.call(ctx, func(p *soapcalls.TVPayload) error {
 		if d.callbacks != nil && d.callbacksRequested {
@@ -745,12 +753,13 @@ type Factory struct {
 	LogOutput   io.Writer
 	CallbackURL CallbackURLProvider
 	Callbacks   *CallbackBridge
+	Quirks      *soapcalls.QuirkDB
 }
 
 func (f *Factory) Open(ctx context.Context, device playback.Device) (playback.Transport, error) {
 	switch device.Protocol {
 	case devices.DeviceTypeDLNA:
-		return NewDLNA(ctx, DLNAConfig{Endpoint: device.Endpoint, LogOutput: f.LogOutput, CallbackURL: f.CallbackURL, Callbacks: f.Callbacks})
+		return NewDLNA(ctx, DLNAConfig{Endpoint: device.Endpoint, LogOutput: f.LogOutput, CallbackURL: f.CallbackURL, Callbacks: f.Callbacks, Quirks: f.Quirks})
 	case devices.DeviceTypeChromecast:
 		cast, err := NewChromecast(device.Endpoint, f.LogOutput)
 		if err != nil {
@@ -770,6 +779,18 @@ func RunMonitor(ctx context.Context, cfg playback.MonitorConfig, device playback
 	if sink == nil {
 		return
 	}
+	if group, ok := playback.GroupOf(transport); ok {
+		// The leader's monitor speaks for the group while followers are kept
+		// within the drift tolerance alongside it.
+		syncCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		wg.Go(func() { group.KeepInSync(syncCtx, cfg.Clock) })
+		leader := group.Leader()
+		RunMonitor(ctx, cfg, leader.Device, leader.Transport)
+		cancel()
+		wg.Wait()
+		return
+	}
 	switch typed := transport.(type) {
 	case *DLNA:
 		if err := typed.ActivateCallbacks(generation); err != nil {
@@ -0,0 +1,291 @@
+// Package playlist reads and writes the M3U, M3U8, PLS and XSPF playlist
+// formats. It only deals with entry locations and titles; resolving a
+// location to a playable file or stream is left to the caller.
+package playlist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/ini.v1"
+)
+
+type Format string
+
+const (
+	M3U  Format = "m3u"
+	M3U8 Format = "m3u8"
+	PLS  Format = "pls"
+	XSPF Format = "xspf"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported playlist format")
+	ErrInvalidPlaylist   = errors.New("invalid playlist")
+)
+
+// Formats lists the supported formats in the order they are offered to users.
+var Formats = []Format{M3U8, M3U, PLS, XSPF}
+
+// Entry is one playlist item. Location is an http(s) URL or a file path,
+// which may be relative to wherever the playlist came from and keeps the
+// separators the playlist used. file:// URLs are turned into paths when
+// parsing.
+type Entry struct {
+	Location string
+	Title    string
+}
+
+// FormatForName picks the format from a file name extension.
+func FormatForName(name string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(name), "."))
+}
+
+// ParseFormat accepts a format name in any case.
+func ParseFormat(value string) (Format, error) {
+	format := Format(strings.ToLower(strings.TrimSpace(value)))
+	if !slices.Contains(Formats, format) {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedFormat, value)
+	}
+	return format, nil
+}
+
+// Extension returns the file name extension, dot included, for the format.
+func (f Format) Extension() string { return "." + string(f) }
+
+// MIMEType returns the media type playlists of the format are served as.
+func (f Format) MIMEType() string {
+	switch f {
+	case M3U8:
+		return "application/vnd.apple.mpegurl"
+	case M3U:
+		return "audio/x-mpegurl"
+	case PLS:
+		return "audio/x-scpls"
+	case XSPF:
+		return "application/xspf+xml"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// IsRemote reports whether location is an http or https URL.
+func IsRemote(location string) bool {
+	u, err := url.Parse(location)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Parse reads the entries of a playlist. Blank locations are skipped; a
+// playlist without any entries is not an error.
+func Parse(format Format, data []byte) ([]Entry, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	var entries []Entry
+	var err error
+	switch format {
+	case M3U, M3U8:
+		if format == M3U && !utf8.Valid(data) {
+			data = latin1(data)
+		}
+		entries, err = parseM3U(data)
+	case PLS:
+		entries, err = parsePLS(data)
+	case XSPF:
+		entries, err = parseXSPF(data)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPlaylist, err)
+	}
+	return entries, nil
+}
+
+// Write encodes entries in the given format. M3U is written as UTF-8, like
+// M3U8, since players that still expect Latin-1 are rare.
+func Write(w io.Writer, format Format, entries []Entry) error {
+	var buf bytes.Buffer
+	switch format {
+	case M3U, M3U8:
+		buf.WriteString("#EXTM3U\n")
+		for _, entry := range entries {
+			if title := oneLine(entry.Title); title != "" {
+				fmt.Fprintf(&buf, "#EXTINF:-1,%s\n", title)
+			}
+			buf.WriteString(oneLine(entry.Location) + "\n")
+		}
+	case PLS:
+		buf.WriteString("[playlist]\n")
+		for index, entry := range entries {
+			fmt.Fprintf(&buf, "File%d=%s\n", index+1, oneLine(entry.Location))
+			if title := oneLine(entry.Title); title != "" {
+				fmt.Fprintf(&buf, "Title%d=%s\n", index+1, title)
+			}
+			fmt.Fprintf(&buf, "Length%d=-1\n", index+1)
+		}
+		fmt.Fprintf(&buf, "NumberOfEntries=%d\nVersion=2\n", len(entries))
+	case XSPF:
+		document := xspfDocument{Version: "1", Namespace: "http://xspf.org/ns/0/"}
+		for _, entry := range entries {
+			document.Tracks = append(document.Tracks, xspfTrack{Location: xspfLocation(entry.Location), Title: entry.Title})
+		}
+		buf.WriteString(xml.Header)
+		encoder := xml.NewEncoder(&buf)
+		encoder.Indent("", "  ")
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func parseM3U(data []byte) ([]Entry, error) {
+	var entries []Entry
+	title := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			// #EXTINF:<duration> [attributes],<title>
+			if _, value, ok := strings.Cut(line, ","); ok {
+				title = strings.TrimSpace(value)
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			entries = append(entries, Entry{Location: fileLocation(line), Title: title})
+			title = ""
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func parsePLS(data []byte) ([]Entry, error) {
+	file, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, data)
+	if err != nil {
+		return nil, err
+	}
+	section, err := file.GetSection("playlist")
+	if err != nil {
+		return nil, errors.New("missing [playlist] section")
+	}
+	// Entries are numbered from 1 but writers are not always consistent about
+	// gaps, so collect every FileN key and order by N.
+	var numbers []int
+	for _, key := range section.Keys() {
+		if number, err := strconv.Atoi(strings.TrimPrefix(key.Name(), "File")); err == nil && strings.HasPrefix(key.Name(), "File") && number > 0 {
+			numbers = append(numbers, number)
+		}
+	}
+	slices.Sort(numbers)
+	entries := make([]Entry, 0, len(numbers))
+	for _, number := range numbers {
+		location := strings.TrimSpace(section.Key("File" + strconv.Itoa(number)).String())
+		if location == "" {
+			continue
+		}
+		entries = append(entries, Entry{Location: fileLocation(location), Title: strings.TrimSpace(section.Key("Title" + strconv.Itoa(number)).String())})
+	}
+	return entries, nil
+}
+
+type xspfDocument struct {
+	XMLName   xml.Name    `xml:"playlist"`
+	Version   string      `xml:"version,attr"`
+	Namespace string      `xml:"xmlns,attr"`
+	Tracks    []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+}
+
+func parseXSPF(data []byte) ([]Entry, error) {
+	// The namespace attribute is only needed when writing; matching on local
+	// names accepts documents that omit or prefix it.
+	var document struct {
+		Tracks []struct {
+			Locations []string `xml:"location"`
+			Title     string   `xml:"title"`
+		} `xml:"trackList>track"`
+	}
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(document.Tracks))
+	for _, track := range document.Tracks {
+		// A track may list alternative locations; the first one is the
+		// preferred source.
+		if len(track.Locations) == 0 || strings.TrimSpace(track.Locations[0]) == "" {
+			continue
+		}
+		location := strings.TrimSpace(track.Locations[0])
+		if u, err := url.Parse(location); err == nil && u.Scheme == "" {
+			// Relative XSPF locations are URI references, so they are
+			// percent-encoded.
+			location = u.Path
+		}
+		entries = append(entries, Entry{Location: fileLocation(location), Title: strings.TrimSpace(track.Title)})
+	}
+	return entries, nil
+}
+
+// fileLocation turns a file:// URL into a local path and leaves anything else
+// untouched.
+func fileLocation(location string) string {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "file" {
+		return location
+	}
+	path := u.Path
+	// file:///C:/Music/a.mp3 carries the drive letter after the leading slash.
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+// xspfLocation writes a location as the URI XSPF requires.
+func xspfLocation(location string) string {
+	if u, err := url.Parse(location); err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		return location
+	}
+	path := filepath.ToSlash(location)
+	if !filepath.IsAbs(location) {
+		return (&url.URL{Path: path}).String()
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// oneLine keeps a value from spilling onto the next line of a line-based
+// format.
+func oneLine(value string) string {
+	return strings.TrimSpace(strings.NewReplacer("\r", " ", "\n", " ").Replace(value))
+}
+
+func latin1(data []byte) []byte {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return []byte(string(runes))
+}
@@ -0,0 +1,110 @@
+package playlist
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		format  Format
+		content string
+		want    []Entry
+	}{
+		{
+			name:   "extended m3u",
+			format: M3U8,
+			content: "\ufeff#EXTM3U\n#EXTINF:123,Artist - Song\nmusic/song.mp3\n\n# comment\n" +
+				"#EXTINF:-1 tvg-id=\"x\",Radio, live\nhttps://radio.example/stream\nfile:///srv/media/clip.mp4\n",
+			want: []Entry{
+				{Location: "music/song.mp3", Title: "Artist - Song"},
+				{Location: "https://radio.example/stream", Title: "Radio, live"},
+				{Location: filepath.FromSlash("/srv/media/clip.mp4")},
+			},
+		},
+		{
+			name:    "latin-1 m3u",
+			format:  M3U,
+			content: "#EXTINF:1,Caf\xe9\r\nCaf\xe9.mp3\r\n",
+			want:    []Entry{{Location: "Café.mp3", Title: "Café"}},
+		},
+		{
+			name:    "pls out of order with gaps",
+			format:  PLS,
+			content: "[playlist]\nFile3=https://radio.example/b?x=1;y=2\nTitle3=B # second\nFile1=a.mp3\nTitle1=A\nNumberOfEntries=2\nVersion=2\n",
+			want: []Entry{
+				{Location: "a.mp3", Title: "A"},
+				{Location: "https://radio.example/b?x=1;y=2", Title: "B # second"},
+			},
+		},
+		{
+			name:   "xspf",
+			format: XSPF,
+			content: `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/"><trackList>
+<track><location>Music/My%20Song.flac</location><location>https://mirror.example/song.flac</location><title>My Song</title></track>
+<track><title>no location</title></track>
+<track><location>file:///srv/media/a%20b.mkv</location></track>
+</trackList></playlist>`,
+			want: []Entry{
+				{Location: "Music/My Song.flac", Title: "My Song"},
+				{Location: filepath.FromSlash("/srv/media/a b.mkv")},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := Parse(test.format, []byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("entries = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteRoundTrips(t *testing.T) {
+	t.Parallel()
+	entries := []Entry{
+		{Location: "Music/My Song.flac", Title: "My Song"},
+		{Location: "https://radio.example/stream?id=1&q=2", Title: "Radio <live> & more"},
+		{Location: filepath.Join(t.TempDir(), "a b.mkv")},
+	}
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, entries); err != nil {
+			t.Fatalf("%s write: %v", format, err)
+		}
+		got, err := Parse(format, buf.Bytes())
+		if err != nil || !slices.Equal(got, entries) {
+			t.Fatalf("%s round trip = %#v, %v\n%s", format, got, err, buf.String())
+		}
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	t.Parallel()
+	if format, err := FormatForName("Mix.M3U8"); err != nil || format != M3U8 {
+		t.Fatalf("FormatForName = %q, %v", format, err)
+	}
+	if _, err := FormatForName("mix.txt"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("unknown extension error = %v", err)
+	}
+	if _, err := Parse(XSPF, []byte("<playlist><trackList>")); !errors.Is(err, ErrInvalidPlaylist) {
+		t.Fatalf("broken XSPF error = %v", err)
+	}
+	if _, err := Parse(PLS, []byte("File1=a.mp3\n")); !errors.Is(err, ErrInvalidPlaylist) {
+		t.Fatalf("PLS without section error = %v", err)
+	}
+	if IsRemote("/srv/a.mp3") || IsRemote("ftp://host/a.mp3") || !IsRemote("https://host/a.mp3") {
+		t.Fatal("IsRemote misclassified a location")
+	}
+}
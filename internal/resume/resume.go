@@ -0,0 +1,324 @@
+// Package resume remembers where playback of media stopped, so that a later
+// session can continue from there, and which files were watched to the end. It
+// holds the rules both the desktop app and the controller apply when deciding
+// what to remember, and a file store keyed by library location for server mode.
+package resume
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/utils"
+)
+
+const (
+	// MinPosition is the earliest position, in seconds, worth resuming from.
+	MinPosition = 10
+	// SaveInterval and MinDelta throttle saves while playback runs.
+	SaveInterval = 5 * time.Second
+	MinDelta     = 5
+	// Playback counts as finished past CompletionProgress of the duration or
+	// within CompletionRemaining seconds of the end.
+	CompletionProgress  = 0.95
+	CompletionRemaining = 30
+	// MaxEntries bounds the saved positions and MaxRecords the whole store;
+	// the least recently played go first.
+	MaxEntries = 100
+	MaxRecords = 10000
+)
+
+const fileVersion = 1
+
+// flushDelay debounces writes of the store file, so the saves of a playing
+// session and a batch of watched marks cost one write.
+const flushDelay = 2 * time.Second
+
+// Entry is the playback history of one library file. Position is 0 when there
+// is nothing to resume; Plays counts plays to the end.
+type Entry struct {
+	Key       library.Location `json:"key"`
+	Name      string           `json:"name"`
+	Kind      string           `json:"kind"`
+	Position  int              `json:"position"`
+	Duration  int              `json:"duration,omitempty"`
+	Watched   bool             `json:"watched,omitempty"`
+	Plays     int              `json:"plays,omitempty"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// Progress reports the entry in the form the library browser lists.
+func (e Entry) Progress() library.Progress {
+	return library.Progress{Watched: e.Watched, Position: e.Position, Duration: e.Duration, Plays: e.Plays}
+}
+
+// Finished reports whether playback at position has reached the end closely
+// enough that there is nothing left to resume.
+func Finished(position int, duration float64) bool {
+	if position < MinPosition || duration <= 0 {
+		return false
+	}
+	return float64(position)/duration >= CompletionProgress || duration-float64(position) <= CompletionRemaining
+}
+
+// ShouldSave reports whether position is worth writing, given the last saved
+// position and when it was saved. force skips the throttle, as when playback
+// stops.
+func ShouldSave(position int, duration float64, lastSaved int, lastSavedAt, now time.Time, force bool) bool {
+	if position < MinPosition || Finished(position, duration) {
+		return false
+	}
+	if force {
+		return position != lastSaved
+	}
+	if !lastSavedAt.IsZero() && now.Sub(lastSavedAt) < SaveInterval {
+		return false
+	}
+	delta := position - lastSaved
+	return delta >= MinDelta || delta <= -MinDelta
+}
+
+type file struct {
+	Version int     `json:"version"`
+	Entries []Entry `json:"entries"`
+}
+
+// Store keeps entries in a JSON file. An empty path keeps them in memory
+// only. Changes apply in memory at once and reach the file flushDelay later
+// from a background write, so callers never wait on the disk. It is safe for
+// concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	entries []Entry
+	index   map[library.Location]int
+	dirty   bool
+	closed  bool
+	timer   *time.Timer
+	// err is the last failed write; changes keep reporting it until a write
+	// succeeds.
+	err error
+	// writeMu keeps writes in the order their snapshots were taken.
+	writeMu sync.Mutex
+}
+
+// Open loads the store at path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	store := &Store{path: path, index: map[library.Location]int{}}
+	if path == "" {
+		return store, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved file
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	if saved.Version != fileVersion {
+		return nil, fmt.Errorf("unsupported resume history version %d", saved.Version)
+	}
+	store.setEntries(prune(saved.Entries))
+	return store, nil
+}
+
+// Find returns the entry saved for key.
+func (s *Store) Find(key library.Location) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.find(key)
+}
+
+// Progress returns what is known about key, for library.Config.Progress.
+func (s *Store) Progress(key library.Location) (library.Progress, bool) {
+	entry, ok := s.Find(key)
+	return entry.Progress(), ok
+}
+
+// Save records the position in entry. The watched state and play count
+// already saved for the key are kept.
+func (s *Store) Save(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if saved, ok := s.find(entry.Key); ok {
+		entry.Watched, entry.Plays = saved.Watched, saved.Plays
+	}
+	return s.put(entry)
+}
+
+// Finish records a play to the end of entry's file: the position is cleared,
+// the file counts as watched and its play count goes up.
+func (s *Store) Finish(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plays := 0
+	if saved, ok := s.find(entry.Key); ok {
+		plays = saved.Plays
+	}
+	entry.Position, entry.Watched, entry.Plays = 0, true, plays+1
+	return s.put(entry)
+}
+
+// SetWatched marks keys watched, clearing any saved position, or forgets
+// them entirely when watched is false.
+func (s *Store) SetWatched(keys []library.Location, watched bool, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	marked := make(map[library.Location]bool, len(keys))
+	for _, key := range keys {
+		marked[key] = true
+	}
+	entries := slices.DeleteFunc(slices.Clone(s.entries), func(entry Entry) bool { return marked[entry.Key] })
+	if watched {
+		for _, key := range keys {
+			entry, ok := s.find(key)
+			if !ok {
+				entry = Entry{Key: key, Name: path.Base(key.Path)}
+			}
+			entry.Position, entry.Watched, entry.UpdatedAt = 0, true, now
+			entries = append(entries, entry)
+		}
+	}
+	return s.replace(entries)
+}
+
+// Remove forgets key. Removing an unknown key is not an error.
+func (s *Store) Remove(key library.Location) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.find(key); !ok {
+		return nil
+	}
+	return s.replace(slices.DeleteFunc(slices.Clone(s.entries), func(entry Entry) bool { return entry.Key == key }))
+}
+
+// Recent returns up to limit entries with a saved position, most recently
+// played first.
+func (s *Store) Recent(limit int) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recent := make([]Entry, 0, min(limit, len(s.entries)))
+	for _, entry := range s.entries {
+		if len(recent) == limit {
+			break
+		}
+		if entry.Position > 0 {
+			recent = append(recent, entry)
+		}
+	}
+	return recent
+}
+
+func (s *Store) find(key library.Location) (Entry, bool) {
+	index, ok := s.index[key]
+	if !ok {
+		return Entry{}, false
+	}
+	return s.entries[index], true
+}
+
+func (s *Store) put(entry Entry) error {
+	entries := slices.DeleteFunc(slices.Clone(s.entries), func(saved Entry) bool { return saved.Key == entry.Key })
+	return s.replace(append(entries, entry))
+}
+
+func (s *Store) replace(entries []Entry) error {
+	s.setEntries(prune(entries))
+	s.markDirty()
+	return s.err
+}
+
+// markDirty schedules a write of the file.
+func (s *Store) markDirty() {
+	if s.path == "" {
+		return
+	}
+	s.dirty = true
+	if s.timer == nil && !s.closed {
+		s.timer = time.AfterFunc(flushDelay, func() { _ = s.Flush() })
+	}
+}
+
+// Flush writes pending changes now. A failed write is retried after
+// flushDelay.
+func (s *Store) Flush() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	s.dirty = false
+	saved := file{Version: fileVersion, Entries: slices.Clone(s.entries)}
+	s.mu.Unlock()
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err == nil {
+		err = utils.WriteFileAtomic(s.path, append(data, '\n'))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	if err != nil {
+		s.markDirty()
+	}
+	return err
+}
+
+// Close writes pending changes and stops background writes. Later changes
+// are kept in memory only.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.Flush()
+}
+
+func (s *Store) setEntries(entries []Entry) {
+	s.entries = entries
+	clear(s.index)
+	for index, entry := range entries {
+		s.index[entry.Key] = index
+	}
+}
+
+// prune orders entries newest first. Positions beyond the newest MaxEntries
+// are dropped, as are entries left with nothing to record, and the oldest
+// entries beyond MaxRecords.
+func prune(entries []Entry) []Entry {
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key.Root+"/"+a.Key.Path, b.Key.Root+"/"+b.Key.Path)
+	})
+	positions := 0
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.Position > 0 {
+			if positions >= MaxEntries {
+				entry.Position = 0
+			}
+			positions++
+		}
+		if entry.Position > 0 || entry.Watched || entry.Plays > 0 {
+			kept = append(kept, entry)
+		}
+	}
+	return kept[:min(len(kept), MaxRecords)]
+}
@@ -0,0 +1,201 @@
+package resume
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/library"
+)
+
+func TestStorePersistsNewestFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC)
+	movie := library.Location{Root: "/media", Path: "movie.mkv"}
+	show := library.Location{Root: "/media", Path: "show/e01.mkv"}
+	for _, entry := range []Entry{
+		{Key: movie, Name: "movie.mkv", Kind: "video", Position: 60, UpdatedAt: base},
+		{Key: show, Name: "e01.mkv", Kind: "video", Position: 30, UpdatedAt: base.Add(time.Minute)},
+		{Key: movie, Name: "movie.mkv", Kind: "video", Position: 120, Duration: 5400, UpdatedAt: base.Add(2 * time.Minute)},
+	} {
+		if err := store.Save(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("saves written without waiting for the flush: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent := reopened.Recent(10)
+	if len(recent) != 2 || recent[0].Key != movie || recent[0].Position != 120 || recent[1].Key != show {
+		t.Fatalf("recent = %+v", recent)
+	}
+	if entry, ok := reopened.Find(show); !ok || entry.Position != 30 {
+		t.Fatalf("find = %+v, %v", entry, ok)
+	}
+	if err := reopened.Remove(show); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Find(show); ok {
+		t.Fatal("removed entry still found")
+	}
+	if recent := reopened.Recent(1); len(recent) != 1 || recent[0].Key != movie {
+		t.Fatalf("recent after removal = %+v", recent)
+	}
+}
+
+func TestStoreBoundsEntries(t *testing.T) {
+	store, _ := Open("")
+	base := time.Now()
+	for i := range MaxEntries + 5 {
+		key := library.Location{Root: "/media", Path: strconv.Itoa(i) + ".mp3"}
+		if err := store.Save(Entry{Key: key, Position: 20, UpdatedAt: base.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recent := store.Recent(MaxEntries * 2)
+	if len(recent) != MaxEntries || recent[0].Key.Path != strconv.Itoa(MaxEntries+4)+".mp3" {
+		t.Fatalf("kept %d entries, newest %+v", len(recent), recent[0])
+	}
+	if _, ok := store.Find(library.Location{Root: "/media", Path: "0.mp3"}); ok {
+		t.Fatal("oldest entry kept")
+	}
+}
+
+func TestWatchedStateAndPlays(t *testing.T) {
+	store, _ := Open("")
+	now := time.Now()
+	movie := library.Location{Root: "/media", Path: "movie.mkv"}
+	show := library.Location{Root: "/media", Path: "show/e01.mkv"}
+	if err := store.Save(Entry{Key: movie, Name: "movie.mkv", Position: 600, Duration: 5400, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Finish(Entry{Key: movie, Name: "movie.mkv", Duration: 5400, UpdatedAt: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if progress, ok := store.Progress(movie); !ok || progress != (library.Progress{Watched: true, Duration: 5400, Plays: 1}) {
+		t.Fatalf("finished progress = %+v, %v", progress, ok)
+	}
+	if recent := store.Recent(10); len(recent) != 0 {
+		t.Fatalf("finished file still in progress: %+v", recent)
+	}
+	// Watching again keeps the count and the watched mark.
+	if err := store.Save(Entry{Key: movie, Name: "movie.mkv", Position: 300, Duration: 5400, UpdatedAt: now.Add(2 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if progress, _ := store.Progress(movie); progress != (library.Progress{Watched: true, Position: 300, Duration: 5400, Plays: 1}) {
+		t.Fatalf("rewatch progress = %+v", progress)
+	}
+
+	if err := store.SetWatched([]library.Location{movie, show}, true, now.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := store.Find(show); !ok || !entry.Watched || entry.Name != "e01.mkv" || entry.Plays != 0 {
+		t.Fatalf("marked entry = %+v, %v", entry, ok)
+	}
+	if entry, _ := store.Find(movie); entry.Position != 0 || entry.Plays != 1 {
+		t.Fatalf("marked watched = %+v", entry)
+	}
+	if err := store.SetWatched([]library.Location{movie, show}, false, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Find(movie); ok {
+		t.Fatal("unwatched entry kept")
+	}
+}
+
+func TestOpenRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(path, []byte(`{"version":9,"entries":[]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Fatal("unknown version accepted")
+	}
+}
+
+func TestSavePolicy(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		name      string
+		position  int
+		duration  float64
+		lastSaved int
+		savedAt   time.Time
+		force     bool
+		want      bool
+	}{
+		{name: "too early", position: 5, duration: 600},
+		{name: "first save", position: 60, duration: 600, want: true},
+		{name: "throttled", position: 70, duration: 600, lastSaved: 60, savedAt: now.Add(-time.Second)},
+		{name: "small move", position: 62, duration: 600, lastSaved: 60, savedAt: now.Add(-time.Minute)},
+		{name: "seek back", position: 30, duration: 600, lastSaved: 60, savedAt: now.Add(-time.Minute), want: true},
+		{name: "forced", position: 61, duration: 600, lastSaved: 60, savedAt: now, force: true, want: true},
+		{name: "finished", position: 590, duration: 600, force: true},
+	} {
+		if got := ShouldSave(tc.position, tc.duration, tc.lastSaved, tc.savedAt, now, tc.force); got != tc.want {
+			t.Errorf("%s = %v", tc.name, got)
+		}
+	}
+	if Finished(100, 0) || !Finished(580, 600) || !Finished(1000, 1050) || Finished(300, 600) {
+		t.Fatal("completion rules changed")
+	}
+}
+
+func TestStoreFlushesInBackground(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.json")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	movie := library.Location{Root: "/media", Path: "movie.mkv"}
+	if err := store.Save(Entry{Key: movie, Position: 60, UpdatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(flushDelay + 5*time.Second)
+	for {
+		if reopened, err := Open(path); err == nil {
+			if entry, ok := reopened.Find(movie); ok && entry.Position == 60 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("history not written in the background")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// A failed write is reported by the next change.
+	blocked, err := Open(filepath.Join(dir, "blocked", "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blocked.Close()
+	if err := os.WriteFile(filepath.Join(dir, "blocked"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := blocked.Save(Entry{Key: movie, Position: 60, UpdatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := blocked.Flush(); err == nil {
+		t.Fatal("write under a file succeeded")
+	}
+	if err := blocked.Save(Entry{Key: movie, Position: 90, UpdatedAt: time.Now()}); err == nil {
+		t.Fatal("failed write not reported")
+	}
+}
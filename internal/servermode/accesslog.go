@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+
+	"go2tv.app/go2tv/v2/internal/webui"
 )
 
 type statusWriter struct {
@@ -58,6 +60,10 @@ func routeTemplate(path string) string {
 		return "/assets/{asset}"
 	case path == "/api/bootstrap":
 		return "/api/bootstrap"
+	case path == loginPath:
+		return loginPath
+	case path == metricsPath:
+		return metricsPath
 	case path == "/api/library":
 		return "/api/library"
 	case path == "/api/thumbnail":
@@ -68,6 +74,10 @@ func routeTemplate(path string) string {
 		return "/api/artwork/{content-id}.jpg"
 	case path == "/api/ws":
 		return "/api/ws"
+	case path == webui.OpenAPIPath, webui.KnownAPIRoute(path):
+		return path
+	case strings.HasPrefix(path, webui.APIPrefix):
+		return webui.APIPrefix + "{unmatched}"
 	default:
 		return "{unmatched}"
 	}
@@ -44,6 +44,8 @@ func TestAccessLogReportsFailuresWithoutDynamicPaths(t *testing.T) {
 	}{
 		{name: "known route", path: "/api/thumbnail", status: http.StatusBadRequest, wantRoute: "/api/thumbnail"},
 		{name: "artwork ID", path: "/api/artwork/private-id.jpg", status: http.StatusInternalServerError, wantRoute: "/api/artwork/{content-id}.jpg", forbidden: "private-id"},
+		{name: "versioned API", path: "/api/v1/player/seek", status: http.StatusConflict, wantRoute: "/api/v1/player/seek"},
+		{name: "unknown versioned API", path: "/api/v1/private", status: http.StatusMethodNotAllowed, wantRoute: "/api/v1/{unmatched}", forbidden: "private"},
 		{name: "unmatched", path: "/private/path", status: http.StatusMethodNotAllowed, wantRoute: "{unmatched}", forbidden: "/private/path"},
 	}
 	for _, tt := range tests {
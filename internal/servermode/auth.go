@@ -0,0 +1,203 @@
+package servermode
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go2tv.app/go2tv/v2/utils"
+)
+
+// Scope limits what an API token may do.
+type Scope string
+
+const (
+	ScopeRead    Scope = "read"
+	ScopeControl Scope = "control"
+)
+
+const (
+	tokenPrefix       = "g2t_"
+	passwordIter      = 600_000
+	minPasswordLength = 8
+	maxTokenName      = 64
+)
+
+var (
+	ErrInvalidScope     = errors.New("invalid token scope")
+	ErrInvalidTokenName = errors.New("invalid token name")
+	ErrTokenExists      = errors.New("token name already exists")
+	ErrTokenNotFound    = errors.New("token not found")
+	ErrWeakPassword     = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+)
+
+// TokenRecord is a named API token. Only the SHA-256 of the token is stored;
+// the plaintext is shown once, when the token is created.
+type TokenRecord struct {
+	Name    string    `json:"name"`
+	Scope   Scope     `json:"scope"`
+	Hash    string    `json:"hash"`
+	Created time.Time `json:"created"`
+}
+
+// PasswordHash is a PBKDF2-SHA256 login password.
+type PasswordHash struct {
+	Salt       string `json:"salt"`
+	Hash       string `json:"hash"`
+	Iterations int    `json:"iterations"`
+}
+
+// AuthStore holds the server-mode credentials persisted in the auth file.
+type AuthStore struct {
+	Tokens   []TokenRecord `json:"tokens,omitempty"`
+	Password *PasswordHash `json:"password,omitempty"`
+}
+
+// DefaultAuthPath is the auth file used when -auth-file is not given.
+func DefaultAuthPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go2tv", "server-auth.json"), nil
+}
+
+// LoadAuth reads an auth file. A missing file is an empty store.
+func LoadAuth(path string) (AuthStore, error) {
+	var store AuthStore
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return store, fmt.Errorf("auth file: %w", err)
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return AuthStore{}, fmt.Errorf("auth file %q: %w", path, err)
+	}
+	return store, nil
+}
+
+// SaveAuth replaces the auth file, readable by the owner only.
+func SaveAuth(path string, store AuthStore) error {
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("auth file: %w", err)
+	}
+	return nil
+}
+
+func ParseScope(value string) (Scope, error) {
+	switch Scope(value) {
+	case ScopeRead, ScopeControl:
+		return Scope(value), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidScope, value)
+	}
+}
+
+// CreateToken adds a token and returns its plaintext value.
+func (s *AuthStore) CreateToken(name string, scope Scope, random io.Reader, now time.Time) (string, error) {
+	if name == "" || len(name) > maxTokenName || strings.TrimSpace(name) != name || strings.ContainsFunc(name, func(r rune) bool { return r < ' ' }) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTokenName, name)
+	}
+	if _, err := ParseScope(string(scope)); err != nil {
+		return "", err
+	}
+	if slices.ContainsFunc(s.Tokens, func(token TokenRecord) bool { return token.Name == name }) {
+		return "", fmt.Errorf("%w: %q", ErrTokenExists, name)
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(random, secret); err != nil {
+		return "", ErrSecretGeneration
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	s.Tokens = append(s.Tokens, TokenRecord{Name: name, Scope: scope, Hash: tokenHash(token), Created: now.UTC().Truncate(time.Second)})
+	return token, nil
+}
+
+func (s *AuthStore) RevokeToken(name string) error {
+	index := slices.IndexFunc(s.Tokens, func(token TokenRecord) bool { return token.Name == name })
+	if index < 0 {
+		return fmt.Errorf("%w: %q", ErrTokenNotFound, name)
+	}
+	s.Tokens = slices.Delete(s.Tokens, index, index+1)
+	return nil
+}
+
+// SetPassword enables the login page. An empty password disables it.
+func (s *AuthStore) SetPassword(password string, random io.Reader) error {
+	if password == "" {
+		s.Password = nil
+		return nil
+	}
+	if len([]rune(password)) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return ErrSecretGeneration
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIter, sha256.Size)
+	if err != nil {
+		return err
+	}
+	s.Password = &PasswordHash{Salt: hex.EncodeToString(salt), Hash: hex.EncodeToString(key), Iterations: passwordIter}
+	return nil
+}
+
+// token returns the record matching a presented bearer token.
+func (s AuthStore) token(presented string) (TokenRecord, bool) {
+	if !strings.HasPrefix(presented, tokenPrefix) {
+		return TokenRecord{}, false
+	}
+	hash := tokenHash(presented)
+	for _, record := range s.Tokens {
+		if subtle.ConstantTimeCompare([]byte(record.Hash), []byte(hash)) == 1 {
+			return record, true
+		}
+	}
+	return TokenRecord{}, false
+}
+
+func (s AuthStore) checkPassword(password string) bool {
+	if s.Password == nil || s.Password.Iterations <= 0 {
+		return false
+	}
+	salt, err := hex.DecodeString(s.Password.Salt)
+	if err != nil {
+		return false
+	}
+	want, err := hex.DecodeString(s.Password.Hash)
+	if err != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, s.Password.Iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(key, want) == 1
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,104 @@
+package servermode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+)
+
+var ErrAuthFileUnavailable = errors.New("auth file location unavailable; pass -auth-file")
+
+// RunAuthCommand performs the token or password action selected on the
+// command line. It reports false when no such action was requested, in which
+// case the caller should start the server as usual.
+func (o *CLIOptions) RunAuthCommand(input io.Reader, output io.Writer) (bool, error) {
+	if !o.Server || (o.TokenCreate == "" && o.TokenRevoke == "" && !o.TokenList && !o.SetPassword && !o.ClearPass) {
+		return false, nil
+	}
+	path := o.authPath()
+	if path == "" {
+		return true, ErrAuthFileUnavailable
+	}
+	store, err := LoadAuth(path)
+	if err != nil {
+		return true, err
+	}
+	switch {
+	case o.TokenList:
+		writer := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tSCOPE\tCREATED")
+		for _, token := range store.Tokens {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", token.Name, token.Scope, token.Created.Format(time.RFC3339))
+		}
+		return true, writer.Flush()
+	case o.TokenCreate != "":
+		scope, err := ParseScope(o.TokenScope)
+		if err != nil {
+			return true, err
+		}
+		token, err := store.CreateToken(o.TokenCreate, scope, nil, time.Now())
+		if err != nil {
+			return true, err
+		}
+		if err := SaveAuth(path, store); err != nil {
+			return true, err
+		}
+		fmt.Fprintf(output, "Created %s token %q. It is shown only once:\n%s\n", scope, o.TokenCreate, token)
+		return true, nil
+	case o.TokenRevoke != "":
+		if err := store.RevokeToken(o.TokenRevoke); err != nil {
+			return true, err
+		}
+		if err := SaveAuth(path, store); err != nil {
+			return true, err
+		}
+		fmt.Fprintf(output, "Revoked token %q.\n", o.TokenRevoke)
+		return true, nil
+	case o.ClearPass:
+		_ = store.SetPassword("", nil)
+		if err := SaveAuth(path, store); err != nil {
+			return true, err
+		}
+		fmt.Fprintln(output, "Web login password disabled.")
+		return true, nil
+	default:
+		password, err := readPassword(input, output)
+		if err != nil {
+			return true, err
+		}
+		if password == "" {
+			return true, ErrWeakPassword
+		}
+		if err := store.SetPassword(password, nil); err != nil {
+			return true, err
+		}
+		if err := SaveAuth(path, store); err != nil {
+			return true, err
+		}
+		fmt.Fprintln(output, "Web login password set.")
+		return true, nil
+	}
+}
+
+// readPassword reads one line from input, without echo when input is a
+// terminal.
+func readPassword(input io.Reader, output io.Writer) (string, error) {
+	if file, ok := input.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		fmt.Fprint(output, "New web login password: ")
+		password, err := term.ReadPassword(int(file.Fd()))
+		fmt.Fprintln(output)
+		return string(password), err
+	}
+	line, err := bufio.NewReader(input).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,401 @@
+package servermode
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthStoreTokensAndPersistence(t *testing.T) {
+	t.Parallel()
+	var store AuthStore
+	token, err := store.CreateToken("stream-deck", ScopeRead, bytes.NewReader(bytes.Repeat([]byte{1}, 32)), time.Unix(100, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, tokenPrefix) || strings.Contains(store.Tokens[0].Hash, strings.TrimPrefix(token, tokenPrefix)) {
+		t.Fatalf("token %q stored as %#v", token, store.Tokens[0])
+	}
+	if _, err := store.CreateToken("stream-deck", ScopeControl, nil, time.Now()); !errors.Is(err, ErrTokenExists) {
+		t.Fatalf("duplicate error = %v", err)
+	}
+	if _, err := store.CreateToken(" padded", ScopeControl, nil, time.Now()); !errors.Is(err, ErrInvalidTokenName) {
+		t.Fatalf("name error = %v", err)
+	}
+	if _, err := store.CreateToken("admin", "root", nil, time.Now()); !errors.Is(err, ErrInvalidScope) {
+		t.Fatalf("scope error = %v", err)
+	}
+	if err := store.SetPassword("short", nil); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("password error = %v", err)
+	}
+	if err := store.SetPassword("correct horse", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "nested", "server-auth.json")
+	if err := SaveAuth(path, store); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || (goruntime.GOOS != "windows" && info.Mode().Perm() != 0o600) {
+		t.Fatalf("auth file mode = %v, err = %v", info, err)
+	}
+	loaded, err := LoadAuth(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record, ok := loaded.token(token); !ok || record.Name != "stream-deck" || record.Scope != ScopeRead {
+		t.Fatalf("token lookup = %#v %v", record, ok)
+	}
+	if _, ok := loaded.token(token + "x"); ok {
+		t.Fatal("altered token accepted")
+	}
+	if !loaded.checkPassword("correct horse") || loaded.checkPassword("wrong horse") {
+		t.Fatal("password check mismatch")
+	}
+	if err := loaded.RevokeToken("stream-deck"); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.RevokeToken("stream-deck"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("revoke error = %v", err)
+	}
+	if missing, err := LoadAuth(filepath.Join(t.TempDir(), "missing.json")); err != nil || len(missing.Tokens) != 0 || missing.Password != nil {
+		t.Fatalf("missing file = %#v, %v", missing, err)
+	}
+}
+
+func TestRunAuthCommand(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "server-auth.json")
+	run := func(input string, args ...string) (string, error) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		options := RegisterCLIFlags(flags)
+		if err := flags.Parse(append([]string{"-server", "-auth-file", path}, args...)); err != nil {
+			t.Fatal(err)
+		}
+		if err := options.Validate(flags); err != nil {
+			return "", err
+		}
+		var output bytes.Buffer
+		handled, err := options.RunAuthCommand(strings.NewReader(input), &output)
+		if !handled {
+			t.Fatalf("%v not handled", args)
+		}
+		return output.String(), err
+	}
+	output, err := run("", "-token-create", "home-assistant", "-token-scope", "read")
+	if err != nil || !strings.Contains(output, tokenPrefix) {
+		t.Fatalf("create = %q, %v", output, err)
+	}
+	if output, err = run("", "-token-list"); err != nil || !strings.Contains(output, "home-assistant  read") {
+		t.Fatalf("list = %q, %v", output, err)
+	}
+	if _, err = run("secret password\n", "-set-password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = run("", "-token-revoke", "home-assistant", "-token-list"); !errors.Is(err, ErrServerFlagConflict) {
+		t.Fatalf("two actions error = %v", err)
+	}
+	if _, err = run("", "-token-revoke", "home-assistant"); err != nil {
+		t.Fatal(err)
+	}
+	store, err := LoadAuth(path)
+	if err != nil || len(store.Tokens) != 0 || !store.checkPassword("secret password") {
+		t.Fatalf("store = %#v, %v", store, err)
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	options := RegisterCLIFlags(flags)
+	if err := flags.Parse([]string{"-token-list"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := options.Validate(flags); !errors.Is(err, ErrServerFlagWithoutMode) {
+		t.Fatalf("token flag without -server = %v", err)
+	}
+}
+
+type readOnlyRecorder struct {
+	http.Handler
+	marked bool
+}
+
+func (r *readOnlyRecorder) ReadOnly(req *http.Request) *http.Request {
+	r.marked = true
+	return req
+}
+
+func TestBearerTokensAndScopes(t *testing.T) {
+	t.Parallel()
+	var store AuthStore
+	readToken, err := store.CreateToken("read", ScopeRead, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	controlToken, err := store.CreateToken("control", ScopeControl, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "server-auth.json")
+	if err := SaveAuth(path, store); err != nil {
+		t.Fatal(err)
+	}
+	next := &readOnlyRecorder{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })}
+	handler, err := NewHandler(Config{Listen: DefaultListen, AuthFile: path}, nil, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		want          int
+		readOnly      bool
+	}{
+		{name: "control from another subnet", method: http.MethodPost, path: "/api/v1/player/pause", authorization: "Bearer " + controlToken, want: http.StatusNoContent},
+		{name: "control websocket", method: http.MethodGet, path: "/api/ws", authorization: "bearer " + controlToken, want: http.StatusNoContent},
+		{name: "read state", method: http.MethodGet, path: "/api/v1/state", authorization: "Bearer " + readToken, want: http.StatusNoContent, readOnly: true},
+		{name: "read websocket", method: http.MethodGet, path: "/api/ws", authorization: "Bearer " + readToken, want: http.StatusNoContent, readOnly: true},
+		{name: "read cannot mutate", method: http.MethodPost, path: "/api/v1/player/pause", authorization: "Bearer " + readToken, want: http.StatusForbidden},
+		{name: "unknown token", method: http.MethodGet, path: "/api/v1/state", authorization: "Bearer g2t_unknown", want: http.StatusUnauthorized},
+		{name: "basic scheme", method: http.MethodGet, path: "/api/v1/state", authorization: "Basic " + controlToken, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next.marked = false
+			req := httptest.NewRequest(tt.method, "http://192.168.7.20:9666"+tt.path, nil)
+			req.Header.Set("Authorization", tt.authorization)
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+			if res.Code != tt.want || next.marked != tt.readOnly {
+				t.Fatalf("status = %d, read-only = %v", res.Code, next.marked)
+			}
+		})
+	}
+}
+
+func TestMetricsBehindSecurity(t *testing.T) {
+	t.Parallel()
+	var store AuthStore
+	readToken, err := store.CreateToken("prometheus", ScopeRead, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "server-auth.json")
+	if err := SaveAuth(path, store); err != nil {
+		t.Fatal(err)
+	}
+	handler, err := newSecurityHandler(Config{Listen: DefaultListen, AuthFile: path}, nil, http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.metrics = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	for _, tt := range []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "anonymous", want: http.StatusForbidden},
+		{name: "read token", authorization: "Bearer " + readToken, want: http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:9666/metrics", nil)
+		req.Host = "127.0.0.1:9666"
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		if res.Code != tt.want {
+			t.Fatalf("%s: status = %d, want %d", tt.name, res.Code, tt.want)
+		}
+	}
+}
+
+func TestPasswordLogin(t *testing.T) {
+	t.Parallel()
+	var store AuthStore
+	if err := store.SetPassword("correct horse", nil); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "server-auth.json")
+	if err := SaveAuth(path, store); err != nil {
+		t.Fatal(err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	handler, err := NewHandler(Config{Listen: DefaultListen, AuthFile: path}, nil, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(method, target, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://127.0.0.1:9666"+target, strings.NewReader(body))
+		req.Header.Set("Origin", "http://127.0.0.1:9666")
+		if body != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+	if res := request(http.MethodGet, "/", "", nil); res.Code != http.StatusSeeOther || res.Header().Get("Location") != loginPath {
+		t.Fatalf("page without login = %d %q", res.Code, res.Header().Get("Location"))
+	}
+	if res := request(http.MethodGet, "/api/bootstrap", "", nil); res.Code != http.StatusUnauthorized || len(res.Result().Cookies()) != 0 {
+		t.Fatalf("bootstrap without login = %d", res.Code)
+	}
+	if res := request(http.MethodGet, loginPath, "", nil); res.Code != http.StatusOK || !strings.Contains(res.Body.String(), `name="password"`) {
+		t.Fatalf("login page = %d", res.Code)
+	}
+	if res := request(http.MethodPost, loginPath, url.Values{"password": {"wrong horse"}}.Encode(), nil); res.Code != http.StatusUnauthorized || len(res.Result().Cookies()) != 0 {
+		t.Fatalf("wrong password = %d", res.Code)
+	}
+	res := request(http.MethodPost, loginPath, url.Values{"password": {"correct horse"}}.Encode(), nil)
+	cookies := res.Result().Cookies()
+	if res.Code != http.StatusSeeOther || len(cookies) != 1 || cookies[0].Path != "/" {
+		t.Fatalf("login = %d %#v", res.Code, cookies)
+	}
+	if res := request(http.MethodGet, "/", "", cookies[0]); res.Code != http.StatusNoContent {
+		t.Fatalf("page after login = %d", res.Code)
+	}
+	if res := request(http.MethodGet, "/api/bootstrap", "", cookies[0]); res.Code != http.StatusOK {
+		t.Fatalf("bootstrap after login = %d", res.Code)
+	}
+}
+
+// credentialRecorder keeps the last credential check the security layer
+// attached and counts the rechecks it requested.
+type credentialRecorder struct {
+	http.Handler
+	valid    func() bool
+	rechecks int
+}
+
+func (r *credentialRecorder) Credential(req *http.Request, valid func() bool) *http.Request {
+	r.valid = valid
+	return req
+}
+
+func (r *credentialRecorder) RecheckCredentials() { r.rechecks++ }
+
+func TestAuthFileCheckedOncePerInterval(t *testing.T) {
+	t.Parallel()
+	var store AuthStore
+	token, err := store.CreateToken("phone", ScopeControl, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "server-auth.json")
+	if err := SaveAuth(path, store); err != nil {
+		t.Fatal(err)
+	}
+	handler, err := newSecurityHandler(Config{Listen: DefaultListen, AuthFile: path}, nil, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.authEvery = time.Hour
+	request := func() int {
+		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:9666/api/v1/state", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+	if code := request(); code != http.StatusNoContent {
+		t.Fatalf("first request = %d", code)
+	}
+	if err := store.RevokeToken("phone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveAuth(path, store); err != nil {
+		t.Fatal(err)
+	}
+	if code := request(); code != http.StatusNoContent {
+		t.Fatalf("request within the interval = %d, want the cached store", code)
+	}
+	handler.authNext.Store(0)
+	if code := request(); code != http.StatusUnauthorized {
+		t.Fatalf("request after the interval = %d", code)
+	}
+}
+
+func TestAuthChangesApplyToRunningServer(t *testing.T) {
+	t.Parallel()
+	var store AuthStore
+	token, err := store.CreateToken("phone", ScopeControl, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "server-auth.json")
+	if err := SaveAuth(path, store); err != nil {
+		t.Fatal(err)
+	}
+	next := &credentialRecorder{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })}
+	handler, err := newSecurityHandler(Config{Listen: DefaultListen, AuthFile: path}, nil, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.authEvery = 0
+	request := func(target, authorization string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:9666"+target, nil)
+		req.Header.Set("Origin", "http://127.0.0.1:9666")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+	if res := request("/api/v1/state", "Bearer "+token, nil); res.Code != http.StatusNoContent {
+		t.Fatalf("token before revoke = %d", res.Code)
+	}
+	tokenValid := next.valid
+	if tokenValid == nil || !tokenValid() {
+		t.Fatal("token request carries no valid credential check")
+	}
+	cookies := request("/api/bootstrap", "", nil).Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("bootstrap cookies = %#v", cookies)
+	}
+
+	if err := store.RevokeToken("phone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveAuth(path, store); err != nil {
+		t.Fatal(err)
+	}
+	if res := request("/api/v1/state", "Bearer "+token, nil); res.Code != http.StatusUnauthorized {
+		t.Fatalf("token after revoke = %d", res.Code)
+	}
+	if tokenValid() || next.rechecks != 1 {
+		t.Fatalf("revoked token valid = %v after %d rechecks", tokenValid(), next.rechecks)
+	}
+	if res := request("/api/v1/state", "", cookies[0]); res.Code != http.StatusNoContent {
+		t.Fatalf("browser session after revoke = %d", res.Code)
+	}
+
+	if err := store.SetPassword("correct horse", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveAuth(path, store); err != nil {
+		t.Fatal(err)
+	}
+	if res := request("/api/v1/state", "", cookies[0]); res.Code != http.StatusForbidden {
+		t.Fatalf("browser session after password change = %d", res.Code)
+	}
+	if res := request("/", "", nil); res.Code != http.StatusSeeOther {
+		t.Fatalf("page after password change = %d", res.Code)
+	}
+}
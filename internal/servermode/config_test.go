@@ -6,6 +6,11 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/mqtt"
+	"go2tv.app/go2tv/v2/internal/webhook"
 )
 
 func TestValidateCLIFlagMatrix(t *testing.T) {
@@ -78,6 +83,82 @@ func TestFFmpegFlagIsSharedByLegacyAndServerModes(t *testing.T) {
 	}
 }
 
+func TestPolicyFlagsRequireServer(t *testing.T) {
+	parse := func(args ...string) (*CLIOptions, error) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		options := RegisterCLIFlags(flags)
+		if err := flags.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return options, options.Validate(flags)
+	}
+	options, err := parse("-server", "-media-root", "root", "-repeat-all", "-shuffle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg := options.Config("test"); !cfg.RepeatAll || !cfg.Shuffle {
+		t.Fatalf("config = %#v", cfg)
+	}
+	if _, err := parse("-server", "-media-root", "root", "-repeat-all"); !errors.Is(err, controller.ErrInvalidPolicy) {
+		t.Fatalf("-repeat-all without -shuffle error = %v", err)
+	}
+	if _, err := parse("-shuffle"); !errors.Is(err, ErrServerFlagWithoutMode) {
+		t.Fatalf("-shuffle without -server error = %v", err)
+	}
+}
+
+func TestEmptyHistoryFileDisablesHistory(t *testing.T) {
+	config := func(args ...string) Config {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		options := RegisterCLIFlags(flags)
+		if err := flags.Parse(append([]string{"-server", "-media-root", "root"}, args...)); err != nil {
+			t.Fatal(err)
+		}
+		if err := options.Validate(flags); err != nil {
+			t.Fatal(err)
+		}
+		return options.Config("test")
+	}
+	if cfg := config(); cfg.HistoryFile == "" {
+		t.Fatal("history file is off by default")
+	}
+	if cfg := config("-history-file="); cfg.HistoryFile != "" {
+		t.Fatalf("-history-file= kept %q", cfg.HistoryFile)
+	}
+	if cfg := config("-history-file", "history.json"); cfg.HistoryFile != "history.json" {
+		t.Fatalf("flag history file = %q", cfg.HistoryFile)
+	}
+}
+
+func TestTimerFlags(t *testing.T) {
+	parse := func(args ...string) (*CLIOptions, error) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		options := RegisterCLIFlags(flags)
+		if err := flags.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return options, options.Validate(flags)
+	}
+	options, err := parse("-server", "-media-root", "root", "-sleep", "90m", "-start-at", "07:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg := options.Config("test"); cfg.SleepAfter != 90*time.Minute || cfg.StartAt != "07:00" {
+		t.Fatalf("config = %#v", cfg)
+	}
+	for _, args := range [][]string{
+		{"-server", "-media-root", "root", "-start-at", "7am"},
+		{"-server", "-media-root", "root", "-sleep", "25h"},
+	} {
+		if _, err := parse(args...); !errors.Is(err, ErrInvalidTimer) {
+			t.Fatalf("%v error = %v", args, err)
+		}
+	}
+	if _, err := parse("-sleep", "1h"); !errors.Is(err, ErrServerFlagWithoutMode) {
+		t.Fatalf("-sleep without -server error = %v", err)
+	}
+}
+
 func TestValidateConfig(t *testing.T) {
 	t.Parallel()
 	root := t.TempDir()
@@ -101,12 +182,27 @@ func TestValidateConfig(t *testing.T) {
 		{name: "missing port", cfg: Config{Listen: "127.0.0.1", MediaRoots: []string{root}}, wantErr: ErrInvalidListen},
 		{name: "origin missing port", cfg: Config{Listen: "0.0.0.0:9666", MediaRoots: []string{root}, AllowedOrigins: []string{"http://tv.local"}}, wantErr: ErrInvalidOrigin},
 		{name: "TLS unavailable", cfg: Config{Listen: "0.0.0.0:9666", MediaRoots: []string{root}, AllowedOrigins: []string{"https://tv.local:9666"}}, wantErr: ErrInvalidOrigin},
+		{name: "TLS origin", cfg: Config{Listen: "0.0.0.0:9666", MediaRoots: []string{root}, AllowedOrigins: []string{"https://tv.local:9666"}, TLSSelfSigned: true}},
+		{name: "plain origin under TLS", cfg: Config{Listen: "0.0.0.0:9666", MediaRoots: []string{root}, AllowedOrigins: []string{"http://tv.local:9666"}, TLSSelfSigned: true}, wantErr: ErrInvalidOrigin},
+		{name: "TLS cert without key", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, TLSCertFile: "cert.pem"}, wantErr: ErrInvalidTLS},
+		{name: "TLS unreadable pair", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, TLSCertFile: filepath.Join(root, "cert.pem"), TLSKeyFile: filepath.Join(root, "key.pem")}, wantErr: ErrInvalidTLS},
+		{name: "TLS with managed child", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, TLSSelfSigned: true, ManagedChild: true}, wantErr: ErrInvalidTLS},
 		{name: "null origin", cfg: Config{Listen: "0.0.0.0:9666", MediaRoots: []string{root}, AllowedOrigins: []string{"null"}}, wantErr: ErrInvalidOrigin},
 		{name: "duplicate origin", cfg: Config{Listen: "0.0.0.0:9666", MediaRoots: []string{root}, AllowedOrigins: []string{"http://TV.local:9666", "http://tv.local:9666"}}, wantErr: ErrInvalidOrigin},
 		{name: "duplicate root", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root, root}}, wantErr: ErrInvalidMediaRoot},
 		{name: "aliased root", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root, alias}}, wantErr: ErrInvalidMediaRoot},
 		{name: "nested roots", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root, child}}},
 		{name: "missing root", cfg: Config{Listen: DefaultListen}, wantErr: ErrInvalidMediaRoot},
+		{name: "webhook", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, Webhooks: []webhook.Endpoint{{URL: "https://hooks.local/tv", Events: []string{webhook.EventSessionStarted}}}}},
+		{name: "relative webhook", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, Webhooks: []webhook.Endpoint{{URL: "/tv"}}}, wantErr: webhook.ErrInvalidEndpoint},
+		{name: "mqtt", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, MQTT: MQTTConfig{Broker: "mqtts://broker.local"}}},
+		{name: "mqtt scheme", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, MQTT: MQTTConfig{Broker: "broker.local:1883"}}, wantErr: mqtt.ErrInvalidBroker},
+		{name: "metrics listen", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, MetricsListen: ":9667"}},
+		{name: "metrics hostname", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, MetricsListen: "metrics.test:9667"}, wantErr: ErrInvalidListen},
+		{name: "upnp listen", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, UPnPListen: ":8201"}},
+		{name: "upnp no port", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, UPnPListen: "0.0.0.0"}, wantErr: ErrInvalidListen},
+		{name: "renderer listen", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, RendererListen: ":8202", RendererDevice: "Living Room"}},
+		{name: "renderer hostname", cfg: Config{Listen: DefaultListen, MediaRoots: []string{root}, RendererListen: "tv.test:8202"}, wantErr: ErrInvalidListen},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,180 @@
+package servermode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"go2tv.app/go2tv/v2/internal/webhook"
+)
+
+var ErrInvalidConfigFile = errors.New("invalid config file")
+
+// FileConfig is the -config file. It is TOML unless the name ends in .json.
+// Relative paths resolve against the directory holding the file.
+type FileConfig struct {
+	Listen         string        `toml:"listen" json:"listen"`
+	MediaRoots     []string      `toml:"media_roots" json:"media_roots"`
+	AllowedOrigins []string      `toml:"allowed_origins" json:"allowed_origins"`
+	FFmpegPath     string        `toml:"ffmpeg" json:"ffmpeg"`
+	Debug          bool          `toml:"debug" json:"debug"`
+	AuthFile       string        `toml:"auth_file" json:"auth_file"`
+	StateFile      *string       `toml:"state_file" json:"state_file"`
+	HistoryFile    *string       `toml:"history_file" json:"history_file"`
+	LibraryIndex   string        `toml:"library_index" json:"library_index"`
+	QuirksFile     string        `toml:"quirks_file" json:"quirks_file"`
+	TLSCert        string        `toml:"tls_cert" json:"tls_cert"`
+	TLSKey         string        `toml:"tls_key" json:"tls_key"`
+	TLSSelfSigned  bool          `toml:"tls_self_signed" json:"tls_self_signed"`
+	Webhooks       []FileWebhook `toml:"webhooks" json:"webhooks"`
+	MQTT           FileMQTT      `toml:"mqtt" json:"mqtt"`
+	MetricsListen  string        `toml:"metrics_listen" json:"metrics_listen"`
+	UPnPListen     string        `toml:"upnp_listen" json:"upnp_listen"`
+	RendererListen string        `toml:"renderer_listen" json:"renderer_listen"`
+	RendererDevice string        `toml:"renderer_device" json:"renderer_device"`
+}
+
+// FileMQTT is the [mqtt] table. The -mqtt flag replaces Broker only.
+type FileMQTT struct {
+	Broker          string `toml:"broker" json:"broker"`
+	Username        string `toml:"username" json:"username"`
+	Password        string `toml:"password" json:"password"`
+	ClientID        string `toml:"client_id" json:"client_id"`
+	Prefix          string `toml:"prefix" json:"prefix"`
+	DiscoveryPrefix string `toml:"discovery_prefix" json:"discovery_prefix"`
+}
+
+// FileWebhook is one [[webhooks]] table. Secret signs each delivery; Events
+// limits delivery to the listed event types.
+type FileWebhook struct {
+	URL    string   `toml:"url" json:"url"`
+	Secret string   `toml:"secret" json:"secret"`
+	Events []string `toml:"events" json:"events"`
+}
+
+// LoadConfigFile reads a config file, rejecting unknown keys so a typo cannot
+// silently drop a media root or origin.
+func LoadConfigFile(path string) (FileConfig, error) {
+	var file FileConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FileConfig{}, fmt.Errorf("%w: %v", ErrInvalidConfigFile, err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return FileConfig{}, fmt.Errorf("%w: %q: %v", ErrInvalidConfigFile, path, err)
+		}
+		if decoder.More() {
+			return FileConfig{}, fmt.Errorf("%w: %q: trailing data", ErrInvalidConfigFile, path)
+		}
+	} else {
+		meta, err := toml.Decode(string(data), &file)
+		if err != nil {
+			return FileConfig{}, fmt.Errorf("%w: %q: %v", ErrInvalidConfigFile, path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) != 0 {
+			return FileConfig{}, fmt.Errorf("%w: %q: unknown key %q", ErrInvalidConfigFile, path, undecoded[0].String())
+		}
+	}
+	dir := filepath.Dir(path)
+	resolve := func(value string) string {
+		if value == "" || filepath.IsAbs(value) {
+			return value
+		}
+		return filepath.Join(dir, value)
+	}
+	file.MediaRoots = slices.Clone(file.MediaRoots)
+	for index, root := range file.MediaRoots {
+		file.MediaRoots[index] = resolve(root)
+	}
+	file.AuthFile = resolve(file.AuthFile)
+	if file.StateFile != nil {
+		state := resolve(*file.StateFile)
+		file.StateFile = &state
+	}
+	if file.HistoryFile != nil {
+		history := resolve(*file.HistoryFile)
+		file.HistoryFile = &history
+	}
+	file.LibraryIndex = resolve(file.LibraryIndex)
+	file.QuirksFile = resolve(file.QuirksFile)
+	file.TLSCert = resolve(file.TLSCert)
+	file.TLSKey = resolve(file.TLSKey)
+	return file, nil
+}
+
+// apply copies the file settings into cfg. Settings whose flag was given on
+// the command line keep the command-line value.
+func (f FileConfig) apply(cfg *Config, explicit map[string]bool) {
+	set := func(flag string, target *string, value string) {
+		if !explicit[flag] && value != "" {
+			*target = value
+		}
+	}
+	set("listen", &cfg.Listen, f.Listen)
+	set("ffmpeg", &cfg.FFmpegPath, f.FFmpegPath)
+	set("auth-file", &cfg.AuthFile, f.AuthFile)
+	if f.StateFile != nil && !explicit["state-file"] {
+		// Unlike the other paths, an empty state_file is kept: it turns
+		// persistence off.
+		cfg.StateFile = *f.StateFile
+	}
+	if f.HistoryFile != nil && !explicit["history-file"] {
+		// So is an empty history_file.
+		cfg.HistoryFile = *f.HistoryFile
+	}
+	set("library-index", &cfg.LibraryIndex, f.LibraryIndex)
+	set("quirks-file", &cfg.QuirksFile, f.QuirksFile)
+	set("tls-cert", &cfg.TLSCertFile, f.TLSCert)
+	set("tls-key", &cfg.TLSKeyFile, f.TLSKey)
+	set("mqtt", &cfg.MQTT.Broker, f.MQTT.Broker)
+	set("metrics-listen", &cfg.MetricsListen, f.MetricsListen)
+	set("upnp-listen", &cfg.UPnPListen, f.UPnPListen)
+	set("renderer-listen", &cfg.RendererListen, f.RendererListen)
+	set("renderer-device", &cfg.RendererDevice, f.RendererDevice)
+	cfg.MQTT.Username, cfg.MQTT.Password, cfg.MQTT.ClientID = f.MQTT.Username, f.MQTT.Password, f.MQTT.ClientID
+	cfg.MQTT.Prefix, cfg.MQTT.DiscoveryPrefix = f.MQTT.Prefix, f.MQTT.DiscoveryPrefix
+	if !explicit["media-root"] {
+		cfg.MediaRoots = f.MediaRoots
+	}
+	if !explicit["allowed-origin"] {
+		cfg.AllowedOrigins = f.AllowedOrigins
+	}
+	if !explicit["debug"] {
+		cfg.Debug = f.Debug
+	}
+	if !explicit["tls-self-signed"] {
+		cfg.TLSSelfSigned = f.TLSSelfSigned
+	}
+	if !explicit["webhook"] {
+		cfg.Webhooks = make([]webhook.Endpoint, 0, len(f.Webhooks))
+		for _, hook := range f.Webhooks {
+			cfg.Webhooks = append(cfg.Webhooks, webhook.Endpoint{URL: hook.URL, Secret: hook.Secret, Events: hook.Events})
+		}
+	}
+}
+
+// reloadConfig rebuilds the configuration from the command line and a fresh
+// read of cfg.ConfigFile, and validates it with the same rules as the initial
+// load.
+func reloadConfig(cfg Config) (Config, error) {
+	file, err := LoadConfigFile(cfg.ConfigFile)
+	if err != nil {
+		return Config{}, err
+	}
+	next := Config{ConfigFile: cfg.ConfigFile, Version: cfg.Version, cliFlags: cfg.cliFlags}
+	if cfg.cliConfig != nil {
+		next = *cfg.cliConfig
+	}
+	next.cliConfig = cfg.cliConfig
+	file.apply(&next, next.cliFlags)
+	return Validate(next)
+}
@@ -0,0 +1,193 @@
+package servermode
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	tomlPath := filepath.Join(dir, "server.toml")
+	writeConfigFile(t, tomlPath, `listen = "0.0.0.0:9666"
+media_roots = ["Movies", '`+filepath.Join(dir, "Music")+`']
+allowed_origins = ["http://tv.local:9666"]
+tls_self_signed = true
+
+[[webhooks]]
+url = "https://hooks.local/tv"
+secret = "s3cret"
+events = ["session.started"]
+
+[mqtt]
+broker = "mqtt://broker.local"
+username = "go2tv"
+discovery_prefix = "ha"
+`)
+	file, err := LoadConfigFile(tomlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Listen != "0.0.0.0:9666" || !file.TLSSelfSigned || !slices.Equal(file.MediaRoots, []string{filepath.Join(dir, "Movies"), filepath.Join(dir, "Music")}) {
+		t.Fatalf("TOML config = %#v", file)
+	}
+	if hooks := file.Webhooks; len(hooks) != 1 || hooks[0].URL != "https://hooks.local/tv" || hooks[0].Secret != "s3cret" || !slices.Equal(hooks[0].Events, []string{"session.started"}) {
+		t.Fatalf("TOML webhooks = %#v", file.Webhooks)
+	}
+	if file.MQTT != (FileMQTT{Broker: "mqtt://broker.local", Username: "go2tv", DiscoveryPrefix: "ha"}) {
+		t.Fatalf("TOML mqtt = %#v", file.MQTT)
+	}
+
+	jsonPath := filepath.Join(dir, "server.json")
+	writeConfigFile(t, jsonPath, `{"media_roots": ["Movies"], "ffmpeg": "ffmpeg"}`)
+	if file, err = LoadConfigFile(jsonPath); err != nil || file.FFmpegPath != "ffmpeg" || file.MediaRoots[0] != filepath.Join(dir, "Movies") {
+		t.Fatalf("JSON config = %#v, %v", file, err)
+	}
+
+	for name, content := range map[string]string{
+		"typo.toml":   `media_root = ["Movies"]`,
+		"typo.json":   `{"media_root": ["Movies"]}`,
+		"broken.toml": `media_roots = [`,
+	} {
+		path := filepath.Join(dir, name)
+		writeConfigFile(t, path, content)
+		if _, err := LoadConfigFile(path); !errors.Is(err, ErrInvalidConfigFile) {
+			t.Fatalf("%s error = %v", name, err)
+		}
+	}
+	if _, err := LoadConfigFile(filepath.Join(dir, "missing.toml")); !errors.Is(err, ErrInvalidConfigFile) {
+		t.Fatalf("missing file error = %v", err)
+	}
+}
+
+func TestConfigFileFlagsTakePrecedence(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	fileRoot, flagRoot := t.TempDir(), t.TempDir()
+	path := filepath.Join(dir, "server.toml")
+	writeConfigFile(t, path, `listen = "127.0.0.1:9700"
+media_roots = ['`+fileRoot+`']
+ffmpeg = "/file/ffmpeg"
+quirks_file = "quirks.json"
+
+[mqtt]
+broker = "mqtt://file.local"
+prefix = "tv"
+`)
+	parse := func(args ...string) (*CLIOptions, error) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		options := RegisterCLIFlags(flags)
+		if err := flags.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return options, options.Validate(flags)
+	}
+	options, err := parse("-server", "-config", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := options.Config("test")
+	if cfg.Listen != "127.0.0.1:9700" || cfg.FFmpegPath != "/file/ffmpeg" || !slices.Equal(cfg.MediaRoots, []string{fileRoot}) || cfg.MQTT.Broker != "mqtt://file.local" {
+		t.Fatalf("file config = %#v", cfg)
+	}
+	if cfg.QuirksFile != filepath.Join(dir, "quirks.json") || LearnedQuirksPath(cfg.QuirksFile) != filepath.Join(dir, "quirks-learned.json") {
+		t.Fatalf("quirks file = %q", cfg.QuirksFile)
+	}
+	if options, err = parse("-server", "-config", path, "-media-root", flagRoot, "-ffmpeg", "/flag/ffmpeg", "-mqtt", "mqtt://flag.local", "-quirks-file", "/flag/quirks.json"); err != nil {
+		t.Fatal(err)
+	}
+	cfg = options.Config("test")
+	if cfg.Listen != "127.0.0.1:9700" || cfg.FFmpegPath != "/flag/ffmpeg" || !slices.Equal(cfg.MediaRoots, []string{flagRoot}) || cfg.MQTT != (MQTTConfig{Broker: "mqtt://flag.local", Prefix: "tv"}) {
+		t.Fatalf("flag override config = %#v", cfg)
+	}
+	if cfg.QuirksFile != "/flag/quirks.json" {
+		t.Fatalf("flag quirks file = %q", cfg.QuirksFile)
+	}
+	if cfg.StateFile == "" {
+		t.Fatal("state file is off by default")
+	}
+	if options, err = parse("-server", "-config", path, "-state-file="); err != nil {
+		t.Fatal(err)
+	}
+	if cfg = options.Config("test"); cfg.StateFile != "" {
+		t.Fatalf("-state-file= kept %q", cfg.StateFile)
+	}
+
+	writeConfigFile(t, path, `media_roots = ['`+fileRoot+`']
+state_file = ""
+history_file = ""
+`)
+	if options, err = parse("-server", "-config", path); err != nil {
+		t.Fatal(err)
+	}
+	if cfg = options.Config("test"); cfg.StateFile != "" || cfg.HistoryFile != "" {
+		t.Fatalf("empty state_file or history_file kept %q, %q", cfg.StateFile, cfg.HistoryFile)
+	}
+	if options, err = parse("-server", "-config", path, "-state-file", "state.json"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg = options.Config("test"); cfg.StateFile != "state.json" {
+		t.Fatalf("flag state file = %q", cfg.StateFile)
+	}
+
+	writeConfigFile(t, path, `listen = "127.0.0.1:9700"`)
+	if _, err := parse("-server", "-config", path); !errors.Is(err, ErrInvalidMediaRoot) {
+		t.Fatalf("config without roots error = %v", err)
+	}
+	if _, err := parse("-config", path); !errors.Is(err, ErrServerFlagWithoutMode) {
+		t.Fatalf("config without -server error = %v", err)
+	}
+}
+
+func TestReloadRevertsRemovedKeys(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	root := t.TempDir()
+	path := filepath.Join(dir, "server.toml")
+	writeConfigFile(t, path, `listen = "127.0.0.1:9700"
+media_roots = ['`+root+`']
+allowed_origins = ["http://tv.local:9700"]
+auth_file = "auth.json"
+metrics_listen = "127.0.0.1:9701"
+`)
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	options := RegisterCLIFlags(flags)
+	if err := flags.Parse([]string{"-server", "-config", path, "-ffmpeg", "/flag/ffmpeg"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := options.Validate(flags); err != nil {
+		t.Fatal(err)
+	}
+	current, err := Validate(options.Config("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current.AllowedOrigins) != 1 || current.AuthFile != filepath.Join(dir, "auth.json") || current.MetricsListen == "" {
+		t.Fatalf("initial config = %#v", current)
+	}
+
+	writeConfigFile(t, path, `listen = "127.0.0.1:9700"
+media_roots = ['`+root+`']
+`)
+	next, err := reloadConfig(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.AllowedOrigins) != 0 || next.AuthFile == current.AuthFile || next.MetricsListen != "" {
+		t.Fatalf("removed keys kept: origins %v, auth %q, metrics %q", next.AllowedOrigins, next.AuthFile, next.MetricsListen)
+	}
+	if next.FFmpegPath != "/flag/ffmpeg" || next.Listen != "127.0.0.1:9700" {
+		t.Fatalf("flag or file settings lost: %#v", next)
+	}
+}
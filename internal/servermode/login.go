@@ -0,0 +1,71 @@
+package servermode
+
+import (
+	"html/template"
+	"net/http"
+)
+
+const maxLoginBytes = 4 << 10
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width,initial-scale=1">
+<meta name="color-scheme" content="light dark">
+<title>Go2TV sign in</title>
+<style>
+body{font-family:system-ui,sans-serif;display:grid;place-items:center;min-height:100vh;margin:0}
+form{display:grid;gap:.75rem;width:min(20rem,90vw)}
+input,button{font:inherit;padding:.6rem;border-radius:.5rem;border:1px solid #8886}
+.error{color:#d33;margin:0}
+</style>
+</head>
+<body>
+<form method="post" action="/login">
+<h1>Go2TV</h1>
+{{if .}}<p class="error" role="alert">{{.}}</p>{{end}}
+<label for="password">Password</label>
+<input id="password" name="password" type="password" autocomplete="current-password" required autofocus>
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+// login serves the password form and exchanges a correct password for the
+// session cookie. Attempts are serialised so parallel guessing gains nothing
+// over the cost of one PBKDF2 round.
+func (h *securityHandler) login(w http.ResponseWriter, r *http.Request, session authSession) {
+	switch r.Method {
+	case http.MethodGet:
+		renderLogin(w, http.StatusOK, "")
+	case http.MethodPost:
+		if !h.validBootstrapSource(r) {
+			writeAPIError(w, http.StatusForbidden, "request_not_allowed")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxLoginBytes)
+		if err := r.ParseForm(); err != nil {
+			renderLogin(w, http.StatusBadRequest, "Invalid request.")
+			return
+		}
+		h.loginMu.Lock()
+		ok := session.auth.checkPassword(r.PostForm.Get("password"))
+		h.loginMu.Unlock()
+		if !ok {
+			renderLogin(w, http.StatusUnauthorized, "Incorrect password.")
+			return
+		}
+		h.setCookie(w, session)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+	}
+}
+
+func renderLogin(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = loginPage.Execute(w, message)
+}
@@ -74,7 +74,7 @@ func runManaged(ctx context.Context, validated Config, output io.Writer, control
 	if err != nil {
 		return err
 	}
-	logStartup(log, validated, listener.Addr().String())
+	logStartup(log, validated, listener.Addr().String(), "")
 	log.Info("Managed by GUI: discovery arrives from the desktop app")
 
 	server := &http.Server{Handler: accessLog(log, handler), ReadHeaderTimeout: defaultReadHeaderTimeout, ReadTimeout: defaultJSONTimeout, WriteTimeout: defaultJSONTimeout, IdleTimeout: defaultIdleTimeout, MaxHeaderBytes: defaultMaxHeaderBytes}
@@ -0,0 +1,223 @@
+package servermode
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"go2tv.app/go2tv/v2/utils"
+)
+
+// TokenEnv names the environment variable holding the API token that
+// command-line actions against a running server present.
+const TokenEnv = "GO2TV_TOKEN"
+
+const remoteTimeout = 30 * time.Second
+
+var ErrRemoteToken = errors.New(TokenEnv + " must hold a control token; create one with -token-create")
+
+// ErrHistoryEntry reports a -resume or -start-over number that is not in the
+// continue watching list.
+var ErrHistoryEntry = errors.New("no such continue watching entry; list them with -history")
+
+// remoteDevice is the part of a discovered device the CLI uses.
+type remoteDevice struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	Protocol string `json:"protocol"`
+}
+
+// historyEntry is the part of a continue watching entry the CLI uses.
+type historyEntry struct {
+	RootID   string `json:"root_id"`
+	EntryID  string `json:"entry_id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	Duration int    `json:"duration"`
+}
+
+// RunRemoteCommand sends the playback action selected on the command line to
+// the server already listening on -listen. It reports false when no such
+// action was requested.
+func (o *CLIOptions) RunRemoteCommand(ctx context.Context, output io.Writer) (bool, error) {
+	if !o.Server || o.Transfer == "" && !o.Devices && !o.explicit["history"] && !o.explicit["resume"] && !o.explicit["start-over"] {
+		return false, nil
+	}
+	token := os.Getenv(TokenEnv)
+	if token == "" {
+		return true, ErrRemoteToken
+	}
+	cfg, err := validateTLS(o.Config(""))
+	if err != nil {
+		return true, err
+	}
+	client, err := remoteClient(cfg)
+	if err != nil {
+		return true, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, remoteTimeout)
+	defer cancel()
+	remote := remoteServer{client: client, base: cfg.scheme() + "://" + remoteHost(cfg.Listen), token: token}
+	if o.Devices || o.Transfer != "" {
+		var devices struct {
+			Devices []remoteDevice `json:"devices"`
+		}
+		if err := remote.call(ctx, http.MethodGet, "/api/v1/devices", nil, &devices); err != nil {
+			return true, fmt.Errorf("devices failed: %w", err)
+		}
+		if o.Devices {
+			if len(devices.Devices) == 0 {
+				fmt.Fprintln(output, "No devices found.")
+			}
+			for _, device := range devices.Devices {
+				fmt.Fprintf(output, "%s\t%s\t%s\n", device.ID, device.Label, device.Protocol)
+			}
+			return true, nil
+		}
+		device := transferDevice(devices.Devices, o.Transfer)
+		if err := remote.call(ctx, http.MethodPost, "/api/v1/player/transfer", map[string]string{"device_id": device.ID}, nil); err != nil {
+			return true, fmt.Errorf("transfer failed: %w", err)
+		}
+		fmt.Fprintf(output, "Playback transferred to %q.\n", device.Label)
+		return true, nil
+	}
+	var history struct {
+		ContinueWatching []historyEntry `json:"continue_watching"`
+	}
+	if err := remote.call(ctx, http.MethodGet, "/api/v1/history", nil, &history); err != nil {
+		return true, fmt.Errorf("history failed: %w", err)
+	}
+	if o.explicit["history"] {
+		if len(history.ContinueWatching) == 0 {
+			fmt.Fprintln(output, "Nothing to continue watching.")
+		}
+		for index, entry := range history.ContinueWatching {
+			fmt.Fprintf(output, "%d. %s\t%s\n", index+1, entry.Name, historyProgress(entry))
+		}
+		return true, nil
+	}
+	number, resume := o.StartOver, false
+	if o.explicit["resume"] {
+		number, resume = o.Resume, true
+	}
+	if number < 1 || number > len(history.ContinueWatching) {
+		return true, fmt.Errorf("%w: %d", ErrHistoryEntry, number)
+	}
+	entry := history.ContinueWatching[number-1]
+	payload := map[string]any{"root_id": entry.RootID, "entry_id": entry.EntryID, "resume": resume}
+	if err := remote.call(ctx, http.MethodPost, "/api/v1/library/play", payload, nil); err != nil {
+		return true, fmt.Errorf("play failed: %w", err)
+	}
+	if resume {
+		fmt.Fprintf(output, "Resuming %q at %s.\n", entry.Name, utils.SecondsToClockTime(entry.Position))
+	} else {
+		fmt.Fprintf(output, "Playing %q from the start.\n", entry.Name)
+	}
+	return true, nil
+}
+
+// transferDevice finds the device -transfer names by ID or, failing that, by
+// name. An unknown one is passed on as an ID for the server to reject.
+func transferDevice(devices []remoteDevice, name string) remoteDevice {
+	if index := slices.IndexFunc(devices, func(device remoteDevice) bool { return device.ID == name }); index >= 0 {
+		return devices[index]
+	}
+	if index := slices.IndexFunc(devices, func(device remoteDevice) bool { return device.Label == name }); index >= 0 {
+		return devices[index]
+	}
+	return remoteDevice{ID: name, Label: name}
+}
+
+func historyProgress(entry historyEntry) string {
+	if entry.Duration <= 0 {
+		return utils.SecondsToClockTime(entry.Position)
+	}
+	return utils.SecondsToClockTime(entry.Position) + " / " + utils.SecondsToClockTime(entry.Duration)
+}
+
+// remoteServer is the API of a running server, reached with a bearer token.
+type remoteServer struct {
+	client *http.Client
+	base   string
+	token  string
+}
+
+// call sends body as JSON and decodes a successful response into result when
+// it is not nil. A failed call returns the server's message and status.
+func (r remoteServer) call(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, r.base+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+r.token)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response, err := r.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		var failure struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(response.Body, 1<<16)).Decode(&failure)
+		if failure.Message == "" {
+			failure.Message = failure.Error
+		}
+		return fmt.Errorf("%s (%s)", failure.Message, response.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(result)
+}
+
+// remoteClient trusts the server certificate itself when the server serves
+// HTTPS, which also covers the self-signed one.
+func remoteClient(cfg Config) (*http.Client, error) {
+	if !cfg.TLSEnabled() {
+		return &http.Client{}, nil
+	}
+	certificate, err := os.ReadFile(cfg.TLSCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTLS, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(certificate) {
+		return nil, fmt.Errorf("%w: no certificate in %s", ErrInvalidTLS, cfg.TLSCertFile)
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}}}, nil
+}
+
+// remoteHost reaches a wildcard listen address over loopback.
+func remoteHost(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,146 @@
+package servermode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunRemoteTransfer(t *testing.T) {
+	var gotPath, gotAuth, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/api/v1/devices" {
+			_, _ = io.WriteString(w, `{"revision":1,"devices":[{"id":"tv","label":"Living Room TV","protocol":"DLNA"},{"id":"cast","label":"Kitchen","protocol":"Chromecast"}]}`)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotAuth, gotBody = r.Method+" "+r.URL.Path, r.Header.Get("Authorization"), string(body)
+		if strings.Contains(gotBody, "gone") {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"error":"not_found","message":"not found"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	}))
+	defer server.Close()
+	listen := strings.TrimPrefix(server.URL, "http://")
+	run := func(args ...string) (string, error) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		options := RegisterCLIFlags(flags)
+		if err := flags.Parse(append([]string{"-server", "-listen", listen}, args...)); err != nil {
+			t.Fatal(err)
+		}
+		if err := options.Validate(flags); err != nil {
+			return "", err
+		}
+		var output bytes.Buffer
+		handled, err := options.RunRemoteCommand(context.Background(), &output)
+		if !handled {
+			t.Fatalf("%v not handled", args)
+		}
+		return output.String(), err
+	}
+
+	t.Setenv(TokenEnv, "")
+	if _, err := run("-transfer", "tv"); !errors.Is(err, ErrRemoteToken) {
+		t.Fatalf("missing token error = %v", err)
+	}
+	t.Setenv(TokenEnv, "g2tv_secret")
+	output, err := run("-transfer", "tv")
+	if err != nil || !strings.Contains(output, `"Living Room TV"`) {
+		t.Fatalf("transfer = %q, %v", output, err)
+	}
+	if gotPath != "POST /api/v1/player/transfer" || gotAuth != "Bearer g2tv_secret" || gotBody != `{"device_id":"tv"}` {
+		t.Fatalf("request = %s %s %s", gotPath, gotAuth, gotBody)
+	}
+	if _, err := run("-transfer", "Kitchen"); err != nil || gotBody != `{"device_id":"cast"}` {
+		t.Fatalf("transfer by name = %s, %v", gotBody, err)
+	}
+	if output, err := run("-devices"); err != nil || output != "tv\tLiving Room TV\tDLNA\ncast\tKitchen\tChromecast\n" {
+		t.Fatalf("devices = %q, %v", output, err)
+	}
+	if _, err := run("-transfer", "gone"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("failed transfer error = %v", err)
+	}
+	if _, err := run("-transfer", "tv", "-token-list"); !errors.Is(err, ErrServerFlagConflict) {
+		t.Fatalf("two actions error = %v", err)
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	options := RegisterCLIFlags(flags)
+	if err := flags.Parse([]string{"-transfer", "tv"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := options.Validate(flags); !errors.Is(err, ErrServerFlagWithoutMode) {
+		t.Fatalf("-transfer without -server = %v", err)
+	}
+	if host := remoteHost("0.0.0.0:9666"); host != "127.0.0.1:9666" {
+		t.Fatalf("wildcard listen reached at %s", host)
+	}
+}
+
+func TestRunRemoteHistory(t *testing.T) {
+	var plays []string
+	entries := `{"revision":3,"continue_watching":[` +
+		`{"root_id":"r1","entry_id":"e1","name":"movie.mkv","kind":"video","position":125,"duration":5400,"updated_at":"2026-01-01T20:00:00Z"},` +
+		`{"root_id":"r1","entry_id":"e2","name":"talk.mp3","kind":"audio","position":60,"duration":0,"updated_at":"2026-01-01T19:00:00Z"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v1/history":
+			_, _ = io.WriteString(w, entries)
+		case "POST /api/v1/library/play":
+			body, _ := io.ReadAll(r.Body)
+			plays = append(plays, string(body))
+			_, _ = io.WriteString(w, `{"request_id":"x","revision":4}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	listen := strings.TrimPrefix(server.URL, "http://")
+	t.Setenv(TokenEnv, "g2tv_secret")
+	run := func(args ...string) (string, error) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		options := RegisterCLIFlags(flags)
+		if err := flags.Parse(append([]string{"-server", "-listen", listen}, args...)); err != nil {
+			t.Fatal(err)
+		}
+		if err := options.Validate(flags); err != nil {
+			return "", err
+		}
+		var output bytes.Buffer
+		if handled, err := options.RunRemoteCommand(context.Background(), &output); !handled {
+			t.Fatalf("%v not handled", args)
+		} else if err != nil {
+			return "", err
+		}
+		return output.String(), nil
+	}
+
+	output, err := run("-history")
+	if err != nil || output != "1. movie.mkv\t00:02:05 / 01:30:00\n2. talk.mp3\t00:01:00\n" {
+		t.Fatalf("history = %q, %v", output, err)
+	}
+	if output, err := run("-resume", "1"); err != nil || !strings.Contains(output, "00:02:05") {
+		t.Fatalf("resume = %q, %v", output, err)
+	}
+	if _, err := run("-start-over", "2"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`{"entry_id":"e1","resume":true,"root_id":"r1"}`, `{"entry_id":"e2","resume":false,"root_id":"r1"}`}
+	if strings.Join(plays, ",") != strings.Join(want, ",") {
+		t.Fatalf("plays = %v", plays)
+	}
+	if _, err := run("-resume", "3"); !errors.Is(err, ErrHistoryEntry) {
+		t.Fatalf("missing entry error = %v", err)
+	}
+	if _, err := run("-resume", "1", "-history"); !errors.Is(err, ErrServerFlagConflict) {
+		t.Fatalf("two actions error = %v", err)
+	}
+}
@@ -4,12 +4,20 @@ package servermode
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/internal/upnpserver"
 )
 
 func Run(ctx context.Context, cfg Config, output io.Writer) error {
@@ -20,6 +28,12 @@ func Run(ctx context.Context, cfg Config, output io.Writer) error {
 	if validated.ManagedChild {
 		return runManaged(ctx, validated, output, os.Stdin)
 	}
+	var certificate *servingCertificate
+	if validated.TLSEnabled() {
+		if certificate, err = newServingCertificate(validated, time.Now, nil); err != nil {
+			return err
+		}
+	}
 	listener, err := net.Listen("tcp", validated.Listen)
 	if err != nil {
 		return fmt.Errorf("listen: %w", err)
@@ -33,22 +47,65 @@ func Run(ctx context.Context, cfg Config, output io.Writer) error {
 		return err
 	}
 	defer runtime.Close()
-	handler, err := NewHandler(securityConfig, nil, runtime.web)
+	handler, err := newSecurityHandler(securityConfig, nil, runtime.web)
 	if err != nil {
 		return err
 	}
-	logStartup(log, validated, listener.Addr().String())
+	handler.metrics = runtime.metricsHandler()
+	fingerprint := ""
+	if certificate != nil {
+		fingerprint = certificateFingerprint(certificate.certificate())
+		certificate.renewed = func(renewed tls.Certificate) {
+			log.Info("TLS certificate renewed; SHA-256 fingerprint: " + certificateFingerprint(renewed))
+		}
+	}
+	logStartup(log, validated, listener.Addr().String(), fingerprint)
+	if validated.MetricsListen != "" {
+		stop, err := serveMetrics(log, validated.MetricsListen, handler.metrics)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+	if validated.UPnPListen != "" {
+		stop, err := serveUPnP(log, validated, runtime)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+	if validated.RendererListen != "" {
+		stop, err := serveRenderer(log, validated, runtime)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
 
+	if certificate != nil {
+		listener = tls.NewListener(listener, &tls.Config{GetCertificate: certificate.GetCertificate, MinVersion: tls.VersionTLS12})
+	}
 	server := &http.Server{Handler: accessLog(log, handler), ReadHeaderTimeout: defaultReadHeaderTimeout, ReadTimeout: defaultJSONTimeout, WriteTimeout: defaultJSONTimeout, IdleTimeout: defaultIdleTimeout, MaxHeaderBytes: defaultMaxHeaderBytes}
 	result := make(chan error, 1)
 	go func() { result <- server.Serve(listener) }()
-	select {
-	case <-ctx.Done():
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultJSONTimeout)
-		_ = server.Shutdown(shutdownCtx)
-		cancel()
-		err = <-result
-	case err = <-result:
+	hangup := make(chan os.Signal, 1)
+	if validated.ConfigFile != "" {
+		signal.Notify(hangup, syscall.SIGHUP)
+		defer signal.Stop(hangup)
+	}
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultJSONTimeout)
+			_ = server.Shutdown(shutdownCtx)
+			cancel()
+			err = <-result
+			done = true
+		case err = <-result:
+			done = true
+		case <-hangup:
+			validated = applyReload(log, validated, runtime.library, handler, securityConfig.Listen)
+		}
 	}
 	if errors.Is(err, http.ErrServerClosed) {
 		return nil
@@ -56,7 +113,138 @@ func Run(ctx context.Context, cfg Config, output io.Writer) error {
 	return err
 }
 
-func logStartup(log *serverLogger, cfg Config, actualListen string) {
+// serveMetrics serves only /metrics, without authentication, on its own
+// listener. The returned func shuts it down.
+func serveMetrics(log *serverLogger, address string, handler http.Handler) (func(), error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("metrics listen: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, handler)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: defaultReadHeaderTimeout, ReadTimeout: defaultJSONTimeout, WriteTimeout: defaultJSONTimeout, IdleTimeout: defaultIdleTimeout, MaxHeaderBytes: defaultMaxHeaderBytes}
+	go func() { _ = server.Serve(listener) }()
+	log.Info("Metrics listening: http://" + listener.Addr().String() + metricsPath)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultJSONTimeout)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}, nil
+}
+
+// serveUPnP shares the library roots as a UPnP media server. The device
+// UUID is derived from the host and address, so control points see the same
+// server after a restart.
+func serveUPnP(log *serverLogger, cfg Config, runtime *runtime) (func(), error) {
+	host, _ := os.Hostname()
+	name := "go2tv"
+	if host != "" {
+		name += " (" + host + ")"
+	}
+	server, err := upnpserver.New(upnpserver.Config{
+		Library:    runtime.library,
+		ListenAddr: cfg.UPnPListen,
+		Name:       name,
+		UUID:       upnpserver.NameUUID(host + "|" + cfg.UPnPListen),
+		Version:    cfg.Version,
+		Artwork:    runtime.web.Thumbnail,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := server.Start(); err != nil {
+		return nil, fmt.Errorf("upnp listen: %w", err)
+	}
+	log.Info("UPnP media server listening: http://" + server.Addr() + "/upnp/description.xml")
+	return func() { _ = server.Close() }, nil
+}
+
+// serveRenderer announces a DLNA media renderer that plays what control
+// points push to it on cfg.RendererDevice. Like serveUPnP, its UUID is
+// stable across restarts.
+func serveRenderer(log *serverLogger, cfg Config, runtime *runtime) (func(), error) {
+	host, _ := os.Hostname()
+	name := "go2tv"
+	if host != "" {
+		name += " (" + host + ")"
+	}
+	renderer, err := upnpserver.NewRenderer(upnpserver.RendererConfig{
+		Controller: runtime.controller,
+		ListenAddr: cfg.RendererListen,
+		Name:       name,
+		UUID:       upnpserver.NameUUID(host + "|renderer|" + cfg.RendererListen),
+		Version:    cfg.Version,
+		Device:     cfg.RendererDevice,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := renderer.Start(); err != nil {
+		return nil, fmt.Errorf("renderer listen: %w", err)
+	}
+	log.Info("DLNA renderer listening: http://" + renderer.Addr() + "/upnp/renderer/description.xml")
+	return func() { _ = renderer.Close() }, nil
+}
+
+// applyReload swaps in the media roots and allowed origins of a re-read config
+// file. The server, its WebSocket clients, and the cast session carry on; a
+// config that fails validation leaves the running settings untouched.
+func applyReload(log *serverLogger, current Config, lib *library.Library, security *securityHandler, actualListen string) Config {
+	next, err := reloadConfig(current)
+	if err != nil {
+		log.Error("Config reload failed; keeping current settings: " + err.Error())
+		return current
+	}
+	if next.TLSEnabled() != current.TLSEnabled() {
+		// Origins are validated against the scheme being served, so turning
+		// TLS on or off cannot be half-applied.
+		log.Error("Config reload failed; TLS changes require a restart.")
+		return current
+	}
+	if err := lib.SetRoots(next.MediaRoots); err != nil {
+		log.Error("Config reload failed; keeping current settings: " + err.Error())
+		return current
+	}
+	securityConfig := next
+	securityConfig.Listen = actualListen
+	security.setAllowed(securityConfig)
+	restartOnly := []struct {
+		name    string
+		changed bool
+	}{
+		{"listen", next.Listen != current.Listen},
+		{"ffmpeg", next.FFmpegPath != current.FFmpegPath},
+		{"debug", next.Debug != current.Debug},
+		{"auth_file", next.AuthFile != current.AuthFile},
+		{"state_file", next.StateFile != current.StateFile},
+		{"history_file", next.HistoryFile != current.HistoryFile},
+		{"library_index", next.LibraryIndex != current.LibraryIndex},
+		{"quirks_file", next.QuirksFile != current.QuirksFile},
+		{"upnp_listen", next.UPnPListen != current.UPnPListen},
+		{"renderer_listen", next.RendererListen != current.RendererListen},
+		{"renderer_device", next.RendererDevice != current.RendererDevice},
+		{"tls", next.TLSSelfSigned != current.TLSSelfSigned || next.TLSCertFile != current.TLSCertFile || next.TLSKeyFile != current.TLSKeyFile},
+		{"webhooks", !reflect.DeepEqual(next.Webhooks, current.Webhooks)},
+		{"mqtt", next.MQTT != current.MQTT},
+		{"metrics_listen", next.MetricsListen != current.MetricsListen},
+	}
+	for _, setting := range restartOnly {
+		if setting.changed {
+			log.Warning("Config reload: " + setting.name + " change takes effect after restart.")
+		}
+	}
+	current.MediaRoots, current.AllowedOrigins = next.MediaRoots, next.AllowedOrigins
+	log.Info("Config reloaded.")
+	for _, origin := range current.AllowedOrigins {
+		log.Info("Allowed URL: " + origin + "/")
+	}
+	for _, root := range current.MediaRoots {
+		log.Info("Media root: " + root)
+	}
+	return current
+}
+
+func logStartup(log *serverLogger, cfg Config, actualListen, fingerprint string) {
 	log.Info("Web server listening: " + actualListen)
 	if len(cfg.AllowedOrigins) != 0 {
 		for _, origin := range cfg.AllowedOrigins {
@@ -64,9 +252,13 @@ func logStartup(log *serverLogger, cfg Config, actualListen string) {
 		}
 	} else {
 		host, port, _ := net.SplitHostPort(actualListen)
-		log.Info("Allowed URL: http://" + net.JoinHostPort(host, port) + "/")
+		log.Info("Allowed URL: " + cfg.scheme() + "://" + net.JoinHostPort(host, port) + "/")
+	}
+	if cfg.TLSEnabled() {
+		log.Info("TLS certificate SHA-256 fingerprint: " + fingerprint)
+	} else {
+		log.Warning("Trusted-LAN mode; no TLS. Do not expose to untrusted networks.")
 	}
-	log.Warning("Trusted-LAN mode; no TLS. Do not expose to untrusted networks.")
 	for _, root := range cfg.MediaRoots {
 		log.Info("Media root: " + root)
 	}
@@ -3,11 +3,21 @@
 package servermode
 
 import (
+	"bytes"
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
 )
 
 func TestVerifiedFFmpegPath(t *testing.T) {
@@ -55,9 +65,113 @@ func TestRunNeverReadsPipedStdin(t *testing.T) {
 	}
 }
 
+func TestApplyReloadSwapsRootsAndOrigins(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	path := filepath.Join(t.TempDir(), "server.json")
+	writeConfigFile(t, path, `{"listen": "0.0.0.0:9666", "media_roots": [`+strconv.Quote(first)+`], "allowed_origins": ["http://tv.local:9666"]}`)
+	file, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{ConfigFile: path}
+	file.apply(&cfg, nil)
+	current, err := Validate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib, err := library.Open(library.Config{Roots: current.MediaRoots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lib.Close()
+	firstID := lib.Roots()[0].ID
+	security, err := newSecurityHandler(current, nil, http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := func(origin string) bool {
+		req := httptest.NewRequest(http.MethodGet, origin+"/api/bootstrap", nil)
+		req.Header.Set("Origin", origin)
+		res := httptest.NewRecorder()
+		security.ServeHTTP(res, req)
+		return res.Code == http.StatusOK
+	}
+	var output bytes.Buffer
+	log := newServerLogger(&output, false)
+
+	writeConfigFile(t, path, `{"listen": "0.0.0.0:9667", "media_roots": [`+strconv.Quote(first)+`, `+strconv.Quote(second)+`], "allowed_origins": ["http://tv.local:9666", "http://phone.local:9666"], "webhooks": [{"url": "http://hooks.local/go2tv"}], "mqtt": {"broker": "mqtt://broker.local"}, "metrics_listen": ":9667"}`)
+	current = applyReload(log, current, lib, security, current.Listen)
+	roots := lib.Roots()
+	if len(current.MediaRoots) != 2 || len(roots) != 2 || roots[0].ID != firstID || !allowed("http://phone.local:9666") || !allowed("http://tv.local:9666") {
+		t.Fatalf("reloaded roots = %+v, origins = %v", roots, current.AllowedOrigins)
+	}
+	if current.Listen != "0.0.0.0:9666" || !strings.Contains(output.String(), "listen change takes effect after restart") {
+		t.Fatalf("listen = %q, output = %q", current.Listen, output.String())
+	}
+	for _, name := range []string{"webhooks", "mqtt", "metrics_listen"} {
+		if !strings.Contains(output.String(), name+" change takes effect after restart") {
+			t.Fatalf("no restart warning for %s: %q", name, output.String())
+		}
+	}
+	if len(current.Webhooks) != 0 || current.MQTT.Broker != "" || current.MetricsListen != "" {
+		t.Fatalf("restart-only settings applied: %+v", current)
+	}
+
+	writeConfigFile(t, path, `{"listen": "0.0.0.0:9666", "media_roots": [], "allowed_origins": ["http://tv.local:9666"]}`)
+	if reloaded := applyReload(log, current, lib, security, current.Listen); len(reloaded.MediaRoots) != 2 || len(lib.Roots()) != 2 || !allowed("http://phone.local:9666") {
+		t.Fatalf("invalid reload applied: %+v", reloaded)
+	}
+	if !strings.Contains(output.String(), "Config reload failed") {
+		t.Fatalf("output = %q", output.String())
+	}
+}
+
 type channelWriter chan<- string
 
 func (w channelWriter) Write(p []byte) (int, error) {
 	w <- string(p)
 	return len(p), nil
 }
+
+func TestApplyPolicyFlagsKeepsRestoredPolicy(t *testing.T) {
+	control := controller.New(controller.Config{})
+	defer control.Close()
+	restored := controller.Policy{LoopSelected: true, ImageDurationSeconds: 20}
+	if result := control.SetPolicy(context.Background(), controller.PolicyRequest{Policy: restored}); !result.OK() {
+		t.Fatal(result)
+	}
+	if err := applyPolicyFlags(context.Background(), control, Config{Shuffle: true}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, _ := control.Snapshot(context.Background())
+	want := controller.Policy{AutoPlayNext: true, Shuffle: true, ImageDurationSeconds: 20}
+	if snapshot.Policy != want {
+		t.Fatalf("policy = %#v, want %#v", snapshot.Policy, want)
+	}
+}
+
+func TestApplyTimerFlags(t *testing.T) {
+	control := controller.New(controller.Config{})
+	defer control.Close()
+	saved := controller.SavedState{
+		Queue:  []controller.MediaRef{{RootID: "root", ID: "a.mp3", Name: "a.mp3", Kind: mediamodel.MediaKindAudio, URL: "http://media/a.mp3"}},
+		Policy: controller.Policy{ImageDurationSeconds: 10},
+		Device: playback.Device{ID: "tv", Name: "Bedroom TV", Protocol: "DLNA", Endpoint: "http://tv"},
+	}
+	if result := control.RestoreState(context.Background(), controller.Mutation{}, saved); !result.OK() {
+		t.Fatal(result)
+	}
+	now := time.Now()
+	// An hour ago has already passed today, so the start moves to tomorrow.
+	cfg := Config{SleepAfter: 90 * time.Minute, StartAt: now.Add(-time.Hour).Format("15:04")}
+	if err := applyTimerFlags(context.Background(), control, cfg, now); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, _ := control.Snapshot(context.Background())
+	if snapshot.SleepTimer.Mode != controller.SleepAfterTime || snapshot.SleepTimer.Deadline.Sub(now) < 89*time.Minute {
+		t.Fatalf("sleep timer = %#v", snapshot.SleepTimer)
+	}
+	if ahead := snapshot.ScheduledStart.At.Sub(now); ahead < 22*time.Hour || ahead > 24*time.Hour || snapshot.ScheduledStart.DeviceName != "Bedroom TV" {
+		t.Fatalf("scheduled start = %#v", snapshot.ScheduledStart)
+	}
+}
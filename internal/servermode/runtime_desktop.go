@@ -16,38 +16,56 @@ import (
 	"go2tv.app/go2tv/v2/internal/controller"
 	"go2tv.app/go2tv/v2/internal/library"
 	"go2tv.app/go2tv/v2/internal/mediaserver"
+	"go2tv.app/go2tv/v2/internal/metrics"
+	"go2tv.app/go2tv/v2/internal/mqtt"
 	"go2tv.app/go2tv/v2/internal/playback"
 	"go2tv.app/go2tv/v2/internal/playbackadapter"
+	"go2tv.app/go2tv/v2/internal/resume"
+	"go2tv.app/go2tv/v2/internal/webhook"
 	"go2tv.app/go2tv/v2/internal/webui"
+	"go2tv.app/go2tv/v2/metadata"
+	"go2tv.app/go2tv/v2/soapcalls"
 	"go2tv.app/go2tv/v2/utils"
 )
 
 type runtime struct {
 	library    *library.Library
+	history    *resume.Store
 	controller *controller.Controller
 	callbacks  *playbackadapter.CallbackBridge
 	web        *webui.Handler
+	webhooks   *webhook.Notifier
+	mqtt       *mqtt.Bridge
+	metrics    *metrics.Registry
 }
 
 // newRuntime builds the server runtime. A nil discovery keeps the standalone
 // scanner-backed construction; managed children inject a pipe-fed discovery.
 func newRuntime(cfg Config, log *serverLogger, discovery playback.Discovery) (*runtime, error) {
-	lib, err := library.Open(library.Config{Roots: cfg.MediaRoots})
+	history, err := resume.Open(cfg.HistoryFile)
 	if err != nil {
-		return nil, err
+		log.Warning("Continue watching history not loaded: " + err.Error())
+		history, _ = resume.Open("")
 	}
-	callbacks := playbackadapter.NewCallbackBridge()
 	ffmpeg, ffmpegErr := verifiedFFmpegPath(cfg.FFmpegPath)
 	if ffmpegErr != nil {
 		// The GUI forwards whatever ffmpeg path the user typed, so a managed
 		// child degrades to transcode-off instead of blocking the session.
 		if !cfg.ManagedChild {
-			callbacks.Close()
-			_ = lib.Close()
 			return nil, fmt.Errorf("verify ffmpeg %q: %w", cfg.FFmpegPath, ffmpegErr)
 		}
 		log.Warning("Configured ffmpeg unusable; transcoding disabled: " + ffmpegErr.Error())
 	}
+	lib, err := library.Open(library.Config{
+		Roots:     cfg.MediaRoots,
+		Progress:  history.Progress,
+		IndexFile: cfg.LibraryIndex,
+		Probe:     probeMedia(ffmpeg),
+	})
+	if err != nil {
+		return nil, err
+	}
+	callbacks := playbackadapter.NewCallbackBridge()
 	var transcodeFunc mediaserver.TranscodeFunc
 	if ffmpeg != "" {
 		transcodeFunc = func(ctx context.Context, w http.ResponseWriter, input io.ReadCloser, request playback.ServerRequest) error {
@@ -58,6 +76,7 @@ func newRuntime(cfg Config, log *serverLogger, discovery playback.Discovery) (*r
 	media := &runtimeMediaServer{Server: base}
 	artwork := controller.NewArtworkCache(controller.ArtworkCacheBytes)
 	var durationProbe func(context.Context, playback.SourceOpener) (float64, error)
+	var codecProbe func(context.Context, playback.SourceOpener) (*utils.MediaCodecInfo, error)
 	if ffmpeg != "" {
 		durationProbe = func(ctx context.Context, open playback.SourceOpener) (float64, error) {
 			media, _, err := open(ctx)
@@ -67,16 +86,146 @@ func newRuntime(cfg Config, log *serverLogger, discovery playback.Discovery) (*r
 			defer media.Close()
 			return utils.DurationForMediaReaderSeconds(ctx, ffmpeg, media)
 		}
+		codecProbe = func(ctx context.Context, open playback.SourceOpener) (*utils.MediaCodecInfo, error) {
+			media, _, err := open(ctx)
+			if err != nil {
+				return nil, err
+			}
+			defer media.Close()
+			return utils.GetMediaCodecInfoReader(ctx, ffmpeg, media)
+		}
+	}
+	sessionMediaServer := func(callback http.Handler) playback.MediaServer {
+		return &runtimeMediaServer{Server: mediaserver.New(mediaserver.Config{Callback: callback, Transcode: transcodeFunc})}
 	}
-	control := controller.New(controller.NewRuntimeConfig(controller.RuntimeConfig{MediaServer: media, Callbacks: callbacks, LogOutput: log.protocolOutput(), Logger: log, Artwork: artwork, DurationProbe: durationProbe, Discovery: discovery}))
-	web, err := webui.New(webui.Config{Version: cfg.Version, Controller: control, Library: lib, Artwork: artwork, FFmpegPath: ffmpeg, TranscodeAvailable: ffmpeg != "", Logger: log, ManagedByGUI: cfg.ManagedChild})
+	quirks, err := soapcalls.OpenQuirks(cfg.QuirksFile, LearnedQuirksPath(cfg.QuirksFile))
+	if err != nil {
+		log.Warning("Renderer quirks not loaded: " + err.Error())
+		quirks, _ = soapcalls.OpenQuirks("", "")
+	}
+	control := controller.New(controller.NewRuntimeConfig(controller.RuntimeConfig{MediaServer: media, Callbacks: callbacks, LogOutput: log.protocolOutput(), Logger: log, Artwork: artwork, DurationProbe: durationProbe, CodecProbe: codecProbe, Remux: ffmpeg != "", Discovery: discovery, SessionMediaServer: sessionMediaServer, Resume: history, Quirks: quirks}))
+	web, err := webui.New(webui.Config{Version: cfg.Version, Controller: control, Library: lib, Artwork: artwork, FFmpegPath: ffmpeg, TranscodeAvailable: ffmpeg != "", Logger: log, ManagedByGUI: cfg.ManagedChild, StateFile: cfg.StateFile})
 	if err != nil {
 		control.Close()
 		callbacks.Close()
 		_ = lib.Close()
+		_ = history.Close()
 		return nil, err
 	}
-	return &runtime{library: lib, controller: control, callbacks: callbacks, web: web}, nil
+	if cfg.RepeatAll || cfg.Shuffle {
+		if err := applyPolicyFlags(context.Background(), control, cfg); err != nil {
+			log.Warning("Startup playback policy not applied: " + err.Error())
+		}
+	}
+	if err := applyTimerFlags(context.Background(), control, cfg, time.Now()); err != nil {
+		log.Warning("Startup timer not applied: " + err.Error())
+	}
+	var hooks *webhook.Notifier
+	if len(cfg.Webhooks) != 0 {
+		hooks, err = webhook.New(webhook.Config{Source: control, Endpoints: cfg.Webhooks, Logger: log})
+		if err != nil {
+			log.Warning("Webhooks not started: " + err.Error())
+		}
+	}
+	var bridge *mqtt.Bridge
+	if cfg.MQTT.Broker != "" {
+		bridge, err = mqtt.New(mqtt.Config{
+			Controller:      control,
+			Broker:          mqtt.Options{Broker: cfg.MQTT.Broker, Username: cfg.MQTT.Username, Password: cfg.MQTT.Password, ClientID: cfg.MQTT.ClientID},
+			Prefix:          cfg.MQTT.Prefix,
+			DiscoveryPrefix: cfg.MQTT.DiscoveryPrefix,
+			Logger:          log,
+		})
+		if err != nil {
+			log.Warning("MQTT bridge not started: " + err.Error())
+		}
+	}
+	return &runtime{library: lib, history: history, controller: control, callbacks: callbacks, web: web, webhooks: hooks, mqtt: bridge, metrics: runtimeMetrics(control, web)}, nil
+}
+
+// runtimeMetrics reads the gauges that describe this server from the
+// controller and the Web UI at scrape time.
+func runtimeMetrics(control *controller.Controller, web *webui.Handler) *metrics.Registry {
+	registry := metrics.NewRegistry()
+	snapshot := func() (controller.Snapshot, bool) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		snapshot, err := control.Snapshot(ctx)
+		return snapshot, err == nil
+	}
+	registry.GaugeFunc("go2tv_devices", "Discovered renderers, by protocol.", []string{"protocol"}, func() []metrics.Sample {
+		snapshot, ok := snapshot()
+		if !ok {
+			return nil
+		}
+		counts := make(map[string]float64)
+		for _, device := range snapshot.Devices {
+			counts[device.Protocol]++
+		}
+		samples := make([]metrics.Sample, 0, len(counts))
+		for protocol, count := range counts {
+			samples = append(samples, metrics.Sample{Labels: []string{protocol}, Value: count})
+		}
+		return samples
+	})
+	registry.GaugeFunc("go2tv_sessions_active", "Playback sessions in progress.", nil, func() []metrics.Sample {
+		snapshot, ok := snapshot()
+		if !ok {
+			return nil
+		}
+		active := 0.0
+		for _, session := range snapshot.Sessions {
+			if session.HasSession {
+				active++
+			}
+		}
+		return []metrics.Sample{{Value: active}}
+	})
+	registry.GaugeFunc("go2tv_websocket_clients", "Connected Web UI WebSocket clients.", nil, func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(web.WebSocketClients())}}
+	})
+	return registry
+}
+
+func (r *runtime) metricsHandler() http.Handler {
+	return metrics.Handler(metrics.Default, r.metrics)
+}
+
+// applyTimerFlags arms -sleep and schedules -start-at for the next time the
+// local clock reads HH:MM after now.
+func applyTimerFlags(ctx context.Context, control *controller.Controller, cfg Config, now time.Time) error {
+	if cfg.SleepAfter > 0 {
+		if err := control.SetSleepTimer(ctx, controller.SleepTimerRequest{Mode: controller.SleepAfterTime, Duration: cfg.SleepAfter}).Err(); err != nil {
+			return err
+		}
+	}
+	if cfg.StartAt == "" {
+		return nil
+	}
+	clock, err := time.Parse("15:04", cfg.StartAt)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTimer, err)
+	}
+	at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return control.ScheduleStart(ctx, controller.ScheduleStartRequest{At: at}).Err()
+}
+
+// applyPolicyFlags turns on autoplay with -repeat-all or -shuffle, keeping the
+// rest of the policy restored from the state file.
+func applyPolicyFlags(ctx context.Context, control *controller.Controller, cfg Config) error {
+	snapshot, err := control.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	policy := snapshot.Policy
+	policy.LoopSelected = false
+	policy.AutoPlayNext = true
+	policy.RepeatAll = policy.RepeatAll || cfg.RepeatAll
+	policy.Shuffle = policy.Shuffle || cfg.Shuffle
+	return control.SetPolicy(ctx, controller.PolicyRequest{Policy: policy}).Err()
 }
 
 // verifiedFFmpegPath resolves and verifies ffmpeg. An explicitly configured
@@ -96,11 +245,50 @@ func verifiedFFmpegPath(preferred string) (string, error) {
 	return ffmpeg, nil
 }
 
+// probeTimeout bounds the ffprobe runs of one library details probe.
+const probeTimeout = 30 * time.Second
+
+// probeMedia reads library details: embedded tags always, and with ffmpeg the
+// streams through ffprobe. ffprobe reads the file the library opened, never
+// the path again, so a file swapped in meanwhile is not what gets probed.
+func probeMedia(ffmpeg string) library.ProbeFunc {
+	return func(file *os.File, meta library.Metadata) (library.MediaInfo, error) {
+		var info library.MediaInfo
+		if tags, ok := metadata.ReadTags(file, meta.Name); ok {
+			info.Title, info.Artist, info.Album = tags.Title, tags.Artist, tags.Album
+		}
+		if ffmpeg == "" {
+			return info, nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		defer cancel()
+		codecs, err := utils.GetMediaCodecInfoReader(ctx, ffmpeg, file)
+		if err != nil {
+			return library.MediaInfo{}, fmt.Errorf("probe %s: %w", meta.Name, err)
+		}
+		info.Duration = codecs.Duration
+		info.Width, info.Height = codecs.Width, codecs.Height
+		info.VideoCodec, info.AudioCodec = codecs.VideoCodec, codecs.AudioCodec
+		info.AudioLanguages = codecs.AudioLanguages
+		// Files without embedded subtitles report ErrNoSubs.
+		info.Subtitles, _ = utils.GetSubsReader(ctx, ffmpeg, file)
+		return info, nil
+	}
+}
+
 func (r *runtime) Close() {
+	if r.webhooks != nil {
+		r.webhooks.Close()
+	}
+	if r.mqtt != nil {
+		r.mqtt.Close()
+	}
 	r.web.Close()
 	r.controller.Close()
 	r.callbacks.Close()
 	_ = r.library.Close()
+	// After the controller, so the positions saved as it stops are written.
+	_ = r.history.Close()
 }
 
 type runtimeMediaServer struct{ *mediaserver.Server }
@@ -116,7 +304,9 @@ func (s *runtimeMediaServer) AddMedia(ctx context.Context, request playback.Serv
 }
 
 func (s *runtimeMediaServer) prepareRequest(request playback.ServerRequest) playback.ServerRequest {
-	if request.Subtitle != nil && request.Transcode {
+	// A remux keeps the subtitle as a side file; burning it in needs a
+	// re-encode.
+	if request.Subtitle != nil && request.Transcode && !request.Remux {
 		request.BurnSubtitle = true
 	}
 	if request.Subtitle != nil && request.Target.Protocol == "Chromecast" && !request.Transcode && filepath.Ext(request.SubtitleExt) == ".srt" {
@@ -171,6 +361,13 @@ func transcode(ctx context.Context, w http.ResponseWriter, input io.ReadCloser,
 		}
 	}
 	var command exec.Cmd
+	if request.Remux && !request.BurnSubtitle {
+		format := utils.RemuxMPEGTS
+		if request.MediaExt == ".mp4" {
+			format = utils.RemuxFragmentedMP4
+		}
+		return utils.ServeRemuxedStream(ctx, w, input, &command, &utils.TranscodeOptions{FFmpegPath: ffmpeg, SeekSeconds: request.SeekOffset}, format)
+	}
 	if isChromecastRequest(request) {
 		return utils.ServeChromecastTranscodedStream(ctx, w, input, &command, &utils.TranscodeOptions{
 			FFmpegPath:   ffmpeg,
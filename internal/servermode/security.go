@@ -10,68 +10,249 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-const sessionCookie = "go2tv_session"
+const (
+	sessionCookie = "go2tv_session"
+	loginPath     = "/login"
+	metricsPath   = "/metrics"
+	// authCheckEvery bounds how often requests stat the auth file.
+	authCheckEvery = time.Second
+)
 
 var ErrSecretGeneration = errors.New("session secret generation failed")
 
 type securityHandler struct {
 	next           http.Handler
-	secret         string
+	random         io.Reader
+	allowedMu      sync.RWMutex
 	allowedHosts   map[string]struct{}
 	allowedOrigins map[string]struct{}
 	bootstrapNext  bool
+	readOnly       func(*http.Request) *http.Request
+	// credential and recheck let the next handler drop WebSocket clients
+	// whose token or login stops validating after an auth file change.
+	credential func(*http.Request, func() bool) *http.Request
+	recheck    func()
+	// metrics serves metricsPath to cookie sessions and tokens of any scope.
+	metrics      http.Handler
+	secureCookie bool
+	loginMu      sync.Mutex
+	authFile     string
+	authMu       sync.RWMutex
+	authInfo     os.FileInfo
+	session      authSession
+	// authNext is the UnixNano time before which reloadAuth skips the stat.
+	authNext  atomic.Int64
+	authEvery time.Duration
+}
+
+// authSession is the auth store in force and the cookie that proves a
+// browser session. A password change replaces the cookie secret, which signs
+// out every browser.
+type authSession struct {
+	auth       AuthStore
+	secret     string
+	cookiePath string
 }
 
 func NewHandler(cfg Config, random io.Reader, next http.Handler) (http.Handler, error) {
+	return newSecurityHandler(cfg, random, next)
+}
+
+func newSecurityHandler(cfg Config, random io.Reader, next http.Handler) (*securityHandler, error) {
 	if random == nil {
 		random = rand.Reader
 	}
-	secretBytes := make([]byte, 32)
-	if _, err := io.ReadFull(random, secretBytes); err != nil {
-		return nil, ErrSecretGeneration
+	secret, err := newSessionSecret(random)
+	if err != nil {
+		return nil, err
 	}
 	_, bootstrapNext := next.(interface{ ServesBootstrap() })
+	var readOnly func(*http.Request) *http.Request
+	if marker, ok := next.(interface {
+		ReadOnly(*http.Request) *http.Request
+	}); ok {
+		readOnly = marker.ReadOnly
+	}
+	var credential func(*http.Request, func() bool) *http.Request
+	var recheck func()
+	if marker, ok := next.(interface {
+		Credential(*http.Request, func() bool) *http.Request
+		RecheckCredentials()
+	}); ok {
+		credential, recheck = marker.Credential, marker.RecheckCredentials
+	}
 	if next == nil {
 		next = http.NotFoundHandler()
 	}
 	h := &securityHandler{
-		next:           next,
-		secret:         base64.RawURLEncoding.EncodeToString(secretBytes),
-		allowedHosts:   make(map[string]struct{}),
-		allowedOrigins: make(map[string]struct{}),
-		bootstrapNext:  bootstrapNext,
+		next:          next,
+		random:        random,
+		bootstrapNext: bootstrapNext,
+		readOnly:      readOnly,
+		credential:    credential,
+		recheck:       recheck,
+		secureCookie:  cfg.TLSEnabled(),
+		authFile:      cfg.AuthFile,
+		authEvery:     authCheckEvery,
+	}
+	h.session.secret = secret
+	if cfg.AuthFile != "" {
+		info, _ := os.Stat(cfg.AuthFile)
+		auth, err := LoadAuth(cfg.AuthFile)
+		if err != nil {
+			return nil, err
+		}
+		h.authInfo = info
+		h.session.auth = auth
+	}
+	h.session.cookiePath = cookiePath(h.session.auth)
+	h.setAllowed(cfg)
+	return h, nil
+}
+
+func newSessionSecret(random io.Reader) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(random, secret); err != nil {
+		return "", ErrSecretGeneration
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func cookiePath(auth AuthStore) string {
+	if auth.Password != nil {
+		// The login session must also gate the page itself.
+		return "/"
+	}
+	return "/api"
+}
+
+// reloadAuth rereads the auth file when it has been replaced or changed since
+// it was last read, so tokens and passwords changed from the command line
+// apply to a running server. The file is checked at most once per authEvery;
+// a file that cannot be read keeps the previous store until a later check
+// retries it. Open WebSocket clients are rechecked after every change.
+func (h *securityHandler) reloadAuth() {
+	if h.authFile == "" {
+		return
+	}
+	now := time.Now().UnixNano()
+	next := h.authNext.Load()
+	if now < next || !h.authNext.CompareAndSwap(next, now+int64(h.authEvery)) {
+		return
+	}
+	info, err := os.Stat(h.authFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if !h.swapAuth(info) || h.recheck == nil {
+		return
+	}
+	h.recheck()
+}
+
+func (h *securityHandler) swapAuth(info os.FileInfo) bool {
+	h.authMu.Lock()
+	defer h.authMu.Unlock()
+	if sameAuthFile(h.authInfo, info) {
+		return false
+	}
+	auth, err := LoadAuth(h.authFile)
+	if err != nil {
+		return false
+	}
+	if !samePassword(h.session.auth.Password, auth.Password) {
+		if secret, err := newSessionSecret(h.random); err == nil {
+			h.session.secret = secret
+		}
 	}
+	h.authInfo = info
+	h.session.auth = auth
+	h.session.cookiePath = cookiePath(auth)
+	return true
+}
+
+func sameAuthFile(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+func samePassword(a, b *PasswordHash) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func (h *securityHandler) currentSession() authSession {
+	h.authMu.RLock()
+	defer h.authMu.RUnlock()
+	return h.session
+}
+
+// setAllowed replaces the accepted hosts and origins. A config reload calls it
+// while requests are in flight; existing cookies stay valid.
+func (h *securityHandler) setAllowed(cfg Config) {
+	hosts := make(map[string]struct{})
+	origins := make(map[string]struct{})
 	listenHost, port, _ := net.SplitHostPort(cfg.Listen)
 	if isLoopbackHost(listenHost) {
 		for _, host := range []string{"127.0.0.1", "localhost", "::1"} {
-			h.allowedHosts[net.JoinHostPort(host, port)] = struct{}{}
-			h.allowedOrigins["http://"+net.JoinHostPort(host, port)] = struct{}{}
+			hosts[net.JoinHostPort(host, port)] = struct{}{}
+			origins[cfg.scheme()+"://"+net.JoinHostPort(host, port)] = struct{}{}
 		}
 	}
 	for _, origin := range cfg.AllowedOrigins {
-		h.allowedOrigins[origin] = struct{}{}
+		origins[origin] = struct{}{}
 		u, _ := url.Parse(origin)
-		h.allowedHosts[u.Host] = struct{}{}
+		hosts[u.Host] = struct{}{}
 	}
-	return h, nil
+	h.allowedMu.Lock()
+	h.allowedHosts, h.allowedOrigins = hosts, origins
+	h.allowedMu.Unlock()
 }
 
 func (h *securityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	setSecurityHeaders(w.Header())
 	w.Header().Set("Cache-Control", "no-store")
+	h.reloadAuth()
+	session := h.currentSession()
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		h.serveBearer(w, r, session, authorization)
+		return
+	}
 	if !h.validHost(r.Host) {
 		writeAPIError(w, http.StatusForbidden, "request_not_allowed")
 		return
 	}
+	if session.auth.Password != nil {
+		if r.URL.Path == loginPath {
+			h.login(w, r, session)
+			return
+		}
+		if r.URL.Path == "/" && !session.validCookie(r) {
+			http.Redirect(w, r, loginPath, http.StatusSeeOther)
+			return
+		}
+	}
 	if r.URL.Path == "/api/bootstrap" {
 		if !h.validBootstrapSource(r) {
 			writeAPIError(w, http.StatusForbidden, "request_not_allowed")
 			return
 		}
-		h.setCookie(w)
+		if session.auth.Password != nil && !session.validCookie(r) {
+			writeAPIError(w, http.StatusUnauthorized, "login_required")
+			return
+		}
+		h.setCookie(w, session)
 		if h.bootstrapNext {
 			h.next.ServeHTTP(w, r)
 		} else {
@@ -80,19 +261,66 @@ func (h *securityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == metricsPath && h.metrics != nil {
 		validOrigin := h.validOriginIfPresent(r)
 		if r.URL.Path == "/api/ws" && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
 			validOrigin = r.Header.Get("Origin") != "" && h.sameOriginRequest(r, r.Header.Get("Origin"))
 		}
-		if !h.validCookie(r) || !validOrigin {
+		if !session.validCookie(r) || !validOrigin {
 			writeAPIError(w, http.StatusForbidden, "request_not_allowed")
 			return
 		}
+		if h.credential != nil {
+			cookieRequest := r
+			r = h.credential(r, func() bool { return h.currentSession().validCookie(cookieRequest) })
+		}
+	}
+	h.serveNext(w, r)
+}
+
+func (h *securityHandler) serveNext(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == metricsPath && h.metrics != nil {
+		h.metrics.ServeHTTP(w, r)
+		return
 	}
 	h.next.ServeHTTP(w, r)
 }
 
+// serveBearer admits API token requests. Browsers cannot attach this header
+// cross-origin without a CORS preflight the server never grants, so token
+// requests skip the host, origin, and cookie checks that guard cookie sessions.
+func (h *securityHandler) serveBearer(w http.ResponseWriter, r *http.Request, session authSession, authorization string) {
+	scheme, presented, _ := strings.Cut(authorization, " ")
+	presented = strings.TrimSpace(presented)
+	record, ok := session.auth.token(presented)
+	if !strings.EqualFold(scheme, "Bearer") || !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeAPIError(w, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+	if record.Scope != ScopeControl {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || (r.URL.Path == "/api/ws" && h.readOnly == nil) {
+			writeAPIError(w, http.StatusForbidden, "insufficient_scope")
+			return
+		}
+		if h.readOnly != nil {
+			r = h.readOnly(r)
+		}
+	}
+	if h.credential != nil {
+		r = h.credential(r, func() bool {
+			_, ok := h.currentSession().auth.token(presented)
+			return ok
+		})
+	}
+	if r.URL.Path == "/api/bootstrap" && !h.bootstrapNext {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"ok":true}`)
+		return
+	}
+	h.serveNext(w, r)
+}
+
 func setSecurityHeaders(header http.Header) {
 	header.Set("Content-Security-Policy", "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; object-src 'none'; base-uri 'none'; frame-ancestors 'none'; form-action 'self'")
 	header.Set("X-Content-Type-Options", "nosniff")
@@ -108,7 +336,9 @@ func (h *securityHandler) validHost(host string) bool {
 	if err != nil {
 		return false
 	}
+	h.allowedMu.RLock()
 	_, ok := h.allowedHosts[canonical]
+	h.allowedMu.RUnlock()
 	return ok
 }
 
@@ -153,26 +383,29 @@ func (h *securityHandler) sameOriginRequest(r *http.Request, raw string) bool {
 	if err != nil || u.Host != host {
 		return false
 	}
+	h.allowedMu.RLock()
 	_, ok := h.allowedOrigins[origin]
+	h.allowedMu.RUnlock()
 	return ok
 }
 
-func (h *securityHandler) setCookie(w http.ResponseWriter) {
+func (h *securityHandler) setCookie(w http.ResponseWriter, session authSession) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     sessionCookie,
-		Value:    h.secret,
-		Path:     "/api",
+		Value:    session.secret,
+		Path:     session.cookiePath,
 		HttpOnly: true,
+		Secure:   h.secureCookie,
 		SameSite: http.SameSiteStrictMode,
 	})
 }
 
-func (h *securityHandler) validCookie(r *http.Request) bool {
+func (s authSession) validCookie(r *http.Request) bool {
 	cookie, err := r.Cookie(sessionCookie)
-	if err != nil || len(cookie.Value) != len(h.secret) {
+	if err != nil || len(cookie.Value) != len(s.secret) {
 		return false
 	}
-	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(h.secret)) == 1
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(s.secret)) == 1
 }
 
 func writeAPIError(w http.ResponseWriter, status int, code string) {
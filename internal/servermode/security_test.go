@@ -46,6 +46,8 @@ func TestSecurityBoundary(t *testing.T) {
 	}{
 		{name: "cookie API", path: "/api/state", host: "127.0.0.1:9666", cookie: cookie, want: http.StatusNoContent},
 		{name: "websocket covered", path: "/api/ws", host: "127.0.0.1:9666", cookie: cookie, want: http.StatusNoContent},
+		{name: "versioned API covered", path: "/api/v1/player/pause", host: "127.0.0.1:9666", cookie: cookie, want: http.StatusNoContent},
+		{name: "versioned API without cookie", path: "/api/v1/player/pause", host: "127.0.0.1:9666", want: http.StatusForbidden},
 		{name: "missing cookie", path: "/api/state", host: "127.0.0.1:9666", want: http.StatusForbidden},
 		{name: "wrong cookie", path: "/api/state", host: "127.0.0.1:9666", cookie: &http.Cookie{Name: sessionCookie, Value: strings.Repeat("x", 43)}, want: http.StatusForbidden},
 		{name: "unknown host", path: "/api/state", host: "evil.test:9666", cookie: cookie, want: http.StatusForbidden},
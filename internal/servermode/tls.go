@@ -0,0 +1,226 @@
+package servermode
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	selfSignedValidity = 365 * 24 * time.Hour
+	// selfSignedRenewal regenerates a self-signed certificate this long before
+	// it expires, both at startup and from the first handshake inside that
+	// window, so a long-running server never serves an expired one.
+	selfSignedRenewal = 30 * 24 * time.Hour
+)
+
+var ErrInvalidTLS = errors.New("invalid TLS configuration")
+
+// TLSEnabled reports whether the server terminates TLS itself.
+func (c Config) TLSEnabled() bool {
+	return c.TLSSelfSigned || c.TLSCertFile != ""
+}
+
+func (c Config) scheme() string {
+	if c.TLSEnabled() {
+		return "https"
+	}
+	return "http"
+}
+
+// validateTLS checks the certificate options and fills the default location
+// of a self-signed certificate.
+func validateTLS(cfg Config) (Config, error) {
+	if cfg.ManagedChild && (cfg.TLSEnabled() || cfg.TLSKeyFile != "") {
+		// The desktop app talks to its child over plain HTTP on loopback, so
+		// Secure cookies and https origins would lock it out.
+		return Config{}, fmt.Errorf("%w: TLS options cannot be used with -managed-child", ErrInvalidTLS)
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return Config{}, fmt.Errorf("%w: -tls-cert and -tls-key must be used together", ErrInvalidTLS)
+	}
+	if cfg.TLSSelfSigned {
+		if cfg.TLSCertFile == "" {
+			dir, err := os.UserConfigDir()
+			if err != nil {
+				return Config{}, fmt.Errorf("%w: %v", ErrInvalidTLS, err)
+			}
+			cfg.TLSCertFile = filepath.Join(dir, "go2tv", "server-cert.pem")
+			cfg.TLSKeyFile = filepath.Join(dir, "go2tv", "server-key.pem")
+		}
+		return cfg, nil
+	}
+	if cfg.TLSCertFile != "" {
+		if _, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+			return Config{}, fmt.Errorf("%w: %v", ErrInvalidTLS, err)
+		}
+	}
+	return cfg, nil
+}
+
+// loadCertificate returns the serving certificate, creating or renewing the
+// persisted self-signed one when needed.
+func loadCertificate(cfg Config, now time.Time) (tls.Certificate, error) {
+	if cfg.TLSSelfSigned {
+		if err := ensureSelfSigned(cfg, now); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	certificate, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("%w: %v", ErrInvalidTLS, err)
+	}
+	return certificate, nil
+}
+
+// servingCertificate supplies the certificate for each TLS handshake. A
+// self-signed certificate is renewed in place once it enters the renewal
+// window; a supplied certificate is served as loaded.
+type servingCertificate struct {
+	cfg     Config
+	now     func() time.Time
+	renewed func(tls.Certificate)
+	mu      sync.Mutex
+	current tls.Certificate
+}
+
+func newServingCertificate(cfg Config, now func() time.Time, renewed func(tls.Certificate)) (*servingCertificate, error) {
+	certificate, err := loadCertificate(cfg, now())
+	if err != nil {
+		return nil, err
+	}
+	return &servingCertificate{cfg: cfg, now: now, renewed: renewed, current: certificate}, nil
+}
+
+func (s *servingCertificate) certificate() tls.Certificate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// GetCertificate implements tls.Config.GetCertificate. A failed renewal keeps
+// the current certificate and is retried on the next handshake.
+func (s *servingCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.cfg.TLSSelfSigned && s.current.Leaf != nil && now.Add(selfSignedRenewal).After(s.current.Leaf.NotAfter) {
+		if certificate, err := loadCertificate(s.cfg, now); err == nil {
+			s.current = certificate
+			if s.renewed != nil {
+				s.renewed(certificate)
+			}
+		}
+	}
+	certificate := s.current
+	return &certificate, nil
+}
+
+// certificateHosts lists the names a browser may use to reach the server.
+func certificateHosts(cfg Config) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, err := net.SplitHostPort(cfg.Listen); err == nil {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+			hosts = append(hosts, host)
+		}
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if u, err := url.Parse(origin); err == nil {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	return hosts
+}
+
+func ensureSelfSigned(cfg Config, now time.Time) error {
+	hosts := certificateHosts(cfg)
+	if current, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile); err == nil && coversHosts(current.Leaf, hosts, now) {
+		return nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Go2TV server"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.TLSCertFile), 0o700); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTLS, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.TLSKeyFile), 0o700); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTLS, err)
+	}
+	if err := os.WriteFile(cfg.TLSKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTLS, err)
+	}
+	if err := os.WriteFile(cfg.TLSCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTLS, err)
+	}
+	return nil
+}
+
+func coversHosts(leaf *x509.Certificate, hosts []string, now time.Time) bool {
+	if leaf == nil || now.Add(selfSignedRenewal).After(leaf.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// certificateFingerprint is the SHA-256 of the leaf certificate, formatted the
+// way browsers display it, so users can verify a self-signed certificate.
+func certificateFingerprint(certificate tls.Certificate) string {
+	if len(certificate.Certificate) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(certificate.Certificate[0])
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
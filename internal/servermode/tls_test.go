@@ -0,0 +1,95 @@
+package servermode
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	goruntime "runtime"
+	"testing"
+	"time"
+)
+
+func TestSelfSignedCertificatePersistsAndRenews(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cfg := Config{Listen: "0.0.0.0:9666", AllowedOrigins: []string{"https://tv.local:9666", "https://192.168.1.20:9666"}, TLSSelfSigned: true, TLSCertFile: filepath.Join(dir, "tls", "cert.pem"), TLSKeyFile: filepath.Join(dir, "tls", "key.pem")}
+	now := time.Now()
+	first, err := loadCertificate(cfg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "127.0.0.1", "tv.local", "192.168.1.20"} {
+		if err := first.Leaf.VerifyHostname(host); err != nil {
+			t.Fatalf("certificate does not cover %s: %v", host, err)
+		}
+	}
+	if info, err := os.Stat(cfg.TLSKeyFile); err != nil || (goruntime.GOOS != "windows" && info.Mode().Perm() != 0o600) {
+		t.Fatalf("key file = %v, %v", info, err)
+	}
+	if !regexp.MustCompile(`^([0-9A-F]{2}:){31}[0-9A-F]{2}$`).MatchString(certificateFingerprint(first)) {
+		t.Fatalf("fingerprint = %q", certificateFingerprint(first))
+	}
+
+	again, err := loadCertificate(cfg, now.Add(time.Hour))
+	if err != nil || !bytes.Equal(again.Certificate[0], first.Certificate[0]) {
+		t.Fatalf("persisted certificate not reused: %v", err)
+	}
+	cfg.AllowedOrigins = append(cfg.AllowedOrigins, "https://new.local:9666")
+	widened, err := loadCertificate(cfg, now.Add(time.Hour))
+	if err != nil || widened.Leaf.VerifyHostname("new.local") != nil {
+		t.Fatalf("new origin host not added: %v", err)
+	}
+	renewed, err := loadCertificate(cfg, now.Add(selfSignedValidity-selfSignedRenewal/2))
+	if err != nil || bytes.Equal(renewed.Certificate[0], widened.Certificate[0]) {
+		t.Fatalf("expiring certificate not renewed: %v", err)
+	}
+}
+
+func TestServingCertificateRenewsWhileRunning(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cfg := Config{Listen: DefaultListen, TLSSelfSigned: true, TLSCertFile: filepath.Join(dir, "cert.pem"), TLSKeyFile: filepath.Join(dir, "key.pem")}
+	now := time.Now()
+	clock := now
+	var renewals int
+	serving, err := newServingCertificate(cfg, func() time.Time { return clock }, func(tls.Certificate) { renewals++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := serving.GetCertificate(nil)
+	if err != nil || !bytes.Equal(first.Certificate[0], serving.certificate().Certificate[0]) || renewals != 0 {
+		t.Fatalf("fresh certificate renewed: %v, %d renewals", err, renewals)
+	}
+	clock = now.Add(selfSignedValidity + time.Hour)
+	renewed, err := serving.GetCertificate(nil)
+	if err != nil || bytes.Equal(renewed.Certificate[0], first.Certificate[0]) || renewals != 1 || !renewed.Leaf.NotAfter.After(clock) {
+		t.Fatalf("expired certificate served: %v, %d renewals", err, renewals)
+	}
+}
+
+func TestSecurityBoundaryUnderTLS(t *testing.T) {
+	t.Parallel()
+	handler, err := NewHandler(Config{Listen: DefaultListen, TLSSelfSigned: true}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for origin, want := range map[string]int{"https://127.0.0.1:9666": http.StatusOK, "http://127.0.0.1:9666": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, "https://127.0.0.1:9666/api/bootstrap", nil)
+		req.Header.Set("Origin", origin)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		if res.Code != want {
+			t.Fatalf("%s bootstrap = %d, want %d", origin, res.Code, want)
+		}
+		if want == http.StatusOK {
+			cookies := res.Result().Cookies()
+			if len(cookies) != 1 || !cookies[0].Secure {
+				t.Fatalf("cookie not Secure: %#v", cookies)
+			}
+		}
+	}
+}
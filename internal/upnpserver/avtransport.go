@@ -0,0 +1,293 @@
+package upnpserver
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/utils"
+)
+
+// AVTransport and RenderingControl errors.
+var (
+	errTransitionNotAvailable = upnpError{701, "Transition not available"}
+	errNoContents             = upnpError{702, "No contents"}
+	errIllegalSeekTarget      = upnpError{711, "Illegal seek target"}
+	errIllegalMIMEType        = upnpError{714, "Illegal MIME-type"}
+	errResourceNotFound       = upnpError{716, "Resource not found"}
+	errInvalidInstanceID      = upnpError{718, "Invalid InstanceID"}
+	errInvalidPresetName      = upnpError{701, "Invalid Name"}
+)
+
+// transportActions lists what a control point may do next in each state.
+var transportActions = map[string]string{
+	"NO_MEDIA_PRESENT": "",
+	"STOPPED":          "Play",
+	"PLAYING":          "Pause,Stop,Seek",
+	"PAUSED_PLAYBACK":  "Play,Stop,Seek",
+	"TRANSITIONING":    "Stop",
+}
+
+// instance checks the InstanceID every renderer action takes. There is one
+// instance, 0.
+func instance(args map[string]string) error {
+	id, ok := args["InstanceID"]
+	switch {
+	case !ok:
+		return errInvalidArgs
+	case id != "0":
+		return errInvalidInstanceID
+	}
+	return nil
+}
+
+func (r *Renderer) avTransport(req *http.Request, action string, args map[string]string) ([]soapArg, error) {
+	if err := instance(args); err != nil {
+		return nil, err
+	}
+	ctx := req.Context()
+	switch action {
+	case "SetAVTransportURI":
+		return nil, r.setURI(ctx, args["CurrentURI"], args["CurrentURIMetaData"])
+	case "Play":
+		return nil, r.play(ctx)
+	case "Pause":
+		return nil, r.transition(ctx, []string{"PLAYING"}, func(mutation controller.Mutation) controller.Result {
+			return r.cfg.Controller.Pause(ctx, mutation)
+		})
+	case "Stop":
+		return nil, r.stop(ctx)
+	case "Seek":
+		return nil, r.seek(ctx, args["Unit"], args["Target"])
+	case "Next", "Previous":
+		return nil, errTransitionNotAvailable
+	}
+	state, err := r.state(ctx)
+	if err != nil {
+		return nil, errActionFailed
+	}
+	switch action {
+	case "GetTransportInfo":
+		return []soapArg{
+			{"CurrentTransportState", state.transport},
+			{"CurrentTransportStatus", "OK"},
+			{"CurrentSpeed", "1"},
+		}, nil
+	case "GetMediaInfo":
+		tracks, medium := "0", "NONE"
+		if state.media.uri != "" {
+			tracks, medium = "1", "NETWORK"
+		}
+		return []soapArg{
+			{"NrTracks", tracks},
+			{"MediaDuration", state.duration()},
+			{"CurrentURI", state.media.uri},
+			{"CurrentURIMetaData", state.media.metadata},
+			{"NextURI", ""},
+			{"NextURIMetaData", ""},
+			{"PlayMedium", medium},
+			{"RecordMedium", "NOT_IMPLEMENTED"},
+			{"WriteStatus", "NOT_IMPLEMENTED"},
+		}, nil
+	case "GetPositionInfo":
+		track, position := "0", utils.SecondsToClockTime(0)
+		if state.media.uri != "" {
+			track = "1"
+		}
+		if state.transport == "PLAYING" || state.transport == "PAUSED_PLAYBACK" {
+			position = utils.SecondsToClockTime(state.playing.Position)
+		}
+		return []soapArg{
+			{"Track", track},
+			{"TrackDuration", state.duration()},
+			{"TrackMetaData", state.media.metadata},
+			{"TrackURI", state.media.uri},
+			{"RelTime", position},
+			{"AbsTime", position},
+			{"RelCount", "2147483647"},
+			{"AbsCount", "2147483647"},
+		}, nil
+	case "GetDeviceCapabilities":
+		return []soapArg{{"PlayMedia", "NETWORK"}, {"RecMedia", "NOT_IMPLEMENTED"}, {"RecQualityModes", "NOT_IMPLEMENTED"}}, nil
+	case "GetTransportSettings":
+		return []soapArg{{"PlayMode", "NORMAL"}, {"RecQualityMode", "NOT_IMPLEMENTED"}}, nil
+	case "GetCurrentTransportActions":
+		return []soapArg{{"Actions", transportActions[state.transport]}}, nil
+	default:
+		return nil, errInvalidAction
+	}
+}
+
+// duration is the length of the relayed media: the controller's once it
+// plays, the metadata's before then.
+func (s rendererState) duration() string {
+	if (s.transport == "PLAYING" || s.transport == "PAUSED_PLAYBACK") && s.playing.Duration > 0 {
+		return utils.SecondsToClockTime(s.playing.Duration)
+	}
+	if s.media.duration != "" {
+		return s.media.duration
+	}
+	return utils.SecondsToClockTime(0)
+}
+
+// setURI replaces the media. As on a real renderer, media set while playing
+// starts at once; otherwise it waits for Play.
+func (r *Renderer) setURI(ctx context.Context, uri, metadata string) error {
+	media, err := relayRef(strings.TrimSpace(uri), metadata)
+	if err != nil {
+		return err
+	}
+	r.actions.Lock()
+	defer r.actions.Unlock()
+	state, err := r.state(ctx)
+	if err != nil {
+		return errActionFailed
+	}
+	r.mu.Lock()
+	r.media, r.relaying = media, false
+	r.mu.Unlock()
+	if state.transport != "PLAYING" && state.transport != "TRANSITIONING" {
+		return nil
+	}
+	if err := r.relay(ctx, media, state.snapshot); err != nil {
+		return errActionFailed
+	}
+	return nil
+}
+
+func (r *Renderer) play(ctx context.Context) error {
+	r.actions.Lock()
+	defer r.actions.Unlock()
+	state, err := r.state(ctx)
+	if err != nil {
+		return errActionFailed
+	}
+	switch state.transport {
+	case "NO_MEDIA_PRESENT":
+		return errNoContents
+	case "PLAYING", "TRANSITIONING":
+		return nil
+	case "PAUSED_PLAYBACK":
+		if err := r.cfg.Controller.Resume(ctx, controller.Mutation{Session: state.session}).Err(); err != nil {
+			return errActionFailed
+		}
+		return nil
+	}
+	if err := r.relay(ctx, state.media, state.snapshot); err != nil {
+		return errActionFailed
+	}
+	return nil
+}
+
+func (r *Renderer) stop(ctx context.Context) error {
+	r.actions.Lock()
+	defer r.actions.Unlock()
+	state, err := r.state(ctx)
+	if err != nil {
+		return errActionFailed
+	}
+	switch state.transport {
+	case "NO_MEDIA_PRESENT":
+		return errTransitionNotAvailable
+	case "STOPPED":
+		return nil
+	}
+	if err := r.cfg.Controller.Stop(ctx, controller.Mutation{Session: state.session}).Err(); err != nil {
+		return errActionFailed
+	}
+	r.mu.Lock()
+	r.relaying = false
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Renderer) seek(ctx context.Context, unit, target string) error {
+	if unit != "REL_TIME" && unit != "ABS_TIME" {
+		return errInvalidArgs
+	}
+	seconds, err := utils.ClockTimeToSeconds(strings.TrimSpace(target))
+	if err != nil || seconds < 0 {
+		return errIllegalSeekTarget
+	}
+	return r.transition(ctx, []string{"PLAYING", "PAUSED_PLAYBACK"}, func(mutation controller.Mutation) controller.Result {
+		return r.cfg.Controller.Seek(ctx, controller.SeekRequest{Mutation: mutation, Seconds: seconds})
+	})
+}
+
+// transition runs command on the relay session when the transport is in one
+// of the from states.
+func (r *Renderer) transition(ctx context.Context, from []string, command func(controller.Mutation) controller.Result) error {
+	r.actions.Lock()
+	defer r.actions.Unlock()
+	state, err := r.state(ctx)
+	if err != nil {
+		return errActionFailed
+	}
+	if !slices.Contains(from, state.transport) {
+		return errTransitionNotAvailable
+	}
+	if err := command(controller.Mutation{Session: state.session}).Err(); err != nil {
+		return errActionFailed
+	}
+	return nil
+}
+
+func (r *Renderer) renderingControl(req *http.Request, action string, args map[string]string) ([]soapArg, error) {
+	if err := instance(args); err != nil {
+		return nil, err
+	}
+	ctx := req.Context()
+	switch action {
+	case "ListPresets":
+		return []soapArg{{"CurrentPresetNameList", "FactoryDefaults"}}, nil
+	case "SelectPreset":
+		if args["PresetName"] != "FactoryDefaults" {
+			return nil, errInvalidPresetName
+		}
+		return nil, nil
+	}
+	if args["Channel"] != "Master" {
+		return nil, errInvalidArgs
+	}
+	state, err := r.state(ctx)
+	if err != nil {
+		return nil, errActionFailed
+	}
+	mutation := controller.Mutation{Session: state.session}
+	switch action {
+	case "GetVolume":
+		return []soapArg{{"CurrentVolume", strconv.Itoa(state.playing.Volume)}}, nil
+	case "GetMute":
+		return []soapArg{{"CurrentMute", upnpBool(state.playing.Muted)}}, nil
+	case "SetVolume":
+		volume, err := strconv.Atoi(args["DesiredVolume"])
+		if err != nil || volume < 0 || volume > 100 {
+			return nil, errInvalidArgs
+		}
+		if err := r.cfg.Controller.SetVolume(ctx, mutation, volume).Err(); err != nil {
+			return nil, errActionFailed
+		}
+		return nil, nil
+	case "SetMute":
+		muted, err := strconv.ParseBool(args["DesiredMute"])
+		if err != nil {
+			return nil, errInvalidArgs
+		}
+		if err := r.cfg.Controller.SetMute(ctx, mutation, muted).Err(); err != nil {
+			return nil, errActionFailed
+		}
+		return nil, nil
+	default:
+		return nil, errInvalidAction
+	}
+}
+
+func upnpBool(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
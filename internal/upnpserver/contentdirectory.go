@@ -0,0 +1,456 @@
+package upnpserver
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/soapcalls"
+	"go2tv.app/go2tv/v2/utils"
+)
+
+const (
+	// rootObjectID is the container listing the library roots. Below it a
+	// root is "<root>" and anything inside one "<root>/<entry>".
+	rootObjectID = "0"
+	// maxChildren bounds the folder listing Browse pages through. Entries
+	// past it are not listed.
+	maxChildren = library.MaxFolderFiles
+	// maxSearchResults bounds the matches one Search collects.
+	maxSearchResults = 1000
+	// snapshotTTL is how long a folder listing or search result is kept for
+	// the requests that page through it, and maxSnapshots how many are kept.
+	snapshotTTL  = 30 * time.Second
+	maxSnapshots = 8
+	// systemUpdateID is constant: changes are not tracked, so control points
+	// re-browse to see them.
+	systemUpdateID = "1"
+)
+
+func (s *Server) contentDirectory(r *http.Request, action string, args map[string]string) ([]soapArg, error) {
+	switch action {
+	case "Browse":
+		return s.browse(r, args)
+	case "Search":
+		return s.search(r, args)
+	case "GetSearchCapabilities":
+		return []soapArg{{"SearchCaps", "dc:title,upnp:class,upnp:artist,upnp:album"}}, nil
+	case "GetSortCapabilities":
+		return []soapArg{{"SortCaps", ""}}, nil
+	case "GetSystemUpdateID":
+		return []soapArg{{"Id", systemUpdateID}}, nil
+	default:
+		return nil, errInvalidAction
+	}
+}
+
+// listing is a Browse or Search result: containers, then items.
+type listing struct {
+	containers []soapcalls.DIDLContainer
+	items      []soapcalls.DIDLItem
+}
+
+func (l listing) result(total int) ([]soapArg, error) {
+	didl, err := soapcalls.BuildDIDLLite(l.containers, l.items)
+	if err != nil {
+		return nil, err
+	}
+	return []soapArg{
+		{"Result", string(didl)},
+		{"NumberReturned", strconv.Itoa(len(l.containers) + len(l.items))},
+		{"TotalMatches", strconv.Itoa(total)},
+		{"UpdateID", systemUpdateID},
+	}, nil
+}
+
+func (s *Server) browse(r *http.Request, args map[string]string) ([]soapArg, error) {
+	start, count, err := window(args)
+	if err != nil {
+		return nil, err
+	}
+	objectID := args["ObjectID"]
+	switch args["BrowseFlag"] {
+	case "BrowseMetadata":
+		object, err := s.metadata(r, objectID)
+		if err != nil {
+			return nil, err
+		}
+		return object.result(1)
+	case "BrowseDirectChildren":
+		if objectID == rootObjectID {
+			return s.rootChildren(start, count)
+		}
+		rootID, entryID, ok := parseObjectID(objectID)
+		if !ok {
+			return nil, errNoSuchObject
+		}
+		entries, err := s.folder(rootID, entryID, start == 0)
+		if err != nil {
+			return nil, err
+		}
+		total := len(entries)
+		return s.entryListing(r, rootID, objectID, page(entries, start, count)).result(total)
+	default:
+		return nil, errInvalidArgs
+	}
+}
+
+func (s *Server) rootChildren(start, count int) ([]soapArg, error) {
+	roots := s.cfg.Library.Roots()
+	var result listing
+	for _, root := range page(roots, start, count) {
+		result.containers = append(result.containers, soapcalls.DIDLContainer{ID: root.ID, ParentID: rootObjectID, Title: root.Name, ChildCount: -1, Searchable: true})
+	}
+	return result.result(len(roots))
+}
+
+// metadata describes the object objectID names.
+func (s *Server) metadata(r *http.Request, objectID string) (listing, error) {
+	if objectID == rootObjectID {
+		container := soapcalls.DIDLContainer{ID: rootObjectID, ParentID: "-1", Title: s.cfg.Name, ChildCount: len(s.cfg.Library.Roots()), Searchable: true}
+		return listing{containers: []soapcalls.DIDLContainer{container}}, nil
+	}
+	rootID, entryID, ok := parseObjectID(objectID)
+	if !ok {
+		return listing{}, errNoSuchObject
+	}
+	if entryID == "" {
+		for _, root := range s.cfg.Library.Roots() {
+			if root.ID != rootID {
+				continue
+			}
+			entries, err := s.folder(rootID, "", false)
+			if err != nil {
+				return listing{}, err
+			}
+			container := soapcalls.DIDLContainer{ID: rootID, ParentID: rootObjectID, Title: root.Name, ChildCount: len(entries), Searchable: true}
+			return listing{containers: []soapcalls.DIDLContainer{container}}, nil
+		}
+		return listing{}, errNoSuchObject
+	}
+	entry, parentID, err := s.cfg.Library.Lookup(rootID, entryID)
+	if err != nil || !playable(entry) {
+		return listing{}, errNoSuchObject
+	}
+	parentObject := objectIDFor(rootID, parentID)
+	if entry.Kind == "directory" {
+		entries, err := s.folder(rootID, entryID, false)
+		if err != nil {
+			return listing{}, err
+		}
+		container := soapcalls.DIDLContainer{ID: objectID, ParentID: parentObject, Title: entry.Name, ChildCount: len(entries), Searchable: true}
+		return listing{containers: []soapcalls.DIDLContainer{container}}, nil
+	}
+	return s.entryListing(r, rootID, parentObject, []library.Entry{entry}), nil
+}
+
+// folder returns a whole library folder, folders first and then by name, so
+// that StartingIndex picks out the same entry on every request. Unless fresh
+// is set, a listing read in the last snapshotTTL is reused.
+func (s *Server) folder(rootID, entryID string, fresh bool) ([]library.Entry, error) {
+	key := "folder\x00" + objectIDFor(rootID, entryID)
+	if !fresh {
+		if entries, ok := s.snapshots.get(key); ok {
+			return entries.([]library.Entry), nil
+		}
+	}
+	entries, err := s.readFolder(rootID, entryID)
+	if err != nil {
+		return nil, err
+	}
+	s.snapshots.put(key, entries)
+	return entries, nil
+}
+
+func (s *Server) readFolder(rootID, entryID string) ([]library.Entry, error) {
+	var entries []library.Entry
+	cursor := ""
+	// Browse cursors are shared with the web UI, so one left open at the
+	// cap or after an error is released rather than left to expire.
+	defer func() { s.cfg.Library.CloseCursor(cursor) }()
+	for {
+		page, err := s.cfg.Library.Browse(rootID, entryID, cursor, library.MaxLimit)
+		switch {
+		case errors.Is(err, library.ErrInvalidRoot), errors.Is(err, library.ErrInvalidEntry), errors.Is(err, library.ErrNotDirectory), errors.Is(err, library.ErrNotRegular):
+			return nil, errNoSuchObject
+		case err != nil:
+			return nil, err
+		}
+		for _, entry := range page.Entries {
+			if playable(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		cursor = page.Cursor
+		if cursor == "" || len(entries) >= maxChildren {
+			break
+		}
+	}
+	entries = entries[:min(len(entries), maxChildren)]
+	slices.SortStableFunc(entries, func(a, b library.Entry) int {
+		if (a.Kind == "directory") != (b.Kind == "directory") {
+			if a.Kind == "directory" {
+				return -1
+			}
+			return 1
+		}
+		return mediamodel.CompareMediaPaths(a.Name, b.Name)
+	})
+	return entries, nil
+}
+
+// entryListing turns library entries inside parentObject into DIDL objects.
+func (s *Server) entryListing(r *http.Request, rootID, parentObject string, entries []library.Entry) listing {
+	var result listing
+	for _, entry := range entries {
+		if entry.Kind == "directory" {
+			result.containers = append(result.containers, soapcalls.DIDLContainer{ID: objectIDFor(rootID, entry.ID), ParentID: parentObject, Title: entry.Name, ChildCount: -1, Searchable: true})
+			continue
+		}
+		result.items = append(result.items, s.item(r, rootID, parentObject, entry))
+	}
+	return result
+}
+
+func (s *Server) item(r *http.Request, rootID, parentObject string, entry library.Entry) soapcalls.DIDLItem {
+	base := s.baseURL(r)
+	route := rootID + "/" + entry.ID
+	item := soapcalls.DIDLItem{
+		ID:        objectIDFor(rootID, entry.ID),
+		ParentID:  parentObject,
+		Title:     entry.Name,
+		MediaType: mediaTypeFor(entry.Name),
+		URL:       base + mediaPrefix + route + strings.ToLower(path.Ext(entry.Name)),
+		Size:      entry.Size,
+		Seekable:  true,
+	}
+	if media := entry.Media; media != nil {
+		if media.Title != "" {
+			item.Title = media.Title
+		}
+		item.Artist, item.Album = media.Artist, media.Album
+		if media.Duration > 0 {
+			item.Duration = utils.SecondsToClockTime(int(math.Round(media.Duration)))
+		}
+	}
+	kind := mediamodel.KindForPath(entry.Name)
+	if kind != mediamodel.MediaKindImage && s.cfg.Artwork != nil {
+		item.ArtworkURL = base + artworkPrefix + route + ".jpg"
+	}
+	if kind == mediamodel.MediaKindVideo {
+		if file, _, err := s.cfg.Library.OpenSidecar(rootID, entry.ID, ".srt"); err == nil {
+			_ = file.Close()
+			item.SubtitlesURL = base + subtitlePrefix + route + ".srt"
+		}
+	}
+	return item
+}
+
+func (s *Server) search(r *http.Request, args map[string]string) ([]soapArg, error) {
+	start, count, err := window(args)
+	if err != nil {
+		return nil, err
+	}
+	criteria, ok := parseCriteria(args["SearchCriteria"])
+	if !ok {
+		return nil, errInvalidCriteria
+	}
+	queries := titleQueries(criteria)
+	if queries == nil {
+		// Without a title to look for, every file would be walked.
+		return nil, errInvalidCriteria
+	}
+	inScope, err := s.searchScope(args["ContainerID"])
+	if err != nil {
+		return nil, err
+	}
+
+	key := "search\x00" + args["ContainerID"] + "\x00" + args["SearchCriteria"]
+	var matches []library.SearchResult
+	if cached, ok := s.snapshots.get(key); ok && start > 0 {
+		matches = cached.([]library.SearchResult)
+	} else {
+		keep := func(result library.SearchResult) bool {
+			return playable(result.Entry) && inScope(result) && criteria.match(propsFor(result.Entry))
+		}
+		seen := make(map[string]bool)
+		for _, query := range queries {
+			if matches, err = s.searchQuery(query, keep, seen, matches); err != nil {
+				return nil, err
+			}
+		}
+		s.snapshots.put(key, matches)
+	}
+
+	var result listing
+	for _, match := range page(matches, start, count) {
+		_, parentID, err := s.cfg.Library.Lookup(match.RootID, match.ID)
+		if err != nil {
+			continue
+		}
+		result.items = append(result.items, s.item(r, match.RootID, objectIDFor(match.RootID, parentID), match.Entry))
+	}
+	return result.result(len(matches))
+}
+
+// searchQuery appends the library results for one title query that keep
+// accepts and seen does not yet hold, up to maxSearchResults in all.
+func (s *Server) searchQuery(query string, keep func(library.SearchResult) bool, seen map[string]bool, matches []library.SearchResult) ([]library.SearchResult, error) {
+	cursor := ""
+	defer func() { s.cfg.Library.CloseCursor(cursor) }()
+	for len(matches) < maxSearchResults {
+		found, err := s.cfg.Library.Search(query, cursor, library.MaxLimit)
+		if errors.Is(err, library.ErrInvalidQuery) {
+			return nil, errInvalidCriteria
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range found.Results {
+			key := result.RootID + "/" + result.ID
+			if seen[key] || !keep(result) {
+				continue
+			}
+			seen[key] = true
+			matches = append(matches, result)
+		}
+		if cursor = found.Cursor; cursor == "" {
+			break
+		}
+	}
+	return matches[:min(len(matches), maxSearchResults)], nil
+}
+
+// searchScope reports whether a search result lies inside containerID.
+func (s *Server) searchScope(containerID string) (func(library.SearchResult) bool, error) {
+	if containerID == rootObjectID {
+		return func(library.SearchResult) bool { return true }, nil
+	}
+	rootID, entryID, ok := parseObjectID(containerID)
+	if !ok {
+		return nil, errNoSuchContainer
+	}
+	if entryID == "" {
+		if !slices.ContainsFunc(s.cfg.Library.Roots(), func(root library.Root) bool { return root.ID == rootID }) {
+			return nil, errNoSuchContainer
+		}
+		return func(result library.SearchResult) bool { return result.RootID == rootID }, nil
+	}
+	entry, _, err := s.cfg.Library.Lookup(rootID, entryID)
+	if err != nil || entry.Kind != "directory" {
+		return nil, errNoSuchContainer
+	}
+	location, err := s.cfg.Library.Locate(rootID, entryID)
+	if err != nil {
+		return nil, errNoSuchContainer
+	}
+	return func(result library.SearchResult) bool {
+		return result.RootID == rootID && (result.Parent == location.Path || strings.HasPrefix(result.Parent, location.Path+"/"))
+	}, nil
+}
+
+// playable reports whether entry is listed. Subtitle files are offered with
+// the video they belong to rather than on their own.
+func playable(entry library.Entry) bool {
+	return entry.Kind == "directory" || mediamodel.KindForPath(entry.Name) != mediamodel.MediaKindUnknown
+}
+
+func propsFor(entry library.Entry) objectProps {
+	props := objectProps{title: []string{entry.Name}, class: soapcalls.UPNPClass(mediaTypeFor(entry.Name))}
+	if media := entry.Media; media != nil {
+		props.title = append(props.title, media.Title)
+		props.artist, props.album = media.Artist, media.Album
+	}
+	return props
+}
+
+// window reads StartingIndex and RequestedCount. A zero count asks for
+// everything from the start.
+func window(args map[string]string) (start, count int, err error) {
+	first, firstErr := strconv.ParseUint(args["StartingIndex"], 10, 31)
+	requested, requestedErr := strconv.ParseUint(args["RequestedCount"], 10, 31)
+	if firstErr != nil || requestedErr != nil {
+		return 0, 0, errInvalidArgs
+	}
+	return int(first), int(requested), nil
+}
+
+func page[T any](values []T, start, count int) []T {
+	if start >= len(values) {
+		return nil
+	}
+	values = values[start:]
+	if count > 0 && count < len(values) {
+		values = values[:count]
+	}
+	return values
+}
+
+func objectIDFor(rootID, entryID string) string {
+	if entryID == "" {
+		return rootID
+	}
+	return rootID + "/" + entryID
+}
+
+// parseObjectID splits a root or entry object ID. Entry IDs are signed, so a
+// forged one fails its lookup.
+func parseObjectID(objectID string) (rootID, entryID string, ok bool) {
+	rootID, entryID, _ = strings.Cut(objectID, "/")
+	return rootID, entryID, rootID != "" && rootID != rootObjectID && !strings.Contains(entryID, "/")
+}
+
+// snapshotCache keeps recent folder listings and search results, so a control
+// point paging through one with StartingIndex does not have it read again for
+// every page. A request for the first page reads afresh.
+type snapshotCache struct {
+	mu    sync.Mutex
+	items map[string]snapshot
+}
+
+type snapshot struct {
+	value   any
+	expires time.Time
+}
+
+func (c *snapshotCache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	if !ok || time.Now().After(item.expires) {
+		return nil, false
+	}
+	return item.value, true
+}
+
+func (c *snapshotCache) put(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.items == nil {
+		c.items = make(map[string]snapshot)
+	}
+	for stale, item := range c.items {
+		if now.After(item.expires) {
+			delete(c.items, stale)
+		}
+	}
+	if _, ok := c.items[key]; !ok && len(c.items) >= maxSnapshots {
+		oldest := ""
+		for other, item := range c.items {
+			if oldest == "" || item.expires.Before(c.items[oldest].expires) {
+				oldest = other
+			}
+		}
+		delete(c.items, oldest)
+	}
+	c.items[key] = snapshot{value: value, expires: now.Add(snapshotTTL)}
+}
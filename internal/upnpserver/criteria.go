@@ -0,0 +1,263 @@
+package upnpserver
+
+import (
+	"strings"
+)
+
+// objectProps are the properties search criteria are matched against.
+type objectProps struct {
+	title  []string
+	class  string
+	artist string
+	album  string
+}
+
+func (p objectProps) values(property string) ([]string, bool) {
+	switch property {
+	case "dc:title":
+		return p.title, true
+	case "upnp:class":
+		return []string{p.class}, true
+	case "upnp:artist", "dc:creator":
+		return []string{p.artist}, true
+	case "upnp:album":
+		return []string{p.album}, true
+	default:
+		return nil, false
+	}
+}
+
+type criterion interface {
+	match(objectProps) bool
+}
+
+type allOf []criterion
+
+func (c allOf) match(props objectProps) bool {
+	for _, child := range c {
+		if !child.match(props) {
+			return false
+		}
+	}
+	return true
+}
+
+type anyOf []criterion
+
+func (c anyOf) match(props objectProps) bool {
+	for _, child := range c {
+		if child.match(props) {
+			return true
+		}
+	}
+	return false
+}
+
+type relation struct {
+	property string
+	op       string
+	value    string
+}
+
+func (r relation) match(props objectProps) bool {
+	values, _ := props.values(r.property)
+	if r.op == "exists" {
+		present := false
+		for _, value := range values {
+			present = present || value != ""
+		}
+		return present == strings.EqualFold(r.value, "true")
+	}
+	switch r.op {
+	case "!=":
+		return !relation{property: r.property, op: "=", value: r.value}.match(props)
+	case "doesNotContain":
+		return !relation{property: r.property, op: "contains", value: r.value}.match(props)
+	}
+	want := strings.ToLower(r.value)
+	for _, value := range values {
+		value = strings.ToLower(value)
+		switch r.op {
+		case "=":
+			if value == want {
+				return true
+			}
+		case "contains":
+			if strings.Contains(value, want) {
+				return true
+			}
+		case "derivedfrom":
+			if value == want || strings.HasPrefix(value, want+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// titleQueries returns library search queries that together find every
+// object c can match, or nil when c does not narrow the title enough to
+// search for.
+func titleQueries(c criterion) []string {
+	switch c := c.(type) {
+	case relation:
+		if c.property == "dc:title" && (c.op == "contains" || c.op == "=") && strings.TrimSpace(c.value) != "" {
+			return []string{c.value}
+		}
+	case allOf:
+		for _, child := range c {
+			if queries := titleQueries(child); queries != nil {
+				return queries
+			}
+		}
+	case anyOf:
+		var queries []string
+		for _, child := range c {
+			childQueries := titleQueries(child)
+			if childQueries == nil {
+				return nil
+			}
+			queries = append(queries, childQueries...)
+		}
+		return queries
+	}
+	return nil
+}
+
+// parseCriteria parses ContentDirectory search criteria, in which "and"
+// binds tighter than "or". "*" and relational comparisons other than
+// equality are not supported.
+func parseCriteria(input string) (criterion, bool) {
+	tokens, ok := tokenizeCriteria(input)
+	if !ok || len(tokens) == 0 {
+		return nil, false
+	}
+	parser := criteriaParser{tokens: tokens}
+	c, ok := parser.or()
+	if !ok || parser.pos != len(tokens) {
+		return nil, false
+	}
+	return c, true
+}
+
+type criteriaToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeCriteria(input string) ([]criteriaToken, bool) {
+	var tokens []criteriaToken
+	for i := 0; i < len(input); {
+		switch ch := input[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+			i++
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, criteriaToken{text: string(ch)})
+			i++
+		case ch == '"':
+			var value strings.Builder
+			for i++; ; i++ {
+				if i == len(input) {
+					return nil, false
+				}
+				if input[i] == '\\' && i+1 < len(input) {
+					i++
+				} else if input[i] == '"' {
+					break
+				}
+				value.WriteByte(input[i])
+			}
+			tokens = append(tokens, criteriaToken{text: value.String(), quoted: true})
+			i++
+		default:
+			start := i
+			for i < len(input) && !strings.ContainsRune(" \t\r\n()\"", rune(input[i])) {
+				i++
+			}
+			tokens = append(tokens, criteriaToken{text: input[start:i]})
+		}
+	}
+	return tokens, true
+}
+
+type criteriaParser struct {
+	tokens []criteriaToken
+	pos    int
+}
+
+func (p *criteriaParser) keyword(word string) bool {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *criteriaParser) or() (criterion, bool) {
+	var terms anyOf
+	for {
+		term, ok := p.and()
+		if !ok {
+			return nil, false
+		}
+		terms = append(terms, term)
+		if !p.keyword("or") {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], true
+	}
+	return terms, true
+}
+
+func (p *criteriaParser) and() (criterion, bool) {
+	var terms allOf
+	for {
+		term, ok := p.operand()
+		if !ok {
+			return nil, false
+		}
+		terms = append(terms, term)
+		if !p.keyword("and") {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], true
+	}
+	return terms, true
+}
+
+func (p *criteriaParser) operand() (criterion, bool) {
+	if p.keyword("(") {
+		c, ok := p.or()
+		if !ok || !p.keyword(")") {
+			return nil, false
+		}
+		return c, true
+	}
+	if p.pos+3 > len(p.tokens) {
+		return nil, false
+	}
+	property, op, value := p.tokens[p.pos], p.tokens[p.pos+1], p.tokens[p.pos+2]
+	p.pos += 3
+	if property.quoted || op.quoted {
+		return nil, false
+	}
+	r := relation{property: property.text, op: op.text, value: value.text}
+	switch r.op {
+	case "exists":
+		if value.quoted || !strings.EqualFold(r.value, "true") && !strings.EqualFold(r.value, "false") {
+			return nil, false
+		}
+		return r, true
+	case "=", "!=", "contains", "doesNotContain", "derivedfrom":
+		if _, known := (objectProps{}).values(r.property); !known || !value.quoted {
+			return nil, false
+		}
+		return r, true
+	default:
+		return nil, false
+	}
+}
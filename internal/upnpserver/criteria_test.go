@@ -0,0 +1,41 @@
+package upnpserver
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCriteriaPrecedenceAndTitleQueries(t *testing.T) {
+	video := objectProps{title: []string{"Film.mp4"}, class: "object.item.videoItem.movie"}
+	song := objectProps{title: []string{"song.mp3", "Song Title"}, class: "object.item.audioItem.musicTrack", artist: "Band"}
+	for _, test := range []struct {
+		criteria string
+		video    bool
+		song     bool
+		queries  []string
+	}{
+		{`dc:title contains "film"`, true, false, []string{"film"}},
+		{`upnp:class derivedfrom "object.item.audioItem" and dc:title contains "title"`, false, true, []string{"title"}},
+		{`upnp:class = "object.item.videoItem.movie" or dc:title contains "song" and upnp:artist = "band"`, true, true, nil},
+		{`(dc:title contains "film" or dc:title contains "song") and upnp:artist exists true`, false, true, []string{"film", "song"}},
+		{`dc:title doesNotContain "song" and @refID exists false`, true, false, nil},
+		{`dc:title = "song title"`, false, true, []string{"song title"}},
+		{`dc:title contains "quoted \"name\""`, false, false, []string{`quoted "name"`}},
+	} {
+		c, ok := parseCriteria(test.criteria)
+		if !ok {
+			t.Fatalf("parse %q failed", test.criteria)
+		}
+		if c.match(video) != test.video || c.match(song) != test.song {
+			t.Fatalf("%q matches video %v song %v", test.criteria, c.match(video), c.match(song))
+		}
+		if got := titleQueries(c); !slices.Equal(got, test.queries) {
+			t.Fatalf("%q queries = %q, want %q", test.criteria, got, test.queries)
+		}
+	}
+	for _, bad := range []string{"", "*", `dc:title contains`, `dc:title contains "x`, `(dc:title contains "x"`, `dc:date >= "2020"`, `upnp:genre = "rock"`, `dc:title exists "true"`} {
+		if _, ok := parseCriteria(bad); ok {
+			t.Fatalf("parsed %q", bad)
+		}
+	}
+}
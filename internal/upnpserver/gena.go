@@ -0,0 +1,342 @@
+package upnpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// subscriptionTimeout is granted when a control point asks for none or
+	// for longer.
+	subscriptionTimeout = 1800 * time.Second
+	minSubscription     = 60 * time.Second
+	maxSubscriptions    = 64
+)
+
+// subscription is one control point's GENA subscription to a service. Its
+// events are delivered in order by one goroutine; as every event carries
+// the whole state, an event still waiting when the next is ready is
+// dropped.
+type subscription struct {
+	sid       string
+	path      string
+	callbacks []string
+	expires   time.Time
+	seq       uint32
+	pending   chan string
+	done      chan struct{}
+}
+
+func (s *subscription) post(body string) {
+	for {
+		select {
+		case s.pending <- body:
+			return
+		default:
+		}
+		select {
+		case <-s.pending:
+		default:
+		}
+	}
+}
+
+// subscribe handles SUBSCRIBE, renewals included, and UNSUBSCRIBE for the
+// service whose event URL is path.
+func (r *Renderer) subscribe(w http.ResponseWriter, req *http.Request, path string) {
+	sid := req.Header.Get("SID")
+	switch req.Method {
+	case "SUBSCRIBE":
+	case "UNSUBSCRIBE":
+		r.mu.Lock()
+		sub, ok := r.subs[sid]
+		ok = ok && sub.path == path
+		if ok {
+			delete(r.subs, sid)
+			close(sub.done)
+		}
+		r.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+		return
+	default:
+		w.Header().Set("Allow", "SUBSCRIBE, UNSUBSCRIBE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	timeout := subscriptionTimeout
+	if value, ok := strings.CutPrefix(req.Header.Get("TIMEOUT"), "Second-"); ok {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			timeout = min(max(time.Duration(seconds)*time.Second, minSubscription), subscriptionTimeout)
+		}
+	}
+	if sid != "" {
+		if req.Header.Get("CALLBACK") != "" || req.Header.Get("NT") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		sub, ok := r.subs[sid]
+		ok = ok && sub.path == path
+		if ok {
+			sub.expires = time.Now().Add(timeout)
+		}
+		r.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		writeSubscription(w, sid, timeout)
+		return
+	}
+	callbacks := parseCallbacks(req.Header.Get("CALLBACK"))
+	if req.Header.Get("NT") != "upnp:event" || len(callbacks) == 0 {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	var seed [16]byte
+	if _, err := io.ReadFull(r.cfg.Rand, seed[:]); err != nil {
+		http.Error(w, "subscription failed", http.StatusInternalServerError)
+		return
+	}
+	body, err := r.event(req.Context(), path)
+	if err != nil {
+		http.Error(w, "subscription failed", http.StatusInternalServerError)
+		return
+	}
+	sub := &subscription{
+		sid:       "uuid:" + formatUUID(seed[:]),
+		path:      path,
+		callbacks: callbacks,
+		expires:   time.Now().Add(timeout),
+		pending:   make(chan string, 1),
+		done:      make(chan struct{}),
+	}
+	r.mu.Lock()
+	if r.http == nil || len(r.subs) >= maxSubscriptions {
+		r.mu.Unlock()
+		http.Error(w, "too many subscriptions", http.StatusServiceUnavailable)
+		return
+	}
+	r.subs[sub.sid] = sub
+	r.wg.Add(1)
+	r.mu.Unlock()
+	// The initial event carries every evented variable.
+	sub.post(body)
+	go r.deliver(sub)
+	writeSubscription(w, sub.sid, timeout)
+}
+
+func writeSubscription(w http.ResponseWriter, sid string, timeout time.Duration) {
+	w.Header().Set("SID", sid)
+	w.Header().Set("TIMEOUT", "Second-"+strconv.Itoa(int(timeout/time.Second)))
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseCallbacks reads the "<url><url>" list of a CALLBACK header, keeping
+// the HTTP URLs.
+func parseCallbacks(header string) []string {
+	var callbacks []string
+	for _, part := range strings.Split(header, "<") {
+		value, _, ok := strings.Cut(part, ">")
+		if !ok {
+			continue
+		}
+		if u, err := url.Parse(value); err == nil && u.Scheme == "http" && u.Host != "" {
+			callbacks = append(callbacks, value)
+		}
+	}
+	return callbacks
+}
+
+// deliver sends sub its events until it is removed or the renderer closes.
+func (r *Renderer) deliver(sub *subscription) {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-sub.done:
+			return
+		case <-r.done:
+			return
+		case body := <-sub.pending:
+			r.notify(sub, body)
+		}
+	}
+}
+
+// notify tries each callback URL in turn until one takes the event.
+func (r *Renderer) notify(sub *subscription, body string) {
+	seq := strconv.FormatUint(uint64(sub.seq), 10)
+	// SEQ wraps to 1; 0 is for the initial event alone.
+	if sub.seq++; sub.seq == 0 {
+		sub.seq = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-r.done:
+			cancel()
+		case <-sub.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for _, callback := range sub.callbacks {
+		req, err := http.NewRequestWithContext(ctx, "NOTIFY", callback, strings.NewReader(body))
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+		req.Header.Set("NT", "upnp:event")
+		req.Header.Set("NTS", "upnp:propchange")
+		req.Header.Set("SID", sub.sid)
+		req.Header.Set("SEQ", seq)
+		response, err := r.cfg.Client.Do(req)
+		if err != nil {
+			continue
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4<<10))
+		_ = response.Body.Close()
+		if response.StatusCode/100 == 2 {
+			return
+		}
+	}
+}
+
+// watch polls the controller and sends subscribers the services whose
+// evented state changed. It drops expired subscriptions as it goes.
+func (r *Renderer) watch() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+		r.expire(time.Now())
+		ctx, cancel := context.WithTimeout(context.Background(), r.cfg.PollInterval)
+		state, err := r.state(ctx)
+		cancel()
+		if err != nil {
+			continue
+		}
+		r.publish(avTransportEventPath, avTransportEvent(state))
+		r.publish(renderingControlEventPath, renderingControlEvent(state))
+	}
+}
+
+func (r *Renderer) expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for sid, sub := range r.subs {
+		if now.After(sub.expires) {
+			delete(r.subs, sid)
+			close(sub.done)
+		}
+	}
+}
+
+// publish sends body to the subscribers of path if it changed.
+func (r *Renderer) publish(path, body string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.published[path] == body {
+		return
+	}
+	r.published[path] = body
+	for _, sub := range r.subs {
+		if sub.path == path {
+			sub.post(body)
+		}
+	}
+}
+
+// event is the current event body of the service whose event URL is path.
+func (r *Renderer) event(ctx context.Context, path string) (string, error) {
+	if path == rendererConnectionManagerEventPath {
+		return propertySet([]soapArg{
+			{"SourceProtocolInfo", ""},
+			{"SinkProtocolInfo", sinkProtocolInfo()},
+			{"CurrentConnectionIDs", "0"},
+		}), nil
+	}
+	state, err := r.state(ctx)
+	if err != nil {
+		return "", err
+	}
+	if path == avTransportEventPath {
+		return avTransportEvent(state), nil
+	}
+	return renderingControlEvent(state), nil
+}
+
+func avTransportEvent(state rendererState) string {
+	tracks := "0"
+	if state.media.uri != "" {
+		tracks = "1"
+	}
+	return propertySet([]soapArg{{"LastChange", lastChange("urn:schemas-upnp-org:metadata-1-0/AVT/", []soapArg{
+		{"TransportState", state.transport},
+		{"TransportStatus", "OK"},
+		{"TransportPlaySpeed", "1"},
+		{"CurrentPlayMode", "NORMAL"},
+		{"CurrentTransportActions", transportActions[state.transport]},
+		{"NumberOfTracks", tracks},
+		{"CurrentTrack", tracks},
+		{"AVTransportURI", state.media.uri},
+		{"AVTransportURIMetaData", state.media.metadata},
+		{"CurrentTrackURI", state.media.uri},
+		{"CurrentTrackMetaData", state.media.metadata},
+		{"CurrentTrackDuration", state.duration()},
+		{"CurrentMediaDuration", state.duration()},
+	})}})
+}
+
+func renderingControlEvent(state rendererState) string {
+	return propertySet([]soapArg{{"LastChange", lastChange("urn:schemas-upnp-org:metadata-1-0/RCS/", []soapArg{
+		{"Volume", strconv.Itoa(state.playing.Volume)},
+		{"Mute", upnpBool(state.playing.Muted)},
+	})}})
+}
+
+// lastChange builds the LastChange document of the single instance. The
+// RenderingControl variables name their channel.
+func lastChange(namespace string, vars []soapArg) string {
+	var b bytes.Buffer
+	b.WriteString(`<Event xmlns="` + namespace + `"><InstanceID val="0">`)
+	for _, v := range vars {
+		b.WriteString("<" + v.name)
+		if v.name == "Volume" || v.name == "Mute" {
+			b.WriteString(` channel="Master"`)
+		}
+		b.WriteString(` val="`)
+		_ = xml.EscapeText(&b, []byte(v.value))
+		b.WriteString(`"/>`)
+	}
+	b.WriteString(`</InstanceID></Event>`)
+	return b.String()
+}
+
+func propertySet(props []soapArg) string {
+	var b bytes.Buffer
+	b.WriteString(xml.Header + `<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">`)
+	for _, p := range props {
+		b.WriteString("<e:property><" + p.name + ">")
+		_ = xml.EscapeText(&b, []byte(p.value))
+		b.WriteString("</" + p.name + "></e:property>")
+	}
+	b.WriteString(`</e:propertyset>`)
+	return b.String()
+}
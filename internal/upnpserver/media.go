@@ -0,0 +1,99 @@
+package upnpserver
+
+import (
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// mediaTypes maps the library's media extensions to the MIME types listings
+// and responses carry. Files are not sniffed: a folder listing would have to
+// open every file.
+var mediaTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/x-m4v",
+	".mkv":  "video/x-matroska",
+	".avi":  "video/x-msvideo",
+	".mpeg": "video/mpeg",
+	".mpv":  "video/mpeg",
+	".mov":  "video/quicktime",
+	".webm": "video/webm",
+	".dv":   "video/x-dv",
+	".mp3":  "audio/mpeg",
+	".flac": "audio/flac",
+	".wav":  "audio/wav",
+	".m4a":  "audio/mp4",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+}
+
+func mediaTypeFor(name string) string {
+	if mediaType, ok := mediaTypes[strings.ToLower(path.Ext(name))]; ok {
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+// relayTypes are types relay targets commonly play that the library does
+// not hold.
+var relayTypes = []string{
+	"audio/aac",
+	"audio/ogg",
+	"audio/x-flac",
+	"video/mp2t",
+	"application/vnd.apple.mpegurl",
+	"application/x-mpegurl",
+}
+
+// sourceProtocolInfo lists every type the server serves, for
+// GetProtocolInfo.
+func sourceProtocolInfo() string {
+	return protocolInfo(append(slices.Collect(maps.Values(mediaTypes)), "text/srt"))
+}
+
+// sinkProtocolInfo lists the types the renderer accepts. What plays in the
+// end is up to the relay target, so it claims everything that is common.
+func sinkProtocolInfo() string {
+	return protocolInfo(append(slices.Collect(maps.Values(mediaTypes)), relayTypes...))
+}
+
+func protocolInfo(types []string) string {
+	slices.Sort(types)
+	types = slices.Compact(types)
+	infos := make([]string, 0, len(types))
+	for _, mediaType := range types {
+		infos = append(infos, "http-get:*:"+mediaType+":*")
+	}
+	return strings.Join(infos, ",")
+}
+
+// connectionManager answers for the single implicit connection of a device
+// that only moves media over HTTP GET.
+func connectionManager(source, sink, direction string) actionFunc {
+	return func(_ *http.Request, action string, args map[string]string) ([]soapArg, error) {
+		switch action {
+		case "GetProtocolInfo":
+			return []soapArg{{"Source", source}, {"Sink", sink}}, nil
+		case "GetCurrentConnectionIDs":
+			return []soapArg{{"ConnectionIDs", "0"}}, nil
+		case "GetCurrentConnectionInfo":
+			if args["ConnectionID"] != "0" {
+				return nil, errInvalidArgs
+			}
+			return []soapArg{
+				{"RcsID", "-1"},
+				{"AVTransportID", "-1"},
+				{"ProtocolInfo", ""},
+				{"PeerConnectionManager", ""},
+				{"PeerConnectionID", "-1"},
+				{"Direction", direction},
+				{"Status", "OK"},
+			}, nil
+		default:
+			return nil, errInvalidAction
+		}
+	}
+}
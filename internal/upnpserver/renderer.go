@@ -0,0 +1,387 @@
+package upnpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+	"go2tv.app/go2tv/v2/soapcalls"
+)
+
+const (
+	// DefaultPollInterval is how often a renderer checks the controller for
+	// state changes to send subscribers.
+	DefaultPollInterval = 500 * time.Millisecond
+
+	rendererDescriptionPath = "/upnp/renderer/description.xml"
+	// relayRootID is the root ID the web UI gives media loaded from a URL.
+	relayRootID = "remote"
+)
+
+var (
+	ErrControllerRequired = errors.New("upnp renderer controller required")
+	ErrNoRelayTarget      = errors.New("upnp renderer relay device not found")
+)
+
+// RendererController is the part of *controller.Controller a renderer relays
+// to.
+type RendererController interface {
+	Snapshot(context.Context) (controller.Snapshot, error)
+	SessionSnapshot(context.Context, string) (controller.Snapshot, error)
+	QueueAndPlay(context.Context, controller.Mutation, controller.MediaRef) controller.Result
+	Pause(context.Context, controller.Mutation) controller.Result
+	Resume(context.Context, controller.Mutation) controller.Result
+	Stop(context.Context, controller.Mutation) controller.Result
+	Seek(context.Context, controller.SeekRequest) controller.Result
+	SetVolume(context.Context, controller.Mutation, int) controller.Result
+	SetMute(context.Context, controller.Mutation, bool) controller.Result
+}
+
+type RendererConfig struct {
+	Controller RendererController
+	ListenAddr string
+	// Name is the friendly name control points list the renderer under.
+	Name string
+	// UUID identifies the device, as Config.UUID does for a server.
+	UUID    string
+	Version string
+	// Device is the ID or name of the device media is relayed to. Empty
+	// relays to the device selected in the controller.
+	Device         string
+	SSDPAddr       string
+	NotifyInterval time.Duration
+	PollInterval   time.Duration
+	Rand           io.Reader
+	// Client delivers event notifications. It defaults to one that gives
+	// up on a subscriber after five seconds.
+	Client *http.Client
+}
+
+// Renderer is a UPnP AV MediaRenderer that plays nothing itself: media a
+// control point pushes to it is relayed to a device through the controller,
+// in the session for that device. The transport state it reports is that of
+// the session while it plays the relayed media.
+type Renderer struct {
+	cfg RendererConfig
+
+	// actions orders transport actions, which wait on the controller.
+	actions sync.Mutex
+
+	mu       sync.Mutex
+	listener net.Listener
+	http     *http.Server
+	ssdp     *ssdpResponder
+	media    relayedMedia
+	relaying bool
+	// session is the device ID of the controller session media was last
+	// relayed in.
+	session string
+	subs    map[string]*subscription
+	// published holds the last event body sent for each event path.
+	published map[string]string
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// relayedMedia is what SetAVTransportURI last set.
+type relayedMedia struct {
+	uri      string
+	metadata string
+	duration string
+	ref      controller.MediaRef
+}
+
+func NewRenderer(cfg RendererConfig) (*Renderer, error) {
+	if cfg.Controller == nil {
+		return nil, ErrControllerRequired
+	}
+	if cfg.Rand == nil {
+		cfg.Rand = rand.Reader
+	}
+	if cfg.Name == "" {
+		cfg.Name = "go2tv"
+	}
+	if cfg.UUID == "" {
+		var seed [16]byte
+		if _, err := io.ReadFull(cfg.Rand, seed[:]); err != nil {
+			return nil, fmt.Errorf("upnp device uuid: %w", err)
+		}
+		cfg.UUID = formatUUID(seed[:])
+	}
+	if cfg.SSDPAddr == "" {
+		cfg.SSDPAddr = DefaultSSDPAddr
+	}
+	if cfg.NotifyInterval == 0 {
+		cfg.NotifyInterval = DefaultNotifyInterval
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &Renderer{cfg: cfg, subs: make(map[string]*subscription), published: make(map[string]string)}, nil
+}
+
+// Start listens for HTTP and SSDP, announces the device, and starts
+// watching the controller for events.
+func (r *Renderer) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.listener != nil {
+		return ErrAlreadyStarted
+	}
+	listener, err := net.Listen("tcp", r.cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("listen upnp renderer: %w", err)
+	}
+	responder, err := newSSDPResponder(r.cfg.SSDPAddr, ssdpDevice{
+		uuid:        r.cfg.UUID,
+		version:     r.cfg.Version,
+		interval:    r.cfg.NotifyInterval,
+		types:       []string{mediaRendererType, avTransportType, renderingControlType, connectionManagerType},
+		description: rendererDescriptionPath,
+	}, listener.Addr().(*net.TCPAddr))
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+	r.listener = listener
+	r.ssdp = responder
+	r.done = make(chan struct{})
+	r.http = &http.Server{
+		Handler:           r,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      15 * time.Second,
+		IdleTimeout:       30 * time.Second,
+	}
+	go func() { _ = r.http.Serve(listener) }()
+	responder.start()
+	r.wg.Add(1)
+	go r.watch()
+	return nil
+}
+
+// Addr returns the HTTP listener address.
+func (r *Renderer) Addr() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.listener == nil {
+		return ""
+	}
+	return r.listener.Addr().String()
+}
+
+// SSDPAddr returns the address discovery requests are read on.
+func (r *Renderer) SSDPAddr() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ssdp == nil {
+		return ""
+	}
+	return r.ssdp.conn.LocalAddr().String()
+}
+
+// Close announces the device is leaving, ends the subscriptions, and stops
+// serving. Media already relayed keeps playing.
+func (r *Renderer) Close() error {
+	r.mu.Lock()
+	responder, httpServer := r.ssdp, r.http
+	r.ssdp, r.http, r.listener = nil, nil, nil
+	if httpServer != nil {
+		close(r.done)
+	}
+	r.mu.Unlock()
+	if httpServer == nil {
+		return nil
+	}
+	err := responder.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if shutdownErr := httpServer.Shutdown(ctx); shutdownErr != nil {
+		_ = httpServer.Close()
+	}
+	r.wg.Wait()
+	return err
+}
+
+func (r *Renderer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case rendererDescriptionPath:
+		serveXML(w, req, r.description())
+	case avTransportSCPDPath:
+		serveXML(w, req, []byte(avTransportSCPD))
+	case renderingControlSCPDPath:
+		serveXML(w, req, []byte(renderingControlSCPD))
+	case rendererConnectionManagerSCPDPath:
+		serveXML(w, req, []byte(connectionManagerSCPD))
+	case avTransportControlPath:
+		control(w, req, avTransportType, r.avTransport)
+	case renderingControlControlPath:
+		control(w, req, renderingControlType, r.renderingControl)
+	case rendererConnectionManagerControlPath:
+		control(w, req, connectionManagerType, connectionManager("", sinkProtocolInfo(), "Input"))
+	case avTransportEventPath, renderingControlEventPath, rendererConnectionManagerEventPath:
+		r.subscribe(w, req, req.URL.Path)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// rendererState is the renderer as control points see it.
+type rendererState struct {
+	media     relayedMedia
+	transport string
+	// snapshot is the default session, which picks the relay target.
+	snapshot controller.Snapshot
+	// playing is the session the media is relayed in, named by session,
+	// or the default session with a blank session while nothing is.
+	playing controller.Snapshot
+	session string
+}
+
+// state reads the controller. Once the relay session plays something other
+// than the relayed media, or is gone, the renderer reports it stopped.
+func (r *Renderer) state(ctx context.Context) (rendererState, error) {
+	snapshot, err := r.cfg.Controller.Snapshot(ctx)
+	if err != nil {
+		return rendererState{}, err
+	}
+	r.mu.Lock()
+	session, relaying := r.session, r.relaying
+	r.mu.Unlock()
+	playing := snapshot
+	if relaying {
+		playing, err = r.cfg.Controller.SessionSnapshot(ctx, session)
+		switch {
+		case errors.Is(err, controller.ErrNotFound):
+			relaying = false
+		case err != nil:
+			return rendererState{}, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := rendererState{media: r.media, snapshot: snapshot, playing: snapshot}
+	current := r.relaying && r.session == session
+	if current && (!relaying || !playing.HasSession || playing.ActiveMediaID != r.media.ref.ID) {
+		r.relaying, current = false, false
+	}
+	if current {
+		state.playing, state.session = playing, session
+	}
+	switch {
+	case r.media.uri == "":
+		state.transport = "NO_MEDIA_PRESENT"
+	case !current:
+		state.transport = "STOPPED"
+	default:
+		switch playing.PlaybackState {
+		case controller.PlaybackStatePlaying:
+			state.transport = "PLAYING"
+		case controller.PlaybackStatePaused:
+			state.transport = "PAUSED_PLAYBACK"
+		case controller.PlaybackStateLoading, controller.PlaybackStateBuffering:
+			state.transport = "TRANSITIONING"
+		default:
+			state.transport = "STOPPED"
+		}
+	}
+	return state, nil
+}
+
+// relay starts the relayed media in the session for the target device,
+// which leaves whatever the default session plays elsewhere alone.
+func (r *Renderer) relay(ctx context.Context, media relayedMedia, snapshot controller.Snapshot) error {
+	device, err := r.target(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := r.cfg.Controller.QueueAndPlay(ctx, controller.Mutation{Session: device.ID}, media.ref).Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.relaying, r.session = r.media.uri == media.uri, device.ID
+	r.mu.Unlock()
+	return nil
+}
+
+// target finds the device to relay to. The renderer is discovered like any
+// other device, and is never its own target.
+func (r *Renderer) target(snapshot controller.Snapshot) (playback.Device, error) {
+	for _, device := range snapshot.Devices {
+		if r.isSelf(device) {
+			continue
+		}
+		if r.cfg.Device == "" && device.ID == snapshot.SelectedDeviceID || r.cfg.Device != "" && (device.ID == r.cfg.Device || device.Name == r.cfg.Device) {
+			return device, nil
+		}
+	}
+	return playback.Device{}, ErrNoRelayTarget
+}
+
+func (r *Renderer) isSelf(device playback.Device) bool {
+	location, err := url.Parse(device.Endpoint)
+	if err != nil || location.Path != rendererDescriptionPath {
+		return false
+	}
+	_, port, _ := net.SplitHostPort(r.Addr())
+	return location.Port() == port
+}
+
+// relayRef describes uri to the controller, taking what it can from the
+// DIDL-Lite metadata that came with it.
+func relayRef(uri, metadata string) (relayedMedia, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return relayedMedia{}, errResourceNotFound
+	}
+	var item soapcalls.DIDLItem
+	if strings.TrimSpace(metadata) != "" {
+		// Metadata only improves the names; a control point that sends a
+		// broken document still gets its URI played.
+		if items, err := soapcalls.ParseDIDLLite([]byte(metadata)); err == nil && len(items) > 0 {
+			item = items[0]
+		}
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = u.Host
+	}
+	mediaType := item.MediaType
+	if mediaType == "" || mediaType == "*" {
+		mediaType = mime.TypeByExtension(path.Ext(name))
+	}
+	kind := mediamodel.MediaKind(strings.Split(mediaType, "/")[0])
+	if kind != mediamodel.MediaKindVideo && kind != mediamodel.MediaKindAudio && kind != mediamodel.MediaKindImage {
+		kind = mediamodel.KindForPath(name)
+	}
+	if kind == mediamodel.MediaKindUnknown {
+		// As in the web UI, audio is assumed for a URL with nothing to go
+		// on. Phones push video with its type set.
+		kind = mediamodel.MediaKindAudio
+	}
+	if _, _, err := mime.ParseMediaType(mediaType); err != nil {
+		mediaType = ""
+	}
+	ref := controller.MediaRef{RootID: relayRootID, ID: uri, Name: name, Kind: kind, MIMEType: mediaType, URL: uri, Title: item.Title}
+	if err := ref.Validate(); err != nil {
+		return relayedMedia{}, errIllegalMIMEType
+	}
+	return relayedMedia{uri: uri, metadata: metadata, duration: item.Duration, ref: ref}, nil
+}
@@ -0,0 +1,287 @@
+package upnpserver
+
+import (
+	"context"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+	"go2tv.app/go2tv/v2/soapcalls"
+)
+
+// fakeRelay plays whatever it is handed at once. A mutation for a device
+// other than the default session's goes to an additional session.
+type fakeRelay struct {
+	mu       sync.Mutex
+	snapshot controller.Snapshot
+	sessions map[string]*controller.Snapshot
+	played   []controller.MediaRef
+	calls    []string
+}
+
+func (f *fakeRelay) Snapshot(context.Context) (controller.Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.snapshot, nil
+}
+
+func (f *fakeRelay) SessionSnapshot(_ context.Context, id string) (controller.Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if session, ok := f.sessions[id]; ok {
+		return *session, nil
+	}
+	if id != f.snapshot.SelectedDeviceID {
+		return controller.Snapshot{}, controller.ErrNotFound
+	}
+	return f.snapshot, nil
+}
+
+func (f *fakeRelay) record(call string, mutation controller.Mutation, update func(*controller.Snapshot)) controller.Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	target := &f.snapshot
+	if id := mutation.Session; id != "" {
+		call += " " + id
+		if id != f.snapshot.SelectedDeviceID {
+			if f.sessions[id] == nil {
+				if f.sessions == nil {
+					f.sessions = make(map[string]*controller.Snapshot)
+				}
+				f.sessions[id] = &controller.Snapshot{SelectedDeviceID: id}
+			}
+			target = f.sessions[id]
+		}
+	}
+	f.calls = append(f.calls, call)
+	update(target)
+	target.Revision++
+	return controller.Result{Revision: target.Revision}
+}
+
+// log returns the calls made and the media played so far.
+func (f *fakeRelay) log() (string, []controller.MediaRef) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return strings.Join(f.calls, ","), append([]controller.MediaRef(nil), f.played...)
+}
+
+func (f *fakeRelay) QueueAndPlay(_ context.Context, mutation controller.Mutation, media controller.MediaRef) controller.Result {
+	return f.record("play", mutation, func(s *controller.Snapshot) {
+		f.played = append(f.played, media)
+		s.HasSession, s.ActiveMediaName, s.ActiveMediaID, s.PlaybackState, s.Duration = true, media.Name, media.ID, controller.PlaybackStatePlaying, 90
+	})
+}
+
+func (f *fakeRelay) Pause(_ context.Context, mutation controller.Mutation) controller.Result {
+	return f.record("pause", mutation, func(s *controller.Snapshot) { s.PlaybackState = controller.PlaybackStatePaused })
+}
+
+func (f *fakeRelay) Resume(_ context.Context, mutation controller.Mutation) controller.Result {
+	return f.record("resume", mutation, func(s *controller.Snapshot) { s.PlaybackState = controller.PlaybackStatePlaying })
+}
+
+func (f *fakeRelay) Stop(_ context.Context, mutation controller.Mutation) controller.Result {
+	return f.record("stop", mutation, func(s *controller.Snapshot) { s.HasSession, s.PlaybackState = false, controller.PlaybackStateStopped })
+}
+
+func (f *fakeRelay) Seek(_ context.Context, request controller.SeekRequest) controller.Result {
+	return f.record("seek", request.Mutation, func(s *controller.Snapshot) { s.Position = request.Seconds })
+}
+
+func (f *fakeRelay) SetVolume(_ context.Context, mutation controller.Mutation, volume int) controller.Result {
+	return f.record("volume", mutation, func(s *controller.Snapshot) { s.Volume = volume })
+}
+
+func (f *fakeRelay) SetMute(_ context.Context, mutation controller.Mutation, muted bool) controller.Result {
+	return f.record("mute", mutation, func(s *controller.Snapshot) { s.Muted = muted })
+}
+
+func startTestRenderer(t *testing.T, relay *fakeRelay, device string) *Renderer {
+	t.Helper()
+	renderer, err := NewRenderer(RendererConfig{
+		Controller:   relay,
+		ListenAddr:   "127.0.0.1:0",
+		Name:         "Test Renderer",
+		Device:       device,
+		SSDPAddr:     "127.0.0.1:0",
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renderer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = renderer.Close() })
+	return renderer
+}
+
+func TestRendererRelaysPushedMediaToConfiguredDevice(t *testing.T) {
+	relay := &fakeRelay{snapshot: controller.Snapshot{SelectedDeviceID: "other", Volume: 30}}
+	renderer := startTestRenderer(t, relay, "Living Room")
+	base := "http://" + renderer.Addr()
+	relay.record("discover", controller.Mutation{}, func(s *controller.Snapshot) {
+		s.Devices = []playback.Device{
+			// Discovery finds the renderer itself under the same name.
+			{ID: "self", Name: "Living Room", Protocol: "dlna", Endpoint: base + rendererDescriptionPath},
+			{ID: "other", Name: "Kitchen", Protocol: "chromecast"},
+			{ID: "tv", Name: "Living Room", Protocol: "chromecast"},
+		}
+	})
+	transport := func(action string, args map[string]string) map[string]string {
+		t.Helper()
+		if args == nil {
+			args = map[string]string{}
+		}
+		args["InstanceID"] = "0"
+		return soap(t, base+avTransportControlPath, avTransportType, action, args)
+	}
+
+	if state := transport("GetTransportInfo", nil)["CurrentTransportState"]; state != "NO_MEDIA_PRESENT" {
+		t.Fatalf("initial state = %q", state)
+	}
+	if code := soapFault(t, base+avTransportControlPath, avTransportType, "Play", map[string]string{"InstanceID": "0", "Speed": "1"}); code != "702" {
+		t.Fatalf("play without media fault = %q", code)
+	}
+	metadata, err := soapcalls.BuildDIDLLite(nil, []soapcalls.DIDLItem{{ID: "1", ParentID: "0", Title: "Holiday", MediaType: "video/mp4", URL: "http://phone:9000/v/12", Duration: "0:01:30"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport("SetAVTransportURI", map[string]string{"CurrentURI": "http://phone:9000/v/12", "CurrentURIMetaData": string(metadata)})
+	if info := transport("GetMediaInfo", nil); info["CurrentURI"] != "http://phone:9000/v/12" || info["MediaDuration"] != "0:01:30" || info["NrTracks"] != "1" {
+		t.Fatalf("media info = %v", info)
+	}
+	if state := transport("GetTransportInfo", nil)["CurrentTransportState"]; state != "STOPPED" {
+		t.Fatalf("state after set = %q", state)
+	}
+
+	transport("Play", map[string]string{"Speed": "1"})
+	calls, played := relay.log()
+	if calls != "discover,play tv" || len(played) != 1 {
+		t.Fatalf("calls = %v", calls)
+	}
+	if played := played[0]; played.URL != "http://phone:9000/v/12" || played.Title != "Holiday" || played.Kind != mediamodel.MediaKindVideo || played.MIMEType != "video/mp4" {
+		t.Fatalf("played = %+v", played)
+	}
+	if state := transport("GetTransportInfo", nil)["CurrentTransportState"]; state != "PLAYING" {
+		t.Fatalf("state after play = %q", state)
+	}
+	transport("Seek", map[string]string{"Unit": "REL_TIME", "Target": "0:00:42"})
+	transport("Pause", nil)
+	if position := transport("GetPositionInfo", nil); position["RelTime"] != "00:00:42" || position["TrackDuration"] != "00:01:30" {
+		t.Fatalf("position = %v", position)
+	}
+	transport("Play", map[string]string{"Speed": "1"})
+	if calls, _ := relay.log(); !strings.HasSuffix(calls, ",play tv,seek tv,pause tv,resume tv") {
+		t.Fatalf("calls = %v", calls)
+	}
+
+	rendering := base + renderingControlControlPath
+	soap(t, rendering, renderingControlType, "SetVolume", map[string]string{"InstanceID": "0", "Channel": "Master", "DesiredVolume": "55"})
+	if volume := soap(t, rendering, renderingControlType, "GetVolume", map[string]string{"InstanceID": "0", "Channel": "Master"}); volume["CurrentVolume"] != "55" {
+		t.Fatalf("volume = %v", volume)
+	}
+	if code := soapFault(t, rendering, renderingControlType, "GetVolume", map[string]string{"InstanceID": "1", "Channel": "Master"}); code != "718" {
+		t.Fatalf("instance fault = %q", code)
+	}
+
+	// The relay left the default session alone.
+	if snapshot, _ := relay.Snapshot(context.Background()); snapshot.SelectedDeviceID != "other" || snapshot.HasSession || snapshot.Volume != 30 {
+		t.Fatalf("default session = %+v", snapshot)
+	}
+
+	// Someone else playing in the relay session stops the renderer, even
+	// under the same name.
+	relay.record("web", controller.Mutation{Session: "tv"}, func(s *controller.Snapshot) { s.ActiveMediaID = "http://laptop/v/12" })
+	if state := transport("GetTransportInfo", nil)["CurrentTransportState"]; state != "STOPPED" {
+		t.Fatalf("state after takeover = %q", state)
+	}
+	if code := soapFault(t, base+avTransportControlPath, avTransportType, "SetAVTransportURI", map[string]string{"InstanceID": "0", "CurrentURI": "file:///etc/passwd"}); code != "716" {
+		t.Fatalf("file URI fault = %q", code)
+	}
+}
+
+func TestRendererEventsLastChangeToSubscribers(t *testing.T) {
+	events := make(chan *http.Request, 8)
+	bodies := make(chan string, 8)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		events <- r
+		bodies <- string(body)
+	}))
+	defer callback.Close()
+	relay := &fakeRelay{snapshot: controller.Snapshot{SelectedDeviceID: "tv", Devices: []playback.Device{{ID: "tv", Name: "TV"}}}}
+	renderer := startTestRenderer(t, relay, "")
+	base := "http://" + renderer.Addr()
+	next := func() (*http.Request, soapcalls.EventNotify) {
+		t.Helper()
+		select {
+		case request := <-events:
+			// As go2tv's own callback handler does, LastChange is
+			// unescaped in place.
+			notify, err := soapcalls.ParseEventNotify(html.UnescapeString(<-bodies))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return request, notify
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+			return nil, soapcalls.EventNotify{}
+		}
+	}
+
+	request, _ := http.NewRequest("SUBSCRIBE", base+avTransportEventPath, nil)
+	request.Header.Set("CALLBACK", "<"+callback.URL+"/event>")
+	request.Header.Set("NT", "upnp:event")
+	request.Header.Set("TIMEOUT", "Second-300")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	sid := response.Header.Get("SID")
+	if response.StatusCode != http.StatusOK || !strings.HasPrefix(sid, "uuid:") || response.Header.Get("TIMEOUT") != "Second-300" {
+		t.Fatalf("subscribe = %d %v", response.StatusCode, response.Header)
+	}
+	initial, notify := next()
+	if initial.Header.Get("SID") != sid || initial.Header.Get("SEQ") != "0" || notify.TransportState != "NO_MEDIA_PRESENT" {
+		t.Fatalf("initial event %v: %+v", initial.Header, notify)
+	}
+
+	soap(t, base+avTransportControlPath, avTransportType, "SetAVTransportURI", map[string]string{"InstanceID": "0", "CurrentURI": "http://phone/song.mp3"})
+	soap(t, base+avTransportControlPath, avTransportType, "Play", map[string]string{"InstanceID": "0", "Speed": "1"})
+	for notify.TransportState != "PLAYING" {
+		request, notify = next()
+	}
+	if _, played := relay.log(); request.Header.Get("SEQ") == "0" || notify.CurrentTransportActions != "Pause,Stop,Seek" || played[0].Kind != mediamodel.MediaKindAudio {
+		t.Fatalf("playing event %v: %+v", request.Header, notify)
+	}
+
+	request, _ = http.NewRequest("SUBSCRIBE", base+avTransportEventPath, nil)
+	request.Header.Set("SID", sid)
+	if response, err = http.DefaultClient.Do(request); err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("renew = %v %v", response, err)
+	}
+	response.Body.Close()
+	for i, want := range []int{http.StatusOK, http.StatusPreconditionFailed} {
+		request, _ = http.NewRequest("UNSUBSCRIBE", base+avTransportEventPath, nil)
+		request.Header.Set("SID", sid)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != want {
+			t.Fatalf("unsubscribe %d = %d", i, response.StatusCode)
+		}
+	}
+}
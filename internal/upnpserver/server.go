@@ -0,0 +1,357 @@
+// Package upnpserver publishes library roots as a UPnP AV MediaServer, so
+// renderers and control points on the network can browse and play them
+// without the web UI. It answers SSDP discovery, serves the device and
+// service descriptions, the ContentDirectory and ConnectionManager actions,
+// and the media, sidecar subtitle, and artwork files the listings point at.
+//
+// The package also provides Renderer, a MediaRenderer that control points
+// push media to and that relays it to a device through the controller.
+package upnpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/utils"
+)
+
+const (
+	// DefaultSSDPAddr is the SSDP multicast group.
+	DefaultSSDPAddr = "239.255.255.250:1900"
+	// DefaultNotifyInterval is how often the device is announced. It is half
+	// the max-age the announcements carry.
+	DefaultNotifyInterval = 15 * time.Minute
+
+	descriptionPath = "/upnp/description.xml"
+	mediaPrefix     = "/upnp/media/"
+	subtitlePrefix  = "/upnp/subtitle/"
+	artworkPrefix   = "/upnp/artwork/"
+)
+
+var (
+	ErrLibraryRequired = errors.New("upnp server library required")
+	ErrAlreadyStarted  = errors.New("upnp server already started")
+)
+
+// ArtworkFunc returns JPEG artwork for a library media entry.
+type ArtworkFunc func(ctx context.Context, rootID, entryID string) ([]byte, error)
+
+type Config struct {
+	Library    *library.Library
+	ListenAddr string
+	// Name is the friendly name control points list the server under.
+	Name string
+	// UUID identifies the device. Keep it stable across restarts so control
+	// points recognize the server; a random one is used when empty.
+	UUID    string
+	Version string
+	// Artwork supplies album art for audio and video items. Nil leaves
+	// items without it.
+	Artwork ArtworkFunc
+	// SSDPAddr is where discovery requests are read, DefaultSSDPAddr when
+	// empty. A unicast address answers searches sent to it directly and
+	// makes no announcements.
+	SSDPAddr       string
+	NotifyInterval time.Duration
+	Rand           io.Reader
+}
+
+// Server owns one HTTP listener and one SSDP socket.
+type Server struct {
+	cfg Config
+
+	mu       sync.Mutex
+	listener net.Listener
+	http     *http.Server
+	ssdp     *ssdpResponder
+
+	snapshots snapshotCache
+}
+
+func New(cfg Config) (*Server, error) {
+	if cfg.Library == nil {
+		return nil, ErrLibraryRequired
+	}
+	if cfg.Rand == nil {
+		cfg.Rand = rand.Reader
+	}
+	if cfg.Name == "" {
+		cfg.Name = "go2tv"
+	}
+	if cfg.UUID == "" {
+		var seed [16]byte
+		if _, err := io.ReadFull(cfg.Rand, seed[:]); err != nil {
+			return nil, fmt.Errorf("upnp device uuid: %w", err)
+		}
+		cfg.UUID = formatUUID(seed[:])
+	}
+	if cfg.SSDPAddr == "" {
+		cfg.SSDPAddr = DefaultSSDPAddr
+	}
+	if cfg.NotifyInterval == 0 {
+		cfg.NotifyInterval = DefaultNotifyInterval
+	}
+	return &Server{cfg: cfg}, nil
+}
+
+// NameUUID derives a stable device UUID from name, in the manner of a
+// version 5 UUID.
+func NameUUID(name string) string {
+	sum := sha1.Sum([]byte("go2tv-upnp-media-server:" + name))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return formatUUID(sum[:16])
+}
+
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Start listens for HTTP and SSDP and announces the device.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return ErrAlreadyStarted
+	}
+	listener, err := net.Listen("tcp", s.cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("listen upnp server: %w", err)
+	}
+	responder, err := newSSDPResponder(s.cfg.SSDPAddr, ssdpDevice{
+		uuid:        s.cfg.UUID,
+		version:     s.cfg.Version,
+		interval:    s.cfg.NotifyInterval,
+		types:       []string{mediaServerType, contentDirectoryType, connectionManagerType},
+		description: descriptionPath,
+	}, listener.Addr().(*net.TCPAddr))
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+	s.listener = listener
+	s.ssdp = responder
+	s.http = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		IdleTimeout:       30 * time.Second,
+		// Streaming responses deliberately have no WriteTimeout.
+	}
+	go func() { _ = s.http.Serve(listener) }()
+	responder.start()
+	return nil
+}
+
+// Addr returns the HTTP listener address.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// SSDPAddr returns the address discovery requests are read on.
+func (s *Server) SSDPAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ssdp == nil {
+		return ""
+	}
+	return s.ssdp.conn.LocalAddr().String()
+}
+
+// Close announces the device is leaving and stops serving.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	responder, httpServer := s.ssdp, s.http
+	s.ssdp, s.http, s.listener = nil, nil, nil
+	s.mu.Unlock()
+	if httpServer == nil {
+		return nil
+	}
+	err := responder.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if shutdownErr := httpServer.Shutdown(ctx); shutdownErr != nil {
+		_ = httpServer.Close()
+	}
+	return err
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case path == descriptionPath:
+		serveXML(w, r, s.description())
+	case path == contentDirectorySCPDPath:
+		serveXML(w, r, []byte(contentDirectorySCPD))
+	case path == connectionManagerSCPDPath:
+		serveXML(w, r, []byte(connectionManagerSCPD))
+	case path == contentDirectoryControlPath:
+		control(w, r, contentDirectoryType, s.contentDirectory)
+	case path == connectionManagerControlPath:
+		control(w, r, connectionManagerType, connectionManager(sourceProtocolInfo(), "", "Output"))
+	case path == contentDirectoryEventPath, path == connectionManagerEventPath:
+		s.subscribe(w, r)
+	case strings.HasPrefix(path, mediaPrefix):
+		s.serveMedia(w, r, strings.TrimPrefix(path, mediaPrefix))
+	case strings.HasPrefix(path, subtitlePrefix):
+		s.serveSubtitle(w, r, strings.TrimPrefix(path, subtitlePrefix))
+	case strings.HasPrefix(path, artworkPrefix):
+		s.serveArtwork(w, r, strings.TrimPrefix(path, artworkPrefix))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func serveXML(w http.ResponseWriter, r *http.Request, body []byte) {
+	if !allowRead(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// subscribe accepts event subscriptions so control points that insist on
+// them carry on. No events follow: the system update ID never changes.
+func (s *Server) subscribe(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "SUBSCRIBE":
+		sid := r.Header.Get("SID")
+		if sid == "" {
+			var seed [16]byte
+			if _, err := io.ReadFull(s.cfg.Rand, seed[:]); err != nil {
+				http.Error(w, "subscription failed", http.StatusInternalServerError)
+				return
+			}
+			sid = "uuid:" + formatUUID(seed[:])
+		}
+		w.Header().Set("SID", sid)
+		w.Header().Set("TIMEOUT", "Second-1800")
+	case "UNSUBSCRIBE":
+	default:
+		w.Header().Set("Allow", "SUBSCRIBE, UNSUBSCRIBE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// serveMedia serves the library file a "<root>/<entry><ext>" path names.
+// The signed IDs confine it to the library roots.
+func (s *Server) serveMedia(w http.ResponseWriter, r *http.Request, rest string) {
+	if !allowRead(w, r) {
+		return
+	}
+	rootID, entryID, ok := routeIDs(rest)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	file, meta, err := s.cfg.Library.OpenMedia(rootID, entryID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	mediaType := mediaTypeFor(meta.Name)
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("transferMode.dlna.org", "Streaming")
+	w.Header().Set("realTimeInfo.dlna.org", "DLNA.ORG_TLAG=*")
+	if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
+		if contentFeatures, err := utils.BuildContentFeatures(mediaType, "01", false); err == nil {
+			w.Header().Set("contentFeatures.dlna.org", contentFeatures)
+		}
+	}
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+func (s *Server) serveSubtitle(w http.ResponseWriter, r *http.Request, rest string) {
+	if !allowRead(w, r) {
+		return
+	}
+	rootID, entryID, ok := routeIDs(rest)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	file, _, err := s.cfg.Library.OpenSidecar(rootID, entryID, ".srt")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/srt")
+	w.Header().Set("transferMode.dlna.org", "Interactive")
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+func (s *Server) serveArtwork(w http.ResponseWriter, r *http.Request, rest string) {
+	if !allowRead(w, r) {
+		return
+	}
+	rootID, entryID, ok := routeIDs(rest)
+	if !ok || s.cfg.Artwork == nil {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := s.cfg.Artwork(r.Context(), rootID, entryID)
+	if err != nil || len(data) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("transferMode.dlna.org", "Interactive")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+func allowRead(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// routeIDs splits "<root>/<entry><ext>". Neither ID holds a slash or a dot.
+func routeIDs(rest string) (rootID, entryID string, ok bool) {
+	rootID, entryID, ok = strings.Cut(rest, "/")
+	if !ok || rootID == "" || strings.Contains(entryID, "/") {
+		return "", "", false
+	}
+	entryID, _, _ = strings.Cut(entryID, ".")
+	return rootID, entryID, entryID != ""
+}
+
+// baseURL is the server at the local address the connection carrying r was
+// accepted on, falling back to the listener address. The Host header is the
+// client's to choose, so it is not used.
+func (s *Server) baseURL(r *http.Request) string {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		return "http://" + addr.String()
+	}
+	return "http://" + s.Addr()
+}
@@ -0,0 +1,388 @@
+package upnpserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/library"
+)
+
+type didlResult struct {
+	Containers []struct {
+		ID         string `xml:"id,attr"`
+		ParentID   string `xml:"parentID,attr"`
+		ChildCount string `xml:"childCount,attr"`
+		Title      string `xml:"title"`
+	} `xml:"container"`
+	Items []struct {
+		ID       string `xml:"id,attr"`
+		ParentID string `xml:"parentID,attr"`
+		Title    string `xml:"title"`
+		Class    string `xml:"class"`
+		ArtURI   string `xml:"albumArtURI"`
+		Res      []struct {
+			ProtocolInfo string `xml:"protocolInfo,attr"`
+			Size         string `xml:"size,attr"`
+			URL          string `xml:",chardata"`
+		} `xml:"res"`
+	} `xml:"item"`
+}
+
+func startTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "Movies"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range map[string]string{
+		"Movies/film.mp4": "film-bytes",
+		"Movies/film.srt": "1\n00:00:01,000 --> 00:00:02,000\nHi\n",
+		"song.mp3":        "song",
+		"photo.jpg":       "jpeg",
+		"notes.txt":       "text",
+	} {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lib, err := library.Open(library.Config{Roots: []string{root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lib.Close() })
+	server, err := New(Config{
+		Library:    lib,
+		ListenAddr: "127.0.0.1:0",
+		Name:       "Test Library",
+		UUID:       NameUUID("test"),
+		SSDPAddr:   "127.0.0.1:0",
+		Artwork: func(_ context.Context, rootID, entryID string) ([]byte, error) {
+			return []byte("thumbnail"), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	return server, lib.Roots()[0].ID
+}
+
+func TestSearchRequestFindsDescription(t *testing.T) {
+	server, _ := startTestServer(t)
+	conn, err := net.Dial("udp4", server.SSDPAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	search := "M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: ssdp:all\r\n\r\n"
+	if _, err := io.WriteString(conn, search); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	targets := make(map[string]string)
+	var location string
+	buf := make([]byte, 2048)
+	for len(targets) < 5 {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("read responses %v: %v", targets, err)
+		}
+		response, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets[response.Header.Get("ST")] = response.Header.Get("USN")
+		location = response.Header.Get("LOCATION")
+	}
+	uuid := NameUUID("test")
+	if targets[mediaServerType] != "uuid:"+uuid+"::"+mediaServerType || targets["uuid:"+uuid] != "uuid:"+uuid {
+		t.Fatalf("targets = %v", targets)
+	}
+	if location != "http://"+server.Addr()+descriptionPath {
+		t.Fatalf("location = %q, server %q", location, server.Addr())
+	}
+
+	response, err := http.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var description deviceRoot
+	if err := xml.NewDecoder(response.Body).Decode(&description); err != nil {
+		t.Fatal(err)
+	}
+	if description.Device.FriendlyName != "Test Library" || description.Device.UDN != "uuid:"+uuid || len(description.Device.Services) != 2 {
+		t.Fatalf("description = %+v", description.Device)
+	}
+
+	if _, err := io.WriteString(conn, strings.Replace(search, "ssdp:all", "urn:schemas-upnp-org:device:MediaRenderer:1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if n, err := conn.Read(buf); err == nil {
+		t.Fatalf("answered a search for another device: %s", buf[:n])
+	}
+}
+
+func TestBrowseListsRootsFoldersAndPlayableItems(t *testing.T) {
+	server, rootID := startTestServer(t)
+	base := "http://" + server.Addr()
+
+	roots := browse(t, base, "0", "BrowseDirectChildren", 0, 0)
+	if len(roots.didl.Containers) != 1 || roots.didl.Containers[0].ID != rootID || roots.total != "1" {
+		t.Fatalf("roots = %+v", roots)
+	}
+	top := browse(t, base, rootID, "BrowseDirectChildren", 0, 0)
+	if top.total != "3" || len(top.didl.Containers) != 1 || top.didl.Containers[0].Title != "Movies" || len(top.didl.Items) != 2 {
+		t.Fatalf("root folder = %+v", top)
+	}
+	if top.didl.Items[0].Title != "photo.jpg" || top.didl.Items[0].ArtURI != "" || top.didl.Items[1].Class != "object.item.audioItem.musicTrack" || top.didl.Items[1].ArtURI == "" {
+		t.Fatalf("root items = %+v", top.didl.Items)
+	}
+	second := browse(t, base, rootID, "BrowseDirectChildren", 1, 1)
+	if second.total != "3" || second.returned != "1" || len(second.didl.Items) != 1 || second.didl.Items[0].Title != "photo.jpg" {
+		t.Fatalf("second page = %+v", second)
+	}
+
+	moviesID := top.didl.Containers[0].ID
+	movies := browse(t, base, moviesID, "BrowseDirectChildren", 0, 0)
+	if len(movies.didl.Items) != 1 || movies.didl.Items[0].ParentID != moviesID || len(movies.didl.Items[0].Res) != 2 {
+		t.Fatalf("movies = %+v", movies)
+	}
+	film := movies.didl.Items[0]
+	if film.Res[0].Size != "10" || !strings.HasPrefix(film.Res[0].ProtocolInfo, "http-get:*:video/mp4:") || film.Res[1].ProtocolInfo != "http-get:*:text/srt:*" {
+		t.Fatalf("film resources = %+v", film.Res)
+	}
+	metadata := browse(t, base, film.ID, "BrowseMetadata", 0, 0)
+	if len(metadata.didl.Items) != 1 || metadata.didl.Items[0].ParentID != moviesID {
+		t.Fatalf("film metadata = %+v", metadata)
+	}
+	folder := browse(t, base, moviesID, "BrowseMetadata", 0, 0)
+	if len(folder.didl.Containers) != 1 || folder.didl.Containers[0].ParentID != rootID || folder.didl.Containers[0].ChildCount != "1" {
+		t.Fatalf("folder metadata = %+v", folder)
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, film.Res[0].URL, nil)
+	request.Header.Set("getcontentFeatures.dlna.org", "1")
+	request.Header.Set("Range", "bytes=5-")
+	if body, header := fetch(t, request, http.StatusPartialContent); body != "bytes" || header.Get("Content-Type") != "video/mp4" || !strings.Contains(header.Get("contentFeatures.dlna.org"), "DLNA.ORG_OP=01") {
+		t.Fatalf("media = %q, %v", body, header)
+	}
+	request, _ = http.NewRequest(http.MethodGet, film.Res[1].URL, nil)
+	if body, _ := fetch(t, request, http.StatusOK); !strings.Contains(body, "Hi") {
+		t.Fatalf("subtitle = %q", body)
+	}
+	request, _ = http.NewRequest(http.MethodGet, film.ArtURI, nil)
+	if body, _ := fetch(t, request, http.StatusOK); body != "thumbnail" {
+		t.Fatalf("artwork = %q", body)
+	}
+	tampered := strings.Replace(film.Res[0].URL, "/"+rootID+"/", "/"+rootID+"/x", 1)
+	request, _ = http.NewRequest(http.MethodGet, tampered, nil)
+	fetch(t, request, http.StatusNotFound)
+
+	if code := soapFault(t, base+contentDirectoryControlPath, contentDirectoryType, "Browse", map[string]string{"ObjectID": rootID + "/forged", "BrowseFlag": "BrowseDirectChildren", "StartingIndex": "0", "RequestedCount": "0"}); code != "701" {
+		t.Fatalf("forged object fault = %q", code)
+	}
+}
+
+func TestSearchByTitleAndClass(t *testing.T) {
+	server, rootID := startTestServer(t)
+	base := "http://" + server.Addr()
+	search := func(container, criteria string) browseResult {
+		t.Helper()
+		out := soap(t, base+contentDirectoryControlPath, contentDirectoryType, "Search", map[string]string{"ContainerID": container, "SearchCriteria": criteria, "Filter": "*", "StartingIndex": "0", "RequestedCount": "0", "SortCriteria": ""})
+		return parseBrowse(t, out)
+	}
+	found := search("0", `upnp:class derivedfrom "object.item.videoItem" and dc:title contains "FILM"`)
+	if len(found.didl.Items) != 1 || found.didl.Items[0].Title != "film.mp4" || !strings.HasPrefix(found.didl.Items[0].ParentID, rootID+"/") {
+		t.Fatalf("video search = %+v", found)
+	}
+	if found := search("0", `upnp:class derivedfrom "object.item.audioItem" and dc:title contains "film"`); found.total != "0" {
+		t.Fatalf("audio search = %+v", found)
+	}
+	if found := search(rootID, `dc:title contains "song" or dc:title contains "photo"`); found.total != "2" {
+		t.Fatalf("either search = %+v", found)
+	}
+	moviesID := found.didl.Items[0].ParentID
+	if found := search(moviesID, `dc:title contains "song"`); found.total != "0" {
+		t.Fatalf("scoped search = %+v", found)
+	}
+	for _, criteria := range []string{"*", `upnp:class derivedfrom "object.item"`, `dc:title < "x"`} {
+		if code := soapFault(t, base+contentDirectoryControlPath, contentDirectoryType, "Search", map[string]string{"ContainerID": "0", "SearchCriteria": criteria, "StartingIndex": "0", "RequestedCount": "0"}); code != "708" {
+			t.Fatalf("criteria %q fault = %q", criteria, code)
+		}
+	}
+
+	info := soap(t, base+connectionManagerControlPath, connectionManagerType, "GetProtocolInfo", nil)
+	if !strings.Contains(info["Source"], "http-get:*:video/mp4:*") || info["Sink"] != "" {
+		t.Fatalf("protocol info = %v", info)
+	}
+}
+
+func TestPagingReusesSnapshotsAndReleasesCursors(t *testing.T) {
+	root := t.TempDir()
+	for i := range maxSearchResults + 100 {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("clip %04d.mp3", i)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lib, err := library.Open(library.Config{Roots: []string{root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lib.Close() })
+	server, err := New(Config{Library: lib, ListenAddr: "127.0.0.1:0", SSDPAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	base := "http://" + server.Addr()
+	rootID := lib.Roots()[0].ID
+
+	// Each search stops at maxSearchResults with its library cursor still
+	// open; more of them than the library allows must not exhaust it.
+	for range library.MaxCursors + 1 {
+		out := soap(t, base+contentDirectoryControlPath, contentDirectoryType, "Search", map[string]string{"ContainerID": "0", "SearchCriteria": `dc:title contains "clip"`, "StartingIndex": "0", "RequestedCount": "1"})
+		if found := parseBrowse(t, out); found.total != strconv.Itoa(maxSearchResults) {
+			t.Fatalf("search total = %s", found.total)
+		}
+	}
+	if _, err := lib.Browse(rootID, "", "", 1); err != nil {
+		t.Fatalf("browse after searches: %v", err)
+	}
+
+	first := browse(t, base, rootID, "BrowseDirectChildren", 0, 10)
+	if err := os.WriteFile(filepath.Join(root, "clip 0000a.mp3"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	next := browse(t, base, rootID, "BrowseDirectChildren", 10, 10)
+	if first.total != next.total || next.didl.Items[0].Title != "clip 0010.mp3" {
+		t.Fatalf("next page = %s items from %q, first %s", next.total, next.didl.Items[0].Title, first.total)
+	}
+	if again := browse(t, base, rootID, "BrowseDirectChildren", 0, 10); again.total == first.total {
+		t.Fatalf("first page reread total = %s", again.total)
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, base+contentDirectoryControlPath, nil)
+	request.Host = "attacker.example"
+	if got := server.baseURL(request.WithContext(context.WithValue(request.Context(), http.LocalAddrContextKey, &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 8200}))); got != "http://192.0.2.1:8200" {
+		t.Fatalf("base URL = %q", got)
+	}
+	if got := server.baseURL(request); got != base {
+		t.Fatalf("base URL without a connection = %q", got)
+	}
+}
+
+type browseResult struct {
+	didl     didlResult
+	returned string
+	total    string
+}
+
+func browse(t *testing.T, base, objectID, flag string, start, count int) browseResult {
+	t.Helper()
+	out := soap(t, base+contentDirectoryControlPath, contentDirectoryType, "Browse", map[string]string{
+		"ObjectID": objectID, "BrowseFlag": flag, "Filter": "*",
+		"StartingIndex": strconv.Itoa(start), "RequestedCount": strconv.Itoa(count), "SortCriteria": "",
+	})
+	return parseBrowse(t, out)
+}
+
+func parseBrowse(t *testing.T, out map[string]string) browseResult {
+	t.Helper()
+	result := browseResult{returned: out["NumberReturned"], total: out["TotalMatches"]}
+	if err := xml.Unmarshal([]byte(out["Result"]), &result.didl); err != nil {
+		t.Fatalf("result %q: %v", out["Result"], err)
+	}
+	return result
+}
+
+func soap(t *testing.T, url, serviceType, action string, args map[string]string) map[string]string {
+	t.Helper()
+	response := postAction(t, url, serviceType, action, args)
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(response.Body)
+		t.Fatalf("%s status %d: %s", action, response.StatusCode, body)
+	}
+	var envelope struct {
+		Body struct {
+			Response struct {
+				Arguments []soapArgument `xml:",any"`
+			} `xml:",any"`
+		} `xml:"Body"`
+	}
+	if err := xml.NewDecoder(response.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]string)
+	for _, arg := range envelope.Body.Response.Arguments {
+		out[arg.XMLName.Local] = arg.Value
+	}
+	return out
+}
+
+func soapFault(t *testing.T, url, serviceType, action string, args map[string]string) string {
+	t.Helper()
+	response := postAction(t, url, serviceType, action, args)
+	defer response.Body.Close()
+	var envelope struct {
+		Code string `xml:"Body>Fault>detail>UPnPError>errorCode"`
+	}
+	if err := xml.NewDecoder(response.Body).Decode(&envelope); err != nil || response.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("%s fault status %d: %v", action, response.StatusCode, err)
+	}
+	return envelope.Code
+}
+
+func postAction(t *testing.T, url, serviceType, action string, args map[string]string) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:` + action + ` xmlns:u="` + serviceType + `">`)
+	for name, value := range args {
+		body.WriteString("<" + name + ">")
+		_ = xml.EscapeText(&body, []byte(value))
+		body.WriteString("</" + name + ">")
+	}
+	body.WriteString(`</u:` + action + `></s:Body></s:Envelope>`)
+	request, _ := http.NewRequest(http.MethodPost, url, &body)
+	request.Header.Set("SOAPACTION", `"`+serviceType+"#"+action+`"`)
+	request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func fetch(t *testing.T, request *http.Request, status int) (string, http.Header) {
+	t.Helper()
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if response.StatusCode != status {
+		t.Fatalf("%s status %d, want %d", request.URL, response.StatusCode, status)
+	}
+	return string(body), response.Header
+}
@@ -0,0 +1,118 @@
+package upnpserver
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxControlBody bounds a SOAP request. Search criteria are the longest
+// arguments any action takes.
+const maxControlBody = 64 << 10
+
+// UPnP errors the actions return. Codes from 700 up mean different things
+// in different services.
+var (
+	errInvalidAction   = upnpError{401, "Invalid Action"}
+	errInvalidArgs     = upnpError{402, "Invalid Args"}
+	errActionFailed    = upnpError{501, "Action Failed"}
+	errNoSuchObject    = upnpError{701, "No such object"}
+	errInvalidCriteria = upnpError{708, "Unsupported or invalid search criteria"}
+	errNoSuchContainer = upnpError{710, "No such container"}
+)
+
+type soapEnvelope struct {
+	Body struct {
+		Action soapAction `xml:",any"`
+	} `xml:"Body"`
+}
+
+type soapAction struct {
+	XMLName   xml.Name
+	Arguments []soapArgument `xml:",any"`
+}
+
+type soapArgument struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// soapArg is one output argument, in the order the SCPD lists them.
+type soapArg struct {
+	name  string
+	value string
+}
+
+// upnpError is a failed action. It is answered with a SOAP fault.
+type upnpError struct {
+	code        int
+	description string
+}
+
+func (e upnpError) Error() string { return e.description }
+
+// actionFunc runs action with its input arguments.
+type actionFunc func(r *http.Request, action string, args map[string]string) ([]soapArg, error)
+
+func control(w http.ResponseWriter, r *http.Request, serviceType string, run actionFunc) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxControlBody+1))
+	if err != nil || len(body) > maxControlBody {
+		writeFault(w, errInvalidArgs)
+		return
+	}
+	var envelope soapEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		writeFault(w, errInvalidAction)
+		return
+	}
+	action := envelope.Body.Action
+	// SOAPACTION names the action too; a client that disagrees with its own
+	// body is not guessed at.
+	if header := strings.Trim(r.Header.Get("SOAPACTION"), `"`); header != "" && header != serviceType+"#"+action.XMLName.Local {
+		writeFault(w, errInvalidAction)
+		return
+	}
+	args := make(map[string]string, len(action.Arguments))
+	for _, arg := range action.Arguments {
+		args[arg.XMLName.Local] = arg.Value
+	}
+	out, err := run(r, action.XMLName.Local, args)
+	if err != nil {
+		code := errActionFailed
+		errors.As(err, &code)
+		writeFault(w, code)
+		return
+	}
+	writeResponse(w, serviceType, action.XMLName.Local, out)
+}
+
+func writeResponse(w http.ResponseWriter, serviceType, action string, out []soapArg) {
+	var body strings.Builder
+	body.WriteString(xml.Header)
+	body.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	body.WriteString(`<u:` + action + `Response xmlns:u="` + serviceType + `">`)
+	for _, arg := range out {
+		body.WriteString("<" + arg.name + ">")
+		_ = xml.EscapeText(&body, []byte(arg.value))
+		body.WriteString("</" + arg.name + ">")
+	}
+	body.WriteString(`</u:` + action + `Response></s:Body></s:Envelope>`)
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("EXT", "")
+	_, _ = io.WriteString(w, body.String())
+}
+
+func writeFault(w http.ResponseWriter, code upnpError) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("EXT", "")
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = io.WriteString(w, xml.Header+`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>`+strconv.Itoa(code.code)+`</errorCode><errorDescription>`+code.description+`</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`)
+}
@@ -0,0 +1,215 @@
+package upnpserver
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSearchDelay caps the MX delay a multicast search asks for, as UPnP
+// 1.1 does.
+const maxSearchDelay = 5
+
+// ssdpDevice is the root device a responder answers for.
+type ssdpDevice struct {
+	uuid     string
+	version  string
+	interval time.Duration
+	// types lists the device type and then its service types.
+	types       []string
+	description string
+}
+
+// ssdpResponder answers M-SEARCH requests and, on the multicast group,
+// announces the device as it starts, every interval, and as it leaves.
+type ssdpResponder struct {
+	uuid     string
+	types    []string
+	server   string
+	path     string
+	maxAge   int
+	interval time.Duration
+	httpPort int
+	httpIP   net.IP
+	conn     *net.UDPConn
+	// group is nil when the socket is unicast and nothing is announced.
+	group *net.UDPAddr
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newSSDPResponder(ssdpAddr string, device ssdpDevice, httpAddr *net.TCPAddr) (*ssdpResponder, error) {
+	addr, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("ssdp address: %w", err)
+	}
+	r := &ssdpResponder{
+		uuid:     device.uuid,
+		types:    device.types,
+		server:   runtime.GOOS + "/1.0 UPnP/1.0 go2tv/" + device.version,
+		path:     device.description,
+		maxAge:   int(2 * device.interval / time.Second),
+		interval: device.interval,
+		httpPort: httpAddr.Port,
+		httpIP:   httpAddr.IP,
+		done:     make(chan struct{}),
+	}
+	if addr.IP.IsMulticast() {
+		r.conn, err = net.ListenMulticastUDP("udp4", nil, addr)
+		r.group = addr
+	} else {
+		r.conn, err = net.ListenUDP("udp4", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listen ssdp: %w", err)
+	}
+	return r, nil
+}
+
+func (r *ssdpResponder) start() {
+	r.wg.Add(1)
+	go r.serve()
+	if r.group == nil {
+		return
+	}
+	r.notify("ssdp:alive")
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				r.notify("ssdp:alive")
+			}
+		}
+	}()
+}
+
+func (r *ssdpResponder) close() error {
+	if r.group != nil {
+		r.notify("ssdp:byebye")
+	}
+	close(r.done)
+	err := r.conn.Close()
+	r.wg.Wait()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (r *ssdpResponder) serve() {
+	defer r.wg.Done()
+	buf := make([]byte, 8<<10)
+	for {
+		n, remote, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buf[:n])))
+		if err != nil || request.Method != "M-SEARCH" || request.Header.Get("MAN") != `"ssdp:discover"` {
+			continue
+		}
+		targets := r.targets(request.Header.Get("ST"))
+		if len(targets) == 0 {
+			continue
+		}
+		var delay time.Duration
+		if mx, err := strconv.Atoi(request.Header.Get("MX")); err == nil && mx > 0 && r.group != nil {
+			delay = rand.N(time.Duration(min(mx, maxSearchDelay)) * time.Second)
+		}
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			select {
+			case <-r.done:
+				return
+			case <-time.After(delay):
+			}
+			location := r.location(remote)
+			for _, target := range targets {
+				_, _ = r.conn.WriteToUDP(r.message("HTTP/1.1 200 OK", []string{
+					"CACHE-CONTROL", "max-age=" + strconv.Itoa(r.maxAge),
+					"DATE", time.Now().UTC().Format(http.TimeFormat),
+					"EXT", "",
+					"LOCATION", location,
+					"SERVER", r.server,
+					"ST", target,
+					"USN", r.usn(target),
+				}), remote)
+			}
+		}()
+	}
+}
+
+func (r *ssdpResponder) notify(nts string) {
+	location := r.location(r.group)
+	for _, target := range r.targets("ssdp:all") {
+		headers := []string{"HOST", r.group.String(), "NT", target, "NTS", nts, "USN", r.usn(target)}
+		if nts == "ssdp:alive" {
+			headers = append(headers, "CACHE-CONTROL", "max-age="+strconv.Itoa(r.maxAge), "LOCATION", location, "SERVER", r.server)
+		}
+		_, _ = r.conn.WriteToUDP(r.message("NOTIFY * HTTP/1.1", headers), r.group)
+	}
+}
+
+func (r *ssdpResponder) targets(st string) []string {
+	all := append([]string{"upnp:rootdevice", "uuid:" + r.uuid}, r.types...)
+	if st == "ssdp:all" {
+		return all
+	}
+	for _, target := range all {
+		if st == target {
+			return []string{target}
+		}
+	}
+	return nil
+}
+
+func (r *ssdpResponder) usn(target string) string {
+	if target == "uuid:"+r.uuid {
+		return target
+	}
+	return "uuid:" + r.uuid + "::" + target
+}
+
+// location is the description URL as seen from remote. A server listening
+// on every address answers with the one its route to remote leaves from.
+func (r *ssdpResponder) location(remote *net.UDPAddr) string {
+	ip := r.httpIP
+	if ip == nil || ip.IsUnspecified() {
+		ip = net.IPv4(127, 0, 0, 1)
+		if conn, err := net.DialUDP("udp4", nil, remote); err == nil {
+			ip = conn.LocalAddr().(*net.UDPAddr).IP
+			_ = conn.Close()
+		}
+	}
+	return "http://" + net.JoinHostPort(ip.String(), strconv.Itoa(r.httpPort)) + r.path
+}
+
+// message formats a start line and header name, value pairs.
+func (r *ssdpResponder) message(start string, headers []string) []byte {
+	var b strings.Builder
+	b.WriteString(start + "\r\n")
+	for i := 0; i+1 < len(headers); i += 2 {
+		b.WriteString(headers[i] + ": " + headers[i+1] + "\r\n")
+	}
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
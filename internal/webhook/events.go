@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"slices"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+// Event types. New types may be added; receivers must tolerate unknown ones.
+const (
+	EventSessionStarted = "session.started"
+	EventSessionEnded   = "session.ended"
+	EventItemAdvanced   = "item.advanced"
+	EventPaused         = "playback.paused"
+	EventResumed        = "playback.resumed"
+	EventQueueChanged   = "queue.changed"
+)
+
+// EventTypes lists every event type, in the order they are documented.
+var EventTypes = []string{EventSessionStarted, EventSessionEnded, EventItemAdvanced, EventPaused, EventResumed, EventQueueChanged}
+
+// ReasonDeviceLost replaces the "error" terminal reason when the renderer has
+// also dropped out of discovery.
+const ReasonDeviceLost = "device_lost"
+
+// Event is the JSON body of one webhook delivery.
+type Event struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Revision   uint64    `json:"revision"`
+	Generation uint64    `json:"generation,omitempty"`
+	// Session is the device ID of an additional device session; it is
+	// omitted for the default session.
+	Session string  `json:"session,omitempty"`
+	State   string  `json:"state,omitempty"`
+	Device  *Device `json:"device,omitempty"`
+	Media   *Media  `json:"media,omitempty"`
+	// Reason is the playback.TerminalReason of session.ended, or
+	// ReasonDeviceLost.
+	Reason      string `json:"reason,omitempty"`
+	Error       string `json:"error,omitempty"`
+	QueueLength int    `json:"queue_length"`
+}
+
+type Device struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+}
+
+type Media struct {
+	QueueItemID string               `json:"queue_item_id,omitempty"`
+	Name        string               `json:"name"`
+	Kind        mediamodel.MediaKind `json:"kind,omitempty"`
+}
+
+// Diff derives the events between two snapshots of the same session.
+// Transitions that start and finish between the two snapshots are not seen.
+func Diff(previous, current controller.Snapshot) []Event {
+	var events []Event
+	add := func(kind string, from controller.Snapshot) *Event {
+		events = append(events, Event{
+			Type:        kind,
+			Revision:    current.Revision,
+			Generation:  from.Generation,
+			State:       current.PlaybackState,
+			Device:      activeDevice(from),
+			Media:       activeMedia(from),
+			QueueLength: len(current.Queue),
+		})
+		return &events[len(events)-1]
+	}
+	switch {
+	case previous.HasSession && !current.HasSession:
+		event := add(EventSessionEnded, previous)
+		event.Reason, event.Error = string(current.TerminalReason), current.LastError
+		if current.TerminalReason == playback.TerminalError && !slices.ContainsFunc(current.Devices, func(device playback.Device) bool {
+			return device.ID == previous.ActiveDeviceID
+		}) {
+			event.Reason = ReasonDeviceLost
+		}
+	case !previous.HasSession && current.HasSession:
+		add(EventSessionStarted, current)
+	case current.HasSession && (current.Generation != previous.Generation || activeItemID(current) != activeItemID(previous)):
+		add(EventItemAdvanced, current)
+	case current.HasSession && previous.PlaybackState == controller.PlaybackStatePlaying && current.PlaybackState == controller.PlaybackStatePaused:
+		add(EventPaused, current)
+	case current.HasSession && previous.PlaybackState == controller.PlaybackStatePaused && current.PlaybackState == controller.PlaybackStatePlaying:
+		add(EventResumed, current)
+	}
+	if !slices.EqualFunc(previous.Queue, current.Queue, func(a, b controller.QueueItem) bool { return a.ID == b.ID }) {
+		add(EventQueueChanged, current)
+	}
+	return events
+}
+
+func activeItemID(snapshot controller.Snapshot) string {
+	for _, item := range snapshot.Queue {
+		if item.IsActive {
+			return item.ID
+		}
+	}
+	return ""
+}
+
+func activeDevice(snapshot controller.Snapshot) *Device {
+	for _, device := range snapshot.Devices {
+		if device.ID == snapshot.ActiveDeviceID {
+			return &Device{ID: device.ID, Name: device.Name, Protocol: device.Protocol}
+		}
+	}
+	return nil
+}
+
+func activeMedia(snapshot controller.Snapshot) *Media {
+	if !snapshot.HasSession || snapshot.ActiveMediaName == "" {
+		return nil
+	}
+	media := &Media{Name: snapshot.ActiveMediaName, Kind: snapshot.MediaType}
+	media.QueueItemID = activeItemID(snapshot)
+	return media
+}
@@ -0,0 +1,317 @@
+// Package webhook posts playback transitions to HTTP endpoints. It follows
+// the change feed of the controller and diffs consecutive snapshots of each
+// session, so it needs no hooks inside the controller's state machine.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+)
+
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body,
+	// keyed with the endpoint secret. It is omitted without a secret.
+	SignatureHeader = "X-Go2TV-Signature"
+	EventHeader     = "X-Go2TV-Event"
+	DeliveryHeader  = "X-Go2TV-Delivery"
+
+	defaultBackoff  = time.Second
+	defaultAttempts = 5
+	maxBackoff      = 30 * time.Second
+	requestTimeout  = 10 * time.Second
+	// pendingLimit bounds the events waiting for a slow endpoint; newer
+	// events are dropped once it is reached.
+	pendingLimit = 64
+)
+
+var ErrInvalidEndpoint = errors.New("invalid webhook endpoint")
+
+// Endpoint is one webhook receiver.
+type Endpoint struct {
+	URL    string
+	Secret string
+	// Events limits delivery to these event types; empty delivers all.
+	Events []string
+}
+
+// Validate checks that URL is an absolute http(s) URL and that Events only
+// names known event types.
+func (e Endpoint) Validate() error {
+	parsed, err := url.Parse(e.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidEndpoint, e.URL)
+	}
+	for _, event := range e.Events {
+		if !slices.Contains(EventTypes, event) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidEndpoint, event)
+		}
+	}
+	return nil
+}
+
+func (e Endpoint) wants(event string) bool {
+	return len(e.Events) == 0 || slices.Contains(e.Events, event)
+}
+
+// host names the endpoint in logs without its path or query, which may hold
+// credentials.
+func (e Endpoint) host() string {
+	if parsed, err := url.Parse(e.URL); err == nil {
+		return parsed.Host
+	}
+	return "webhook"
+}
+
+// Source is the part of *controller.Controller the notifier reads.
+type Source interface {
+	Snapshot(context.Context) (controller.Snapshot, error)
+	Subscribe(int) (<-chan controller.Change, func())
+}
+
+type Config struct {
+	Source    Source
+	Endpoints []Endpoint
+	// Logger receives delivery logs. It is optional.
+	Logger controller.EventLogger
+	// Client defaults to an http.Client with a 10s timeout.
+	Client *http.Client
+	// Backoff and Attempts default to 1s and 5. Backoff doubles after each
+	// failed attempt, up to 30s.
+	Backoff  time.Duration
+	Attempts int
+}
+
+// Notifier follows Source and delivers events to every endpoint that wants
+// them. Each endpoint has its own ordered delivery queue, so a slow or
+// failing receiver does not hold up the others.
+type Notifier struct {
+	cfg       Config
+	ctx       context.Context
+	cancel    context.CancelFunc
+	queues    []chan Event
+	stop      func()
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// New validates the endpoints and starts following Source from its current
+// state.
+func New(cfg Config) (*Notifier, error) {
+	if cfg.Source == nil {
+		return nil, errors.New("webhook source is required")
+	}
+	for _, endpoint := range cfg.Endpoints {
+		if err := endpoint.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: requestTimeout}
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = defaultAttempts
+	}
+	changes, stop := cfg.Source.Subscribe(pendingLimit)
+	ctx, cancel := context.WithCancel(context.Background())
+	// Changes up to the baseline revision are already part of it.
+	baselineCtx, baselineCancel := context.WithTimeout(ctx, requestTimeout)
+	baseline, err := cfg.Source.Snapshot(baselineCtx)
+	baselineCancel()
+	if err != nil {
+		stop()
+		cancel()
+		return nil, fmt.Errorf("webhook baseline: %w", err)
+	}
+	n := &Notifier{cfg: cfg, ctx: ctx, cancel: cancel, stop: stop}
+	for _, endpoint := range cfg.Endpoints {
+		queue := make(chan Event, pendingLimit)
+		n.queues = append(n.queues, queue)
+		n.wg.Add(1)
+		go n.run(endpoint, queue)
+	}
+	n.wg.Add(1)
+	go n.follow(changes, baseline)
+	return n, nil
+}
+
+// Close stops following Source and abandons undelivered events.
+func (n *Notifier) Close() {
+	n.closeOnce.Do(func() {
+		n.cancel()
+		n.stop()
+		n.wg.Wait()
+	})
+}
+
+// follow diffs each change against the previous state of the same session.
+// An additional session that is closed and opened again counts its revisions
+// from zero, so a revision that goes backwards starts it over.
+func (n *Notifier) follow(changes <-chan controller.Change, baseline controller.Snapshot) {
+	defer n.wg.Done()
+	previous := map[string]controller.Snapshot{"": baseline}
+	for {
+		var change controller.Change
+		select {
+		case <-n.ctx.Done():
+			return
+		case next, ok := <-changes:
+			if !ok {
+				return
+			}
+			change = next
+		}
+		last := previous[change.Session]
+		if change.Snapshot.Revision <= last.Revision {
+			if change.Session == "" {
+				continue
+			}
+			last = controller.Snapshot{}
+		}
+		for _, event := range Diff(last, change.Snapshot) {
+			event.Session = change.Session
+			n.publish(event)
+		}
+		previous[change.Session] = change.Snapshot
+	}
+}
+
+func (n *Notifier) publish(event Event) {
+	event.ID, event.Time = newDeliveryID(), time.Now().UTC()
+	for index, endpoint := range n.cfg.Endpoints {
+		if !endpoint.wants(event.Type) {
+			continue
+		}
+		select {
+		case n.queues[index] <- event:
+		default:
+			n.logWarning("Webhook " + endpoint.host() + " is falling behind; dropped " + event.Type)
+		}
+	}
+}
+
+func (n *Notifier) run(endpoint Endpoint, queue <-chan Event) {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case event := <-queue:
+			n.deliver(endpoint, event)
+		}
+	}
+}
+
+// deliver posts event, retrying network errors, 429, and 5xx responses with
+// exponential backoff.
+func (n *Notifier) deliver(endpoint Endpoint, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	backoff := n.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		err := n.post(endpoint, event, body)
+		if err == nil {
+			n.logInfo(fmt.Sprintf("Webhook %s delivered to %s", event.Type, endpoint.host()))
+			return
+		}
+		if n.ctx.Err() != nil {
+			return
+		}
+		var status statusError
+		if attempt == n.cfg.Attempts || errors.As(err, &status) && !status.retryable() {
+			n.logWarning(fmt.Sprintf("Webhook %s to %s failed after %d attempts: %v", event.Type, endpoint.host(), attempt, err))
+			return
+		}
+		n.logDebug(fmt.Sprintf("Webhook %s to %s failed, retrying in %s: %v", event.Type, endpoint.host(), backoff, err))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-n.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (n *Notifier) post(endpoint Endpoint, event Event, body []byte) error {
+	request, err := http.NewRequestWithContext(n.ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "go2tv-webhook")
+	request.Header.Set(EventHeader, event.Type)
+	request.Header.Set(DeliveryHeader, event.ID)
+	if endpoint.Secret != "" {
+		request.Header.Set(SignatureHeader, Sign(endpoint.Secret, body))
+	}
+	response, err := n.cfg.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	_ = response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return statusError(response.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type statusError int
+
+func (e statusError) Error() string { return fmt.Sprintf("HTTP %d", int(e)) }
+
+func (e statusError) retryable() bool {
+	return e == http.StatusTooManyRequests || e >= 500
+}
+
+func newDeliveryID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+func (n *Notifier) logDebug(message string) {
+	if n.cfg.Logger != nil {
+		n.cfg.Logger.Debug(message)
+	}
+}
+
+func (n *Notifier) logInfo(message string) {
+	if n.cfg.Logger != nil {
+		n.cfg.Logger.Info(message)
+	}
+}
+
+func (n *Notifier) logWarning(message string) {
+	if n.cfg.Logger != nil {
+		n.cfg.Logger.Warning(message)
+	}
+}
@@ -0,0 +1,283 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+var tv = playback.Device{ID: "tv", Name: "Living room", Protocol: "DLNA"}
+
+func playing(revision, generation uint64, item string) controller.Snapshot {
+	return controller.Snapshot{
+		Revision:        revision,
+		Devices:         []playback.Device{tv},
+		ActiveDeviceID:  tv.ID,
+		ActiveMediaName: item + ".mp4",
+		Queue:           []controller.QueueItem{{ID: "a", IsActive: item == "a"}, {ID: "b", IsActive: item == "b"}},
+		Generation:      generation,
+		HasSession:      true,
+		PlaybackState:   controller.PlaybackStatePlaying,
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	idle := controller.Snapshot{Revision: 1, Devices: []playback.Device{tv}, Queue: []controller.QueueItem{{ID: "a"}, {ID: "b"}}}
+	paused := playing(3, 1, "a")
+	paused.PlaybackState = controller.PlaybackStatePaused
+	finished := idle
+	finished.Revision, finished.TerminalReason = 6, playback.TerminalFinished
+	lost := controller.Snapshot{Revision: 6, Queue: idle.Queue, TerminalReason: playback.TerminalError, LastError: "playback failed"}
+	requeued := playing(7, 1, "a")
+	requeued.Queue = requeued.Queue[:1]
+	tests := []struct {
+		name     string
+		previous controller.Snapshot
+		current  controller.Snapshot
+		want     []string
+		reason   string
+	}{
+		{name: "start", previous: idle, current: playing(2, 1, "a"), want: []string{EventSessionStarted}},
+		{name: "pause", previous: playing(2, 1, "a"), current: paused, want: []string{EventPaused}},
+		{name: "resume", previous: paused, current: playing(4, 1, "a"), want: []string{EventResumed}},
+		{name: "advance", previous: playing(4, 1, "a"), current: playing(5, 2, "b"), want: []string{EventItemAdvanced}},
+		{name: "finish", previous: playing(5, 2, "b"), current: finished, want: []string{EventSessionEnded}, reason: "finished"},
+		{name: "device lost", previous: playing(5, 2, "b"), current: lost, want: []string{EventSessionEnded}, reason: ReasonDeviceLost},
+		{name: "queue", previous: playing(5, 1, "a"), current: requeued, want: []string{EventQueueChanged}},
+		{name: "position only", previous: playing(5, 1, "a"), current: playing(6, 1, "a")},
+	}
+	for _, tt := range tests {
+		events := Diff(tt.previous, tt.current)
+		var kinds []string
+		for _, event := range events {
+			kinds = append(kinds, event.Type)
+		}
+		if !slices.Equal(kinds, tt.want) {
+			t.Fatalf("%s: events = %v, want %v", tt.name, kinds, tt.want)
+		}
+		if tt.reason != "" {
+			if event := events[0]; event.Reason != tt.reason || event.Device == nil || event.Device.Name != tv.Name || event.Media == nil || event.Media.QueueItemID != "b" {
+				t.Fatalf("%s: event = %#v", tt.name, event)
+			}
+		}
+	}
+}
+
+type fakeSource struct {
+	mu       sync.Mutex
+	snapshot controller.Snapshot
+	changes  chan controller.Change
+}
+
+func newFakeSource(snapshot controller.Snapshot) *fakeSource {
+	return &fakeSource{snapshot: snapshot, changes: make(chan controller.Change, 8)}
+}
+
+func (s *fakeSource) Snapshot(context.Context) (controller.Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot, nil
+}
+
+func (s *fakeSource) Subscribe(int) (<-chan controller.Change, func()) {
+	return s.changes, func() {}
+}
+
+func (s *fakeSource) set(snapshot controller.Snapshot) {
+	s.mu.Lock()
+	s.snapshot = snapshot
+	s.mu.Unlock()
+	s.changes <- controller.Change{Snapshot: snapshot}
+}
+
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) add(message string) {
+	l.mu.Lock()
+	l.messages = append(l.messages, message)
+	l.mu.Unlock()
+}
+func (l *testLogger) Debug(message string)   { l.add(message) }
+func (l *testLogger) Info(message string)    { l.add(message) }
+func (l *testLogger) Warning(message string) { l.add(message) }
+func (l *testLogger) Error(message string)   { l.add(message) }
+
+func TestNotifierSignsAndRetries(t *testing.T) {
+	type delivery struct {
+		header http.Header
+		body   []byte
+	}
+	deliveries := make(chan delivery, 8)
+	var attempts int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		attempts++
+		failing := attempts == 1
+		mu.Unlock()
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		deliveries <- delivery{header: r.Header.Clone(), body: body}
+	}))
+	defer server.Close()
+
+	source := newFakeSource(controller.Snapshot{Revision: 1})
+	logger := &testLogger{}
+	notifier, err := New(Config{
+		Source:    source,
+		Endpoints: []Endpoint{{URL: server.URL + "/hook", Secret: "s3cret", Events: []string{EventSessionStarted}}},
+		Logger:    logger,
+		Backoff:   time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notifier.Close()
+	source.set(playing(2, 1, "a"))
+
+	var got delivery
+	select {
+	case got = <-deliveries:
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+	if got.header.Get(SignatureHeader) != Sign("s3cret", got.body) || got.header.Get(EventHeader) != EventSessionStarted {
+		t.Fatalf("headers = %v", got.header)
+	}
+	var event Event
+	if err := json.Unmarshal(got.body, &event); err != nil || event.Type != EventSessionStarted || event.Device.ID != tv.ID || event.ID != got.header.Get(DeliveryHeader) {
+		t.Fatalf("event = %#v, %v", event, err)
+	}
+	// The endpoint only wants session.started, so the advance is filtered out.
+	source.set(playing(3, 1, "b"))
+	select {
+	case extra := <-deliveries:
+		t.Fatalf("unexpected delivery %s", extra.body)
+	case <-time.After(50 * time.Millisecond):
+	}
+	notifier.Close()
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if !slices.ContainsFunc(logger.messages, func(message string) bool { return strings.Contains(message, "retrying") }) ||
+		!slices.ContainsFunc(logger.messages, func(message string) bool { return strings.Contains(message, "delivered") }) {
+		t.Fatalf("logs = %q", logger.messages)
+	}
+}
+
+func TestNotifierGivesUpOnClientErrors(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+	source := newFakeSource(controller.Snapshot{Revision: 1})
+	logger := &testLogger{}
+	notifier, err := New(Config{Source: source, Endpoints: []Endpoint{{URL: server.URL}}, Logger: logger, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.set(playing(2, 1, "a"))
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		logger.mu.Lock()
+		failed := 0
+		for _, message := range logger.messages {
+			if strings.Contains(message, "HTTP 410") {
+				failed++
+			}
+		}
+		logger.mu.Unlock()
+		// session.started and queue.changed each fail once.
+		if failed == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("failure not logged")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	notifier.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Fatalf("attempts = %d", attempts)
+	}
+}
+
+func TestNotifierFollowsAdditionalSessions(t *testing.T) {
+	events := make(chan Event, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer server.Close()
+	source := newFakeSource(playing(4, 1, "a"))
+	notifier, err := New(Config{Source: source, Endpoints: []Endpoint{{URL: server.URL, Events: []string{EventSessionStarted, EventSessionEnded}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notifier.Close()
+	ended := playing(1, 0, "")
+	ended.HasSession, ended.TerminalReason = false, playback.TerminalShutdown
+	// A change the baseline already covers, then a bedroom session that
+	// starts, is closed, and is opened again with fresh revisions.
+	source.changes <- controller.Change{Snapshot: playing(3, 1, "a")}
+	source.changes <- controller.Change{Session: "bedroom", Snapshot: playing(1, 1, "b")}
+	ended.Revision = 2
+	source.changes <- controller.Change{Session: "bedroom", Snapshot: ended}
+	source.changes <- controller.Change{Session: "bedroom", Snapshot: playing(1, 1, "a")}
+	var got []string
+	for len(got) < 3 {
+		select {
+		case event := <-events:
+			if event.Session != "bedroom" {
+				t.Fatalf("event = %#v", event)
+			}
+			got = append(got, event.Type+":"+event.Reason)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("events = %v", got)
+		}
+	}
+	if want := []string{EventSessionStarted + ":", EventSessionEnded + ":shutdown", EventSessionStarted + ":"}; !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
+
+func TestEndpointValidate(t *testing.T) {
+	t.Parallel()
+	for _, endpoint := range []Endpoint{
+		{URL: "ftp://host/hook"},
+		{URL: "/relative"},
+		{URL: "https://host/hook", Events: []string{"session.exploded"}},
+	} {
+		if err := endpoint.Validate(); !errors.Is(err, ErrInvalidEndpoint) {
+			t.Fatalf("%#v: %v", endpoint, err)
+		}
+	}
+	if err := (Endpoint{URL: "https://host/hook", Events: EventTypes}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
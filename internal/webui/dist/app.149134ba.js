@@ -1 +0,0 @@
-function et(tt){let{document:c,window:ue,fetch:K,WebSocket:Se,location:X,sessionStorage:pe,localStorage:Ee,matchMedia:at,setTimeout:me,clearTimeout:Ne}=tt,r=e=>c.querySelector(`#${e}`),nt=r("status"),it=r("connection-dot"),rt=r("device-picker"),I=r("device-trigger"),fe=r("devices"),v=r("roots"),G=r("library"),E=r("queue"),ot=r("toast"),st=r("pending"),be=r("breadcrumbs"),Z=r("folder-up"),ee=r("add-visible"),Ce=r("add-visible-count"),te=r("back-to-top"),n={revision:0,devices:[],queue:[],policy:{LoopSelected:!1,AutoPlayNext:!1,AutoPlaySameType:!1,GaplessEnabled:!1,ImageDurationSeconds:10},selected_device_id:"",selected_media:!1,selected_media_name:"",active_media_name:"",selected_subtitle:!1,selected_subtitle_name:"",transcode:!1,has_session:!1,playback_state:"",position:0,duration:0,volume:0,muted:!1,media_type:"",artwork_id:""},U,lt=0,ae,T=!1,b=!1,N=!1,_="",f=[],F=[],Pe="",ye="",z=1e3,Ie="",w=null,y=null,H=null,ve="",he="",ne=!1,dt=pe.getItem("go2tv-protocol-reload")==="1",m=new Map,Ae=new Set(["library.play","player.play","player.pause","player.resume","player.stop"]),ct=new Set([...Ae,"library.clear_subtitle","player.seek","player.volume","player.mute","player.transcode"]),ut=new Set(["devices.select","devices.refresh"]),xe="http://www.w3.org/2000/svg",De=(e,t)=>{let a=c.createElement("option");return a.value=e,a.textContent=t,a},pt=(e,t=!1)=>{let a=c.createElementNS(xe,"svg"),i=c.createElementNS(xe,"use");return a.setAttribute("class",`action-icon${t?" is-spinning":""}`),a.setAttribute("viewBox","0 0 24 24"),a.setAttribute("aria-hidden","true"),a.setAttribute("focusable","false"),i.setAttribute("href",`#icon-${e}`),a.append(i),a},L=(e,t,a,i=!1)=>{(e.dataset.icon!==t||e.dataset.iconSpinning!==String(i))&&(e.replaceChildren(pt(t,i)),e.dataset.icon=t,e.dataset.iconSpinning=String(i)),e.title=a,e.ariaLabel=a},C=(e,t,a={})=>{let i=c.createElement("button");return i.type="button",i.disabled=!!a.disabled,i.className=a.className||"",a.icon?L(i,a.icon,a.ariaLabel||e,a.spin):i.textContent=e,i.title=a.title??(a.icon?e:""),i.ariaLabel=a.ariaLabel||i.ariaLabel||"",i.addEventListener("click",t),i},ie=(...e)=>{let t=c.createElement("div");return t.className="row-actions",t.append(...e),t},$=(e,t)=>{r(e).textContent=t},A=()=>String(n.playback_state||"STOPPED").toUpperCase(),h=(e,t="")=>[...m.values()].some(a=>a?.type===e&&(!t||a.payload?.item_id===t)),qe=e=>e?.type?.startsWith("queue.")||Ae.has(e?.type),mt=e=>ct.has(e?.type),ft=e=>ut.has(e?.type),Te=()=>["LOADING","STOPPING"].includes(A())||[...m.values()].some(qe),V=(e,t="")=>{nt.textContent=e,it.dataset.state=t},$e=e=>{e=Math.max(0,Number(e)||0);let t=Math.floor(e/3600),a=Math.floor(e%3600/60),i=Math.floor(e%60);return t?`${t}:${String(a).padStart(2,"0")}:${String(i).padStart(2,"0")}`:`${a}:${String(i).padStart(2,"0")}`},Oe=e=>{let t=Number(e);return!Number.isFinite(t)||t<=0?0:Math.min(300,Math.max(5,Math.trunc(t)))},Re=e=>({audio:"Audio",video:"Video",image:"Image"})[e]||"Media",bt=e=>{if(e.kind==="directory")return"Folder";let t=re(e.name),a=t?"Subtitle":Re(e.media_kind),i=e.name.lastIndexOf("."),l=i>0?e.name.slice(i+1).toUpperCase():"";return l?`${a} \xB7 ${l}`:a},yt=e=>({audio:"\u266A",video:"\u25B6",image:"\u25A7"})[e]||"\u2022",vt=(e,t)=>e.name.localeCompare(t.name,void 0,{numeric:!0,sensitivity:"base"}),re=e=>/\.(srt|vtt)$/i.test(e),Me=()=>{let e=r("library-filter").value.trim().toLowerCase();return e?F.filter(t=>t.name.toLowerCase().includes(e)):F},Ge=e=>e.filter(t=>t.kind!=="directory"&&!re(t.name)),oe=["auto","light","dark"],ht={auto:"Auto",light:"Light",dark:"Dark"},Ue=at("(prefers-color-scheme: dark)"),S=Ee.getItem("go2tv-theme");oe.includes(S)||(S="auto"),L(r("stop-button"),"square","Stop"),L(r("volume-down"),"volume-1","Volume down"),L(r("volume-up"),"volume-2","Volume up"),L(r("queue-clear"),"list-x","Clear playlist"),L(Z,"arrow-left","Up one folder");function ge(){let e=S==="auto"?Ue.matches?"dark":"light":S;c.documentElement.dataset.theme=e;for(let i of c.querySelectorAll('meta[name="theme-color"]'))i.content=e==="dark"?"#0b0a0f":"#e9e5f1";let t=r("theme-toggle"),a=`Theme: ${ht[S]}`;t.dataset.mode=S,t.title=a,t.ariaLabel=a}function gt(e,t=0){let a=e.added||0,i=e.duplicates||0,l=(e.dropped||0)+t,o=e.failed||0,d=[];a&&d.push(`Added ${a} ${a===1?"file":"files"} to playlist`),i&&d.push(`${i} already in playlist`),l&&d.push(`${l} skipped (playlist full)`),o&&d.push(`${o} unavailable`),d.length&&x(d.join("; "),a?"info":"error")}function x(e,t="info"){let a=c.createElement("p");a.textContent=e||"Request failed",a.dataset.level=t,ot.append(a),me(()=>a.remove(),5e3)}function ke(){let e=r("artwork-modal");r("artwork-modal-image").removeAttribute("src"),e.open&&e.close()}function kt(e){let t=r("artwork-modal"),a=r("artwork-modal-image");$("artwork-modal-title",e.name),a.alt=`Artwork for ${e.name}`,a.hidden=!1,a.src=e.artwork_url,t.showModal()}function _t(e){let t=c.createElement("button"),a=c.createElement("img"),i=c.createElement("span");return t.type="button",t.className="media-thumbnail",t.ariaLabel=`View artwork for ${e.name}`,t.title="View artwork",a.alt="",a.loading="lazy",a.decoding="async",a.src=e.thumbnail_url,i.className="thumbnail-fallback",i.textContent=yt(e.media_kind),i.ariaHidden="true",a.addEventListener("load",()=>{a.hidden=!1,i.hidden=!0,t.disabled=!1}),a.addEventListener("error",()=>{a.hidden=!0,i.hidden=!1,t.disabled=!0}),t.addEventListener("click",()=>kt(e)),t.append(a,i),t}function D(e){if(st.textContent=m.size?`${m.size} working`:"",!e?.type){O(),Q(),q();return}ft(e)&&q(),qe(e)&&Q(),mt(e)&&O()}function q(){let e=n.selected_device_id||"",t=n.devices||[],a=t.find(l=>l.id===e),i=!b||T||h("devices.select");if(I.replaceChildren(),I.dataset.selected=String(!!a),I.ariaExpanded=String(N),I.disabled=i||!t.length,a)Ve(I,a);else{let l=c.createElement("span");l.className="device-name",l.textContent=t.length?"Choose a renderer":"No renderers found",I.append(l)}fe.replaceChildren(),fe.hidden=!N;for(let l of t){let o=c.createElement("button");o.type="button",o.className="device-option",o.dataset.selected=String(l.id===e),o.role="option",o.ariaSelected=String(l.id===e),o.disabled=i,o.addEventListener("click",()=>{N=!1,u("devices.select",{device_id:l.id})}),Ve(o,l),fe.append(o)}r("refresh").disabled=!b||T||h("devices.refresh")}function Ve(e,t){let a=c.createElement("span"),i=c.createElement("span"),l=String(t.protocol||"Renderer");a.className="device-name",a.textContent=t.label,a.title=t.label,i.className="device-badges",i.append(je(l,l.toLowerCase())),(t.capabilities||[]).includes("audio_only")&&i.append(je("Audio only","audio-only")),e.append(a,i)}function je(e,t){let a=c.createElement("span");return a.className="device-badge",a.dataset.kind=t,a.textContent=e,a}function wt(e,t){let a=A();return e.selected&&a==="LOADING"||h("player.play",e.id)?{label:"Starting\u2026",icon:"loader-circle",spin:!0,disabled:!0,run:()=>{}}:e.active&&a==="PLAYING"?{label:"Pause",icon:"pause",disabled:t,run:()=>u("player.pause")}:e.active&&a==="PAUSED"?{label:"Resume",icon:"play",disabled:t,run:()=>u("player.resume")}:e.active&&a==="STOPPING"?{label:"Stopping\u2026",icon:"loader-circle",spin:!0,disabled:!0,run:()=>{}}:{label:"Play",icon:"play",disabled:!b||t,run:()=>u("player.play",{item_id:e.id})}}let Be=()=>[...E.children].filter(e=>e.className==="queue-row");function se(e,t){if(!y||e!==void 0&&y.pointerID!==e)return;let a=y;y=null;for(let i of Be())delete i.dataset.dragging,delete i.dataset.dropPosition;delete E.dataset.dragging;try{a.control.hasPointerCapture?.(a.pointerID)&&a.control.releasePointerCapture(a.pointerID)}catch{}t&&a.toIndex!==a.fromIndex&&!Te()&&u("queue.move",{item_id:a.itemID,delta:a.toIndex-a.fromIndex})}function Lt(e){if(!y||y.pointerID!==e.pointerId)return;e.preventDefault();let t=Be(),a=t.length-1;for(let[o,d]of t.entries()){let s=d.getBoundingClientRect();if(e.clientY<s.top+s.height/2){a=o;break}}y.toIndex=a;for(let[o,d]of t.entries())delete d.dataset.dropPosition,o===a&&a!==y.fromIndex&&(d.dataset.dropPosition=a<y.fromIndex?"before":"after");let i=E.getBoundingClientRect(),l=Math.min(48,i.height/4);e.clientY<i.top+l?E.scrollBy?.({top:-16,behavior:"auto"}):e.clientY>i.bottom-l&&E.scrollBy?.({top:16,behavior:"auto"})}function St(e,t,a,i,l){let o=c.createElement("button"),d=`Reorder ${e.name||"Untitled media"}`;return o.type="button",o.className="queue-drag-handle icon-action",o.disabled=!b||a||l<2,L(o,"grip-vertical",`${d}. Drag or use arrow keys`),o.title="Drag to reorder",o.setAttribute("aria-keyshortcuts","ArrowUp ArrowDown"),o.addEventListener("pointerdown",s=>{o.disabled||y||s.pointerType==="mouse"&&s.button!==0||(s.preventDefault(),y={pointerID:s.pointerId,itemID:e.id,fromIndex:t,toIndex:t,control:o},i.dataset.dragging="true",E.dataset.dragging="true",o.setPointerCapture?.(s.pointerId))}),o.addEventListener("pointermove",Lt),o.addEventListener("pointerup",s=>{s.preventDefault(),se(s.pointerId,!0)}),o.addEventListener("pointercancel",s=>se(s.pointerId,!1)),o.addEventListener("lostpointercapture",s=>se(s.pointerId,!1)),o.addEventListener("keydown",s=>{let p=s.key==="ArrowUp"?-1:s.key==="ArrowDown"?1:0;!p||o.disabled||t+p<0||t+p>=l||(s.preventDefault(),u("queue.move",{item_id:e.id,delta:p}))}),o}function Q(){let e=n.queue||[],t=Te(),a=[...m.values()].filter(d=>d?.type==="player.play").map(d=>d.payload?.item_id??""),i=JSON.stringify([e,t,A(),b,a]);if(i===Ie)return;if(y&&se(void 0,!1),Ie=i,r("queue-clear").disabled=!b||t||!e.length,E.replaceChildren(),$("queue-count",String(e.length)),!e.length){let d=c.createElement("li");d.className="empty-state",d.textContent="Playlist is empty. Add something from your library.",E.append(d);return}let l=null;for(let[d,s]of e.entries()){let p=c.createElement("li");p.className="queue-row",s.selected&&(p.dataset.current="true"),s.selected&&(l=p);let g=c.createElement("span");g.className="queue-index",g.textContent=String(d+1);let R=c.createElement("div");R.className="entry-copy";let M=c.createElement("strong");M.className="entry-name",M.textContent=s.name||"Untitled media",M.title=M.textContent,R.append(M);let B=c.createElement("span");B.className="entry-meta",B.textContent=s.active?"Now playing":s.selected?"Current":s.parent||Re(s.kind),R.append(B),p.append(g,R);let k=wt(s,t),J=s.active||s.selected&&A()!=="STOPPED",we=s.selected&&A()!=="STOPPED"?"Cannot remove current item":s.active?"Cannot remove active item":"Remove",Y=ie(C(k.label,k.run,{disabled:k.disabled,className:"queue-primary icon-action",icon:k.icon,spin:k.spin,title:k.label,ariaLabel:`${k.label.replace("\u2026","")} ${s.name}`}),St(s,d,t,p,e.length),C("Remove",()=>u("queue.remove",{item_id:s.id}),{disabled:t||J,className:"remove-action icon-action",icon:"trash-2",title:we,ariaLabel:`Remove ${s.name}`}));p.append(Y),E.append(p)}let o=e.find(d=>d.selected);w&&o?.id!==w.previousCurrentID&&(w=null,l?.scrollIntoView({behavior:"smooth",block:"nearest"}))}function le(){let e=r("seek"),t=Math.min(H??n.position??0,n.duration||0),a=n.duration?t:n.position??0;$("time",`${$e(a)} / ${$e(n.duration)}`),e.max=String(Math.max(0,n.duration||0)),e.value=String(t),e.disabled=!b||!n.has_session||!n.duration||A()==="LOADING"||A()==="STOPPING"||h("player.seek")}function O(){let e=A(),t=e.charAt(0)+e.slice(1).toLowerCase();$("playback-state",t),le();let a=e==="LOADING"?n.selected_media_name:n.active_media_name||n.selected_media_name;$("now-playing-title",a||"Nothing playing");let i=h("player.volume"),l=b&&(n.has_session||!!n.selected_device_id),o=r("mute"),d=n.muted?"Unmute":"Mute";r("volume-down").disabled=!l||i,r("volume-up").disabled=!l||i,L(o,"volume-x",d),o.ariaPressed=String(!!n.muted),o.disabled=!l||h("player.mute");let s=r("transcode");s.checked=!!n.transcode,s.disabled=!b||!ne||h("player.transcode"),s.title=ne?"":"FFmpeg unavailable";let p=n.selected_media?n.selected_media_name||"Current media":"No media",g=n.selected_subtitle?n.selected_subtitle_name||"Subtitle":"None",R=r("subtitle-clear"),M=r("subtitle-selection"),B=r("selection-status"),k=!!n.selected_subtitle;$("media-selected",p),$("subtitle-selected",g),r("media-selected").title=p,r("subtitle-selected").title=g,R.hidden=!n.selected_subtitle,R.disabled=!b||h("library.clear_subtitle"),M.hidden=!k,B.dataset.hasDetails=String(k),B.open=k;let J=r("play-toggle"),we=r("stop-button"),Y="player.play",W="Play",Le=!n.selected_media&&!n.queue?.some(Tt=>Tt.selected);e==="PLAYING"?(Y="player.pause",W="Pause"):e==="PAUSED"?(Y="player.resume",W="Resume"):e==="LOADING"?(W="Starting\u2026",Le=!0):e==="STOPPING"&&(W="Stopping\u2026",Le=!0);let Ke=e==="LOADING"||e==="STOPPING";J.dataset.command=Y,L(J,Ke?"loader-circle":e==="PLAYING"?"pause":"play",W,Ke),J.disabled=!b||T||Le||h(Y),we.disabled=!b||T||!n.has_session&&e!=="LOADING"||e==="STOPPING"||h("player.stop");let ce=r("artwork"),Xe=r("artwork-placeholder"),Ze=n.artwork_id?`/api/artwork/${encodeURIComponent(n.artwork_id)}.jpg`:"";Ze?(ce.src=Ze,ce.hidden=!1,Xe.hidden=!0):(ce.removeAttribute("src"),ce.hidden=!0,Xe.hidden=!1)}function de(){let e=n.policy||{},t=n.active_device_id||n.selected_device_id,a=n.devices.find(i=>i.id===t)?.protocol==="DLNA";r("loop").checked=!!e.LoopSelected,r("autoplay").checked=!!e.AutoPlayNext,r("same-type").checked=!!e.AutoPlaySameType,r("gapless").checked=!!e.GaplessEnabled,r("image-duration").value=String(Oe(e.ImageDurationSeconds??10)),r("same-type").disabled=!e.AutoPlayNext,r("gapless").disabled=!e.AutoPlayNext||!a}function Et(e){let t=n.queue.find(i=>i.selected)?.id||"";n.selected_media=!0,n.selected_media_name=e.name,n.media_type=e.media_kind,n.artwork_id="",O(),j();let a=u("library.play",{root_id:_,entry_id:e.id});w=a?{requestID:a,previousCurrentID:t}:null}function Nt(e){u("library.select_subtitle",{root_id:_,entry_id:e.id})&&(n.selected_subtitle=!0,n.selected_subtitle_name=e.name,O())}function Ct(){q(),Q(),O(),de(),F.length&&j()}function Ye(e){Object.assign(n,e),n.artwork_id=e.artwork_id??"",n.selected_media_name=e.selected_media_name??"",n.active_media_name=e.active_media_name??"",n.playback_state=e.playback_state??n.playback_state,n.policy=e.policy??n.policy,n.revision=e.revision??n.revision,Ct()}function _e(){dt?V("Incompatible server","error"):(pe.setItem("go2tv-protocol-reload","1"),X.reload())}function Fe(e){if(e.protocol_version!==1){_e();return}let t=e.payload||{};switch(e.type){case"state.snapshot":Ye(t);break;case"state.devices":n.revision=t.revision??n.revision,n.devices=t.devices||[],q(),de();break;case"state.queue":n.revision=t.revision??n.revision,n.queue=t.queue||[],Q();break;case"state.playback":let a={revision:t.revision??n.revision,playback_state:t.state??n.playback_state,position:t.position??n.position,duration:t.duration??n.duration,volume:t.volume??n.volume,muted:t.muted??n.muted,has_session:t.has_session??n.has_session},i=n.position!==a.position||n.duration!==a.duration,l=["playback_state","volume","muted","has_session"].some(s=>n[s]!==a[s]),o=n.playback_state!==a.playback_state;Object.assign(n,a),l?O():i&&le(),o&&Q();break;case"state.selection":let d=t.media!==void 0&&t.media!==n.selected_media||t.media_name!==void 0&&t.media_name!==n.selected_media_name||t.media_type!==void 0&&t.media_type!==n.media_type;Object.assign(n,{revision:t.revision??n.revision,selected_device_id:t.device_id??n.selected_device_id,selected_media:t.media??n.selected_media,selected_media_name:t.media_name??n.selected_media_name,selected_subtitle:t.subtitle??n.selected_subtitle,selected_subtitle_name:t.subtitle_name??n.selected_subtitle_name,transcode:t.transcode??n.transcode,media_type:t.media_type??n.media_type,artwork_id:t.artwork_id??n.artwork_id}),q(),O(),de(),d&&j();break;case"state.policy":n.revision=t.revision??n.revision,n.policy=t.policy||n.policy,de();break;case"pending":m.has(e.id)||m.set(e.id,null),D(m.get(e.id));break;case"ack":{let s=m.get(e.id);m.delete(e.id),n.revision=t.revision??n.revision,s?.type==="queue.add_many"&&gt(t,s.truncated||0),D(s);break}case"error":{let s=m.get(e.id),p=w?.requestID===e.id;if(m.delete(e.id),n.revision=t.revision??n.revision,t.code==="conflict"&&s&&s.attempt<2){let g=u(s.type,s.payload,s.attempt+1);g&&s.truncated&&(m.get(g).truncated=s.truncated),p&&(w=g?{...w,requestID:g}:null);break}p&&(w=null),x(t.code==="conflict"?"The app kept changing. Please try that action again.":t.message||t.code||"Request failed","error"),D(s);break}case"toast":x(t.message,t.level);break;case"server.shutdown":T=!0,b=!1,m.clear(),V("Server stopped","error"),D();break}}function ze(){Ne(ae),m.clear(),w=null,b=!1,D(),V("Connecting\u2026"),U=new Se(`${X.protocol==="https:"?"wss":"ws"}://${X.host}/api/ws`),U.addEventListener("open",()=>{b=!0,V("Connected","connected"),D()}),U.addEventListener("close",()=>{b=!1,m.clear(),w=null,D(),T||V("Reconnecting\u2026","error"),ae=me(He,1e3)}),U.addEventListener("message",e=>{try{Fe(JSON.parse(e.data))}catch{x("Invalid server message","error")}})}async function He(){Ne(ae);try{let e=await K("/api/bootstrap",{headers:{Accept:"application/json"}}),t=await e.json();if(!e.ok)throw new Error;if(t.protocol_version!==1){_e();return}if(ve&&t.assets_hash!==ve){X.reload();return}ne=!!t.features?.transcode,he!==(t.instance_id||"")&&await It(t),T=!1,ze()}catch{ae=me(He,2e3)}}async function Pt(e,t){let a="";do{let i=new URLSearchParams({root_id:_,limit:"200"});e&&i.set("parent_id",e),a&&i.set("cursor",a);let l=await K(`/api/library?${i}`,{headers:{Accept:"application/json"}}),o=await l.json();if(!l.ok)return"";let d=(o.entries||[]).find(s=>s.kind==="directory"&&s.name===t);if(d)return d.id;a=o.cursor||""}while(a);return""}async function It(e){z=e.limits?.queue_items||z;let t=[...v.children].find(o=>o.value===_)?.textContent;v.replaceChildren();for(let o of e.roots||[])v.append(De(o.id,o.name));let a=[...v.children].find(o=>o.textContent===t);a&&(v.value=a.value),_=v.value;let i=f;f=[];let l="";if(a)for(let o of i){let d=await Pt(l,o.name);if(!d)break;f.push({id:d,name:o.name}),l=d}he=e.instance_id||"",await P(l)}function u(e,t={},a=0){if(U?.readyState!==Se.OPEN){x("Not connected","error");return}let i=String(++lt),l={...t};return delete l.expected_revision,m.set(i,{type:e,payload:l,attempt:a}),D(m.get(i)),U.send(JSON.stringify({protocol_version:1,type:e,id:i,payload:{...l,expected_revision:n.revision}})),i}function At(){be.replaceChildren();let e=C("Library",()=>{f=[],P()});f.length||(e.ariaCurrent="page"),be.append(e);for(let[t,a]of f.entries()){let i=C(a.name,()=>{f=f.slice(0,t+1),P(a.id)});t===f.length-1&&(i.ariaCurrent="page"),be.append(i)}if(Z.hidden=!f.length,f.length){let t=f.length>1?f[f.length-2].name:"Library";L(Z,"arrow-left",`Up to ${t}`)}}function j(){G.replaceChildren();let e=Me();if(xt(Ge(e).length),!e.length){let t=c.createElement("li");t.className="empty-state",t.textContent=r("library-filter").value.trim()?"No matches in this folder.":"This folder is empty.",G.append(t),Qe();return}for(let t of e){let a=c.createElement("li"),i=c.createElement("div"),l=c.createElement("div"),o=c.createElement("strong"),d=c.createElement("span");a.className="library-row";let s=t.kind!=="directory"&&!re(t.name)&&n.selected_media&&t.name===n.selected_media_name;if(a.dataset.selected=String(s),s&&(a.ariaCurrent="true"),i.className="entry-main",l.className="entry-copy",o.className="entry-name",o.textContent=t.name,o.title=t.name,d.className="entry-meta",d.textContent=bt(t),l.append(o,d),t.thumbnail_url)i.append(_t(t));else{let p=c.createElement("span");p.className=t.kind==="directory"?"entry-icon folder-icon":"entry-icon",p.ariaHidden="true",t.kind!=="directory"&&(p.textContent="CC"),i.append(p)}i.append(l),a.append(i),t.kind==="directory"?a.append(ie(C("Open",()=>{f.push({id:t.id,name:t.name}),P(t.id)},{className:"primary-action"}))):re(t.name)?a.append(ie(C("Use subtitle",()=>Nt(t),{className:"primary-action"}))):a.append(ie(C("Play",()=>Et(t),{className:"primary-action icon-action",icon:"play",title:"Play",ariaLabel:`Play ${t.name}`}),C("Add to playlist",()=>u("queue.add",{root_id:_,entry_id:t.id}),{className:"icon-action",icon:"list-plus",title:"Add to playlist",ariaLabel:`Add ${t.name} to playlist`}))),G.append(a)}Qe()}function xt(e){let t=e?`Add ${e} listed ${e===1?"file":"files"} to playlist`:"Add listed files to playlist";ee.disabled=!e,ee.title=t,ee.ariaLabel=t,Ce.hidden=!e,Ce.textContent=e?e>999?"999+":String(e):""}function Qe(){if(!ye)return;let e=c.createElement("li");e.className="browser-nav";let t=C("Load more",()=>{t.disabled=!0,P(Pe,ye,!0)});e.append(t),G.append(e)}async function P(e="",t="",a=!1){let i=new URLSearchParams({root_id:_,limit:"200"});if(e&&i.set("parent_id",e),t&&i.set("cursor",t),!a){G.replaceChildren();let l=c.createElement("li");l.className="empty-state loading-state",l.textContent="Loading folder\u2026",G.append(l)}try{let l=await K(`/api/library?${i}`,{headers:{Accept:"application/json"}}),o=await l.json();if(!l.ok)throw new Error(o.error||"Browse failed");F=(a?[...F,...o.entries||[]]:o.entries||[]).sort(vt),Pe=e,ye=o.cursor||"",At(),j()}catch(l){x(l.message,"error"),a&&j()}}function Dt(e=""){e==="loop"&&r("loop").checked?(r("autoplay").checked=!1,r("same-type").checked=!1,r("gapless").checked=!1):e==="autoplay"&&r("autoplay").checked&&(r("loop").checked=!1);let t=r("autoplay").checked,a=Oe(r("image-duration").value);r("image-duration").value=String(a),u("playback.policy",{policy:{LoopSelected:r("loop").checked,AutoPlayNext:t,AutoPlaySameType:t&&r("same-type").checked,GaplessEnabled:t&&r("gapless").checked,ImageDurationSeconds:a}})}async function qt(){let e=await K("/api/bootstrap",{headers:{Accept:"application/json"}}),t=await e.json();if(!e.ok)throw new Error(t.error||"Bootstrap failed");if(t.protocol_version!==1){_e();return}pe.removeItem("go2tv-protocol-reload"),ve=t.assets_hash||"",he=t.instance_id||"",ne=!!t.features?.transcode,z=t.limits?.queue_items||z,Ye(t.snapshot),v.replaceChildren();for(let a of t.roots||[])v.append(De(a.id,a.name));_=v.value,await P(),ze()}v.addEventListener("change",()=>{_=v.value,f=[],P()}),Z.addEventListener("click",()=>{f.length&&(f.pop(),P(f.at(-1)?.id||""))}),ee.addEventListener("click",()=>{let e=Ge(Me());if(!e.length||h("queue.add_many"))return;let t=e.slice(0,z),a=u("queue.add_many",{root_id:_,entry_ids:t.map(l=>l.id)}),i=a&&m.get(a);i&&(i.truncated=e.length-t.length)}),r("refresh").addEventListener("click",()=>u("devices.refresh")),r("queue-clear").addEventListener("click",()=>u("queue.clear"));let Je,We=()=>{let e=ue.scrollY>=400;e!==Je&&(Je=e,te.dataset.visible=String(e),te.ariaHidden=String(!e),te.tabIndex=e?0:-1)};ue.addEventListener("scroll",We,{passive:!0}),te.addEventListener("click",()=>ue.scrollTo({top:0,behavior:"smooth"})),We(),I.addEventListener("click",()=>{N=!N,q()}),c.addEventListener("click",e=>{N&&!e.composedPath().includes(rt)&&(N=!1,q())}),c.addEventListener("keydown",e=>{N&&e.key==="Escape"&&(N=!1,q(),I.focus())});for(let e of c.querySelectorAll("[data-command]"))e.addEventListener("click",()=>u(e.dataset.command));r("seek").addEventListener("input",e=>{H=Math.min(Math.max(0,Number(e.target.value)||0),n.duration||0),le()}),r("seek").addEventListener("change",e=>{H=Number(e.target.value);let t=u("player.seek",{seconds:H});H=null,t||le()}),r("volume-down").addEventListener("click",()=>u("player.volume",{delta:-1})),r("volume-up").addEventListener("click",()=>u("player.volume",{delta:1})),r("mute").addEventListener("click",()=>u("player.mute",{muted:!n.muted})),r("transcode").addEventListener("change",e=>u("player.transcode",{enabled:e.target.checked})),r("subtitle-clear").addEventListener("click",()=>u("library.clear_subtitle")),r("library-filter").addEventListener("input",j),r("artwork").addEventListener("error",()=>{r("artwork").hidden=!0,r("artwork-placeholder").hidden=!1}),r("artwork-modal-image").addEventListener("error",()=>{x("Artwork unavailable","error"),ke()}),r("artwork-modal-close").addEventListener("click",ke),r("artwork-modal").addEventListener("click",e=>{e.target===r("artwork-modal")&&ke()});for(let e of["loop","autoplay","same-type","gapless","image-duration"])r(e).addEventListener("change",()=>Dt(e));return r("theme-toggle").addEventListener("click",()=>{S=oe[(oe.indexOf(S)+1)%oe.length],Ee.setItem("go2tv-theme",S),ge()}),Ue.addEventListener("change",()=>{S==="auto"&&ge()}),ge(),qt().catch(e=>{V("Unavailable","error"),x(e.message,"error")}),{state:n,pending:m,handle:Fe,send:u,browse:P}}et({document,window,fetch,WebSocket,location,sessionStorage,localStorage,matchMedia,setTimeout,clearTimeout});
@@ -24,6 +24,7 @@ import (
 	"go2tv.app/go2tv/v2/internal/mediaartwork"
 	"go2tv.app/go2tv/v2/internal/mediamodel"
 	"go2tv.app/go2tv/v2/internal/playback"
+	"go2tv.app/go2tv/v2/internal/resume"
 	"go2tv.app/go2tv/v2/metadata"
 	"go2tv.app/go2tv/v2/utils"
 )
@@ -32,6 +33,10 @@ const csp = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inl
 
 const maxArtworkRefs = controller.MaxQueueItems + 1
 
+// maxGroupToleranceMS bounds the drift a group accepts; past a minute the
+// members are no longer playing together in any useful sense.
+const maxGroupToleranceMS = 60_000
+
 type Handler struct {
 	cfg          Config
 	hub          *hub
@@ -39,6 +44,16 @@ type Handler struct {
 	artMu        sync.RWMutex
 	artworkRefs  map[string]controller.ArtworkLoader
 	artworkOrder []string
+	state        *stateStore
+	historyMu    sync.Mutex
+	historyRefs  map[library.Location]historyRef
+}
+
+// historyRef is a saved location signed for this process; a blank rootID
+// marks one that no longer resolves.
+type historyRef struct {
+	rootID  string
+	entryID string
 }
 
 func New(cfg Config) (*Handler, error) {
@@ -55,13 +70,59 @@ func New(cfg Config) (*Handler, error) {
 		return nil, err
 	}
 	h := &Handler{cfg: cfg, instanceID: hex.EncodeToString(instance), artworkRefs: make(map[string]controller.ArtworkLoader)}
-	h.hub = newHub(cfg.Controller, h.command)
+	h.hub = newHub(cfg.Controller, h.command, h.snapshotDTO)
+	if cfg.StateFile != "" {
+		h.state = newStateStore(cfg.StateFile)
+		h.restoreState()
+		go h.persistState()
+	}
 	return h, nil
 }
 
-func (h *Handler) Close()           { h.hub.close() }
+func (h *Handler) Close() {
+	h.hub.close()
+	if h.state != nil {
+		h.state.stopOnce.Do(func() { close(h.state.stop) })
+		<-h.state.done
+	}
+}
+
 func (h *Handler) ServesBootstrap() {}
 
+type readOnlyKey struct{}
+
+// ReadOnly marks a request as authorized to observe but not control playback.
+// The security layer applies it to requests made with read-scoped tokens.
+func (h *Handler) ReadOnly(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), readOnlyKey{}, true))
+}
+
+type credentialKey struct{}
+
+// Credential attaches the check that the request's credential is still valid.
+// The security layer applies it so RecheckCredentials can drop WebSocket
+// clients whose token was revoked or whose login was signed out.
+func (h *Handler) Credential(r *http.Request, valid func() bool) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), credentialKey{}, valid))
+}
+
+// RecheckCredentials closes WebSocket clients whose credential no longer
+// validates. The security layer calls it after the auth file changes.
+func (h *Handler) RecheckCredentials() { h.hub.recheck() }
+
+func credential(ctx context.Context) func() bool {
+	valid, _ := ctx.Value(credentialKey{}).(func() bool)
+	return valid
+}
+
+// WebSocketClients reports how many WebSocket clients are connected.
+func (h *Handler) WebSocketClients() int { return h.hub.clientCount() }
+
+func readOnly(ctx context.Context) bool {
+	value, _ := ctx.Value(readOnlyKey{}).(bool)
+	return value
+}
+
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	securityHeaders(w.Header())
 	w.Header().Set("Cache-Control", "no-store")
@@ -104,6 +165,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.bootstrap(w, r)
 	case r.URL.Path == "/api/library":
 		h.browse(w, r)
+	case r.URL.Path == "/api/library/search":
+		h.search(w, r)
+	case r.URL.Path == "/api/library/recent":
+		h.recent(w, r)
+	case r.URL.Path == "/api/library/details":
+		h.details(w, r)
 	case r.URL.Path == "/api/thumbnail":
 		h.libraryArtwork(w, r, true)
 	case r.URL.Path == "/api/media-artwork":
@@ -112,6 +179,10 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.artwork(w, r)
 	case r.URL.Path == "/api/ws":
 		h.hub.serve(w, r)
+	case r.URL.Path == OpenAPIPath:
+		h.openAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, APIPrefix):
+		h.rest(w, r)
 	default:
 		http.NotFound(w, r)
 	}
@@ -144,7 +215,7 @@ func (h *Handler) bootstrap(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	roots := h.cfg.Library.Roots()
-	result := bootstrapDTO{ServerVersion: h.cfg.Version, ProtocolVersion: ProtocolVersion, AssetsHash: assetsHash, InstanceID: h.instanceID, ManagedByGUI: h.cfg.ManagedByGUI, Snapshot: safeSnapshot(snapshot), Limits: map[string]int{"ws_message_bytes": maxMessageBytes, "ws_clients": maxClients, "ws_clients_per_ip": maxClientsPerIP, "queue_items": controller.MaxQueueItems, "library_page": library.MaxLimit}, Features: map[string]bool{"websocket": true, "artwork": true, "transcode": h.cfg.TranscodeAvailable, "gapless": true}}
+	result := bootstrapDTO{ServerVersion: h.cfg.Version, ProtocolVersion: ProtocolVersion, AssetsHash: assetsHash, InstanceID: h.instanceID, ManagedByGUI: h.cfg.ManagedByGUI, Snapshot: h.snapshotDTO(snapshot), Limits: map[string]int{"ws_message_bytes": maxMessageBytes, "ws_clients": maxClients, "ws_clients_per_ip": maxClientsPerIP, "queue_items": controller.MaxQueueItems, "library_page": library.MaxLimit}, Features: map[string]bool{"websocket": true, "artwork": true, "transcode": h.cfg.TranscodeAvailable, "gapless": true, "library_index": h.cfg.Library.Indexed(), "library_details": h.cfg.Library.Probing()}}
 	for _, root := range roots {
 		result.Roots = append(result.Roots, rootDTO{ID: root.ID, Name: root.Name})
 	}
@@ -158,15 +229,11 @@ func (h *Handler) browse(w http.ResponseWriter, r *http.Request) {
 	}
 	q := r.URL.Query()
 	for key, values := range q {
-		if (key != "root_id" && key != "parent_id" && key != "cursor" && key != "limit") || len(values) != 1 {
+		if (key != "root_id" && key != "parent_id" && key != "cursor" && key != "limit" && key != "filter") || len(values) != 1 {
 			apiError(w, http.StatusBadRequest, "invalid_request")
 			return
 		}
 	}
-	if len(q) > 4 {
-		apiError(w, http.StatusBadRequest, "invalid_request")
-		return
-	}
 	limit := 0
 	if q.Get("limit") != "" {
 		var err error
@@ -176,28 +243,137 @@ func (h *Handler) browse(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	page, err := h.cfg.Library.Browse(q.Get("root_id"), q.Get("parent_id"), q.Get("cursor"), limit)
+	page, err := h.cfg.Library.BrowseFiltered(q.Get("root_id"), q.Get("parent_id"), q.Get("cursor"), limit, library.Filter(q.Get("filter")))
 	if err != nil {
 		apiError(w, http.StatusBadRequest, libraryCode(err))
 		return
 	}
 	entries := make([]entryDTO, 0, len(page.Entries))
 	for _, entry := range page.Entries {
-		dto := entryDTO{ID: entry.ID, Name: entry.Name, Kind: entry.Kind}
-		if entry.Kind == "file" {
-			kind := mediamodel.KindForPath(entry.Name)
-			if kind != mediamodel.MediaKindUnknown {
-				dto.MediaKind = string(kind)
-				dto.ThumbnailURL = libraryArtworkURL("/api/thumbnail", q.Get("root_id"), entry.ID)
-				dto.ArtworkURL = libraryArtworkURL("/api/media-artwork", q.Get("root_id"), entry.ID)
-			}
+		entries = append(entries, libraryEntry(q.Get("root_id"), entry))
+	}
+	writeJSON(w, http.StatusOK, libraryPageDTO{Entries: entries, Cursor: page.Cursor})
+}
+
+func (h *Handler) search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	q := r.URL.Query()
+	for key, values := range q {
+		if (key != "q" && key != "cursor" && key != "limit") || len(values) != 1 {
+			apiError(w, http.StatusBadRequest, "invalid_request")
+			return
+		}
+	}
+	limit := 0
+	if q.Get("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(q.Get("limit"))
+		if err != nil {
+			apiError(w, http.StatusBadRequest, "invalid_request")
+			return
+		}
+	}
+	page, err := h.cfg.Library.Search(q.Get("q"), q.Get("cursor"), limit)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, libraryCode(err))
+		return
+	}
+	results := make([]searchResultDTO, 0, len(page.Results))
+	for _, result := range page.Results {
+		results = append(results, searchResultDTO{RootID: result.RootID, Parent: result.Parent, entryDTO: libraryEntry(result.RootID, result.Entry)})
+	}
+	writeJSON(w, http.StatusOK, searchPageDTO{Entries: results, Cursor: page.Cursor})
+}
+
+func (h *Handler) recent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	q := r.URL.Query()
+	for key, values := range q {
+		if key != "limit" || len(values) != 1 {
+			apiError(w, http.StatusBadRequest, "invalid_request")
+			return
 		}
-		entries = append(entries, dto)
 	}
-	writeJSON(w, http.StatusOK, struct {
-		Entries []entryDTO `json:"entries"`
-		Cursor  string     `json:"cursor,omitempty"`
-	}{entries, page.Cursor})
+	limit := 0
+	if q.Get("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(q.Get("limit"))
+		if err != nil {
+			apiError(w, http.StatusBadRequest, "invalid_request")
+			return
+		}
+	}
+	found, err := h.cfg.Library.RecentlyAdded(limit)
+	if errors.Is(err, library.ErrNoIndex) {
+		apiError(w, http.StatusNotFound, "index_disabled")
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusBadRequest, libraryCode(err))
+		return
+	}
+	results := make([]searchResultDTO, 0, len(found))
+	for _, result := range found {
+		results = append(results, searchResultDTO{RootID: result.RootID, Parent: result.Parent, entryDTO: libraryEntry(result.RootID, result.Entry)})
+	}
+	writeJSON(w, http.StatusOK, searchPageDTO{Entries: results})
+}
+
+// details probes a library file, or returns what an earlier probe found while
+// the file is unchanged.
+func (h *Handler) details(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	rootID, entryID, ok := mediaQuery(r.URL.Query())
+	if !ok {
+		apiError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	info, err := h.cfg.Library.Details(rootID, entryID)
+	switch {
+	case errors.Is(err, library.ErrNoProbe):
+		apiError(w, http.StatusNotFound, "probe_unavailable")
+	case errors.Is(err, library.ErrClosed), errors.Is(err, library.ErrInvalidRoot), errors.Is(err, library.ErrInvalidEntry),
+		errors.Is(err, library.ErrNotRegular), errors.Is(err, library.ErrUnsupportedExtension):
+		apiError(w, http.StatusNotFound, "not_found")
+	case err != nil:
+		apiError(w, http.StatusUnprocessableEntity, "probe_failed")
+	default:
+		writeJSON(w, http.StatusOK, mediaDetails(info))
+	}
+}
+
+func mediaDetails(info library.MediaInfo) mediaDTO {
+	return mediaDTO{
+		Duration: info.Duration, Width: info.Width, Height: info.Height,
+		VideoCodec: info.VideoCodec, AudioCodec: info.AudioCodec, AudioLanguages: info.AudioLanguages,
+		Subtitles: info.Subtitles, Title: info.Title, Artist: info.Artist, Album: info.Album,
+	}
+}
+
+func libraryEntry(rootID string, entry library.Entry) entryDTO {
+	dto := entryDTO{ID: entry.ID, Name: entry.Name, Kind: entry.Kind}
+	if entry.Kind == "file" {
+		kind := mediamodel.KindForPath(entry.Name)
+		if kind != mediamodel.MediaKindUnknown {
+			dto.MediaKind = string(kind)
+			dto.ThumbnailURL = libraryArtworkURL("/api/thumbnail", rootID, entry.ID)
+			dto.ArtworkURL = libraryArtworkURL("/api/media-artwork", rootID, entry.ID)
+		}
+		dto.Watched, dto.Position, dto.Duration, dto.PlayCount = entry.Progress.Watched, entry.Progress.Position, entry.Progress.Duration, entry.Progress.Plays
+		if entry.Media != nil {
+			dto.Media = mediaDetails(*entry.Media)
+		}
+	}
+	return dto
 }
 
 func libraryArtworkURL(path, rootID, entryID string) string {
@@ -215,35 +391,41 @@ func (h *Handler) libraryArtwork(w http.ResponseWriter, r *http.Request, thumbna
 		apiError(w, http.StatusBadRequest, "invalid_request")
 		return
 	}
-	file, meta, err := h.cfg.Library.OpenMedia(rootID, entryID)
-	if err != nil {
+	data, err := h.libraryArtworkData(r.Context(), rootID, entryID, thumbnail)
+	if err != nil || len(data) == 0 {
 		apiError(w, http.StatusNotFound, "not_found")
 		return
 	}
+	serveJPEG(w, r, data, "private, no-cache")
+}
+
+// Thumbnail returns the JPEG library thumbnail of a media entry, from the
+// same cache the library artwork routes use.
+func (h *Handler) Thumbnail(ctx context.Context, rootID, entryID string) ([]byte, error) {
+	return h.libraryArtworkData(ctx, rootID, entryID, true)
+}
+
+func (h *Handler) libraryArtworkData(ctx context.Context, rootID, entryID string, thumbnail bool) ([]byte, error) {
+	file, meta, err := h.cfg.Library.OpenMedia(rootID, entryID)
+	if err != nil {
+		return nil, err
+	}
 	defer file.Close()
 	kind := mediamodel.KindForPath(meta.Name)
 	if kind == mediamodel.MediaKindUnknown {
-		apiError(w, http.StatusNotFound, "not_found")
-		return
+		return nil, library.ErrUnsupportedExtension
 	}
 	request := h.mediaArtworkRequest(rootID, entryID, meta.Name, kind, file)
-	var data []byte
 	if thumbnail {
-		data, err = mediaartwork.ThumbnailWithResolver(r.Context(), request, func(ctx context.Context) (*metadata.ArtworkAsset, error) {
+		return mediaartwork.ThumbnailWithResolver(ctx, request, func(ctx context.Context) (*metadata.ArtworkAsset, error) {
 			return h.resolveArtworkRequest(ctx, request)
 		})
-	} else {
-		var asset *metadata.ArtworkAsset
-		asset, err = h.resolveArtworkRequest(r.Context(), request)
-		if asset != nil {
-			data = asset.Data
-		}
 	}
-	if err != nil || len(data) == 0 {
-		apiError(w, http.StatusNotFound, "not_found")
-		return
+	asset, err := h.resolveArtworkRequest(ctx, request)
+	if err != nil || asset == nil {
+		return nil, err
 	}
-	serveJPEG(w, r, data, "private, no-cache")
+	return asset.Data, nil
 }
 
 func mediaQuery(query url.Values) (string, string, bool) {
@@ -313,18 +495,68 @@ func (h *Handler) loadArtwork(ctx context.Context, id string) ([]byte, error) {
 
 func safeSnapshot(s controller.Snapshot) snapshotDTO {
 	result := snapshotDTO{Revision: s.Revision, SelectedDeviceID: s.SelectedDeviceID, ActiveDeviceID: s.ActiveDeviceID, SelectedMedia: s.SelectedMedia != "", SelectedMediaName: s.SelectedMedia, ActiveMediaName: s.ActiveMediaName, SelectedSubtitle: s.SelectedSubtitle != "", SelectedSubtitleName: s.SelectedSubtitle, Transcode: s.Transcode, HasSession: s.HasSession, PlaybackState: s.PlaybackState, Position: s.Position, Duration: s.Duration, Volume: s.Volume, Muted: s.Muted, MediaType: string(s.MediaType), ArtworkID: s.ArtworkID, Policy: s.Policy}
+	result.SleepTimer = sleepTimerDTO{Mode: string(s.SleepTimer.Mode), Deadline: s.SleepTimer.Deadline}
+	if s.SleepTimer.Mode == controller.SleepOff {
+		result.SleepTimer.Mode = sleepModeOff
+	}
+	result.ScheduledStart = scheduledStartDTO{At: s.ScheduledStart.At, DeviceID: s.ScheduledStart.DeviceID, DeviceLabel: s.ScheduledStart.DeviceName}
 	result.Devices = make([]deviceDTO, 0, len(s.Devices))
 	for _, d := range s.Devices {
 		caps := []string{}
 		if d.AudioOnly {
 			caps = append(caps, "audio_only")
 		}
+		if slices.ContainsFunc(s.Groups, func(group controller.GroupInfo) bool { return group.ID == d.ID }) {
+			caps = append(caps, "group")
+		}
 		result.Devices = append(result.Devices, deviceDTO{ID: d.ID, Label: d.Name, Protocol: d.Protocol, Capabilities: caps})
 	}
 	result.Queue = make([]queueDTO, 0, len(s.Queue))
 	for _, q := range s.Queue {
 		result.Queue = append(result.Queue, queueDTO{ID: q.ID, Name: q.Name, Parent: q.Parent, Kind: string(q.MediaKind), Selected: q.IsSelected, Active: q.IsActive})
 	}
+	result.Sessions = make([]sessionDTO, 0, len(s.Sessions))
+	for _, session := range s.Sessions {
+		result.Sessions = append(result.Sessions, sessionDTO{ID: session.ID, DeviceID: session.DeviceID, DeviceLabel: session.DeviceName, Revision: session.Revision, HasSession: session.HasSession, PlaybackState: session.PlaybackState, ActiveMediaName: session.ActiveMediaName, Position: session.Position, Duration: session.Duration, Volume: session.Volume, Muted: session.Muted})
+	}
+	result.Groups = make([]groupDTO, 0, len(s.Groups))
+	for _, group := range s.Groups {
+		result.Groups = append(result.Groups, groupDTO{ID: group.ID, Label: group.Name, DeviceIDs: slices.Clone(group.DeviceIDs), ToleranceMS: group.Tolerance.Milliseconds()})
+	}
+	result.ContinueWatching = []historyDTO{}
+	return result
+}
+
+// snapshotDTO is safeSnapshot plus the continue watching list, whose entries
+// need the library to be signed.
+func (h *Handler) snapshotDTO(s controller.Snapshot) snapshotDTO {
+	result := safeSnapshot(s)
+	result.ContinueWatching = h.continueWatching(s.ContinueWatching)
+	return result
+}
+
+// continueWatching signs saved positions for this process, dropping files
+// that are gone or outside the configured roots. Resolving opens each file, so
+// results are kept for as long as the entry stays in the list.
+func (h *Handler) continueWatching(entries []resume.Entry) []historyDTO {
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+	refs := make(map[library.Location]historyRef, len(entries))
+	result := make([]historyDTO, 0, len(entries))
+	for _, entry := range entries {
+		ref, ok := h.historyRefs[entry.Key]
+		if !ok {
+			if rootID, entryID, err := h.cfg.Library.Resolve(entry.Key); err == nil {
+				ref = historyRef{rootID: rootID, entryID: entryID}
+			}
+		}
+		refs[entry.Key] = ref
+		if ref.rootID == "" {
+			continue
+		}
+		result = append(result, historyDTO{RootID: ref.rootID, EntryID: ref.entryID, Name: entry.Name, Kind: entry.Kind, Position: entry.Position, Duration: entry.Duration, UpdatedAt: entry.UpdatedAt})
+	}
+	h.historyRefs = refs
 	return result
 }
 
@@ -335,9 +567,7 @@ func writeJSON(w http.ResponseWriter, status int, value any) {
 	_ = json.NewEncoder(w).Encode(value)
 }
 func apiError(w http.ResponseWriter, status int, code string) {
-	writeJSON(w, status, struct {
-		Error string `json:"error"`
-	}{code})
+	writeJSON(w, status, errorDTO{Error: code})
 }
 func methodNotAllowed(w http.ResponseWriter) {
 	apiError(w, http.StatusMethodNotAllowed, "method_not_allowed")
@@ -350,6 +580,10 @@ func libraryCode(err error) string {
 		return "cursor_limit"
 	case errors.Is(err, library.ErrInvalidLimit):
 		return "invalid_limit"
+	case errors.Is(err, library.ErrInvalidFilter):
+		return "invalid_filter"
+	case errors.Is(err, library.ErrInvalidQuery):
+		return "invalid_query"
 	default:
 		return "invalid_request"
 	}
@@ -366,21 +600,36 @@ func readStrict(raw []byte, dst any) error {
 	}
 	return nil
 }
-func expectedMutation(id string, revision *uint64) controller.Mutation {
-	return controller.Mutation{RequestID: id, ExpectedRevision: revision}
+func expectedMutation(message envelope, revision *uint64) controller.Mutation {
+	return controller.Mutation{RequestID: message.ID, ExpectedRevision: revision, Session: message.Session}
+}
+
+// sessionSnapshot reads the session a command targets. A device without a
+// session yet reads as an empty one, since the command will open it.
+func (h *Handler) sessionSnapshot(ctx context.Context, session string) (controller.Snapshot, error) {
+	snapshot, err := h.cfg.Controller.SessionSnapshot(ctx, session)
+	if errors.Is(err, controller.ErrNotFound) {
+		return controller.Snapshot{}, nil
+	}
+	return snapshot, err
 }
 func (h *Handler) command(ctx context.Context, message envelope) (controller.Result, map[string]any) {
 	var before controller.Snapshot
 	if h.cfg.Logger != nil && knownAction(message.Type) {
-		before, _ = h.cfg.Controller.Snapshot(ctx)
+		before, _ = h.sessionSnapshot(ctx, message.Session)
 	}
 	var result controller.Result
 	var extra map[string]any
-	// queue.add_many is dispatched here, not in executeCommand, because it is
-	// the only command that acknowledges with extra payload fields.
-	if message.Type == "queue.add_many" {
+	// queue.add_many, queue.import and groups.create are dispatched here, not
+	// in executeCommand, because they acknowledge with extra payload fields.
+	switch message.Type {
+	case "queue.add_many":
 		result, extra = h.queueAddMany(ctx, message)
-	} else {
+	case "queue.import":
+		result, extra = h.queueImport(ctx, message)
+	case "groups.create":
+		result, extra = h.createGroup(ctx, message)
+	default:
 		result = h.executeCommand(ctx, message)
 	}
 	if h.cfg.Logger == nil || !knownAction(message.Type) {
@@ -393,13 +642,34 @@ func (h *Handler) command(ctx context.Context, message envelope) (controller.Res
 		h.cfg.Logger.Warning("WebUI action failed: " + actionName(message.Type) + " (" + result.Message + ")")
 		return result, extra
 	}
-	snapshot, err := h.cfg.Controller.Snapshot(ctx)
+	snapshot, err := h.sessionSnapshot(ctx, message.Session)
 	if err == nil {
 		h.logAction(message.Type, before, snapshot)
 	}
 	return result, extra
 }
 
+func (h *Handler) createGroup(ctx context.Context, message envelope) (controller.Result, map[string]any) {
+	var p struct {
+		Name             string   `json:"name"`
+		DeviceIDs        []string `json:"device_ids"`
+		ToleranceMS      *int64   `json:"tolerance_ms"`
+		ExpectedRevision *uint64  `json:"expected_revision"`
+	}
+	if readStrict(message.Payload, &p) != nil || len(p.DeviceIDs) < 2 || p.ToleranceMS != nil && (*p.ToleranceMS < 1 || *p.ToleranceMS > maxGroupToleranceMS) {
+		return invalid(message.ID), nil
+	}
+	request := controller.GroupRequest{Mutation: expectedMutation(message, p.ExpectedRevision), Name: p.Name, DeviceIDs: p.DeviceIDs}
+	if p.ToleranceMS != nil {
+		request.Tolerance = time.Duration(*p.ToleranceMS) * time.Millisecond
+	}
+	result := h.cfg.Controller.CreateGroup(ctx, request)
+	if !result.OK() {
+		return result.Result, nil
+	}
+	return result.Result, map[string]any{"group_id": result.GroupID}
+}
+
 func (h *Handler) queueAddMany(ctx context.Context, message envelope) (controller.Result, map[string]any) {
 	var p struct {
 		RootID           string   `json:"root_id"`
@@ -424,7 +694,7 @@ func (h *Handler) queueAddMany(ctx context.Context, message envelope) (controlle
 	if len(refs) == 0 {
 		return invalid(message.ID), nil
 	}
-	result := h.cfg.Controller.AddQueueItems(ctx, controller.QueueAddManyRequest{Mutation: expectedMutation(message.ID, p.ExpectedRevision), Items: refs})
+	result := h.cfg.Controller.AddQueueItems(ctx, controller.QueueAddManyRequest{Mutation: expectedMutation(message, p.ExpectedRevision), Items: refs})
 	if !result.OK() {
 		return result.Result, nil
 	}
@@ -445,7 +715,7 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if readStrict(message.Payload, &p) != nil {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.Refresh(ctx, expectedMutation(message.ID, p.ExpectedRevision))
+		return h.cfg.Controller.Refresh(ctx, expectedMutation(message, p.ExpectedRevision))
 	case "devices.select":
 		var p struct {
 			DeviceID         string  `json:"device_id"`
@@ -454,31 +724,61 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if readStrict(message.Payload, &p) != nil {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.SelectDevice(ctx, expectedMutation(message.ID, p.ExpectedRevision), p.DeviceID)
-	case "library.play", "library.select_media", "library.select_subtitle":
+		return h.cfg.Controller.SelectDevice(ctx, expectedMutation(message, p.ExpectedRevision), p.DeviceID)
+	case "library.play":
 		var p struct {
 			RootID           string  `json:"root_id"`
 			EntryID          string  `json:"entry_id"`
+			Resume           bool    `json:"resume"`
 			ExpectedRevision *uint64 `json:"expected_revision"`
 		}
 		if readStrict(message.Payload, &p) != nil {
 			return invalid(message.ID)
 		}
-		if message.Type == "library.play" || message.Type == "library.select_media" {
+		ref, err := h.mediaRef(ctx, p.RootID, p.EntryID)
+		if err != nil {
+			return invalid(message.ID)
+		}
+		if p.Resume {
+			return h.cfg.Controller.QueueAndResume(ctx, expectedMutation(message, p.ExpectedRevision), ref)
+		}
+		return h.cfg.Controller.QueueAndPlay(ctx, expectedMutation(message, p.ExpectedRevision), ref)
+	case "library.mark":
+		var p struct {
+			RootID           string  `json:"root_id"`
+			EntryID          string  `json:"entry_id"`
+			Watched          *bool   `json:"watched"`
+			ExpectedRevision *uint64 `json:"expected_revision"`
+		}
+		if readStrict(message.Payload, &p) != nil || p.Watched == nil {
+			return invalid(message.ID)
+		}
+		locations, err := h.cfg.Library.Files(p.RootID, p.EntryID)
+		if err != nil {
+			return filesError(message.ID, err)
+		}
+		return h.cfg.Controller.MarkWatched(ctx, expectedMutation(message, p.ExpectedRevision), locations, *p.Watched)
+	case "library.select_media", "library.select_subtitle":
+		var p struct {
+			RootID           string  `json:"root_id"`
+			EntryID          string  `json:"entry_id"`
+			ExpectedRevision *uint64 `json:"expected_revision"`
+		}
+		if readStrict(message.Payload, &p) != nil {
+			return invalid(message.ID)
+		}
+		if message.Type == "library.select_media" {
 			ref, err := h.mediaRef(ctx, p.RootID, p.EntryID)
 			if err != nil {
 				return invalid(message.ID)
 			}
-			if message.Type == "library.play" {
-				return h.cfg.Controller.QueueAndPlay(ctx, expectedMutation(message.ID, p.ExpectedRevision), ref)
-			}
-			return h.selectMedia(ctx, expectedMutation(message.ID, p.ExpectedRevision), ref)
+			return h.selectMedia(ctx, expectedMutation(message, p.ExpectedRevision), ref)
 		}
 		ref, err := h.subtitleRef(p.RootID, p.EntryID)
 		if err != nil {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.SelectSubtitle(ctx, expectedMutation(message.ID, p.ExpectedRevision), ref)
+		return h.cfg.Controller.SelectSubtitle(ctx, expectedMutation(message, p.ExpectedRevision), ref)
 	case "library.clear_subtitle":
 		var p struct {
 			ExpectedRevision *uint64 `json:"expected_revision"`
@@ -486,7 +786,7 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if readStrict(message.Payload, &p) != nil {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.SelectSubtitle(ctx, expectedMutation(message.ID, p.ExpectedRevision), controller.SubtitleRef{})
+		return h.cfg.Controller.SelectSubtitle(ctx, expectedMutation(message, p.ExpectedRevision), controller.SubtitleRef{})
 	case "queue.add":
 		var p struct {
 			RootID           string  `json:"root_id"`
@@ -500,7 +800,7 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if err != nil {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.AddQueueItem(ctx, controller.QueueAddRequest{Mutation: expectedMutation(message.ID, p.ExpectedRevision), Media: ref}).Result
+		return h.cfg.Controller.AddQueueItem(ctx, controller.QueueAddRequest{Mutation: expectedMutation(message, p.ExpectedRevision), Media: ref}).Result
 	case "queue.select":
 		var p struct {
 			ItemID           string  `json:"item_id"`
@@ -509,7 +809,7 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if readStrict(message.Payload, &p) != nil {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.SelectQueueItem(ctx, expectedMutation(message.ID, p.ExpectedRevision), p.ItemID)
+		return h.cfg.Controller.SelectQueueItem(ctx, expectedMutation(message, p.ExpectedRevision), p.ItemID)
 	case "queue.remove":
 		var p struct {
 			ItemID           string  `json:"item_id"`
@@ -518,7 +818,7 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if readStrict(message.Payload, &p) != nil {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.RemoveQueueItem(ctx, expectedMutation(message.ID, p.ExpectedRevision), p.ItemID)
+		return h.cfg.Controller.RemoveQueueItem(ctx, expectedMutation(message, p.ExpectedRevision), p.ItemID)
 	case "queue.move":
 		var p struct {
 			ItemID           string  `json:"item_id"`
@@ -528,7 +828,7 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if readStrict(message.Payload, &p) != nil || p.Delta == nil || *p.Delta == 0 {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.MoveQueueItem(ctx, expectedMutation(message.ID, p.ExpectedRevision), p.ItemID, *p.Delta)
+		return h.cfg.Controller.MoveQueueItem(ctx, expectedMutation(message, p.ExpectedRevision), p.ItemID, *p.Delta)
 	case "queue.clear":
 		return h.simplePayload(ctx, message, h.cfg.Controller.ClearQueue)
 	case "player.play":
@@ -542,14 +842,14 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if readStrict(message.Payload, &p) != nil {
 			return invalid(message.ID)
 		}
-		if snapshot, err := h.cfg.Controller.Snapshot(ctx); err == nil && snapshot.HasSession && snapshot.PlaybackState == "PAUSED" {
+		if snapshot, err := h.sessionSnapshot(ctx, message.Session); err == nil && snapshot.HasSession && snapshot.PlaybackState == "PAUSED" {
 			if p.ItemID == "" || slices.ContainsFunc(snapshot.Queue, func(item controller.QueueItem) bool {
 				return item.ID == p.ItemID && item.IsActive
 			}) {
-				return h.cfg.Controller.Resume(ctx, expectedMutation(message.ID, p.ExpectedRevision))
+				return h.cfg.Controller.Resume(ctx, expectedMutation(message, p.ExpectedRevision))
 			}
 		}
-		return h.cfg.Controller.Play(ctx, controller.PlayRequest{Mutation: expectedMutation(message.ID, p.ExpectedRevision), QueueItemID: p.ItemID})
+		return h.cfg.Controller.Play(ctx, controller.PlayRequest{Mutation: expectedMutation(message, p.ExpectedRevision), QueueItemID: p.ItemID})
 	case "player.resume":
 		return h.simplePayload(ctx, message, h.cfg.Controller.Resume)
 	case "player.pause":
@@ -569,12 +869,12 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 			if *p.Delta != -1 && *p.Delta != 1 {
 				return invalid(message.ID)
 			}
-			return h.cfg.Controller.AdjustVolume(ctx, expectedMutation(message.ID, p.ExpectedRevision), *p.Delta)
+			return h.cfg.Controller.AdjustVolume(ctx, expectedMutation(message, p.ExpectedRevision), *p.Delta)
 		}
 		if *p.Volume < 0 || *p.Volume > 100 {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.SetVolume(ctx, expectedMutation(message.ID, p.ExpectedRevision), *p.Volume)
+		return h.cfg.Controller.SetVolume(ctx, expectedMutation(message, p.ExpectedRevision), *p.Volume)
 	case "player.mute":
 		var p struct {
 			Muted            *bool   `json:"muted"`
@@ -583,7 +883,7 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if readStrict(message.Payload, &p) != nil || p.Muted == nil {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.SetMute(ctx, expectedMutation(message.ID, p.ExpectedRevision), *p.Muted)
+		return h.cfg.Controller.SetMute(ctx, expectedMutation(message, p.ExpectedRevision), *p.Muted)
 	case "player.transcode":
 		var p struct {
 			Enabled          *bool   `json:"enabled"`
@@ -595,7 +895,7 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if *p.Enabled && !h.cfg.TranscodeAvailable {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.SetTranscode(ctx, expectedMutation(message.ID, p.ExpectedRevision), *p.Enabled)
+		return h.cfg.Controller.SetTranscode(ctx, expectedMutation(message, p.ExpectedRevision), *p.Enabled)
 	case "playback.policy":
 		var p struct {
 			Policy           *controller.Policy `json:"policy"`
@@ -604,7 +904,56 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if readStrict(message.Payload, &p) != nil || p.Policy == nil {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.SetPolicy(ctx, controller.PolicyRequest{Mutation: expectedMutation(message.ID, p.ExpectedRevision), Policy: *p.Policy})
+		return h.cfg.Controller.SetPolicy(ctx, controller.PolicyRequest{Mutation: expectedMutation(message, p.ExpectedRevision), Policy: *p.Policy})
+	case "player.sleep":
+		var p struct {
+			Mode             string  `json:"mode"`
+			Minutes          int     `json:"minutes"`
+			ExpectedRevision *uint64 `json:"expected_revision"`
+		}
+		if readStrict(message.Payload, &p) != nil || !slices.Contains(sleepModes, p.Mode) {
+			return invalid(message.ID)
+		}
+		request := controller.SleepTimerRequest{Mutation: expectedMutation(message, p.ExpectedRevision), Mode: controller.SleepMode(p.Mode)}
+		if p.Mode == sleepModeOff {
+			request.Mode = controller.SleepOff
+		}
+		if request.Mode == controller.SleepAfterTime {
+			if p.Minutes <= 0 || time.Duration(p.Minutes)*time.Minute > controller.MaxSleepDuration {
+				return invalid(message.ID)
+			}
+			request.Duration = time.Duration(p.Minutes) * time.Minute
+		}
+		return h.cfg.Controller.SetSleepTimer(ctx, request)
+	case "player.schedule":
+		var p struct {
+			At               string  `json:"at"`
+			DeviceID         string  `json:"device_id"`
+			ExpectedRevision *uint64 `json:"expected_revision"`
+		}
+		if readStrict(message.Payload, &p) != nil {
+			return invalid(message.ID)
+		}
+		request := controller.ScheduleStartRequest{Mutation: expectedMutation(message, p.ExpectedRevision), DeviceID: p.DeviceID}
+		if p.At != "" {
+			at, err := time.Parse(time.RFC3339, p.At)
+			if err != nil {
+				return invalid(message.ID)
+			}
+			request.At = at
+		}
+		return h.cfg.Controller.ScheduleStart(ctx, request)
+	case "session.close":
+		return h.simplePayload(ctx, message, h.cfg.Controller.CloseSession)
+	case "groups.remove":
+		var p struct {
+			GroupID          string  `json:"group_id"`
+			ExpectedRevision *uint64 `json:"expected_revision"`
+		}
+		if readStrict(message.Payload, &p) != nil || p.GroupID == "" {
+			return invalid(message.ID)
+		}
+		return h.cfg.Controller.RemoveGroup(ctx, expectedMutation(message, p.ExpectedRevision), p.GroupID)
 	case "player.seek":
 		var p struct {
 			Seconds          *int    `json:"seconds"`
@@ -613,7 +962,16 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 		if readStrict(message.Payload, &p) != nil || p.Seconds == nil || *p.Seconds < 0 {
 			return invalid(message.ID)
 		}
-		return h.cfg.Controller.Seek(ctx, controller.SeekRequest{Mutation: expectedMutation(message.ID, p.ExpectedRevision), Seconds: *p.Seconds})
+		return h.cfg.Controller.Seek(ctx, controller.SeekRequest{Mutation: expectedMutation(message, p.ExpectedRevision), Seconds: *p.Seconds})
+	case "player.transfer":
+		var p struct {
+			DeviceID         string  `json:"device_id"`
+			ExpectedRevision *uint64 `json:"expected_revision"`
+		}
+		if readStrict(message.Payload, &p) != nil || p.DeviceID == "" {
+			return invalid(message.ID)
+		}
+		return h.cfg.Controller.Transfer(ctx, expectedMutation(message, p.ExpectedRevision), p.DeviceID)
 	default:
 		return invalid(message.ID)
 	}
@@ -622,8 +980,9 @@ func (h *Handler) executeCommand(ctx context.Context, message envelope) controll
 func knownAction(kind string) bool {
 	switch kind {
 	case "devices.refresh", "devices.select", "library.play", "library.select_media", "library.select_subtitle", "library.clear_subtitle",
-		"queue.add", "queue.add_many", "queue.select", "queue.remove", "queue.move", "queue.clear", "player.play", "player.resume",
-		"player.pause", "player.stop", "player.volume", "player.mute", "player.transcode", "playback.policy", "player.seek":
+		"queue.add", "queue.add_many", "queue.import", "queue.select", "queue.remove", "queue.move", "queue.clear", "player.play", "player.resume",
+		"player.pause", "player.stop", "player.volume", "player.mute", "player.transcode", "playback.policy", "player.seek",
+		"player.sleep", "player.schedule":
 		return true
 	default:
 		return false
@@ -665,6 +1024,8 @@ func (h *Handler) logAction(kind string, before, snapshot controller.Snapshot) {
 		if added := len(snapshot.Queue) - len(before.Queue); added > 0 {
 			message = fmt.Sprintf("Media added to queue: %d files", added)
 		}
+	case "queue.import":
+		message = fmt.Sprintf("Playlist imported: %d items added", max(len(snapshot.Queue)-len(before.Queue), 0))
 	case "queue.select":
 		message = "Queue item selected: " + snapshot.SelectedMedia
 	case "queue.remove":
@@ -701,7 +1062,7 @@ func (h *Handler) logAction(kind string, before, snapshot controller.Snapshot) {
 }
 
 func (h *Handler) selectMedia(ctx context.Context, mutation controller.Mutation, ref controller.MediaRef) controller.Result {
-	snapshot, err := h.cfg.Controller.Snapshot(ctx)
+	snapshot, err := h.sessionSnapshot(ctx, mutation.Session)
 	if err != nil {
 		return controller.Result{RequestID: mutation.RequestID, Code: controller.CodeInternal, Message: "snapshot failed"}
 	}
@@ -728,12 +1089,31 @@ func (h *Handler) simplePayload(ctx context.Context, m envelope, fn func(context
 	if readStrict(m.Payload, &p) != nil {
 		return invalid(m.ID)
 	}
-	return fn(ctx, expectedMutation(m.ID, p.ExpectedRevision))
+	return fn(ctx, expectedMutation(m, p.ExpectedRevision))
 }
 func invalid(id string) controller.Result {
 	return controller.Result{RequestID: id, Code: controller.CodeInvalid, Message: "invalid request"}
 }
 
+// codeFolderLimit reports a folder with more files than library.Files walks.
+const codeFolderLimit controller.ErrorCode = "folder_limit"
+
+// filesError maps a failed library.Files the way details does: a missing
+// entry, an oversized folder and a read failure each get their own code.
+func filesError(id string, err error) controller.Result {
+	switch {
+	case errors.Is(err, library.ErrFolderLimit):
+		return controller.Result{RequestID: id, Code: codeFolderLimit, Message: "folder has too many files"}
+	case errors.Is(err, library.ErrClosed):
+		return controller.Result{RequestID: id, Code: controller.CodeClosed, Message: "library closed"}
+	case errors.Is(err, library.ErrInvalidRoot), errors.Is(err, library.ErrInvalidEntry),
+		errors.Is(err, library.ErrNotRegular), errors.Is(err, library.ErrUnsupportedExtension):
+		return controller.Result{RequestID: id, Code: controller.CodeNotFound, Message: "not found"}
+	default:
+		return controller.Result{RequestID: id, Code: controller.CodeInternal, Message: "folder could not be read"}
+	}
+}
+
 func (h *Handler) mediaRef(_ context.Context, rootID, entryID string) (controller.MediaRef, error) {
 	return h.buildMediaRef(rootID, entryID)
 }
@@ -751,7 +1131,8 @@ func (h *Handler) buildMediaRef(rootID, entryID string) (controller.MediaRef, er
 	mediaType := detectMediaType(file)
 	_ = file.Close()
 	open := h.opener(rootID, entryID)
-	return controller.MediaRef{RootID: rootID, ID: entryID, AbsolutePath: meta.AbsolutePath(), Name: meta.Name, Kind: kind, MIMEType: mediaType, OpenDirect: open, OpenTranscode: open, LoadArtwork: h.mediaArtworkLoader(rootID, entryID, meta.Name, kind)}, nil
+	location, _ := h.cfg.Library.Locate(rootID, entryID)
+	return controller.MediaRef{RootID: rootID, ID: entryID, Location: location, AbsolutePath: meta.AbsolutePath(), Name: meta.Name, Kind: kind, MIMEType: mediaType, OpenDirect: open, OpenTranscode: open, LoadArtwork: h.mediaArtworkLoader(rootID, entryID, meta.Name, kind)}, nil
 }
 
 func detectMediaType(file *os.File) string {
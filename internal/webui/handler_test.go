@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"image"
 	"image/color"
 	"image/jpeg"
@@ -13,6 +14,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -20,6 +22,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -152,6 +155,144 @@ func TestBootstrapAndLibrarySanitizedNoStore(t *testing.T) {
 	}
 }
 
+func TestLibrarySearchReturnsPlayableEntries(t *testing.T) {
+	h, lib, _, rootID := testHandler(t)
+	response := httptest.NewRecorder()
+	h.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/library/search?q=MOVIE", nil))
+	var page searchPageDTO
+	if err := json.Unmarshal(response.Body.Bytes(), &page); err != nil || response.Code != http.StatusOK || len(page.Entries) != 1 {
+		t.Fatalf("search = %d %s", response.Code, response.Body.String())
+	}
+	result := page.Entries[0]
+	if result.RootID != rootID || result.Name != "movie.mp4" || result.MediaKind != "video" || result.ThumbnailURL == "" || result.Parent != "" {
+		t.Fatalf("result = %+v", result)
+	}
+	file, _, err := lib.OpenMedia(result.RootID, result.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	for _, query := range []string{"", "?q=", "?q=a&filter=watched"} {
+		response := httptest.NewRecorder()
+		h.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/library/search"+query, nil))
+		if response.Code != http.StatusBadRequest {
+			t.Fatalf("search %q = %d %s", query, response.Code, response.Body.String())
+		}
+	}
+}
+
+func TestLibraryRecentNeedsIndex(t *testing.T) {
+	h, _, _, _ := testHandler(t)
+	response := httptest.NewRecorder()
+	h.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/library/recent", nil))
+	if response.Code != http.StatusNotFound || !strings.Contains(response.Body.String(), "index_disabled") {
+		t.Fatalf("recent without index = %d %s", response.Code, response.Body.String())
+	}
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "new.mkv"), []byte("media"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lib, err := library.Open(library.Config{Roots: []string{root}, IndexFile: filepath.Join(t.TempDir(), "index.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	control := controller.New(controller.Config{})
+	indexed, err := New(Config{Version: "test", Controller: control, Library: lib})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { indexed.Close(); control.Close(); _ = lib.Close() }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		response := httptest.NewRecorder()
+		indexed.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/library/recent?limit=5", nil))
+		var page searchPageDTO
+		if err := json.Unmarshal(response.Body.Bytes(), &page); err != nil || response.Code != http.StatusOK {
+			t.Fatalf("recent = %d %s", response.Code, response.Body.String())
+		}
+		if len(page.Entries) == 1 && page.Entries[0].Name == "new.mkv" && page.Entries[0].MediaKind == "video" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("recent = %+v", page)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLibraryDetails(t *testing.T) {
+	h, lib, _, rootID := testHandler(t)
+	page, err := lib.Browse(rootID, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := "?" + url.Values{"root_id": {rootID}, "entry_id": {page.Entries[0].ID}}.Encode()
+	response := httptest.NewRecorder()
+	h.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/library/details"+query, nil))
+	if response.Code != http.StatusNotFound || !strings.Contains(response.Body.String(), "probe_unavailable") {
+		t.Fatalf("details without probe = %d %s", response.Code, response.Body.String())
+	}
+
+	root := t.TempDir()
+	for _, name := range []string{"movie.mkv", "broken.mkv"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("media"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	probed, err := library.Open(library.Config{Roots: []string{root}, Probe: func(file *os.File, meta library.Metadata) (library.MediaInfo, error) {
+		if meta.Name == "broken.mkv" {
+			return library.MediaInfo{}, errors.New("no streams")
+		}
+		return library.MediaInfo{Duration: 90.5, Width: 1920, Height: 1080, VideoCodec: "h264", AudioLanguages: []string{"eng", "fre"}}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	control := controller.New(controller.Config{})
+	handler, err := New(Config{Version: "test", Controller: control, Library: probed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { handler.Close(); control.Close(); _ = probed.Close() }()
+	rootID = probed.Roots()[0].ID
+	entries, err := probed.Browse(rootID, "", "", 0)
+	if err != nil || len(entries.Entries) != 2 {
+		t.Fatalf("entries = %+v, %v", entries, err)
+	}
+	for _, entry := range entries.Entries {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/library/details?"+url.Values{"root_id": {rootID}, "entry_id": {entry.ID}}.Encode(), nil))
+		if entry.Name == "broken.mkv" {
+			if response.Code != http.StatusUnprocessableEntity || !strings.Contains(response.Body.String(), "probe_failed") {
+				t.Fatalf("broken details = %d %s", response.Code, response.Body.String())
+			}
+			continue
+		}
+		var media mediaDTO
+		if err := json.Unmarshal(response.Body.Bytes(), &media); err != nil || response.Code != http.StatusOK || media.Duration != 90.5 || media.Height != 1080 || len(media.AudioLanguages) != 2 {
+			t.Fatalf("details = %d %s", response.Code, response.Body.String())
+		}
+	}
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/library?root_id="+url.QueryEscape(rootID), nil))
+	var listing libraryPageDTO
+	if err := json.Unmarshal(response.Body.Bytes(), &listing); err != nil || len(listing.Entries) != 2 {
+		t.Fatalf("listing = %d %s", response.Code, response.Body.String())
+	}
+	for _, entry := range listing.Entries {
+		if probed := entry.Media.VideoCodec == "h264"; probed != (entry.Name == "movie.mkv") {
+			t.Fatalf("listed %s media = %+v", entry.Name, entry.Media)
+		}
+	}
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/library/details?root_id="+url.QueryEscape(rootID), nil))
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("details without entry = %d", response.Code)
+	}
+}
+
 func TestTranscodeUnavailableRejected(t *testing.T) {
 	root := t.TempDir()
 	lib, err := library.Open(library.Config{Roots: []string{root}})
@@ -253,6 +394,10 @@ func TestStateUpdatesOnlyChangedDomains(t *testing.T) {
 		{name: "queue", change: func(s *snapshotDTO) { s.Queue[0].Active = true }, want: []string{"state.queue"}},
 		{name: "selection", change: func(s *snapshotDTO) { s.SelectedMediaName = "One" }, want: []string{"state.selection"}},
 		{name: "policy", change: func(s *snapshotDTO) { s.Policy.AutoPlayNext = true }, want: []string{"state.policy"}},
+		{name: "timers", change: func(s *snapshotDTO) { s.SleepTimer.Mode = "after_item" }, want: []string{"state.timers"}},
+		{name: "sessions", change: func(s *snapshotDTO) { s.Sessions = []sessionDTO{{ID: "tv", HasSession: true}} }, want: []string{"state.sessions"}},
+		{name: "groups", change: func(s *snapshotDTO) { s.Groups = []groupDTO{{ID: "group:1", DeviceIDs: []string{"tv", "radio"}}} }, want: []string{"state.groups"}},
+		{name: "history", change: func(s *snapshotDTO) { s.ContinueWatching = []historyDTO{{RootID: "r", EntryID: "e", Position: 60}} }, want: []string{"state.history"}},
 		{name: "multiple", change: func(s *snapshotDTO) { s.Position = 1; s.Queue[0].Active = true }, want: []string{"state.queue", "state.playback"}},
 		{name: "snapshot fallback", change: func(s *snapshotDTO) { s.ActiveMediaName = "One" }, want: []string{"state.snapshot"}},
 		{name: "unmapped change", change: func(*snapshotDTO) {}, want: []string{"state.snapshot"}},
@@ -781,6 +926,33 @@ func TestWebSocketPerIPLimit(t *testing.T) {
 	}
 }
 
+func TestWebSocketClosesRevokedCredential(t *testing.T) {
+	h, _, _, _ := testHandler(t)
+	var revoked atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, h.Credential(r, func() bool { return !revoked.Load() }))
+	}))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	readType(t, conn, "state.snapshot")
+	h.RecheckCredentials()
+	revoked.Store(true)
+	h.RecheckCredentials()
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != websocket.ClosePolicyViolation {
+				t.Fatalf("revoked close = %v", err)
+			}
+			break
+		}
+	}
+}
+
 func TestWebSocketGlobalLimit(t *testing.T) {
 	h, _, _, _ := testHandler(t)
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -43,6 +43,8 @@ type hubConfig struct {
 	pingEvery     time.Duration
 	snapshotEvery time.Duration
 	writerGate    <-chan struct{}
+	// snapshot converts controller state for clients; nil uses safeSnapshot.
+	snapshot func(controller.Snapshot) snapshotDTO
 }
 
 func defaultHubConfig() hubConfig {
@@ -78,19 +80,35 @@ type client struct {
 	once   sync.Once
 	ids    map[string]struct{}
 	order  []string
+	// readOnly clients receive state but every command is refused.
+	readOnly bool
+	// valid reports whether the credential the client connected with still
+	// holds; nil when the security layer attached none.
+	valid func() bool
 }
 
-func newHub(c *controller.Controller, command commandFunc) *hub {
-	return newHubWithConfig(c, command, defaultHubConfig())
+func newHub(c *controller.Controller, command commandFunc, snapshot func(controller.Snapshot) snapshotDTO) *hub {
+	cfg := defaultHubConfig()
+	cfg.snapshot = snapshot
+	return newHubWithConfig(c, command, cfg)
 }
 func newHubWithConfig(c snapshotController, command commandFunc, cfg hubConfig) *hub {
 	if cfg.snapshotEvery <= 0 {
 		cfg.snapshotEvery = snapshotEvery
 	}
+	if cfg.snapshot == nil {
+		cfg.snapshot = safeSnapshot
+	}
 	h := &hub{controller: c, command: command, cfg: cfg, clients: make(map[*client]struct{}), byIP: make(map[string]int), stop: make(chan struct{}), done: make(chan struct{})}
 	go h.snapshots()
 	return h
 }
+func (h *hub) clientCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
 func (h *hub) close() {
 	h.mu.Lock()
 	if h.closed {
@@ -147,7 +165,7 @@ func (h *hub) serve(w http.ResponseWriter, r *http.Request) {
 		h.releaseSlot(ip)
 		return
 	}
-	c := &client{hub: h, conn: conn, ip: ip, send: make(chan outbound, outboundSize), done: make(chan struct{}), ids: make(map[string]struct{})}
+	c := &client{hub: h, conn: conn, ip: ip, send: make(chan outbound, outboundSize), done: make(chan struct{}), ids: make(map[string]struct{}), readOnly: readOnly(r.Context()), valid: credential(r.Context())}
 	h.mu.Lock()
 	if h.closed {
 		h.mu.Unlock()
@@ -159,12 +177,30 @@ func (h *hub) serve(w http.ResponseWriter, r *http.Request) {
 	h.wg.Add(2)
 	h.mu.Unlock()
 	if snapshot, err := h.controller.Snapshot(r.Context()); err == nil {
-		c.enqueue("state.snapshot", mustEnvelope("state.snapshot", "", safeSnapshot(snapshot)))
+		c.enqueue("state.snapshot", mustEnvelope("state.snapshot", "", h.cfg.snapshot(snapshot)))
 	}
 	go func() { defer h.wg.Done(); c.writer() }()
 	go func() { defer h.wg.Done(); c.reader() }()
 }
 
+// recheck closes clients whose credential has stopped validating. The checks
+// run outside h.mu because they take the security layer's lock.
+func (h *hub) recheck() {
+	h.mu.Lock()
+	clients := make([]*client, 0, len(h.clients))
+	for c := range h.clients {
+		if c.valid != nil {
+			clients = append(clients, c)
+		}
+	}
+	h.mu.Unlock()
+	for _, c := range clients {
+		if !c.valid() {
+			c.close(websocket.ClosePolicyViolation, "credential revoked")
+		}
+	}
+}
+
 // releaseSlot gives back a slot taken by serve for a connection that never made
 // it into h.clients.
 func (h *hub) releaseSlot(ip string) {
@@ -325,6 +361,10 @@ func (c *client) reader() {
 			c.enqueue("error", mustEnvelope("error", message.ID, map[string]any{"code": "invalid_message"}))
 			continue
 		}
+		if c.readOnly {
+			c.enqueue("error", mustEnvelope("error", message.ID, map[string]any{"code": "insufficient_scope"}))
+			continue
+		}
 		if c.duplicate(message.ID) {
 			c.enqueue("error", mustEnvelope("error", message.ID, map[string]any{"code": "duplicate_request"}))
 			continue
@@ -436,6 +476,21 @@ func stateUpdates(previous, current snapshotDTO) []outbound {
 	if previous.Policy != current.Policy {
 		updates = append(updates, outbound{kind: "state.policy", data: mustEnvelope("state.policy", "", map[string]any{"revision": current.Revision, "policy": current.Policy})})
 	}
+	if previous.SleepTimer != current.SleepTimer || previous.ScheduledStart != current.ScheduledStart {
+		updates = append(updates, outbound{kind: "state.timers", data: mustEnvelope("state.timers", "", map[string]any{"revision": current.Revision, "sleep_timer": current.SleepTimer, "scheduled_start": current.ScheduledStart})})
+	}
+	if !slices.Equal(previous.Sessions, current.Sessions) {
+		updates = append(updates, outbound{kind: "state.sessions", data: mustEnvelope("state.sessions", "", map[string]any{"revision": current.Revision, "sessions": current.Sessions})})
+	}
+	groupsEqual := slices.EqualFunc(previous.Groups, current.Groups, func(a, b groupDTO) bool {
+		return a.ID == b.ID && a.Label == b.Label && a.ToleranceMS == b.ToleranceMS && slices.Equal(a.DeviceIDs, b.DeviceIDs)
+	})
+	if !groupsEqual {
+		updates = append(updates, outbound{kind: "state.groups", data: mustEnvelope("state.groups", "", map[string]any{"revision": current.Revision, "groups": current.Groups})})
+	}
+	if !slices.Equal(previous.ContinueWatching, current.ContinueWatching) {
+		updates = append(updates, outbound{kind: "state.history", data: mustEnvelope("state.history", "", map[string]any{"revision": current.Revision, "continue_watching": current.ContinueWatching})})
+	}
 	// A changed revision outside granular DTO fields needs authoritative state.
 	if len(updates) == 0 {
 		updates = append(updates, outbound{kind: "state.snapshot", data: mustEnvelope("state.snapshot", "", current)})
@@ -462,10 +517,15 @@ func (h *hub) snapshots() {
 				continue
 			}
 			s, err := h.controller.Snapshot(context.Background())
-			if err != nil || havePrevious && s.Revision == previous.Revision {
+			if err != nil {
+				continue
+			}
+			current := h.cfg.snapshot(s)
+			// Additional sessions keep their own revisions, so the default
+			// revision alone does not cover them.
+			if havePrevious && s.Revision == previous.Revision && slices.Equal(current.Sessions, previous.Sessions) {
 				continue
 			}
-			current := safeSnapshot(s)
 			updates := []outbound{{kind: "state.snapshot", data: mustEnvelope("state.snapshot", "", current)}}
 			if havePrevious {
 				updates = stateUpdates(previous, current)
@@ -0,0 +1,368 @@
+package webui
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/internal/playlist"
+)
+
+// OpenAPIPath serves the checked-in description of the HTTP API.
+const OpenAPIPath = "/api/openapi.json"
+
+// openAPIDocument is the generated openapi.json. TestOpenAPIDocumentCurrent
+// fails when it no longer matches buildOpenAPI, and TestOpenAPIClientCurrent
+// when the apiclient package no longer matches it; run go generate after
+// changing a DTO or route.
+//
+//go:generate go test -run TestOpenAPI(Document|Client)Current -update .
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// schemaNames lists the DTOs published as named components. Any struct reached
+// from a route that is not listed here is inlined.
+var schemaNames = map[reflect.Type]string{
+	reflect.TypeFor[bootstrapDTO]():        "Bootstrap",
+	reflect.TypeFor[rootDTO]():             "Root",
+	reflect.TypeFor[entryDTO]():            "LibraryEntry",
+	reflect.TypeFor[libraryPageDTO]():      "LibraryPage",
+	reflect.TypeFor[searchResultDTO]():     "SearchResult",
+	reflect.TypeFor[searchPageDTO]():       "SearchPage",
+	reflect.TypeFor[mediaDTO]():            "MediaDetails",
+	reflect.TypeFor[snapshotDTO]():         "Snapshot",
+	reflect.TypeFor[deviceDTO]():           "Device",
+	reflect.TypeFor[devicesDTO]():          "DeviceList",
+	reflect.TypeFor[queueDTO]():            "QueueItem",
+	reflect.TypeFor[queueListDTO]():        "Queue",
+	reflect.TypeFor[policyDTO]():           "PolicyState",
+	reflect.TypeFor[playlistDTO]():         "Playlist",
+	reflect.TypeFor[timersDTO]():           "Timers",
+	reflect.TypeFor[sleepTimerDTO]():       "SleepTimer",
+	reflect.TypeFor[scheduledStartDTO]():   "ScheduledStart",
+	reflect.TypeFor[sessionDTO]():          "Session",
+	reflect.TypeFor[sessionsDTO]():         "SessionList",
+	reflect.TypeFor[groupDTO]():            "Group",
+	reflect.TypeFor[groupsDTO]():           "GroupList",
+	reflect.TypeFor[groupResultDTO]():      "GroupResult",
+	reflect.TypeFor[historyDTO]():          "HistoryEntry",
+	reflect.TypeFor[historyListDTO]():      "History",
+	reflect.TypeFor[controller.Policy]():   "Policy",
+	reflect.TypeFor[commandResultDTO]():    "CommandResult",
+	reflect.TypeFor[queueBatchResultDTO](): "QueueBatchResult",
+	reflect.TypeFor[errorDTO]():            "Error",
+}
+
+// readSchemas names the response body of each GET route in restRoutes.
+var readSchemas = map[string]reflect.Type{
+	"/api/v1/state":        reflect.TypeFor[snapshotDTO](),
+	"/api/v1/devices":      reflect.TypeFor[devicesDTO](),
+	"/api/v1/queue":        reflect.TypeFor[queueListDTO](),
+	"/api/v1/policy":       reflect.TypeFor[policyDTO](),
+	"/api/v1/queue/export": reflect.TypeFor[playlistDTO](),
+	"/api/v1/timers":       reflect.TypeFor[timersDTO](),
+	"/api/v1/sessions":     reflect.TypeFor[sessionsDTO](),
+	"/api/v1/groups":       reflect.TypeFor[groupsDTO](),
+	"/api/v1/history":      reflect.TypeFor[historyListDTO](),
+}
+
+type payloadField struct {
+	name     string
+	schema   map[string]any
+	required bool
+}
+
+// commandPayloads mirrors the payload decoders in executeCommand,
+// queueAddMany, queueImport and createGroup. Every payload also accepts expected_revision.
+var commandPayloads = map[string][]payloadField{
+	"devices.select": {{"device_id", stringSchema(), true}},
+	"library.play":   append(mediaPayload(), payloadField{"resume", map[string]any{"type": "boolean"}, false}),
+	"library.mark": {
+		{"root_id", stringSchema(), true},
+		{"entry_id", stringSchema(), false},
+		{"watched", map[string]any{"type": "boolean"}, true},
+	},
+	"library.select_media":    mediaPayload(),
+	"library.select_subtitle": mediaPayload(),
+	"queue.add":               mediaPayload(),
+	"queue.add_many": {
+		{"root_id", stringSchema(), true},
+		{"entry_ids", map[string]any{"type": "array", "items": stringSchema(), "minItems": 1, "maxItems": controller.MaxQueueItems}, true},
+	},
+	"queue.import": {
+		{"format", map[string]any{"type": "string", "enum": playlist.Formats}, true},
+		{"content", stringSchema(), true},
+	},
+	"queue.select": {{"item_id", stringSchema(), true}},
+	"queue.remove": {{"item_id", stringSchema(), true}},
+	"queue.move": {
+		{"item_id", stringSchema(), true},
+		{"delta", map[string]any{"type": "integer", "not": map[string]any{"const": 0}}, true},
+	},
+	"player.play": {{"item_id", stringSchema(), false}},
+	"player.volume": {
+		{"volume", map[string]any{"type": "integer", "minimum": 0, "maximum": 100}, false},
+		{"delta", map[string]any{"type": "integer", "enum": []int{-1, 1}}, false},
+	},
+	"player.mute":      {{"muted", map[string]any{"type": "boolean"}, true}},
+	"player.transcode": {{"enabled", map[string]any{"type": "boolean"}, true}},
+	"playback.policy":  {{"policy", ref("Policy"), true}},
+	"player.seek":      {{"seconds", map[string]any{"type": "integer", "minimum": 0}, true}},
+	"player.transfer":  {{"device_id", stringSchema(), true}},
+	"player.sleep": {
+		{"mode", map[string]any{"type": "string", "enum": sleepModes}, true},
+		{"minutes", map[string]any{"type": "integer", "minimum": 1, "maximum": int(controller.MaxSleepDuration / time.Minute)}, false},
+	},
+	"player.schedule": {
+		{"at", map[string]any{"type": "string", "format": "date-time"}, false},
+		{"device_id", stringSchema(), false},
+	},
+	"groups.create": {
+		{"name", stringSchema(), false},
+		{"device_ids", map[string]any{"type": "array", "items": stringSchema(), "minItems": 2}, true},
+		{"tolerance_ms", map[string]any{"type": "integer", "minimum": 1, "maximum": maxGroupToleranceMS}, false},
+	},
+	"groups.remove": {{"group_id", stringSchema(), true}},
+}
+
+func mediaPayload() []payloadField {
+	return []payloadField{{"root_id", stringSchema(), true}, {"entry_id", stringSchema(), true}}
+}
+
+func stringSchema() map[string]any { return map[string]any{"type": "string"} }
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (h *Handler) openAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPIDocument)
+}
+
+// buildOpenAPI renders the API description from the DTO types and route
+// tables, so the document cannot describe fields the server does not send.
+func buildOpenAPI() ([]byte, error) {
+	schemas := map[string]any{}
+	for t, name := range schemaNames {
+		schemas[name] = structSchema(t)
+	}
+	errorResponse := map[string]any{"description": "Request failed; error carries the controller or request code.", "content": jsonContent(ref("Error"))}
+	paths := map[string]any{
+		"/api/bootstrap": map[string]any{"get": map[string]any{
+			"operationId": "bootstrap",
+			"summary":     "Issue the session cookie and return server state",
+			"responses":   map[string]any{"200": jsonResponse("Server bootstrap.", "Bootstrap"), "default": errorResponse},
+		}},
+		"/api/library": map[string]any{"get": map[string]any{
+			"operationId": "browseLibrary",
+			"summary":     "Browse a media root",
+			"parameters": []any{
+				queryParameter("root_id", true), queryParameter("parent_id", false), queryParameter("cursor", false),
+				map[string]any{"name": "limit", "in": "query", "schema": map[string]any{"type": "integer", "minimum": 1, "maximum": 200}},
+				map[string]any{"name": "filter", "in": "query", "schema": map[string]any{"type": "string", "enum": library.Filters}},
+			},
+			"responses": map[string]any{"200": jsonResponse("One page of entries.", "LibraryPage"), "default": errorResponse},
+		}},
+		"/api/library/search": map[string]any{"get": map[string]any{
+			"operationId": "searchLibrary",
+			"summary":     "Search media file names in every root",
+			"parameters": []any{
+				map[string]any{"name": "q", "in": "query", "required": true, "schema": map[string]any{"type": "string", "minLength": 1, "maxLength": library.MaxQuery}},
+				queryParameter("cursor", false),
+				map[string]any{"name": "limit", "in": "query", "schema": map[string]any{"type": "integer", "minimum": 1, "maximum": library.MaxLimit}},
+			},
+			"responses": map[string]any{"200": jsonResponse("One page of matches; a page may be empty and still carry a cursor.", "SearchPage"), "default": errorResponse},
+		}},
+		"/api/library/recent": map[string]any{"get": map[string]any{
+			"operationId": "recentLibrary",
+			"summary":     "List the media files most recently added to the library index",
+			"parameters": []any{
+				map[string]any{"name": "limit", "in": "query", "schema": map[string]any{"type": "integer", "minimum": 1, "maximum": library.MaxLimit}},
+			},
+			"responses": map[string]any{"200": jsonResponse("Newest first, without a cursor; index_disabled when the server keeps no index.", "SearchPage"), "default": errorResponse},
+		}},
+		"/api/library/details": map[string]any{"get": map[string]any{
+			"operationId": "libraryDetails",
+			"summary":     "Probe a library file for its duration, streams, and tags",
+			"parameters":  []any{queryParameter("root_id", true), queryParameter("entry_id", true)},
+			"responses":   map[string]any{"200": jsonResponse("Cached until the file changes; probe_unavailable when the server cannot probe, probe_failed when the file could not be read.", "MediaDetails"), "default": errorResponse},
+		}},
+		OpenAPIPath: map[string]any{"get": map[string]any{
+			"operationId": "openAPI",
+			"summary":     "This document",
+			"responses":   map[string]any{"200": map[string]any{"description": "OpenAPI 3.1 document.", "content": jsonContent(map[string]any{"type": "object"})}},
+		}},
+	}
+	for path, methods := range restRoutes {
+		operations := map[string]any{}
+		for method, kind := range methods {
+			operation := map[string]any{"responses": map[string]any{"default": errorResponse}}
+			responses := operation["responses"].(map[string]any)
+			if kind == "" {
+				operation["summary"] = "Read " + strings.TrimPrefix(path, APIPrefix)
+				operation["operationId"] = "get" + camelCase(strings.TrimPrefix(path, APIPrefix))
+				operation["parameters"] = []any{map[string]any{"name": "If-None-Match", "in": "header", "schema": stringSchema()}}
+				if path == "/api/v1/queue/export" {
+					operation["parameters"] = append(operation["parameters"].([]any), map[string]any{"name": "format", "in": "query", "schema": map[string]any{"type": "string", "enum": playlist.Formats, "default": playlist.M3U8}})
+				}
+				responses["200"] = jsonResponse("Current state; ETag carries the revision.", schemaNames[readSchemas[path]])
+				responses["304"] = map[string]any{"description": "Revision unchanged."}
+			} else {
+				operation["summary"] = "Run the " + kind + " command"
+				operation["operationId"] = lowerFirst(camelCase(kind))
+				operation["parameters"] = []any{map[string]any{"name": "If-Match", "in": "header", "description": "Quoted revision from a previous ETag; * matches any.", "schema": stringSchema()}}
+				operation["requestBody"] = map[string]any{"required": false, "content": jsonContent(payloadSchema(commandPayloads[kind]))}
+				result := "CommandResult"
+				switch kind {
+				case "queue.add_many", "queue.import":
+					result = "QueueBatchResult"
+				case "groups.create":
+					result = "GroupResult"
+				}
+				responses["200"] = jsonResponse("Command applied; ETag carries the new revision.", result)
+				responses["412"] = map[string]any{"description": "If-Match revision is stale.", "content": jsonContent(ref("Error"))}
+			}
+			if path != "/api/v1/queue/export" {
+				operation["parameters"] = append(operation["parameters"].([]any), map[string]any{"name": "session", "in": "query", "description": "Device ID of the target session; blank is the default session.", "schema": stringSchema()})
+			}
+			operations[strings.ToLower(method)] = operation
+		}
+		paths[path] = operations
+	}
+	document := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Go2TV server mode",
+			"version":     "1",
+			"description": "Browser clients fetch /api/bootstrap first to receive the session cookie required by every other /api route. Other clients send an API token as a bearer credential; read-scoped tokens may only use GET routes.",
+		},
+		"paths":    paths,
+		"security": []any{map[string]any{"sessionCookie": []string{}}, map[string]any{"apiToken": []string{}}},
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"sessionCookie": map[string]any{"type": "apiKey", "in": "cookie", "name": "go2tv_session"},
+				"apiToken":      map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// camelCase joins the words of a route path or command kind, so
+// "queue/export" becomes QueueExport and "library.select_media" becomes
+// LibrarySelectMedia.
+func camelCase(name string) string {
+	var out strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '.' || r == '_' }) {
+		out.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return out.String()
+}
+
+func lowerFirst(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func jsonResponse(description, schema string) map[string]any {
+	return map[string]any{"description": description, "content": jsonContent(ref(schema))}
+}
+
+func queryParameter(name string, required bool) map[string]any {
+	return map[string]any{"name": name, "in": "query", "required": required, "schema": stringSchema()}
+}
+
+func payloadSchema(fields []payloadField) map[string]any {
+	properties := map[string]any{"expected_revision": map[string]any{"type": "integer", "minimum": 0}}
+	required := []string{}
+	for _, field := range fields {
+		properties[field.name] = field.schema
+		if field.required {
+			required = append(required, field.name)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	if name, ok := schemaNames[t]; ok {
+		return ref(name)
+	}
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return stringSchema()
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		panic("openapi: unsupported DTO field type " + t.String())
+	}
+}
+
+func structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	var collect func(reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if field.Anonymous {
+				collect(field.Type)
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = typeSchema(field.Type)
+			if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") {
+				required = append(required, name)
+			}
+		}
+	}
+	collect(t)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,282 @@
+package webui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"slices"
+	"strings"
+)
+
+// clientPath is the generated half of the apiclient package, relative to this
+// directory. TestOpenAPIClientCurrent fails when it no longer matches
+// buildClient.
+const clientPath = "../../apiclient/openapi_gen.go"
+
+// clientOptionParameters are carried by the hand-written apiclient options
+// rather than by generated parameter structs.
+var clientOptionParameters = map[string]bool{"If-Match": true, "If-None-Match": true, "session": true}
+
+// clientInitialisms are the words written in capitals in Go names.
+var clientInitialisms = map[string]bool{"id": true, "ms": true, "url": true, "api": true, "gui": true}
+
+type apiSchema struct {
+	Ref                  string               `json:"$ref"`
+	Type                 string               `json:"type"`
+	Format               string               `json:"format"`
+	Minimum              *float64             `json:"minimum"`
+	Maximum              *float64             `json:"maximum"`
+	Properties           map[string]apiSchema `json:"properties"`
+	Required             []string             `json:"required"`
+	Items                *apiSchema           `json:"items"`
+	AdditionalProperties json.RawMessage      `json:"additionalProperties"`
+}
+
+type apiOperation struct {
+	OperationID string `json:"operationId"`
+	Summary     string `json:"summary"`
+	Parameters  []struct {
+		Name     string    `json:"name"`
+		In       string    `json:"in"`
+		Required bool      `json:"required"`
+		Schema   apiSchema `json:"schema"`
+	} `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema apiSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema apiSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+// buildClient renders the typed half of the apiclient package from an OpenAPI
+// document: one struct per component schema, and one method per operation
+// that sends the route's parameters and body and decodes its 200 response.
+func buildClient(document []byte) ([]byte, error) {
+	var api struct {
+		Paths      map[string]map[string]apiOperation `json:"paths"`
+		Components struct {
+			Schemas map[string]apiSchema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(document, &api); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	for _, name := range sortedKeys(api.Components.Schemas) {
+		if err := writeClientStruct(&out, name, "", api.Components.Schemas[name], false, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range sortedKeys(api.Paths) {
+		for _, method := range sortedKeys(api.Paths[path]) {
+			if err := writeClientMethod(&out, path, strings.ToUpper(method), api.Paths[path][method]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var file bytes.Buffer
+	file.WriteString("// Code generated by go generate ./internal/webui; DO NOT EDIT.\n\npackage apiclient\n\nimport (\n")
+	for _, pkg := range []string{"context", "net/http", "net/url", "strconv", "time"} {
+		if bytes.Contains(out.Bytes(), []byte(pkg[strings.LastIndex(pkg, "/")+1:]+".")) {
+			fmt.Fprintf(&file, "%q\n", pkg)
+		}
+	}
+	file.WriteString(")\n\n")
+	file.Write(out.Bytes())
+	return format.Source(file.Bytes())
+}
+
+// writeClientStruct writes schema as a struct. Request fields are optional
+// pointers; query fields carry no JSON tags.
+func writeClientStruct(out *bytes.Buffer, name, doc string, schema apiSchema, request, query bool) error {
+	if doc != "" {
+		fmt.Fprintf(out, "// %s %s\n", name, doc)
+	}
+	fmt.Fprintf(out, "type %s struct {\n", name)
+	for _, property := range sortedKeys(schema.Properties) {
+		required := slices.Contains(schema.Required, property)
+		goType, err := clientType(schema.Properties[property], required, request)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", name, property, err)
+		}
+		if query {
+			fmt.Fprintf(out, "%s %s\n", goName(property), goType)
+			continue
+		}
+		tag := property
+		if !required {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(out, "%s %s `json:%q`\n", goName(property), goType, tag)
+	}
+	out.WriteString("}\n\n")
+	return nil
+}
+
+// clientType maps a property schema to a Go type. Optional request fields are
+// pointers so zero values such as a volume of 0 can still be sent.
+func clientType(schema apiSchema, required, request bool) (string, error) {
+	goType, err := clientValueType(schema)
+	if err != nil {
+		return "", err
+	}
+	optionalPointer := !required && (request || schema.Ref != "" || schema.Format == "date-time")
+	if optionalPointer && !strings.HasPrefix(goType, "[]") && !strings.HasPrefix(goType, "map[") {
+		return "*" + goType, nil
+	}
+	return goType, nil
+}
+
+func clientValueType(schema apiSchema) (string, error) {
+	if schema.Ref != "" {
+		return strings.TrimPrefix(schema.Ref, "#/components/schemas/"), nil
+	}
+	switch schema.Type {
+	case "string":
+		if schema.Format == "date-time" {
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "boolean":
+		return "bool", nil
+	case "integer":
+		if schema.Minimum != nil && *schema.Minimum == 0 && schema.Maximum == nil {
+			return "uint64", nil
+		}
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "array":
+		if schema.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := clientValueType(*schema.Items)
+		return "[]" + item, err
+	case "object":
+		if len(schema.Properties) > 0 {
+			return "", fmt.Errorf("inline object; name it in schemaNames")
+		}
+		var value apiSchema
+		if len(schema.AdditionalProperties) == 0 || json.Unmarshal(schema.AdditionalProperties, &value) != nil {
+			return "map[string]any", nil
+		}
+		item, err := clientValueType(value)
+		return "map[string]" + item, err
+	}
+	return "", fmt.Errorf("unsupported schema type %q", schema.Type)
+}
+
+func writeClientMethod(out *bytes.Buffer, path, method string, operation apiOperation) error {
+	if operation.OperationID == "" {
+		return fmt.Errorf("%s %s has no operationId", method, path)
+	}
+	name := goName(operation.OperationID)
+	response := operation.Responses["200"].Content["application/json"].Schema
+	if response.Ref == "" {
+		// Only the OpenAPI document itself has no named response.
+		return nil
+	}
+	result, err := clientValueType(response)
+	if err != nil {
+		return fmt.Errorf("%s response: %w", name, err)
+	}
+	params := apiSchema{Properties: map[string]apiSchema{}}
+	for _, parameter := range operation.Parameters {
+		if clientOptionParameters[parameter.Name] {
+			continue
+		}
+		if parameter.In != "query" {
+			return fmt.Errorf("%s parameter %s in %s", name, parameter.Name, parameter.In)
+		}
+		params.Properties[parameter.Name] = parameter.Schema
+		if parameter.Required {
+			params.Required = append(params.Required, parameter.Name)
+		}
+	}
+	var body apiSchema
+	if operation.RequestBody != nil {
+		// Revisions travel in the If-Match header through the IfMatch option.
+		body = operation.RequestBody.Content["application/json"].Schema
+		delete(body.Properties, "expected_revision")
+	}
+
+	args := "ctx context.Context"
+	if len(params.Properties) > 0 {
+		if err := writeClientStruct(out, name+"Params", "holds the query parameters of "+name+".", params, false, true); err != nil {
+			return err
+		}
+		args += ", params " + name + "Params"
+	}
+	bodyArg := "nil"
+	if len(body.Properties) > 0 {
+		if err := writeClientStruct(out, name+"Request", "is the request body of "+name+".", body, true, false); err != nil {
+			return err
+		}
+		args += ", body " + name + "Request"
+		bodyArg = "body"
+	}
+	fmt.Fprintf(out, "// %s calls %s %s: %s.\n", name, method, path, lowerFirst(operation.Summary))
+	fmt.Fprintf(out, "func (c *Client) %s(%s, opts ...Option) (*%s, error) {\n", name, args, result)
+	queryArg := "nil"
+	if len(params.Properties) > 0 {
+		queryArg = "query"
+		out.WriteString("query := url.Values{}\n")
+		for _, parameter := range sortedKeys(params.Properties) {
+			field := "params." + goName(parameter)
+			value := field
+			zero := `""`
+			if goType, _ := clientValueType(params.Properties[parameter]); goType != "string" {
+				value = "strconv.Itoa(" + field + ")"
+				zero = "0"
+			}
+			if slices.Contains(params.Required, parameter) {
+				fmt.Fprintf(out, "query.Set(%q, %s)\n", parameter, value)
+			} else {
+				fmt.Fprintf(out, "if %s != %s {\nquery.Set(%q, %s)\n}\n", field, zero, parameter, value)
+			}
+		}
+	}
+	fmt.Fprintf(out, "var out %s\n", result)
+	fmt.Fprintf(out, "if err := c.do(ctx, http.Method%s, %q, %s, %s, &out, opts); err != nil {\nreturn nil, err\n}\n", methodConstant(method), path, queryArg, bodyArg)
+	out.WriteString("return &out, nil\n}\n\n")
+	return nil
+}
+
+func methodConstant(method string) string {
+	return method[:1] + strings.ToLower(method[1:])
+}
+
+// goName turns a JSON or operation name into an exported Go identifier,
+// writing clientInitialisms in capitals: entry_ids becomes EntryIDs.
+func goName(name string) string {
+	var out strings.Builder
+	for _, word := range strings.Split(name, "_") {
+		if word == "" {
+			continue
+		}
+		switch {
+		case word == "ids":
+			out.WriteString("IDs")
+		case clientInitialisms[strings.ToLower(word)]:
+			out.WriteString(strings.ToUpper(word))
+		default:
+			out.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return out.String()
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
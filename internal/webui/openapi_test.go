@@ -0,0 +1,140 @@
+package webui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"go2tv.app/go2tv/v2/apiclient"
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playback"
+)
+
+var updateOpenAPI = flag.Bool("update", false, "rewrite openapi.json and the apiclient package from the DTOs")
+
+func TestOpenAPIDocumentCurrent(t *testing.T) {
+	generated, err := buildOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updateOpenAPI {
+		if err := os.WriteFile("openapi.json", generated, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if !bytes.Equal(generated, openAPIDocument) {
+		t.Fatal("openapi.json is out of date; run go generate ./internal/webui")
+	}
+}
+
+func TestOpenAPIDescribesSnapshotFields(t *testing.T) {
+	var document struct {
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPIDocument, &document); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(safeSnapshot(snapshotFixture()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent map[string]any
+	if err := json.Unmarshal(data, &sent); err != nil {
+		t.Fatal(err)
+	}
+	described := document.Components.Schemas["Snapshot"].Properties
+	for key := range sent {
+		if _, ok := described[key]; !ok {
+			t.Errorf("snapshot field %q missing from openapi.json", key)
+		}
+	}
+	for path := range restRoutes {
+		if _, ok := document.Paths[path]; !ok {
+			t.Errorf("route %s missing from openapi.json", path)
+		}
+	}
+	for _, name := range schemaNames {
+		if _, ok := document.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s missing from openapi.json", name)
+		}
+	}
+}
+
+// snapshotFixture fills every optional field so omitempty keys are encoded.
+func snapshotFixture() controller.Snapshot {
+	return controller.Snapshot{
+		Revision: 7, SelectedDeviceID: "device", ActiveDeviceID: "device", SelectedMedia: "movie.mp4", SelectedSubtitle: "movie.srt",
+		ActiveMediaName: "movie.mp4", MediaType: mediamodel.MediaKindVideo, ArtworkID: "art", PlaybackState: "PLAYING",
+		Devices: []playback.Device{{ID: "device", Name: "TV", AudioOnly: true}},
+		Queue:   []controller.QueueItem{{ID: "item", Name: "movie.mp4", Parent: "Movies", MediaKind: mediamodel.MediaKindVideo}},
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	h, _, _, _ := testHandler(t)
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+	if res.Code != http.StatusOK || res.Header().Get("Content-Type") != "application/json" || !bytes.Equal(res.Body.Bytes(), openAPIDocument) {
+		t.Fatalf("status = %d type = %q", res.Code, res.Header().Get("Content-Type"))
+	}
+}
+
+func TestOpenAPIClientCurrent(t *testing.T) {
+	document, err := buildOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, err := buildClient(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updateOpenAPI {
+		if err := os.WriteFile(clientPath, generated, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	current, err := os.ReadFile(clientPath)
+	if err != nil || !bytes.Equal(generated, current) {
+		t.Fatalf("%s is out of date (%v); run go generate ./internal/webui", clientPath, err)
+	}
+}
+
+func TestAPIClientDrivesHandler(t *testing.T) {
+	h, _, _, rootID := testHandler(t)
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	client := apiclient.New(server.URL, "")
+	ctx := t.Context()
+	page, err := client.BrowseLibrary(ctx, apiclient.BrowseLibraryParams{RootID: rootID})
+	if err != nil || len(page.Entries) != 1 {
+		t.Fatalf("browse = %#v %v", page, err)
+	}
+	added, err := client.QueueAdd(ctx, apiclient.QueueAddRequest{RootID: rootID, EntryID: page.Entries[0].ID}, apiclient.IfMatch(0))
+	if err != nil || added.Revision != 1 {
+		t.Fatalf("add = %#v %v", added, err)
+	}
+	var statusErr *apiclient.StatusError
+	if _, err := client.QueueClear(ctx, apiclient.IfMatch(0)); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusPreconditionFailed || statusErr.Body.Error != "conflict" {
+		t.Fatalf("stale clear = %v", err)
+	}
+	queue, err := client.GetQueue(ctx)
+	if err != nil || len(queue.Queue) != 1 || queue.Queue[0].Name != "movie.mp4" {
+		t.Fatalf("queue = %#v %v", queue, err)
+	}
+	if _, err := client.GetQueue(ctx, apiclient.IfNoneMatch(queue.Revision)); !errors.Is(err, apiclient.ErrNotModified) {
+		t.Fatalf("conditional queue = %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package webui
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/mediamodel"
+	"go2tv.app/go2tv/v2/internal/playlist"
+)
+
+// remoteRootID marks queue items that the renderer loads from a URL instead of
+// from a library root.
+const remoteRootID = "remote"
+
+// queueImport appends the entries of an uploaded playlist. Relative paths are
+// looked up under each library root in turn, absolute paths must lie inside a
+// root, and http(s) URLs become remote items.
+func (h *Handler) queueImport(ctx context.Context, message envelope) (controller.Result, map[string]any) {
+	var p struct {
+		Format           string  `json:"format"`
+		Content          string  `json:"content"`
+		ExpectedRevision *uint64 `json:"expected_revision"`
+	}
+	if readStrict(message.Payload, &p) != nil {
+		return invalid(message.ID), nil
+	}
+	format, err := playlist.ParseFormat(p.Format)
+	if err != nil {
+		return invalid(message.ID), nil
+	}
+	entries, err := playlist.Parse(format, []byte(p.Content))
+	if err != nil {
+		return invalid(message.ID), nil
+	}
+	refs := make([]controller.MediaRef, 0, min(len(entries), controller.MaxQueueItems))
+	failed, excess := 0, 0
+	for _, entry := range entries {
+		if len(refs) == controller.MaxQueueItems {
+			excess++
+			continue
+		}
+		ref, err := h.playlistMediaRef(entry)
+		if err != nil {
+			failed++
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		return invalid(message.ID), nil
+	}
+	result := h.cfg.Controller.AddQueueItems(ctx, controller.QueueAddManyRequest{Mutation: expectedMutation(message, p.ExpectedRevision), Items: refs})
+	if !result.OK() {
+		return result.Result, nil
+	}
+	return result.Result, map[string]any{"added": result.Added, "duplicates": result.Duplicates, "dropped": result.Dropped + excess, "failed": failed}
+}
+
+func (h *Handler) playlistMediaRef(entry playlist.Entry) (controller.MediaRef, error) {
+	if playlist.IsRemote(entry.Location) {
+		return remoteMediaRef(entry)
+	}
+	// Playlists written on Windows separate directories with backslashes.
+	location := filepath.FromSlash(strings.ReplaceAll(entry.Location, `\`, "/"))
+	rootID, entryID, err := h.cfg.Library.Find(location)
+	if err != nil {
+		return controller.MediaRef{}, err
+	}
+	ref, err := h.queuedMediaRef(rootID, entryID)
+	if err != nil {
+		return controller.MediaRef{}, err
+	}
+	if ref.Kind == mediamodel.MediaKindUnknown {
+		return controller.MediaRef{}, errors.New("not a media file")
+	}
+	ref.Title = entry.Title
+	return ref, nil
+}
+
+func remoteMediaRef(entry playlist.Entry) (controller.MediaRef, error) {
+	u, err := url.Parse(entry.Location)
+	if err != nil {
+		return controller.MediaRef{}, err
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = u.Host
+	}
+	kind := mediamodel.KindForPath(name)
+	if kind == mediamodel.MediaKindUnknown {
+		// Extensionless stream URLs are nearly always internet radio, and
+		// audio is the one kind every renderer accepts.
+		kind = mediamodel.MediaKindAudio
+	}
+	ref := controller.MediaRef{RootID: remoteRootID, ID: entry.Location, Name: name, Kind: kind, MIMEType: mime.TypeByExtension(path.Ext(name)), URL: entry.Location, Title: entry.Title}
+	return ref, ref.Validate()
+}
+
+// queueExport writes the queue as a playlist. Library items are written
+// relative to their root, so importing the file again resolves them even
+// after the roots move.
+func (h *Handler) queueExport(w http.ResponseWriter, r *http.Request) {
+	value := r.URL.Query().Get("format")
+	if value == "" {
+		value = string(playlist.M3U8)
+	}
+	format, err := playlist.ParseFormat(value)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	state, revision, err := h.cfg.Controller.SavedState(r.Context())
+	if err != nil {
+		apiError(w, http.StatusServiceUnavailable, "unavailable")
+		return
+	}
+	entries := make([]playlist.Entry, 0, len(state.Queue))
+	for _, media := range state.Queue {
+		entry := playlist.Entry{Location: media.URL, Title: media.Title}
+		if media.URL == "" {
+			location, err := h.cfg.Library.Locate(media.RootID, media.ID)
+			if err != nil {
+				continue
+			}
+			entry.Location = location.Path
+		}
+		entries = append(entries, entry)
+	}
+	var content strings.Builder
+	if err := playlist.Write(&content, format, entries); err != nil {
+		apiError(w, http.StatusInternalServerError, "internal")
+		return
+	}
+	writeJSON(w, http.StatusOK, playlistDTO{Revision: revision, Format: string(format), FileName: "go2tv-queue" + format.Extension(), Content: content.String()})
+}
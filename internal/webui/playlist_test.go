@@ -0,0 +1,77 @@
+package webui
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/internal/playlist"
+)
+
+func TestQueueImportResolvesAgainstRootsAndExports(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "Music"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Music/song.mp3", "clip.mp4"} {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte("media"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lib, err := library.Open(library.Config{Roots: []string{root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lib.Close() })
+	control := controller.New(controller.Config{})
+	t.Cleanup(control.Close)
+	h, err := New(Config{Version: "test", Controller: control, Library: lib})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+
+	content := "#EXTM3U\n#EXTINF:-1,My Song\nMusic\\song.mp3\n" + filepath.Join(root, "clip.mp4") + "\n" +
+		"#EXTINF:-1,Radio\nhttps://radio.example/live\nmissing.mp3\n../escape.mp3\nMusic/song.mp3\n"
+	payload, _ := json.Marshal(map[string]string{"format": "m3u8", "content": content})
+	res, body := restCall(t, h, http.MethodPost, "/api/v1/queue/import", string(payload), nil)
+	if res.Code != http.StatusOK || body["added"] != float64(3) || body["failed"] != float64(2) || body["duplicates"] != float64(1) {
+		t.Fatalf("import = %d %v", res.Code, body)
+	}
+	snapshot, _ := control.Snapshot(context.Background())
+	if len(snapshot.Queue) != 3 || snapshot.Queue[0].Name != "My Song" || snapshot.Queue[1].Name != "clip.mp4" || snapshot.Queue[2].Name != "Radio" {
+		t.Fatalf("queue = %#v", snapshot.Queue)
+	}
+	for _, bad := range []string{`{"format":"wpl","content":"a.mp3"}`, `{"format":"m3u","content":"missing.mp3"}`, `{"format":"m3u"}`} {
+		if res, body := restCall(t, h, http.MethodPost, "/api/v1/queue/import", bad, nil); res.Code != http.StatusBadRequest {
+			t.Fatalf("import %s = %d %v", bad, res.Code, body)
+		}
+	}
+
+	res, body = restCall(t, h, http.MethodGet, "/api/v1/queue/export?format=xspf", "", nil)
+	if res.Code != http.StatusOK || body["file_name"] != "go2tv-queue.xspf" {
+		t.Fatalf("export = %d %v", res.Code, body)
+	}
+	entries, err := playlist.Parse(playlist.XSPF, []byte(body["content"].(string)))
+	want := []playlist.Entry{{Location: "Music/song.mp3", Title: "My Song"}, {Location: "clip.mp4"}, {Location: "https://radio.example/live", Title: "Radio"}}
+	if err != nil || len(entries) != len(want) {
+		t.Fatalf("exported entries = %#v, %v", entries, err)
+	}
+	for index := range want {
+		if entries[index] != want[index] {
+			t.Fatalf("exported entries = %#v, want %#v", entries, want)
+		}
+	}
+	if res, _ := restCall(t, h, http.MethodGet, "/api/v1/queue/export?format=txt", "", nil); res.Code != http.StatusBadRequest {
+		t.Fatalf("unknown export format = %d", res.Code)
+	}
+	if res, body := restCall(t, h, http.MethodGet, "/api/v1/queue/export", "", nil); res.Code != http.StatusOK || !strings.HasPrefix(body["content"].(string), "#EXTM3U\n") {
+		t.Fatalf("default export = %d %v", res.Code, body)
+	}
+}
@@ -0,0 +1,240 @@
+package webui
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+)
+
+// APIPrefix is the root of the versioned HTTP API. It maps plain JSON requests
+// onto the same commands the WebSocket hub dispatches, for clients that cannot
+// hold a socket open.
+const APIPrefix = "/api/v1/"
+
+const restCommandTimeout = 15 * time.Second
+
+// restRoutes maps each fixed API path and method to a hub command type. Bodies
+// use the command payload shape; an empty body is an empty payload. The
+// session query parameter selects the session a route reads or commands.
+var restRoutes = map[string]map[string]string{
+	"/api/v1/state":            {http.MethodGet: ""},
+	"/api/v1/devices":          {http.MethodGet: ""},
+	"/api/v1/devices/refresh":  {http.MethodPost: "devices.refresh"},
+	"/api/v1/devices/select":   {http.MethodPost: "devices.select"},
+	"/api/v1/library/mark":     {http.MethodPost: "library.mark"},
+	"/api/v1/library/play":     {http.MethodPost: "library.play"},
+	"/api/v1/library/select":   {http.MethodPost: "library.select_media"},
+	"/api/v1/library/subtitle": {http.MethodPost: "library.select_subtitle", http.MethodDelete: "library.clear_subtitle"},
+	"/api/v1/queue":            {http.MethodGet: "", http.MethodPost: "queue.add", http.MethodDelete: "queue.clear"},
+	"/api/v1/queue/batch":      {http.MethodPost: "queue.add_many"},
+	"/api/v1/queue/import":     {http.MethodPost: "queue.import"},
+	"/api/v1/queue/export":     {http.MethodGet: ""},
+	"/api/v1/queue/select":     {http.MethodPost: "queue.select"},
+	"/api/v1/queue/remove":     {http.MethodPost: "queue.remove"},
+	"/api/v1/queue/move":       {http.MethodPost: "queue.move"},
+	"/api/v1/player/play":      {http.MethodPost: "player.play"},
+	"/api/v1/player/resume":    {http.MethodPost: "player.resume"},
+	"/api/v1/player/pause":     {http.MethodPost: "player.pause"},
+	"/api/v1/player/stop":      {http.MethodPost: "player.stop"},
+	"/api/v1/player/volume":    {http.MethodPost: "player.volume"},
+	"/api/v1/player/mute":      {http.MethodPost: "player.mute"},
+	"/api/v1/player/transcode": {http.MethodPost: "player.transcode"},
+	"/api/v1/player/seek":      {http.MethodPost: "player.seek"},
+	"/api/v1/player/sleep":     {http.MethodPost: "player.sleep"},
+	"/api/v1/player/schedule":  {http.MethodPost: "player.schedule"},
+	"/api/v1/player/transfer":  {http.MethodPost: "player.transfer"},
+	"/api/v1/timers":           {http.MethodGet: ""},
+	"/api/v1/policy":           {http.MethodGet: "", http.MethodPut: "playback.policy"},
+	"/api/v1/sessions":         {http.MethodGet: "", http.MethodDelete: "session.close"},
+	"/api/v1/groups":           {http.MethodGet: "", http.MethodPost: "groups.create", http.MethodDelete: "groups.remove"},
+	"/api/v1/history":          {http.MethodGet: ""},
+}
+
+// KnownAPIRoute reports whether path is a versioned API endpoint. Paths carry
+// no identifiers, so callers may log them verbatim.
+func KnownAPIRoute(path string) bool {
+	_, ok := restRoutes[path]
+	return ok
+}
+
+func (h *Handler) rest(w http.ResponseWriter, r *http.Request) {
+	methods, ok := restRoutes[r.URL.Path]
+	if !ok {
+		apiError(w, http.StatusNotFound, "not_found")
+		return
+	}
+	kind, ok := methods[r.Method]
+	if !ok {
+		allowed := make([]string, 0, len(methods))
+		for method := range methods {
+			allowed = append(allowed, method)
+		}
+		slices.Sort(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		methodNotAllowed(w)
+		return
+	}
+	if kind == "" {
+		h.restRead(w, r)
+		return
+	}
+	if readOnly(r.Context()) {
+		apiError(w, http.StatusForbidden, "insufficient_scope")
+		return
+	}
+	payload, err := restPayload(w, r)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	id, err := restRequestID()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), restCommandTimeout)
+	defer cancel()
+	result, extra := h.command(ctx, envelope{ProtocolVersion: ProtocolVersion, Type: kind, ID: id, Session: r.URL.Query().Get("session"), Payload: payload})
+	w.Header().Set("ETag", revisionTag(result.Revision))
+	if !result.OK() {
+		writeJSON(w, restStatus(result.Code), errorDTO{Error: string(result.Code), Message: result.Message, RequestID: result.RequestID, Revision: result.Revision})
+		return
+	}
+	body := commandResultDTO{RequestID: result.RequestID, Revision: result.Revision}
+	if kind == "queue.add_many" || kind == "queue.import" {
+		count := func(key string) int { value, _ := extra[key].(int); return value }
+		writeJSON(w, http.StatusOK, queueBatchResultDTO{commandResultDTO: body, Added: count("added"), Duplicates: count("duplicates"), Dropped: count("dropped"), Failed: count("failed")})
+		return
+	}
+	if kind == "groups.create" {
+		groupID, _ := extra["group_id"].(string)
+		writeJSON(w, http.StatusOK, groupResultDTO{commandResultDTO: body, GroupID: groupID})
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (h *Handler) restRead(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := h.cfg.Controller.SessionSnapshot(r.Context(), r.URL.Query().Get("session"))
+	if errors.Is(err, controller.ErrNotFound) {
+		apiError(w, http.StatusNotFound, "not_found")
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusServiceUnavailable, "unavailable")
+		return
+	}
+	tag := revisionTag(snapshot.Revision)
+	w.Header().Set("ETag", tag)
+	if r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	state := h.snapshotDTO(snapshot)
+	switch r.URL.Path {
+	case "/api/v1/devices":
+		writeJSON(w, http.StatusOK, devicesDTO{Revision: state.Revision, Devices: state.Devices, SelectedDeviceID: state.SelectedDeviceID, ActiveDeviceID: state.ActiveDeviceID})
+	case "/api/v1/queue":
+		writeJSON(w, http.StatusOK, queueListDTO{Revision: state.Revision, Queue: state.Queue})
+	case "/api/v1/policy":
+		writeJSON(w, http.StatusOK, policyDTO{Revision: state.Revision, Policy: state.Policy})
+	case "/api/v1/timers":
+		writeJSON(w, http.StatusOK, timersDTO{Revision: state.Revision, SleepTimer: state.SleepTimer, ScheduledStart: state.ScheduledStart})
+	case "/api/v1/sessions":
+		writeJSON(w, http.StatusOK, sessionsDTO{Revision: state.Revision, Sessions: state.Sessions})
+	case "/api/v1/groups":
+		writeJSON(w, http.StatusOK, groupsDTO{Revision: state.Revision, Groups: state.Groups})
+	case "/api/v1/history":
+		writeJSON(w, http.StatusOK, historyListDTO{Revision: state.Revision, ContinueWatching: state.ContinueWatching})
+	case "/api/v1/queue/export":
+		h.queueExport(w, r)
+	default:
+		writeJSON(w, http.StatusOK, state)
+	}
+}
+
+// restPayload reads the command body and folds an If-Match revision into it as
+// expected_revision, so both transports share one optimistic-concurrency path.
+func restPayload(w http.ResponseWriter, r *http.Request) (json.RawMessage, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageBytes))
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+			return nil, errors.New("payload must be a JSON object")
+		}
+	}
+	if match := r.Header.Get("If-Match"); match != "" {
+		revision, ok := parseRevisionTag(match)
+		if !ok {
+			return nil, errors.New("invalid If-Match")
+		}
+		if revision != nil {
+			if _, ok := fields["expected_revision"]; ok {
+				return nil, errors.New("revision supplied twice")
+			}
+			fields["expected_revision"] = json.RawMessage(strconv.FormatUint(*revision, 10))
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func parseRevisionTag(value string) (*uint64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "*" {
+		return nil, true
+	}
+	if len(value) < 3 || value[0] != '"' || value[len(value)-1] != '"' {
+		return nil, false
+	}
+	revision, err := strconv.ParseUint(value[1:len(value)-1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &revision, true
+}
+
+func revisionTag(revision uint64) string {
+	return `"` + strconv.FormatUint(revision, 10) + `"`
+}
+
+func restRequestID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return "http-" + hex.EncodeToString(id), nil
+}
+
+func restStatus(code controller.ErrorCode) int {
+	switch code {
+	case controller.CodeInvalid:
+		return http.StatusBadRequest
+	case controller.CodeNotFound:
+		return http.StatusNotFound
+	case controller.CodeConflict:
+		return http.StatusPreconditionFailed
+	case controller.CodeBusy, controller.CodeNoDevice, controller.CodeNoMedia, controller.CodeNoSession, controller.CodeAudioOnly, controller.CodeQueueLimit:
+		return http.StatusConflict
+	case controller.CodeClosed, controller.CodeCanceled:
+		return http.StatusServiceUnavailable
+	case controller.CodeDeadline:
+		return http.StatusGatewayTimeout
+	case codeFolderLimit:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,301 @@
+package webui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/internal/resume"
+)
+
+func restCall(t *testing.T, h http.Handler, method, path, body string, header map[string]string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	decoded := map[string]any{}
+	if res.Body.Len() > 0 {
+		if err := json.Unmarshal(res.Body.Bytes(), &decoded); err != nil {
+			t.Fatalf("%s %s body %q: %v", method, path, res.Body.String(), err)
+		}
+	}
+	return res, decoded
+}
+
+func TestRESTQueueLifecycleAndRevisions(t *testing.T) {
+	h, lib, _, rootID := testHandler(t)
+	page, err := lib.Browse(rootID, "", "", 10)
+	if err != nil || len(page.Entries) != 1 {
+		t.Fatalf("browse: %#v %v", page, err)
+	}
+	res, state := restCall(t, h, http.MethodGet, "/api/v1/state", "", nil)
+	if res.Code != http.StatusOK || res.Header().Get("ETag") != `"0"` || state["revision"] != float64(0) {
+		t.Fatalf("state = %d %q %v", res.Code, res.Header().Get("ETag"), state)
+	}
+	if res, _ := restCall(t, h, http.MethodGet, "/api/v1/state", "", map[string]string{"If-None-Match": `"0"`}); res.Code != http.StatusNotModified {
+		t.Fatalf("conditional state = %d", res.Code)
+	}
+	add := `{"root_id":"` + rootID + `","entry_id":"` + page.Entries[0].ID + `"}`
+	res, body := restCall(t, h, http.MethodPost, "/api/v1/queue", add, map[string]string{"If-Match": `"0"`})
+	if res.Code != http.StatusOK || body["revision"] != float64(1) || res.Header().Get("ETag") != `"1"` || !strings.HasPrefix(body["request_id"].(string), "http-") {
+		t.Fatalf("add = %d %v", res.Code, body)
+	}
+	res, body = restCall(t, h, http.MethodDelete, "/api/v1/queue", "", map[string]string{"If-Match": `"0"`})
+	if res.Code != http.StatusPreconditionFailed || body["error"] != "conflict" {
+		t.Fatalf("stale clear = %d %v", res.Code, body)
+	}
+	res, queue := restCall(t, h, http.MethodGet, "/api/v1/queue", "", nil)
+	items, _ := queue["queue"].([]any)
+	if res.Code != http.StatusOK || len(items) != 1 {
+		t.Fatalf("queue = %d %v", res.Code, queue)
+	}
+	if res, body := restCall(t, h, http.MethodDelete, "/api/v1/queue", "", map[string]string{"If-Match": `"1"`}); res.Code != http.StatusOK || body["revision"] != float64(2) {
+		t.Fatalf("clear = %d %v", res.Code, body)
+	}
+}
+
+func TestRESTStatusMapping(t *testing.T) {
+	h, _, _, _ := testHandler(t)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header map[string]string
+		status int
+		code   string
+	}{
+		{name: "nothing playing", method: http.MethodPost, path: "/api/v1/player/pause", status: http.StatusConflict, code: "no_session"},
+		{name: "invalid payload", method: http.MethodPost, path: "/api/v1/player/seek", body: `{"seconds":-1}`, status: http.StatusBadRequest, code: "invalid"},
+		{name: "unknown field", method: http.MethodPost, path: "/api/v1/player/mute", body: `{"muted":true,"extra":1}`, status: http.StatusBadRequest, code: "invalid"},
+		{name: "queue item missing", method: http.MethodPost, path: "/api/v1/queue/remove", body: `{"item_id":"missing"}`, status: http.StatusNotFound, code: "not_found"},
+		{name: "non-object body", method: http.MethodPost, path: "/api/v1/player/stop", body: `[]`, status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "weak etag", method: http.MethodPost, path: "/api/v1/player/stop", header: map[string]string{"If-Match": `W/"0"`}, status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "revision twice", method: http.MethodPost, path: "/api/v1/player/stop", body: `{"expected_revision":0}`, header: map[string]string{"If-Match": `"0"`}, status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "transfer to unknown device", method: http.MethodPost, path: "/api/v1/player/transfer", body: `{"device_id":"tv"}`, status: http.StatusNotFound, code: "not_found"},
+		{name: "transfer without device", method: http.MethodPost, path: "/api/v1/player/transfer", body: `{}`, status: http.StatusBadRequest, code: "invalid"},
+		{name: "wrong method", method: http.MethodGet, path: "/api/v1/player/seek", status: http.StatusMethodNotAllowed, code: "method_not_allowed"},
+		{name: "unknown route", method: http.MethodGet, path: "/api/v1/unknown", status: http.StatusNotFound, code: "not_found"},
+		{name: "unknown session", method: http.MethodPost, path: "/api/v1/player/pause?session=missing", status: http.StatusNotFound, code: "not_found"},
+		{name: "unknown session read", method: http.MethodGet, path: "/api/v1/queue?session=missing", status: http.StatusNotFound, code: "not_found"},
+		{name: "close default session", method: http.MethodDelete, path: "/api/v1/sessions", status: http.StatusBadRequest, code: "invalid"},
+		{name: "group of one", method: http.MethodPost, path: "/api/v1/groups", body: `{"device_ids":["tv"]}`, status: http.StatusBadRequest, code: "invalid"},
+		{name: "group tolerance", method: http.MethodPost, path: "/api/v1/groups", body: `{"device_ids":["tv","radio"],"tolerance_ms":0}`, status: http.StatusBadRequest, code: "invalid"},
+		{name: "group of unknown devices", method: http.MethodPost, path: "/api/v1/groups", body: `{"device_ids":["tv","radio"]}`, status: http.StatusNotFound, code: "not_found"},
+		{name: "unknown group", method: http.MethodDelete, path: "/api/v1/groups", body: `{"group_id":"group:9"}`, status: http.StatusNotFound, code: "not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := restCall(t, h, tt.method, tt.path, tt.body, tt.header)
+			if res.Code != tt.status || body["error"] != tt.code {
+				t.Fatalf("%s %s = %d %v", tt.method, tt.path, res.Code, body)
+			}
+		})
+	}
+	if res, _ := restCall(t, h, http.MethodPatch, "/api/v1/queue", "", nil); res.Header().Get("Allow") != "DELETE, GET, POST" {
+		t.Fatalf("allow = %q", res.Header().Get("Allow"))
+	}
+}
+
+func TestRESTPolicyRoundTrip(t *testing.T) {
+	h, _, _, _ := testHandler(t)
+	res, body := restCall(t, h, http.MethodPut, "/api/v1/policy", `{"policy":{"LoopSelected":true}}`, map[string]string{"If-Match": "*"})
+	if res.Code != http.StatusOK {
+		t.Fatalf("put policy = %d %v", res.Code, body)
+	}
+	revision := strconv.FormatFloat(body["revision"].(float64), 'f', 0, 64)
+	res, body = restCall(t, h, http.MethodGet, "/api/v1/policy", "", nil)
+	policy, _ := body["policy"].(map[string]any)
+	if res.Code != http.StatusOK || policy["LoopSelected"] != true || res.Header().Get("ETag") != `"`+revision+`"` {
+		t.Fatalf("get policy = %d %v", res.Code, body)
+	}
+}
+
+func TestRESTSleepTimerAndSchedule(t *testing.T) {
+	h, _, _, _ := testHandler(t)
+	if res, body := restCall(t, h, http.MethodPost, "/api/v1/player/sleep", `{"mode":"time","minutes":45}`, nil); res.Code != http.StatusOK {
+		t.Fatalf("sleep = %d %v", res.Code, body)
+	}
+	res, body := restCall(t, h, http.MethodGet, "/api/v1/timers", "", nil)
+	sleep, _ := body["sleep_timer"].(map[string]any)
+	deadline, err := time.Parse(time.RFC3339, fmt.Sprint(sleep["deadline"]))
+	if res.Code != http.StatusOK || sleep["mode"] != "time" || err != nil || time.Until(deadline) < 44*time.Minute {
+		t.Fatalf("timers = %d %v", res.Code, body)
+	}
+	for _, payload := range []string{`{"mode":"time"}`, `{"mode":"time","minutes":1441}`, `{"mode":"soon"}`, `{"minutes":5}`} {
+		if res, body := restCall(t, h, http.MethodPost, "/api/v1/player/sleep", payload, nil); res.Code != http.StatusBadRequest {
+			t.Fatalf("sleep %s = %d %v", payload, res.Code, body)
+		}
+	}
+	restCall(t, h, http.MethodPost, "/api/v1/player/sleep", `{"mode":"off"}`, nil)
+	if _, body := restCall(t, h, http.MethodGet, "/api/v1/timers", "", nil); body["sleep_timer"].(map[string]any)["mode"] != "off" {
+		t.Fatalf("canceled timers = %v", body)
+	}
+
+	at := time.Now().Add(time.Hour).Format(time.RFC3339)
+	if res, body := restCall(t, h, http.MethodPost, "/api/v1/player/schedule", `{"at":"`+at+`"}`, nil); res.Code != http.StatusConflict || body["error"] != "no_device" {
+		t.Fatalf("schedule without device = %d %v", res.Code, body)
+	}
+	if res, body := restCall(t, h, http.MethodPost, "/api/v1/player/schedule", `{"at":"07:00"}`, nil); res.Code != http.StatusBadRequest {
+		t.Fatalf("schedule bad time = %d %v", res.Code, body)
+	}
+	if res, body := restCall(t, h, http.MethodPost, "/api/v1/player/schedule", "", nil); res.Code != http.StatusOK {
+		t.Fatalf("cancel schedule = %d %v", res.Code, body)
+	}
+}
+
+func TestRESTReadOnlyRefusesCommands(t *testing.T) {
+	h, _, _, _ := testHandler(t)
+	for method, path := range map[string]string{http.MethodGet: "/api/v1/state", http.MethodPost: "/api/v1/player/stop"} {
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, h.ReadOnly(httptest.NewRequest(method, path, nil)))
+		want := http.StatusOK
+		if method == http.MethodPost {
+			want = http.StatusForbidden
+		}
+		if res.Code != want {
+			t.Fatalf("%s %s = %d, want %d", method, path, res.Code, want)
+		}
+	}
+}
+
+func TestRESTHistoryListsResolvableEntries(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "movie.mp4"), []byte("media"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lib, err := library.Open(library.Config{Roots: []string{root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, _ := resume.Open("")
+	control := controller.New(controller.Config{Resume: history})
+	h, err := New(Config{Version: "test", Controller: control, Library: lib})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close(); control.Close(); _ = lib.Close() })
+	rootID := lib.Roots()[0].ID
+	page, err := lib.Browse(rootID, "", "", 1)
+	if err != nil || len(page.Entries) != 1 {
+		t.Fatalf("browse = %#v, err=%v", page, err)
+	}
+	location, err := lib.Locate(rootID, page.Entries[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, entry := range []resume.Entry{
+		{Key: location, Name: "movie.mp4", Kind: "video", Position: 125, Duration: 600, UpdatedAt: now},
+		{Key: library.Location{Root: location.Root, Path: "deleted.mp4"}, Name: "deleted.mp4", Kind: "video", Position: 60, UpdatedAt: now.Add(time.Second)},
+	} {
+		if err := history.Save(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, body := restCall(t, h, http.MethodGet, "/api/v1/history", "", nil)
+	entries, _ := body["continue_watching"].([]any)
+	if res.Code != http.StatusOK || len(entries) != 1 {
+		t.Fatalf("history = %d %v", res.Code, body)
+	}
+	entry := entries[0].(map[string]any)
+	if entry["root_id"] != rootID || entry["entry_id"] != page.Entries[0].ID || entry["position"] != float64(125) || entry["duration"] != float64(600) {
+		t.Fatalf("entry = %v", entry)
+	}
+
+	play := `{"root_id":"` + rootID + `","entry_id":"` + page.Entries[0].ID + `","resume":true}`
+	if res, body := restCall(t, h, http.MethodPost, "/api/v1/library/play", play, nil); res.Code != http.StatusConflict || body["error"] != "no_device" {
+		t.Fatalf("resume = %d %v", res.Code, body)
+	}
+	if res, body := restCall(t, h, http.MethodPost, "/api/v1/library/select", play, nil); res.Code != http.StatusBadRequest || body["error"] != "invalid" {
+		t.Fatalf("select with resume = %d %v", res.Code, body)
+	}
+}
+
+func TestRESTMarkFolderWatched(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "show"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"show/e01.mp4", "show/e02.mp4", "movie.mp4"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("media"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history, _ := resume.Open("")
+	lib, err := library.Open(library.Config{Roots: []string{root}, Progress: history.Progress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	control := controller.New(controller.Config{Resume: history})
+	h, err := New(Config{Version: "test", Controller: control, Library: lib})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close(); control.Close(); _ = lib.Close() })
+	rootID := lib.Roots()[0].ID
+	browse := func(parentID, filter string) []entryDTO {
+		t.Helper()
+		query := url.Values{"root_id": {rootID}, "filter": {filter}}
+		if parentID != "" {
+			query.Set("parent_id", parentID)
+		}
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/library?"+query.Encode(), nil))
+		var page libraryPageDTO
+		if err := json.Unmarshal(res.Body.Bytes(), &page); err != nil || res.Code != http.StatusOK {
+			t.Fatalf("browse %q = %d %s", filter, res.Code, res.Body.String())
+		}
+		return page.Entries
+	}
+	var showID string
+	for _, entry := range browse("", "") {
+		if entry.Name == "show" {
+			showID = entry.ID
+		}
+	}
+
+	mark := `{"root_id":"` + rootID + `","entry_id":"` + showID + `","watched":true}`
+	if res, body := restCall(t, h, http.MethodPost, "/api/v1/library/mark", mark, nil); res.Code != http.StatusOK {
+		t.Fatalf("mark = %d %v", res.Code, body)
+	}
+	if watched := browse(showID, "watched"); len(watched) != 2 || !watched[0].Watched {
+		t.Fatalf("watched episodes = %+v", watched)
+	}
+	// Directories stay listed so the filter can be applied inside them.
+	if unwatched := browse("", "unwatched"); len(unwatched) != 2 || unwatched[0].Name != "movie.mp4" || unwatched[1].Kind != "directory" {
+		t.Fatalf("unwatched = %+v", unwatched)
+	}
+	if res, body := restCall(t, h, http.MethodPost, "/api/v1/library/mark", `{"root_id":"`+rootID+`","watched":false}`, nil); res.Code != http.StatusOK {
+		t.Fatalf("unmark root = %d %v", res.Code, body)
+	}
+	if watched := browse(showID, "watched"); len(watched) != 0 {
+		t.Fatalf("still watched = %+v", watched)
+	}
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/library?root_id="+rootID+"&filter=seen", nil))
+	if res.Code != http.StatusBadRequest || !strings.Contains(res.Body.String(), "invalid_filter") {
+		t.Fatalf("unknown filter = %d %s", res.Code, res.Body.String())
+	}
+	if res, body := restCall(t, h, http.MethodPost, "/api/v1/library/mark", `{"root_id":"`+rootID+`"}`, nil); res.Code != http.StatusBadRequest {
+		t.Fatalf("mark without watched = %d %v", res.Code, body)
+	}
+	if res, body := restCall(t, h, http.MethodPost, "/api/v1/library/mark", `{"root_id":"gone","watched":true}`, nil); res.Code != http.StatusNotFound {
+		t.Fatalf("mark in an unknown root = %d %v", res.Code, body)
+	}
+}
@@ -1,5 +1,6 @@
 const protocolVersion = 1;
 const maxConflictRetries = 2;
+const searchDelay = 250;
 
 export function startClient(env) {
   const {
@@ -23,24 +24,35 @@ export function startClient(env) {
     roots = byID("roots"),
     library = byID("library"),
     queue = byID("queue"),
+    history = byID("history"),
     toast = byID("toast"),
     pendingNode = byID("pending"),
     breadcrumbs = byID("breadcrumbs"),
     folderUp = byID("folder-up"),
     addVisible = byID("add-visible"),
     addVisibleCount = byID("add-visible-count"),
+    librarySearch = byID("library-search"),
+    libraryRecent = byID("library-recent"),
+    progressFilter = byID("library-progress"),
+    markWatched = byID("mark-watched"),
+    markUnwatched = byID("mark-unwatched"),
     backToTop = byID("back-to-top");
   const state = {
     revision: 0,
     devices: [],
     queue: [],
+    continue_watching: [],
     policy: {
       LoopSelected: false,
       AutoPlayNext: false,
       AutoPlaySameType: false,
       GaplessEnabled: false,
+      RepeatAll: false,
+      Shuffle: false,
       ImageDurationSeconds: 10,
     },
+    sleep_timer: { mode: "off" },
+    scheduled_start: {},
     selected_device_id: "",
     selected_media: false,
     selected_media_name: "",
@@ -68,7 +80,13 @@ export function startClient(env) {
     libraryEntries = [],
     libraryParent = "",
     libraryCursor = "",
+    searchQuery = "",
+    recentView = false,
+    detailsAvailable = false,
+    detailsEntry = "",
+    searchTimer,
     queueLimit = 1000,
+    messageLimit = 256 << 10,
     queueRenderKey = "",
     queueFocus = null,
     queueDrag = null,
@@ -93,7 +111,11 @@ export function startClient(env) {
       "player.mute",
       "player.transcode",
     ]),
-    devicePendingTypes = new Set(["devices.select", "devices.refresh"]);
+    devicePendingTypes = new Set([
+      "devices.select",
+      "devices.refresh",
+      "player.transfer",
+    ]);
   const svgNS = "http://www.w3.org/2000/svg";
   const option = (value, label) => {
     const node = document.createElement("option");
@@ -180,6 +202,19 @@ export function startClient(env) {
       ? `${h}:${String(m).padStart(2, "0")}:${String(s).padStart(2, "0")}`
       : `${m}:${String(s).padStart(2, "0")}`;
   };
+  const clockTime = (value) => {
+    const date = new Date(value);
+    return `${String(date.getHours()).padStart(2, "0")}:${String(date.getMinutes()).padStart(2, "0")}`;
+  };
+  // nextOccurrence returns the next local time matching an HH:MM input value.
+  const nextOccurrence = (value) => {
+    const [hours, minutes] = String(value).split(":").map(Number);
+    if (!Number.isInteger(hours) || !Number.isInteger(minutes)) return null;
+    const at = new Date();
+    at.setHours(hours, minutes, 0, 0);
+    if (at <= new Date()) at.setDate(at.getDate() + 1);
+    return at;
+  };
   const normalizeImageDuration = (value) => {
     const seconds = Number(value);
     if (!Number.isFinite(seconds) || seconds <= 0) return 0;
@@ -192,8 +227,70 @@ export function startClient(env) {
     const subtitle = isSubtitle(item.name),
       label = subtitle ? "Subtitle" : kindLabel(item.media_kind),
       dot = item.name.lastIndexOf("."),
-      format = dot > 0 ? item.name.slice(dot + 1).toUpperCase() : "";
-    return format ? `${label} · ${format}` : label;
+      format = dot > 0 ? item.name.slice(dot + 1).toUpperCase() : "",
+      meta = format ? `${label} · ${format}` : label;
+    return item.parent ? `${meta} · ${item.parent}` : meta;
+  };
+  const entryStatus = (item) => {
+    const nodes = [];
+    if (item.watched) {
+      const badge = document.createElement("span");
+      badge.className = "entry-badge";
+      badge.textContent =
+        item.play_count > 1 ? `Watched ${item.play_count}×` : "Watched";
+      nodes.push(badge);
+    }
+    if (item.position && item.duration) {
+      const bar = document.createElement("progress");
+      bar.className = "entry-progress";
+      bar.max = item.duration;
+      bar.value = item.position;
+      bar.title = `${format(item.position)} of ${format(item.duration)}`;
+      bar.ariaLabel = bar.title;
+      nodes.push(bar);
+    } else if (item.position) {
+      const badge = document.createElement("span");
+      badge.className = "entry-badge";
+      badge.textContent = `Stopped at ${format(item.position)}`;
+      nodes.push(badge);
+    }
+    if (!nodes.length) return null;
+    const status = document.createElement("span");
+    status.className = "entry-status";
+    status.append(...nodes);
+    return status;
+  };
+  // entryDetails lists what probing found; absent fields are left out.
+  const entryDetails = (media) => {
+    const list = document.createElement("dl"),
+      rows = [
+        ["Duration", media.duration ? format(media.duration) : ""],
+        [
+          "Resolution",
+          media.width && media.height ? `${media.width}×${media.height}` : "",
+        ],
+        ["Video", media.video_codec?.toUpperCase()],
+        ["Audio", media.audio_codec?.toUpperCase()],
+        ["Languages", media.audio_languages?.join(", ")],
+        ["Subtitles", media.subtitles?.join(", ")],
+        ["Title", media.title],
+        ["Artist", media.artist],
+        ["Album", media.album],
+      ].filter(([, value]) => value);
+    list.className = "entry-details";
+    if (!rows.length) {
+      const none = document.createElement("dd");
+      none.textContent = "No details found.";
+      list.append(none);
+    }
+    for (const [name, value] of rows) {
+      const term = document.createElement("dt"),
+        detail = document.createElement("dd");
+      term.textContent = name;
+      detail.textContent = value;
+      list.append(term, detail);
+    }
+    return list;
   };
   const mediaGlyph = (kind) =>
     ({ audio: "♪", video: "▶", image: "▧" })[kind] || "•";
@@ -241,6 +338,7 @@ export function startClient(env) {
     toggle.title = label;
     toggle.ariaLabel = label;
   }
+  const playlistFormats = ["m3u8", "m3u", "pls", "xspf"];
   function bulkAddToast(p, truncated = 0) {
     const added = p.added || 0,
       duplicates = p.duplicates || 0,
@@ -312,11 +410,17 @@ export function startClient(env) {
     if (!type) {
       renderPlayback();
       renderQueue();
+      renderHistory();
       renderDevices();
+      renderMarkButtons();
       return;
     }
+    if (type === "library.mark") renderMarkButtons();
     if (affectsDevices(request)) renderDevices();
-    if (affectsQueue(request)) renderQueue();
+    if (affectsQueue(request)) {
+      renderQueue();
+      renderHistory();
+    }
     if (affectsPlayback(request)) renderPlayback();
   }
   function renderDevices() {
@@ -356,6 +460,20 @@ export function startClient(env) {
     }
     byID("refresh").disabled =
       !connected || shuttingDown || hasPending("devices.refresh");
+    const transfer = byID("transfer"),
+      transferLabel = selectedDevice
+        ? `Move playback to ${selectedDevice.label}`
+        : "Move playback";
+    transfer.hidden =
+      !state.has_session ||
+      !selectedDevice ||
+      selected === state.active_device_id;
+    transfer.disabled =
+      disabled ||
+      ["LOADING", "STOPPING"].includes(playbackState()) ||
+      hasPending("player.transfer");
+    transfer.title = transferLabel;
+    transfer.ariaLabel = transferLabel;
   }
   function appendDeviceContent(node, device) {
     const name = document.createElement("span"),
@@ -542,6 +660,8 @@ export function startClient(env) {
     if (queueDrag) finishQueueDrag(undefined, false);
     queueRenderKey = key;
     byID("queue-clear").disabled = !connected || locked || !items.length;
+    byID("queue-import").disabled = !connected || locked;
+    byID("queue-export").disabled = !connected || !items.length;
     queue.replaceChildren();
     text("queue-count", String(items.length));
     if (!items.length) {
@@ -612,6 +732,50 @@ export function startClient(env) {
       currentRow?.scrollIntoView({ behavior: "smooth", block: "nearest" });
     }
   }
+  function renderHistory() {
+    const items = state.continue_watching || [],
+      locked = !connected || queueLocked();
+    byID("history-card").hidden = !items.length;
+    text("history-count", String(items.length));
+    history.replaceChildren();
+    for (const item of items) {
+      const row = document.createElement("li"),
+        copy = document.createElement("div"),
+        label = document.createElement("strong"),
+        meta = document.createElement("span"),
+        resumeLabel = `Resume from ${format(item.position)}`;
+      row.className = "history-row";
+      copy.className = "entry-copy";
+      label.className = "entry-name";
+      label.textContent = item.name || "Untitled media";
+      label.title = label.textContent;
+      meta.className = "entry-meta";
+      meta.textContent = item.duration
+        ? `${format(item.position)} of ${format(item.duration)}`
+        : `Stopped at ${format(item.position)}`;
+      copy.append(label, meta);
+      row.append(
+        copy,
+        actionGroup(
+          button(resumeLabel, () => playHistory(item, true), {
+            disabled: locked,
+            className: "primary-action icon-action",
+            icon: "play",
+            title: resumeLabel,
+            ariaLabel: `${resumeLabel}: ${label.textContent}`,
+          }),
+          button("Start over", () => playHistory(item, false), {
+            disabled: locked,
+            className: "icon-action",
+            icon: "rotate-ccw",
+            title: "Start over",
+            ariaLabel: `Start ${label.textContent} over`,
+          }),
+        ),
+      );
+      history.append(row);
+    }
+  }
   function renderProgress() {
     const seek = byID("seek");
     const sliderPosition = Math.min(
@@ -736,12 +900,55 @@ export function startClient(env) {
     byID("autoplay").checked = !!p.AutoPlayNext;
     byID("same-type").checked = !!p.AutoPlaySameType;
     byID("gapless").checked = !!p.GaplessEnabled;
+    byID("repeat-all").checked = !!p.RepeatAll;
+    byID("shuffle").checked = !!p.Shuffle;
     byID("image-duration").value = String(
       normalizeImageDuration(p.ImageDurationSeconds ?? 10),
     );
     byID("same-type").disabled = !p.AutoPlayNext;
+    // Repeat all only starts a shuffled order over; sequential autoplay
+    // always wraps.
+    byID("repeat-all").disabled = !p.AutoPlayNext || !p.Shuffle;
+    byID("shuffle").disabled = !p.AutoPlayNext;
     byID("gapless").disabled = !p.AutoPlayNext || !gaplessSupported;
   }
+  function renderTimers() {
+    const sleep = state.sleep_timer || {},
+      schedule = state.scheduled_start || {},
+      mode = sleep.mode || "off";
+    byID("sleep-mode").value = mode === "time" ? "running" : mode;
+    byID("sleep-running").hidden = mode !== "time";
+    byID("sleep-running").textContent =
+      mode === "time" && sleep.deadline
+        ? `Until ${clockTime(sleep.deadline)}`
+        : "";
+    byID("schedule-cancel").hidden = !schedule.at;
+    byID("schedule-status").textContent = schedule.at
+      ? `Starts at ${clockTime(schedule.at)} on ${schedule.device_label || "the selected device"}`
+      : "Play the playlist at a set time";
+  }
+  function sendSleep() {
+    const value = byID("sleep-mode").value;
+    if (value === "running") return;
+    const minutes = Number(value);
+    send(
+      "player.sleep",
+      Number.isInteger(minutes) && minutes > 0
+        ? { mode: "time", minutes }
+        : { mode: value },
+    );
+  }
+  function sendSchedule() {
+    const at = nextOccurrence(byID("schedule-time").value);
+    if (!at) {
+      showToast("Choose a start time", "error");
+      return;
+    }
+    send("player.schedule", {
+      at: at.toISOString(),
+      device_id: state.selected_device_id,
+    });
+  }
   function playLibraryMedia(item) {
     const previousCurrentID =
       state.queue.find((queued) => queued.selected)?.id || "";
@@ -752,11 +959,18 @@ export function startClient(env) {
     renderPlayback();
     renderLibrary();
     const requestID = send("library.play", {
-      root_id: selectedRoot,
+      root_id: item.root_id || selectedRoot,
       entry_id: item.id,
     });
     queueFocus = requestID ? { requestID, previousCurrentID } : null;
   }
+  function playHistory(item, resume) {
+    send("library.play", {
+      root_id: item.root_id,
+      entry_id: item.entry_id,
+      resume,
+    });
+  }
   function selectLibrarySubtitle(item) {
     const requestID = send("library.select_subtitle", {
       root_id: selectedRoot,
@@ -770,8 +984,10 @@ export function startClient(env) {
   function renderAll() {
     renderDevices();
     renderQueue();
+    renderHistory();
     renderPlayback();
     renderPolicy();
+    renderTimers();
     if (libraryEntries.length) renderLibrary();
   }
   function mergeSnapshot(payload) {
@@ -781,6 +997,8 @@ export function startClient(env) {
     state.active_media_name = payload.active_media_name ?? "";
     state.playback_state = payload.playback_state ?? state.playback_state;
     state.policy = payload.policy ?? state.policy;
+    state.sleep_timer = payload.sleep_timer ?? state.sleep_timer;
+    state.scheduled_start = payload.scheduled_start ?? state.scheduled_start;
     state.revision = payload.revision ?? state.revision;
     renderAll();
   }
@@ -865,6 +1083,17 @@ export function startClient(env) {
         state.policy = p.policy || state.policy;
         renderPolicy();
         break;
+      case "state.timers":
+        state.revision = p.revision ?? state.revision;
+        state.sleep_timer = p.sleep_timer || state.sleep_timer;
+        state.scheduled_start = p.scheduled_start || {};
+        renderTimers();
+        break;
+      case "state.history":
+        state.revision = p.revision ?? state.revision;
+        state.continue_watching = p.continue_watching || [];
+        renderHistory();
+        break;
       case "pending":
         if (!pending.has(message.id)) pending.set(message.id, null);
         renderPending(pending.get(message.id));
@@ -873,8 +1102,12 @@ export function startClient(env) {
         const request = pending.get(message.id);
         pending.delete(message.id);
         state.revision = p.revision ?? state.revision;
-        if (request?.type === "queue.add_many")
+        if (
+          request?.type === "queue.add_many" ||
+          request?.type === "queue.import"
+        )
           bulkAddToast(p, request.truncated || 0);
+        if (request?.type === "library.mark") browse(libraryParent);
         renderPending(request);
         break;
       }
@@ -971,6 +1204,8 @@ export function startClient(env) {
         return;
       }
       transcodeAvailable = !!bootstrap.features?.transcode;
+      libraryRecent.hidden = !bootstrap.features?.library_index;
+      detailsAvailable = !!bootstrap.features?.library_details;
       if (instanceID !== (bootstrap.instance_id || ""))
         await refreshLibrary(bootstrap);
       shuttingDown = false;
@@ -1006,6 +1241,7 @@ export function startClient(env) {
   // current root and folder path by name to mint fresh IDs.
   async function refreshLibrary(bootstrap) {
     queueLimit = bootstrap.limits?.queue_items || queueLimit;
+    messageLimit = bootstrap.limits?.ws_message_bytes || messageLimit;
     const previousRootName = [...roots.children].find(
       (node) => node.value === selectedRoot,
     )?.textContent;
@@ -1028,7 +1264,12 @@ export function startClient(env) {
         parentID = nextID;
       }
     instanceID = bootstrap.instance_id || "";
-    await browse(parentID);
+    if (!searchQuery && !recentView) {
+      await browse(parentID);
+      return;
+    }
+    libraryParent = parentID;
+    await (recentView ? showRecent() : searchLibrary(searchQuery));
   }
   function send(type, payload = {}, attempt = 0) {
     if (ws?.readyState !== WebSocket.OPEN) {
@@ -1056,6 +1297,19 @@ export function startClient(env) {
       parents = [];
       browse();
     });
+    renderMarkButtons();
+    libraryRecent.ariaPressed = String(recentView);
+    if (searchQuery || recentView) {
+      const results = document.createElement("span");
+      results.className = "search-crumb";
+      results.ariaCurrent = "page";
+      results.textContent = recentView
+        ? "Recently added"
+        : `Results for “${searchQuery}”`;
+      breadcrumbs.append(root, results);
+      folderUp.hidden = true;
+      return;
+    }
     if (!parents.length) root.ariaCurrent = "page";
     breadcrumbs.append(root);
     for (const [index, parent] of parents.entries()) {
@@ -1080,9 +1334,13 @@ export function startClient(env) {
     if (!entries.length) {
       const empty = document.createElement("li");
       empty.className = "empty-state";
-      empty.textContent = byID("library-filter").value.trim()
-        ? "No matches in this folder."
-        : "This folder is empty.";
+      empty.textContent = recentView
+        ? "Nothing has been added yet."
+        : searchQuery
+          ? "No media matches this search."
+        : byID("library-filter").value.trim() || progressFilter.value
+          ? "No matches in this folder."
+          : "This folder is empty.";
       library.append(empty);
       renderLoadMore();
       return;
@@ -1109,6 +1367,8 @@ export function startClient(env) {
       meta.className = "entry-meta";
       meta.textContent = entryMeta(item);
       copy.append(label, meta);
+      const status = item.kind === "directory" ? null : entryStatus(item);
+      if (status) copy.append(status);
       if (item.thumbnail_url) main.append(mediaThumbnail(item));
       else {
         const icon = document.createElement("span");
@@ -1153,7 +1413,10 @@ export function startClient(env) {
             button(
               "Add to playlist",
               () =>
-                send("queue.add", { root_id: selectedRoot, entry_id: item.id }),
+                send("queue.add", {
+                  root_id: item.root_id || selectedRoot,
+                  entry_id: item.id,
+                }),
               {
                 className: "icon-action",
                 icon: "list-plus",
@@ -1161,12 +1424,38 @@ export function startClient(env) {
                 ariaLabel: `Add ${item.name} to playlist`,
               },
             ),
+            ...(detailsAvailable
+              ? [
+                  button("Details", () => toggleDetails(item), {
+                    className: "icon-action",
+                    icon: "info",
+                    title: "Details",
+                    ariaLabel: `Details for ${item.name}`,
+                  }),
+                ]
+              : []),
           ),
         );
+      if (detailsEntry === item.id && item.media)
+        row.append(entryDetails(item.media));
       library.append(row);
     }
     renderLoadMore();
   }
+  function renderMarkButtons() {
+    const listing = !!searchQuery || recentView,
+      disabled = !connected || listing || hasPending("library.mark");
+    markWatched.disabled = disabled;
+    markUnwatched.disabled = disabled;
+    progressFilter.disabled = listing;
+  }
+  function markFolder(watched) {
+    send("library.mark", {
+      root_id: selectedRoot,
+      entry_id: libraryParent,
+      watched,
+    });
+  }
   function renderAddVisible(count) {
     const label = count
       ? `Add ${count} listed ${count === 1 ? "file" : "files"} to playlist`
@@ -1187,7 +1476,8 @@ export function startClient(env) {
     nav.className = "browser-nav";
     const more = button("Load more", () => {
       more.disabled = true;
-      browse(libraryParent, libraryCursor, true);
+      if (searchQuery) searchLibrary(searchQuery, libraryCursor, true);
+      else browse(libraryParent, libraryCursor, true);
     });
     nav.append(more);
     library.append(nav);
@@ -1196,7 +1486,12 @@ export function startClient(env) {
     const query = new URLSearchParams({ root_id: selectedRoot, limit: "200" });
     if (parentID) query.set("parent_id", parentID);
     if (cursor) query.set("cursor", cursor);
+    if (progressFilter.value) query.set("filter", progressFilter.value);
     if (!append) {
+      clearTimeout(searchTimer);
+      searchQuery = "";
+      recentView = false;
+      librarySearch.value = "";
       library.replaceChildren();
       const loading = document.createElement("li");
       loading.className = "empty-state loading-state";
@@ -1223,11 +1518,105 @@ export function startClient(env) {
       if (append) renderLibrary();
     }
   }
+  // Search pages stop after a bounded scan, so an empty page can still carry
+  // a cursor; keep reading until something matches or the walk ends.
+  async function searchLibrary(query, cursor = "", append = false) {
+    searchQuery = query;
+    recentView = false;
+    if (!append) {
+      library.replaceChildren();
+      const loading = document.createElement("li");
+      loading.className = "empty-state loading-state";
+      loading.textContent = "Searching…";
+      library.append(loading);
+    }
+    try {
+      let results = [];
+      do {
+        const params = new URLSearchParams({ q: query, limit: "200" });
+        if (cursor) params.set("cursor", cursor);
+        const response = await fetch(`/api/library/search?${params}`, {
+            headers: { Accept: "application/json" },
+          }),
+          data = await response.json();
+        if (!response.ok) throw new Error(data.error || "Search failed");
+        if (searchQuery !== query) return;
+        results = results.concat(data.entries || []);
+        cursor = data.cursor || "";
+      } while (!results.length && cursor);
+      libraryEntries = (
+        append ? [...libraryEntries, ...results] : results
+      ).sort(byName);
+      libraryCursor = cursor;
+      renderBreadcrumbs();
+      renderLibrary();
+    } catch (error) {
+      showToast(error.message, "error");
+      if (append) renderLibrary();
+    }
+  }
+  // The server lists recently added files newest first, so they keep its order.
+  // toggleDetails shows or hides the details of a library file, probing it
+  // first when the listing did not carry a result.
+  async function toggleDetails(item) {
+    if (detailsEntry === item.id) {
+      detailsEntry = "";
+      renderLibrary();
+      return;
+    }
+    detailsEntry = item.id;
+    if (!item.media) {
+      try {
+        const query = new URLSearchParams({
+            root_id: item.root_id || selectedRoot,
+            entry_id: item.id,
+          }),
+          response = await fetch(`/api/library/details?${query}`, {
+            headers: { Accept: "application/json" },
+          }),
+          data = await response.json();
+        if (!response.ok) throw new Error(data.error || "Details failed");
+        item.media = data;
+      } catch (error) {
+        if (detailsEntry === item.id) detailsEntry = "";
+        showToast(error.message, "error");
+        return;
+      }
+    }
+    if (detailsEntry === item.id) renderLibrary();
+  }
+  async function showRecent() {
+    clearTimeout(searchTimer);
+    searchQuery = "";
+    recentView = true;
+    librarySearch.value = "";
+    library.replaceChildren();
+    const loading = document.createElement("li");
+    loading.className = "empty-state loading-state";
+    loading.textContent = "Loading recently added…";
+    library.append(loading);
+    try {
+      const response = await fetch("/api/library/recent?limit=200", {
+          headers: { Accept: "application/json" },
+        }),
+        data = await response.json();
+      if (!response.ok) throw new Error(data.error || "Recently added failed");
+      if (!recentView) return;
+      libraryEntries = data.entries || [];
+      libraryCursor = "";
+      renderBreadcrumbs();
+      renderLibrary();
+    } catch (error) {
+      showToast(error.message, "error");
+    }
+  }
   function sendPolicy(changed = "") {
     if (changed === "loop" && byID("loop").checked) {
       byID("autoplay").checked = false;
       byID("same-type").checked = false;
       byID("gapless").checked = false;
+      byID("repeat-all").checked = false;
+      byID("shuffle").checked = false;
     } else if (changed === "autoplay" && byID("autoplay").checked)
       byID("loop").checked = false;
     const auto = byID("autoplay").checked,
@@ -1239,6 +1628,9 @@ export function startClient(env) {
         AutoPlayNext: auto,
         AutoPlaySameType: auto && byID("same-type").checked,
         GaplessEnabled: auto && byID("gapless").checked,
+        RepeatAll:
+          auto && byID("shuffle").checked && byID("repeat-all").checked,
+        Shuffle: auto && byID("shuffle").checked,
         ImageDurationSeconds: duration,
       },
     });
@@ -1257,7 +1649,10 @@ export function startClient(env) {
     assetsHash = bootstrap.assets_hash || "";
     instanceID = bootstrap.instance_id || "";
     transcodeAvailable = !!bootstrap.features?.transcode;
+    libraryRecent.hidden = !bootstrap.features?.library_index;
+    detailsAvailable = !!bootstrap.features?.library_details;
     queueLimit = bootstrap.limits?.queue_items || queueLimit;
+    messageLimit = bootstrap.limits?.ws_message_bytes || messageLimit;
     mergeSnapshot(bootstrap.snapshot);
     roots.replaceChildren();
     for (const root of bootstrap.roots || [])
@@ -1271,6 +1666,20 @@ export function startClient(env) {
     parents = [];
     browse();
   });
+  librarySearch.addEventListener("input", () => {
+    clearTimeout(searchTimer);
+    searchTimer = setTimeout(() => {
+      const query = librarySearch.value.trim();
+      if (query) searchLibrary(query);
+      else if (searchQuery) browse(libraryParent);
+    }, searchDelay);
+  });
+  libraryRecent.addEventListener("click", () =>
+    recentView ? browse(libraryParent) : showRecent(),
+  );
+  progressFilter.addEventListener("change", () => browse(libraryParent));
+  markWatched.addEventListener("click", () => markFolder(true));
+  markUnwatched.addEventListener("click", () => markFolder(false));
   folderUp.addEventListener("click", () => {
     if (!parents.length) return;
     parents.pop();
@@ -1279,16 +1688,61 @@ export function startClient(env) {
   addVisible.addEventListener("click", () => {
     const playable = playableEntries(filteredEntries());
     if (!playable.length || hasPending("queue.add_many")) return;
+    // Search results can span roots; each root gets its own batch.
     const batch = playable.slice(0, queueLimit),
-      id = send("queue.add_many", {
-        root_id: selectedRoot,
-        entry_ids: batch.map((item) => item.id),
-      }),
-      request = id && pending.get(id);
+      byRoot = new Map();
+    for (const item of batch) {
+      const rootID = item.root_id || selectedRoot;
+      byRoot.set(rootID, [...(byRoot.get(rootID) || []), item.id]);
+    }
+    let id;
+    for (const [rootID, entryIDs] of byRoot)
+      id = send("queue.add_many", { root_id: rootID, entry_ids: entryIDs });
+    const request = id && pending.get(id);
     if (request) request.truncated = playable.length - batch.length;
   });
   byID("refresh").addEventListener("click", () => send("devices.refresh"));
+  byID("transfer").addEventListener("click", () =>
+    send("player.transfer", { device_id: state.selected_device_id }),
+  );
   byID("queue-clear").addEventListener("click", () => send("queue.clear"));
+  byID("queue-import").addEventListener("click", () =>
+    byID("queue-import-file").click(),
+  );
+  byID("queue-import-file").addEventListener("change", async (event) => {
+    const file = event.target.files?.[0],
+      format = file?.name.split(".").pop().toLowerCase();
+    event.target.value = "";
+    if (!file) return;
+    if (!playlistFormats.includes(format)) {
+      showToast("Choose an M3U, M3U8, PLS or XSPF playlist", "error");
+      return;
+    }
+    const content = await file.text();
+    // Leave room for the envelope and JSON escaping.
+    if (JSON.stringify(content).length > messageLimit - 1024) {
+      showToast("Playlist file is too large", "error");
+      return;
+    }
+    send("queue.import", { format, content });
+  });
+  byID("queue-export").addEventListener("click", async () => {
+    const format = byID("queue-export-format").value;
+    try {
+      const response = await fetch(
+          `/api/v1/queue/export?format=${encodeURIComponent(format)}`,
+          { headers: { Accept: "application/json" } },
+        ),
+        data = await response.json();
+      if (!response.ok) throw new Error();
+      const link = document.createElement("a");
+      link.href = `data:text/plain;charset=utf-8,${encodeURIComponent(data.content)}`;
+      link.download = data.file_name;
+      link.click();
+    } catch {
+      showToast("Playlist export failed", "error");
+    }
+  });
   let backToTopVisible;
   const updateBackToTop = () => {
     const visible = window.scrollY >= 400;
@@ -1367,10 +1821,17 @@ export function startClient(env) {
     "loop",
     "autoplay",
     "same-type",
+    "repeat-all",
+    "shuffle",
     "gapless",
     "image-duration",
   ])
     byID(id).addEventListener("change", () => sendPolicy(id));
+  byID("sleep-mode").addEventListener("change", sendSleep);
+  byID("schedule-set").addEventListener("click", sendSchedule);
+  byID("schedule-cancel").addEventListener("click", () =>
+    send("player.schedule", {}),
+  );
   byID("theme-toggle").addEventListener("click", () => {
     themeChoice =
       themeOrder[(themeOrder.indexOf(themeChoice) + 1) % themeOrder.length];
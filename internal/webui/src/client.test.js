@@ -83,6 +83,10 @@ class Node {
   scrollBy(options) {
     this.scrolledBy = options;
   }
+  click() {
+    this.clicked = true;
+    this.emit("click");
+  }
 }
 class FakeSocket extends Node {
   static OPEN = 1;
@@ -143,16 +147,37 @@ function fixture() {
     "loop",
     "autoplay",
     "same-type",
+    "repeat-all",
+    "shuffle",
     "gapless",
     "image-duration",
+    "sleep-mode",
+    "sleep-running",
+    "schedule-time",
+    "schedule-set",
+    "schedule-cancel",
+    "schedule-status",
     "refresh",
+    "transfer",
     "queue-count",
+    "history-card",
+    "history-count",
+    "history",
     "queue-clear",
+    "queue-import",
+    "queue-import-file",
+    "queue-export",
+    "queue-export-format",
     "breadcrumbs",
     "folder-up",
     "add-visible",
     "add-visible-count",
     "library-filter",
+    "library-search",
+    "library-progress",
+    "mark-watched",
+    "mark-unwatched",
+    "library-recent",
     "play-toggle",
     "stop-button",
     "theme-toggle",
@@ -486,6 +511,7 @@ test("merges partial playback/policy, clears pending, reports errors and shutdow
   });
   assert.equal(ids.autoplay.checked, true);
   assert.equal(ids["same-type"].disabled, false);
+  assert.equal(ids["repeat-all"].disabled, true);
   assert.equal(ids.gapless.checked, true);
   assert.equal(ids.gapless.disabled, true);
   ws.message({
@@ -668,6 +694,7 @@ test("control interactions emit typed payloads and subtitle selection", async ()
   ids.transcode.emit("change");
   ids.autoplay.checked = true;
   ids["same-type"].checked = true;
+  ids.shuffle.checked = true;
   ids.gapless.checked = true;
   ids["image-duration"].value = "12";
   ids["same-type"].emit("change");
@@ -691,6 +718,8 @@ test("control interactions emit typed payloads and subtitle selection", async ()
             AutoPlayNext: true,
             AutoPlaySameType: true,
             GaplessEnabled: true,
+            RepeatAll: false,
+            Shuffle: true,
             ImageDurationSeconds: 12,
           },
           expected_revision: 3,
@@ -700,6 +729,153 @@ test("control interactions emit typed payloads and subtitle selection", async ()
   );
 });
 
+test("sleep timer and scheduled start controls", async () => {
+  const { ids, env } = fixture();
+  const client = startClient(env);
+  await settle();
+  const ws = FakeSocket.instances[0];
+  assert.equal(ids["sleep-mode"].value, "off");
+  assert.equal(ids["schedule-cancel"].hidden, true);
+  ids["sleep-mode"].value = "30";
+  ids["sleep-mode"].emit("change");
+  ids["sleep-mode"].value = "end_of_queue";
+  ids["sleep-mode"].emit("change");
+  ids["schedule-time"].value = "07:00";
+  ids["schedule-set"].emit("click");
+  const [timed, queued, scheduled] = ws.sent;
+  assert.deepEqual(timed.payload, {
+    mode: "time",
+    minutes: 30,
+    expected_revision: 3,
+  });
+  assert.deepEqual(queued.payload, {
+    mode: "end_of_queue",
+    expected_revision: 3,
+  });
+  const at = new Date(scheduled.payload.at);
+  assert.equal(scheduled.type, "player.schedule");
+  assert.equal(scheduled.payload.device_id, "dev-1");
+  assert.equal(at.getHours(), 7);
+  assert.ok(at > new Date() && at - new Date() <= 24 * 3600 * 1000);
+
+  const deadline = new Date(2026, 0, 1, 23, 5);
+  client.handle({
+    protocol_version: 1,
+    type: "state.timers",
+    payload: {
+      revision: 4,
+      sleep_timer: { mode: "time", deadline: deadline.toISOString() },
+      scheduled_start: {
+        at: new Date(2026, 0, 2, 7, 0).toISOString(),
+        device_id: "dev-1",
+        device_label: "Kitchen",
+      },
+    },
+  });
+  assert.equal(client.state.revision, 4);
+  assert.equal(ids["sleep-mode"].value, "running");
+  assert.equal(ids["sleep-running"].hidden, false);
+  assert.equal(ids["sleep-running"].textContent, "Until 23:05");
+  assert.equal(ids["schedule-status"].textContent, "Starts at 07:00 on Kitchen");
+  ids["schedule-cancel"].emit("click");
+  assert.deepEqual(ws.sent.at(-1).payload, { expected_revision: 4 });
+  client.handle({
+    protocol_version: 1,
+    type: "state.timers",
+    payload: { revision: 5, sleep_timer: { mode: "off" }, scheduled_start: {} },
+  });
+  assert.equal(ids["sleep-mode"].value, "off");
+  assert.equal(ids["schedule-cancel"].hidden, true);
+});
+
+test("transfer moves active playback to the selected renderer", async () => {
+  const { ids, env } = fixture();
+  const client = startClient(env);
+  await settle();
+  const ws = FakeSocket.instances[0];
+  assert.equal(ids.transfer.hidden, true);
+  client.handle({
+    protocol_version: 1,
+    type: "state.snapshot",
+    payload: {
+      ...client.state,
+      revision: 4,
+      has_session: true,
+      playback_state: "PLAYING",
+      active_device_id: "dev-2",
+    },
+  });
+  assert.equal(ids.transfer.hidden, false);
+  assert.equal(ids.transfer.ariaLabel, "Move playback to <Kitchen>");
+  ids.transfer.emit("click");
+  assert.equal(ws.sent.at(-1).type, "player.transfer");
+  assert.deepEqual(ws.sent.at(-1).payload, {
+    device_id: "dev-1",
+    expected_revision: 4,
+  });
+  assert.equal(ids.transfer.disabled, true);
+  client.handle({
+    protocol_version: 1,
+    type: "state.snapshot",
+    payload: { ...client.state, revision: 5, active_device_id: "dev-1" },
+  });
+  assert.equal(ids.transfer.hidden, true);
+});
+
+test("continue watching offers resume and start over", async () => {
+  const { ids, env } = fixture();
+  const client = startClient(env);
+  await settle();
+  const ws = FakeSocket.instances[0];
+  assert.equal(ids["history-card"].hidden, true);
+  client.handle({
+    protocol_version: 1,
+    type: "state.history",
+    payload: {
+      revision: 6,
+      continue_watching: [
+        {
+          root_id: "root-1",
+          entry_id: "movie",
+          name: "movie.mkv",
+          kind: "video",
+          position: 125,
+          duration: 5400,
+        },
+      ],
+    },
+  });
+  assert.equal(ids["history-card"].hidden, false);
+  assert.equal(ids["history-count"].textContent, "1");
+  const [copy, actions] = ids.history.children[0].children;
+  assert.equal(copy.children[1].textContent, "2:05 of 1:30:00");
+  const [resume, startOver] = actions.children;
+  assert.equal(resume.title, "Resume from 2:05");
+  resume.emit("click");
+  assert.deepEqual(ws.sent.at(-1).payload, {
+    root_id: "root-1",
+    entry_id: "movie",
+    resume: true,
+    expected_revision: 6,
+  });
+  assert.equal(ids.history.children[0].children[1].children[1].disabled, true);
+  client.handle({
+    protocol_version: 1,
+    type: "ack",
+    id: ws.sent.at(-1).id,
+    payload: { revision: 7 },
+  });
+  ids.history.children[0].children[1].children[1].emit("click");
+  assert.equal(ws.sent.at(-1).type, "library.play");
+  assert.equal(ws.sent.at(-1).payload.resume, false);
+  client.handle({
+    protocol_version: 1,
+    type: "state.history",
+    payload: { revision: 8, continue_watching: [] },
+  });
+  assert.equal(ids["history-card"].hidden, true);
+});
+
 test("position-only playback update redraws progress only", async () => {
   const { ids, env } = fixture();
   const client = startClient(env);
@@ -1309,6 +1485,187 @@ test("load more survives filtering and appends the next page", async () => {
   );
 });
 
+test("library rows show progress and folders can be marked watched", async () => {
+  const { ids, env } = fixture();
+  const bootstrapFetch = env.fetch,
+    browsed = [];
+  env.fetch = async (url) => {
+    if (!url.startsWith("/api/library")) return bootstrapFetch(url);
+    const query = new URL(`http://host${url}`).searchParams;
+    browsed.push(query.get("filter"));
+    return {
+      ok: true,
+      json: async () => ({
+        entries: [
+          {
+            id: "e01",
+            name: "e01.mkv",
+            kind: "file",
+            media_kind: "video",
+            watched: true,
+            play_count: 2,
+          },
+          {
+            id: "e02",
+            name: "e02.mkv",
+            kind: "file",
+            media_kind: "video",
+            position: 125,
+            duration: 1500,
+          },
+          { id: "e03", name: "e03.mkv", kind: "file", media_kind: "video" },
+        ],
+      }),
+    };
+  };
+  const client = startClient(env);
+  await settle();
+  const ws = FakeSocket.instances[0];
+  ws.emit("open");
+  const status = (index) =>
+    ids.library.children[index].children[0].children[1].children[2];
+  assert.equal(status(0).children[0].textContent, "Watched 2×");
+  assert.equal(status(1).children[0].tag, "progress");
+  assert.equal(status(1).children[0].value, 125);
+  assert.equal(status(1).children[0].title, "2:05 of 25:00");
+  assert.equal(status(2), undefined);
+
+  ids["library-progress"].value = "unwatched";
+  ids["library-progress"].emit("change");
+  await settle();
+  assert.deepEqual(browsed, [null, "unwatched"]);
+
+  assert.equal(ids["mark-watched"].disabled, false);
+  ids["mark-watched"].emit("click");
+  const mark = ws.sent.at(-1);
+  assert.equal(mark.type, "library.mark");
+  assert.deepEqual(mark.payload, {
+    root_id: "root-1",
+    entry_id: "",
+    watched: true,
+    expected_revision: 3,
+  });
+  assert.equal(ids["mark-unwatched"].disabled, true);
+  client.handle({
+    protocol_version: 1,
+    type: "ack",
+    id: mark.id,
+    payload: { revision: 4 },
+  });
+  await settle();
+  assert.equal(ids["mark-unwatched"].disabled, false);
+  assert.deepEqual(browsed, [null, "unwatched", "unwatched"]);
+});
+
+test("search lists matches from every root and returns to browsing", async () => {
+  const { ids, env, timers } = fixture();
+  const bootstrapFetch = env.fetch,
+    searched = [];
+  env.fetch = async (url) => {
+    if (!url.startsWith("/api/library/search")) return bootstrapFetch(url);
+    const query = new URL(`http://host${url}`).searchParams;
+    searched.push(`${query.get("q")}:${query.get("cursor") || ""}`);
+    return {
+      ok: true,
+      json: async () =>
+        query.get("cursor")
+          ? {
+              entries: [
+                {
+                  root_id: "root-2",
+                  parent: "Show/Season 1",
+                  id: "e01",
+                  name: "Show S01E01.mkv",
+                  kind: "file",
+                  media_kind: "video",
+                },
+              ],
+            }
+          : { entries: [], cursor: "more" },
+    };
+  };
+  startClient(env);
+  await settle();
+  const ws = FakeSocket.instances[0];
+  ws.emit("open");
+  ids["library-search"].value = " s01e01 ";
+  ids["library-search"].emit("input");
+  assert.deepEqual(searched, []);
+  timers.at(-1)();
+  await settle();
+  assert.deepEqual(searched, ["s01e01:", "s01e01:more"]);
+  const [main, actions] = ids.library.children[0].children;
+  assert.equal(
+    main.children[1].children[1].textContent,
+    "Video · MKV · Show/Season 1",
+  );
+  assert.equal(
+    ids.breadcrumbs.children[1].textContent,
+    "Results for “s01e01”",
+  );
+  assert.equal(ids["mark-watched"].disabled, true);
+  assert.equal(ids["library-progress"].disabled, true);
+  actions.children[0].emit("click");
+  assert.deepEqual(ws.sent.at(-1).payload, {
+    root_id: "root-2",
+    entry_id: "e01",
+    expected_revision: 3,
+  });
+
+  ids["library-search"].value = "";
+  ids["library-search"].emit("input");
+  timers.at(-1)();
+  await settle();
+  assert.equal(ids.breadcrumbs.children.length, 1);
+  assert.equal(ids["mark-watched"].disabled, false);
+  assert.equal(
+    ids.library.children[0].children[0].children[1].children[0].textContent,
+    "<movie>.mp4",
+  );
+});
+
+test("recently added keeps the server order and toggles back", async () => {
+  const { ids, env } = fixture();
+  const bootstrapFetch = env.fetch;
+  env.fetch = async (url) => {
+    if (url === "/api/bootstrap") {
+      const response = await bootstrapFetch(url),
+        body = await response.json();
+      return {
+        ok: true,
+        json: async () => ({ ...body, features: { library_index: true } }),
+      };
+    }
+    if (url !== "/api/library/recent?limit=200") return bootstrapFetch(url);
+    return {
+      ok: true,
+      json: async () => ({
+        entries: [
+          { root_id: "root-1", id: "z", name: "zeta.mp4", kind: "file" },
+          { root_id: "root-1", id: "a", name: "alpha.mp4", kind: "file" },
+        ],
+      }),
+    };
+  };
+  startClient(env);
+  await settle();
+  assert.equal(ids["library-recent"].hidden, false);
+  ids["library-recent"].emit("click");
+  await settle();
+  const names = ids.library.children.map(
+    (row) => row.children[0].children[1].children[0].textContent,
+  );
+  assert.deepEqual(names, ["zeta.mp4", "alpha.mp4"]);
+  assert.equal(ids.breadcrumbs.children[1].textContent, "Recently added");
+  assert.equal(ids["library-recent"].ariaPressed, "true");
+  assert.equal(ids["library-progress"].disabled, true);
+
+  ids["library-recent"].emit("click");
+  await settle();
+  assert.equal(ids.breadcrumbs.children.length, 1);
+  assert.equal(ids["library-recent"].ariaPressed, "false");
+});
+
 test("orders library entries by name across pages", async () => {
   const { ids, env } = fixture();
   const bootstrapFetch = env.fetch;
@@ -1716,6 +2073,100 @@ test("clear queue button sends queue.clear and tracks queue state", async () =>
   assert.equal(ids.seek.value, "0");
 });
 
+test("imports playlist files and exports the queue in the chosen format", async () => {
+  const { ids, env, document } = fixture();
+  const bootstrapFetch = env.fetch,
+    fetched = [];
+  env.fetch = async (url) => {
+    fetched.push(url);
+    return url.startsWith("/api/v1/queue/export")
+      ? {
+          ok: true,
+          json: async () => ({
+            revision: 5,
+            format: "pls",
+            file_name: "go2tv-queue.pls",
+            content: "[playlist]\nFile1=a b.mp3\n",
+          }),
+        }
+      : bootstrapFetch(url);
+  };
+  const links = [],
+    createElement = document.createElement;
+  document.createElement = (tag) => {
+    const node = createElement(tag);
+    if (tag === "a") links.push(node);
+    return node;
+  };
+  startClient(env);
+  await settle();
+  const ws = FakeSocket.instances[0];
+  ws.emit("open");
+  assert.equal(ids["queue-import"].disabled, false);
+  assert.equal(ids["queue-export"].disabled, true);
+  ids["queue-import"].emit("click");
+  assert.equal(ids["queue-import-file"].clicked, true);
+
+  const choose = async (name, content) => {
+    ids["queue-import-file"].files = [{ name, text: async () => content }];
+    ids["queue-import-file"].emit("change");
+    await settle();
+  };
+  await choose("notes.txt", "a.mp3");
+  assert.equal(ws.sent.length, 0);
+  assert.equal(
+    ids.toast.children.at(-1).textContent,
+    "Choose an M3U, M3U8, PLS or XSPF playlist",
+  );
+  await choose("Mix.M3U", "#EXTM3U\nsong.mp3\n");
+  assert.equal(ws.sent.at(-1).type, "queue.import");
+  assert.deepEqual(ws.sent.at(-1).payload, {
+    format: "m3u",
+    content: "#EXTM3U\nsong.mp3\n",
+    expected_revision: 3,
+  });
+  ws.message({
+    protocol_version: 1,
+    type: "ack",
+    id: ws.sent.at(-1).id,
+    payload: { revision: 4, added: 1, duplicates: 0, dropped: 0, failed: 2 },
+  });
+  assert.equal(
+    ids.toast.children.at(-1).textContent,
+    "Added 1 file to playlist; 2 unavailable",
+  );
+  await choose("huge.m3u8", "x".repeat(256 << 10));
+  assert.equal(ws.sent.length, 1);
+
+  ws.message({
+    protocol_version: 1,
+    type: "state.snapshot",
+    payload: {
+      revision: 5,
+      queue: [
+        {
+          id: "q1",
+          name: "a b.mp3",
+          kind: "audio",
+          selected: true,
+          active: false,
+        },
+      ],
+    },
+  });
+  assert.equal(ids["queue-export"].disabled, false);
+  ids["queue-export-format"].value = "pls";
+  ids["queue-export"].emit("click");
+  await settle();
+  assert.equal(fetched.at(-1), "/api/v1/queue/export?format=pls");
+  assert.equal(links.at(-1).download, "go2tv-queue.pls");
+  assert.equal(
+    links.at(-1).href,
+    "data:text/plain;charset=utf-8,%5Bplaylist%5D%0AFile1%3Da%20b.mp3%0A",
+  );
+  assert.equal(links.at(-1).clicked, true);
+});
+
 test("clear queue retains active item controls and artwork", async () => {
   const { ids, env } = fixture();
   startClient(env);
@@ -1842,6 +2293,21 @@ test("back to top appears after scrolling and returns smoothly", async () => {
   assert.equal(ids["back-to-top"].tabIndex, -1);
 });
 
+test("repeat all is only sent with shuffle", async () => {
+  const { ids, env } = fixture();
+  startClient(env);
+  await settle();
+  const ws = FakeSocket.instances[0];
+  ids.autoplay.checked = true;
+  ids["repeat-all"].checked = true;
+  ids["repeat-all"].emit("change");
+  assert.equal(ws.sent.at(-1).payload.policy.RepeatAll, false);
+  ids.shuffle.checked = true;
+  ids.shuffle.emit("change");
+  assert.equal(ws.sent.at(-1).payload.policy.RepeatAll, true);
+  assert.equal(ws.sent.at(-1).payload.policy.Shuffle, true);
+});
+
 test("loop and autoplay remain mutually exclusive", async () => {
   const { ids, env } = fixture();
   startClient(env);
@@ -1849,17 +2315,21 @@ test("loop and autoplay remain mutually exclusive", async () => {
   const ws = FakeSocket.instances[0];
   ids.autoplay.checked = true;
   ids["same-type"].checked = true;
+  ids["repeat-all"].checked = true;
   ids.gapless.checked = true;
   ids.loop.checked = true;
   ids.loop.emit("change");
   assert.equal(ids.autoplay.checked, false);
   assert.equal(ids["same-type"].checked, false);
+  assert.equal(ids["repeat-all"].checked, false);
   assert.equal(ids.gapless.checked, false);
   assert.deepEqual(ws.sent.at(-1).payload.policy, {
     LoopSelected: true,
     AutoPlayNext: false,
     AutoPlaySameType: false,
     GaplessEnabled: false,
+    RepeatAll: false,
+    Shuffle: false,
     ImageDurationSeconds: 10,
   });
   ids.autoplay.checked = true;
@@ -1888,3 +2358,64 @@ test("normalizes image duration to desktop limits", async () => {
     assert.equal(ids["image-duration"].value, String(want));
   }
 });
+
+test("details pane probes a file once and toggles", async () => {
+  const { ids, env } = fixture();
+  const bootstrapFetch = env.fetch,
+    probes = [];
+  env.fetch = async (url) => {
+    if (url === "/api/bootstrap") {
+      const response = await bootstrapFetch(url),
+        body = await response.json();
+      return {
+        ok: true,
+        json: async () => ({ ...body, features: { library_details: true } }),
+      };
+    }
+    if (!url.startsWith("/api/library/details?")) return bootstrapFetch(url);
+    probes.push(url);
+    return {
+      ok: true,
+      json: async () => ({
+        duration: 5400,
+        width: 1920,
+        height: 1080,
+        video_codec: "h264",
+        audio_languages: ["eng", "fre"],
+      }),
+    };
+  };
+  startClient(env);
+  await settle();
+  const row = () => ids.library.children[0],
+    details = () => row().children[1].children.at(-1);
+  assert.equal(details().ariaLabel, "Details for <movie>.mp4");
+  details().emit("click");
+  await settle();
+  assert.deepEqual(probes, [
+    "/api/library/details?root_id=root-1&entry_id=media-1",
+  ]);
+  const pane = row().children[2];
+  assert.equal(pane.className, "entry-details");
+  assert.deepEqual(
+    pane.children.map((node) => node.textContent),
+    [
+      "Duration",
+      "1:30:00",
+      "Resolution",
+      "1920×1080",
+      "Video",
+      "H264",
+      "Languages",
+      "eng, fre",
+    ],
+  );
+
+  details().emit("click");
+  await settle();
+  assert.equal(row().children.length, 2);
+  details().emit("click");
+  await settle();
+  assert.equal(row().children.length, 3);
+  assert.equal(probes.length, 1);
+});
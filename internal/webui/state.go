@@ -0,0 +1,223 @@
+package webui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/library"
+	"go2tv.app/go2tv/v2/internal/playback"
+	"go2tv.app/go2tv/v2/internal/playlist"
+	"go2tv.app/go2tv/v2/utils"
+)
+
+const (
+	stateVersion   = 1
+	stateSaveEvery = 2 * time.Second
+	stateTimeout   = 10 * time.Second
+)
+
+// savedState is the state file form of controller.SavedState. Library IDs
+// are signed per process, so media are kept as library locations.
+type savedState struct {
+	Version  int               `json:"version"`
+	Queue    []savedMedia      `json:"queue"`
+	Selected int               `json:"selected"`
+	Subtitle *library.Location `json:"subtitle,omitempty"`
+	Policy   controller.Policy `json:"policy"`
+	Device   *savedDevice      `json:"device,omitempty"`
+}
+
+// savedMedia is a library location, or a URL for remote items, plus the
+// title an imported playlist gave the item.
+type savedMedia struct {
+	library.Location
+	URL   string `json:"url,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+type savedDevice struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+	Endpoint string `json:"endpoint"`
+}
+
+type stateStore struct {
+	path     string
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	revision uint64
+	last     []byte
+	// blocked stops saving over a state file that could not be restored and
+	// could not be moved aside either.
+	blocked bool
+}
+
+func newStateStore(path string) *stateStore {
+	return &stateStore{path: path, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+// restoreState loads the state file into the controller. Entries whose root
+// or file is gone are skipped rather than failing the whole restore.
+func (h *Handler) restoreState() {
+	data, err := os.ReadFile(h.state.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	var saved savedState
+	if err == nil {
+		err = json.Unmarshal(data, &saved)
+	}
+	if err == nil && saved.Version != stateVersion {
+		err = fmt.Errorf("unsupported version %d", saved.Version)
+	}
+	if err != nil {
+		h.setAsideState("Saved session state ignored: " + err.Error())
+		return
+	}
+	state := controller.SavedState{Selected: -1, Policy: saved.Policy}
+	// Older releases accepted RepeatAll without Shuffle and ignored it.
+	state.Policy.RepeatAll = state.Policy.RepeatAll && state.Policy.Shuffle
+	if state.Policy.Validate() != nil {
+		state.Policy = controller.DefaultPolicy()
+	}
+	skipped := 0
+	for index, media := range saved.Queue {
+		if len(state.Queue) == controller.MaxQueueItems {
+			skipped += len(saved.Queue) - index
+			break
+		}
+		ref, err := h.savedMediaRef(media)
+		if err != nil {
+			skipped++
+			continue
+		}
+		if index == saved.Selected {
+			state.Selected = len(state.Queue)
+		}
+		state.Queue = append(state.Queue, ref)
+	}
+	if saved.Subtitle != nil {
+		if rootID, entryID, err := h.cfg.Library.Resolve(*saved.Subtitle); err == nil {
+			state.Subtitle, _ = h.subtitleRef(rootID, entryID)
+		}
+	}
+	if saved.Device != nil {
+		state.Device = playback.Device{Name: saved.Device.Name, Protocol: saved.Device.Protocol, Endpoint: saved.Device.Endpoint}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	if result := h.cfg.Controller.RestoreState(ctx, controller.Mutation{}, state); !result.OK() {
+		h.setAsideState("Saved session state not restored: " + result.Message)
+		return
+	}
+	h.state.last = bytes.TrimSpace(data)
+	if h.cfg.Logger != nil && len(saved.Queue) != 0 {
+		h.cfg.Logger.Info(fmt.Sprintf("Restored %d queued items", len(state.Queue)))
+	}
+	if skipped != 0 {
+		h.logWarning(fmt.Sprintf("Skipped %d saved queue items that no longer resolve", skipped))
+	}
+}
+
+// setAsideState renames a state file that failed to restore to path.bad, so
+// the first save cannot overwrite the queue it holds. When the rename fails
+// the file is left alone and nothing is saved this run.
+func (h *Handler) setAsideState(reason string) {
+	bad := h.state.path + ".bad"
+	if err := os.Rename(h.state.path, bad); err != nil {
+		h.state.blocked = true
+		h.logWarning(reason + "; state will not be saved: " + err.Error())
+		return
+	}
+	h.logWarning(reason + "; moved to " + bad)
+}
+
+// persistState saves the state file whenever the controller revision moves
+// and the saved fields actually changed.
+func (h *Handler) persistState() {
+	defer close(h.state.done)
+	ticker := time.NewTicker(stateSaveEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.state.stop:
+			h.saveState()
+			return
+		case <-ticker.C:
+			h.saveState()
+		}
+	}
+}
+
+func (h *Handler) saveState() {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	if h.state.blocked {
+		return
+	}
+	state, revision, err := h.cfg.Controller.SavedState(ctx)
+	if err != nil || revision == h.state.revision && h.state.last != nil {
+		return
+	}
+	h.state.revision = revision
+	saved := savedState{Version: stateVersion, Queue: []savedMedia{}, Selected: -1, Policy: state.Policy}
+	for index, media := range state.Queue {
+		entry := savedMedia{URL: media.URL, Title: media.Title}
+		if media.URL == "" {
+			location, err := h.cfg.Library.Locate(media.RootID, media.ID)
+			if err != nil {
+				// The root was removed by a config reload.
+				continue
+			}
+			entry.Location = location
+		}
+		if index == state.Selected {
+			saved.Selected = len(saved.Queue)
+		}
+		saved.Queue = append(saved.Queue, entry)
+	}
+	if state.Subtitle.ID != "" {
+		if location, err := h.cfg.Library.Locate(state.Subtitle.RootID, state.Subtitle.ID); err == nil {
+			saved.Subtitle = &location
+		}
+	}
+	if state.Device.Endpoint != "" {
+		saved.Device = &savedDevice{Name: state.Device.Name, Protocol: state.Device.Protocol, Endpoint: state.Device.Endpoint}
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil || bytes.Equal(data, h.state.last) {
+		return
+	}
+	if err := utils.WriteFileAtomic(h.state.path, append(data, '\n')); err != nil {
+		h.logWarning("Session state not saved: " + err.Error())
+		return
+	}
+	h.state.last = data
+}
+
+func (h *Handler) savedMediaRef(media savedMedia) (controller.MediaRef, error) {
+	if media.URL != "" {
+		return remoteMediaRef(playlist.Entry{Location: media.URL, Title: media.Title})
+	}
+	rootID, entryID, err := h.cfg.Library.Resolve(media.Location)
+	if err != nil {
+		return controller.MediaRef{}, err
+	}
+	ref, err := h.queuedMediaRef(rootID, entryID)
+	ref.Title = media.Title
+	return ref, err
+}
+
+func (h *Handler) logWarning(message string) {
+	if h.cfg.Logger != nil {
+		h.cfg.Logger.Warning(message)
+	}
+}
@@ -0,0 +1,110 @@
+package webui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go2tv.app/go2tv/v2/internal/controller"
+	"go2tv.app/go2tv/v2/internal/library"
+)
+
+func TestStateFileRestoresQueueAcrossRestart(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.mp3", "b.mp4", "c.mkv"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("media"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statePath := filepath.Join(t.TempDir(), "state", "server-state.json")
+	start := func() (*Handler, *library.Library, *controller.Controller) {
+		lib, err := library.Open(library.Config{Roots: []string{root}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		control := controller.New(controller.Config{})
+		handler, err := New(Config{Version: "test", Controller: control, Library: lib, StateFile: statePath})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return handler, lib, control
+	}
+
+	handler, lib, control := start()
+	rootID := lib.Roots()[0].ID
+	page, err := lib.Browse(rootID, "", "", 10)
+	if err != nil || len(page.Entries) != 3 {
+		t.Fatalf("browse = %#v, %v", page, err)
+	}
+	names := make([]string, 0, len(page.Entries))
+	for _, entry := range page.Entries {
+		names = append(names, entry.Name)
+		if res, body := restCall(t, handler, http.MethodPost, "/api/v1/queue", fmt.Sprintf(`{"root_id":%q,"entry_id":%q}`, rootID, entry.ID), nil); res.Code != http.StatusOK {
+			t.Fatalf("queue add = %d %v", res.Code, body)
+		}
+	}
+	if res, body := restCall(t, handler, http.MethodPost, "/api/v1/queue/import", `{"format":"m3u","content":"#EXTINF:-1,Radio\nhttps://radio.example/live\n"}`, nil); res.Code != http.StatusOK {
+		t.Fatalf("queue import = %d %v", res.Code, body)
+	}
+	snapshot, _ := control.Snapshot(context.Background())
+	if res, body := restCall(t, handler, http.MethodPost, "/api/v1/queue/select", fmt.Sprintf(`{"item_id":%q}`, snapshot.Queue[2].ID), nil); res.Code != http.StatusOK {
+		t.Fatalf("queue select = %d %v", res.Code, body)
+	}
+	if res, body := restCall(t, handler, http.MethodPut, "/api/v1/policy", `{"policy":{"LoopSelected":false,"AutoPlayNext":true,"AutoPlaySameType":true,"GaplessEnabled":false,"ImageDurationSeconds":30}}`, nil); res.Code != http.StatusOK {
+		t.Fatalf("policy = %d %v", res.Code, body)
+	}
+	handler.Close()
+	control.Close()
+	_ = lib.Close()
+
+	var saved savedState
+	data, err := os.ReadFile(statePath)
+	if err != nil || json.Unmarshal(data, &saved) != nil || len(saved.Queue) != 4 || saved.Queue[0].Path != names[0] || saved.Queue[3].URL != "https://radio.example/live" || saved.Selected != 2 {
+		t.Fatalf("state file = %s, %v", data, err)
+	}
+
+	if err := os.Remove(filepath.Join(root, names[1])); err != nil {
+		t.Fatal(err)
+	}
+	handler, lib, control = start()
+	t.Cleanup(func() { handler.Close(); control.Close(); _ = lib.Close() })
+	snapshot, _ = control.Snapshot(context.Background())
+	if len(snapshot.Queue) != 3 || snapshot.Queue[0].Name != names[0] || !snapshot.Queue[1].IsSelected || snapshot.SelectedMedia != names[2] || snapshot.Queue[2].Name != "Radio" {
+		t.Fatalf("restored queue = %#v", snapshot.Queue)
+	}
+	if !snapshot.Policy.AutoPlaySameType || snapshot.Policy.ImageDurationSeconds != 30 {
+		t.Fatalf("restored policy = %#v", snapshot.Policy)
+	}
+}
+
+func TestUnreadableStateFileSurvivesSaves(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "server-state.json")
+	corrupt := []byte(`{"version":1,"queue":[`)
+	if err := os.WriteFile(statePath, corrupt, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lib, err := library.Open(library.Config{Roots: []string{t.TempDir()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lib.Close()
+	control := controller.New(controller.Config{})
+	defer control.Close()
+	handler, err := New(Config{Version: "test", Controller: control, Library: lib, StateFile: statePath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.Close()
+	if data, err := os.ReadFile(statePath + ".bad"); err != nil || !bytes.Equal(data, corrupt) {
+		t.Fatalf("set-aside state = %q, %v", data, err)
+	}
+	var saved savedState
+	if data, err := os.ReadFile(statePath); err != nil || json.Unmarshal(data, &saved) != nil || saved.Version != stateVersion {
+		t.Fatalf("new state file = %q, %v", data, err)
+	}
+}
@@ -2,6 +2,7 @@ package webui
 
 import (
 	"encoding/json"
+	"time"
 
 	"go2tv.app/go2tv/v2/internal/controller"
 	"go2tv.app/go2tv/v2/internal/library"
@@ -9,6 +10,12 @@ import (
 
 const ProtocolVersion = 1
 
+// sleepModeOff is the wire name for controller.SleepOff.
+const sleepModeOff = "off"
+
+// sleepModes lists the accepted player.sleep modes.
+var sleepModes = []string{sleepModeOff, string(controller.SleepAfterTime), string(controller.SleepAfterItem), string(controller.SleepEndOfQueue)}
+
 type Config struct {
 	Version            string
 	Controller         *controller.Controller
@@ -20,13 +27,19 @@ type Config struct {
 	// ManagedByGUI marks a GUI-managed remote session so the browser can
 	// disclose that device availability comes from the desktop app.
 	ManagedByGUI bool
+	// StateFile keeps the queue, selection, and playback policy across
+	// restarts. Empty disables persistence.
+	StateFile string
 }
 
 type envelope struct {
-	ProtocolVersion int             `json:"protocol_version"`
-	Type            string          `json:"type"`
-	ID              string          `json:"id,omitempty"`
-	Payload         json.RawMessage `json:"payload,omitempty"`
+	ProtocolVersion int    `json:"protocol_version"`
+	Type            string `json:"type"`
+	ID              string `json:"id,omitempty"`
+	// Session names the target session by device ID; blank is the default
+	// session.
+	Session string          `json:"session,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
 }
 
 type rootDTO struct {
@@ -40,6 +53,28 @@ type entryDTO struct {
 	MediaKind    string `json:"media_kind,omitempty"`
 	ThumbnailURL string `json:"thumbnail_url,omitempty"`
 	ArtworkURL   string `json:"artwork_url,omitempty"`
+	Watched      bool   `json:"watched,omitempty"`
+	Position     int    `json:"position,omitempty"`
+	Duration     int    `json:"duration,omitempty"`
+	PlayCount    int    `json:"play_count,omitempty"`
+	// Media is present once the file has been probed through
+	// /api/library/details.
+	Media mediaDTO `json:"media,omitzero"`
+}
+
+// mediaDTO is what probing a library file found; fields the probe could not
+// read are omitted.
+type mediaDTO struct {
+	Duration       float64  `json:"duration,omitempty"`
+	Width          int      `json:"width,omitempty"`
+	Height         int      `json:"height,omitempty"`
+	VideoCodec     string   `json:"video_codec,omitempty"`
+	AudioCodec     string   `json:"audio_codec,omitempty"`
+	AudioLanguages []string `json:"audio_languages,omitempty"`
+	Subtitles      []string `json:"subtitles,omitempty"`
+	Title          string   `json:"title,omitempty"`
+	Artist         string   `json:"artist,omitempty"`
+	Album          string   `json:"album,omitempty"`
 }
 
 type bootstrapDTO struct {
@@ -89,4 +124,133 @@ type snapshotDTO struct {
 	MediaType            string            `json:"media_type,omitempty"`
 	ArtworkID            string            `json:"artwork_id"`
 	Policy               controller.Policy `json:"policy"`
+	SleepTimer           sleepTimerDTO     `json:"sleep_timer"`
+	ScheduledStart       scheduledStartDTO `json:"scheduled_start"`
+	Sessions             []sessionDTO      `json:"sessions"`
+	Groups               []groupDTO        `json:"groups"`
+	ContinueWatching     []historyDTO      `json:"continue_watching"`
+}
+
+// sessionDTO summarizes one playback session. The default session has a blank
+// id; additional sessions use the ID of the device they drive.
+type sessionDTO struct {
+	ID              string `json:"id"`
+	DeviceID        string `json:"device_id,omitempty"`
+	DeviceLabel     string `json:"device_label,omitempty"`
+	Revision        uint64 `json:"revision"`
+	HasSession      bool   `json:"has_session"`
+	PlaybackState   string `json:"playback_state"`
+	ActiveMediaName string `json:"active_media_name,omitempty"`
+	Position        int    `json:"position"`
+	Duration        int    `json:"duration"`
+	Volume          int    `json:"volume"`
+	Muted           bool   `json:"muted"`
+}
+
+// groupDTO describes a renderer group. The group also appears in devices under
+// the same id; device_ids lists its members, leader first.
+type groupDTO struct {
+	ID          string   `json:"id"`
+	Label       string   `json:"label"`
+	DeviceIDs   []string `json:"device_ids"`
+	ToleranceMS int64    `json:"tolerance_ms"`
+}
+
+// historyDTO is a library file that stopped part way, most recently played
+// first. Position and duration are in seconds; duration is 0 when unknown.
+type historyDTO struct {
+	RootID    string    `json:"root_id"`
+	EntryID   string    `json:"entry_id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Position  int       `json:"position"`
+	Duration  int       `json:"duration"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// sleepTimerDTO reports mode "off" when no timer is armed; deadline is only
+// sent for mode "time".
+type sleepTimerDTO struct {
+	Mode     string    `json:"mode"`
+	Deadline time.Time `json:"deadline,omitzero"`
+}
+type scheduledStartDTO struct {
+	At          time.Time `json:"at,omitzero"`
+	DeviceID    string    `json:"device_id,omitempty"`
+	DeviceLabel string    `json:"device_label,omitempty"`
+}
+
+type devicesDTO struct {
+	Revision         uint64      `json:"revision"`
+	Devices          []deviceDTO `json:"devices"`
+	SelectedDeviceID string      `json:"selected_device_id,omitempty"`
+	ActiveDeviceID   string      `json:"active_device_id,omitempty"`
+}
+type sessionsDTO struct {
+	Revision uint64       `json:"revision"`
+	Sessions []sessionDTO `json:"sessions"`
+}
+type groupsDTO struct {
+	Revision uint64     `json:"revision"`
+	Groups   []groupDTO `json:"groups"`
+}
+type historyListDTO struct {
+	Revision         uint64       `json:"revision"`
+	ContinueWatching []historyDTO `json:"continue_watching"`
+}
+type queueListDTO struct {
+	Revision uint64     `json:"revision"`
+	Queue    []queueDTO `json:"queue"`
+}
+type policyDTO struct {
+	Revision uint64            `json:"revision"`
+	Policy   controller.Policy `json:"policy"`
+}
+type timersDTO struct {
+	Revision       uint64            `json:"revision"`
+	SleepTimer     sleepTimerDTO     `json:"sleep_timer"`
+	ScheduledStart scheduledStartDTO `json:"scheduled_start"`
+}
+type playlistDTO struct {
+	Revision uint64 `json:"revision"`
+	Format   string `json:"format"`
+	FileName string `json:"file_name"`
+	Content  string `json:"content"`
+}
+type libraryPageDTO struct {
+	Entries []entryDTO `json:"entries"`
+	Cursor  string     `json:"cursor,omitempty"`
+}
+
+// searchResultDTO is a search match; unlike a browsed entry it carries the
+// root it was found in and the folder path below that root.
+type searchResultDTO struct {
+	RootID string `json:"root_id"`
+	Parent string `json:"parent"`
+	entryDTO
+}
+type searchPageDTO struct {
+	Entries []searchResultDTO `json:"entries"`
+	Cursor  string            `json:"cursor,omitempty"`
+}
+type commandResultDTO struct {
+	RequestID string `json:"request_id"`
+	Revision  uint64 `json:"revision"`
+}
+type queueBatchResultDTO struct {
+	commandResultDTO
+	Added      int `json:"added"`
+	Duplicates int `json:"duplicates"`
+	Dropped    int `json:"dropped"`
+	Failed     int `json:"failed"`
+}
+type groupResultDTO struct {
+	commandResultDTO
+	GroupID string `json:"group_id"`
+}
+type errorDTO struct {
+	Error     string `json:"error"`
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Revision  uint64 `json:"revision,omitempty"`
 }
@@ -1,7 +1,9 @@
-// Package metadata resolves and normalizes optional media artwork.
+// Package metadata resolves and normalizes optional media artwork and reads
+// descriptive tags.
 //
 // Embedded tags are read with github.com/alexballas/tunetag, a
-// pure-Go library under the MIT license. This package uses its MP3 ID3v2 APIC,
-// MP4/M4A covr, FLAC PICTURE, and Ogg Vorbis/Opus METADATA_BLOCK_PICTURE
-// readers. Other tunetag container support is outside Go2TV's artwork MVP.
+// pure-Go library under the MIT license. This package uses its MP3 ID3v2,
+// MP4/M4A, FLAC, and Ogg Vorbis/Opus readers for pictures and for the title,
+// artist, and album tags. Other tunetag container support is outside Go2TV's
+// metadata MVP.
 package metadata
@@ -0,0 +1,39 @@
+package metadata
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/alexballas/tunetag"
+)
+
+// Tags are the descriptive tags embedded in a media file.
+type Tags struct {
+	Title  string
+	Artist string
+	Album  string
+}
+
+// ReadTags reads the title, artist, and album tags of an open media file. It
+// reports false when the format carries no tags Go2TV reads or the file has
+// none. The file offset is left at the start.
+func ReadTags(file *os.File, source string) (Tags, bool) {
+	if file == nil || !supportsEmbeddedArtwork(source) {
+		return Tags{}, false
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Tags{}, false
+	}
+	defer func() { _, _ = file.Seek(0, io.SeekStart) }()
+	tag, err := tunetag.OpenReader(file)
+	if err != nil {
+		return Tags{}, false
+	}
+	tags := Tags{
+		Title:  strings.TrimSpace(tag.Title()),
+		Artist: strings.TrimSpace(tag.Artist()),
+		Album:  strings.TrimSpace(tag.Album()),
+	}
+	return tags, tags != Tags{}
+}
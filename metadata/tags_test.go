@@ -0,0 +1,35 @@
+package metadata
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadTagsWithoutTags(t *testing.T) {
+	if _, ok := ReadTags(nil, "song.mp3"); ok {
+		t.Fatal("tags from a nil file")
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"clip.mkv", "song.mp3"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("not really media"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Seek(4, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if tags, ok := ReadTags(file, name); ok {
+			t.Fatalf("%s tags = %+v", name, tags)
+		}
+		if offset, _ := file.Seek(0, io.SeekCurrent); name == "song.mp3" && offset != 0 {
+			t.Fatalf("%s offset = %d", name, offset)
+		}
+		_ = file.Close()
+	}
+}
@@ -62,7 +62,7 @@ func rendererHTTPClient(location *url.URL, pinned net.IP) *http.Client {
 	}
 	return &http.Client{
 		Timeout:   soapHTTPClientTimeout,
-		Transport: base,
+		Transport: instrumentedTransport{next: base},
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) == 0 || !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
 				return http.ErrUseLastResponse
@@ -4,9 +4,11 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
+	"go2tv.app/go2tv/v2/internal/metrics"
 )
 
 const (
@@ -31,10 +33,46 @@ var soapHTTPTransport = &http.Transport{
 	IdleConnTimeout:       soapHTTPIdleConnTimeout,
 }
 
+var (
+	soapDuration = metrics.NewHistogram("go2tv_soap_request_duration_seconds", "Round trip of renderer SOAP and event subscription requests, by action.", nil, "action")
+	soapErrors   = metrics.NewCounter("go2tv_soap_errors_total", "Renderer requests that failed or returned an HTTP error, by action.", "action")
+)
+
+// instrumentedTransport times every renderer request. Retries and the M-POST
+// fallback are separate round trips, so each attempt is observed.
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	action := soapActionName(req)
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	soapDuration.ObserveSince(start, action)
+	// A 405 to POST only sends the call again as M-POST.
+	if err != nil || res.StatusCode >= 400 && !(res.StatusCode == http.StatusMethodNotAllowed && req.Method == http.MethodPost) {
+		soapErrors.Inc(action)
+	}
+	return res, err
+}
+
+// soapActionName returns the action after '#' in the SOAPAction header, or
+// the HTTP method for GENA and description requests.
+func soapActionName(req *http.Request) string {
+	header := headerGetCaseInsensitive(req.Header, "SOAPAction")
+	if header == "" {
+		header = req.Header.Get("01-SOAPACTION")
+	}
+	if _, action, ok := strings.Cut(strings.Trim(header, `"`), "#"); ok && action != "" {
+		return action
+	}
+	return req.Method
+}
+
 func newHTTPClient() *http.Client {
 	return &http.Client{
 		Timeout:   soapHTTPClientTimeout,
-		Transport: soapHTTPTransport,
+		Transport: instrumentedTransport{next: soapHTTPTransport},
 	}
 }
 
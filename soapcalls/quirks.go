@@ -0,0 +1,214 @@
+package soapcalls
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"go2tv.app/go2tv/v2/utils"
+)
+
+// Quirks are the workarounds a renderer needs beyond what the UPnP and DLNA
+// specifications ask for.
+type Quirks struct {
+	// StopBeforeSetURI stops the renderer before loading new media, for
+	// renderers that refuse SetAVTransportURI while they play.
+	StopBeforeSetURI bool `json:"stop_before_set_uri,omitempty"`
+	// LegacyMetadata sends the DIDL-Lite metadata in the compatibility
+	// encoding straight away.
+	LegacyMetadata bool `json:"legacy_metadata,omitempty"`
+	// NoMetadata sends SetAVTransportURI without DIDL-Lite metadata.
+	NoMetadata bool `json:"no_metadata,omitempty"`
+	// MPost sends SOAP actions as M-POST rather than POST.
+	MPost bool `json:"mpost,omitempty"`
+	// CaptionInfoHeader names the subtitles in a CaptionInfo.sec header on
+	// the media response, where Samsung renderers look for them.
+	CaptionInfoHeader bool `json:"caption_info_header,omitempty"`
+	// ByteSeek seeks to a byte offset (X_DLNA_REL_BYTE) rather than a time,
+	// for renderers that only seek by byte. The offset is estimated from the
+	// media size and duration.
+	ByteSeek bool `json:"byte_seek,omitempty"`
+}
+
+// or returns the quirks set in either q or other.
+func (q Quirks) or(other Quirks) Quirks {
+	return Quirks{
+		StopBeforeSetURI:  q.StopBeforeSetURI || other.StopBeforeSetURI,
+		LegacyMetadata:    q.LegacyMetadata || other.LegacyMetadata,
+		NoMetadata:        q.NoMetadata || other.NoMetadata,
+		MPost:             q.MPost || other.MPost,
+		CaptionInfoHeader: q.CaptionInfoHeader || other.CaptionInfoHeader,
+		ByteSeek:          q.ByteSeek || other.ByteSeek,
+	}
+}
+
+// RendererInfo identifies a renderer model by its device description.
+type RendererInfo struct {
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Model        string `json:"model,omitempty"`
+	FriendlyName string `json:"friendly_name,omitempty"`
+}
+
+func (r RendererInfo) known() bool {
+	return r.Manufacturer != "" || r.Model != "" || r.FriendlyName != ""
+}
+
+// QuirkRule gives the renderers it matches its Quirks. Each field that is
+// set must appear, ignoring case, in the same field of the renderer's
+// description; a rule with no fields set matches every renderer.
+type QuirkRule struct {
+	RendererInfo
+	Quirks Quirks `json:"quirks"`
+}
+
+func (r QuirkRule) matches(info RendererInfo) bool {
+	contains := func(value, part string) bool {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(part))
+	}
+	return contains(info.Manufacturer, r.Manufacturer) && contains(info.Model, r.Model) && contains(info.FriendlyName, r.FriendlyName)
+}
+
+// builtinQuirks are the workarounds known to be needed up front.
+var builtinQuirks = []QuirkRule{
+	{RendererInfo: RendererInfo{Manufacturer: "Samsung"}, Quirks: Quirks{CaptionInfoHeader: true}},
+}
+
+// maxLearnedQuirks bounds the learned file; the oldest entries go first.
+const maxLearnedQuirks = 256
+
+const quirkFileVersion = 1
+
+type quirkFile struct {
+	Version   int         `json:"version,omitempty"`
+	Renderers []QuirkRule `json:"renderers"`
+}
+
+// QuirkDB looks up the quirks of a renderer: the built-in rules, the
+// quirks learned when a fallback made a renderer work, and a user-edited
+// override file. An override rule replaces everything else for the
+// renderers it matches, so it can turn a quirk off as well as on. A nil
+// QuirkDB has the built-in rules only. It is safe for concurrent use.
+type QuirkDB struct {
+	mu          sync.Mutex
+	learnedPath string
+	learned     []QuirkRule
+	overrides   []QuirkRule
+}
+
+// OpenQuirks loads the override rules at overridePath and the learned quirks
+// at learnedPath. Missing files are empty; an empty learnedPath keeps what
+// is learned for this run only.
+func OpenQuirks(overridePath, learnedPath string) (*QuirkDB, error) {
+	db := &QuirkDB{learnedPath: learnedPath}
+	overrides, err := readQuirkFile(overridePath)
+	if err != nil {
+		return nil, fmt.Errorf("renderer quirks %s: %w", overridePath, err)
+	}
+	learned, err := readQuirkFile(learnedPath)
+	if err != nil {
+		return nil, fmt.Errorf("learned renderer quirks %s: %w", learnedPath, err)
+	}
+	db.overrides, db.learned = overrides.Renderers, learned.Renderers
+	return db, nil
+}
+
+func readQuirkFile(path string) (quirkFile, error) {
+	var file quirkFile
+	if path == "" {
+		return file, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return file, err
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return file, err
+	}
+	if file.Version > quirkFileVersion {
+		return file, fmt.Errorf("unsupported version %d", file.Version)
+	}
+	return file, nil
+}
+
+// Lookup returns the quirks of the renderer described by info.
+func (db *QuirkDB) Lookup(info RendererInfo) Quirks {
+	var quirks Quirks
+	for _, rule := range builtinQuirks {
+		if rule.matches(info) {
+			quirks = quirks.or(rule.Quirks)
+		}
+	}
+	if db == nil {
+		return quirks
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, rule := range db.overrides {
+		if rule.matches(info) {
+			return rule.Quirks
+		}
+	}
+	if index := db.learnedIndex(info); index >= 0 {
+		quirks = quirks.or(db.learned[index].Quirks)
+	}
+	return quirks
+}
+
+// Learn records that the renderer described by info needs quirks and saves
+// the learned file. Renderers without a description are not recorded.
+func (db *QuirkDB) Learn(info RendererInfo, quirks Quirks) error {
+	if db == nil || !info.known() {
+		return nil
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	learned := slices.Clone(db.learned)
+	if index := db.learnedIndex(info); index >= 0 {
+		if learned[index].Quirks.or(quirks) == learned[index].Quirks {
+			return nil
+		}
+		quirks = learned[index].Quirks.or(quirks)
+		learned = slices.Delete(learned, index, index+1)
+	}
+	learned = append(learned, QuirkRule{RendererInfo: info, Quirks: quirks})
+	learned = learned[max(0, len(learned)-maxLearnedQuirks):]
+	if db.learnedPath != "" {
+		data, err := json.MarshalIndent(quirkFile{Version: quirkFileVersion, Renderers: learned}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := utils.WriteFileAtomic(db.learnedPath, append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	db.learned = learned
+	return nil
+}
+
+// learnedIndex finds the entry learned for exactly this renderer.
+func (db *QuirkDB) learnedIndex(info RendererInfo) int {
+	return slices.IndexFunc(db.learned, func(rule QuirkRule) bool {
+		return strings.EqualFold(rule.Manufacturer, info.Manufacturer) &&
+			strings.EqualFold(rule.Model, info.Model) &&
+			strings.EqualFold(rule.FriendlyName, info.FriendlyName)
+	})
+}
+
+// quirks returns the quirks of the payload's renderer.
+func (p *TVPayload) quirks() Quirks {
+	return p.Quirks.Lookup(p.Renderer)
+}
+
+// learnQuirks records quirks a fallback found the renderer to need.
+func (p *TVPayload) learnQuirks(method string, quirks Quirks) {
+	if err := p.Quirks.Learn(p.Renderer, quirks); err != nil {
+		p.Log().Error("", "Method", method, "Action", "Learn Quirks", "error", err)
+	}
+}
@@ -0,0 +1,186 @@
+package soapcalls
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestQuirkDBLookupPrecedence(t *testing.T) {
+	samsung := RendererInfo{Manufacturer: "Samsung Electronics", Model: "UE55", FriendlyName: "Living Room"}
+	if quirks := (*QuirkDB)(nil).Lookup(samsung); quirks != (Quirks{CaptionInfoHeader: true}) {
+		t.Fatalf("built-in quirks = %+v", quirks)
+	}
+
+	dir := t.TempDir()
+	overridePath := filepath.Join(dir, "renderer-quirks.json")
+	learnedPath := filepath.Join(dir, "renderer-quirks-learned.json")
+	override := `{"version": 1, "renderers": [{"model": "bravia", "quirks": {"no_metadata": true}}]}`
+	if err := os.WriteFile(overridePath, []byte(override), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenQuirks(overridePath, learnedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Learn(samsung, Quirks{MPost: true}); err != nil {
+		t.Fatal(err)
+	}
+	if quirks := db.Lookup(samsung); quirks != (Quirks{CaptionInfoHeader: true, MPost: true}) {
+		t.Fatalf("learned quirks = %+v", quirks)
+	}
+	if quirks := db.Lookup(RendererInfo{Manufacturer: "Samsung", Model: "UE55"}); quirks.MPost {
+		t.Fatalf("quirks learned for another renderer = %+v", quirks)
+	}
+
+	sony := RendererInfo{Manufacturer: "Sony", Model: "BRAVIA KD-55"}
+	if err := db.Learn(sony, Quirks{StopBeforeSetURI: true}); err != nil {
+		t.Fatal(err)
+	}
+	if quirks := db.Lookup(sony); quirks != (Quirks{NoMetadata: true}) {
+		t.Fatalf("overridden quirks = %+v", quirks)
+	}
+
+	reopened, err := OpenQuirks("", learnedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quirks := reopened.Lookup(sony); quirks != (Quirks{StopBeforeSetURI: true}) {
+		t.Fatalf("reopened learned quirks = %+v", quirks)
+	}
+	if err := reopened.Learn(RendererInfo{}, Quirks{MPost: true}); err != nil || len(reopened.learned) != 2 {
+		t.Fatalf("learned for an unknown renderer: %v, %+v", err, reopened.learned)
+	}
+}
+
+func TestOpenQuirksRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "renderer-quirks.json")
+	if err := os.WriteFile(path, []byte(`{"version": 2, "renderers": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenQuirks(path, ""); err == nil {
+		t.Fatal("OpenQuirks accepted a newer file version")
+	}
+}
+
+func TestLearnedMPostSkipsPost(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		methods []string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		mu.Unlock()
+
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	learnedPath := filepath.Join(t.TempDir(), "learned.json")
+	db, err := OpenQuirks("", learnedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer := RendererInfo{Manufacturer: "Acme", Model: "Renderer 1"}
+	p := &TVPayload{RenderingControlURL: srv.URL, Renderer: renderer, Quirks: db}
+
+	for range 2 {
+		if err := p.SetVolumeSoapCall("10"); err != nil {
+			t.Fatalf("SetVolumeSoapCall failed: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	want := []string{http.MethodPost, "M-POST", "M-POST"}
+	if len(methods) != len(want) {
+		t.Fatalf("methods = %q, want %q", methods, want)
+	}
+	for i := range want {
+		if methods[i] != want[i] {
+			t.Fatalf("methods = %q, want %q", methods, want)
+		}
+	}
+
+	reopened, err := OpenQuirks("", learnedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.Lookup(renderer).MPost {
+		t.Fatal("M-POST quirk was not saved")
+	}
+}
+
+func TestSeekFallsBackToByteOffset(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		targets []string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", "1000")
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		targets = append(targets, string(body))
+		mu.Unlock()
+
+		if !strings.Contains(string(body), "<Unit>X_DLNA_REL_BYTE</Unit>") {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`<s:Fault><detail><UPnPError><errorCode>710</errorCode><errorDescription>Seek mode not supported</errorDescription></UPnPError></detail></s:Fault>`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	learnedPath := filepath.Join(t.TempDir(), "learned.json")
+	db, err := OpenQuirks("", learnedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer := RendererInfo{Manufacturer: "Acme", Model: "Renderer 2"}
+	p := &TVPayload{ControlURL: srv.URL, MediaURL: srv.URL + "/media.mp4", MediaDuration: 100, Renderer: renderer, Quirks: db}
+
+	for _, reltime := range []string{"00:00:50", "00:00:10"} {
+		if err := p.SeekSoapCall(reltime); err != nil {
+			t.Fatalf("SeekSoapCall(%s) failed: %v", reltime, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	want := []string{"<Unit>REL_TIME</Unit><Target>00:00:50</Target>", "<Unit>X_DLNA_REL_BYTE</Unit><Target>500</Target>", "<Unit>X_DLNA_REL_BYTE</Unit><Target>100</Target>"}
+	if len(targets) != len(want) {
+		t.Fatalf("seeks = %q", targets)
+	}
+	for i := range want {
+		if !strings.Contains(targets[i], want[i]) {
+			t.Fatalf("seek %d = %s, want %s", i, targets[i], want[i])
+		}
+	}
+
+	reopened, err := OpenQuirks("", learnedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.Lookup(renderer).ByteSeek {
+		t.Fatal("byte seek quirk was not saved")
+	}
+}
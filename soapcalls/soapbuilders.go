@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -142,12 +143,24 @@ type nextURIMetaData struct {
 }
 
 type didLLite struct {
-	XMLName      xml.Name     `xml:"DIDL-Lite"`
-	SchemaDIDL   string       `xml:"xmlns,attr"`
-	DC           string       `xml:"xmlns:dc,attr"`
-	Sec          string       `xml:"xmlns:sec,attr"`
-	SchemaUPNP   string       `xml:"xmlns:upnp,attr"`
-	DIDLLiteItem didLLiteItem `xml:"item"`
+	XMLName    xml.Name        `xml:"DIDL-Lite"`
+	SchemaDIDL string          `xml:"xmlns,attr"`
+	DC         string          `xml:"xmlns:dc,attr"`
+	Sec        string          `xml:"xmlns:sec,attr"`
+	SchemaUPNP string          `xml:"xmlns:upnp,attr"`
+	Containers []didLContainer `xml:"container"`
+	Items      []didLLiteItem  `xml:"item"`
+}
+
+type didLContainer struct {
+	XMLName    xml.Name `xml:"container"`
+	DCtitle    string   `xml:"dc:title"`
+	UPNPClass  string   `xml:"upnp:class"`
+	ID         string   `xml:"id,attr"`
+	ParentID   string   `xml:"parentID,attr"`
+	Restricted string   `xml:"restricted,attr"`
+	Searchable string   `xml:"searchable,attr,omitempty"`
+	ChildCount string   `xml:"childCount,attr,omitempty"`
 }
 
 type didLLiteItem struct {
@@ -168,6 +181,7 @@ type didLLiteItem struct {
 type resNode struct {
 	XMLName      xml.Name `xml:"res"`
 	Duration     string   `xml:"duration,attr,omitempty"`
+	Size         int64    `xml:"size,attr,omitempty"`
 	ProtocolInfo string   `xml:"protocolInfo,attr"`
 	Value        string   `xml:",chardata"`
 }
@@ -343,6 +357,16 @@ func setAVTransportSoapBuildWithCompat(tvdata *TVPayload, legacyMetadataCompat b
 		return nil, fmt.Errorf("setAVTransportSoapBuild DIDL error: %w", err)
 	}
 
+	return setAVTransportSoapBuildMetadata(tvdata, a, legacyMetadataCompat)
+}
+
+// setAVTransportSoapBuildWithoutMetadata leaves CurrentURIMetaData empty, for
+// renderers that reject DIDL-Lite in any encoding.
+func setAVTransportSoapBuildWithoutMetadata(tvdata *TVPayload) ([]byte, error) {
+	return setAVTransportSoapBuildMetadata(tvdata, nil, false)
+}
+
+func setAVTransportSoapBuildMetadata(tvdata *TVPayload, a []byte, legacyMetadataCompat bool) ([]byte, error) {
 	d := setAVTransportEnvelope{
 		XMLName:  xml.Name{},
 		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
@@ -442,81 +466,166 @@ func setNextAVTransportSoapBuild(tvdata *TVPayload, clear bool) ([]byte, error)
 	return append(xmlStart, b...), nil
 }
 
-func buildDIDLLite(tvdata *TVPayload, mediaURL string, mediaMetadata metadata.Media) ([]byte, error) {
-	seekflag := "00"
-	if tvdata.Seekable {
-		seekflag = "01"
+// DIDLItem is one playable object of a DIDL-Lite document.
+type DIDLItem struct {
+	ID           string
+	ParentID     string
+	Title        string
+	Artist       string
+	Album        string
+	MediaType    string
+	URL          string
+	Duration     string // H:MM:SS
+	Size         int64
+	ArtworkURL   string
+	SubtitlesURL string
+	Seekable     bool
+	Transcode    bool
+}
+
+// DIDLContainer is one folder object of a DIDL-Lite document. A negative
+// ChildCount is left out.
+type DIDLContainer struct {
+	ID         string
+	ParentID   string
+	Title      string
+	ChildCount int
+	Searchable bool
+}
+
+// BuildDIDLLite builds a DIDL-Lite document listing containers and then
+// items, as a ContentDirectory Browse or Search result carries them.
+func BuildDIDLLite(containers []DIDLContainer, items []DIDLItem) ([]byte, error) {
+	doc := newDIDLLite()
+	for _, container := range containers {
+		node := didLContainer{
+			ID:         container.ID,
+			ParentID:   container.ParentID,
+			Restricted: "1",
+			DCtitle:    container.Title,
+			UPNPClass:  "object.container.storageFolder",
+		}
+		if container.ChildCount >= 0 {
+			node.ChildCount = strconv.Itoa(container.ChildCount)
+		}
+		if container.Searchable {
+			node.Searchable = "1"
+		}
+		doc.Containers = append(doc.Containers, node)
+	}
+	for _, item := range items {
+		node, err := didlItem(item)
+		if err != nil {
+			return nil, err
+		}
+		doc.Items = append(doc.Items, node)
 	}
+	return xml.Marshal(doc)
+}
 
-	contentFeatures, err := utils.BuildContentFeatures(tvdata.MediaType, seekflag, tvdata.Transcode)
+func buildDIDLLite(tvdata *TVPayload, mediaURL string, mediaMetadata metadata.Media) ([]byte, error) {
+	parsedMediaURL, err := url.Parse(mediaURL)
 	if err != nil {
-		return nil, fmt.Errorf("build content features: %w", err)
+		return nil, fmt.Errorf("parse media URL: %w", err)
 	}
 
-	class := "object.item.videoItem.movie"
-	switch strings.Split(tvdata.MediaType, "/")[0] {
-	case "audio":
-		class = "object.item.audioItem.musicTrack"
-	case "image":
-		class = "object.item.imageItem.photo"
+	item := DIDLItem{
+		ID:           "1",
+		ParentID:     "0",
+		Title:        mediaMetadata.Title,
+		Artist:       mediaMetadata.Artist,
+		Album:        mediaMetadata.Album,
+		MediaType:    tvdata.MediaType,
+		URL:          mediaURL,
+		SubtitlesURL: tvdata.SubtitlesURL,
+		Seekable:     tvdata.Seekable,
+		Transcode:    tvdata.Transcode,
+	}
+	if item.Title == "" {
+		item.Title = strings.TrimLeft(parsedMediaURL.Path, "/")
+	}
+	if tvdata.MediaDuration > 0 {
+		item.Duration = utils.SecondsToClockTime(int(math.Round(tvdata.MediaDuration)))
+	} else if duration, _ := utils.DurationForMedia(tvdata.FFmpegPath, tvdata.MediaPath); duration != "" {
+		item.Duration = duration
+	}
+	if mediaMetadata.Artwork != nil {
+		item.ArtworkURL = mediaMetadata.Artwork.URL
 	}
 
-	parsedMediaURL, err := url.Parse(mediaURL)
+	didl, err := didlItem(item)
 	if err != nil {
-		return nil, fmt.Errorf("parse media URL: %w", err)
+		return nil, err
 	}
+	doc := newDIDLLite()
+	doc.Items = []didLLiteItem{didl}
+	return xml.Marshal(doc)
+}
 
-	mediaTitle := mediaMetadata.Title
-	if mediaTitle == "" {
-		mediaTitle = strings.TrimLeft(parsedMediaURL.Path, "/")
+// UPNPClass returns the upnp:class of an item of mediaType.
+func UPNPClass(mediaType string) string {
+	switch strings.Split(mediaType, "/")[0] {
+	case "audio":
+		return "object.item.audioItem.musicTrack"
+	case "image":
+		return "object.item.imageItem.photo"
+	default:
+		return "object.item.videoItem.movie"
 	}
+}
 
-	resNodeData := []resNode{{
-		ProtocolInfo: fmt.Sprintf("http-get:*:%s:%s", tvdata.MediaType, contentFeatures),
-		Value:        mediaURL,
-	}}
-	if tvdata.MediaDuration > 0 {
-		resNodeData[0].Duration = utils.SecondsToClockTime(int(math.Round(tvdata.MediaDuration)))
-	} else if duration, _ := utils.DurationForMedia(tvdata.FFmpegPath, tvdata.MediaPath); duration != "" {
-		resNodeData[0].Duration = duration
+func newDIDLLite() didLLite {
+	return didLLite{
+		SchemaDIDL: "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/",
+		DC:         "http://purl.org/dc/elements/1.1/",
+		Sec:        "http://www.sec.co.kr/",
+		SchemaUPNP: "urn:schemas-upnp-org:metadata-1-0/upnp/",
 	}
+}
 
-	didl := didLLiteItem{
-		ID:         "1",
-		ParentID:   "0",
-		Restricted: "1",
-		DCtitle:    mediaTitle,
-		Artist:     mediaMetadata.Artist,
-		Album:      mediaMetadata.Album,
-		UPNPClass:  class,
-		ResNode:    resNodeData,
+func didlItem(item DIDLItem) (didLLiteItem, error) {
+	seekflag := "00"
+	if item.Seekable {
+		seekflag = "01"
 	}
-	if mediaMetadata.Artwork != nil {
-		didl.AlbumArtURI = mediaMetadata.Artwork.URL
+
+	contentFeatures, err := utils.BuildContentFeatures(item.MediaType, seekflag, item.Transcode)
+	if err != nil {
+		return didLLiteItem{}, fmt.Errorf("build content features: %w", err)
 	}
 
-	if strings.Contains(tvdata.SubtitlesURL, "srt") {
+	didl := didLLiteItem{
+		ID:          item.ID,
+		ParentID:    item.ParentID,
+		Restricted:  "1",
+		DCtitle:     item.Title,
+		Artist:      item.Artist,
+		Album:       item.Album,
+		UPNPClass:   UPNPClass(item.MediaType),
+		AlbumArtURI: item.ArtworkURL,
+		ResNode: []resNode{{
+			Duration:     item.Duration,
+			Size:         item.Size,
+			ProtocolInfo: fmt.Sprintf("http-get:*:%s:%s", item.MediaType, contentFeatures),
+			Value:        item.URL,
+		}},
+	}
+
+	if strings.Contains(item.SubtitlesURL, "srt") {
 		didl.ResNode = append(didl.ResNode, resNode{
 			ProtocolInfo: "http-get:*:text/srt:*",
-			Value:        tvdata.SubtitlesURL,
+			Value:        item.SubtitlesURL,
 		})
 		didl.SecCaptionInfo = &secCaptionInfo{
 			Type:  "srt",
-			Value: tvdata.SubtitlesURL,
+			Value: item.SubtitlesURL,
 		}
 		didl.SecCaptionInfoEx = &secCaptionInfoEx{
 			Type:  "srt",
-			Value: tvdata.SubtitlesURL,
+			Value: item.SubtitlesURL,
 		}
 	}
-
-	return xml.Marshal(didLLite{
-		SchemaDIDL:   "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/",
-		DC:           "http://purl.org/dc/elements/1.1/",
-		Sec:          "http://www.sec.co.kr/",
-		SchemaUPNP:   "urn:schemas-upnp-org:metadata-1-0/upnp/",
-		DIDLLiteItem: didl,
-	})
+	return didl, nil
 }
 
 func playSoapBuild() ([]byte, error) {
@@ -782,7 +891,14 @@ func getPositionInfoSoapBuild() ([]byte, error) {
 	return append(xmlStart, b...), nil
 }
 
-func seekSoapBuild(reltime string) ([]byte, error) {
+// Seek units: a relative time such as 00:01:30, or a byte offset for
+// renderers with the ByteSeek quirk.
+const (
+	seekUnitTime = "REL_TIME"
+	seekUnitByte = "X_DLNA_REL_BYTE"
+)
+
+func seekSoapBuild(unit, target string) ([]byte, error) {
 	d := seekEnvelope{
 		XMLName:  xml.Name{},
 		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
@@ -793,8 +909,8 @@ func seekSoapBuild(reltime string) ([]byte, error) {
 				XMLName:     xml.Name{},
 				AVTransport: "urn:schemas-upnp-org:service:AVTransport:1",
 				InstanceID:  "0",
-				Unit:        "REL_TIME",
-				Target:      reltime,
+				Unit:        unit,
+				Target:      target,
 			},
 		},
 	}
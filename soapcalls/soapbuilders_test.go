@@ -438,7 +438,7 @@ func TestSeekSoapBuild(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			out, err := seekSoapBuild(tc.target)
+			out, err := seekSoapBuild(seekUnitTime, tc.target)
 			if err != nil {
 				t.Fatalf("%s: Failed to call seekSoapBuild due to %s", tc.name, err.Error())
 			}
@@ -524,3 +524,23 @@ func TestSetAVTransportSoapBuildLegacyCompatEscapesCurrentURI(t *testing.T) {
 		t.Fatalf("expected CurrentURI to stay XML escaped, got %q", got)
 	}
 }
+
+func TestBuildDIDLLiteListsContainersThenItems(t *testing.T) {
+	got, err := BuildDIDLLite(
+		[]DIDLContainer{{ID: "r/a", ParentID: "0", Title: "Movies", ChildCount: 2, Searchable: true}},
+		[]DIDLItem{{ID: "f/a/1", ParentID: "r/a", Title: "Film", MediaType: "video/mp4", URL: "http://host/f.mp4", Duration: "0:01:00", Size: 42, Seekable: true}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	didl := string(got)
+	for _, want := range []string{
+		`<container id="r/a" parentID="0" restricted="1" searchable="1" childCount="2"><dc:title>Movies</dc:title><upnp:class>object.container.storageFolder</upnp:class></container><item id="f/a/1" parentID="r/a"`,
+		`<res duration="0:01:00" size="42" protocolInfo="http-get:*:video/mp4:`,
+		`>http://host/f.mp4</res></item></DIDL-Lite>`,
+	} {
+		if !strings.Contains(didl, want) {
+			t.Fatalf("DIDL missing %q: %s", want, didl)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 
 	"go2tv.app/go2tv/v2/internal/logging"
 	"go2tv.app/go2tv/v2/metadata"
+	"go2tv.app/go2tv/v2/utils"
 )
 
 type States struct {
@@ -58,6 +59,8 @@ type TVPayload struct {
 	RenderingControlURL         string
 	PinnedIP                    string
 	Metadata                    metadata.Media
+	Renderer                    RendererInfo
+	Quirks                      *QuirkDB
 	mu                          sync.RWMutex
 	initLogOnce                 sync.Once
 	Transcode                   bool
@@ -173,6 +176,19 @@ type getPositionInfoResponse struct {
 }
 
 func (p *TVPayload) doSOAPRequestWithMPostFallback(client *http.Client, req *http.Request, payload []byte, method string) (*http.Response, bool, error) {
+	soapAction := headerGetCaseInsensitive(req.Header, "SOAPAction")
+	if req.Method == http.MethodPost && soapAction != "" && p.quirks().MPost {
+		mpostReq, err := mpostRequest(req, payload, soapAction)
+		if err != nil {
+			return nil, true, err
+		}
+		res, err := client.Do(mpostReq)
+		if err != nil {
+			return nil, true, err
+		}
+		return res, true, nil
+	}
+
 	res, err := client.Do(req)
 	if err != nil {
 		return nil, false, err
@@ -182,7 +198,6 @@ func (p *TVPayload) doSOAPRequestWithMPostFallback(client *http.Client, req *htt
 		return res, false, nil
 	}
 
-	soapAction := headerGetCaseInsensitive(req.Header, "SOAPAction")
 	if soapAction == "" {
 		return res, false, nil
 	}
@@ -192,11 +207,30 @@ func (p *TVPayload) doSOAPRequestWithMPostFallback(client *http.Client, req *htt
 	_, _ = io.Copy(io.Discard, res.Body)
 	_ = res.Body.Close()
 
-	mpostReq, err := http.NewRequestWithContext(req.Context(), "M-POST", req.URL.String(), bytes.NewReader(payload))
+	mpostReq, err := mpostRequest(req, payload, soapAction)
 	if err != nil {
 		return nil, true, err
 	}
 
+	res, err = client.Do(mpostReq)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if isHTTPSuccess(res.StatusCode) {
+		p.learnQuirks(method, Quirks{MPost: true})
+	}
+
+	return res, true, nil
+}
+
+// mpostRequest resends req, a SOAP POST, as M-POST.
+func mpostRequest(req *http.Request, payload []byte, soapAction string) (*http.Request, error) {
+	mpostReq, err := http.NewRequestWithContext(req.Context(), "M-POST", req.URL.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
 	mpostReq.Header = req.Header.Clone()
 	mpostReq.Host = req.Host
 
@@ -213,12 +247,7 @@ func (p *TVPayload) doSOAPRequestWithMPostFallback(client *http.Client, req *htt
 	mpostReq.Header.Set("MAN", `"http://schemas.xmlsoap.org/soap/envelope/"; ns=01`)
 	mpostReq.Header.Set("01-SOAPACTION", soapAction)
 
-	res, err = client.Do(mpostReq)
-	if err != nil {
-		return nil, true, err
-	}
-
-	return res, true, nil
+	return mpostReq, nil
 }
 
 func headerGetCaseInsensitive(h http.Header, key string) string {
@@ -282,7 +311,16 @@ func (p *TVPayload) setAVTransportSoapCall() error {
 		return fmt.Errorf("setAVTransportSoapCall parse error: %w", err)
 	}
 
-	xmlData, err := setAVTransportSoapBuild(p)
+	quirks := p.quirks()
+	var xmlData []byte
+	switch {
+	case quirks.NoMetadata:
+		xmlData, err = setAVTransportSoapBuildWithoutMetadata(p)
+	case quirks.LegacyMetadata:
+		xmlData, err = setAVTransportSoapBuildWithCompat(p, true)
+	default:
+		xmlData, err = setAVTransportSoapBuild(p)
+	}
 	if err != nil {
 		p.Log().Error("", "Method", "setAVTransportSoapCall", "Action", "setAVTransportSoapBuild", "error", err)
 		return fmt.Errorf("setAVTransportSoapCall soap build error: %w", err)
@@ -360,6 +398,12 @@ func (p *TVPayload) setAVTransportSoapCall() error {
 	trySetAVTransport := func() (int, []byte, error) {
 		statusCode, resBytes, err := sendWithRetries(xmlData, "Standard", 2)
 		switch {
+		case quirks.NoMetadata || quirks.LegacyMetadata:
+			// The quirk already picked the metadata encoding.
+			if err == nil && !isHTTPSuccess(statusCode) {
+				err = fmt.Errorf("setAVTransportSoapCall HTTP status %d", statusCode)
+			}
+			return statusCode, resBytes, err
 		case err != nil:
 			p.Log().Debug("Retrying after standard SetAVTransportURI failure", "Method", "setAVTransportSoapCall", "Action", "Legacy DIDL Metadata Retry")
 		case shouldRetrySetAVTransportLegacyMetadata(statusCode, resBytes):
@@ -392,9 +436,16 @@ func (p *TVPayload) setAVTransportSoapCall() error {
 			return compatStatusCode, compatResBytes, fmt.Errorf("setAVTransportSoapCall compat HTTP status %d", compatStatusCode)
 		}
 
+		p.learnQuirks("setAVTransportSoapCall", Quirks{LegacyMetadata: true})
 		return compatStatusCode, compatResBytes, nil
 	}
 
+	if quirks.StopBeforeSetURI {
+		if stopErr := p.PlayPauseStopSoapCall("Stop"); stopErr != nil {
+			p.Log().Debug("Stop before SetAVTransportURI failed", "Method", "setAVTransportSoapCall", "Action", "Stop Before Set", "error", stopErr)
+		}
+	}
+
 	statusCode, resBytes, err := trySetAVTransport()
 	if err == nil {
 		return nil
@@ -415,6 +466,9 @@ func (p *TVPayload) setAVTransportSoapCall() error {
 		return err
 	}
 
+	if !quirks.StopBeforeSetURI {
+		p.learnQuirks("setAVTransportSoapCall", Quirks{StopBeforeSetURI: true})
+	}
 	return nil
 }
 
@@ -560,24 +614,57 @@ func (p *TVPayload) PlayPauseStopSoapCall(action string) error {
 	return nil
 }
 
-// SeekSoapCall builds and sends the AVTransport actions for Seek.
+// SeekSoapCall builds and sends the AVTransport actions for Seek. Renderers
+// with the ByteSeek quirk are sent the byte offset of reltime instead; others
+// are tried with it when they reject REL_TIME, and learn the quirk if that
+// works.
 func (p *TVPayload) SeekSoapCall(reltime string) error {
 	if p.ctx == nil {
 		p.ctx = context.Background()
 	}
 
+	if p.quirks().ByteSeek {
+		offset, err := p.seekByteOffset(reltime)
+		if err != nil {
+			p.Log().Error("", "Method", "SeekSoapCall", "Action", "Byte Offset", "error", err)
+			return fmt.Errorf("SeekSoapCall byte offset error: %w", err)
+		}
+		_, _, err = p.seekSoapCall(seekUnitByte, offset)
+		return err
+	}
+
+	status, body, err := p.seekSoapCall(seekUnitTime, reltime)
+	if err == nil || !shouldRetrySeekByByte(status, body) {
+		return err
+	}
+
+	offset, offsetErr := p.seekByteOffset(reltime)
+	if offsetErr != nil {
+		p.Log().Debug("", "Method", "SeekSoapCall", "Action", "Byte Seek Fallback", "error", offsetErr)
+		return err
+	}
+	if _, _, retryErr := p.seekSoapCall(seekUnitByte, offset); retryErr != nil {
+		return err
+	}
+	p.learnQuirks("SeekSoapCall", Quirks{ByteSeek: true})
+	return nil
+}
+
+// seekSoapCall sends one Seek action and returns the response status and body
+// along with any error.
+func (p *TVPayload) seekSoapCall(unit, target string) (int, []byte, error) {
 	parsedURLtransport, err := url.Parse(p.ControlURL)
 	if err != nil {
 		p.Log().Error("", "Method", "SeekSoapCall", "Action", "URL Parse", "error", err)
-		return fmt.Errorf("SeekSoapCall parse error: %w", err)
+		return 0, nil, fmt.Errorf("SeekSoapCall parse error: %w", err)
 	}
 
 	var xmlData []byte
 
-	xmlData, err = seekSoapBuild(reltime)
+	xmlData, err = seekSoapBuild(unit, target)
 	if err != nil {
 		p.Log().Error("", "Method", "SeekSoapCall", "Action", "Action Error", "error", err)
-		return fmt.Errorf("SeekSoapCall action error: %w", err)
+		return 0, nil, fmt.Errorf("SeekSoapCall action error: %w", err)
 	}
 
 	client := p.httpClient(parsedURLtransport)
@@ -585,7 +672,7 @@ func (p *TVPayload) SeekSoapCall(reltime string) error {
 	req, err := http.NewRequestWithContext(p.ctx, "POST", parsedURLtransport.String(), bytes.NewReader(xmlData))
 	if err != nil {
 		p.Log().Error("", "Method", "SeekSoapCall", "Action", "Prepare POST", "error", err)
-		return fmt.Errorf("SeekSoapCall POST error: %w", err)
+		return 0, nil, fmt.Errorf("SeekSoapCall POST error: %w", err)
 	}
 
 	req.Header = http.Header{
@@ -597,7 +684,7 @@ func (p *TVPayload) SeekSoapCall(reltime string) error {
 	headerBytesReq, err := json.Marshal(req.Header)
 	if err != nil {
 		p.Log().Error("", "Method", "SeekSoapCall", "Action", "Header Marshaling", "error", err)
-		return fmt.Errorf("SeekSoapCall Request Marshaling error: %w", err)
+		return 0, nil, fmt.Errorf("SeekSoapCall Request Marshaling error: %w", err)
 	}
 
 	p.Log().Debug(string(xmlData), "Method", "SeekSoapCall", "Action", "Seek Request", "Headers", json.RawMessage(headerBytesReq))
@@ -605,29 +692,81 @@ func (p *TVPayload) SeekSoapCall(reltime string) error {
 	res, _, err := p.doSOAPRequestWithMPostFallback(client, req, xmlData, "SeekSoapCall")
 	if err != nil {
 		p.Log().Error("", "Method", "SeekSoapCall", "Action", "Do POST", "error", err)
-		return fmt.Errorf("SeekSoapCall Do POST error: %w", err)
+		return 0, nil, fmt.Errorf("SeekSoapCall Do POST error: %w", err)
 	}
 	defer res.Body.Close()
 
 	resBytes, err := readCapped(res.Body, maxSOAPResponseBody)
 	if err != nil {
 		p.Log().Error("", "Method", "SeekSoapCall", "Action", "Readall", "error", err)
-		return fmt.Errorf("SeekSoapCall Failed to read response: %w", err)
+		return res.StatusCode, nil, fmt.Errorf("SeekSoapCall Failed to read response: %w", err)
 	}
 
 	headerBytesRes, err := json.Marshal(res.Header)
 	if err != nil {
 		p.Log().Error("", "Method", "SeekSoapCall", "Action", "Header Marshaling #2", "error", err)
-		return fmt.Errorf("SeekSoapCall Response Marshaling error: %w", err)
+		return res.StatusCode, resBytes, fmt.Errorf("SeekSoapCall Response Marshaling error: %w", err)
 	}
 
 	p.Log().Debug(string(resBytes), "Method", "SeekSoapCall", "Action", "Seek Response", "Status Code", strconv.Itoa(res.StatusCode), "Headers", json.RawMessage(headerBytesRes))
 
 	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
-		return fmt.Errorf("SeekSoapCall unexpected status %d: %s", res.StatusCode, strings.TrimSpace(string(resBytes)))
+		return res.StatusCode, resBytes, fmt.Errorf("SeekSoapCall unexpected status %d: %s", res.StatusCode, strings.TrimSpace(string(resBytes)))
 	}
 
-	return nil
+	return res.StatusCode, resBytes, nil
+}
+
+// shouldRetrySeekByByte reports a renderer that does not support the REL_TIME
+// seek mode (UPnP error 710).
+func shouldRetrySeekByByte(statusCode int, body []byte) bool {
+	if isHTTPSuccess(statusCode) {
+		return false
+	}
+
+	bodyLower := strings.ToLower(string(body))
+	return strings.Contains(bodyLower, "<errorcode>710</errorcode>") ||
+		strings.Contains(bodyLower, "seek mode not supported")
+}
+
+// seekByteOffset estimates the byte offset of reltime in the loaded media
+// from its size and duration. Transcoded streams have no byte offsets.
+func (p *TVPayload) seekByteOffset(reltime string) (string, error) {
+	if p.Transcode {
+		return "", errors.New("transcoded stream has no byte offsets")
+	}
+	seconds, err := utils.ClockTimeToSeconds(reltime)
+	if err != nil {
+		return "", err
+	}
+	duration := p.MediaDuration
+	if duration <= 0 {
+		info, err := p.GetPositionInfo()
+		if err != nil {
+			return "", err
+		}
+		trackSeconds, err := utils.ClockTimeToSeconds(info[0])
+		if err != nil || trackSeconds <= 0 {
+			return "", errors.New("unknown media duration")
+		}
+		duration = float64(trackSeconds)
+	}
+
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodHead, p.MediaURL, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := newHTTPClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	_ = res.Body.Close()
+	if !isHTTPSuccess(res.StatusCode) || res.ContentLength <= 0 {
+		return "", errors.New("unknown media size")
+	}
+
+	offset := int64(float64(res.ContentLength) * min(float64(seconds)/duration, 1))
+	return strconv.FormatInt(min(offset, res.ContentLength-1), 10), nil
 }
 
 // SubscribeSoapCall send a SUBSCRIBE request to the DMR device.
@@ -1112,30 +1251,71 @@ func (p *TVPayload) SetVolumeSoapCall(v string) error {
 // GetProtocolInfo retrieves the protocol information from the device.
 // It constructs a SOAP request, sends it to the device, and processes the response.
 func (p *TVPayload) GetProtocolInfo() error {
+	resBytes, err := p.protocolInfo()
+	if err != nil || resBytes == nil {
+		return err
+	}
+
+	if err := parseProtocolInfo(resBytes, p.MediaType); err != nil {
+		return fmt.Errorf("GetProtocolInfo Selected device does not support the media type: %w", err)
+	}
+
+	return nil
+}
+
+// SinkProtocolInfo returns the comma-separated protocolInfo entries the
+// device says it can play. It is empty when the device has no
+// ConnectionManager or does not answer.
+func (p *TVPayload) SinkProtocolInfo() (string, error) {
+	resBytes, err := p.protocolInfo()
+	if err != nil || resBytes == nil {
+		return "", err
+	}
+
+	var respProtocolInfo protocolInfoResponse
+	if err := xml.Unmarshal(resBytes, &respProtocolInfo); err != nil {
+		return "", fmt.Errorf("SinkProtocolInfo unmarshal error: %w", err)
+	}
+
+	return strings.TrimSpace(respProtocolInfo.Body.GetProtocolInfoResponse.Sink), nil
+}
+
+// LoadSinkProtocolInfo asks a discovered device for its SinkProtocolInfo
+// over the address its description was loaded from.
+func LoadSinkProtocolInfo(ctx context.Context, device *DMRextracted) (string, error) {
+	p := &TVPayload{ConnectionManagerURL: device.ConnectionManagerURL, PinnedIP: device.PinnedIP}
+	p.SetContext(ctx)
+	return p.SinkProtocolInfo()
+}
+
+// protocolInfo returns the device's GetProtocolInfo response. Failures to
+// reach the device are logged and give a nil response, so callers let
+// playback go ahead.
+func (p *TVPayload) protocolInfo() ([]byte, error) {
 	if p.ctx == nil {
 		p.ctx = context.Background()
 	}
 
 	if p.ConnectionManagerURL == "" {
 		p.Log().Debug("Skipping GetProtocolInfo; no ConnectionManager URL", "Method", "GetProtocolInfo")
-		return nil
+		return nil, nil
 	}
 
 	xmlbuilder, err := getProtocolInfoSoapBuild()
 	if err != nil {
 		p.Log().Error("", "Method", "GetProtocolInfo", "Action", "Build", "error", err)
-		return fmt.Errorf("GetProtocolInfo build error: %w", err)
+		return nil, fmt.Errorf("GetProtocolInfo build error: %w", err)
 	}
 
 	parsedConnectionManagerURL, parseErr := url.Parse(p.ConnectionManagerURL)
 	if parseErr != nil {
-		return fmt.Errorf("GetProtocolInfo parse error: %w", parseErr)
+		return nil, fmt.Errorf("GetProtocolInfo parse error: %w", parseErr)
 	}
 	client := p.httpClient(parsedConnectionManagerURL)
 	req, err := http.NewRequestWithContext(p.ctx, "POST", p.ConnectionManagerURL, bytes.NewReader(xmlbuilder))
 	if err != nil {
 		p.Log().Error("", "Method", "GetProtocolInfo", "Action", "Prepare POST", "error", err)
-		return fmt.Errorf("GetProtocolInfo prepare POST error: %w", err)
+		return nil, fmt.Errorf("GetProtocolInfo prepare POST error: %w", err)
 	}
 
 	req.Header = http.Header{
@@ -1147,7 +1327,7 @@ func (p *TVPayload) GetProtocolInfo() error {
 	headerBytesReq, err := json.Marshal(req.Header)
 	if err != nil {
 		p.Log().Error("", "Method", "GetProtocolInfo", "Action", "Header Marshaling failed <ignoring>", "error", err)
-		return nil
+		return nil, nil
 	}
 
 	p.Log().Debug(string(xmlbuilder), "Method", "GetProtocolInfo", "Action", "Request", "Headers", json.RawMessage(headerBytesReq))
@@ -1155,29 +1335,25 @@ func (p *TVPayload) GetProtocolInfo() error {
 	res, _, err := p.doSOAPRequestWithMPostFallback(client, req, xmlbuilder, "GetProtocolInfo")
 	if err != nil {
 		p.Log().Error("", "Method", "GetProtocolInfo", "Action", "Do POST failed <ignoring>", "error", err)
-		return nil
+		return nil, nil
 	}
 	defer res.Body.Close()
 
 	headerBytesRes, err := json.Marshal(res.Header)
 	if err != nil {
 		p.Log().Error("", "Method", "GetProtocolInfo", "Action", "Header Marshaling #2 failed <ignoring>", "error", err)
-		return nil
+		return nil, nil
 	}
 
 	resBytes, err := readCapped(res.Body, maxSOAPResponseBody)
 	if err != nil {
 		p.Log().Error("", "Method", "GetProtocolInfo", "Action", "Readall failed <ignoring>", "error", err)
-		return nil
+		return nil, nil
 	}
 
 	p.Log().Debug(string(resBytes), "Method", "GetProtocolInfo", "Action", "Response", "Status Code", strconv.Itoa(res.StatusCode), "Headers", json.RawMessage(headerBytesRes))
 
-	if err := parseProtocolInfo(resBytes, p.MediaType); err != nil {
-		return fmt.Errorf("GetProtocolInfo Selected device does not support the media type: %w", err)
-	}
-
-	return nil
+	return resBytes, nil
 }
 
 // Gapless requests our device's media info and returns the Next URI.
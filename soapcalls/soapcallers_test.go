@@ -1,6 +1,7 @@
 package soapcalls
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -125,6 +126,9 @@ func TestSetVolumeSoapCallMPostFallbackOn405(t *testing.T) {
 	if len(requests) != 2 {
 		t.Fatalf("expected 2 requests, got %d", len(requests))
 	}
+	if soapDuration.Count("SetVolume") < 2 {
+		t.Fatalf("SetVolume observations = %d, want both attempts", soapDuration.Count("SetVolume"))
+	}
 
 	if requests[0].method != http.MethodPost {
 		t.Fatalf("first request method = %q, want %q", requests[0].method, http.MethodPost)
@@ -291,6 +295,7 @@ func TestSeekSoapCallReturnsErrorOnFaultStatus(t *testing.T) {
 		ControlURL: srv.URL,
 	}
 
+	failures := soapErrors.Value("Seek")
 	err := p.SeekSoapCall("00:10:00")
 	if err == nil {
 		t.Fatal("expected SeekSoapCall to fail on SOAP fault status")
@@ -299,6 +304,9 @@ func TestSeekSoapCallReturnsErrorOnFaultStatus(t *testing.T) {
 	if !strings.Contains(err.Error(), "701") {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if soapErrors.Value("Seek") != failures+1 {
+		t.Fatalf("Seek errors = %v, want %v", soapErrors.Value("Seek"), failures+1)
+	}
 }
 
 func TestGetProtocolInfoSkipsWhenConnectionManagerMissing(t *testing.T) {
@@ -309,6 +317,25 @@ func TestGetProtocolInfoSkipsWhenConnectionManagerMissing(t *testing.T) {
 	}
 }
 
+func TestSinkProtocolInfoReturnsSinkList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:GetProtocolInfoResponse xmlns:u="urn:schemas-upnp-org:service:ConnectionManager:1"><Source></Source><Sink>
+http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_HP_HD_AAC,http-get:*:audio/mpeg:*</Sink></u:GetProtocolInfoResponse></s:Body></s:Envelope>`))
+	}))
+	defer srv.Close()
+
+	sink, err := LoadSinkProtocolInfo(context.Background(), &DMRextracted{ConnectionManagerURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink != "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_HP_HD_AAC,http-get:*:audio/mpeg:*" {
+		t.Fatalf("sink = %q", sink)
+	}
+	if sink, err := (&TVPayload{}).SinkProtocolInfo(); sink != "" || err != nil {
+		t.Fatalf("no ConnectionManager = %q, %v", sink, err)
+	}
+}
+
 func TestUpdateMRstate(t *testing.T) {
 	p := &TVPayload{
 		MediaRenderersStates:        make(map[string]*States),
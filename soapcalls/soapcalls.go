@@ -25,6 +25,7 @@ type Options struct {
 	Seek           bool
 	FFmpegSeek     int
 	Metadata       metadata.Media
+	Quirks         *QuirkDB
 }
 
 // NewTVPayload creates a new TVPayload based on the provided options.
@@ -74,6 +75,8 @@ func NewTVPayload(o *Options) (*TVPayload, error) {
 		Seekable:                    o.Seek,
 		LogOutput:                   o.LogOutput,
 		Metadata:                    o.Metadata,
+		Renderer:                    upnpServicesURLs.Renderer(),
+		Quirks:                      o.Quirks,
 	}, nil
 }
 
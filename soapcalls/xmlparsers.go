@@ -27,6 +27,8 @@ type serviceListNode struct {
 type deviceNode struct {
 	DeviceType   string          `xml:"deviceType"`
 	FriendlyName string          `xml:"friendlyName"`
+	Manufacturer string          `xml:"manufacturer"`
+	ModelName    string          `xml:"modelName"`
 	UDN          string          `xml:"UDN"`
 	ServiceList  serviceListNode `xml:"serviceList"`
 	DeviceList   []deviceNode    `xml:"deviceList>device"`
@@ -65,9 +67,16 @@ type DMRextracted struct {
 	RenderingControlURL    string
 	ConnectionManagerURL   string
 	FriendlyName           string
+	Manufacturer           string
+	ModelName              string
 	UDN                    string
 }
 
+// Renderer identifies the renderer model, for looking up its Quirks.
+func (d *DMRextracted) Renderer() RendererInfo {
+	return RendererInfo{Manufacturer: d.Manufacturer, Model: d.ModelName, FriendlyName: d.FriendlyName}
+}
+
 // DMRextractor extracts the services URLs from the main DMR xml.
 func DMRextractor(ctx context.Context, dmrurl string) (*DMRextracted, error) {
 	parsedURL, pinned, err := validateRendererLocation(ctx, dmrurl)
@@ -224,6 +233,8 @@ func extractAllServicesFromDevice(device *deviceNode, baseURL *url.URL, results
 func buildDMRExtracted(device *deviceNode, baseURL *url.URL) *DMRextracted {
 	ex := &DMRextracted{
 		FriendlyName: device.FriendlyName,
+		Manufacturer: strings.TrimSpace(device.Manufacturer),
+		ModelName:    strings.TrimSpace(device.ModelName),
 		UDN:          device.UDN,
 	}
 	hasAVTransport := false
@@ -344,3 +355,82 @@ func ParseEventNotify(xmlbody string) (EventNotify, error) {
 		CurrentTransportActions: root.EventInstance.EventCurrentTransportActions.Value,
 	}, nil
 }
+
+type didlLiteDocument struct {
+	Items []struct {
+		ID          string `xml:"id,attr"`
+		ParentID    string `xml:"parentID,attr"`
+		Title       string `xml:"title"`
+		Creator     string `xml:"creator"`
+		Artist      string `xml:"artist"`
+		Album       string `xml:"album"`
+		AlbumArtURI string `xml:"albumArtURI"`
+		CaptionInfo string `xml:"CaptionInfo"`
+		Res         []struct {
+			Duration     string `xml:"duration,attr"`
+			Size         int64  `xml:"size,attr"`
+			ProtocolInfo string `xml:"protocolInfo,attr"`
+			Value        string `xml:",chardata"`
+		} `xml:"res"`
+	} `xml:"item"`
+}
+
+// ParseDIDLLite reads the items of a DIDL-Lite document, such as the
+// CurrentURIMetaData a control point sends with SetAVTransportURI. It is the
+// reverse of BuildDIDLLite: each item takes its media type and URL from its
+// first resource that is not a subtitle, and its subtitles from the first
+// that is.
+func ParseDIDLLite(data []byte) ([]DIDLItem, error) {
+	var doc didlLiteDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("ParseDIDLLite unmarshal error: %w", err)
+	}
+	items := make([]DIDLItem, 0, len(doc.Items))
+	for _, node := range doc.Items {
+		item := DIDLItem{
+			ID:           node.ID,
+			ParentID:     node.ParentID,
+			Title:        strings.TrimSpace(node.Title),
+			Artist:       strings.TrimSpace(node.Artist),
+			Album:        strings.TrimSpace(node.Album),
+			ArtworkURL:   strings.TrimSpace(node.AlbumArtURI),
+			SubtitlesURL: strings.TrimSpace(node.CaptionInfo),
+		}
+		if item.Artist == "" {
+			item.Artist = strings.TrimSpace(node.Creator)
+		}
+		for _, res := range node.Res {
+			// protocol:network:contentFormat:additionalInfo
+			info := strings.SplitN(res.ProtocolInfo, ":", 4)
+			if len(info) < 3 {
+				continue
+			}
+			if strings.HasPrefix(info[2], "text/") {
+				if item.SubtitlesURL == "" {
+					item.SubtitlesURL = strings.TrimSpace(res.Value)
+				}
+				continue
+			}
+			if item.URL != "" {
+				continue
+			}
+			item.URL = strings.TrimSpace(res.Value)
+			item.MediaType = info[2]
+			item.Duration, _, _ = strings.Cut(res.Duration, ".")
+			item.Size = res.Size
+			if len(info) == 4 {
+				for _, flag := range strings.Split(info[3], ";") {
+					name, value, _ := strings.Cut(flag, "=")
+					switch name {
+					case "DLNA.ORG_OP":
+						item.Seekable = strings.HasSuffix(value, "1")
+					case "DLNA.ORG_CI":
+						item.Transcode = value == "1"
+					}
+				}
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
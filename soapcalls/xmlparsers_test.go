@@ -461,6 +461,8 @@ func TestDMRextractorEmbeddedDevice(t *testing.T) {
 	<device>
 		<deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType>
 		<friendlyName>Home Theater</friendlyName>
+		<manufacturer> Denon </manufacturer>
+		<modelName>AVR-X2700H</modelName>
 		<serviceList>
 		<service>
 			<serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType>
@@ -499,6 +501,10 @@ func TestDMRextractorEmbeddedDevice(t *testing.T) {
 		t.Error("RenderingControlURL should not be empty for embedded device")
 	}
 
+	if result.Manufacturer != "Denon" || result.ModelName != "AVR-X2700H" {
+		t.Errorf("renderer = %q %q, want Denon AVR-X2700H", result.Manufacturer, result.ModelName)
+	}
+
 	// Verify the URLs contain the embedded device path
 	expectedPath := "/upnp/control/renderer_dvc/AVTransport"
 	if !containsPath(result.AvtransportControlURL, expectedPath) {
@@ -745,3 +751,50 @@ func TestLoadDevicesFromLocationMultiple(t *testing.T) {
 		t.Error("Kitchen not found")
 	}
 }
+
+func TestParseDIDLLiteReversesBuild(t *testing.T) {
+	built, err := BuildDIDLLite(nil, []DIDLItem{{
+		ID:           "1",
+		ParentID:     "0",
+		Title:        "Film & Friends",
+		Artist:       "Someone",
+		MediaType:    "video/mp4",
+		URL:          "http://phone:8080/film.mp4",
+		Duration:     "1:02:03",
+		Size:         42,
+		ArtworkURL:   "http://phone:8080/film.jpg",
+		SubtitlesURL: "http://phone:8080/film.srt",
+		Seekable:     true,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := ParseDIDLLite(built)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DIDLItem{
+		ID:           "1",
+		ParentID:     "0",
+		Title:        "Film & Friends",
+		Artist:       "Someone",
+		MediaType:    "video/mp4",
+		URL:          "http://phone:8080/film.mp4",
+		Duration:     "1:02:03",
+		Size:         42,
+		ArtworkURL:   "http://phone:8080/film.jpg",
+		SubtitlesURL: "http://phone:8080/film.srt",
+		Seekable:     true,
+	}
+	if len(items) != 1 || items[0] != want {
+		t.Fatalf("items = %+v, want %+v", items, want)
+	}
+
+	items, err = ParseDIDLLite([]byte(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/"><item id="a" parentID="b" restricted="1"><dc:title>Song</dc:title><dc:creator>Band</dc:creator><res protocolInfo="http-get:*:audio/flac:*" duration="0:03:10.500">http://phone/song.flac</res></item></DIDL-Lite>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Artist != "Band" || items[0].MediaType != "audio/flac" || items[0].Duration != "0:03:10" || items[0].Seekable {
+		t.Fatalf("items = %+v", items)
+	}
+}
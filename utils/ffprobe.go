@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -27,6 +28,9 @@ type ffprobeInfo struct {
 		SampleRate string `json:"sample_rate"`
 		Width      int    `json:"width"`
 		Height     int    `json:"height"`
+		Tags       struct {
+			Language string `json:"language"`
+		} `json:"tags"`
 	} `json:"streams"`
 }
 
@@ -36,6 +40,13 @@ type MediaCodecInfo struct {
 	AudioCodec    string
 	AudioChannels int
 	Container     string
+	// Duration is in seconds, 0 when the container does not report one.
+	Duration float64
+	Width    int
+	Height   int
+	// AudioLanguages lists the language tags of the audio streams in stream
+	// order, without repeats.
+	AudioLanguages []string
 }
 
 func DurationForMedia(ffmpeg string, f string) (string, error) {
@@ -91,20 +102,43 @@ func DurationForMediaSeconds(ffmpeg string, f string) (float64, error) {
 // requiring a filesystem path. Seekable files use ffmpeg's fd protocol;
 // other readers fall back to stdin's pipe protocol. The input offset is
 // restored when the reader supports seeking.
-func DurationForMediaReaderSeconds(ctx context.Context, ffmpeg string, media io.ReadSeekCloser) (seconds float64, err error) {
+func DurationForMediaReaderSeconds(ctx context.Context, ffmpeg string, media io.ReadSeekCloser) (float64, error) {
+	var info ffprobeInfo
+	if err := probeReader(ctx, ffmpeg, media, &info, "-show_format"); err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.ParseFloat(info.Format.Duration, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse ffprobe duration: %w", err)
+	}
+	return seconds, nil
+}
+
+// GetMediaCodecInfoReader is GetMediaCodecInfo for a media handle, read the
+// way DurationForMediaReaderSeconds reads it.
+func GetMediaCodecInfoReader(ctx context.Context, ffmpeg string, media io.ReadSeekCloser) (*MediaCodecInfo, error) {
+	var info ffprobeInfo
+	if err := probeReader(ctx, ffmpeg, media, &info, "-show_format", "-show_streams"); err != nil {
+		return nil, err
+	}
+	return mediaCodecInfo(info), nil
+}
+
+// probeReader runs ffprobe on media and decodes its JSON output into out.
+func probeReader(ctx context.Context, ffmpeg string, media io.ReadSeekCloser, out any, show ...string) (err error) {
 	if ctx == nil {
-		return 0, errors.New("ffprobe context required")
+		return errors.New("ffprobe context required")
 	}
 	if media == nil {
-		return 0, ErrInvalidInput
+		return ErrInvalidInput
 	}
 	if err := ctx.Err(); err != nil {
-		return 0, err
+		return err
 	}
 
 	ffprobePath, err := ResolveFFprobePath(ffmpeg)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
 	input := io.Reader(media)
@@ -118,7 +152,7 @@ func DurationForMediaReaderSeconds(ctx context.Context, ffmpeg string, media io.
 		offset, seekErr := seeker.Seek(0, io.SeekCurrent)
 		if seekErr == nil {
 			if _, seekErr = seeker.Seek(0, io.SeekStart); seekErr != nil {
-				return 0, fmt.Errorf("rewind ffprobe input: %w", seekErr)
+				return fmt.Errorf("rewind ffprobe input: %w", seekErr)
 			}
 			defer func() {
 				if _, restoreErr := seeker.Seek(offset, io.SeekStart); err == nil && restoreErr != nil {
@@ -128,33 +162,23 @@ func DurationForMediaReaderSeconds(ctx context.Context, ffmpeg string, media io.
 		}
 	}
 
-	cmd := exec.CommandContext(
-		ctx,
-		ffprobePath,
-		"-loglevel", "error",
-		"-show_format",
-		"-of", "json",
-		inputURL,
-	)
+	args := append([]string{"-loglevel", "error"}, show...)
+	args = append(args, "-of", "json", inputURL)
+	cmd := exec.CommandContext(ctx, ffprobePath, args...)
 	setSysProcAttr(cmd)
 	cmd.Stdin = input
 	output, err := cmd.Output()
 	if ctxErr := ctx.Err(); ctxErr != nil {
-		return 0, ctxErr
+		return ctxErr
 	}
 	if err != nil {
-		return 0, fmt.Errorf("ffprobe duration: %w", err)
+		return fmt.Errorf("ffprobe: %w", err)
 	}
 
-	var info ffprobeInfo
-	if err := json.Unmarshal(output, &info); err != nil {
-		return 0, fmt.Errorf("decode ffprobe duration: %w", err)
+	if err := json.Unmarshal(output, out); err != nil {
+		return fmt.Errorf("decode ffprobe output: %w", err)
 	}
-	seconds, err = strconv.ParseFloat(info.Format.Duration, 64)
-	if err != nil {
-		return 0, fmt.Errorf("parse ffprobe duration: %w", err)
-	}
-	return seconds, nil
+	return nil
 }
 
 func GetMediaCodecInfo(ffmpeg string, f string) (*MediaCodecInfo, error) {
@@ -192,22 +216,34 @@ func GetMediaCodecInfo(ffmpeg string, f string) (*MediaCodecInfo, error) {
 		return nil, err
 	}
 
+	return mediaCodecInfo(info), nil
+}
+
+func mediaCodecInfo(info ffprobeInfo) *MediaCodecInfo {
 	result := &MediaCodecInfo{
 		Container: info.Format.FormatName,
 	}
+	if seconds, err := strconv.ParseFloat(info.Format.Duration, 64); err == nil {
+		result.Duration = seconds
+	}
 
 	for _, stream := range info.Streams {
 		switch stream.CodecType {
 		case "video":
 			result.VideoCodec = stream.CodecName
 			result.VideoProfile = stream.Profile
+			result.Width = stream.Width
+			result.Height = stream.Height
 		case "audio":
 			result.AudioCodec = stream.CodecName
 			result.AudioChannels = stream.Channels
+			if language := stream.Tags.Language; language != "" && !slices.Contains(result.AudioLanguages, language) {
+				result.AudioLanguages = append(result.AudioLanguages, language)
+			}
 		}
 	}
 
-	return result, nil
+	return result
 }
 
 func IsChromecastCompatible(info *MediaCodecInfo) bool {
@@ -33,7 +33,11 @@ if [ "$GO2TV_PROBE_BLOCK" = "1" ]; then
 fi
 printf '%s\n' "$@" > "$GO2TV_PROBE_ARGS"
 cat > "$GO2TV_PROBE_STDIN"
-printf '{"format":{"duration":"12.75"}}'
+if [ -n "$GO2TV_PROBE_OUTPUT" ]; then
+	printf '%s' "$GO2TV_PROBE_OUTPUT"
+else
+	printf '{"format":{"duration":"12.75"}}'
+fi
 `
 	if err := os.WriteFile(ffprobe, []byte(script), 0o700); err != nil {
 		t.Fatal(err)
@@ -141,6 +145,41 @@ func TestDurationForMediaReaderSecondsPipeFallback(t *testing.T) {
 	})
 }
 
+func TestGetMediaCodecInfoReader(t *testing.T) {
+	ffmpeg, argsFile, _ := writeDurationProbeTools(t)
+	t.Setenv("GO2TV_PROBE_OUTPUT", `{"format":{"format_name":"matroska,webm","duration":"60"},"streams":[{"codec_type":"video","codec_name":"h264"},{"codec_type":"audio","codec_name":"ac3","channels":6}]}`)
+	media := &memoryReadSeekCloser{bytes.NewReader([]byte("matroska"))}
+
+	info, err := GetMediaCodecInfoReader(context.Background(), ffmpeg, media)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.VideoCodec != "h264" || info.AudioCodec != "ac3" || info.AudioChannels != 6 || info.Duration != 60 {
+		t.Fatalf("info = %+v", info)
+	}
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "-show_streams") {
+		t.Fatalf("ffprobe args = %q", data)
+	}
+}
+
+func TestGetSubsReader(t *testing.T) {
+	ffmpeg, _, _ := writeDurationProbeTools(t)
+	t.Setenv("GO2TV_PROBE_OUTPUT", `{"streams":[{"codec_type":"video","index":0},{"codec_type":"subtitle","index":1,"tags":{"language":"eng","title":"Full"}}]}`)
+	subs, err := GetSubsReader(context.Background(), ffmpeg, &memoryReadSeekCloser{bytes.NewReader([]byte("matroska"))})
+	if err != nil || len(subs) != 1 || subs[0] != "Full (eng)" {
+		t.Fatalf("subs = %q, %v", subs, err)
+	}
+
+	t.Setenv("GO2TV_PROBE_OUTPUT", `{"streams":[{"codec_type":"audio","index":0}]}`)
+	if _, err := GetSubsReader(context.Background(), ffmpeg, &memoryReadSeekCloser{bytes.NewReader([]byte("matroska"))}); !errors.Is(err, ErrNoSubs) {
+		t.Fatalf("no subs error = %v", err)
+	}
+}
+
 func TestDurationForMediaReaderSecondsContext(t *testing.T) {
 	ffmpeg, _, _ := writeDurationProbeTools(t)
 	t.Setenv("GO2TV_PROBE_BLOCK", "1")
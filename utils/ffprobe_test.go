@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 )
@@ -157,3 +159,26 @@ func TestFormatDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestMediaCodecInfoFromProbe(t *testing.T) {
+	var info ffprobeInfo
+	if err := json.Unmarshal([]byte(`{
+		"format": {"duration": "5400.250000", "format_name": "matroska,webm"},
+		"streams": [
+			{"codec_type": "video", "codec_name": "hevc", "profile": "Main 10", "width": 3840, "height": 2160},
+			{"codec_type": "audio", "codec_name": "eac3", "channels": 6, "tags": {"language": "eng"}},
+			{"codec_type": "audio", "codec_name": "aac", "channels": 2, "tags": {"language": "ger"}},
+			{"codec_type": "audio", "codec_name": "aac", "channels": 2, "tags": {"language": "eng"}},
+			{"codec_type": "subtitle", "codec_name": "subrip", "tags": {"language": "eng"}}
+		]
+	}`), &info); err != nil {
+		t.Fatal(err)
+	}
+	got := mediaCodecInfo(info)
+	if got.Duration != 5400.25 || got.Width != 3840 || got.Height != 2160 || got.VideoCodec != "hevc" || got.AudioCodec != "aac" || got.Container != "matroska,webm" {
+		t.Fatalf("info = %+v", got)
+	}
+	if strings.Join(got.AudioLanguages, ",") != "eng,ger" {
+		t.Fatalf("audio languages = %q", got.AudioLanguages)
+	}
+}
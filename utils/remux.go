@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// RemuxFormat is the container a remux writes.
+type RemuxFormat string
+
+const (
+	// RemuxMPEGTS writes MPEG-TS, as the DLNA transcode does.
+	RemuxMPEGTS RemuxFormat = "mpegts"
+	// RemuxFragmentedMP4 writes MP4 fragments that play while they arrive.
+	RemuxFragmentedMP4 RemuxFormat = "mp4"
+)
+
+// ServeRemuxedStream copies the first video and audio streams of input into
+// format without re-encoding them, so only the container changes. It takes
+// its input the way ServeTranscodedStream does; opts.SeekSeconds restarts the
+// stream at the keyframe before that position, and opts.SubsPath is ignored
+// as burning subtitles needs a re-encode.
+func ServeRemuxedStream(ctx context.Context, w io.Writer, input any, ff *exec.Cmd, opts *TranscodeOptions, format RemuxFormat) error {
+	if opts == nil || opts.FFmpegPath == "" {
+		return ErrInvalidInput
+	}
+	if r, ok := input.(io.Reader); ok {
+		if f, ok := underlyingOSFile(r); ok {
+			input = f
+		}
+	}
+
+	var in string
+	switch f := input.(type) {
+	case string:
+		in = f
+	case *os.File:
+		in = ffmpegInputForFile(opts.FFmpegPath, f)
+	case io.Reader:
+		in = "pipe:0"
+	default:
+		return ErrInvalidInput
+	}
+
+	var muxArgs []string
+	switch format {
+	case RemuxMPEGTS:
+		muxArgs = []string{"-f", "mpegts"}
+	case RemuxFragmentedMP4:
+		muxArgs = []string{"-movflags", "+frag_keyframe+empty_moov+default_base_moof", "-f", "mp4"}
+	default:
+		return ErrInvalidInput
+	}
+
+	if ff != nil && ff.Process != nil {
+		_ = ff.Process.Kill()
+	}
+
+	args := []string{opts.FFmpegPath}
+	if in != "pipe:0" && opts.SeekSeconds > 0 {
+		args = append(args, "-ss", strconv.Itoa(opts.SeekSeconds), "-copyts")
+	}
+	args = append(
+		args,
+		"-i", in,
+		// Only the main streams; subtitle and data tracks often have no
+		// mapping in the target container.
+		"-map", "0:v:0",
+		"-map", "0:a:0?",
+		"-c", "copy",
+	)
+	args = append(args, muxArgs...)
+	args = append(args, "pipe:1")
+
+	_, err := runFFmpegTranscode(ctx, ff, input, in, w, args)
+	return err
+}
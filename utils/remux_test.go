@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestServeRemuxedStreamCopiesCodecs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell-script fake ffmpeg test skipped on windows")
+	}
+
+	dir := t.TempDir()
+	ffmpegPath := filepath.Join(dir, "ffmpeg")
+	argsPath := filepath.Join(dir, "args")
+	script := `#!/bin/sh
+printf '%s\n' "$@" > "$GO2TV_REMUX_ARGS"
+printf 'stream'
+`
+	if err := os.WriteFile(ffmpegPath, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GO2TV_REMUX_ARGS", argsPath)
+	run := func(seek int, format RemuxFormat) ([]string, string) {
+		t.Helper()
+		var command exec.Cmd
+		var out bytes.Buffer
+		if err := ServeRemuxedStream(context.Background(), &out, "movie.mkv", &command, &TranscodeOptions{FFmpegPath: ffmpegPath, SeekSeconds: seek}, format); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(argsPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Fields(string(data)), out.String()
+	}
+
+	args, out := run(0, RemuxMPEGTS)
+	if out != "stream" {
+		t.Fatalf("output = %q", out)
+	}
+	copyAt := slices.Index(args, "-c")
+	if copyAt < 0 || args[copyAt+1] != "copy" || slices.Contains(args, "-vf") || slices.Contains(args, "-ss") {
+		t.Fatalf("remux args = %q", args)
+	}
+	if format := slices.Index(args, "-f"); format < 0 || args[format+1] != "mpegts" {
+		t.Fatalf("MPEG-TS args = %q", args)
+	}
+
+	args, _ = run(42, RemuxFragmentedMP4)
+	seek, input := slices.Index(args, "-ss"), slices.Index(args, "-i")
+	if seek < 0 || args[seek+1] != "42" || seek > input || !slices.Contains(args, "-copyts") {
+		t.Fatalf("seek args = %q", args)
+	}
+	if format := slices.Index(args, "-f"); format < 0 || args[format+1] != "mp4" || !slices.Contains(args, "+frag_keyframe+empty_moov+default_base_moof") {
+		t.Fatalf("fragmented MP4 args = %q", args)
+	}
+
+	var command exec.Cmd
+	if err := ServeRemuxedStream(context.Background(), &bytes.Buffer{}, "movie.mkv", &command, &TranscodeOptions{FFmpegPath: ffmpegPath}, "avi"); err != ErrInvalidInput {
+		t.Fatalf("unknown format error = %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"os"
 	"os/exec"
 	"strconv"
@@ -78,6 +80,26 @@ func GetSubs(ffmpeg string, f string) ([]string, error) {
 	return out, nil
 }
 
+// GetSubsReader is GetSubs for a media handle, read the way
+// DurationForMediaReaderSeconds reads it.
+func GetSubsReader(ctx context.Context, ffmpeg string, media io.ReadSeekCloser) ([]string, error) {
+	var info ffprobeInfoforSubs
+	if err := probeReader(ctx, ffmpeg, media, &info, "-show_streams"); err != nil {
+		return nil, err
+	}
+
+	out, err := subtitleNames(info.Streams)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(out) == 0 {
+		return nil, ErrNoSubs
+	}
+
+	return out, nil
+}
+
 func subtitleNames(streams []streams) ([]string, error) {
 	out := make([]string, 0)
 
@@ -8,6 +8,14 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
+
+	"go2tv.app/go2tv/v2/internal/metrics"
+)
+
+var (
+	transcodeProcesses = metrics.NewGauge("go2tv_transcode_processes", "Running ffmpeg transcode processes.")
+	transcodeSeconds   = metrics.NewCounter("go2tv_transcode_seconds_total", "Wall-clock run time of finished ffmpeg transcode processes.")
 )
 
 type countingWriter struct {
@@ -55,9 +63,14 @@ func runFFmpegTranscode(
 		return 0, fmt.Errorf("%w: %s", err, tailFFmpegStderr(strings.TrimSpace(stderr.String()), 240))
 	}
 
+	transcodeProcesses.Add(1)
+	started := time.Now()
 	done := make(chan error, 1)
 	go func() {
-		done <- ff.Wait()
+		err := ff.Wait()
+		transcodeProcesses.Add(-1)
+		transcodeSeconds.Add(time.Since(started).Seconds())
+		done <- err
 	}()
 
 	select {